@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// TestBuildDB tests that the generated file is a valid SQLite database with the CSV contents
+func TestBuildDB(t *testing.T) {
+	tmpDir := t.TempDir()
+	csvPath := filepath.Join(tmpDir, "test.csv")
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	content := `ip,city,country
+8.8.8.8,Mountain View,United States
+1.1.1.1,Sydney,Australia`
+
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	count, err := buildDB(csvPath, dbPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 records written, got %d", count)
+	}
+
+	sqliteStore, err := store.NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("generated file is not a valid SQLite database: %v", err)
+	}
+	defer sqliteStore.Close()
+
+	location, err := sqliteStore.FindByIP(context.Background(), "1.1.1.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Sydney" {
+		t.Errorf("expected city 'Sydney', got '%s'", location.City)
+	}
+}
+
+// TestBuildDB_MatchesRepositoryData tests the generate step on the real dataset
+func TestBuildDB_MatchesRepositoryData(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ip2country.db")
+
+	count, err := buildDB("../../data/ip2country.csv", dbPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count == 0 {
+		t.Error("expected records from data/ip2country.csv")
+	}
+}
+
+// TestBuildDB_MissingCSV tests that a missing source file is reported
+func TestBuildDB_MissingCSV(t *testing.T) {
+	_, err := buildDB("/nonexistent/file.csv", filepath.Join(t.TempDir(), "out.db"))
+
+	if err == nil {
+		t.Error("expected error for missing CSV, got nil")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// This tool converts the CSV dataset into an SQLite database
+// It is normally run through go generate (see data/embed.go):
+//
+//	go generate ./data
+//
+// Usage: go run ./cmd/builddb -csv data/ip2country.csv -out data/ip2country.db
+func main() {
+	csvPath := flag.String("csv", "data/ip2country.csv", "source CSV file (ip,city,country)")
+	outPath := flag.String("out", "data/ip2country.db", "SQLite database to create (replaced if it exists)")
+	flag.Parse()
+
+	count, err := buildDB(*csvPath, *outPath)
+	if err != nil {
+		log.Fatalf("Failed to build SQLite database: %v", err)
+	}
+
+	fmt.Printf("✅ Wrote %d records from %s to %s\n", count, *csvPath, *outPath)
+}
+
+// buildDB loads the CSV file and writes its records to an SQLite database
+func buildDB(csvPath, outPath string) (int, error) {
+	csvStore, err := store.NewCSVStore(csvPath)
+	if err != nil {
+		return 0, err
+	}
+	defer csvStore.Close()
+
+	return store.BuildSQLiteDatabase(outPath, csvStore)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// compress writes a gzip copy of the file at inPath to outPath and returns both sizes
+// The output is written to a temporary file and renamed into place, so a CSV store
+// watching outPath never reads a half-written file
+func compress(inPath, outPath string) (int64, int64, error) {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open input: %w", err)
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create output: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	gz, err := gzip.NewWriterLevel(tmp, gzip.BestCompression)
+	if err != nil {
+		tmp.Close()
+		return 0, 0, err
+	}
+	read, err := io.Copy(gz, in)
+	if err != nil {
+		tmp.Close()
+		return 0, 0, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return 0, 0, fmt.Errorf("failed to compress: %w", err)
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return 0, 0, fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		return 0, 0, fmt.Errorf("failed to write output: %w", err)
+	}
+	return read, info.Size(), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// TestCompress tests that the compressed file loads into a CSV store with the same records
+func TestCompress(t *testing.T) {
+	tmpDir := t.TempDir()
+	csvPath := filepath.Join(tmpDir, "test.csv")
+	gzPath := filepath.Join(tmpDir, "test.csv.gz")
+
+	content := `ip,city,country
+8.8.8.8,Mountain View,United States
+1.1.1.1,Sydney,Australia`
+
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	in, out, err := compress(csvPath, gzPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if in != int64(len(content)) || out == 0 {
+		t.Errorf("expected %d bytes in and a non-empty output, got %d and %d", len(content), in, out)
+	}
+
+	csvStore, err := store.NewCSVStore(gzPath)
+	if err != nil {
+		t.Fatalf("compressed file is not a valid CSV file: %v", err)
+	}
+	defer csvStore.Close()
+
+	location, err := csvStore.FindByIP(context.Background(), "1.1.1.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Sydney" {
+		t.Errorf("expected city 'Sydney', got '%s'", location.City)
+	}
+
+	// Only the output is left behind, no temporary file
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 2 {
+		t.Errorf("expected the input and output files only, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+// This tool gzip-compresses a CSV dataset for the CSV store, which reads .gz files natively
+// A 200MB CSV typically compresses to about 20MB
+//
+// Usage: go run ./cmd/compress -in data/ip2country.csv -out data/ip2country.csv.gz
+func main() {
+	inPath := flag.String("in", "data/ip2country.csv", "CSV file to compress")
+	outPath := flag.String("out", "", "compressed file to create, replaced if it exists (default: the input path + .gz)")
+	flag.Parse()
+
+	if *outPath == "" {
+		*outPath = *inPath + ".gz"
+	}
+
+	in, out, err := compress(*inPath, *outPath)
+	if err != nil {
+		log.Fatalf("Failed to compress CSV file: %v", err)
+	}
+
+	fmt.Printf("✅ Compressed %s (%d bytes) to %s (%d bytes)\n", *inPath, in, *outPath, out)
+}
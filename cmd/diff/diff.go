@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// Exit codes, like diff(1)
+const (
+	exitNoDiffs = 0
+	exitDiffs   = 1
+	exitError   = 2
+)
+
+// DefaultWorkers is the number of lookups run in parallel when --workers is not set
+const DefaultWorkers = 8
+
+// diffHeader is the first line of the output, written even when there are no discrepancies
+var diffHeader = []string{"ip", "source_city", "source_country", "target_city", "target_country"}
+
+// errLookupFailed stops iteration once a worker has failed; the worker's error is returned instead
+var errLookupFailed = errors.New("lookup failed")
+
+// discrepancy is one output row
+// A nil side is an IP missing from that store; its columns are left empty
+type discrepancy struct {
+	IP     string
+	Source *models.IPLocation
+	Target *models.IPLocation
+}
+
+// record returns the CSV row for d
+func (d discrepancy) record() []string {
+	row := []string{d.IP, "", "", "", ""}
+	if d.Source != nil {
+		row[1], row[2] = d.Source.City, d.Source.Country
+	}
+	if d.Target != nil {
+		row[3], row[4] = d.Target.City, d.Target.Country
+	}
+	return row
+}
+
+// diffStores compares every record of source and target and writes the discrepancies to out as CSV
+// Records whose city or country differ are written with both sides; records in only one store
+// are written with the other side empty. Rows are sorted by IP. Returns the number of rows written.
+// Both stores must implement store.Iterator
+func diffStores(source, target store.Store, out io.Writer, workers int) (int, error) {
+	sourceIterator, ok := source.(store.Iterator)
+	if !ok {
+		return 0, fmt.Errorf("source store does not support iteration")
+	}
+	targetIterator, ok := target.(store.Iterator)
+	if !ok {
+		return 0, fmt.Errorf("target store does not support iteration")
+	}
+
+	// Source records are looked up in the target: different or missing
+	diffs, err := compare(sourceIterator, target, workers, func(location, found *models.IPLocation) (discrepancy, bool) {
+		if found == nil {
+			return discrepancy{IP: location.IP, Source: location}, true
+		}
+		if found.City != location.City || found.Country != location.Country {
+			return discrepancy{IP: location.IP, Source: location, Target: found}, true
+		}
+		return discrepancy{}, false
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compare source records: %w", err)
+	}
+
+	// Target records are looked up in the source only to find the ones missing there,
+	// since differing records were already found above
+	missing, err := compare(targetIterator, source, workers, func(location, found *models.IPLocation) (discrepancy, bool) {
+		if found == nil {
+			return discrepancy{IP: location.IP, Target: location}, true
+		}
+		return discrepancy{}, false
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compare target records: %w", err)
+	}
+	diffs = append(diffs, missing...)
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].IP < diffs[j].IP
+	})
+
+	writer := csv.NewWriter(out)
+	writer.Write(diffHeader)
+	for _, d := range diffs {
+		writer.Write(d.record())
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, fmt.Errorf("failed to write output: %w", err)
+	}
+	return len(diffs), nil
+}
+
+// compare looks up every record of iterator in other, from workers goroutines, and returns
+// the discrepancies check reports. check receives nil when other doesn't have the IP
+func compare(iterator store.Iterator, other store.Store, workers int, check func(location, found *models.IPLocation) (discrepancy, bool)) ([]discrepancy, error) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	var (
+		mu       sync.Mutex
+		diffs    []discrepancy
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	queue := make(chan *models.IPLocation, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for location := range queue {
+				found, err := other.FindByIP(context.Background(), location.IP)
+				if err != nil {
+					if !errors.Is(err, pkerr.ErrNotFound) {
+						fail(fmt.Errorf("lookup of %s failed: %w", location.IP, err))
+						continue
+					}
+					found = nil
+				}
+
+				if d, ok := check(location, found); ok {
+					mu.Lock()
+					diffs = append(diffs, d)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	err := iterator.Iterate(func(location *models.IPLocation) error {
+		// Stop feeding the workers once a lookup has failed
+		if failed() {
+			return errLookupFailed
+		}
+		queue <- location
+		return nil
+	})
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+// exitCode maps the result of diffStores to the process exit code
+func exitCode(diffs int, err error) int {
+	switch {
+	case err != nil:
+		return exitError
+	case diffs > 0:
+		return exitDiffs
+	default:
+		return exitNoDiffs
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// newDiffMockStores returns two stores with the same 3 records, except for the city of 1.1.1.1
+func newDiffMockStores() (*store.MockStore, *store.MockStore) {
+	source := store.NewEmptyMockStore()
+	source.Data = map[string]*models.IPLocation{
+		"8.8.8.8": {IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		"1.1.1.1": {IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+		"9.9.9.9": {IP: "9.9.9.9", City: "Berkeley", Country: "United States"},
+	}
+
+	target := store.NewEmptyMockStore()
+	target.Data = map[string]*models.IPLocation{
+		"8.8.8.8": {IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		"1.1.1.1": {IP: "1.1.1.1", City: "Melbourne", Country: "Australia"},
+		"9.9.9.9": {IP: "9.9.9.9", City: "Berkeley", Country: "United States"},
+	}
+	return source, target
+}
+
+// readDiff parses the CSV output of diffStores
+func readDiff(t *testing.T, out *bytes.Buffer) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV output: %v", err)
+	}
+	return records
+}
+
+// TestDiffStores tests that only the differing record is written, after the header
+func TestDiffStores(t *testing.T) {
+	source, target := newDiffMockStores()
+	var out bytes.Buffer
+
+	count, err := diffStores(source, target, &out, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 discrepancy, got %d", count)
+	}
+
+	records := readDiff(t, &out)
+	expected := [][]string{
+		diffHeader,
+		{"1.1.1.1", "Sydney", "Australia", "Melbourne", "Australia"},
+	}
+	if !slices.EqualFunc(records, expected, slices.Equal) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+	if code := exitCode(count, err); code != exitDiffs {
+		t.Errorf("expected exit code %d, got %d", exitDiffs, code)
+	}
+}
+
+// TestDiffStores_Missing tests that records in only one store are written with the other side empty
+func TestDiffStores_Missing(t *testing.T) {
+	source, target := newDiffMockStores()
+	target.Data["1.1.1.1"] = source.Data["1.1.1.1"]
+	delete(target.Data, "9.9.9.9")
+	target.Data["2.2.2.2"] = &models.IPLocation{IP: "2.2.2.2", City: "Paris", Country: "France"}
+	var out bytes.Buffer
+
+	count, err := diffStores(source, target, &out, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := [][]string{
+		diffHeader,
+		{"2.2.2.2", "", "", "Paris", "France"},
+		{"9.9.9.9", "Berkeley", "United States", "", ""},
+	}
+	if records := readDiff(t, &out); !slices.EqualFunc(records, expected, slices.Equal) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 discrepancies, got %d", count)
+	}
+}
+
+// TestDiffStores_NoDiffs tests that identical stores produce the header only and exit code 0
+func TestDiffStores_NoDiffs(t *testing.T) {
+	source, _ := newDiffMockStores()
+	target, _ := newDiffMockStores()
+	var out bytes.Buffer
+
+	count, err := diffStores(source, target, &out, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := out.String(); got != strings.Join(diffHeader, ",")+"\n" {
+		t.Errorf("expected the header only, got %q", got)
+	}
+	if code := exitCode(count, err); code != exitNoDiffs {
+		t.Errorf("expected exit code %d, got %d", exitNoDiffs, code)
+	}
+}
+
+// TestDiffStores_LookupError tests that a failed lookup is an error, not a missing record
+func TestDiffStores_LookupError(t *testing.T) {
+	source, target := newDiffMockStores()
+	target.FindByIPError = errors.New("connection refused")
+
+	count, err := diffStores(source, target, &bytes.Buffer{}, 4)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the lookup error, got %v", err)
+	}
+	if code := exitCode(count, err); code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// This tool compares the records of two store backends and writes the discrepancies as CSV:
+// ip,source_city,source_country,target_city,target_country
+// Connection settings come from the usual environment variables / .env file, and can be
+// overridden per store as type:location (a file path for sqlite/csv, a DSN for mysql, an address for redis)
+// Exit status is 0 when the stores match, 1 when discrepancies were found and 2 on error
+//
+// Usage:
+//
+//	go run ./cmd/diff --source redis --target mysql > diffs.csv
+//	go run ./cmd/diff --source csv:data/ip2country.csv --target redis:replica:6379 --workers 32
+func main() {
+	source := flag.String("source", "", "store to compare: sqlite, csv, mysql or redis, optionally as type:location")
+	target := flag.String("target", "", "store to compare against, in the same format as --source")
+	workers := flag.Int("workers", DefaultWorkers, "number of lookups to run in parallel")
+	flag.Parse()
+
+	os.Exit(run(*source, *target, *workers))
+}
+
+// run compares the two stores and returns the exit code
+func run(source, target string, workers int) int {
+	if source == "" || target == "" {
+		fmt.Fprintln(os.Stderr, "Both --source and --target are required")
+		return exitError
+	}
+
+	appConfig := config.Load()
+	sourceStore, err := openStore(source, appConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open source store %s: %v\n", source, err)
+		return exitError
+	}
+	defer sourceStore.Close()
+
+	targetStore, err := openStore(target, appConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open target store %s: %v\n", target, err)
+		return exitError
+	}
+	defer targetStore.Close()
+
+	diffs, err := diffStores(sourceStore, targetStore, os.Stdout, workers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to compare stores: %v\n", err)
+	} else if diffs > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d discrepancies found\n", diffs)
+	} else {
+		fmt.Fprintln(os.Stderr, "✅ Stores match")
+	}
+	return exitCode(diffs, err)
+}
+
+// openStore connects to the store described by spec: a type, optionally followed by
+// ":location" to override the configured path, DSN or address of that type
+func openStore(spec string, appConfig *config.Config) (store.Store, error) {
+	storeType, location, _ := strings.Cut(spec, ":")
+
+	switch storeType {
+	case "sqlite":
+		if location == "" {
+			location = appConfig.SQLitePath
+		}
+		return store.NewSQLiteStore(location)
+	case "csv":
+		if location == "" {
+			location = appConfig.DatastorePath
+		}
+		return store.NewCSVStore(location)
+	case "mysql":
+		if location == "" {
+			location = appConfig.MySQLDSN
+		}
+		return store.NewMySQLStore(location)
+	case "redis":
+		if location == "" {
+			location = appConfig.RedisAddr
+		}
+		return store.NewRedisStore(location, appConfig.RedisPassword, appConfig.RedisDB)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s (supported: 'sqlite', 'csv', 'mysql', 'redis')", storeType)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/evyataryagoni/ip2country/pkg/iprange"
+	"github.com/evyataryagoni/ip2country/pkg/validate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// Defaults of the command line flags
+const (
+	DefaultSize         = 1000
+	DefaultMinCountries = 20
+	DefaultSeed         = 1
+)
+
+// cityRange is one row of a DB-IP city dataset: an IPv4 range located in a city
+type cityRange struct {
+	start, end  uint32
+	city        string
+	countryCode string
+}
+
+// size is the number of addresses in the range
+func (r cityRange) size() uint64 {
+	return uint64(r.end-r.start) + 1
+}
+
+// sampleOptions controls how many records sample picks
+type sampleOptions struct {
+	Size         int    // Records to pick in total
+	PerCountry   int    // Records per country (0 = Size spread evenly across every country of the dataset)
+	MinCountries int    // sample fails if the result covers fewer countries
+	Seed         uint64 // Same dataset and seed, same records
+}
+
+// readCityRanges parses a DB-IP "IP to City Lite" CSV, gzipped or not:
+// ip_start,ip_end,continent,country,stateprov,city,latitude,longitude (no header)
+// Returns the IPv4 ranges by country code. IPv6 ranges are skipped: their blocks are so large
+// and sparsely used that random addresses in them look nothing like real traffic
+func readCityRanges(r io.Reader) (map[string][]cityRange, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress dataset: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Validated below, with the line number
+
+	ranges := make(map[string][]cityRange)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dataset: %w", err)
+		}
+		if len(record) < 6 {
+			return nil, fmt.Errorf("line %d: expected at least 6 columns, got %d", line, len(record))
+		}
+
+		startIP, endIP := net.ParseIP(record[0]), net.ParseIP(record[1])
+		if startIP == nil || endIP == nil {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, record[0], record[1])
+		}
+		if startIP.To4() == nil {
+			continue // IPv6
+		}
+		start, err := iprange.ToUint32(startIP)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := iprange.ToUint32(endIP)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, record[0], record[1])
+		}
+
+		code, city := strings.ToUpper(record[3]), record[5]
+		if len(code) != 2 || code == "ZZ" || city == "" {
+			continue // Reserved or unlocated
+		}
+		ranges[code] = append(ranges[code], cityRange{start: start, end: end, city: city, countryCode: code})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("dataset has no located IPv4 ranges")
+	}
+	return ranges, nil
+}
+
+// sample picks random IPs from ranges, the same number from each country picked
+// Within a country, larger ranges are likelier to be picked, so big cities dominate like in real traffic.
+// Every IP is unique and passes validate.ValidateIP. The records are sorted by country code, then IP
+func sample(ranges map[string][]cityRange, opts sampleOptions) ([]store.SeedRecord, error) {
+	if opts.Size <= 0 {
+		return nil, fmt.Errorf("size must be positive, got %d", opts.Size)
+	}
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+
+	codes := make([]string, 0, len(ranges))
+	for code := range ranges {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes) // Map order is random; the seed alone must decide the picks
+	rng.Shuffle(len(codes), func(i, j int) { codes[i], codes[j] = codes[j], codes[i] })
+
+	perCountry := opts.PerCountry
+	if perCountry <= 0 {
+		perCountry = (opts.Size + len(codes) - 1) / len(codes)
+	}
+	if countries := (opts.Size + perCountry - 1) / perCountry; countries < len(codes) {
+		codes = codes[:countries]
+	}
+
+	seen := make(map[string]bool, opts.Size)
+	var records []store.SeedRecord
+	for _, code := range codes {
+		picked := pickCountry(rng, ranges[code], min(perCountry, opts.Size-len(records)), seen)
+		records = append(records, picked...)
+	}
+
+	if countries := countCountries(records); countries < opts.MinCountries {
+		return nil, fmt.Errorf("sample covers %d countries, expected at least %d", countries, opts.MinCountries)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CountryCode != records[j].CountryCode {
+			return records[i].CountryCode < records[j].CountryCode
+		}
+		a, _ := iprange.ToUint32(net.ParseIP(records[i].IP))
+		b, _ := iprange.ToUint32(net.ParseIP(records[j].IP))
+		return a < b
+	})
+	return records, nil
+}
+
+// pickCountry picks up to n IPs not in seen from the ranges of one country, adding them to seen
+// Gives up after 10 attempts per IP, so small countries don't loop forever
+func pickCountry(rng *rand.Rand, ranges []cityRange, n int, seen map[string]bool) []store.SeedRecord {
+	cumulative := make([]uint64, len(ranges))
+	var total uint64
+	for i, r := range ranges {
+		total += r.size()
+		cumulative[i] = total
+	}
+
+	var picked []store.SeedRecord
+	for attempt := 0; len(picked) < n && attempt < 10*n; attempt++ {
+		offset := rng.Uint64N(total)
+		r := ranges[sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > offset })]
+
+		ip := iprange.FromUint32(r.start + uint32(rng.Uint64N(r.size()))).String()
+		if seen[ip] || validate.ValidateIP(ip) != nil {
+			continue
+		}
+		seen[ip] = true
+		picked = append(picked, store.SeedRecord{IP: ip, City: r.city, Country: countryName(r.countryCode), CountryCode: r.countryCode})
+	}
+	return picked
+}
+
+// countryName returns the English name of an ISO 3166 alpha-2 code, or the code if it has none
+func countryName(code string) string {
+	if region, err := language.ParseRegion(code); err == nil {
+		if name := display.English.Regions().Name(region); name != "" {
+			return name
+		}
+	}
+	return code
+}
+
+// writeRecords writes records to path as a JSON array, one record per line so diffs stay readable
+// The file is written to a temporary file and renamed into place, like cmd/compress
+func writeRecords(path string, records []store.SeedRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	w := bufio.NewWriter(tmp)
+	w.WriteString("[\n")
+	for i, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.WriteString("  ")
+		w.Write(line)
+		if i < len(records)-1 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString("]\n")
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil { // CreateTemp makes the file private
+		tmp.Close()
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// countCountries returns the number of distinct country codes in records
+func countCountries(records []store.SeedRecord) int {
+	countries := make(map[string]bool)
+	for _, record := range records {
+		countries[record.CountryCode] = true
+	}
+	return len(countries)
+}
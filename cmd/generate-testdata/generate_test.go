@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/evyataryagoni/ip2country/pkg/validate"
+)
+
+// excerptPath is the bundled dataset in the DB-IP format
+const excerptPath = "testdata/dbip-city-lite-excerpt.csv"
+
+// readExcerpt parses the bundled dataset
+func readExcerpt(t *testing.T) map[string][]cityRange {
+	t.Helper()
+
+	f, err := os.Open(excerptPath)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", excerptPath, err)
+	}
+	defer f.Close()
+
+	ranges, err := readCityRanges(f)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", excerptPath, err)
+	}
+	return ranges
+}
+
+// TestReadCityRanges tests that IPv4 ranges are grouped by country, and IPv6 and unlocated ranges skipped
+func TestReadCityRanges(t *testing.T) {
+	dataset := `8.8.8.0,8.8.8.255,NA,US,California,Mountain View,37.3861,-122.084
+12.0.0.0,12.15.255.255,NA,us,New York,New York,40.7128,-74.006
+1.1.1.0,1.1.1.255,OC,AU,New South Wales,Sydney,-33.8688,151.209
+203.0.113.0,203.0.113.255,ZZ,ZZ,,,0,0
+2001:4860::,2001:4860:ffff:ffff:ffff:ffff:ffff:ffff,NA,US,California,Mountain View,37.3861,-122.084
+`
+	ranges, err := readCityRanges(strings.NewReader(dataset))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ranges) != 2 || len(ranges["US"]) != 2 || len(ranges["AU"]) != 1 {
+		t.Fatalf("expected 2 US ranges and 1 AU range, got %+v", ranges)
+	}
+	if r := ranges["US"][1]; r.city != "New York" || r.size() != 1<<20 {
+		t.Errorf("expected New York with 1048576 addresses, got %+v (%d)", r, r.size())
+	}
+}
+
+// TestReadCityRanges_Gzip tests that gzipped datasets, like the DB-IP downloads, are decompressed
+func TestReadCityRanges_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("1.1.1.0,1.1.1.255,OC,AU,New South Wales,Sydney,-33.8688,151.209\n"))
+	gz.Close()
+
+	ranges, err := readCityRanges(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ranges["AU"]) != 1 {
+		t.Errorf("expected 1 AU range, got %+v", ranges)
+	}
+}
+
+// TestReadCityRanges_Invalid tests that malformed datasets are rejected
+func TestReadCityRanges_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"too few columns": "1.1.1.0,1.1.1.255,OC,AU\n",
+		"invalid IP":      "1.1.1.x,1.1.1.255,OC,AU,New South Wales,Sydney,0,0\n",
+		"reversed range":  "1.1.1.255,1.1.1.0,OC,AU,New South Wales,Sydney,0,0\n",
+		"mixed families":  "1.1.1.0,2001:db8::,OC,AU,New South Wales,Sydney,0,0\n",
+		"nothing located": "203.0.113.0,203.0.113.255,ZZ,ZZ,,,0,0\n",
+		"empty":           "",
+	}
+
+	for name, dataset := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readCityRanges(strings.NewReader(dataset)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestSample tests the size, uniqueness, validity and spread of the records picked from the excerpt
+func TestSample(t *testing.T) {
+	ranges := readExcerpt(t)
+
+	records, err := sample(ranges, sampleOptions{Size: DefaultSize, MinCountries: DefaultMinCountries, Seed: DefaultSeed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != DefaultSize {
+		t.Fatalf("expected %d records, got %d", DefaultSize, len(records))
+	}
+
+	seen := make(map[string]bool)
+	perCountry := make(map[string]int)
+	for _, record := range records {
+		if seen[record.IP] {
+			t.Errorf("duplicate IP %s", record.IP)
+		}
+		seen[record.IP] = true
+		perCountry[record.CountryCode]++
+
+		if err := validate.ValidateIP(record.IP); err != nil {
+			t.Errorf("invalid IP %q", record.IP)
+		}
+		if record.City == "" || record.Country == "" || record.Country == record.CountryCode {
+			t.Errorf("incomplete record %+v", record)
+		}
+	}
+
+	// Every country of the excerpt, evenly
+	if len(perCountry) != len(ranges) {
+		t.Errorf("expected records from all %d countries, got %d", len(ranges), len(perCountry))
+	}
+	expected := (DefaultSize + len(ranges) - 1) / len(ranges)
+	for code, n := range perCountry {
+		if n > expected {
+			t.Errorf("expected at most %d records from %s, got %d", expected, code, n)
+		}
+	}
+}
+
+// TestSample_PerCountry tests that -per-country limits the countries picked to what -size needs
+func TestSample_PerCountry(t *testing.T) {
+	records, err := sample(readExcerpt(t), sampleOptions{Size: 100, PerCountry: 4, Seed: DefaultSeed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 100 || countCountries(records) != 25 {
+		t.Errorf("expected 100 records from 25 countries, got %d from %d", len(records), countCountries(records))
+	}
+}
+
+// TestSample_Seed tests that the records depend on the seed alone
+func TestSample_Seed(t *testing.T) {
+	ranges := readExcerpt(t)
+	opts := sampleOptions{Size: 50, PerCountry: 2, Seed: 42}
+
+	first, _ := sample(ranges, opts)
+	second, _ := sample(ranges, opts)
+	if !slices.Equal(first, second) {
+		t.Error("expected the same records for the same seed")
+	}
+
+	opts.Seed = 43
+	if other, _ := sample(ranges, opts); slices.Equal(first, other) {
+		t.Error("expected different records for another seed")
+	}
+}
+
+// TestSample_Sorted tests that records are sorted by country code, then numerically by IP
+func TestSample_Sorted(t *testing.T) {
+	records, err := sample(readExcerpt(t), sampleOptions{Size: 200, Seed: DefaultSeed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sorted := slices.IsSortedFunc(records, func(a, b store.SeedRecord) int {
+		if c := strings.Compare(a.CountryCode, b.CountryCode); c != 0 {
+			return c
+		}
+		return bytes.Compare(net.ParseIP(a.IP).To4(), net.ParseIP(b.IP).To4())
+	})
+	if !sorted {
+		t.Error("expected records sorted by country code, then IP")
+	}
+}
+
+// TestSample_SmallRanges tests that a country with fewer addresses than requested gives what it has
+func TestSample_SmallRanges(t *testing.T) {
+	ranges := map[string][]cityRange{
+		"AU": {{start: 0x01010100, end: 0x01010103, city: "Sydney", countryCode: "AU"}}, // 4 addresses
+	}
+
+	records, err := sample(ranges, sampleOptions{Size: 10, Seed: DefaultSeed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) > 4 {
+		t.Errorf("expected at most 4 records, got %d", len(records))
+	}
+}
+
+// TestSample_Errors tests that too few countries and a non-positive size are rejected
+func TestSample_Errors(t *testing.T) {
+	ranges := readExcerpt(t)
+
+	if _, err := sample(ranges, sampleOptions{Size: 0, Seed: DefaultSeed}); err == nil {
+		t.Error("expected an error for size 0")
+	}
+	if _, err := sample(ranges, sampleOptions{Size: 100, PerCountry: 10, MinCountries: 20, Seed: DefaultSeed}); err == nil {
+		t.Error("expected an error for 10 countries with -min-countries 20")
+	}
+}
+
+// TestWriteRecords tests that the fixture loads back with store.LoadSeedRecords
+func TestWriteRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "sample_ips.json")
+	records := []store.SeedRecord{
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia", CountryCode: "AU"},
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States", CountryCode: "US"},
+	}
+
+	if err := writeRecords(path, records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := store.LoadSeedRecords(path)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	if !slices.Equal(loaded, records) {
+		t.Errorf("expected %+v, got %+v", records, loaded)
+	}
+
+	data, _ := os.ReadFile(path)
+	expected := `[
+  {"ip":"1.1.1.1","city":"Sydney","country":"Australia","country_code":"AU"},
+  {"ip":"8.8.8.8","city":"Mountain View","country":"United States","country_code":"US"}
+]
+`
+	if string(data) != expected {
+		t.Errorf("expected one record per line, got:\n%s", data)
+	}
+}
+
+// TestDBIPURL tests the monthly dataset URL
+func TestDBIPURL(t *testing.T) {
+	got := dbipURL(time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC))
+	if expected := "https://download.db-ip.com/free/dbip-city-lite-2026-10.csv.gz"; got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// This tool writes the IP fixture of store.NewSeedMockStore: random IPv4 addresses from a free
+// geolocation dataset, the same number from each of many countries, as JSON:
+// [{"ip":"...","city":"...","country":"...","country_code":"..."}]
+// By default the current DB-IP "IP to City Lite" dataset (CC BY 4.0, https://db-ip.com) is downloaded;
+// -source takes another URL or a local file in the same format, gzipped or not. The picks only
+// depend on the dataset and -seed, so a rerun with the same inputs writes the same file
+//
+// testdata/dbip-city-lite-excerpt.csv is a small hand-made file in the DB-IP format, for runs without
+// network access and for the tool's own tests. Its ranges are illustrative, not authoritative
+//
+// Usage:
+//
+//	go generate ./internal/store
+//	go run ./cmd/generate-testdata -out internal/store/testdata/sample_ips.json -size 2000 -per-country 20
+//	go run ./cmd/generate-testdata -source cmd/generate-testdata/testdata/dbip-city-lite-excerpt.csv -out internal/store/testdata/sample_ips.json
+func main() {
+	source := flag.String("source", "", "dataset URL or file (default: the DB-IP City Lite dataset of the current month)")
+	outPath := flag.String("out", "testdata/sample_ips.json", "fixture file to create, replaced if it exists")
+	size := flag.Int("size", DefaultSize, "number of records to write")
+	perCountry := flag.Int("per-country", 0, "records per country (default: -size spread evenly across every country)")
+	minCountries := flag.Int("min-countries", DefaultMinCountries, "fail if the fixture would cover fewer countries")
+	seed := flag.Uint64("seed", DefaultSeed, "random seed")
+	flag.Parse()
+
+	if *source == "" {
+		*source = dbipURL(time.Now())
+	}
+
+	dataset, err := openSource(*source)
+	if err != nil {
+		log.Fatalf("Failed to open dataset: %v", err)
+	}
+	defer dataset.Close()
+
+	ranges, err := readCityRanges(dataset)
+	if err != nil {
+		log.Fatalf("Failed to read dataset %s: %v", *source, err)
+	}
+
+	records, err := sample(ranges, sampleOptions{Size: *size, PerCountry: *perCountry, MinCountries: *minCountries, Seed: *seed})
+	if err != nil {
+		log.Fatalf("Failed to sample dataset: %v", err)
+	}
+	if err := writeRecords(*outPath, records); err != nil {
+		log.Fatalf("Failed to write fixture: %v", err)
+	}
+
+	fmt.Printf("✅ Wrote %d records from %d countries to %s\n", len(records), countCountries(records), *outPath)
+}
+
+// dbipURL returns the URL of the DB-IP City Lite dataset published for the month of now
+func dbipURL(now time.Time) string {
+	return fmt.Sprintf("https://download.db-ip.com/free/dbip-city-lite-%s.csv.gz", now.Format("2006-01"))
+}
+
+// openSource opens a dataset from an http(s) URL or a file path
+func openSource(source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Minute} // The City Lite dataset is over 100MB
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s (datasets of a new month appear during its first days; try -source with last month's URL)", source, resp.Status)
+	}
+	return resp.Body, nil
+}
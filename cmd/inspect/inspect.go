@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// Supported output formats
+const (
+	formatNDJSON = "ndjson"
+	formatTable  = "table"
+)
+
+// progressInterval is how often (in records) the progress counter is refreshed
+const progressInterval = 100
+
+// errLimitReached stops iteration once --limit records have been written
+var errLimitReached = errors.New("limit reached")
+
+// inspectOptions controls what inspect writes
+type inspectOptions struct {
+	Format        string    // ndjson or table
+	Limit         int       // Maximum records to write (0 = unlimited)
+	FilterCountry string    // Only write records for this country (empty = all)
+	Progress      io.Writer // Where to report progress (nil = disabled)
+}
+
+// inspectRecord is the NDJSON shape of a record
+// models.IPLocation hides the IP from JSON, but here it's the whole point
+type inspectRecord struct {
+	IP      string `json:"ip"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+// inspect streams records from the iterator to out and returns how many were written
+func inspect(iterator store.Iterator, out io.Writer, opts inspectOptions) (int, error) {
+	var write func(location *models.IPLocation) error
+	var flush func() error
+
+	switch opts.Format {
+	case formatNDJSON, "":
+		encoder := json.NewEncoder(out)
+		write = func(location *models.IPLocation) error {
+			return encoder.Encode(inspectRecord{IP: location.IP, City: location.City, Country: location.Country})
+		}
+		flush = func() error { return nil }
+
+	case formatTable:
+		table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "IP\tCITY\tCOUNTRY")
+		write = func(location *models.IPLocation) error {
+			_, err := fmt.Fprintf(table, "%s\t%s\t%s\n", location.IP, location.City, location.Country)
+			return err
+		}
+		flush = table.Flush
+
+	default:
+		return 0, fmt.Errorf("unknown format: %s (supported: '%s', '%s')", opts.Format, formatNDJSON, formatTable)
+	}
+
+	count := 0
+	err := iterator.Iterate(func(location *models.IPLocation) error {
+		if opts.FilterCountry != "" && !strings.EqualFold(location.Country, opts.FilterCountry) {
+			return nil
+		}
+
+		if err := write(location); err != nil {
+			return err
+		}
+		count++
+
+		if opts.Progress != nil && count%progressInterval == 0 {
+			fmt.Fprintf(opts.Progress, "\r📦 %d records emitted", count)
+		}
+
+		if opts.Limit > 0 && count >= opts.Limit {
+			return errLimitReached
+		}
+		return nil
+	})
+	if err != nil && err != errLimitReached {
+		return count, err
+	}
+
+	return count, flush()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// newInspectMockStore creates a mock store with 5 records (3 in US, 2 elsewhere)
+func newInspectMockStore() *store.MockStore {
+	mockStore := store.NewEmptyMockStore()
+	mockStore.Data = map[string]*models.IPLocation{
+		"8.8.8.8":      {IP: "8.8.8.8", City: "Mountain View", Country: "US"},
+		"8.8.4.4":      {IP: "8.8.4.4", City: "Mountain View", Country: "US"},
+		"9.9.9.9":      {IP: "9.9.9.9", City: "Berkeley", Country: "US"},
+		"1.1.1.1":      {IP: "1.1.1.1", City: "Sydney", Country: "AU"},
+		"2.22.233.255": {IP: "2.22.233.255", City: "London", Country: "GB"},
+	}
+	return mockStore
+}
+
+// TestInspect_NDJSON tests that each record is written as one JSON line
+func TestInspect_NDJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	count, err := inspect(newInspectMockStore(), &out, inspectOptions{Format: formatNDJSON})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("expected 5 records, got %d", count)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		lines++
+		var record inspectRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Errorf("line %d is not valid JSON: %v", lines, err)
+		}
+		if record.IP == "" {
+			t.Errorf("line %d: expected IP to be included", lines)
+		}
+	}
+	if lines != 5 {
+		t.Errorf("expected 5 lines, got %d", lines)
+	}
+}
+
+// TestInspect_Table tests the table format has a header row
+func TestInspect_Table(t *testing.T) {
+	var out bytes.Buffer
+
+	if _, err := inspect(newInspectMockStore(), &out, inspectOptions{Format: formatTable}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected header + 5 rows, got %d lines", len(lines))
+	}
+	if fields := strings.Fields(lines[0]); len(fields) != 3 || fields[0] != "IP" || fields[1] != "CITY" || fields[2] != "COUNTRY" {
+		t.Errorf("unexpected header row: %q", lines[0])
+	}
+}
+
+// TestInspect_Limit tests that --limit caps the number of records
+func TestInspect_Limit(t *testing.T) {
+	var out bytes.Buffer
+
+	count, err := inspect(newInspectMockStore(), &out, inspectOptions{Format: formatNDJSON, Limit: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 records, got %d", count)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+}
+
+// TestInspect_FilterCountry tests that non-matching countries are skipped
+func TestInspect_FilterCountry(t *testing.T) {
+	var out bytes.Buffer
+
+	count, err := inspect(newInspectMockStore(), &out, inspectOptions{Format: formatNDJSON, FilterCountry: "us"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 US records, got %d", count)
+	}
+
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record inspectRecord
+		json.Unmarshal(scanner.Bytes(), &record)
+		if record.Country != "US" {
+			t.Errorf("expected only US records, got %s", record.Country)
+		}
+	}
+}
+
+// TestInspect_UnknownFormat tests that an invalid format is rejected
+func TestInspect_UnknownFormat(t *testing.T) {
+	var out bytes.Buffer
+
+	if _, err := inspect(newInspectMockStore(), &out, inspectOptions{Format: "xml"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// This tool dumps every record from a store backend to stdout
+// Connection settings come from the usual environment variables / .env file
+//
+// Usage:
+//
+//	go run ./cmd/inspect --store redis --format table
+//	go run ./cmd/inspect --store mysql --filter-country "United States" --limit 10
+func main() {
+	storeType := flag.String("store", "", "store backend to inspect: sqlite, csv, mysql or redis (default: DATASTORE_TYPE)")
+	format := flag.String("format", formatNDJSON, "output format: ndjson or table")
+	limit := flag.Int("limit", 0, "maximum number of records to output (0 = unlimited)")
+	filterCountry := flag.String("filter-country", "", "only output records for this country (case-insensitive)")
+	progress := flag.Bool("progress", false, "report the number of records emitted on stderr")
+	flag.Parse()
+
+	appConfig := config.Load()
+	if *storeType == "" {
+		*storeType = appConfig.DatastoreType
+	}
+
+	dataStore, err := openStore(*storeType, appConfig)
+	if err != nil {
+		log.Fatalf("Failed to open %s store: %v", *storeType, err)
+	}
+	defer dataStore.Close()
+
+	iterator, ok := dataStore.(store.Iterator)
+	if !ok {
+		log.Fatalf("Store type %s does not support iteration", *storeType)
+	}
+
+	opts := inspectOptions{
+		Format:        *format,
+		Limit:         *limit,
+		FilterCountry: *filterCountry,
+	}
+	if *progress {
+		opts.Progress = os.Stderr
+	}
+
+	count, err := inspect(iterator, os.Stdout, opts)
+	if err != nil {
+		log.Fatalf("Failed to inspect store: %v", err)
+	}
+
+	if *progress {
+		fmt.Fprintf(os.Stderr, "\n✅ %d records emitted\n", count)
+	}
+}
+
+// openStore connects to the requested backend using the loaded configuration
+func openStore(storeType string, appConfig *config.Config) (store.Store, error) {
+	switch storeType {
+	case "sqlite":
+		return store.NewSQLiteStore(appConfig.SQLitePath)
+	case "csv":
+		return store.NewCSVStore(appConfig.DatastorePath)
+	case "mysql":
+		return store.NewMySQLStore(appConfig.MySQLDSN)
+	case "redis":
+		return store.NewRedisStore(appConfig.RedisAddr, appConfig.RedisPassword, appConfig.RedisDB)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s (supported: 'sqlite', 'csv', 'mysql', 'redis')", storeType)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 
@@ -9,12 +10,22 @@ import (
 )
 
 // This tool loads IP data from CSV into Redis
-// Usage: go run cmd/load-redis/main.go
+//
+// Usage:
+//
+//	go run ./cmd/load-redis
+//	go run ./cmd/load-redis --throttle-rps 1000  # Gentle import into a production Redis
 func main() {
+	throttleRPS := flag.Int("throttle-rps", -1, "max IPs written per second, 0 = unlimited (default: REDIS_WRITE_RPS)")
+	flag.Parse()
+
 	fmt.Println("🔄 Loading IP data into Redis...")
 
 	// Load configuration
 	appConfig := config.Load()
+	if *throttleRPS < 0 {
+		*throttleRPS = appConfig.RedisWriteRPS
+	}
 
 	// Connect to Redis
 	fmt.Printf("📡 Connecting to Redis at %s...\n", appConfig.RedisAddr)
@@ -23,15 +34,24 @@ func main() {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 	defer redisStore.Close()
+	redisStore.SetWriteRPS(*throttleRPS)
 
 	fmt.Println("✅ Connected to Redis")
 
 	// Load data from CSV
-	fmt.Printf("📁 Loading data from %s...\n", appConfig.DatastorePath)
-	if err := redisStore.LoadFromCSV(appConfig.DatastorePath); err != nil {
+	fmt.Printf("📁 Loading data from %s (%d workers, %s)...\n", appConfig.DatastorePath, appConfig.RedisLoadWorkers, throttleDescription(*throttleRPS))
+	if err := redisStore.BulkLoadCSVParallel(appConfig.DatastorePath, appConfig.RedisLoadWorkers); err != nil {
 		log.Fatalf("Failed to load CSV data: %v", err)
 	}
 
 	fmt.Println("✅ Data loaded successfully!")
 	fmt.Println("\n💡 You can now start the server with DATASTORE_TYPE=redis")
 }
+
+// throttleDescription describes the write limit for the progress output
+func throttleDescription(rps int) string {
+	if rps == 0 {
+		return "unthrottled"
+	}
+	return fmt.Sprintf("max %d writes/sec", rps)
+}
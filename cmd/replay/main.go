@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/evyataryagoni/ip2country/pkg/client"
+)
+
+// This tool warms a new store by replaying the lookups recorded in a server's JSON log
+// IPs are replayed against the target server's API, most looked up first
+//
+// Usage:
+//
+//	go run ./cmd/replay --file server.log --target http://new-server:3000
+//	kubectl logs deploy/ip2country | go run ./cmd/replay --target http://new-server:3000 --concurrency 20
+//	go run ./cmd/replay --file server.log --dry-run
+func main() {
+	file := flag.String("file", "", "newline-delimited JSON server log to read (default: stdin)")
+	target := flag.String("target", "http://localhost:3000", "base URL of the server to warm")
+	dryRun := flag.Bool("dry-run", false, "only list the IPs that would be warmed, with their lookup counts")
+	concurrency := flag.Int("concurrency", DefaultConcurrency, "number of lookups to replay in parallel")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	ips, err := extractIPs(input)
+	if err != nil {
+		log.Fatalf("Failed to extract IPs: %v", err)
+	}
+	if len(ips) == 0 {
+		log.Fatalf("No lookups found in the log (is LOG_FORMAT=json?)")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := replayOptions{
+		Concurrency: *concurrency,
+		DryRun:      *dryRun,
+		Progress:    os.Stderr,
+	}
+	result, err := replay(ctx, client.New(*target, nil), ips, os.Stdout, opts)
+	if err != nil {
+		log.Fatalf("\nReplay interrupted: %v", err)
+	}
+
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "✅ %d IPs would be warmed\n", len(ips))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n✅ %d IPs warmed, %d failed\n", result.Warmed, result.Failed)
+}
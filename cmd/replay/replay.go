@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/evyataryagoni/ip2country/pkg/client"
+	"github.com/evyataryagoni/ip2country/pkg/validate"
+)
+
+// DefaultConcurrency is the number of lookups replayed in parallel when --concurrency is not set
+const DefaultConcurrency = 10
+
+// lookupMessages are the log messages IPService writes once per lookup of a valid IP
+// Other entries (debug lines, admin audit entries, startup messages) are ignored
+var lookupMessages = map[string]bool{
+	"IP lookup successful": true,
+	"IP address not found": true,
+}
+
+// ipCount is an IP with the number of times it was looked up
+type ipCount struct {
+	IP    string `json:"ip"`
+	Count int    `json:"count"`
+}
+
+// logEntry is the part of a server log line replay cares about
+type logEntry struct {
+	IP      string `json:"ip"`
+	Message string `json:"message"`
+}
+
+// replayOptions controls how replay warms the target
+type replayOptions struct {
+	Concurrency int       // Lookups in flight at once (<= 0 uses DefaultConcurrency)
+	DryRun      bool      // Only write the IPs that would be warmed to out
+	Progress    io.Writer // Where to report percentage complete (nil = disabled)
+}
+
+// replayResult counts the outcome of the replayed lookups
+type replayResult struct {
+	Warmed int // Lookups that reached the store (found or not found)
+	Failed int // Lookups that returned any other error
+}
+
+// extractIPs reads newline-delimited JSON server logs from r and counts the lookups of each IP
+// Lines that aren't JSON or aren't lookup entries are skipped.
+// The result is sorted by count, most looked up first (ties by IP so the order is stable)
+func extractIPs(r io.Reader) ([]ipCount, error) {
+	counts := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // Log lines with long error messages
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !lookupMessages[entry.Message] || validate.ValidateIP(entry.IP) != nil {
+			continue
+		}
+		counts[entry.IP]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	ips := make([]ipCount, 0, len(counts))
+	for ip, count := range counts {
+		ips = append(ips, ipCount{IP: ip, Count: count})
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if ips[i].Count != ips[j].Count {
+			return ips[i].Count > ips[j].Count
+		}
+		return ips[i].IP < ips[j].IP
+	})
+	return ips, nil
+}
+
+// replay looks up each IP once against the target, in the given order
+// Each IP is replayed once regardless of its count: one lookup is enough to warm a cache.
+// In dry-run mode the IPs are written to out as NDJSON instead and c is never called
+func replay(ctx context.Context, c *client.Client, ips []ipCount, out io.Writer, opts replayOptions) (replayResult, error) {
+	if opts.DryRun {
+		encoder := json.NewEncoder(out)
+		for _, ip := range ips {
+			if err := encoder.Encode(ip); err != nil {
+				return replayResult{}, fmt.Errorf("failed to write output: %w", err)
+			}
+		}
+		return replayResult{}, nil
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var warmed, failed, done atomic.Int64
+	var progressMu sync.Mutex
+	reportProgress := func() {
+		if opts.Progress == nil {
+			return
+		}
+		// Serialized so the counter never goes backwards on screen
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		n := done.Add(1)
+		fmt.Fprintf(opts.Progress, "\rReplaying... %d/%d (%d%%)", n, len(ips), n*100/int64(len(ips)))
+	}
+
+	// Workers take IPs in order, so the most looked up IPs are warmed first
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range queue {
+				_, err := c.FindCountry(ctx, ip)
+				if err == nil || errors.Is(err, client.ErrNotFound) {
+					warmed.Add(1)
+				} else {
+					failed.Add(1)
+				}
+				reportProgress()
+			}
+		}()
+	}
+
+feed:
+	for _, ip := range ips {
+		select {
+		case queue <- ip.IP:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	result := replayResult{Warmed: int(warmed.Load()), Failed: int(failed.Load())}
+	return result, ctx.Err()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/client"
+)
+
+// testLog is a server log with lookups of three IPs, plus entries replay must ignore
+const testLog = `{"level":"info","message":"Starting IP2Country Server..."}
+{"level":"info","ip":"8.8.8.8","city":"Mountain View","country":"United States","message":"IP lookup successful"}
+{"level":"info","ip":"1.1.1.1","city":"Sydney","country":"Australia","message":"IP lookup successful"}
+{"level":"debug","ip":"8.8.8.8","message":"Looking up IP address"}
+{"level":"info","ip":"8.8.8.8","city":"Mountain View","country":"United States","message":"IP lookup successful"}
+{"level":"debug","ip":"2001:db8::1","message":"IP address not found"}
+{"level":"warn","ip":"not-an-ip","message":"Invalid IP address format"}
+not json at all
+{"level":"info","request_id":"abc","method":"POST","path":"/admin/reload","status":200,"message":"Admin request"}
+{"level":"info","ip":"8.8.8.8","city":"Mountain View","country":"United States","message":"IP lookup successful"}
+{"level":"info","ip":"1.1.1.1","city":"Sydney","country":"Australia","message":"IP lookup successful"}
+`
+
+// writeTestLog writes testLog to a temporary file and returns it opened
+func writeTestLog(t *testing.T) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "server.log")
+	if err := os.WriteFile(path, []byte(testLog), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// TestExtractIPs tests that lookups are counted per IP, most looked up first, ignoring other entries
+func TestExtractIPs(t *testing.T) {
+	ips, err := extractIPs(writeTestLog(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ipCount{
+		{IP: "8.8.8.8", Count: 3},
+		{IP: "1.1.1.1", Count: 2},
+		{IP: "2001:db8::1", Count: 1},
+	}
+	if !slices.Equal(ips, expected) {
+		t.Errorf("expected %v, got %v", expected, ips)
+	}
+}
+
+// TestExtractIPs_TiesSortedByIP tests that IPs with the same count keep a stable order
+func TestExtractIPs_TiesSortedByIP(t *testing.T) {
+	log := `{"ip":"9.9.9.9","message":"IP lookup successful"}
+{"ip":"1.1.1.1","message":"IP lookup successful"}
+{"ip":"5.5.5.5","message":"IP lookup successful"}
+{"ip":"5.5.5.5","message":"IP lookup successful"}
+`
+	ips, err := extractIPs(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ipCount{{IP: "5.5.5.5", Count: 2}, {IP: "1.1.1.1", Count: 1}, {IP: "9.9.9.9", Count: 1}}
+	if !slices.Equal(ips, expected) {
+		t.Errorf("expected %v, got %v", expected, ips)
+	}
+}
+
+// TestReplay tests that every IP is looked up once, in frequency order, and not found counts as warmed
+func TestReplay(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.URL.Query().Get("ip")
+		requested = append(requested, ip) // Concurrency 1: requests arrive one at a time
+		switch ip {
+		case "2001:db8::1":
+			w.WriteHeader(http.StatusNotFound)
+		case "1.1.1.1":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte(`{"city":"Mountain View","country":"United States"}`))
+		}
+	}))
+	defer server.Close()
+
+	ips, _ := extractIPs(writeTestLog(t))
+	var progress bytes.Buffer
+	result, err := replay(context.Background(), client.New(server.URL, nil), ips, &bytes.Buffer{}, replayOptions{
+		Concurrency: 1,
+		Progress:    &progress,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"8.8.8.8", "1.1.1.1", "2001:db8::1"}; !slices.Equal(requested, expected) {
+		t.Errorf("expected lookups %v, got %v", expected, requested)
+	}
+	if result.Warmed != 2 || result.Failed != 1 {
+		t.Errorf("expected 2 warmed and 1 failed, got %+v", result)
+	}
+	if !strings.HasSuffix(progress.String(), "3/3 (100%)") {
+		t.Errorf("expected progress to end at 100%%, got %q", progress.String())
+	}
+}
+
+// TestReplay_DryRun tests that dry-run lists the IPs without calling the server
+func TestReplay_DryRun(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	ips, _ := extractIPs(writeTestLog(t))
+	var out bytes.Buffer
+	if _, err := replay(context.Background(), client.New(server.URL, nil), ips, &out, replayOptions{DryRun: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("expected no calls to the server, got %d", calls.Load())
+	}
+
+	var listed []ipCount
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var ip ipCount
+		if err := decoder.Decode(&ip); err != nil {
+			t.Fatalf("expected NDJSON output: %v", err)
+		}
+		listed = append(listed, ip)
+	}
+	if !slices.Equal(listed, ips) {
+		t.Errorf("expected %v to be listed, got %v", ips, listed)
+	}
+}
+
+// TestReplay_ConcurrencyLimit tests that no more than --concurrency lookups are ever in flight
+func TestReplay_ConcurrencyLimit(t *testing.T) {
+	const concurrency = 3
+
+	var inFlight, maxInFlight atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond) // Long enough for the workers to overlap
+		w.Write([]byte(`{"city":"Sydney","country":"Australia"}`))
+	}))
+	defer server.Close()
+
+	var ips []ipCount
+	for i := range 30 {
+		ips = append(ips, ipCount{IP: fmt.Sprintf("10.0.0.%d", i+1), Count: 1})
+	}
+
+	result, err := replay(context.Background(), client.New(server.URL, nil), ips, &bytes.Buffer{}, replayOptions{Concurrency: concurrency})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Warmed != len(ips) {
+		t.Errorf("expected %d IPs warmed, got %+v", len(ips), result)
+	}
+	if peak := maxInFlight.Load(); peak > concurrency {
+		t.Errorf("expected at most %d lookups in flight, got %d", concurrency, peak)
+	} else if peak < 2 {
+		t.Errorf("expected lookups to run in parallel, peak in flight was %d", peak)
+	}
+}
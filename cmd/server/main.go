@@ -1,19 +1,22 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/evyataryagoni/ip2country/internal/config"
-	"github.com/evyataryagoni/ip2country/internal/handler"
-	"github.com/evyataryagoni/ip2country/internal/limiter"
 	"github.com/evyataryagoni/ip2country/internal/logger"
-	"github.com/evyataryagoni/ip2country/internal/metrics"
-	"github.com/evyataryagoni/ip2country/internal/router"
-	"github.com/evyataryagoni/ip2country/internal/service"
-	"github.com/evyataryagoni/ip2country/internal/store"
 )
 
+// BuildVersion is the version shown in the Swagger UI, set at compile time:
+//
+//	go build -ldflags "-X main.BuildVersion=1.2.3" ./cmd/server
+var BuildVersion = "dev"
+
 // @title           IP2Country API
 // @version         1.0
 // @description     A high-performance IP geolocation service with rate limiting and multiple storage backends
@@ -28,35 +31,58 @@ import (
 // @host      localhost:3000
 // @BasePath  /
 func main() {
-	// Load configuration
-	appConfig := config.Load()
-
-	// Initialize components
-	appLogger := setupLogger(appConfig)
-	dataStore := setupDataStore(appConfig, appLogger)
-	defer dataStore.Close()
+	appConfig, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err) // No logger yet: its settings are in the configuration
+	}
+	appLogger, logRing := setupLogger(appConfig)
+	if !checkConfig(appConfig, appLogger) {
+		appLogger.Fatal().Msg("Invalid configuration")
+	}
+	tuneGOMAXPROCS(cgroupRoot, appLogger)
 
-	rateLimiter := setupRateLimiter(appConfig, appLogger)
-	defer rateLimiter.Close()
+	server := NewServer(appConfig, appLogger)
+	server.LogRing = logRing
+	if err := server.Setup(); err != nil {
+		server.Logger.Fatal().Err(err).Msg("Failed to set up server")
+	}
 
-	metricsCollector := setupMetrics(appLogger)
+	// Stop on Ctrl+C or SIGTERM (docker stop, Kubernetes)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Build application layers
-	ipService := service.NewIPService(dataStore, metricsCollector, appLogger)
-	defer ipService.Close()
+	// Reload the datastore in place on SIGUSR1 (Unix only)
+	reloadOnSignal(ctx, server.Store, server.Logger)
 
-	ipHandler := handler.NewIPHandler(ipService)
-	appRouter := router.SetupRouter(ipHandler, rateLimiter, metricsCollector, appLogger)
+	runErr := server.Run(ctx)
+	if err := server.Close(); err != nil {
+		server.Logger.Warn().Err(err).Msg("Failed to close dependencies")
+	}
+	if runErr != nil {
+		server.Logger.Fatal().Err(runErr).Msg("Server failed")
+	}
+	server.Logger.Info().Msg("Server stopped")
+}
 
-	// Start server
-	startServer(appConfig, appRouter, appLogger)
+// loadConfig loads the configuration from the YAML file named by CONFIG_FILE, or from the environment alone when it's unset
+func loadConfig() (*config.Config, error) {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return config.LoadFromFile(path)
+	}
+	return config.Load(), nil
 }
 
 // setupLogger initializes the structured logger
-func setupLogger(appConfig *config.Config) *logger.Logger {
+// With DEBUG_LOG_RING, it also returns the ring of recent lines served at /debug/logs (nil otherwise)
+func setupLogger(appConfig *config.Config) (*logger.Logger, *logger.RingLogger) {
+	var ring *logger.RingLogger
+	if appConfig.DebugLogRing {
+		ring = logger.NewRingLogger(appConfig.DebugLogRingSize)
+	}
 	appLogger := logger.New(logger.Config{
-		Level:  "info",
-		Pretty: true,
+		Level:  appConfig.LogLevel,
+		Pretty: appConfig.LogPretty,
+		Ring:   ring,
 	})
 
 	appLogger.Info().Msg("Starting IP2Country Server...")
@@ -69,107 +95,30 @@ func setupLogger(appConfig *config.Config) *logger.Logger {
 		Str("datastore_path", appConfig.DatastorePath).
 		Msg("Configuration loaded")
 
-	return appLogger
+	return appLogger, ring
 }
 
-// setupDataStore initializes the data store based on configuration
-// Supports CSV, MySQL, and Redis backends
-func setupDataStore(appConfig *config.Config, log *logger.Logger) store.Store {
-	var dataStore store.Store
-	var err error
-
-	switch appConfig.DatastoreType {
-	case "csv":
-		dataStore, err = store.NewCSVStore(appConfig.DatastorePath)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to initialize CSV store")
-		}
-		fmt.Println("✅ CSV store initialized")
-
-	case "mysql":
-		dataStore, err = store.NewMySQLStore(appConfig.MySQLDSN)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to initialize MySQL store")
-		}
-		fmt.Println("✅ MySQL store initialized")
-
-	case "redis":
-		redisStore, err := store.NewRedisStore(appConfig.RedisAddr, appConfig.RedisPassword, appConfig.RedisDB)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to initialize Redis store")
+// checkConfig logs every problem config.Validate finds in appConfig, and every environment
+// variable config.ValidateEnv finds doesn't conform to the config schema
+// Returns false if any of them is fatal; schema errors always are
+func checkConfig(appConfig *config.Config, log *logger.Logger) bool {
+	configErrors := config.Validate(appConfig)
+	for _, configErr := range configErrors {
+		event := log.Warn()
+		if configErr.Fatal {
+			event = log.Error()
 		}
-		fmt.Println("✅ Redis store initialized")
-
-		// Auto-load data if Redis is empty
-		loadRedisDataIfEmpty(redisStore, appConfig.DatastorePath, log)
-
-		dataStore = redisStore
-
-	default:
-		log.Fatal().Str("type", appConfig.DatastoreType).Msg("Unknown datastore type")
+		event.Str("field", configErr.Field).Msg(configErr.Message)
 	}
 
-	return dataStore
-}
-
-// loadRedisDataIfEmpty checks if Redis is empty and loads sample data from CSV
-func loadRedisDataIfEmpty(redisStore *store.RedisStore, csvPath string, log *logger.Logger) {
-	isEmpty, err := redisStore.IsEmpty()
-	if err != nil {
-		log.Warn().Err(err).Msg("Failed to check if Redis is empty")
-		return
-	}
-
-	if isEmpty {
-		fmt.Println("📦 Redis is empty, loading sample data from CSV...")
-		if err := redisStore.LoadFromCSV(csvPath); err != nil {
-			log.Warn().Err(err).Msg("Failed to load sample data")
-		}
-	}
-}
-
-// setupRateLimiter initializes the rate limiter
-// Supports in-memory and Redis-based rate limiting
-func setupRateLimiter(appConfig *config.Config, log *logger.Logger) limiter.Limiter {
-	// Calculate effective rate: requests per second
-	// Example: 10 requests per 5 seconds = 10/5 = 2.0 req/s
-	effectiveRate := float64(appConfig.RateLimit) / float64(appConfig.RateLimitWindow)
-
-	rateLimiter, err := limiter.NewLimiter(limiter.LimiterConfig{
-		Type:              appConfig.RateLimitType,
-		RequestsPerSecond: effectiveRate,
-		RedisAddr:         appConfig.RedisAddr,
-		RedisPassword:     appConfig.RedisPassword,
-		RedisDB:           appConfig.RedisDB,
-	})
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to initialize rate limiter")
+	schemaErrors := config.ValidateEnv()
+	for _, schemaErr := range schemaErrors {
+		log.Error().
+			Str("field", strings.TrimPrefix(schemaErr.Path, "/")).
+			Str("expected", schemaErr.Expected).
+			Str("actual", schemaErr.Actual).
+			Msg("Setting doesn't match the config schema")
 	}
 
-	fmt.Printf("✅ Rate limiter initialized (type: %s, limit: %d req per %d sec = %.2f req/s)\n",
-		appConfig.RateLimitType, appConfig.RateLimit, appConfig.RateLimitWindow, effectiveRate)
-
-	return rateLimiter
-}
-
-// setupMetrics initializes the Prometheus metrics collector
-func setupMetrics(log *logger.Logger) *metrics.Metrics {
-	metricsCollector := metrics.New()
-	log.Info().Msg("Metrics initialized")
-	return metricsCollector
-}
-
-// startServer starts the HTTP server and blocks
-func startServer(appConfig *config.Config, appRouter http.Handler, log *logger.Logger) {
-	serverAddr := ":" + appConfig.Port
-
-	log.Info().
-		Str("port", appConfig.Port).
-		Str("api_endpoint", "http://localhost:"+appConfig.Port+"/v1/find-country?ip=<ip>").
-		Str("health_check", "http://localhost:"+appConfig.Port+"/health").
-		Str("metrics", "http://localhost:"+appConfig.Port+"/metrics").
-		Str("swagger", "http://localhost:"+appConfig.Port+"/swagger/index.html").
-		Msg("Server is running")
-
-	log.Fatal().Err(http.ListenAndServe(serverAddr, appRouter)).Msg("Server failed")
+	return !config.HasFatal(configErrors) && len(schemaErrors) == 0
 }
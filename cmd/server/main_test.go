@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// newTestLogger returns a logger writing to buf
+func newTestLogger(buf *bytes.Buffer) *logger.Logger {
+	zl := zerolog.New(buf)
+	return &logger.Logger{Logger: &zl}
+}
+
+// newWarmupMetrics returns metrics with an unregistered warmup gauge
+func newWarmupMetrics() *metrics.Metrics {
+	return &metrics.Metrics{
+		StoreWarmupDuration: prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_store_warmup_duration_seconds"}),
+	}
+}
+
+// TestWarmupDataStore tests that warmable stores are warmed up
+func TestWarmupDataStore(t *testing.T) {
+	var buf bytes.Buffer
+	mockStore := store.NewMockStore()
+
+	warmupDataStore(mockStore, newWarmupMetrics(), newTestLogger(&buf))
+
+	if !mockStore.WarmupCalled {
+		t.Error("expected Warmup to be called")
+	}
+	if !strings.Contains(buf.String(), "Datastore warmed up") {
+		t.Errorf("expected success log, got: %s", buf.String())
+	}
+}
+
+// TestWarmupDataStore_Failure tests that a failed warmup is logged and not fatal
+func TestWarmupDataStore_Failure(t *testing.T) {
+	var buf bytes.Buffer
+	mockStore := store.NewMockStore()
+	mockStore.WarmupError = errors.New("connection reset")
+
+	warmupDataStore(mockStore, newWarmupMetrics(), newTestLogger(&buf))
+
+	out := buf.String()
+	if !strings.Contains(out, "Datastore warmup failed") || !strings.Contains(out, "connection reset") {
+		t.Errorf("expected warning with the error, got: %s", out)
+	}
+}
+
+// TestCheckConfig tests that every problem is logged and only fatal ones fail the check
+func TestCheckConfig(t *testing.T) {
+	valid := &config.Config{Port: "3000", RateLimit: 10, RateLimitWindow: 1, RateLimitType: "memory", DatastoreType: "csv"}
+
+	var buf bytes.Buffer
+	if !checkConfig(valid, newTestLogger(&buf)) {
+		t.Error("expected a valid config to pass")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing logged for a valid config, got %s", buf.String())
+	}
+
+	warning := *valid
+	warning.RateLimitType = "redis"
+	warning.RedisAddr = "localhost:6379"
+	buf.Reset()
+	if !checkConfig(&warning, newTestLogger(&buf)) {
+		t.Error("expected a config with only warnings to pass")
+	}
+	if !strings.Contains(buf.String(), `"level":"warn"`) || !strings.Contains(buf.String(), "RATE_LIMITER_TYPE") {
+		t.Errorf("expected a RATE_LIMITER_TYPE warning, got %s", buf.String())
+	}
+
+	invalid := *valid
+	invalid.Port = "http"
+	buf.Reset()
+	if checkConfig(&invalid, newTestLogger(&buf)) {
+		t.Error("expected an invalid port to fail")
+	}
+	if !strings.Contains(buf.String(), `"level":"error"`) || !strings.Contains(buf.String(), "PORT") {
+		t.Errorf("expected a PORT error, got %s", buf.String())
+	}
+
+	// An environment variable Load would silently replace with its default
+	t.Setenv("RATE_LIMIT", "ten")
+	buf.Reset()
+	if checkConfig(valid, newTestLogger(&buf)) {
+		t.Error("expected an environment variable not matching the config schema to fail")
+	}
+	if !strings.Contains(buf.String(), `"field":"RATE_LIMIT"`) || !strings.Contains(buf.String(), `"expected":"integer"`) {
+		t.Errorf("expected a RATE_LIMIT schema error, got %s", buf.String())
+	}
+}
+
+// TestSetupLogger_Ring tests that the log ring is created only with DEBUG_LOG_RING, and receives the logger's lines
+func TestSetupLogger_Ring(t *testing.T) {
+	if _, ring := setupLogger(&config.Config{LogLevel: "info"}); ring != nil {
+		t.Error("expected no log ring with DEBUG_LOG_RING=false")
+	}
+
+	appLogger, ring := setupLogger(&config.Config{LogLevel: "info", DebugLogRing: true, DebugLogRingSize: 10})
+	if ring == nil {
+		t.Fatal("expected a log ring with DEBUG_LOG_RING=true")
+	}
+	appLogger.Info().Msg("ring test")
+	lines := ring.Lines(0)
+	if len(lines) == 0 || !strings.Contains(lines[len(lines)-1], "ring test") {
+		t.Errorf("expected the logged line last in the ring, got %v", lines)
+	}
+}
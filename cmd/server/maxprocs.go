@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+)
+
+// cgroupRoot is where the container's cgroup files are mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// tuneGOMAXPROCS lowers GOMAXPROCS to the container's CPU limit, read from the cgroup files under root
+// Without it, a pod limited to 2 CPUs on a 64-core node could run 64 threads and be throttled.
+// Go 1.25 does the same by default (with a floor of 2), so this mostly matters for limits under 2 CPUs.
+// A GOMAXPROCS environment variable is left alone, and so is a process without a CPU quota
+func tuneGOMAXPROCS(root string, log *logger.Logger) {
+	limit, ok := cgroupCPULimit(root)
+	if !ok {
+		log.Debug().Int("gomaxprocs", runtime.GOMAXPROCS(0)).Msg("No cgroup CPU quota, keeping the default GOMAXPROCS")
+		return
+	}
+
+	if os.Getenv("GOMAXPROCS") == "" && limit < runtime.GOMAXPROCS(0) {
+		// Setting it stops the runtime from updating GOMAXPROCS if the limit changes later
+		runtime.GOMAXPROCS(limit)
+	}
+	log.Info().
+		Int("cpu_limit", limit).
+		Int("gomaxprocs", runtime.GOMAXPROCS(0)).
+		Msg("Detected container CPU limit")
+}
+
+// cgroupCPULimit returns the CPU quota of the cgroup files under root, rounded up to whole CPUs
+// Both cgroup v1 (cpu/cpu.cfs_quota_us and cpu/cpu.cfs_period_us) and v2 (cpu.max) are read.
+// ok is false when the files are missing (not in a container) or there's no quota
+func cgroupCPULimit(root string) (limit int, ok bool) {
+	quota, period, ok := readCFSQuotaV1(root)
+	if !ok {
+		quota, period, ok = readCPUMaxV2(root)
+	}
+	if !ok || quota <= 0 || period <= 0 {
+		return 0, false
+	}
+	return max(int(math.Ceil(float64(quota)/float64(period))), 1), true
+}
+
+// readCFSQuotaV1 reads the cgroup v1 CFS quota and period, in microseconds (quota -1 = no quota)
+func readCFSQuotaV1(root string) (quota, period int64, ok bool) {
+	quota, err := readCgroupInt(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, 0, false
+	}
+	period, err = readCgroupInt(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return quota, period, true
+}
+
+// readCPUMaxV2 reads the cgroup v2 cpu.max file: "<quota> <period>", or "max <period>" without a quota
+func readCPUMaxV2(root string) (quota, period int64, ok bool) {
+	data, err := os.ReadFile(filepath.Join(root, "cpu.max"))
+	if err != nil {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, 0, false
+	}
+	quota, err = strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	period, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return quota, period, true
+}
+
+// readCgroupInt reads a cgroup file holding a single integer
+func readCgroupInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
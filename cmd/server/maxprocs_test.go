@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeCgroupFiles writes the cgroup files named by their path under root into a temp dir, and returns it
+func writeCgroupFiles(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// TestGomaxprocsAutoTune tests that the CPU limit is ceil(quota/period) for cgroup v1 and v2 files
+func TestGomaxprocsAutoTune(t *testing.T) {
+	tests := []struct {
+		name          string
+		quota, period string
+		expected      int
+	}{
+		{"whole CPUs", "200000", "100000", 2},
+		{"fraction rounds up", "150000", "100000", 2},
+		{"less than one CPU", "50000", "100000", 1},
+		{"other period", "400000", "50000", 8},
+		{"just over", "100001", "100000", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v1 := writeCgroupFiles(t, map[string]string{
+				"cpu/cpu.cfs_quota_us":  tt.quota,
+				"cpu/cpu.cfs_period_us": tt.period,
+			})
+			if limit, ok := cgroupCPULimit(v1); !ok || limit != tt.expected {
+				t.Errorf("v1: expected %d, got %d, %v", tt.expected, limit, ok)
+			}
+
+			v2 := writeCgroupFiles(t, map[string]string{"cpu.max": tt.quota + " " + tt.period})
+			if limit, ok := cgroupCPULimit(v2); !ok || limit != tt.expected {
+				t.Errorf("v2: expected %d, got %d, %v", tt.expected, limit, ok)
+			}
+		})
+	}
+}
+
+// TestGomaxprocsAutoTune_NoQuota tests that missing files and unlimited quotas leave GOMAXPROCS alone
+func TestGomaxprocsAutoTune_NoQuota(t *testing.T) {
+	roots := map[string]string{
+		"not in a container": t.TempDir(),
+		"v1 unlimited":       writeCgroupFiles(t, map[string]string{"cpu/cpu.cfs_quota_us": "-1", "cpu/cpu.cfs_period_us": "100000"}),
+		"v2 unlimited":       writeCgroupFiles(t, map[string]string{"cpu.max": "max 100000"}),
+		"v2 malformed":       writeCgroupFiles(t, map[string]string{"cpu.max": "lots"}),
+	}
+
+	for name, root := range roots {
+		if limit, ok := cgroupCPULimit(root); ok {
+			t.Errorf("%s: expected no limit, got %d", name, limit)
+		}
+	}
+
+	var buf bytes.Buffer
+	before := runtime.GOMAXPROCS(0)
+	tuneGOMAXPROCS(roots["not in a container"], newTestLogger(&buf))
+	if got := runtime.GOMAXPROCS(0); got != before {
+		t.Errorf("expected GOMAXPROCS to stay %d, got %d", before, got)
+	}
+}
+
+// TestTuneGOMAXPROCS tests that GOMAXPROCS is lowered to the limit and both values are logged
+func TestTuneGOMAXPROCS(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4)) // Above the limit, whatever the machine
+
+	var buf bytes.Buffer
+	root := writeCgroupFiles(t, map[string]string{"cpu.max": "100000 100000"})
+	tuneGOMAXPROCS(root, newTestLogger(&buf))
+
+	if got := runtime.GOMAXPROCS(0); got != 1 {
+		t.Errorf("expected GOMAXPROCS 1, got %d", got)
+	}
+	if !strings.Contains(buf.String(), `"cpu_limit":1`) || !strings.Contains(buf.String(), `"gomaxprocs":1`) {
+		t.Errorf("expected the limit and GOMAXPROCS logged, got %s", buf.String())
+	}
+}
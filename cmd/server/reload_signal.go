@@ -0,0 +1,54 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// reloadOnSignal reloads dataStore each time the process receives SIGUSR1, until ctx is done
+// The new data is swapped in at once, so lookups never see a partial file: kill -USR1 <pid>
+// refreshes a CSV store without a restart. A failed reload is logged and keeps the previous data
+func reloadOnSignal(ctx context.Context, dataStore store.Store, log *logger.Logger) {
+	// Registered before returning, so a signal sent right after is never lost (or fatal)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigs:
+				reloadDataStore(dataStore, log)
+			}
+		}
+	}()
+}
+
+// reloadDataStore reloads dataStore if it's a store.Reloader, logging the outcome
+func reloadDataStore(dataStore store.Store, log *logger.Logger) {
+	reloader, ok := dataStore.(store.Reloader)
+	if !ok {
+		log.Warn().Msg("Reload requested, but the datastore doesn't support reloading")
+		return
+	}
+
+	result, err := reloader.Reload()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to reload the datastore, keeping the previous data")
+		return
+	}
+	log.Info().
+		Int("records", result.Records).
+		Int("added", result.Added).
+		Int("removed", result.Removed).
+		Msg("Datastore reloaded")
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// reloadOnSignal does nothing: SIGUSR1 doesn't exist on this platform
+// Use DATASTORE_WATCH to reload a CSV or MaxMind store when its files change instead
+func reloadOnSignal(ctx context.Context, dataStore store.Store, log *logger.Logger) {}
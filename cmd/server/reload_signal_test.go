@@ -0,0 +1,102 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// writeReloadCSV writes content to path, failing the test on error
+func writeReloadCSV(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write CSV file: %v", err)
+	}
+}
+
+// TestReloadOnSignal tests that SIGUSR1 reloads the CSV store behind the wrappers, serving the new data within 200ms
+func TestReloadOnSignal(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "data.csv")
+	writeReloadCSV(t, csvPath, "ip,city,country\n8.8.8.8,Mountain View,United States\n")
+	csvStore, err := store.NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	dataStore := store.NewMetricsStore(csvStore, metrics.NewWithRegistry(prometheus.NewRegistry()), "csv")
+	defer dataStore.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var buf bytes.Buffer
+	reloadOnSignal(ctx, dataStore, newTestLogger(&buf))
+
+	writeReloadCSV(t, csvPath, "ip,city,country\n1.1.1.1,Sydney,Australia\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("failed to send SIGUSR1: %v", err)
+	}
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for {
+		location, err := dataStore.FindByIP(context.Background(), "1.1.1.1")
+		if err == nil {
+			if location.City != "Sydney" {
+				t.Errorf("expected Sydney, got %q", location.City)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the new data within 200ms of SIGUSR1, got %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := dataStore.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected the removed record to be gone after the reload")
+	}
+}
+
+// TestReloadDataStore tests that a reload logs its counts, and that a failed or unsupported reload is logged and keeps the data
+func TestReloadDataStore(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "data.csv")
+	writeReloadCSV(t, csvPath, "ip,city,country\n8.8.8.8,Mountain View,United States\n")
+	csvStore, err := store.NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer csvStore.Close()
+
+	var buf bytes.Buffer
+	writeReloadCSV(t, csvPath, "ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n")
+	reloadDataStore(csvStore, newTestLogger(&buf))
+	for _, field := range []string{`"records":2`, `"added":1`, `"removed":0`, "Datastore reloaded"} {
+		if !strings.Contains(buf.String(), field) {
+			t.Errorf("expected %s in the log, got %s", field, buf.String())
+		}
+	}
+
+	buf.Reset()
+	writeReloadCSV(t, csvPath, "")
+	reloadDataStore(csvStore, newTestLogger(&buf))
+	if !strings.Contains(buf.String(), "keeping the previous data") {
+		t.Errorf("expected a failed reload to be logged, got %s", buf.String())
+	}
+	if _, err := csvStore.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected the previous data to be kept, got %v", err)
+	}
+
+	buf.Reset()
+	reloadDataStore(store.NewMockStore(), newTestLogger(&buf))
+	if !strings.Contains(buf.String(), "doesn't support reloading") {
+		t.Errorf("expected an unsupported store to be logged, got %s", buf.String())
+	}
+}
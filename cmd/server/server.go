@@ -0,0 +1,944 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/docs"
+	"github.com/evyataryagoni/ip2country/internal/analytics"
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/handler"
+	"github.com/evyataryagoni/ip2country/internal/health"
+	"github.com/evyataryagoni/ip2country/internal/history"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	custommiddleware "github.com/evyataryagoni/ip2country/internal/middleware"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/router"
+	"github.com/evyataryagoni/ip2country/internal/service"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	storesync "github.com/evyataryagoni/ip2country/internal/sync"
+	"github.com/evyataryagoni/ip2country/pkg/ipenrich"
+	"github.com/evyataryagoni/ip2country/pkg/retry"
+	"github.com/redis/go-redis/v9"
+	"github.com/swaggo/swag"
+)
+
+// shutdownTimeout bounds how long Run waits for in-flight requests after its context is cancelled
+const shutdownTimeout = 10 * time.Second
+
+// Server holds every dependency of the HTTP server
+// Setup creates any dependency left nil from Config, so tests can inject mocks before calling it
+type Server struct {
+	Config  *config.Config
+	Logger  *logger.Logger
+	Metrics *metrics.Metrics
+
+	Store              store.Store
+	HealthChecks       *health.CheckRegistry // Run by GET /health; Setup registers every store it opens (an injected Store's check is up to the caller)
+	RateLimiter        limiter.Limiter
+	FingerprintLimiter limiter.Limiter                    // Created only if FingerprintRateLimitMultiplier > 0
+	UniqueIPs          *redis.Client                      // Created only if UniqueIPsEnabled
+	Tokens             *limiter.DisposableTokenLimiter    // Created only if DisposableTokensEnabled
+	Blocklist          *custommiddleware.Blocklist        // Created only if BlocklistFile or BlocklistRedisKey is set
+	OpenAPI            *custommiddleware.OpenAPIValidator // Built from the embedded docs/swagger.json
+	AdminSchema        *custommiddleware.SchemaValidator  // Built from the embedded admin API JSON Schema
+	LogRing            *logger.RingLogger                 // Recent log lines served at /debug/logs; set only if DebugLogRing
+	Spans              *store.SpanStore                   // Ranges answering recent lookups, served at /debug/spans; created only if DebugSpans
+
+	reloadableConfig *config.ReloadableConfig
+	handler          http.Handler
+	closers          []func() error     // Closed in reverse order by Close
+	stopBackground   context.CancelFunc // Stops background goroutines (unique IP gauge, blocklist refresh)
+}
+
+// NewServer creates a server for the given configuration
+// Call Setup to build the dependencies, then Run to serve
+func NewServer(appConfig *config.Config, log *logger.Logger) *Server {
+	return &Server{Config: appConfig, Logger: log}
+}
+
+// Setup builds all dependencies and the HTTP handler without starting a listener
+// On error, every dependency registered so far (created or injected) is closed
+func (s *Server) Setup() (err error) {
+	if s.Config == nil {
+		return errors.New("server config is required")
+	}
+	if s.Logger == nil {
+		s.Logger = logger.NewDefault()
+	}
+	if _, err := custommiddleware.ParseTrustedProxies(s.Config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			s.Close()
+		}
+	}()
+
+	// Settings read per request (rate limits, log level) follow POST /admin/config/reload
+	s.reloadableConfig = config.NewReloadableConfig(s.Config)
+	s.reloadableConfig.OnReload(func(c *config.Config) {
+		logger.SetLevel(c.LogLevel)
+		s.Logger.Info().Str("log_level", c.LogLevel).Int("rate_limit", c.RateLimit).Msg("Configuration reloaded")
+	})
+
+	if s.Metrics == nil {
+		s.Metrics = setupMetrics(s.Logger)
+	}
+
+	if s.HealthChecks == nil {
+		s.HealthChecks = health.NewCheckRegistry()
+	}
+
+	if s.Store == nil {
+		if s.Store, s.Spans, err = setupDataStore(s.Config, s.Metrics, s.HealthChecks, s.Logger); err != nil {
+			return err
+		}
+	}
+	s.closers = append(s.closers, s.Store.Close)
+
+	if s.RateLimiter == nil {
+		if s.RateLimiter, err = setupRateLimiter(s.reloadableConfig, s.Metrics, s.Logger); err != nil {
+			return err
+		}
+	}
+	s.closers = append(s.closers, s.RateLimiter.Close)
+
+	if s.FingerprintLimiter == nil {
+		if s.FingerprintLimiter, err = setupFingerprintLimiter(s.reloadableConfig, s.Logger); err != nil {
+			return err
+		}
+	}
+	if s.FingerprintLimiter != nil {
+		s.closers = append(s.closers, s.FingerprintLimiter.Close)
+	}
+
+	// Build application layers
+	ipService := service.NewIPService(s.Store, s.Metrics, s.Logger)
+	s.closers = append(s.closers, ipService.Close)
+	if s.Config.HistorySize > 0 {
+		ipService.SetHistory(history.NewRingBuffer[models.HistoryEntry](s.Config.HistorySize))
+	}
+	ipService.SetSubnetSampleSize(s.Config.MaxCIDRSampleSize)
+	ipService.SetBatchWorkers(s.Config.BatchLookupWorkers)
+
+	ipHandler := handler.NewIPHandler(ipService)
+	ipHandler.SetHealthRegistry(s.HealthChecks)
+	ipHandler.SetMaxBatchSize(s.Config.BatchLookupMaxSize)
+	adminHandler := handler.NewAdminHandler(s.reloadableConfig)
+	adminHandler.SetRateLimiter(s.RateLimiter)
+	adminHandler.SetStore(s.Store)
+	if s.Config.AdminAPIKey == "" {
+		s.Logger.Warn().Msg("ADMIN_API_KEY is not set, /admin and /debug endpoints reject every request")
+	}
+
+	var background context.Context
+	background, s.stopBackground = context.WithCancel(context.Background())
+	if s.UniqueIPs == nil {
+		if s.UniqueIPs, err = setupUniqueIPs(background, s.Config, s.Metrics, s.Logger); err != nil {
+			return err
+		}
+	}
+	if s.UniqueIPs != nil {
+		s.closers = append(s.closers, s.UniqueIPs.Close)
+		adminHandler.SetUniqueIPsClient(s.UniqueIPs)
+	}
+
+	if csvStore := setupStoreComparator(background, s.Config, s.Store, s.Metrics, s.Logger); csvStore != nil {
+		s.closers = append(s.closers, csvStore.Close)
+	}
+
+	sampler, samplerClient, err := setupSampler(background, s.Config, s.Logger)
+	if err != nil {
+		return err
+	}
+	if samplerClient != nil {
+		s.closers = append(s.closers, samplerClient.Close)
+	}
+	if sampler != nil {
+		ipService.SetSampler(sampler)
+		adminHandler.SetSampler(sampler)
+	}
+
+	if s.Tokens == nil {
+		if s.Tokens, err = setupDisposableTokens(s.Config, s.Logger); err != nil {
+			return err
+		}
+	}
+	if s.Tokens != nil {
+		s.closers = append(s.closers, s.Tokens.Close)
+		adminHandler.SetTokenLimiter(s.Tokens)
+	}
+
+	if s.Blocklist == nil {
+		var blocklistClient *redis.Client
+		if s.Blocklist, blocklistClient, err = setupBlocklist(background, s.Config, s.Logger); err != nil {
+			return err
+		}
+		if blocklistClient != nil {
+			s.closers = append(s.closers, blocklistClient.Close)
+		}
+	}
+
+	if enricher := setupEnricher(s.Config, s.Blocklist); enricher != nil {
+		ipService.SetEnricher(enricher)
+	}
+
+	if s.OpenAPI == nil {
+		if s.OpenAPI, err = custommiddleware.NewOpenAPIValidator(docs.SwaggerJSON); err != nil {
+			return err
+		}
+	}
+
+	if s.AdminSchema == nil {
+		if s.AdminSchema, err = custommiddleware.NewSchemaValidator(custommiddleware.AdminAPISchema); err != nil {
+			return err
+		}
+	}
+
+	var prefetcher *custommiddleware.Prefetcher
+	if s.Config.PrefetchAdjacent > 0 {
+		prefetcher = custommiddleware.NewPrefetcher(ipService, s.Config.PrefetchAdjacent, s.Metrics)
+		s.closers = append(s.closers, prefetcher.Close)
+		fmt.Printf("✅ Prefetching %d adjacent IPs on each side of every lookup\n", s.Config.PrefetchAdjacent)
+	}
+
+	describeSwagger(s.Config)
+
+	s.handler = router.SetupRouter(s.Config, ipHandler, adminHandler, s.RateLimiter, s.FingerprintLimiter, s.UniqueIPs, s.Tokens, s.Blocklist, s.OpenAPI, s.AdminSchema, prefetcher, s.LogRing, s.Spans, s.Metrics, s.Logger)
+	return nil
+}
+
+// describeSwagger shows the backends of this instance in the Swagger UI's description
+// The spec is registered with swag by the generated docs package; without one (a build
+// that skipped swag init) there is nothing to describe
+func describeSwagger(appConfig *config.Config) {
+	if spec, ok := swag.GetSwagger(swag.Name).(*swag.Spec); ok {
+		spec.Description = swaggerDescription(appConfig)
+	}
+}
+
+// swaggerDescription summarizes the store, rate limiter and build version
+func swaggerDescription(appConfig *config.Config) string {
+	return fmt.Sprintf("Backend: %s | Rate Limiter: %s | Version: %s", appConfig.DatastoreType, appConfig.RateLimitType, BuildVersion)
+}
+
+// Handler returns the HTTP handler built by Setup (nil before Setup)
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Run serves HTTP on Config.Port until ctx is cancelled, then shuts down gracefully
+// Returns nil after a clean shutdown, or the listener error
+func (s *Server) Run(ctx context.Context) error {
+	if s.handler == nil {
+		return errors.New("server is not set up (call Setup before Run)")
+	}
+
+	httpServer := &http.Server{
+		Addr:    ":" + s.Config.Port,
+		Handler: s.handler,
+	}
+
+	s.Logger.Info().
+		Str("port", s.Config.Port).
+		Str("api_endpoint", "http://localhost:"+s.Config.Port+"/v1/find-country?ip=<ip>").
+		Str("health_check", "http://localhost:"+s.Config.Port+"/health").
+		Str("metrics", "http://localhost:"+s.Config.Port+"/metrics").
+		Str("swagger", "http://localhost:"+s.Config.Port+"/swagger/index.html").
+		Msg("Server is running")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.Logger.Info().Msg("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	return nil
+}
+
+// Close stops background work and closes every dependency, newest first
+func (s *Server) Close() error {
+	if s.stopBackground != nil {
+		s.stopBackground()
+	}
+
+	var errs []error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		errs = append(errs, s.closers[i]())
+	}
+	s.closers = nil
+	return errors.Join(errs...)
+}
+
+// setupMetrics initializes the Prometheus metrics collector
+func setupMetrics(log *logger.Logger) *metrics.Metrics {
+	metricsCollector := metrics.New()
+	log.Info().Msg("Metrics initialized")
+	return metricsCollector
+}
+
+// setupDataStore initializes the data store based on configuration
+// Supports SQLite, CSV, MySQL, PostgreSQL, Redis and MaxMind backends, or a weighted mix of them
+// With GOSSIP_BIND_ADDR set, writes are shared with peer nodes over gossip
+// With SHADOW_DATASTORE_TYPE set, a sample of lookups is also compared against a second backend
+// With SERVE_STALE_ON_ERROR set, lookups fall back to the last known result while the backend is down
+// With CACHE_SIZE set, repeated lookups are answered from an in-process LRU cache in front of all of the above
+// With DEBUG_SPANS set, the range answering each lookup is recorded by the returned SpanStore (nil otherwise)
+// Stores that support it are warmed up before the server starts accepting traffic
+// The health check of every store opened is registered with checks (see openDataStore for the names)
+func setupDataStore(appConfig *config.Config, m *metrics.Metrics, checks *health.CheckRegistry, log *logger.Logger) (store.Store, *store.SpanStore, error) {
+	dataStore, err := openDataStore(appConfig.DatastoreType, appConfig.DatastoreType, appConfig, m, checks, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Right over the backend: the wrappers below don't pass ranges through
+	var spans *store.SpanStore
+	if appConfig.DebugSpans {
+		spans = store.NewSpanStore(dataStore, appConfig.DebugSpansSize)
+		fmt.Printf("✅ Recording the datastore ranges of recent lookups at /debug/spans\n")
+		dataStore = spans
+	}
+
+	if appConfig.GossipBindAddr != "" {
+		gossipStore, err := store.NewGossipStore(dataStore, appConfig.GossipBindAddr, appConfig.GossipPeers)
+		if err != nil {
+			dataStore.Close()
+			return nil, nil, fmt.Errorf("gossip store: %w", err)
+		}
+		gossipStore.SetLogger(log.WithComponent("GossipStore"))
+		fmt.Printf("✅ Gossip sync enabled on %s (%d members)\n", appConfig.GossipBindAddr, gossipStore.Members())
+		dataStore = gossipStore
+	}
+
+	if appConfig.ShadowDatastoreType != "" {
+		shadow, err := openDataStore(appConfig.ShadowDatastoreType, "shadow_"+appConfig.ShadowDatastoreType, appConfig, m, checks, log)
+		if err != nil {
+			dataStore.Close()
+			return nil, nil, fmt.Errorf("shadow store: %w", err)
+		}
+		shadowStore := store.NewShadowStore(dataStore, shadow, appConfig.ShadowReadRate)
+		shadowStore.SetLogger(log.WithComponent("ShadowStore"))
+		shadowStore.SetDiscrepancyCounter(m.ShadowDiscrepancies)
+		fmt.Printf("✅ Shadow mode enabled (shadow: %s, sample rate: %.2f)\n", appConfig.ShadowDatastoreType, appConfig.ShadowReadRate)
+		dataStore = shadowStore
+	}
+
+	if appConfig.ServeStaleOnError {
+		staleStore := store.NewStaleStore(dataStore, store.DefaultStaleCacheSize)
+		staleStore.SetStaleServesCounter(m.StaleServesTotal)
+		fmt.Printf("✅ Serving stale data on datastore errors (last %d IPs)\n", store.DefaultStaleCacheSize)
+		dataStore = staleStore
+	}
+
+	if appConfig.CacheSize > 0 {
+		cachedStore := store.NewCachedStore(dataStore, appConfig.CacheSize, time.Duration(appConfig.CacheTTLSeconds)*time.Second)
+		cachedStore.SetMetrics(store.CachedStoreMetrics{
+			Hits:      m.DatastoreCacheHits.WithLabelValues(appConfig.DatastoreType, "hit"),
+			Misses:    m.DatastoreCacheHits.WithLabelValues(appConfig.DatastoreType, "miss"),
+			Entries:   m.DatastoreCacheEntries,
+			Evictions: m.DatastoreCacheEvictions,
+		})
+		fmt.Printf("✅ Caching lookups in memory (%d IPs, TTL %ds)\n", appConfig.CacheSize, appConfig.CacheTTLSeconds)
+		dataStore = cachedStore
+	}
+
+	warmupDataStore(dataStore, m, log)
+
+	return dataStore, spans, nil
+}
+
+// openWeightedStore opens every store listed in WEIGHTED_STORE_CONFIG and spreads reads across them
+// Their checks are named after the weighted store and their position, e.g. weighted_0_redis
+func openWeightedStore(name string, appConfig *config.Config, m *metrics.Metrics, checks *health.CheckRegistry, log *logger.Logger) (store.Store, error) {
+	file, err := config.LoadWeightedStoreFile(appConfig.WeightedStoreConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var stores []store.WeightedStore
+	closeAll := func() {
+		for _, ws := range stores {
+			ws.Store.Close()
+		}
+	}
+	for i, entry := range file.Stores {
+		s, err := openDataStore(entry.Type, fmt.Sprintf("%s_%d_%s", name, i, entry.Type), appConfig, m, checks, log)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("weighted store: %w", err)
+		}
+		stores = append(stores, store.WeightedStore{Store: s, Weight: entry.Weight})
+	}
+
+	weightedStore, err := store.NewWeightedProxyStore(stores)
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to initialize weighted store: %w", err)
+	}
+	weightedStore.SetVerify(appConfig.WeightedStoreVerify)
+	weightedStore.SetLogger(log.WithComponent("WeightedProxyStore"))
+	weightedStore.SetDiscrepancyCounter(m.WeightedDiscrepancies)
+	fmt.Printf("✅ Weighted store initialized (%d stores, verification: %v)\n", len(stores), appConfig.WeightedStoreVerify)
+	return weightedStore, nil
+}
+
+// openDataStore creates a store of the given type, instrumented with datastore query metrics
+// A weighted store isn't wrapped itself: each of its stores is, under its own type
+// The store's health check is registered with checks as name: the type for the primary store,
+// prefixed for the others (shadow_mysql), so two stores of one type don't replace each other's check
+func openDataStore(datastoreType, name string, appConfig *config.Config, m *metrics.Metrics, checks *health.CheckRegistry, log *logger.Logger) (store.Store, error) {
+	if datastoreType == "weighted" {
+		return openWeightedStore(name, appConfig, m, checks, log)
+	}
+
+	dataStore, err := newDataStore(datastoreType, appConfig, log)
+	if err != nil {
+		return nil, err
+	}
+	if checker, ok := dataStore.(store.HealthChecker); ok {
+		checks.Register(name, checker.HealthCheck)
+	}
+	return store.NewMetricsStore(dataStore, m, datastoreType), nil
+}
+
+// newDataStore creates a backend store of the given type
+func newDataStore(datastoreType string, appConfig *config.Config, log *logger.Logger) (store.Store, error) {
+	switch datastoreType {
+	case "sqlite":
+		sqliteStore, err := store.NewSQLiteStore(appConfig.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize SQLite store: %w", err)
+		}
+		fmt.Println("✅ SQLite store initialized")
+		return sqliteStore, nil
+
+	case "csv":
+		if appConfig.DatastorePath == "" || appConfig.DatastorePath == store.CSVEmbeddedPath {
+			csvStore, err := store.NewEmbeddedCSVStore()
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize CSV store: %w", err)
+			}
+			fmt.Println("✅ CSV store initialized (embedded data)")
+			return csvStore, nil
+		}
+
+		delimiter, err := store.ParseCSVDelimiter(appConfig.CSVDelimiter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CSV store: %w", err)
+		}
+		csvStore, err := store.NewCSVStore(appConfig.DatastorePath, store.WithDelimiter(delimiter), store.WithLazyQuotes(appConfig.CSVLazyQuotes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CSV store: %w", err)
+		}
+		if appConfig.DatastoreWatch {
+			if err := csvStore.Watch(log.WithComponent("CSVStore")); err != nil {
+				csvStore.Close()
+				return nil, fmt.Errorf("failed to initialize CSV store: %w", err)
+			}
+			fmt.Println("✅ CSV store initialized (reloaded on change)")
+			return csvStore, nil
+		}
+		fmt.Println("✅ CSV store initialized")
+		return csvStore, nil
+
+	case "mysql":
+		mysqlStore, err := store.NewMySQLStoreWithOptions(appConfig.MySQLDSN, store.MySQLStoreOptions{
+			SlowQueryThreshold: time.Duration(appConfig.MySQLSlowQueryThresholdMS) * time.Millisecond,
+			SlowQueryLogger:    log.WithComponent("MySQLStore"),
+			Retry:              storeRetryConfig(appConfig, log),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize MySQL store: %w", err)
+		}
+		fmt.Println("✅ MySQL store initialized")
+		return mysqlStore, nil
+
+	case "postgres":
+		postgresStore, err := store.NewPostgreSQLStoreWithOptions(appConfig.PostgresDSN, store.PostgreSQLStoreOptions{
+			AutoMigrate:       appConfig.PGAutoMigrate,
+			Retry:             storeRetryConfig(appConfig, log),
+			MaxConns:          int32(appConfig.PGMaxConns),
+			MaxConnIdleTime:   time.Duration(appConfig.PGMaxConnIdleSeconds) * time.Second,
+			HealthCheckPeriod: time.Duration(appConfig.PGHealthCheckPeriodSeconds) * time.Second,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize PostgreSQL store: %w", err)
+		}
+		fmt.Println("✅ PostgreSQL store initialized")
+		return postgresStore, nil
+
+	case "redis":
+		if len(appConfig.RedisClusterAddrs) > 0 {
+			return newRedisClusterStore(appConfig, log)
+		}
+
+		redisStore, err := store.NewRedisStoreWithRetry(appConfig.RedisAddr, appConfig.RedisPassword, appConfig.RedisDB,
+			storeRetryConfig(appConfig, log))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Redis store: %w", err)
+		}
+		redisStore.SetRetry(redisOperationRetryConfig(appConfig, log))
+		redisStore.SetWriteRPS(appConfig.RedisWriteRPS)
+		redisStore.SetTTL(time.Duration(appConfig.IPDataTTLHours) * time.Hour)
+		fmt.Println("✅ Redis store initialized")
+
+		// Auto-load data if Redis is empty
+		loadRedisDataIfEmpty(redisStore, appConfig.DatastorePath, appConfig.RedisLoadWorkers, log)
+		return redisStore, nil
+
+	case "maxmind":
+		maxmindStore, err := store.NewMaxMindStore(appConfig.MaxMindCityPath, appConfig.MaxMindASNPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize MaxMind store: %w", err)
+		}
+		if appConfig.DatastoreWatch {
+			if err := maxmindStore.Watch(log.WithComponent("MaxMindStore")); err != nil {
+				maxmindStore.Close()
+				return nil, fmt.Errorf("failed to initialize MaxMind store: %w", err)
+			}
+			fmt.Println("✅ MaxMind store initialized (reloaded on change)")
+			return maxmindStore, nil
+		}
+		if maxmindStore.HasASNData() {
+			fmt.Println("✅ MaxMind store initialized (with ASN data)")
+		} else {
+			fmt.Println("✅ MaxMind store initialized")
+		}
+		return maxmindStore, nil
+
+	default:
+		return nil, fmt.Errorf("unknown datastore type %q", datastoreType)
+	}
+}
+
+// newRedisClusterStore creates a store over the Redis Cluster at REDIS_CLUSTER_ADDRS
+// Like the standalone store, an empty cluster is loaded from the CSV file
+func newRedisClusterStore(appConfig *config.Config, log *logger.Logger) (store.Store, error) {
+	clusterStore, err := store.NewRedisClusterStoreWithRetry(appConfig.RedisClusterAddrs, appConfig.RedisPassword,
+		storeRetryConfig(appConfig, log))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Redis Cluster store: %w", err)
+	}
+	clusterStore.SetRetry(redisOperationRetryConfig(appConfig, log))
+	fmt.Printf("✅ Redis Cluster store initialized (%d seed nodes)\n", len(appConfig.RedisClusterAddrs))
+
+	isEmpty, err := clusterStore.IsEmpty()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to check if Redis Cluster is empty")
+	} else if isEmpty {
+		fmt.Println("📦 Redis Cluster is empty, loading sample data from CSV...")
+		if err := clusterStore.LoadFromCSV(appConfig.DatastorePath); err != nil {
+			log.Warn().Err(err).Msg("Failed to load sample data")
+		}
+	}
+	return clusterStore, nil
+}
+
+// redisOperationRetryConfig builds the Redis store's retry policy for transient errors
+// REDIS_MAX_RETRIES counts attempts, so it's one more than retry.Config.MaxRetries
+func redisOperationRetryConfig(appConfig *config.Config, log *logger.Logger) retry.Config {
+	return retry.Config{
+		MaxRetries: max(appConfig.RedisMaxRetries-1, 0),
+		BaseDelay:  time.Duration(appConfig.RedisRetryDelayMS) * time.Millisecond,
+		Logger:     log.WithComponent("RedisStore"),
+	}
+}
+
+// storeWarmupTimeout bounds the startup warmup so a slow backend can't hold up the server
+const storeWarmupTimeout = 30 * time.Second
+
+// warmupDataStore warms up stores implementing store.WarmableStore
+// A failed warmup is logged and ignored: the store still serves requests, just with cold caches
+func warmupDataStore(dataStore store.Store, m *metrics.Metrics, log *logger.Logger) {
+	warmable, ok := dataStore.(store.WarmableStore)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeWarmupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := warmable.Warmup(ctx)
+	duration := time.Since(start)
+	m.StoreWarmupDuration.Set(duration.Seconds())
+
+	if err != nil {
+		log.Warn().Err(err).Dur("duration", duration).Msg("Datastore warmup failed, continuing with cold caches")
+		return
+	}
+	log.Info().Dur("duration", duration).Msg("Datastore warmed up")
+}
+
+// storeRetryConfig converts application config into the startup connection retry policy
+func storeRetryConfig(appConfig *config.Config, log *logger.Logger) retry.Config {
+	return retry.Config{
+		MaxRetries: appConfig.StoreConnectMaxRetries,
+		BaseDelay:  time.Duration(appConfig.StoreConnectBaseDelayMS) * time.Millisecond,
+		MaxDelay:   time.Duration(appConfig.StoreConnectMaxDelayMS) * time.Millisecond,
+		Logger:     log.WithComponent("connect"),
+	}
+}
+
+// loadRedisDataIfEmpty checks if Redis is empty and loads sample data from CSV
+func loadRedisDataIfEmpty(redisStore *store.RedisStore, csvPath string, workers int, log *logger.Logger) {
+	isEmpty, err := redisStore.IsEmpty()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to check if Redis is empty")
+		return
+	}
+
+	if isEmpty {
+		fmt.Println("📦 Redis is empty, loading sample data from CSV...")
+		if err := redisStore.BulkLoadCSVParallel(csvPath, workers); err != nil {
+			log.Warn().Err(err).Msg("Failed to load sample data")
+		}
+	}
+}
+
+// setupRateLimiter initializes the rate limiter
+// Supports in-memory and Redis-based rate limiting
+// The limiter follows config reloads: it is rebuilt when the rate limit settings change
+// With RATE_LIMIT_TIERS_CONFIG set, the limit of each request comes from its tier instead (see setupTieredRateLimiter)
+func setupRateLimiter(reloadableConfig *config.ReloadableConfig, m *metrics.Metrics, log *logger.Logger) (limiter.Limiter, error) {
+	// Built once so the config compares equal across calls (the reloadable limiter rebuilds on change)
+	retryConfig := storeRetryConfig(reloadableConfig.Get(), log)
+
+	if reloadableConfig.Get().RateLimitTiersPath != "" {
+		return setupTieredRateLimiter(reloadableConfig.Get(), retryConfig)
+	}
+
+	// factor scales the configured rate; it's 1 unless the adaptive limiter is throttling
+	newLimiter := func(factor float64) (limiter.Limiter, error) {
+		return limiter.NewReloadableLimiter(func() limiter.LimiterConfig {
+			cfg := scaleLimiterConfig(limiterConfig(reloadableConfig.Get()), factor)
+			cfg.Retry = retryConfig
+			return cfg
+		})
+	}
+
+	appConfig := reloadableConfig.Get()
+	var rateLimiter limiter.Limiter
+	var err error
+	if appConfig.AdaptiveRateLimit {
+		var adaptive *limiter.AdaptiveLimiter
+		adaptive, err = limiter.NewAdaptiveLimiter(newLimiter, limiter.NewProcessCPUPoller(), limiter.AdaptiveConfig{
+			HighWatermark:  appConfig.AdaptiveHighWatermark,
+			LowWatermark:   appConfig.AdaptiveLowWatermark,
+			ThrottleFactor: appConfig.AdaptiveThrottleFactor,
+		})
+		if err == nil {
+			adaptive.SetRateGauge(m.AdaptiveRateLimitCurrent, func() float64 {
+				return limiterConfig(reloadableConfig.Get()).RequestsPerSecond
+			})
+			rateLimiter = adaptive
+		}
+	} else {
+		rateLimiter, err = newLimiter(1)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize rate limiter: %w", err)
+	}
+
+	fmt.Printf("✅ Rate limiter initialized (type: %s, limit: %d req per %d sec = %.2f req/s)\n",
+		appConfig.RateLimitType, appConfig.RateLimit, appConfig.RateLimitWindow, limiterConfig(appConfig).RequestsPerSecond)
+	if appConfig.AdaptiveRateLimit {
+		fmt.Printf("✅ Adaptive rate limiting enabled (%.0f%% of the limit above %.0f%% CPU, restored below %.0f%%)\n",
+			appConfig.AdaptiveThrottleFactor*100, appConfig.AdaptiveHighWatermark*100, appConfig.AdaptiveLowWatermark*100)
+	}
+
+	return rateLimiter, nil
+}
+
+// scaleLimiterConfig scales the rate and burst of cfg by factor
+// The burst is rounded up and never drops below the scaled rate, which the memory limiter rejects
+// (RATE_LIMIT=5, RATE_LIMIT_BURST=5 halved is 2.5 req/s with a burst of 3, not 2)
+func scaleLimiterConfig(cfg limiter.LimiterConfig, factor float64) limiter.LimiterConfig {
+	cfg.RequestsPerSecond *= factor
+	if cfg.BurstSize > 0 {
+		burst := math.Ceil(float64(cfg.BurstSize) * factor)
+		cfg.BurstSize = int(max(burst, math.Ceil(cfg.RequestsPerSecond)))
+	}
+	return cfg
+}
+
+// setupTieredRateLimiter builds a MultiTenantLimiter from the tiers in RATE_LIMIT_TIERS_CONFIG
+// A request's tier is the one its X-API-Key header is listed under, or the default tier. Each tier limits
+// every IP to its own rate with RATE_LIMITER_TYPE. Tiers are read once at startup: config reloads don't change them
+func setupTieredRateLimiter(appConfig *config.Config, retryConfig retry.Config) (limiter.Limiter, error) {
+	if appConfig.AdaptiveRateLimit {
+		return nil, errors.New("ADAPTIVE_RATE_LIMIT can't be combined with RATE_LIMIT_TIERS_CONFIG")
+	}
+
+	file, err := config.LoadRateLimitTiersFile(appConfig.RateLimitTiersPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := make(map[string]limiter.LimiterConfig, len(file.Tiers))
+	for name, tier := range file.Tiers {
+		cfg := limiterConfig(appConfig)
+		cfg.Retry = retryConfig
+		if tier.Unlimited {
+			cfg.RequestsPerSecond = limiter.Unlimited
+		} else {
+			cfg.RequestsPerSecond = tier.RequestsPerSecond
+			cfg.BurstSize = tier.Burst
+		}
+		tiers[name] = cfg
+	}
+
+	tieredLimiter, err := limiter.NewMultiTenantLimiter(tiers, file.DefaultTier, func(r *http.Request) string {
+		return file.Keys[r.Header.Get(custommiddleware.APIKeyHeader)]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize rate limiter: %w", err)
+	}
+
+	fmt.Printf("✅ Tiered rate limiter initialized (type: %s, %d tiers, %d API keys, default tier: %s)\n",
+		appConfig.RateLimitType, len(file.Tiers), len(file.Keys), file.DefaultTier)
+	return tieredLimiter, nil
+}
+
+// setupFingerprintLimiter initializes the limiter keyed by request fingerprint
+// It allows FingerprintRateLimitMultiplier times the per-IP rate, so only clients
+// spreading traffic over many IPs hit it. Returns nil when disabled
+func setupFingerprintLimiter(reloadableConfig *config.ReloadableConfig, log *logger.Logger) (limiter.Limiter, error) {
+	if reloadableConfig.Get().FingerprintRateLimitMultiplier <= 0 {
+		return nil, nil
+	}
+
+	retryConfig := storeRetryConfig(reloadableConfig.Get(), log)
+
+	fingerprintLimiter, err := limiter.NewReloadableLimiter(func() limiter.LimiterConfig {
+		cfg := fingerprintLimiterConfig(reloadableConfig.Get())
+		cfg.Retry = retryConfig
+		return cfg
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize fingerprint rate limiter: %w", err)
+	}
+
+	fmt.Printf("✅ Fingerprint rate limiter initialized (%dx the per-IP limit)\n",
+		reloadableConfig.Get().FingerprintRateLimitMultiplier)
+
+	return fingerprintLimiter, nil
+}
+
+// fingerprintLimiterConfig derives the fingerprint limiter config from the per-IP one
+func fingerprintLimiterConfig(appConfig *config.Config) limiter.LimiterConfig {
+	cfg := limiterConfig(appConfig)
+	multiplier := appConfig.FingerprintRateLimitMultiplier
+	if multiplier <= 0 {
+		// Disabled after a reload: the limiter can't be removed, so make it permissive instead
+		multiplier = 1000
+	}
+
+	cfg.RequestsPerSecond *= float64(multiplier)
+	cfg.BurstSize *= multiplier
+	return cfg
+}
+
+// limiterConfig converts application config into rate limiter config
+func limiterConfig(appConfig *config.Config) limiter.LimiterConfig {
+	// Calculate effective rate: requests per second
+	// Example: 10 requests per 5 seconds = 10/5 = 2.0 req/s
+	effectiveRate := float64(appConfig.RateLimit) / float64(appConfig.RateLimitWindow)
+
+	return limiter.LimiterConfig{
+		Type:              appConfig.RateLimitType,
+		RequestsPerSecond: effectiveRate,
+		BurstSize:         appConfig.RateLimitBurst,
+		Window:            time.Duration(appConfig.RateLimitWindow) * time.Second,
+		RedisAddr:         appConfig.RedisAddr,
+		RedisPassword:     appConfig.RedisPassword,
+		RedisDB:           appConfig.RedisDB,
+	}
+}
+
+// setupUniqueIPs connects to Redis for unique IP analytics and starts the unique_ips_today gauge
+// The gauge goroutine runs until ctx is cancelled. Returns nil when tracking is disabled
+func setupUniqueIPs(ctx context.Context, appConfig *config.Config, m *metrics.Metrics, log *logger.Logger) (*redis.Client, error) {
+	if !appConfig.UniqueIPsEnabled {
+		return nil, nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     appConfig.RedisAddr,
+		Password: appConfig.RedisPassword,
+		DB:       appConfig.RedisDB,
+	})
+
+	err := retry.Connect(func() error {
+		return client.Ping(ctx).Err()
+	}, storeRetryConfig(appConfig, log))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis for unique IP analytics: %w", err)
+	}
+
+	go custommiddleware.TrackUniqueIPsToday(ctx, client, router.UniqueIPsLayout(appConfig.UniqueIPsWindow), m.UniqueIPsToday, time.Minute)
+
+	fmt.Printf("✅ Unique IP analytics enabled (window: %s)\n", appConfig.UniqueIPsWindow)
+	return client, nil
+}
+
+// setupSampler creates the sampler of looked-up IPs and, with ANALYTICS_SAMPLE_REDIS_KEY set,
+// writes its sample to Redis every ANALYTICS_SAMPLE_FLUSH_SECONDS until ctx is cancelled
+// Returns the Redis client it opened (nil without a key), or nil for everything when sampling is disabled
+func setupSampler(ctx context.Context, appConfig *config.Config, log *logger.Logger) (*analytics.Sampler, *redis.Client, error) {
+	if appConfig.AnalyticsSampleSize <= 0 {
+		return nil, nil, nil
+	}
+	sampler := analytics.NewSampler(appConfig.AnalyticsSampleSize)
+
+	if appConfig.AnalyticsSampleRedisKey == "" {
+		fmt.Printf("✅ IP sampling enabled (%d IPs)\n", appConfig.AnalyticsSampleSize)
+		return sampler, nil, nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     appConfig.RedisAddr,
+		Password: appConfig.RedisPassword,
+		DB:       appConfig.RedisDB,
+	})
+	err := retry.Connect(func() error {
+		return client.Ping(ctx).Err()
+	}, storeRetryConfig(appConfig, log))
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("failed to connect to Redis for IP sampling: %w", err)
+	}
+
+	interval := time.Duration(appConfig.AnalyticsSampleFlushSeconds) * time.Second
+	go sampler.FlushEvery(ctx, client, appConfig.AnalyticsSampleRedisKey, interval, log.WithComponent("sampler"))
+
+	fmt.Printf("✅ IP sampling enabled (%d IPs, written to redis:%s every %s)\n", appConfig.AnalyticsSampleSize, appConfig.AnalyticsSampleRedisKey, interval)
+	return sampler, client, nil
+}
+
+// setupStoreComparator compares the Redis datastore with the CSV file it's loaded from (DATASTORE_PATH)
+// every STORE_COMPARE_INTERVAL_HOURS until ctx is cancelled, alerting when more than STORE_DRIFT_ALERT_THRESHOLD
+// IPs differ. Returns the CSV store it opened, or nil when comparisons are disabled or can't run.
+// Comparisons only monitor the data, so a CSV file that fails to load is logged rather than stopping the server
+func setupStoreComparator(ctx context.Context, appConfig *config.Config, dataStore store.Store, m *metrics.Metrics, log *logger.Logger) *store.CSVStore {
+	if appConfig.StoreCompareIntervalHours <= 0 || appConfig.DatastoreType != "redis" {
+		return nil
+	}
+	redisStore, ok := dataStore.(store.Iterator)
+	if !ok || len(appConfig.RedisClusterAddrs) > 0 || appConfig.DatastorePath == "" || appConfig.DatastorePath == store.CSVEmbeddedPath {
+		log.Warn().Msg("Comparisons with the CSV file need a CSV file in DATASTORE_PATH and a Redis store without REDIS_CLUSTER_ADDRS, SERVE_STALE_ON_ERROR, CACHE_SIZE, GOSSIP_BIND_ADDR or SHADOW_DATASTORE_TYPE, disabled")
+		return nil
+	}
+
+	csvStore, err := store.NewCSVStore(appConfig.DatastorePath)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load the CSV file to compare Redis with, comparisons are disabled")
+		return nil
+	}
+
+	comparator := storesync.NewComparator(redisStore, csvStore, appConfig.StoreDriftAlertThreshold, m, log.WithComponent("comparator"))
+	interval := time.Duration(appConfig.StoreCompareIntervalHours) * time.Hour
+	go comparator.Run(ctx, interval)
+
+	fmt.Printf("✅ Redis compared with %s every %s\n", appConfig.DatastorePath, interval)
+	return csvStore
+}
+
+// setupEnricher returns the sources added to lookups with ENRICHMENT_ENABLED: continent and timezone,
+// and the abuse score of addresses in blocklist (nil blocklist = no abuse score). Returns nil when disabled
+func setupEnricher(appConfig *config.Config, blocklist *custommiddleware.Blocklist) *ipenrich.MultiEnricher {
+	if !appConfig.EnrichmentEnabled {
+		return nil
+	}
+
+	enricher := ipenrich.NewMultiEnricher(time.Duration(appConfig.EnrichmentTimeoutMS)*time.Millisecond).
+		Add("continent", ipenrich.ContinentEnricher{}, 0).
+		Add("timezone", ipenrich.TimezoneEnricher{}, 0)
+	if blocklist != nil {
+		enricher.Add("abuse", ipenrich.NewAbuseEnricher(blocklist), 0)
+	}
+	fmt.Println("✅ Lookup enrichment enabled")
+	return enricher
+}
+
+// setupBlocklist loads the IP blocklist from BLOCKLIST_FILE or the BLOCKLIST_REDIS_KEY set
+// and reloads it every BLOCKLIST_REFRESH_SECONDS until ctx is cancelled
+// Returns the Redis client it opened (nil for a file), or nil for everything when the blocklist is disabled
+func setupBlocklist(ctx context.Context, appConfig *config.Config, log *logger.Logger) (*custommiddleware.Blocklist, *redis.Client, error) {
+	var loader custommiddleware.BlocklistLoader
+	var client *redis.Client
+	var source string
+
+	switch {
+	case appConfig.BlocklistFile != "":
+		loader = custommiddleware.NewFileBlocklistLoader(appConfig.BlocklistFile)
+		source = appConfig.BlocklistFile
+	case appConfig.BlocklistRedisKey != "":
+		client = redis.NewClient(&redis.Options{
+			Addr:     appConfig.RedisAddr,
+			Password: appConfig.RedisPassword,
+			DB:       appConfig.RedisDB,
+		})
+		err := retry.Connect(func() error {
+			return client.Ping(ctx).Err()
+		}, storeRetryConfig(appConfig, log))
+		if err != nil {
+			client.Close()
+			return nil, nil, fmt.Errorf("failed to connect to Redis for the blocklist: %w", err)
+		}
+		loader = custommiddleware.NewRedisBlocklistLoader(client, appConfig.BlocklistRedisKey)
+		source = "redis:" + appConfig.BlocklistRedisKey
+	default:
+		return nil, nil, nil
+	}
+
+	blocklist, err := custommiddleware.NewBlocklist(loader)
+	if err != nil {
+		if client != nil {
+			client.Close()
+		}
+		return nil, nil, fmt.Errorf("failed to load blocklist: %w", err)
+	}
+
+	interval := time.Duration(appConfig.BlocklistRefreshSeconds) * time.Second
+	go blocklist.Refresh(ctx, interval, log.WithComponent("blocklist"))
+
+	fmt.Printf("✅ IP blocklist enabled (%s, %d networks, refreshed every %s)\n", source, blocklist.Len(), interval)
+	return blocklist, client, nil
+}
+
+// setupDisposableTokens connects the one-time admin token limiter to Redis
+// Returns nil when disposable tokens are disabled
+func setupDisposableTokens(appConfig *config.Config, log *logger.Logger) (*limiter.DisposableTokenLimiter, error) {
+	if !appConfig.DisposableTokensEnabled {
+		return nil, nil
+	}
+
+	tokens, err := limiter.NewDisposableTokenLimiterWithRetry(appConfig.RedisAddr, appConfig.RedisPassword, appConfig.RedisDB, storeRetryConfig(appConfig, log))
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("✅ Disposable admin tokens enabled (default TTL: %ds)\n", appConfig.DisposableTokenTTLSeconds)
+	return tokens, nil
+}
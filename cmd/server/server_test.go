@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/docs"
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	custommiddleware "github.com/evyataryagoni/ip2country/internal/middleware"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/swaggo/swag"
+)
+
+// newTestConfig returns a config for a self-contained server (memory limiter, no Redis)
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	csvPath := filepath.Join(t.TempDir(), "ip2country.csv")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n8.8.8.8,Mountain View,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to create test CSV: %v", err)
+	}
+
+	return &config.Config{
+		Port:            "0",
+		LogLevel:        "info",
+		RateLimitType:   "memory",
+		RateLimit:       100,
+		RateLimitWindow: 1,
+		DatastoreType:   "csv",
+		DatastorePath:   csvPath,
+		UniqueIPsWindow: "daily",
+		AdminAPIKey:     "secret",
+	}
+}
+
+// newTestServer returns a server with an unregistered metrics collector and a buffered logger
+func newTestServer(t *testing.T, appConfig *config.Config) *Server {
+	t.Helper()
+
+	var buf bytes.Buffer
+	server := NewServer(appConfig, newTestLogger(&buf))
+	server.Metrics = metrics.NewWithRegistry(prometheus.NewRegistry())
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// TestServer_Setup_InvalidConfig tests that configuration errors are returned instead of exiting
+func TestServer_Setup_InvalidConfig(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.DatastoreType = "bogus"
+
+	err := newTestServer(t, appConfig).Setup()
+	if err == nil || !strings.Contains(err.Error(), "unknown datastore type") {
+		t.Errorf("expected unknown datastore type error, got %v", err)
+	}
+
+	appConfig = newTestConfig(t)
+	appConfig.DatastorePath = "/nonexistent/ip2country.csv"
+	if err := newTestServer(t, appConfig).Setup(); err == nil {
+		t.Error("expected error for missing CSV file, got nil")
+	}
+
+	if err := (&Server{}).Setup(); err == nil {
+		t.Error("expected error for missing config, got nil")
+	}
+}
+
+// TestServer_Setup_CSVStore tests that DATASTORE_TYPE=csv selects the CSV store
+func TestServer_Setup_CSVStore(t *testing.T) {
+	server := newTestServer(t, newTestConfig(t))
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metricsStore, ok := server.Store.(*store.MetricsStore)
+	if !ok {
+		t.Fatalf("expected *store.MetricsStore, got %T", server.Store)
+	}
+	if _, ok := metricsStore.Unwrap().(*store.CSVStore); !ok {
+		t.Errorf("expected *store.CSVStore, got %T", metricsStore.Unwrap())
+	}
+	if server.FingerprintLimiter != nil {
+		t.Error("expected no fingerprint limiter when the multiplier is 0")
+	}
+	if server.UniqueIPs != nil {
+		t.Error("expected no unique IP client when analytics are disabled")
+	}
+}
+
+// TestServer_Setup_DebugSpans tests that DEBUG_SPANS records lookups through the store and serves them at /debug/spans
+func TestServer_Setup_DebugSpans(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.DebugSpans = true
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Spans == nil || server.Store != store.Store(server.Spans) {
+		t.Fatalf("expected the store to be the span store, got %T", server.Store)
+	}
+
+	if _, err := server.Store.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/debug/spans", nil)
+	req.Header.Set(custommiddleware.APIKeyHeader, "secret")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	var resp models.SpansResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with spans, got %d (%v)", rec.Code, err)
+	}
+	if len(resp.NoSpans) != 1 || resp.NoSpans[0].IP != "8.8.8.8" || resp.NoSpans[0].Result != "exact" {
+		t.Errorf("expected the exact lookup of 8.8.8.8, got %+v", resp)
+	}
+}
+
+// TestServer_Setup_EmbeddedCSVStore tests that an empty or ":embedded:" DATASTORE_PATH uses the bundled CSV
+func TestServer_Setup_EmbeddedCSVStore(t *testing.T) {
+	for _, path := range []string{"", store.CSVEmbeddedPath} {
+		appConfig := newTestConfig(t)
+		appConfig.DatastorePath = path
+		server := newTestServer(t, appConfig)
+		if err := server.Setup(); err != nil {
+			t.Fatalf("path %q: unexpected error: %v", path, err)
+		}
+
+		if _, err := server.Store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+			t.Errorf("path %q: expected the embedded data to contain 1.1.1.1, got %v", path, err)
+		}
+	}
+}
+
+// TestServer_Setup_WeightedStore tests that DATASTORE_TYPE=weighted opens every store in the YAML file
+func TestServer_Setup_WeightedStore(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.DatastoreType = "weighted"
+	appConfig.WeightedStoreConfigPath = filepath.Join(t.TempDir(), "weighted.yaml")
+	content := "stores:\n  - type: csv\n    weight: 90\n  - type: sqlite\n    weight: 10\n"
+	if err := os.WriteFile(appConfig.WeightedStoreConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write weighted config: %v", err)
+	}
+	appConfig.SQLitePath = store.SQLiteEmbeddedPath
+
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := server.Store.(*store.WeightedProxyStore); !ok {
+		t.Errorf("expected *store.WeightedProxyStore, got %T", server.Store)
+	}
+
+	// A store that fails to open fails the whole setup
+	content = "stores:\n  - type: csv\n    weight: 1\n  - type: bogus\n    weight: 1\n"
+	if err := os.WriteFile(appConfig.WeightedStoreConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write weighted config: %v", err)
+	}
+	if err := newTestServer(t, appConfig).Setup(); err == nil {
+		t.Error("expected error for an unknown store type, got nil")
+	}
+}
+
+// TestServer_Setup_HealthChecks tests that every opened store gets its own check, even with several of one type
+func TestServer_Setup_HealthChecks(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.DatastoreType = "weighted"
+	appConfig.WeightedStoreConfigPath = filepath.Join(t.TempDir(), "weighted.yaml")
+	content := "stores:\n  - type: csv\n    weight: 1\n  - type: csv\n    weight: 1\n"
+	if err := os.WriteFile(appConfig.WeightedStoreConfigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write weighted config: %v", err)
+	}
+	appConfig.ShadowDatastoreType = "csv"
+
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	var resp models.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with checks, got %d (%v)", rec.Code, err)
+	}
+	if len(resp.Checks) != 3 {
+		t.Errorf("expected 3 checks, got %+v", resp.Checks)
+	}
+	for _, name := range []string{"weighted_0_csv", "weighted_1_csv", "shadow_csv"} {
+		if resp.Checks[name].Status != "ok" {
+			t.Errorf("expected check %q to be ok, got %+v", name, resp.Checks)
+		}
+	}
+}
+
+// TestServer_Setup_RedisCluster tests that REDIS_CLUSTER_ADDRS selects the cluster store and loads it when empty
+func TestServer_Setup_RedisCluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	appConfig := newTestConfig(t)
+	appConfig.DatastoreType = "redis"
+	appConfig.RedisAddr = "localhost:1" // Unused: the cluster is preferred
+	appConfig.RedisClusterAddrs = []string{mr.Addr()}
+
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metricsStore, ok := server.Store.(*store.MetricsStore)
+	if !ok {
+		t.Fatalf("expected *store.MetricsStore, got %T", server.Store)
+	}
+	if _, ok := metricsStore.Unwrap().(*store.RedisClusterStore); !ok {
+		t.Errorf("expected *store.RedisClusterStore, got %T", metricsStore.Unwrap())
+	}
+	if !mr.Exists("ip:{8.8.8.8}") {
+		t.Errorf("expected the CSV to be loaded into the empty cluster, got keys %v", mr.Keys())
+	}
+}
+
+// TestServer_Setup_ServeStaleOnError tests that only SERVE_STALE_ON_ERROR=true wraps the store in a StaleStore
+func TestServer_Setup_ServeStaleOnError(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		appConfig := newTestConfig(t)
+		appConfig.ServeStaleOnError = enabled
+		server := newTestServer(t, appConfig)
+		if err := server.Setup(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := server.Store.(*store.StaleStore); ok != enabled {
+			t.Errorf("SERVE_STALE_ON_ERROR=%v: got store %T", enabled, server.Store)
+		}
+	}
+}
+
+// TestServer_Setup_CacheSize tests that only CACHE_SIZE > 0 wraps the store in a CachedStore
+func TestServer_Setup_CacheSize(t *testing.T) {
+	for _, size := range []int{0, 100} {
+		appConfig := newTestConfig(t)
+		appConfig.CacheSize = size
+		server := newTestServer(t, appConfig)
+		if err := server.Setup(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := server.Store.(*store.CachedStore); ok != (size > 0) {
+			t.Errorf("CACHE_SIZE=%d: got store %T", size, server.Store)
+		}
+	}
+}
+
+// TestSetupStoreComparator tests that the Redis store is compared with DATASTORE_PATH only when it's possible and enabled
+func TestSetupStoreComparator(t *testing.T) {
+	redisStore := store.NewTestRedisStore(t)
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+
+	tests := []struct {
+		name    string
+		modify  func(c *config.Config)
+		store   store.Store
+		enabled bool
+	}{
+		{"enabled", func(c *config.Config) {}, redisStore, true},
+		{"interval 0", func(c *config.Config) { c.StoreCompareIntervalHours = 0 }, redisStore, false},
+		{"CSV datastore", func(c *config.Config) { c.DatastoreType = "csv" }, redisStore, false},
+		{"embedded CSV", func(c *config.Config) { c.DatastorePath = store.CSVEmbeddedPath }, redisStore, false},
+		{"missing CSV file", func(c *config.Config) { c.DatastorePath = "/nonexistent/ip2country.csv" }, redisStore, false},
+		{"Redis Cluster", func(c *config.Config) { c.RedisClusterAddrs = []string{"localhost:1"} }, redisStore, false},
+		{"store without iteration", func(c *config.Config) {}, store.NewStaleStore(redisStore, 10), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig := newTestConfig(t)
+			appConfig.DatastoreType = "redis"
+			appConfig.StoreCompareIntervalHours = 24
+			tt.modify(appConfig)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var buf bytes.Buffer
+			csvStore := setupStoreComparator(ctx, appConfig, tt.store, m, newTestLogger(&buf))
+			if csvStore != nil {
+				defer csvStore.Close()
+			}
+			if got := csvStore != nil; got != tt.enabled {
+				t.Errorf("expected enabled=%v, got %v (log: %s)", tt.enabled, got, buf.String())
+			}
+		})
+	}
+}
+
+// TestServer_Setup_Blocklist tests that BLOCKLIST_FILE rejects listed clients before they reach the API
+func TestServer_Setup_Blocklist(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.BlocklistFile = filepath.Join(t.TempDir(), "blocklist.txt")
+	appConfig.BlocklistRefreshSeconds = 300
+	if err := os.WriteFile(appConfig.BlocklistFile, []byte("192.0.2.0/24\n"), 0644); err != nil {
+		t.Fatalf("failed to create blocklist: %v", err)
+	}
+
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for ip, expected := range map[string]int{"192.0.2.10": http.StatusForbidden, "198.51.100.10": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+		req.Header.Set("X-Real-IP", ip)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+
+		if rec.Code != expected {
+			t.Errorf("client %s: expected status %d, got %d", ip, expected, rec.Code)
+		}
+	}
+
+	// A blocklist that can't be loaded fails startup rather than silently allowing everyone
+	appConfig = newTestConfig(t)
+	appConfig.BlocklistFile = filepath.Join(t.TempDir(), "missing.txt")
+	appConfig.BlocklistRefreshSeconds = 300
+	if err := newTestServer(t, appConfig).Setup(); err == nil {
+		t.Error("expected error for a missing blocklist file, got nil")
+	}
+}
+
+// TestServer_Setup_RateLimitTiers tests that RATE_LIMIT_TIERS_CONFIG limits each request by the tier of its API key
+func TestServer_Setup_RateLimitTiers(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.RateLimitTiersPath = filepath.Join(t.TempDir(), "tiers.yaml")
+	content := "default_tier: free\ntiers:\n  free:\n    requests_per_second: 1\n  pro:\n    requests_per_second: 5\n" +
+		"  enterprise:\n    unlimited: true\nkeys:\n  key-pro: pro\n  key-ent: enterprise\n"
+	if err := os.WriteFile(appConfig.RateLimitTiersPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write tiers config: %v", err)
+	}
+
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := server.RateLimiter.(*limiter.MultiTenantLimiter); !ok {
+		t.Fatalf("expected *limiter.MultiTenantLimiter, got %T", server.RateLimiter)
+	}
+
+	// Same client IP for every key: each tier counts it separately
+	allowed := func(key string) int {
+		count := 0
+		for i := 0; i < 20; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+			req.Header.Set("X-Real-IP", "198.51.100.10")
+			if key != "" {
+				req.Header.Set(custommiddleware.APIKeyHeader, key)
+			}
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, req)
+			if rec.Code == http.StatusOK {
+				count++
+			}
+		}
+		return count
+	}
+	tests := []struct {
+		key      string
+		expected int
+	}{
+		{"", 1},
+		{"unknown-key", 0}, // Default tier too, which the IP has used up
+		{"key-pro", 5},
+		{"key-ent", 20},
+	}
+	for _, tt := range tests {
+		if got := allowed(tt.key); got != tt.expected {
+			t.Errorf("key %q: expected %d of 20 requests allowed, got %d", tt.key, tt.expected, got)
+		}
+	}
+
+	// Adaptive limits scale the single configured limit, so they can't apply to tiers
+	appConfig.AdaptiveRateLimit = true
+	if err := newTestServer(t, appConfig).Setup(); err == nil {
+		t.Error("expected error for ADAPTIVE_RATE_LIMIT with tiers, got nil")
+	}
+}
+
+// TestServer_Setup_AdaptiveOddRate tests that the throttled limit of an odd rate is still a valid limiter
+func TestServer_Setup_AdaptiveOddRate(t *testing.T) {
+	appConfig := newTestConfig(t)
+	appConfig.RateLimit = 5
+	appConfig.RateLimitBurst = 5
+	appConfig.AdaptiveRateLimit = true
+	appConfig.AdaptiveThrottleFactor = 0.5
+
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := server.RateLimiter.(*limiter.AdaptiveLimiter); !ok {
+		t.Fatalf("expected *limiter.AdaptiveLimiter, got %T", server.RateLimiter)
+	}
+}
+
+// TestScaleLimiterConfig tests that the scaled burst is rounded up and kept at least the scaled rate
+func TestScaleLimiterConfig(t *testing.T) {
+	tests := []struct {
+		rate          float64
+		burst         int
+		factor        float64
+		expectedRate  float64
+		expectedBurst int
+	}{
+		{rate: 10, burst: 20, factor: 0.5, expectedRate: 5, expectedBurst: 10},
+		{rate: 5, burst: 5, factor: 0.5, expectedRate: 2.5, expectedBurst: 3},
+		{rate: 5, burst: 7, factor: 0.3, expectedRate: 1.5, expectedBurst: 3},
+		{rate: 9, burst: 1, factor: 0.5, expectedRate: 4.5, expectedBurst: 5}, // Raised to the rate
+		{rate: 5, burst: 0, factor: 0.5, expectedRate: 2.5, expectedBurst: 0}, // 0 = same as the rate
+		{rate: 5, burst: 5, factor: 1, expectedRate: 5, expectedBurst: 5},
+	}
+
+	for _, tt := range tests {
+		got := scaleLimiterConfig(limiter.LimiterConfig{Type: "memory", RequestsPerSecond: tt.rate, BurstSize: tt.burst}, tt.factor)
+		if math.Abs(got.RequestsPerSecond-tt.expectedRate) > 1e-9 || got.BurstSize != tt.expectedBurst {
+			t.Errorf("%.1f req/s, burst %d * %.1f: expected %.1f req/s, burst %d, got %.1f req/s, burst %d",
+				tt.rate, tt.burst, tt.factor, tt.expectedRate, tt.expectedBurst, got.RequestsPerSecond, got.BurstSize)
+		}
+		if _, err := limiter.NewLimiter(got); err != nil {
+			t.Errorf("%.1f req/s, burst %d * %.1f: %v", tt.rate, tt.burst, tt.factor, err)
+		}
+	}
+}
+
+// TestServer_Setup_OpenAPIValidation tests that /v1 requests breaking the embedded spec never reach the store
+func TestServer_Setup_OpenAPIValidation(t *testing.T) {
+	mockStore := store.NewMockStore()
+	server := newTestServer(t, newTestConfig(t))
+	server.Store = mockStore
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=12345", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "INVALID_REQUEST") {
+		t.Errorf("expected an INVALID_REQUEST error, got %s", rec.Body.String())
+	}
+	if len(mockStore.FindByIPCalls) != 0 {
+		t.Errorf("expected no store lookups, got %v", mockStore.FindByIPCalls)
+	}
+}
+
+// registeredSwaggerSpec returns the spec served at /swagger/doc.json
+// The generated docs package registers it; builds without swag init get one made from the
+// embedded JSON, templated like swag's generated docs.go
+func registeredSwaggerSpec(t *testing.T) *swag.Spec {
+	t.Helper()
+
+	if swag.GetSwagger(swag.Name) == nil {
+		var doc map[string]any
+		if err := json.Unmarshal(docs.SwaggerJSON, &doc); err != nil {
+			t.Fatalf("failed to parse the embedded spec: %v", err)
+		}
+		doc["info"].(map[string]any)["description"] = "{{escape .Description}}"
+		template, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("failed to build the spec template: %v", err)
+		}
+		swag.Register(swag.Name, &swag.Spec{InfoInstanceName: swag.Name, SwaggerTemplate: string(template)})
+	}
+	spec, ok := swag.GetSwagger(swag.Name).(*swag.Spec)
+	if !ok {
+		t.Fatalf("expected a *swag.Spec to be registered, got %T", swag.GetSwagger(swag.Name))
+	}
+	return spec
+}
+
+// TestServer_Setup_SwaggerDescription tests that the Swagger description names the configured backends
+func TestServer_Setup_SwaggerDescription(t *testing.T) {
+	spec := registeredSwaggerSpec(t)
+
+	appConfig := newTestConfig(t)
+	appConfig.RateLimitType = "redis"
+	server := newTestServer(t, appConfig)
+	server.RateLimiter = limiter.NewMockLimiter(true)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := "Backend: csv | Rate Limiter: redis"; !strings.Contains(spec.Description, expected) {
+		t.Errorf("expected description to contain %q, got %q", expected, spec.Description)
+	}
+}
+
+// TestServer_Setup_SwaggerDescription_Defaults tests the description when no environment variables are set
+func TestServer_Setup_SwaggerDescription_Defaults(t *testing.T) {
+	spec := registeredSwaggerSpec(t)
+	t.Setenv("DATASTORE_TYPE", "")
+	t.Setenv("RATE_LIMITER_TYPE", "")
+
+	server := newTestServer(t, config.Load())
+	server.Store = store.NewMockStore() // The default SQLite database isn't available here
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := "Backend: sqlite | Rate Limiter: memory | Version: dev"; spec.Description != expected {
+		t.Errorf("expected description %q, got %q", expected, spec.Description)
+	}
+}
+
+// TestServer_Setup_SwaggerDescription_BuildVersion tests that the -ldflags version is served in the spec
+func TestServer_Setup_SwaggerDescription_BuildVersion(t *testing.T) {
+	registeredSwaggerSpec(t)
+	previous := BuildVersion
+	BuildVersion = "1.2.3"
+	t.Cleanup(func() { BuildVersion = previous })
+
+	server := newTestServer(t, newTestConfig(t))
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Version: 1.2.3") {
+		t.Errorf("expected the served spec to contain the build version, got %s", rec.Body.String())
+	}
+}
+
+// TestServer_Handler tests the handler built by Setup over a real HTTP connection
+func TestServer_Handler(t *testing.T) {
+	server := newTestServer(t, newTestConfig(t))
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected /health status 200, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/find-country?ip=8.8.8.8")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected lookup status 200, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/v1/find-country", "application/json", strings.NewReader(`{"ip":"8.8.8.8"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected POST lookup status 200, got %d", resp.StatusCode)
+	}
+
+	// /admin is protected by the configured key
+	resp, err = http.Get(ts.URL + "/admin/config")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected /admin status 401 without a key, got %d", resp.StatusCode)
+	}
+}
+
+// TestServer_Setup_InjectedDependencies tests that injected mocks are used and the middleware order holds
+func TestServer_Setup_InjectedDependencies(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockLimiter := limiter.NewMockLimiter(false) // Deny everything
+
+	server := newTestServer(t, newTestConfig(t))
+	server.Store = mockStore
+	server.RateLimiter = mockLimiter
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 from the injected limiter, got %d", rec.Code)
+	}
+	// The request context middleware runs before rate limiting, so even rejected requests get an ID
+	if rec.Header().Get(custommiddleware.RequestIDHeader) == "" {
+		t.Error("expected X-Request-ID on a rate limited response")
+	}
+	// Rate limiting runs before the handler, so the store is never reached
+	if len(mockStore.FindByIPCalls) != 0 {
+		t.Errorf("expected no store lookups, got %v", mockStore.FindByIPCalls)
+	}
+
+	server.Close()
+	if !mockStore.CloseCalled {
+		t.Error("expected Close to close the injected store")
+	}
+}
+
+// TestServer_Run tests that Run serves until its context is cancelled
+func TestServer_Run(t *testing.T) {
+	// Reserve a free port for the server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	appConfig := newTestConfig(t)
+	appConfig.Port = port
+	server := newTestServer(t, appConfig)
+	if err := server.Setup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+
+	// Wait for the listener to come up
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://127.0.0.1:" + port + "/health"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("server never started: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
+
+// TestServer_Run_NotSetUp tests that Run refuses to start before Setup
+func TestServer_Run_NotSetUp(t *testing.T) {
+	if err := NewServer(newTestConfig(t), nil).Run(context.Background()); err == nil {
+		t.Error("expected error when Run is called before Setup, got nil")
+	}
+}
+
+// TestSetupEnricher tests that enrichment is off by default, and scores blocklisted addresses when there's a blocklist
+func TestSetupEnricher(t *testing.T) {
+	if enricher := setupEnricher(&config.Config{}, nil); enricher != nil {
+		t.Error("expected no enricher with ENRICHMENT_ENABLED=false")
+	}
+
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklistPath, []byte("8.8.8.0/24\n"), 0644); err != nil {
+		t.Fatalf("failed to create blocklist: %v", err)
+	}
+	blocklist, err := custommiddleware.NewBlocklist(custommiddleware.NewFileBlocklistLoader(blocklistPath))
+	if err != nil {
+		t.Fatalf("failed to load blocklist: %v", err)
+	}
+
+	enricher := setupEnricher(&config.Config{EnrichmentEnabled: true, EnrichmentTimeoutMS: 1000}, blocklist)
+	location := &models.IPLocation{City: "Mountain View", Country: "United States"}
+	if err := enricher.Enrich(context.Background(), "8.8.8.8", location); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.Continent != "North America" || location.Timezone == "" || location.AbuseScore == 0 {
+		t.Errorf("expected the continent, timezone and abuse score, got %+v", location)
+	}
+}
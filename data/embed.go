@@ -0,0 +1,16 @@
+// Package data holds the IP datasets bundled into the binary
+package data
+
+import _ "embed"
+
+// CSV is the IP dataset in CSV format (ip,city,country)
+//
+//go:embed ip2country.csv
+var CSV []byte
+
+// SQLiteDB is the pre-built SQLite database, generated from ip2country.csv
+// Regenerate after editing the CSV with: go generate ./data
+//
+//go:generate go run ../cmd/builddb -csv ip2country.csv -out ip2country.db
+//go:embed ip2country.db
+var SQLiteDB []byte
@@ -0,0 +1,9 @@
+package docs
+
+import _ "embed"
+
+// SwaggerJSON is the Swagger 2.0 spec generated into this directory by swag init
+// Embedded so the OpenAPI validation middleware enforces the same contract the docs describe
+//
+//go:embed swagger.json
+var SwaggerJSON []byte
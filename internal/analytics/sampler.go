@@ -0,0 +1,133 @@
+// Package analytics collects statistics about the lookups being served
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// Sample is one looked-up IP with the country it resolved to
+type Sample struct {
+	IP      string `json:"ip" example:"8.8.8.8"`
+	Country string `json:"country" example:"United States"`
+}
+
+// Sampler keeps a uniform random sample of the IPs looked up, for training anomaly detection models
+// It uses reservoir sampling (Algorithm R): the first size IPs fill the reservoir, then the n-th IP
+// replaces a random entry with probability size/n. Every IP seen so far is equally likely to be in
+// the sample, and memory never grows past size entries.
+// An IP already in the reservoir isn't added again, so the sample holds distinct IPs
+// Thread-safe
+type Sampler struct {
+	mu        sync.Mutex
+	reservoir []Sample
+	index     map[string]int // Reservoir position of each sampled IP
+	seen      uint64         // Number of IPs offered to the reservoir
+	rng       *rand.Rand
+}
+
+// NewSampler creates a sampler keeping at most size IPs
+// A size below 1 is raised to 1
+func NewSampler(size int) *Sampler {
+	return newSampler(size, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+}
+
+// newSampler creates a sampler drawing from rng, so tests can fix the seed
+func newSampler(size int, rng *rand.Rand) *Sampler {
+	if size < 1 {
+		size = 1
+	}
+	return &Sampler{
+		reservoir: make([]Sample, 0, size),
+		index:     make(map[string]int, size),
+		rng:       rng,
+	}
+}
+
+// AddSample offers a looked-up IP and its country to the reservoir
+// If ip is already sampled, only its country is updated
+func (s *Sampler) AddSample(ip, country string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.index[ip]; ok {
+		s.reservoir[i].Country = country
+		return
+	}
+
+	s.seen++
+	if len(s.reservoir) < cap(s.reservoir) {
+		s.index[ip] = len(s.reservoir)
+		s.reservoir = append(s.reservoir, Sample{IP: ip, Country: country})
+		return
+	}
+
+	if j := s.rng.Uint64N(s.seen); j < uint64(len(s.reservoir)) {
+		delete(s.index, s.reservoir[j].IP)
+		s.reservoir[j] = Sample{IP: ip, Country: country}
+		s.index[ip] = int(j)
+	}
+}
+
+// GetSample returns a copy of the current reservoir, in no particular order
+func (s *Sampler) GetSample() []Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Sample, len(s.reservoir))
+	copy(out, s.reservoir)
+	return out
+}
+
+// Seen returns the number of IPs offered to the reservoir so far
+func (s *Sampler) Seen() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seen
+}
+
+// Size returns the maximum number of IPs sampled
+func (s *Sampler) Size() int {
+	return cap(s.reservoir)
+}
+
+// FlushToRedis stores the current reservoir under key as a JSON array of samples, replacing the previous one
+// The key has no expiry: the ML pipeline reads the latest sample whenever it runs
+func (s *Sampler) FlushToRedis(ctx context.Context, client *redis.Client, key string) error {
+	data, err := json.Marshal(s.GetSample())
+	if err != nil {
+		return fmt.Errorf("failed to encode sample: %w", err)
+	}
+	if err := client.Set(ctx, key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to write sample to Redis: %w", err)
+	}
+	return nil
+}
+
+// FlushEvery calls FlushToRedis every interval. Failed flushes are logged and retried at the next tick
+// Blocks until ctx is cancelled, so run it in its own goroutine
+func (s *Sampler) FlushEvery(ctx context.Context, client *redis.Client, key string, interval time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.FlushToRedis(ctx, client, key); err != nil {
+			log.Error().Err(err).Msg("Failed to flush the IP sample to Redis")
+			continue
+		}
+		log.Debug().Str("key", key).Msg("IP sample flushed to Redis")
+	}
+}
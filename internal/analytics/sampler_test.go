@@ -0,0 +1,165 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestSampler_Bounded tests that the reservoir never holds more than size IPs
+func TestSampler_Bounded(t *testing.T) {
+	s := NewSampler(10)
+
+	for i := 0; i < 5; i++ {
+		s.AddSample(fmt.Sprintf("10.0.0.%d", i), "Private")
+	}
+	if got := len(s.GetSample()); got != 5 {
+		t.Errorf("expected 5 samples before the reservoir is full, got %d", got)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.AddSample(fmt.Sprintf("10.0.%d.%d", i/256, i%256), "Private")
+	}
+	if got := len(s.GetSample()); got != 10 {
+		t.Errorf("expected 10 samples, got %d", got)
+	}
+	if s.Size() != 10 {
+		t.Errorf("expected size 10, got %d", s.Size())
+	}
+}
+
+// TestSampler_Distinct tests that an IP already in the reservoir isn't added twice
+func TestSampler_Distinct(t *testing.T) {
+	s := NewSampler(10)
+	s.AddSample("8.8.8.8", "United States")
+	s.AddSample("8.8.8.8", "United States of America")
+	s.AddSample("1.1.1.1", "Australia")
+
+	sample := s.GetSample()
+	if len(sample) != 2 || s.Seen() != 2 {
+		t.Fatalf("expected 2 samples of 2 IPs seen, got %+v of %d", sample, s.Seen())
+	}
+	if sample[0] != (Sample{IP: "8.8.8.8", Country: "United States of America"}) {
+		t.Errorf("expected the latest country for 8.8.8.8, got %+v", sample[0])
+	}
+}
+
+// TestSampler_Uniform tests with a chi-squared test that every IP of a long stream is equally likely to be sampled
+func TestSampler_Uniform(t *testing.T) {
+	const (
+		streamLength = 1000
+		reservoir    = 50
+		trials       = 400
+		buckets      = 10 // Consecutive runs of the stream, streamLength/buckets IPs each
+	)
+
+	ips := make([]string, streamLength)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	bucketOf := make(map[string]int, streamLength)
+	for i, ip := range ips {
+		bucketOf[ip] = i * buckets / streamLength
+	}
+
+	var observed [buckets]float64
+	for trial := uint64(0); trial < trials; trial++ {
+		s := newSampler(reservoir, rand.New(rand.NewPCG(trial, trial)))
+		for _, ip := range ips {
+			s.AddSample(ip, "Private")
+		}
+		for _, sample := range s.GetSample() {
+			observed[bucketOf[sample.IP]]++
+		}
+	}
+
+	expected := float64(trials*reservoir) / buckets
+	var chiSquared float64
+	for _, o := range observed {
+		chiSquared += (o - expected) * (o - expected) / expected
+	}
+	// 27.88 is the critical value for 9 degrees of freedom at p = 0.001
+	if chiSquared > 27.88 {
+		t.Errorf("expected a uniform sample, got chi-squared %.2f for %v (expected %.0f per bucket)", chiSquared, observed, expected)
+	}
+}
+
+// TestSampler_Concurrent tests that AddSample and GetSample are safe for concurrent use (run with -race)
+func TestSampler_Concurrent(t *testing.T) {
+	s := NewSampler(100)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				s.AddSample(fmt.Sprintf("10.%d.%d.%d", g, i/256, i%256), "Private")
+				if i%100 == 0 {
+					s.GetSample()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.Seen() != 8000 {
+		t.Errorf("expected 8000 IPs seen, got %d", s.Seen())
+	}
+	sample := s.GetSample()
+	if len(sample) != 100 {
+		t.Errorf("expected 100 samples, got %d", len(sample))
+	}
+	seen := make(map[string]bool)
+	for _, entry := range sample {
+		if seen[entry.IP] {
+			t.Errorf("duplicate IP %s in the sample", entry.IP)
+		}
+		seen[entry.IP] = true
+	}
+}
+
+// TestSampler_FlushToRedis tests that the reservoir is written as a JSON array of samples
+func TestSampler_FlushToRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	s := NewSampler(10)
+	s.AddSample("8.8.8.8", "United States")
+	s.AddSample("1.1.1.1", "Australia")
+
+	if err := s.FlushToRedis(context.Background(), client, "analytics:sample"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := mr.Get("analytics:sample")
+	if err != nil {
+		t.Fatalf("expected the sample in Redis: %v", err)
+	}
+	var flushed []Sample
+	if err := json.Unmarshal([]byte(data), &flushed); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", data, err)
+	}
+	if len(flushed) != 2 || flushed[0].IP != "8.8.8.8" || flushed[1].Country != "Australia" {
+		t.Errorf("expected both samples, got %+v", flushed)
+	}
+}
+
+// TestSampler_FlushToRedis_Error tests that a Redis failure is returned
+func TestSampler_FlushToRedis_Error(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	mr.Close()
+
+	if err := NewSampler(10).FlushToRedis(context.Background(), client, "analytics:sample"); err == nil {
+		t.Error("expected an error with Redis down")
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoad_NodeID tests that NODE_ID overrides the hostname default
+func TestLoad_NodeID(t *testing.T) {
+	t.Setenv("NODE_ID", "")
+	expected, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("failed to read hostname: %v", err)
+	}
+
+	if got := Load().NodeID; got != expected {
+		t.Errorf("expected NodeID to default to hostname %q, got %q", expected, got)
+	}
+
+	t.Setenv("NODE_ID", "api-7")
+	if got := Load().NodeID; got != "api-7" {
+		t.Errorf("expected NodeID 'api-7', got %q", got)
+	}
+}
+
+// TestLoad_LogBodyExcludePaths tests the comma-separated list parsing and its default
+func TestLoad_LogBodyExcludePaths(t *testing.T) {
+	t.Setenv("LOG_BODY_EXCLUDE_PATHS", "")
+	if got := Load().LogBodyExcludePaths; len(got) != 1 || got[0] != "/admin/token" {
+		t.Errorf("expected default [/admin/token], got %v", got)
+	}
+
+	t.Setenv("LOG_BODY_EXCLUDE_PATHS", " /admin , ,/v1/find-country")
+	got := Load().LogBodyExcludePaths
+	if len(got) != 2 || got[0] != "/admin" || got[1] != "/v1/find-country" {
+		t.Errorf("expected [/admin /v1/find-country], got %v", got)
+	}
+}
+
+// TestLoad_RateLimitExemptPaths tests that health probes and metrics scrapes are exempt by default, and a custom list replaces the default
+func TestLoad_RateLimitExemptPaths(t *testing.T) {
+	t.Setenv("RATE_LIMIT_EXEMPT_PATHS", "")
+	if got := Load().RateLimitExemptPaths; len(got) != 2 || got[0] != "/health" || got[1] != "/metrics" {
+		t.Errorf("expected default [/health /metrics], got %v", got)
+	}
+
+	t.Setenv("RATE_LIMIT_EXEMPT_PATHS", "/healthz, /admin")
+	got := Load().RateLimitExemptPaths
+	if len(got) != 2 || got[0] != "/healthz" || got[1] != "/admin" {
+		t.Errorf("expected [/healthz /admin], got %v", got)
+	}
+}
+
+// TestLoad_FingerprintRateLimitMultiplier tests that the fingerprint limiter is off unless enabled
+func TestLoad_FingerprintRateLimitMultiplier(t *testing.T) {
+	t.Setenv("FINGERPRINT_RATE_LIMIT_MULTIPLIER", "")
+	if got := Load().FingerprintRateLimitMultiplier; got != 0 {
+		t.Errorf("expected the fingerprint limiter to be disabled by default, got multiplier %d", got)
+	}
+
+	t.Setenv("FINGERPRINT_RATE_LIMIT_MULTIPLIER", "10")
+	if got := Load().FingerprintRateLimitMultiplier; got != 10 {
+		t.Errorf("expected multiplier 10, got %d", got)
+	}
+}
+
+// TestLoad_RedisClusterAddrs tests that the cluster is off by default and its nodes are parsed from a list
+func TestLoad_RedisClusterAddrs(t *testing.T) {
+	t.Setenv("REDIS_CLUSTER_ADDRS", "")
+	if got := Load().RedisClusterAddrs; len(got) != 0 {
+		t.Errorf("expected no cluster nodes by default, got %v", got)
+	}
+
+	t.Setenv("REDIS_CLUSTER_ADDRS", "redis-1:6379, redis-2:6379")
+	got := Load().RedisClusterAddrs
+	if len(got) != 2 || got[0] != "redis-1:6379" || got[1] != "redis-2:6379" {
+		t.Errorf("expected [redis-1:6379 redis-2:6379], got %v", got)
+	}
+}
+
+// TestLoad_SQLitePath tests that the SQLite store defaults to the embedded database,
+// or to DATASTORE_PATH when it names a .db file, and that SQLITE_PATH overrides both
+func TestLoad_SQLitePath(t *testing.T) {
+	t.Setenv("SQLITE_PATH", "")
+	t.Setenv("DATASTORE_PATH", "./data/ip2country.csv")
+	if got := Load().SQLitePath; got != ":embedded:" {
+		t.Errorf("expected :embedded: by default, got %q", got)
+	}
+
+	t.Setenv("DATASTORE_PATH", "/var/lib/ip2country/ip2country.db")
+	if got := Load().SQLitePath; got != "/var/lib/ip2country/ip2country.db" {
+		t.Errorf("expected DATASTORE_PATH, got %q", got)
+	}
+
+	t.Setenv("SQLITE_PATH", ":memory:")
+	if got := Load().SQLitePath; got != ":memory:" {
+		t.Errorf("expected :memory:, got %q", got)
+	}
+}
+
+// TestLoadRateLimitTiersFile tests parsing and validation of the rate limit tiers YAML
+func TestLoadRateLimitTiersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiers.yaml")
+	content := "default_tier: free\ntiers:\n  free:\n    requests_per_second: 10\n  pro:\n    requests_per_second: 100\n    burst: 200\n" +
+		"  enterprise:\n    unlimited: true\nkeys:\n  key-pro: pro\n  key-ent: enterprise\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	file, err := LoadRateLimitTiersFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.DefaultTier != "free" || len(file.Tiers) != 3 || file.Tiers["pro"] != (RateLimitTier{RequestsPerSecond: 100, Burst: 200}) ||
+		!file.Tiers["enterprise"].Unlimited {
+		t.Errorf("unexpected tiers: %+v", file)
+	}
+	if file.Keys["key-pro"] != "pro" || file.Keys["key-ent"] != "enterprise" {
+		t.Errorf("unexpected keys: %v", file.Keys)
+	}
+
+	for name, content := range map[string]string{
+		"no default tier":    "tiers:\n  free:\n    requests_per_second: 10\n",
+		"undefined default":  "default_tier: gold\ntiers:\n  free:\n    requests_per_second: 10\n",
+		"no rate":            "default_tier: free\ntiers:\n  free: {}\n",
+		"undefined key tier": "default_tier: free\ntiers:\n  free:\n    requests_per_second: 10\nkeys:\n  key-gold: gold\n",
+		"bad YAML":           "tiers: [",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if _, err := LoadRateLimitTiersFile(path); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	if _, err := LoadRateLimitTiersFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for a missing file, got nil")
+	}
+}
+
+// TestLoadWeightedStoreFile tests parsing and validation of the weighted store YAML
+func TestLoadWeightedStoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weighted.yaml")
+	content := "stores:\n  - type: redis\n    weight: 90\n  - type: mysql\n    weight: 10\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	file, err := LoadWeightedStoreFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []WeightedStoreEntry{{Type: "redis", Weight: 90}, {Type: "mysql", Weight: 10}}
+	if len(file.Stores) != 2 || file.Stores[0] != expected[0] || file.Stores[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, file.Stores)
+	}
+
+	for name, content := range map[string]string{
+		"no stores": "stores: []\n",
+		"recursive": "stores:\n  - type: weighted\n    weight: 1\n",
+		"no type":   "stores:\n  - weight: 1\n",
+		"bad YAML":  "stores: [",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if _, err := LoadWeightedStoreFile(path); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	if _, err := LoadWeightedStoreFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for a missing file, got nil")
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import "sync"
+
+// maskedValue replaces secrets in sanitised config output
+const maskedValue = "****"
+
+// ReloadableConfig holds the current configuration and allows replacing it at runtime
+// Readers call Get() on each use so they always see the latest values
+// without restarting the process
+type ReloadableConfig struct {
+	mu       sync.RWMutex
+	current  *Config
+	onReload []func(*Config)
+}
+
+// NewReloadableConfig wraps an already-loaded configuration
+func NewReloadableConfig(initial *Config) *ReloadableConfig {
+	return &ReloadableConfig{current: initial}
+}
+
+// Get returns the current configuration
+// The returned Config must be treated as read-only - Reload swaps the pointer, it never mutates it
+func (rc *ReloadableConfig) Get() *Config {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.current
+}
+
+// OnReload registers a function called with the new configuration after every successful Reload
+// Used for settings that must be pushed rather than read per request (e.g. global log level)
+func (rc *ReloadableConfig) OnReload(fn func(*Config)) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.onReload = append(rc.onReload, fn)
+}
+
+// Reload re-reads the environment (and .env file) and atomically replaces the current configuration
+func (rc *ReloadableConfig) Reload() error {
+	next := Load()
+
+	rc.mu.Lock()
+	rc.current = next
+	hooks := rc.onReload
+	rc.mu.Unlock()
+
+	// Run hooks outside the lock so they can call Get()
+	for _, hook := range hooks {
+		hook(next)
+	}
+	return nil
+}
+
+// Sanitized returns a copy of the configuration with secrets masked
+// Safe to expose over the admin API or write to logs
+func (c *Config) Sanitized() *Config {
+	sanitized := *c
+	if sanitized.MySQLDSN != "" {
+		sanitized.MySQLDSN = maskedValue
+	}
+	if sanitized.PostgresDSN != "" {
+		sanitized.PostgresDSN = maskedValue
+	}
+	if sanitized.RedisPassword != "" {
+		sanitized.RedisPassword = maskedValue
+	}
+	if sanitized.AdminAPIKey != "" {
+		sanitized.AdminAPIKey = maskedValue
+	}
+	return &sanitized
+}
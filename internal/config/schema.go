@@ -0,0 +1,217 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Schema is the JSON Schema (draft-07) of the configuration: every setting Load reads,
+// keyed by environment variable name, with its type and, where there is a fixed set, its allowed values
+// Ranges and settings that depend on each other are left to Validate
+//
+//go:embed config.schema.json
+var Schema []byte
+
+// schemaURL is the URL the schema is compiled under
+const schemaURL = "config.schema.json"
+
+// schemaErrorPrinter formats validation errors in English, whatever the process locale
+var schemaErrorPrinter = message.NewPrinter(language.English)
+
+// SchemaError is a value that doesn't conform to Schema
+type SchemaError struct {
+	Path     string // JSON pointer to the value, e.g. "/DATASTORE_TYPE" or "/GOSSIP_PEERS/0"
+	Expected string // e.g. "integer" or `one of "sqlite", "csv"`
+	Actual   string // The value found, e.g. `"oracle"`
+}
+
+// Error formats the problem as "path: expected X, got Y"
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("%s: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// SchemaErrors is returned by LoadFromFile when the file doesn't conform to Schema
+type SchemaErrors []SchemaError
+
+// Error lists every problem, separated by "; "
+func (errs SchemaErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// compiledSchema is Schema, compiled on first use
+// Schema is embedded and compiled by the tests, so a failure here is a bug: it panics
+var compiledSchema = sync.OnceValue(func() *jsonschema.Schema {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(Schema))
+	if err != nil {
+		panic(fmt.Errorf("failed to parse config schema: %w", err))
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft7)
+	if err := c.AddResource(schemaURL, doc); err != nil {
+		panic(fmt.Errorf("failed to load config schema: %w", err))
+	}
+	return c.MustCompile(schemaURL)
+})
+
+// settingTypes maps each setting of Schema to the JSON types it accepts, e.g. ["array", "string"]
+var settingTypes = sync.OnceValue(func() map[string][]string {
+	var parsed struct {
+		Properties map[string]struct {
+			Type any `json:"type"` // A type name, or a list of them
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema, &parsed); err != nil {
+		panic(fmt.Errorf("failed to parse config schema: %w", err))
+	}
+
+	types := make(map[string][]string, len(parsed.Properties))
+	for name, property := range parsed.Properties {
+		switch t := property.Type.(type) {
+		case string:
+			types[name] = []string{t}
+		case []any:
+			for _, typ := range t {
+				if s, ok := typ.(string); ok {
+					types[name] = append(types[name], s)
+				}
+			}
+		}
+	}
+	return types
+})
+
+// ValidateSchema checks settings (environment variable name -> value, as decoded from JSON or YAML)
+// against Schema. Returns every problem found; an empty slice means settings conform
+func ValidateSchema(settings map[string]any) []SchemaError {
+	// Round trip through JSON so YAML values (int, map[string]any...) have the types the validator expects
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return []SchemaError{{Path: "/", Expected: "a JSON-compatible document", Actual: err.Error()}}
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return []SchemaError{{Path: "/", Expected: "a JSON-compatible document", Actual: err.Error()}}
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(compiledSchema().Validate(doc), &validationErr) {
+		return nil
+	}
+	return schemaErrors(validationErr, doc)
+}
+
+// schemaErrors flattens a validation error into one SchemaError per failed keyword
+func schemaErrors(err *jsonschema.ValidationError, doc any) []SchemaError {
+	if len(err.Causes) > 0 {
+		var errs []SchemaError
+		for _, cause := range err.Causes {
+			errs = append(errs, schemaErrors(cause, doc)...)
+		}
+		return errs
+	}
+
+	path := "/" + strings.Join(err.InstanceLocation, "/")
+	switch k := err.ErrorKind.(type) {
+	case *kind.AdditionalProperties:
+		// One error per unknown setting, usually a typo
+		errs := make([]SchemaError, len(k.Properties))
+		for i, name := range k.Properties {
+			errs[i] = SchemaError{Path: strings.TrimSuffix(path, "/") + "/" + name, Expected: "a known setting", Actual: "unknown setting " + strconv.Quote(name)}
+		}
+		return errs
+	case *kind.Type:
+		return []SchemaError{{Path: path, Expected: strings.Join(k.Want, " or "), Actual: k.Got + " " + describeValue(valueAt(doc, err.InstanceLocation))}}
+	case *kind.Enum:
+		want := make([]string, len(k.Want))
+		for i, v := range k.Want {
+			want[i] = describeValue(v)
+		}
+		return []SchemaError{{Path: path, Expected: "one of " + strings.Join(want, ", "), Actual: describeValue(k.Got)}}
+	default:
+		return []SchemaError{{Path: path, Expected: err.ErrorKind.LocalizedString(schemaErrorPrinter), Actual: describeValue(valueAt(doc, err.InstanceLocation))}}
+	}
+}
+
+// valueAt returns the value at location in a document decoded by jsonschema.UnmarshalJSON
+func valueAt(doc any, location []string) any {
+	for _, token := range location {
+		switch v := doc.(type) {
+		case map[string]any:
+			doc = v[token]
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			doc = v[i]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
+
+// describeValue formats a value as JSON, e.g. "oracle" with its quotes
+func describeValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// ValidateEnv checks the environment variables Schema knows against it
+// Each value is converted to its setting's type first, as Load would: RATE_LIMIT=10 is an integer,
+// RATE_LIMIT=ten stays a string and is reported, where Load would silently use the default.
+// Other variables (PATH, HOME...) are ignored, so unlike LoadFromFile a misspelt name isn't caught
+func ValidateEnv() []SchemaError {
+	settings := make(map[string]any)
+	for name, types := range settingTypes() {
+		value, set := os.LookupEnv(name)
+		if !set || value == "" {
+			continue // Load uses the default
+		}
+		settings[name] = parseEnvValue(value, types)
+	}
+	return ValidateSchema(settings)
+}
+
+// parseEnvValue converts an environment variable to a setting accepting types
+// Settings accepting strings (including lists, see getEnvAsList) keep the value as is; a value that
+// doesn't parse as the setting's type is returned as a string too, for the schema to report
+func parseEnvValue(value string, types []string) any {
+	switch {
+	case slices.Contains(types, "string"):
+		return value
+	case slices.Contains(types, "integer"):
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case slices.Contains(types, "number"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case slices.Contains(types, "boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
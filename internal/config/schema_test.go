@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// TestSchema_SelfValidating tests that the schema is itself a valid draft-07 JSON Schema
+func TestSchema_SelfValidating(t *testing.T) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(Schema))
+	if err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	metaSchema, err := jsonschema.NewCompiler().Compile("http://json-schema.org/draft-07/schema")
+	if err != nil {
+		t.Fatalf("failed to compile the draft-07 meta-schema: %v", err)
+	}
+	if err := metaSchema.Validate(doc); err != nil {
+		t.Errorf("schema doesn't conform to the draft-07 meta-schema: %v", err)
+	}
+}
+
+// TestSchema_CoversLoad tests that every environment variable Load reads is in the schema, with a type matching how it's parsed
+func TestSchema_CoversLoad(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "config.go", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse config.go: %v", err)
+	}
+
+	expectedTypes := map[string]string{
+		"getEnv":        "string",
+		"getEnvAsInt":   "integer",
+		"getEnvAsBool":  "boolean",
+		"getEnvAsFloat": "number",
+		"getEnvAsList":  "array",
+	}
+	found := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		fn, ok := call.Fun.(*ast.Ident)
+		lit, isLit := call.Args[0].(*ast.BasicLit)
+		if !ok || !isLit || expectedTypes[fn.Name] == "" {
+			return true
+		}
+
+		name, _ := strconv.Unquote(lit.Value)
+		found++
+		types, ok := settingTypes()[name]
+		if !ok {
+			t.Errorf("%s is read by Load but missing from the schema", name)
+		} else if !slices.Contains(types, expectedTypes[fn.Name]) {
+			t.Errorf("%s is read with %s: expected schema type %q, got %v", name, fn.Name, expectedTypes[fn.Name], types)
+		}
+		return true
+	})
+
+	if found != len(settingTypes()) {
+		t.Errorf("expected the schema to have the %d settings Load reads, got %d", found, len(settingTypes()))
+	}
+}
+
+// TestValidateSchema tests valid settings, unknown names, values outside an enum and values of the wrong type
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		expected []SchemaError
+	}{
+		{
+			name: "valid",
+			settings: map[string]any{
+				"PORT":                    8080,
+				"DATASTORE_TYPE":          "csv",
+				"RATE_LIMIT":              10,
+				"ADAPTIVE_HIGH_WATERMARK": 0.9,
+				"LOG_PRETTY":              false,
+				"GOSSIP_PEERS":            []any{"node-1:7946", "node-2:7946"},
+				"RATE_LIMIT_EXEMPT_PATHS": "/health,/metrics",
+				"SHADOW_DATASTORE_TYPE":   "",
+			},
+		},
+		{
+			name:     "unknown field",
+			settings: map[string]any{"DATASTORE_TPYE": "csv"},
+			expected: []SchemaError{{Path: "/DATASTORE_TPYE", Expected: "a known setting", Actual: `unknown setting "DATASTORE_TPYE"`}},
+		},
+		{
+			name:     "enum",
+			settings: map[string]any{"DATASTORE_TYPE": "oracle"},
+			expected: []SchemaError{{Path: "/DATASTORE_TYPE", Expected: `one of "sqlite", "csv", "mysql", "postgres", "redis", "maxmind", "weighted"`, Actual: `"oracle"`}},
+		},
+		{
+			name:     "string for an integer",
+			settings: map[string]any{"RATE_LIMIT": "ten"},
+			expected: []SchemaError{{Path: "/RATE_LIMIT", Expected: "integer", Actual: `string "ten"`}},
+		},
+		{
+			name:     "list item",
+			settings: map[string]any{"GOSSIP_PEERS": []any{"node-1:7946", 7946}},
+			expected: []SchemaError{{Path: "/GOSSIP_PEERS/1", Expected: "string", Actual: "number 7946"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateSchema(tt.settings)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("expected %+v, got %+v", tt.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+// unsetEnv unsets names for the rest of the test, restoring them when it ends
+func unsetEnv(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "") // Registers the restore
+		os.Unsetenv(name)
+	}
+}
+
+// writeConfigFile writes content to a YAML file in a temporary directory
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+// TestLoadFromFile tests that the file's settings are loaded, without overriding the environment
+func TestLoadFromFile(t *testing.T) {
+	unsetEnv(t, "DATASTORE_TYPE", "RATE_LIMIT", "GOSSIP_PEERS")
+	t.Setenv("PORT", "9090")
+
+	path := writeConfigFile(t, `
+DATASTORE_TYPE: csv
+RATE_LIMIT: 25
+PORT: 8080
+GOSSIP_PEERS:
+  - node-1:7946
+  - node-2:7946
+`)
+
+	cfg, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DatastoreType != "csv" || cfg.RateLimit != 25 {
+		t.Errorf("expected the file's settings, got DatastoreType=%q RateLimit=%d", cfg.DatastoreType, cfg.RateLimit)
+	}
+	if cfg.Port != "9090" {
+		t.Errorf("expected PORT from the environment to win, got %q", cfg.Port)
+	}
+	if len(cfg.GossipPeers) != 2 || cfg.GossipPeers[1] != "node-2:7946" {
+		t.Errorf("expected [node-1:7946 node-2:7946], got %v", cfg.GossipPeers)
+	}
+}
+
+// TestLoadFromFile_Invalid tests that a file not matching the schema returns every SchemaError and loads nothing
+func TestLoadFromFile_Invalid(t *testing.T) {
+	unsetEnv(t, "DATASTORE_TYPE", "RATE_LIMIT")
+
+	path := writeConfigFile(t, `
+DATASTORE_TYPE: cvs
+RATE_LIMIT: "ten"
+RATE_LIMT: 10
+`)
+
+	cfg, err := LoadFromFile(path)
+	var schemaErrs SchemaErrors
+	if !errors.As(err, &schemaErrs) {
+		t.Fatalf("expected SchemaErrors, got %v", err)
+	}
+	if cfg != nil {
+		t.Error("expected no config")
+	}
+	if len(schemaErrs) != 3 {
+		t.Errorf("expected 3 errors, got %v", schemaErrs)
+	}
+	for _, field := range []string{"/DATASTORE_TYPE", "/RATE_LIMIT:", "/RATE_LIMT"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected an error for %s, got %v", field, err)
+		}
+	}
+	if _, set := os.LookupEnv("DATASTORE_TYPE"); set {
+		t.Error("expected nothing applied from an invalid file")
+	}
+}
+
+// TestLoadFromFile_Errors tests that missing and malformed files are errors
+func TestLoadFromFile_Errors(t *testing.T) {
+	if _, err := LoadFromFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if _, err := LoadFromFile(writeConfigFile(t, "- not\n- a map\n")); err == nil {
+		t.Error("expected an error for a file that isn't a map")
+	}
+}
+
+// TestValidateEnv tests that environment variables are checked against the schema after parsing them like Load
+func TestValidateEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT", "10")
+	t.Setenv("ADAPTIVE_RATE_LIMIT", "true")
+	t.Setenv("DATASTORE_TYPE", "csv")
+	t.Setenv("GOSSIP_PEERS", "node-1:7946,node-2:7946")
+	if errs := ValidateEnv(); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+
+	t.Setenv("RATE_LIMIT", "ten")
+	t.Setenv("DATASTORE_TYPE", "oracle")
+	errs := ValidateEnv()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	paths := []string{errs[0].Path, errs[1].Path}
+	if !strings.Contains(strings.Join(paths, " "), "/RATE_LIMIT") || !strings.Contains(strings.Join(paths, " "), "/DATASTORE_TYPE") {
+		t.Errorf("expected errors for RATE_LIMIT and DATASTORE_TYPE, got %v", errs)
+	}
+}
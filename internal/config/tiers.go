@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// RateLimitTier is the per-IP limit of one customer tier in the RATE_LIMIT_TIERS_CONFIG file
+type RateLimitTier struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // Rate allowed to each IP of the tier
+	Burst             int     `yaml:"burst"`               // Max requests at once (0 = same as the rate)
+	Unlimited         bool    `yaml:"unlimited"`           // Never rate limit the tier (requests_per_second is ignored)
+}
+
+// RateLimitTiersFile is the YAML file read when RATE_LIMIT_TIERS_CONFIG is set
+// Requests are placed in a tier by their X-API-Key header; requests without a listed key get default_tier
+//
+// Example:
+//
+//	default_tier: free
+//	tiers:
+//	  free:
+//	    requests_per_second: 10
+//	  pro:
+//	    requests_per_second: 100
+//	    burst: 200
+//	  enterprise:
+//	    unlimited: true
+//	keys:
+//	  0c8f2b7e: pro
+//	  91d4a6c3: enterprise
+type RateLimitTiersFile struct {
+	DefaultTier string                   `yaml:"default_tier"`
+	Tiers       map[string]RateLimitTier `yaml:"tiers"`
+	Keys        map[string]string        `yaml:"keys"` // API key -> tier
+}
+
+// LoadRateLimitTiersFile reads and validates the rate limit tiers config at path
+// Every tier the file refers to, as the default or for a key, must be defined
+func LoadRateLimitTiersFile(path string) (*RateLimitTiersFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit tiers config: %w", err)
+	}
+
+	var file RateLimitTiersFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rate limit tiers config: %w", err)
+	}
+
+	if _, ok := file.Tiers[file.DefaultTier]; !ok {
+		return nil, fmt.Errorf("rate limit tiers config %s: default tier %q is not defined", path, file.DefaultTier)
+	}
+	for name, tier := range file.Tiers {
+		if !tier.Unlimited && tier.RequestsPerSecond <= 0 {
+			return nil, fmt.Errorf("rate limit tiers config: tier %q needs requests_per_second > 0 or unlimited", name)
+		}
+	}
+	for key, tier := range file.Keys {
+		if _, ok := file.Tiers[tier]; !ok || key == "" {
+			return nil, fmt.Errorf("rate limit tiers config: key %q has undefined tier %q", key, tier)
+		}
+	}
+
+	return &file, nil
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ConfigError is a problem found in a configuration by Validate
+type ConfigError struct {
+	Field   string // Environment variable at fault, e.g. "REDIS_ADDR"
+	Message string
+	Fatal   bool // false = a warning: the server works, but probably not as intended
+}
+
+// Error formats the problem as "FIELD: message"
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Validate checks c for settings that are invalid or don't work together
+// Catches at startup what would otherwise surface as a confusing runtime error
+// Returns every problem found, fatal or not; an empty slice means c is valid
+func Validate(c *Config) []ConfigError {
+	var errs []ConfigError
+	fatal := func(field, format string, args ...any) {
+		errs = append(errs, ConfigError{Field: field, Message: fmt.Sprintf(format, args...), Fatal: true})
+	}
+	warn := func(field, format string, args ...any) {
+		errs = append(errs, ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		fatal("PORT", "must be a number from 1 to 65535, got %q", c.Port)
+	}
+
+	if c.RateLimit <= 0 {
+		fatal("RATE_LIMIT", "must be positive, got %d", c.RateLimit)
+	}
+	if c.RateLimitWindow <= 0 {
+		fatal("RATE_LIMIT_WINDOW", "must be positive, got %d", c.RateLimitWindow)
+	}
+	for _, path := range c.RateLimitExemptPaths {
+		if !strings.HasPrefix(path, "/") {
+			fatal("RATE_LIMIT_EXEMPT_PATHS", "paths must start with '/', got %q", path)
+		} else if path == "/" {
+			warn("RATE_LIMIT_EXEMPT_PATHS", "'/' exempts every request from rate limiting")
+		}
+	}
+
+	if c.AdaptiveRateLimit {
+		if c.AdaptiveHighWatermark <= 0 || c.AdaptiveHighWatermark > 1 {
+			fatal("ADAPTIVE_HIGH_WATERMARK", "must be between 0 and 1, got %.2f", c.AdaptiveHighWatermark)
+		}
+		if c.AdaptiveLowWatermark <= 0 || c.AdaptiveLowWatermark > c.AdaptiveHighWatermark {
+			fatal("ADAPTIVE_LOW_WATERMARK", "must be positive and at most ADAPTIVE_HIGH_WATERMARK, got %.2f", c.AdaptiveLowWatermark)
+		}
+		if c.AdaptiveThrottleFactor <= 0 || c.AdaptiveThrottleFactor > 1 {
+			fatal("ADAPTIVE_THROTTLE_FACTOR", "must be between 0 and 1, got %.2f", c.AdaptiveThrottleFactor)
+		}
+	}
+
+	switch c.DatastoreType {
+	case "redis":
+		if c.RedisAddr == "" && len(c.RedisClusterAddrs) == 0 {
+			fatal("REDIS_ADDR", "required with DATASTORE_TYPE=redis (or set REDIS_CLUSTER_ADDRS)")
+		}
+	case "mysql":
+		if c.MySQLDSN == "" {
+			fatal("MYSQL_DSN", "required with DATASTORE_TYPE=mysql")
+		}
+	case "postgres":
+		if c.PostgresDSN == "" {
+			fatal("POSTGRES_DSN", "required with DATASTORE_TYPE=postgres")
+		}
+	}
+
+	if c.PGMaxConns < 0 {
+		fatal("PG_MAX_CONNS", "must be 0 (pgx default) or positive, got %d", c.PGMaxConns)
+	}
+	if c.PGMaxConnIdleSeconds < 0 {
+		fatal("PG_MAX_CONN_IDLE_SECONDS", "must be 0 (pgx default) or positive, got %d", c.PGMaxConnIdleSeconds)
+	}
+	if c.PGHealthCheckPeriodSeconds < 0 {
+		fatal("PG_HEALTH_CHECK_PERIOD_SECONDS", "must be 0 (pgx default) or positive, got %d", c.PGHealthCheckPeriodSeconds)
+	}
+
+	if c.IPDataTTLHours < 0 {
+		fatal("IP_DATA_TTL_HOURS", "must be 0 (never expire) or positive, got %d", c.IPDataTTLHours)
+	}
+	if c.StoreCompareIntervalHours < 0 {
+		fatal("STORE_COMPARE_INTERVAL_HOURS", "must be 0 (disabled) or positive, got %d", c.StoreCompareIntervalHours)
+	}
+	if c.StoreDriftAlertThreshold < 0 {
+		fatal("STORE_DRIFT_ALERT_THRESHOLD", "must be 0 (alert on any drift) or positive, got %d", c.StoreDriftAlertThreshold)
+	}
+	if c.CacheSize < 0 {
+		fatal("CACHE_SIZE", "must be 0 (disabled) or positive, got %d", c.CacheSize)
+	}
+	if c.CacheTTLSeconds < 0 {
+		fatal("CACHE_TTL_SECONDS", "must be 0 (until evicted) or positive, got %d", c.CacheTTLSeconds)
+	}
+
+	watchesCSV := c.DatastoreType == "csv" && c.DatastorePath != "" && c.DatastorePath != ":embedded:"
+	if c.DatastoreWatch && !watchesCSV && c.DatastoreType != "maxmind" {
+		warn("DATASTORE_WATCH", "ignored unless DATASTORE_TYPE=csv loads a file from DATASTORE_PATH, or DATASTORE_TYPE=maxmind")
+	}
+	if c.CSVDelimiter != "" && c.CSVDelimiter != `\t` && (utf8.RuneCountInString(c.CSVDelimiter) != 1 || strings.ContainsAny(c.CSVDelimiter, "\"\r\n\uFFFD")) {
+		fatal("CSV_DELIMITER", "must be a single character other than a quote or newline, or \\t for a tab, got %q", c.CSVDelimiter)
+	}
+	if ((c.CSVDelimiter != "" && c.CSVDelimiter != ",") || c.CSVLazyQuotes) && (c.DatastoreType != "csv" || c.DatastorePath == ":embedded:") {
+		warn("CSV_DELIMITER", "ignored, like CSV_LAZY_QUOTES, unless DATASTORE_TYPE=csv loads a file from DATASTORE_PATH")
+	}
+
+	if c.GossipBindAddr != "" && c.DatastoreType != "csv" {
+		fatal("GOSSIP_BIND_ADDR", "requires DATASTORE_TYPE=csv, the only local store that accepts writes")
+	}
+	if c.GossipBindAddr == "" && len(c.GossipPeers) > 0 {
+		warn("GOSSIP_PEERS", "ignored without GOSSIP_BIND_ADDR")
+	}
+	if len(c.RoutingNodes) > 0 && !slices.Contains(c.RoutingNodes, c.NodeID) {
+		warn("ROUTING_NODES", "doesn't include this instance's NODE_ID, so no IP is routed to it")
+	}
+
+	if c.RateLimitType == "sliding" && c.RateLimitBurst > 0 {
+		warn("RATE_LIMIT_BURST", "ignored with RATE_LIMITER_TYPE=sliding, which allows no burst above RATE_LIMIT")
+	}
+	if c.RateLimitType == "redis" {
+		if c.RedisAddr == "" {
+			fatal("REDIS_ADDR", "required with RATE_LIMITER_TYPE=redis")
+		}
+		if c.DatastoreType != "redis" {
+			warn("RATE_LIMITER_TYPE", "redis rate limiting with DATASTORE_TYPE=%s opens a Redis connection just for the limiter", c.DatastoreType)
+		}
+	}
+
+	if c.PrefetchAdjacent < 0 || c.PrefetchAdjacent > 255 {
+		fatal("PREFETCH_ADJACENT", "must be from 0 (disabled) to 255, got %d", c.PrefetchAdjacent)
+	}
+
+	if c.AnalyticsSampleSize < 0 {
+		fatal("ANALYTICS_SAMPLE_SIZE", "must be 0 (disabled) or positive, got %d", c.AnalyticsSampleSize)
+	}
+	if c.AnalyticsSampleRedisKey != "" {
+		if c.AnalyticsSampleSize == 0 {
+			warn("ANALYTICS_SAMPLE_REDIS_KEY", "ignored with ANALYTICS_SAMPLE_SIZE=0")
+		} else if c.AnalyticsSampleFlushSeconds <= 0 {
+			fatal("ANALYTICS_SAMPLE_FLUSH_SECONDS", "must be positive, got %d", c.AnalyticsSampleFlushSeconds)
+		}
+	}
+
+	if c.DebugLogRing && c.DebugLogRingSize < 0 {
+		fatal("DEBUG_LOG_RING_SIZE", "must be 0 (default of 1000) or positive, got %d", c.DebugLogRingSize)
+	}
+
+	if c.DebugSpans {
+		if c.DebugSpansSize < 0 {
+			fatal("DEBUG_SPANS_SIZE", "must be 0 (default of 1000) or positive, got %d", c.DebugSpansSize)
+		}
+		if c.DatastoreType != "csv" {
+			warn("DEBUG_SPANS", "only range mode CSV files have ranges; with DATASTORE_TYPE=%s every lookup is recorded without one", c.DatastoreType)
+		}
+	}
+
+	if c.EnrichmentEnabled && c.EnrichmentTimeoutMS <= 0 {
+		fatal("ENRICHMENT_TIMEOUT_MS", "must be positive, got %d", c.EnrichmentTimeoutMS)
+	}
+
+	if c.BatchLookupMaxSize < 0 {
+		fatal("BATCH_LOOKUP_MAX_SIZE", "must be 0 (default of 500) or positive, got %d", c.BatchLookupMaxSize)
+	}
+	if c.BatchLookupWorkers < 0 {
+		fatal("BATCH_LOOKUP_WORKERS", "must be 0 (default of 16) or positive, got %d", c.BatchLookupWorkers)
+	}
+	if c.AdminMaxPageSize < 0 {
+		fatal("ADMIN_MAX_PAGE_SIZE", "must be 0 (default of 1000) or positive, got %d", c.AdminMaxPageSize)
+	}
+
+	if c.SkipPrivateIPs && c.PrivateIPCountry == "" {
+		fatal("PRIVATE_IP_COUNTRY", "required with SKIP_PRIVATE_IPS=true")
+	}
+
+	if c.BlocklistFile != "" || c.BlocklistRedisKey != "" {
+		if c.BlocklistFile != "" && c.BlocklistRedisKey != "" {
+			fatal("BLOCKLIST_FILE", "set either BLOCKLIST_FILE or BLOCKLIST_REDIS_KEY, not both")
+		}
+		if c.BlocklistRefreshSeconds <= 0 {
+			fatal("BLOCKLIST_REFRESH_SECONDS", "must be positive, got %d", c.BlocklistRefreshSeconds)
+		}
+	}
+
+	return errs
+}
+
+// HasFatal reports whether any of errs is fatal
+func HasFatal(errs []ConfigError) bool {
+	for _, err := range errs {
+		if err.Fatal {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package config
+
+import "testing"
+
+// validConfig returns a config Validate accepts without errors or warnings
+func validConfig() *Config {
+	return &Config{
+		Port:            "3000",
+		RateLimitType:   "memory",
+		RateLimit:       10,
+		RateLimitWindow: 1,
+		DatastoreType:   "csv",
+		RedisAddr:       "localhost:6379",
+	}
+}
+
+// TestValidate_Valid tests that a valid config produces no errors, whatever the datastore
+func TestValidate_Valid(t *testing.T) {
+	configs := map[string]func(c *Config){
+		"csv":   func(c *Config) {},
+		"redis": func(c *Config) { c.DatastoreType = "redis" },
+		"redis cluster": func(c *Config) {
+			c.DatastoreType = "redis"
+			c.RedisAddr = ""
+			c.RedisClusterAddrs = []string{"redis-1:6379"}
+		},
+		"mysql":           func(c *Config) { c.DatastoreType = "mysql"; c.MySQLDSN = "root@tcp(localhost:3306)/ip2country" },
+		"sliding limiter": func(c *Config) { c.RateLimitType = "sliding"; c.RateLimitWindow = 60 },
+		"redis limiter":   func(c *Config) { c.DatastoreType = "redis"; c.RateLimitType = "redis" },
+		"highest port":    func(c *Config) { c.Port = "65535" },
+		"blocklist":       func(c *Config) { c.BlocklistFile = "blocklist.txt"; c.BlocklistRefreshSeconds = 300 },
+		"watched csv":     func(c *Config) { c.DatastorePath = "data/ip2country.csv.gz"; c.DatastoreWatch = true },
+		"watched maxmind": func(c *Config) { c.DatastoreType = "maxmind"; c.DatastoreWatch = true },
+		"adaptive rate limit": func(c *Config) {
+			c.AdaptiveRateLimit = true
+			c.AdaptiveHighWatermark, c.AdaptiveLowWatermark, c.AdaptiveThrottleFactor = 0.8, 0.5, 0.5
+		},
+		"IP sampling": func(c *Config) {
+			c.AnalyticsSampleSize, c.AnalyticsSampleRedisKey, c.AnalyticsSampleFlushSeconds = 1000, "analytics:sample", 300
+		},
+		"rate limit exempt paths": func(c *Config) { c.RateLimitExemptPaths = []string{"/health", "/metrics", "/admin/"} },
+		"gossip":                  func(c *Config) { c.GossipBindAddr = "0.0.0.0:7946"; c.GossipPeers = []string{"edge-1:7946"} },
+		"routing nodes":           func(c *Config) { c.NodeID = "instance-1"; c.RoutingNodes = []string{"instance-1", "instance-2"} },
+	}
+
+	for name, modify := range configs {
+		t.Run(name, func(t *testing.T) {
+			c := validConfig()
+			modify(c)
+			if errs := Validate(c); len(errs) != 0 {
+				t.Errorf("expected no errors, got %v", errs)
+			}
+		})
+	}
+}
+
+// TestValidate_Rules tests that each rule reports its field, and whether it's fatal
+func TestValidate_Rules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		field  string
+		fatal  bool
+	}{
+		{"redis without addr", func(c *Config) { c.DatastoreType = "redis"; c.RedisAddr = "" }, "REDIS_ADDR", true},
+		{"mysql without DSN", func(c *Config) { c.DatastoreType = "mysql" }, "MYSQL_DSN", true},
+		{"postgres without DSN", func(c *Config) { c.DatastoreType = "postgres" }, "POSTGRES_DSN", true},
+		{"zero rate limit", func(c *Config) { c.RateLimit = 0 }, "RATE_LIMIT", true},
+		{"negative rate limit", func(c *Config) { c.RateLimit = -1 }, "RATE_LIMIT", true},
+		{"zero window", func(c *Config) { c.RateLimitWindow = 0 }, "RATE_LIMIT_WINDOW", true},
+		{"relative exempt path", func(c *Config) { c.RateLimitExemptPaths = []string{"/health", "metrics"} }, "RATE_LIMIT_EXEMPT_PATHS", true},
+		{"root exempt path", func(c *Config) { c.RateLimitExemptPaths = []string{"/"} }, "RATE_LIMIT_EXEMPT_PATHS", false},
+		{"port not a number", func(c *Config) { c.Port = "http" }, "PORT", true},
+		{"port zero", func(c *Config) { c.Port = "0" }, "PORT", true},
+		{"port too high", func(c *Config) { c.Port = "65536" }, "PORT", true},
+		{"redis limiter without addr", func(c *Config) { c.RateLimitType = "redis"; c.RedisAddr = "" }, "REDIS_ADDR", true},
+		{"burst with the sliding limiter", func(c *Config) { c.RateLimitType = "sliding"; c.RateLimitBurst = 20 }, "RATE_LIMIT_BURST", false},
+		{"redis limiter with another datastore", func(c *Config) { c.RateLimitType = "redis" }, "RATE_LIMITER_TYPE", false},
+		{"two blocklist sources", func(c *Config) { c.BlocklistFile = "blocklist.txt"; c.BlocklistRedisKey = "blocklist:cidrs" }, "BLOCKLIST_FILE", true},
+		{"blocklist without refresh", func(c *Config) { c.BlocklistFile = "blocklist.txt" }, "BLOCKLIST_REFRESH_SECONDS", true},
+		{"negative cache size", func(c *Config) { c.CacheSize = -1 }, "CACHE_SIZE", true},
+		{"negative cache ttl", func(c *Config) { c.CacheSize = 1000; c.CacheTTLSeconds = -1 }, "CACHE_TTL_SECONDS", true},
+		{"watch without a csv file", func(c *Config) { c.DatastoreType = "sqlite"; c.DatastoreWatch = true }, "DATASTORE_WATCH", false},
+		{"watch the embedded csv", func(c *Config) { c.DatastorePath = ":embedded:"; c.DatastoreWatch = true }, "DATASTORE_WATCH", false},
+		{"multi-character csv delimiter", func(c *Config) { c.CSVDelimiter = "||" }, "CSV_DELIMITER", true},
+		{"quote as csv delimiter", func(c *Config) { c.CSVDelimiter = `"` }, "CSV_DELIMITER", true},
+		{"csv delimiter without a csv file", func(c *Config) { c.DatastoreType = "sqlite"; c.CSVDelimiter = "|" }, "CSV_DELIMITER", false},
+		{"adaptive watermarks reversed", func(c *Config) {
+			c.AdaptiveRateLimit = true
+			c.AdaptiveHighWatermark, c.AdaptiveLowWatermark, c.AdaptiveThrottleFactor = 0.5, 0.8, 0.5
+		}, "ADAPTIVE_LOW_WATERMARK", true},
+		{"adaptive high watermark above 1", func(c *Config) {
+			c.AdaptiveRateLimit = true
+			c.AdaptiveHighWatermark, c.AdaptiveLowWatermark, c.AdaptiveThrottleFactor = 80, 0.5, 0.5
+		}, "ADAPTIVE_HIGH_WATERMARK", true},
+		{"adaptive throttle factor zero", func(c *Config) {
+			c.AdaptiveRateLimit = true
+			c.AdaptiveHighWatermark, c.AdaptiveLowWatermark = 0.8, 0.5
+		}, "ADAPTIVE_THROTTLE_FACTOR", true},
+		{"negative IP data TTL", func(c *Config) { c.IPDataTTLHours = -1 }, "IP_DATA_TTL_HOURS", true},
+		{"negative store compare interval", func(c *Config) { c.StoreCompareIntervalHours = -1 }, "STORE_COMPARE_INTERVAL_HOURS", true},
+		{"negative postgres max conns", func(c *Config) { c.PGMaxConns = -1 }, "PG_MAX_CONNS", true},
+		{"negative postgres idle time", func(c *Config) { c.PGMaxConnIdleSeconds = -1 }, "PG_MAX_CONN_IDLE_SECONDS", true},
+		{"negative postgres health check period", func(c *Config) { c.PGHealthCheckPeriodSeconds = -1 }, "PG_HEALTH_CHECK_PERIOD_SECONDS", true},
+		{"negative store drift alert threshold", func(c *Config) { c.StoreDriftAlertThreshold = -1 }, "STORE_DRIFT_ALERT_THRESHOLD", true},
+		{"negative prefetch", func(c *Config) { c.PrefetchAdjacent = -1 }, "PREFETCH_ADJACENT", true},
+		{"prefetch beyond the /24", func(c *Config) { c.PrefetchAdjacent = 256 }, "PREFETCH_ADJACENT", true},
+		{"negative sample size", func(c *Config) { c.AnalyticsSampleSize = -1 }, "ANALYTICS_SAMPLE_SIZE", true},
+		{"sample flush interval zero", func(c *Config) {
+			c.AnalyticsSampleSize, c.AnalyticsSampleRedisKey, c.AnalyticsSampleFlushSeconds = 1000, "analytics:sample", 0
+		}, "ANALYTICS_SAMPLE_FLUSH_SECONDS", true},
+		{"sample key without sampling", func(c *Config) { c.AnalyticsSampleRedisKey = "analytics:sample" }, "ANALYTICS_SAMPLE_REDIS_KEY", false},
+		{"negative log ring size", func(c *Config) { c.DebugLogRing = true; c.DebugLogRingSize = -1 }, "DEBUG_LOG_RING_SIZE", true},
+		{"negative spans size", func(c *Config) { c.DebugSpans = true; c.DebugSpansSize = -1 }, "DEBUG_SPANS_SIZE", true},
+		{"spans without ranges", func(c *Config) { c.DebugSpans = true; c.DatastoreType = "redis" }, "DEBUG_SPANS", false},
+		{"enrichment without a timeout", func(c *Config) { c.EnrichmentEnabled = true }, "ENRICHMENT_TIMEOUT_MS", true},
+		{"negative batch size", func(c *Config) { c.BatchLookupMaxSize = -1 }, "BATCH_LOOKUP_MAX_SIZE", true},
+		{"negative batch workers", func(c *Config) { c.BatchLookupWorkers = -1 }, "BATCH_LOOKUP_WORKERS", true},
+		{"negative admin page size", func(c *Config) { c.AdminMaxPageSize = -1 }, "ADMIN_MAX_PAGE_SIZE", true},
+		{"private IPs without country", func(c *Config) { c.SkipPrivateIPs = true; c.PrivateIPCountry = "" }, "PRIVATE_IP_COUNTRY", true},
+		{"gossip with a read-only datastore", func(c *Config) { c.DatastoreType = "sqlite"; c.GossipBindAddr = "0.0.0.0:7946" }, "GOSSIP_BIND_ADDR", true},
+		{"gossip peers without bind addr", func(c *Config) { c.GossipPeers = []string{"edge-1:7946"} }, "GOSSIP_PEERS", false},
+		{"routing nodes without this node", func(c *Config) { c.NodeID = "instance-9"; c.RoutingNodes = []string{"instance-1"} }, "ROUTING_NODES", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.modify(c)
+
+			errs := Validate(c)
+			found := false
+			for _, err := range errs {
+				if err.Field == tt.field {
+					found = true
+					if err.Fatal != tt.fatal {
+						t.Errorf("expected fatal=%v, got %v", tt.fatal, err.Fatal)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("expected an error for %s, got %v", tt.field, errs)
+			}
+			if HasFatal(errs) != tt.fatal {
+				t.Errorf("expected HasFatal=%v for %v", tt.fatal, errs)
+			}
+		})
+	}
+}
+
+// TestValidate_ReportsEveryError tests that all problems are returned at once, not just the first
+func TestValidate_ReportsEveryError(t *testing.T) {
+	c := validConfig()
+	c.Port = ""
+	c.RateLimit = 0
+	c.RateLimitWindow = 0
+
+	if errs := Validate(c); len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %v", errs)
+	}
+}
+
+// TestConfigError_Error tests the error format
+func TestConfigError_Error(t *testing.T) {
+	err := ConfigError{Field: "PORT", Message: "must be a number"}
+	if got := err.Error(); got != "PORT: must be a number" {
+		t.Errorf("expected 'PORT: must be a number', got %q", got)
+	}
+}
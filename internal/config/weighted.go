@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// WeightedStoreEntry is one backend in the DATASTORE_TYPE=weighted config file
+type WeightedStoreEntry struct {
+	Type   string `yaml:"type"`   // "sqlite", "csv", "mysql", "postgres", "redis", or "maxmind"
+	Weight int    `yaml:"weight"` // Relative share of reads
+}
+
+// WeightedStoreFile is the YAML file read for DATASTORE_TYPE=weighted
+//
+// Example:
+//
+//	stores:
+//	  - type: redis
+//	    weight: 90
+//	  - type: mysql
+//	    weight: 10
+type WeightedStoreFile struct {
+	Stores []WeightedStoreEntry `yaml:"stores"`
+}
+
+// LoadWeightedStoreFile reads and validates the weighted store config at path
+// Each store is configured by the usual environment variables for its type
+func LoadWeightedStoreFile(path string) (*WeightedStoreFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weighted store config: %w", err)
+	}
+
+	var file WeightedStoreFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse weighted store config: %w", err)
+	}
+
+	if len(file.Stores) == 0 {
+		return nil, fmt.Errorf("weighted store config %s lists no stores", path)
+	}
+	for i, entry := range file.Stores {
+		if entry.Type == "" || entry.Type == "weighted" {
+			return nil, fmt.Errorf("weighted store config: store %d has invalid type %q", i, entry.Type)
+		}
+	}
+
+	return &file, nil
+}
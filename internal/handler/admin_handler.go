@@ -0,0 +1,660 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/analytics"
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/middleware"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// AdminHandler handles operator-facing HTTP endpoints under /admin
+// These endpoints manage the running service rather than serving lookups
+type AdminHandler struct {
+	config *config.ReloadableConfig
+
+	// rateLimiter is the per-IP limiter whose state ResetRateLimit clears
+	rateLimiter limiter.Limiter
+
+	// uniqueIPs holds the HyperLogLogs written by UniqueIPMiddleware (nil = tracking disabled)
+	uniqueIPs *redis.Client
+
+	// sampler holds the random sample of looked-up IPs served by Sample (nil = sampling disabled)
+	sampler *analytics.Sampler
+
+	// tokens issues one-time API tokens (nil = disposable tokens disabled)
+	tokens *limiter.DisposableTokenLimiter
+
+	// store is the datastore DeleteIPs removes records from (nil or not a store.BulkDeleter = unsupported),
+	// ListIPs pages through (nil or not both a store.Searcher and a store.Counter = unsupported)
+	// and ImportCSV loads into (nil or neither a store.BulkLoader nor a store.StreamLoader = unsupported)
+	store store.Store
+
+	// imports tracks the latest ImportCSV for GET /admin/import/progress
+	imports importTracker
+}
+
+// maxDisposableTokenTTL caps the lifetime of a one-time token
+const maxDisposableTokenTTL = 24 * time.Hour
+
+// IssueTokenRequest is the (optional) request body of POST /admin/token
+type IssueTokenRequest struct {
+	TTLSeconds int `json:"ttl_seconds" example:"300"` // Token lifetime (0 = DISPOSABLE_TOKEN_TTL_SECONDS)
+}
+
+// IssueTokenResponse is the response body of POST /admin/token
+type IssueTokenResponse struct {
+	Token     string    `json:"token"` // Send as X-API-Key, accepted for one request
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// maxDeleteIPsBodySize caps the DELETE /admin/ips body (tens of thousands of IPs)
+const maxDeleteIPsBodySize = 1 << 20
+
+// DeleteIPsRequest is the request body of DELETE /admin/ips
+type DeleteIPsRequest struct {
+	IPs []string `json:"ips" example:"1.2.3.4,5.6.7.8"`
+}
+
+// DeleteIPsResponse is the response body of DELETE /admin/ips
+type DeleteIPsResponse struct {
+	Deleted  int `json:"deleted"`   // IPs removed from the store
+	NotFound int `json:"not_found"` // IPs the store didn't hold
+}
+
+// defaultAdminPageSize is the GET /admin/ips page size when page_size is unset
+const defaultAdminPageSize = 100
+
+// defaultAdminMaxPageSize caps page_size on GET /admin/ips when ADMIN_MAX_PAGE_SIZE is unset
+const defaultAdminMaxPageSize = 1000
+
+// ListIPsResponse is the response body of GET /admin/ips
+type ListIPsResponse struct {
+	Data       []models.IPLocationWithIP `json:"data"`                      // Records of this page, ordered by IP
+	Total      int                       `json:"total" example:"12345"`     // Records in the store
+	Page       int                       `json:"page" example:"1"`          // This page's number, from 1
+	PageSize   int                       `json:"page_size" example:"100"`   // Records per page
+	TotalPages int                       `json:"total_pages" example:"124"` // Pages of page_size records
+}
+
+// defaultMaxImportSize caps the POST /admin/import body when MAX_IMPORT_SIZE_BYTES is unset
+const defaultMaxImportSize = 1 << 30
+
+// ImportResponse is the response body of POST /admin/import
+type ImportResponse struct {
+	Imported int `json:"imported"` // Rows written to the store
+}
+
+// ImportProgress is the state of the latest POST /admin/import, streamed by GET /admin/import/progress
+type ImportProgress struct {
+	Running   bool      `json:"running"`
+	Rows      int       `json:"rows"`    // Rows written so far
+	Batches   int       `json:"batches"` // Batches written so far (1000 rows each, except the last)
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"` // Zero if no import has run since startup
+}
+
+// importTracker holds the ImportProgress of the latest import and notifies watchers of changes
+// The zero value is ready to use
+type importTracker struct {
+	mu       sync.Mutex
+	progress ImportProgress
+	changed  chan struct{} // Closed (and replaced) on every update
+}
+
+// start marks a new import as running, or returns false if one already is
+func (t *importTracker) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.progress.Running {
+		return false
+	}
+	t.progress = ImportProgress{Running: true, StartedAt: time.Now().UTC()}
+	t.notify()
+	return true
+}
+
+// batch records that a batch was written, bringing the total to rows
+func (t *importTracker) batch(rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Rows = rows
+	t.progress.Batches++
+	t.notify()
+}
+
+// finish marks the running import as done
+func (t *importTracker) finish(rows int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Running = false
+	t.progress.Rows = rows
+	if err != nil {
+		t.progress.Error = err.Error()
+	}
+	t.notify()
+}
+
+// notify wakes up the watchers of the previous state. Must be called with mu held
+func (t *importTracker) notify() {
+	if t.changed != nil {
+		close(t.changed)
+	}
+	t.changed = make(chan struct{})
+}
+
+// snapshot returns the current progress and a channel closed on its next change
+func (t *importTracker) snapshot() (ImportProgress, <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.changed == nil {
+		t.changed = make(chan struct{})
+	}
+	return t.progress, t.changed
+}
+
+// UniqueIPsResponse is the response body of GET /admin/analytics/unique-ips
+type UniqueIPsResponse struct {
+	Date      string `json:"date"`
+	UniqueIPs int64  `json:"unique_ips"` // Approximate (HyperLogLog, ~0.81% standard error)
+}
+
+// SampleResponse is the response body of GET /admin/analytics/sample
+type SampleResponse struct {
+	Size    int                `json:"size" example:"1000"`   // Maximum number of IPs sampled
+	Seen    uint64             `json:"seen" example:"250000"` // IPs offered to the sample since startup
+	Samples []analytics.Sample `json:"samples"`
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(cfg *config.ReloadableConfig) *AdminHandler {
+	return &AdminHandler{
+		config: cfg,
+	}
+}
+
+// SetRateLimiter sets the limiter reset by DELETE /admin/rate-limit/{ip}
+func (h *AdminHandler) SetRateLimiter(rateLimiter limiter.Limiter) {
+	h.rateLimiter = rateLimiter
+}
+
+// SetUniqueIPsClient enables the unique IP analytics endpoint
+func (h *AdminHandler) SetUniqueIPsClient(client *redis.Client) {
+	h.uniqueIPs = client
+}
+
+// SetSampler enables GET /admin/analytics/sample, serving sampler's current sample
+func (h *AdminHandler) SetSampler(sampler *analytics.Sampler) {
+	h.sampler = sampler
+}
+
+// SetTokenLimiter enables POST /admin/token
+func (h *AdminHandler) SetTokenLimiter(tokens *limiter.DisposableTokenLimiter) {
+	h.tokens = tokens
+}
+
+// SetStore sets the datastore DELETE /admin/ips removes records from and POST /admin/import loads into
+func (h *AdminHandler) SetStore(s store.Store) {
+	h.store = s
+}
+
+// GetConfig handles GET /admin/config
+// @Summary      Current configuration
+// @Description  Return the configuration currently in effect. Secrets (MySQL DSN, Redis password) are masked
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object}   config.Config
+// @Router       /admin/config [get]
+func (h *AdminHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.config.Get().Sanitized())
+}
+
+// ReloadConfig handles POST /admin/config/reload
+// @Summary      Reload configuration
+// @Description  Re-read environment variables and the .env file without restarting. Returns the new (masked) configuration
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object}   config.Config
+// @Failure      500  {object}   models.ErrorResponse  "Reload failed"
+// @Router       /admin/config/reload [post]
+func (h *AdminHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := h.config.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to reload configuration")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.config.Get().Sanitized())
+}
+
+// ResetRateLimit handles DELETE /admin/rate-limit/{ip}
+// @Summary      Reset an IP's rate limit
+// @Description  Clear the rate limit state of an IP so its next request starts with a full allowance. Requires the X-API-Key header matching ADMIN_API_KEY
+// @Tags         Admin
+// @Produce      json
+// @Param        ip   path      string  true  "IP address"
+// @Success      200  {object}   map[string]string
+// @Failure      400  {object}   models.ErrorResponse  "Invalid IP"
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Failure      500  {object}   models.ErrorResponse  "Reset failed"
+// @Router       /admin/rate-limit/{ip} [delete]
+func (h *AdminHandler) ResetRateLimit(w http.ResponseWriter, r *http.Request) {
+	ip := chi.URLParam(r, "ip")
+	if net.ParseIP(ip) == nil {
+		writeError(w, http.StatusBadRequest, "Invalid IP address format")
+		return
+	}
+
+	if h.rateLimiter == nil {
+		writeError(w, http.StatusInternalServerError, "Rate limiter not configured")
+		return
+	}
+
+	if err := h.rateLimiter.Reset(ip); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to reset rate limit")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"ip": ip, "status": "reset"})
+}
+
+// maxRateLimitsListed caps the IPs returned by ListRateLimits
+const maxRateLimitsListed = 1000
+
+// ListRateLimits handles GET /admin/rate-limits
+// @Summary      List rate limit state
+// @Description  Return the rate limit state of every IP the per-IP limiter is tracking, keyed by IP: tokens remaining, last request, and whether the next request would be allowed. Capped at the 1000 most recently active IPs. Requires the X-API-Key header matching ADMIN_API_KEY
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object}   map[string]ratelimit.RateLimitStatus
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Failure      500  {object}   models.ErrorResponse  "Listing failed"
+// @Router       /admin/rate-limits [get]
+func (h *AdminHandler) ListRateLimits(w http.ResponseWriter, r *http.Request) {
+	if h.rateLimiter == nil {
+		writeError(w, http.StatusInternalServerError, "Rate limiter not configured")
+		return
+	}
+
+	statuses, err := h.rateLimiter.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list rate limits")
+		return
+	}
+
+	if len(statuses) > maxRateLimitsListed {
+		recent := slices.SortedFunc(maps.Values(statuses), func(a, b limiter.RateLimitStatus) int {
+			return b.LastAccessedAt.Compare(a.LastAccessedAt)
+		})
+		statuses = make(map[string]limiter.RateLimitStatus, maxRateLimitsListed)
+		for _, status := range recent[:maxRateLimitsListed] {
+			statuses[status.IP] = status
+		}
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// ListIPs handles GET /admin/ips?page=<page>&page_size=<page_size>
+// @Summary      List IP records
+// @Description  Page through every record in the datastore, ordered by IP, e.g. for auditing. A page past the last one has no data. Each page is a fresh query, so records written between requests can shift later pages. Requires the X-API-Key header matching ADMIN_API_KEY
+// @Tags         Admin
+// @Produce      json
+// @Param        page       query      int  false  "Page number (default 1)"  example(1)
+// @Param        page_size  query      int  false  "Records per page (1-ADMIN_MAX_PAGE_SIZE, default 100)"  example(100)
+// @Success      200  {object}   ListIPsResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid page or page_size"
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Failure      500  {object}   models.ErrorResponse  "Listing failed"
+// @Failure      501  {object}   models.ErrorResponse  "Store does not support listing"
+// @Router       /admin/ips [get]
+func (h *AdminHandler) ListIPs(w http.ResponseWriter, r *http.Request) {
+	searcher, searches := h.store.(store.Searcher)
+	counter, counts := h.store.(store.Counter)
+	if !searches || !counts {
+		writeError(w, http.StatusNotImplemented, "Listing IPs is not supported by this store")
+		return
+	}
+
+	maxPageSize := h.config.Get().AdminMaxPageSize
+	if maxPageSize <= 0 {
+		maxPageSize = defaultAdminMaxPageSize
+	}
+	page, pageSize := 1, min(defaultAdminPageSize, maxPageSize)
+	q := r.URL.Query()
+	if raw := q.Get("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "'page' must be a positive integer")
+			return
+		}
+		page = n
+	}
+	if raw := q.Get("page_size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("'page_size' must be an integer between 1 and %d", maxPageSize))
+			return
+		}
+		pageSize = n
+	}
+
+	total, err := counter.Count(r.Context())
+	if err != nil {
+		writeListIPsError(w, err)
+		return
+	}
+	resp := ListIPsResponse{
+		Data:       []models.IPLocationWithIP{},
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	}
+
+	// Past the last page there's nothing to search for (and the offset could overflow)
+	if page <= resp.TotalPages {
+		locations, err := searcher.Search(r.Context(), store.StoreQuery{Limit: pageSize, Offset: (page - 1) * pageSize})
+		if err != nil {
+			writeListIPsError(w, err)
+			return
+		}
+		for _, location := range locations {
+			resp.Data = append(resp.Data, models.IPLocationWithIP{IPLocation: *location, IP: location.IP})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeListIPsError responds to a failed Count or Search of ListIPs
+// Wrapper stores implement both but fail when the store they wrap doesn't: that's a 501, like an unwrapped store
+func writeListIPsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pkerr.ErrNotSupported):
+		writeError(w, http.StatusNotImplemented, "Listing IPs is not supported by this store")
+	default:
+		writeError(w, http.StatusInternalServerError, "Failed to list IPs")
+	}
+}
+
+// DeleteIPs handles DELETE /admin/ips
+// @Summary      Delete IP records
+// @Description  Remove the records of the given IPs from the datastore, e.g. for GDPR erasure requests. Duplicate IPs are counted once. Requires the X-API-Key header matching ADMIN_API_KEY
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request  body      DeleteIPsRequest  true  "IPs to delete"
+// @Success      200  {object}   DeleteIPsResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid request body or IP"
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Failure      413  {object}   models.ErrorResponse  "Request body too large"
+// @Failure      500  {object}   models.ErrorResponse  "Delete failed"
+// @Failure      501  {object}   models.ErrorResponse  "Store does not support deletes"
+// @Router       /admin/ips [delete]
+func (h *AdminHandler) DeleteIPs(w http.ResponseWriter, r *http.Request) {
+	deleter, ok := h.store.(store.BulkDeleter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Deleting IPs is not supported by this store")
+		return
+	}
+
+	var req DeleteIPsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDeleteIPsBodySize)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate everything before deleting anything, and count duplicates once
+	seen := make(map[string]struct{}, len(req.IPs))
+	ips := make([]string, 0, len(req.IPs))
+	for _, ip := range req.IPs {
+		if net.ParseIP(ip) == nil {
+			writeError(w, http.StatusBadRequest, "Invalid IP address format: "+ip)
+			return
+		}
+		if _, dup := seen[ip]; !dup {
+			seen[ip] = struct{}{}
+			ips = append(ips, ip)
+		}
+	}
+
+	if len(ips) == 0 {
+		writeJSON(w, http.StatusOK, DeleteIPsResponse{})
+		return
+	}
+
+	deleted, err := deleter.BulkDelete(r.Context(), ips)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete IPs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DeleteIPsResponse{Deleted: deleted, NotFound: len(ips) - deleted})
+}
+
+// ImportCSV handles POST /admin/import
+// @Summary      Import a CSV
+// @Description  Load an ip,city,country CSV (first row is a header) into the datastore. The body is parsed as it arrives and written in batches of 1000 rows, so files of any size (up to MAX_IMPORT_SIZE_BYTES) are never held in memory. Rows written before a failure are kept. One import runs at a time. Requires the X-API-Key header matching ADMIN_API_KEY
+// @Tags         Admin
+// @Accept       text/csv
+// @Produce      json
+// @Success      200  {object}   ImportResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid CSV"
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Failure      409  {object}   models.ErrorResponse  "Another import is running"
+// @Failure      413  {object}   models.ErrorResponse  "CSV larger than MAX_IMPORT_SIZE_BYTES"
+// @Failure      500  {object}   models.ErrorResponse  "Import failed"
+// @Failure      501  {object}   models.ErrorResponse  "Store does not support imports"
+// @Router       /admin/import [post]
+func (h *AdminHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	_, streams := h.store.(store.StreamLoader)
+	_, loads := h.store.(store.BulkLoader)
+	if !streams && !loads {
+		writeError(w, http.StatusNotImplemented, "Importing is not supported by this store")
+		return
+	}
+
+	if !h.imports.start() {
+		writeError(w, http.StatusConflict, "Another import is already running")
+		return
+	}
+
+	maxSize := h.config.Get().MaxImportSizeBytes
+	if maxSize <= 0 {
+		maxSize = defaultMaxImportSize
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxSize))
+
+	ctx := store.WithLoadProgress(r.Context(), h.imports.batch)
+	rows, err := store.BulkLoadFromReader(ctx, h.store, r.Body)
+	h.imports.finish(rows, err)
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		case errors.As(err, &parseErr) || errors.Is(err, store.ErrEmptyCSV):
+			writeError(w, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+		case errors.Is(err, context.Canceled):
+			// The client went away - there's nobody to answer
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to import CSV")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ImportResponse{Imported: rows})
+}
+
+// ImportProgressEvents handles GET /admin/import/progress
+// @Summary      Import progress
+// @Description  Server-sent events with the ImportProgress of the latest POST /admin/import: one event now, then one per written batch. The stream ends once the import is no longer running
+// @Tags         Admin
+// @Produce      text/event-stream
+// @Success      200  {object}   ImportProgress
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Router       /admin/import/progress [get]
+func (h *AdminHandler) ImportProgressEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		progress, changed := h.imports.snapshot()
+
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		if !progress.Running {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// UniqueIPs handles GET /admin/analytics/unique-ips?date=2024-01-01
+// @Summary      Unique client IPs
+// @Description  Approximate number of distinct client IPs for a day (YYYY-MM-DD) or month (YYYY-MM). Defaults to today (UTC)
+// @Tags         Admin
+// @Produce      json
+// @Param        date  query     string  false  "Day (2006-01-02) or month (2006-01)"
+// @Success      200  {object}   UniqueIPsResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid date"
+// @Failure      404  {object}   models.ErrorResponse  "Unique IP tracking disabled"
+// @Failure      500  {object}   models.ErrorResponse  "Redis error"
+// @Router       /admin/analytics/unique-ips [get]
+func (h *AdminHandler) UniqueIPs(w http.ResponseWriter, r *http.Request) {
+	if h.uniqueIPs == nil {
+		writeError(w, http.StatusNotFound, "Unique IP tracking is disabled")
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().UTC().Format(middleware.UniqueIPsDaily)
+	}
+	if !validUniqueIPsDate(date) {
+		writeError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD or YYYY-MM")
+		return
+	}
+
+	count, err := h.uniqueIPs.PFCount(r.Context(), middleware.UniqueIPsKey(date)).Result()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to count unique IPs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UniqueIPsResponse{Date: date, UniqueIPs: count})
+}
+
+// Sample handles GET /admin/analytics/sample
+// @Summary      Random sample of looked-up IPs
+// @Description  A uniform random sample (reservoir sampling) of the distinct IPs looked up successfully since startup, with their country. For training anomaly detection models
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object}   SampleResponse
+// @Failure      404  {object}   models.ErrorResponse  "Sampling disabled"
+// @Router       /admin/analytics/sample [get]
+func (h *AdminHandler) Sample(w http.ResponseWriter, r *http.Request) {
+	if h.sampler == nil {
+		writeError(w, http.StatusNotFound, "IP sampling is disabled")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SampleResponse{Size: h.sampler.Size(), Seen: h.sampler.Seen(), Samples: h.sampler.GetSample()})
+}
+
+// IssueToken handles POST /admin/token
+// @Summary      Issue a one-time API token
+// @Description  Create a token accepted as X-API-Key for exactly one /admin request before it expires. Requires the admin API key itself, so a one-time token can't issue more tokens
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        request  body      IssueTokenRequest  false  "Token lifetime"
+// @Success      201  {object}   IssueTokenResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid request body or TTL"
+// @Failure      404  {object}   models.ErrorResponse  "Disposable tokens disabled"
+// @Failure      500  {object}   models.ErrorResponse  "Redis error"
+// @Router       /admin/token [post]
+func (h *AdminHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		writeError(w, http.StatusNotFound, "Disposable tokens are disabled")
+		return
+	}
+
+	// The body is optional - an empty body uses the configured default TTL
+	var req IssueTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TTLSeconds == 0 {
+		req.TTLSeconds = h.config.Get().DisposableTokenTTLSeconds
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl <= 0 || ttl > maxDisposableTokenTTL {
+		writeError(w, http.StatusBadRequest, "Invalid ttl_seconds, expected 1 to 86400")
+		return
+	}
+
+	token, err := limiter.NewDisposableToken()
+	if err == nil {
+		err = h.tokens.AddToken(token, ttl)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, IssueTokenResponse{Token: token, ExpiresAt: time.Now().UTC().Add(ttl)})
+}
+
+// validUniqueIPsDate reports whether date matches one of the unique IP window layouts
+func validUniqueIPsDate(date string) bool {
+	for _, layout := range []string{middleware.UniqueIPsDaily, middleware.UniqueIPsMonthly} {
+		if _, err := time.Parse(layout, date); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,833 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/analytics"
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/middleware"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestAdminHandler_GetConfig tests that the current config is returned with secrets masked
+func TestAdminHandler_GetConfig(t *testing.T) {
+	rc := config.NewReloadableConfig(&config.Config{
+		Port:          "3000",
+		RateLimit:     10,
+		MySQLDSN:      "root:secret@tcp(localhost:3306)/ip2country",
+		RedisPassword: "hunter2",
+	})
+	handler := NewAdminHandler(rc)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	rec := httptest.NewRecorder()
+
+	handler.GetConfig(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body config.Config
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if body.RateLimit != 10 {
+		t.Errorf("expected rate limit 10, got %d", body.RateLimit)
+	}
+	if body.MySQLDSN == "root:secret@tcp(localhost:3306)/ip2country" {
+		t.Error("expected MySQLDSN to be masked")
+	}
+	if body.RedisPassword == "hunter2" {
+		t.Error("expected RedisPassword to be masked")
+	}
+}
+
+// TestAdminHandler_ReloadConfig tests that reload picks up new environment values
+func TestAdminHandler_ReloadConfig(t *testing.T) {
+	rc := config.NewReloadableConfig(&config.Config{RateLimit: 1})
+	handler := NewAdminHandler(rc)
+
+	t.Setenv("RATE_LIMIT", "25")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ReloadConfig(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body config.Config
+	json.NewDecoder(rec.Body).Decode(&body)
+
+	if body.RateLimit != 25 {
+		t.Errorf("expected reloaded rate limit 25 in response, got %d", body.RateLimit)
+	}
+	if rc.Get().RateLimit != 25 {
+		t.Errorf("expected reloaded rate limit 25 in config, got %d", rc.Get().RateLimit)
+	}
+}
+
+// setupUniqueIPsHandler creates an admin handler backed by miniredis
+func setupUniqueIPsHandler(t *testing.T) (*AdminHandler, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetUniqueIPsClient(client)
+	return handler, client
+}
+
+// TestAdminHandler_UniqueIPs tests that the count for the requested date is returned
+func TestAdminHandler_UniqueIPs(t *testing.T) {
+	handler, client := setupUniqueIPsHandler(t)
+	client.PFAdd(context.Background(), middleware.UniqueIPsKey("2024-01-01"), "10.0.0.1", "10.0.0.2", "10.0.0.1")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/unique-ips?date=2024-01-01", nil)
+	rec := httptest.NewRecorder()
+
+	handler.UniqueIPs(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body UniqueIPsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Date != "2024-01-01" || body.UniqueIPs != 2 {
+		t.Errorf("expected 2 unique IPs on 2024-01-01, got %+v", body)
+	}
+}
+
+// TestAdminHandler_UniqueIPs_InvalidDate tests that malformed dates are rejected
+func TestAdminHandler_UniqueIPs_InvalidDate(t *testing.T) {
+	handler, _ := setupUniqueIPsHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/unique-ips?date=01/01/2024", nil)
+	rec := httptest.NewRecorder()
+
+	handler.UniqueIPs(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+// TestAdminHandler_UniqueIPs_Disabled tests the endpoint without a Redis client
+func TestAdminHandler_UniqueIPs_Disabled(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/unique-ips", nil)
+	rec := httptest.NewRecorder()
+
+	handler.UniqueIPs(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// TestAdminHandler_Sample tests that the current sample is returned with its size and the IPs seen
+func TestAdminHandler_Sample(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	sampler := analytics.NewSampler(100)
+	sampler.AddSample("8.8.8.8", "United States")
+	sampler.AddSample("1.1.1.1", "Australia")
+	handler.SetSampler(sampler)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/sample", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Sample(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body SampleResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Size != 100 || body.Seen != 2 || len(body.Samples) != 2 || body.Samples[1].IP != "1.1.1.1" {
+		t.Errorf("expected both IPs of a 100 IP sample, got %+v", body)
+	}
+}
+
+// TestAdminHandler_Sample_Disabled tests the endpoint without a sampler
+func TestAdminHandler_Sample_Disabled(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/sample", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Sample(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// resetRateLimit sends DELETE /admin/rate-limit/{ip} through a chi router so the URL param is set
+func resetRateLimit(handler *AdminHandler, ip string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Delete("/admin/rate-limit/{ip}", handler.ResetRateLimit)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/rate-limit/"+ip, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestAdminHandler_ResetRateLimit tests that the limiter is reset for the given IP
+func TestAdminHandler_ResetRateLimit(t *testing.T) {
+	mockLimiter := limiter.NewMockLimiter(true)
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetRateLimiter(mockLimiter)
+
+	rec := resetRateLimit(handler, "10.0.0.1")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if len(mockLimiter.ResetCalls) != 1 || mockLimiter.ResetCalls[0] != "10.0.0.1" {
+		t.Errorf("expected Reset(10.0.0.1), got %v", mockLimiter.ResetCalls)
+	}
+}
+
+// TestAdminHandler_ResetRateLimit_Error tests that limiter errors return 500
+func TestAdminHandler_ResetRateLimit_Error(t *testing.T) {
+	mockLimiter := limiter.NewMockLimiter(true)
+	mockLimiter.ResetError = errors.New("redis: connection refused")
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetRateLimiter(mockLimiter)
+
+	rec := resetRateLimit(handler, "10.0.0.1")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
+
+// TestAdminHandler_ResetRateLimit_InvalidIP tests that malformed IPs are rejected before reaching the limiter
+func TestAdminHandler_ResetRateLimit_InvalidIP(t *testing.T) {
+	mockLimiter := limiter.NewMockLimiter(true)
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetRateLimiter(mockLimiter)
+
+	rec := resetRateLimit(handler, "not-an-ip")
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+	if len(mockLimiter.ResetCalls) != 0 {
+		t.Errorf("expected no Reset calls, got %v", mockLimiter.ResetCalls)
+	}
+}
+
+// TestAdminHandler_ListRateLimits tests that the limiter's state is returned keyed by IP
+func TestAdminHandler_ListRateLimits(t *testing.T) {
+	lastAccess := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockLimiter := limiter.NewMockLimiter(true)
+	mockLimiter.ListResult = map[string]limiter.RateLimitStatus{
+		"10.0.0.1": {IP: "10.0.0.1", TokensRemaining: 4.5, LastAccessedAt: lastAccess, Allowed: true},
+		"10.0.0.2": {IP: "10.0.0.2", TokensRemaining: 0, LastAccessedAt: lastAccess, Allowed: false},
+	}
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetRateLimiter(mockLimiter)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rate-limits", nil)
+	rec := httptest.NewRecorder()
+	handler.ListRateLimits(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var statuses map[string]limiter.RateLimitStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 IPs, got %v", statuses)
+	}
+	if got := statuses["10.0.0.1"]; got.TokensRemaining != 4.5 || !got.Allowed || !got.LastAccessedAt.Equal(lastAccess) {
+		t.Errorf("unexpected status for 10.0.0.1: %+v", got)
+	}
+	if statuses["10.0.0.2"].Allowed {
+		t.Errorf("expected 10.0.0.2 to be denied, got %+v", statuses["10.0.0.2"])
+	}
+}
+
+// TestAdminHandler_ListRateLimits_Capped tests that only the most recently active IPs are returned past the cap
+func TestAdminHandler_ListRateLimits_Capped(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockLimiter := limiter.NewMockLimiter(true)
+	mockLimiter.ListResult = make(map[string]limiter.RateLimitStatus)
+	for i := 0; i < maxRateLimitsListed+500; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		mockLimiter.ListResult[ip] = limiter.RateLimitStatus{IP: ip, LastAccessedAt: start.Add(time.Duration(i) * time.Second)}
+	}
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetRateLimiter(mockLimiter)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rate-limits", nil)
+	rec := httptest.NewRecorder()
+	handler.ListRateLimits(rec, req)
+
+	var statuses map[string]limiter.RateLimitStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(statuses) != maxRateLimitsListed {
+		t.Fatalf("expected %d IPs, got %d", maxRateLimitsListed, len(statuses))
+	}
+	oldestKept := start.Add(500 * time.Second)
+	for ip, status := range statuses {
+		if status.LastAccessedAt.Before(oldestKept) {
+			t.Errorf("expected only the most recent IPs, got %s last accessed at %v", ip, status.LastAccessedAt)
+		}
+	}
+}
+
+// TestAdminHandler_ListRateLimits_Errors tests that a missing limiter or a List failure returns 500
+func TestAdminHandler_ListRateLimits_Errors(t *testing.T) {
+	failing := limiter.NewMockLimiter(true)
+	failing.ListError = errors.New("redis: connection refused")
+
+	tests := map[string]limiter.Limiter{
+		"no limiter":   nil,
+		"List failure": failing,
+	}
+
+	for name, lim := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+			if lim != nil {
+				handler.SetRateLimiter(lim)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/rate-limits", nil)
+			rec := httptest.NewRecorder()
+			handler.ListRateLimits(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("expected status 500, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+// setupTokenHandler creates an admin handler issuing tokens into miniredis
+func setupTokenHandler(t *testing.T) (*AdminHandler, *limiter.DisposableTokenLimiter) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	tokens, err := limiter.NewDisposableTokenLimiter(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to create token limiter: %v", err)
+	}
+	t.Cleanup(func() { tokens.Close() })
+
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{DisposableTokenTTLSeconds: 300}))
+	handler.SetTokenLimiter(tokens)
+	return handler, tokens
+}
+
+// TestAdminHandler_IssueToken tests that an issued token is usable once and expires per the TTL
+func TestAdminHandler_IssueToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantTTL time.Duration
+	}{
+		{name: "default TTL", body: "", wantTTL: 300 * time.Second},
+		{name: "custom TTL", body: `{"ttl_seconds": 60}`, wantTTL: 60 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, tokens := setupTokenHandler(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/token", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.IssueToken(rec, req)
+
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("expected status 201, got %d", rec.Code)
+			}
+
+			var body IssueTokenResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !limiter.IsDisposableToken(body.Token) {
+				t.Errorf("expected a one-time token, got %q", body.Token)
+			}
+			if remaining := time.Until(body.ExpiresAt); remaining <= tt.wantTTL-time.Minute || remaining > tt.wantTTL {
+				t.Errorf("expected expiry in %v, got %v", tt.wantTTL, remaining)
+			}
+
+			if !tokens.Allow(body.Token) {
+				t.Error("expected the issued token to be accepted")
+			}
+			if tokens.Allow(body.Token) {
+				t.Error("expected the issued token to be accepted only once")
+			}
+		})
+	}
+}
+
+// TestAdminHandler_IssueToken_Invalid tests malformed bodies and out of range TTLs
+func TestAdminHandler_IssueToken_Invalid(t *testing.T) {
+	handler, _ := setupTokenHandler(t)
+
+	for _, body := range []string{`{"ttl_seconds": -1}`, `{"ttl_seconds": 86401}`, `not json`} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/token", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.IssueToken(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %q: expected status 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+// TestAdminHandler_IssueToken_Disabled tests the endpoint without a token limiter
+func TestAdminHandler_IssueToken_Disabled(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/token", nil)
+	rec := httptest.NewRecorder()
+	handler.IssueToken(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// readOnlyStore hides every optional interface of the store it wraps, like a store without deletes
+type readOnlyStore struct {
+	store.Store
+}
+
+// newListIPsStore returns a mock store holding the 5 records 10.0.0.1 to 10.0.0.5
+func newListIPsStore() *store.MockStore {
+	s := store.NewEmptyMockStore()
+	for i := 1; i <= 5; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		s.Data[ip] = &models.IPLocation{IP: ip, City: "City", Country: "Country"}
+	}
+	return s
+}
+
+// TestAdminHandler_ListIPs tests the records and page counts of the first, last and a missing page
+func TestAdminHandler_ListIPs(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected ListIPsResponse
+		ips      []string
+	}{
+		{"defaults", "", ListIPsResponse{Total: 5, Page: 1, PageSize: 100, TotalPages: 1}, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}},
+		{"first page", "?page=1&page_size=2", ListIPsResponse{Total: 5, Page: 1, PageSize: 2, TotalPages: 3}, []string{"10.0.0.1", "10.0.0.2"}},
+		{"last page", "?page=3&page_size=2", ListIPsResponse{Total: 5, Page: 3, PageSize: 2, TotalPages: 3}, []string{"10.0.0.5"}},
+		{"beyond the last page", "?page=4&page_size=2", ListIPsResponse{Total: 5, Page: 4, PageSize: 2, TotalPages: 3}, []string{}},
+		{"far beyond the last page", fmt.Sprintf("?page=%d&page_size=1000", math.MaxInt), ListIPsResponse{Total: 5, Page: math.MaxInt, PageSize: 1000, TotalPages: 1}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+			handler.SetStore(newListIPsStore())
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/ips"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handler.ListIPs(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var body ListIPsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Total != tt.expected.Total || body.Page != tt.expected.Page ||
+				body.PageSize != tt.expected.PageSize || body.TotalPages != tt.expected.TotalPages {
+				t.Errorf("expected %+v, got %+v", tt.expected, body)
+			}
+			if body.Data == nil {
+				t.Fatal("expected data to be an array, got null")
+			}
+			ips := make([]string, len(body.Data))
+			for i, location := range body.Data {
+				ips[i] = location.IP
+			}
+			if strings.Join(ips, ",") != strings.Join(tt.ips, ",") {
+				t.Errorf("expected %v, got %v", tt.ips, ips)
+			}
+		})
+	}
+}
+
+// TestAdminHandler_ListIPs_Errors tests rejected parameters, store failures and stores that can't list
+func TestAdminHandler_ListIPs_Errors(t *testing.T) {
+	failing := newListIPsStore()
+	failing.CountError = errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		store       store.Store
+		maxPageSize int
+		query       string
+		status      int
+	}{
+		{"page zero", newListIPsStore(), 0, "?page=0", http.StatusBadRequest},
+		{"page not a number", newListIPsStore(), 0, "?page=first", http.StatusBadRequest},
+		{"page_size zero", newListIPsStore(), 0, "?page_size=0", http.StatusBadRequest},
+		{"page_size above the default maximum", newListIPsStore(), 0, "?page_size=1001", http.StatusBadRequest},
+		{"page_size above ADMIN_MAX_PAGE_SIZE", newListIPsStore(), 10, "?page_size=11", http.StatusBadRequest},
+		{"page_size at ADMIN_MAX_PAGE_SIZE", newListIPsStore(), 10, "?page_size=10", http.StatusOK},
+		{"store error", failing, 0, "", http.StatusInternalServerError},
+		{"unsupported store", readOnlyStore{newListIPsStore()}, 0, "", http.StatusNotImplemented},
+		{"wrapped unsupported store", store.NewStaleStore(readOnlyStore{newListIPsStore()}, 10), 0, "", http.StatusNotImplemented},
+		{"no store", nil, 0, "", http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{AdminMaxPageSize: tt.maxPageSize}))
+			if tt.store != nil {
+				handler.SetStore(tt.store)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/ips"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handler.ListIPs(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestAdminHandler_DeleteIPs tests the deleted and not found counts for various request bodies
+func TestAdminHandler_DeleteIPs(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected DeleteIPsResponse
+		kept     []string
+	}{
+		{"all found", `{"ips":["8.8.8.8","1.1.1.1"]}`, DeleteIPsResponse{Deleted: 2}, nil},
+		{"some not found", `{"ips":["8.8.8.8","9.9.9.9"]}`, DeleteIPsResponse{Deleted: 1, NotFound: 1}, []string{"1.1.1.1"}},
+		{"duplicates counted once", `{"ips":["8.8.8.8","8.8.8.8"]}`, DeleteIPsResponse{Deleted: 1}, []string{"1.1.1.1"}},
+		{"empty list", `{"ips":[]}`, DeleteIPsResponse{}, []string{"8.8.8.8", "1.1.1.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := store.NewMockStore()
+			handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+			handler.SetStore(mockStore)
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/ips", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.DeleteIPs(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var body DeleteIPsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, body)
+			}
+			if len(mockStore.Data) != len(tt.kept) {
+				t.Errorf("expected %d records kept, got %d", len(tt.kept), len(mockStore.Data))
+			}
+			for _, ip := range tt.kept {
+				if _, ok := mockStore.Data[ip]; !ok {
+					t.Errorf("expected %s to be kept", ip)
+				}
+			}
+		})
+	}
+}
+
+// TestAdminHandler_DeleteIPs_Errors tests rejected requests and store failures
+func TestAdminHandler_DeleteIPs_Errors(t *testing.T) {
+	failing := store.NewMockStore()
+	failing.BulkDeleteError = errors.New("connection refused")
+
+	tests := []struct {
+		name   string
+		store  store.Store
+		body   string
+		status int
+	}{
+		{"invalid IP", store.NewMockStore(), `{"ips":["8.8.8.8","not-an-ip"]}`, http.StatusBadRequest},
+		{"invalid body", store.NewMockStore(), `{"ips":`, http.StatusBadRequest},
+		{"body too large", store.NewMockStore(), `{"ips":["` + strings.Repeat("1", maxDeleteIPsBodySize) + `"]}`, http.StatusRequestEntityTooLarge},
+		{"store error", failing, `{"ips":["8.8.8.8"]}`, http.StatusInternalServerError},
+		{"unsupported store", readOnlyStore{store.NewMockStore()}, `{"ips":["8.8.8.8"]}`, http.StatusNotImplemented},
+		{"no store", nil, `{"ips":["8.8.8.8"]}`, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+			if tt.store != nil {
+				handler.SetStore(tt.store)
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/ips", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.DeleteIPs(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+// TestAdminHandler_DeleteIPs_InvalidIPDeletesNothing tests that one bad IP rejects the whole request
+func TestAdminHandler_DeleteIPs_InvalidIPDeletesNothing(t *testing.T) {
+	mockStore := store.NewMockStore()
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetStore(mockStore)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/ips", strings.NewReader(`{"ips":["8.8.8.8","not-an-ip"]}`))
+	rec := httptest.NewRecorder()
+	handler.DeleteIPs(rec, req)
+
+	if _, ok := mockStore.Data["8.8.8.8"]; !ok {
+		t.Error("expected nothing to be deleted when the request has an invalid IP")
+	}
+}
+
+// importCSV sends body to POST /admin/import
+func importCSV(handler *AdminHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	handler.ImportCSV(rec, req)
+	return rec
+}
+
+// TestAdminHandler_ImportCSV tests that the rows are written to the store and counted
+func TestAdminHandler_ImportCSV(t *testing.T) {
+	mockStore := store.NewMockStore()
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetStore(mockStore)
+
+	rec := importCSV(handler, "ip,city,country\n9.9.9.9,Berkeley,United States\n208.67.222.222,San Francisco,United States\n")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body ImportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Imported != 2 {
+		t.Errorf("expected 2 rows imported, got %d", body.Imported)
+	}
+	if location, ok := mockStore.Data["9.9.9.9"]; !ok || location.City != "Berkeley" {
+		t.Errorf("expected 9.9.9.9 to be imported, got %+v", location)
+	}
+
+	progress, _ := handler.imports.snapshot()
+	if progress.Running || progress.Rows != 2 || progress.Batches != 1 {
+		t.Errorf("expected a finished import of 2 rows in 1 batch, got %+v", progress)
+	}
+}
+
+// TestAdminHandler_ImportCSV_Errors tests rejected uploads and store failures
+func TestAdminHandler_ImportCSV_Errors(t *testing.T) {
+	failing := store.NewMockStore()
+	failing.BulkLoadError = errors.New("connection refused")
+	valid := "ip,city,country\n9.9.9.9,Berkeley,United States\n"
+
+	tests := []struct {
+		name    string
+		store   store.Store
+		maxSize int
+		body    string
+		status  int
+	}{
+		{"body too large", store.NewMockStore(), 64, "ip,city,country\n" + strings.Repeat("9.9.9.9,Berkeley,United States\n", 10), http.StatusRequestEntityTooLarge},
+		{"empty body", store.NewMockStore(), 0, "", http.StatusBadRequest},
+		{"malformed CSV", store.NewMockStore(), 0, "ip,city,country\n9.9.9.9,\"Berkeley,United States\n", http.StatusBadRequest},
+		{"store error", failing, 0, valid, http.StatusInternalServerError},
+		{"unsupported store", readOnlyStore{store.NewMockStore()}, 0, valid, http.StatusNotImplemented},
+		{"no store", nil, 0, valid, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{MaxImportSizeBytes: tt.maxSize}))
+			if tt.store != nil {
+				handler.SetStore(tt.store)
+			}
+
+			if rec := importCSV(handler, tt.body); rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if progress, _ := handler.imports.snapshot(); progress.Running {
+				t.Error("expected no import to be left running")
+			}
+		})
+	}
+}
+
+// TestAdminHandler_ImportCSV_Conflict tests that only one import runs at a time
+func TestAdminHandler_ImportCSV_Conflict(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetStore(store.NewMockStore())
+	handler.imports.start()
+
+	if rec := importCSV(handler, "ip,city,country\n"); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+}
+
+// readProgressEvents reads ImportProgress events from a server-sent events stream until it ends
+// Events are also sent on events as they arrive
+func readProgressEvents(t *testing.T, body io.Reader, events chan<- ImportProgress) {
+	t.Helper()
+	defer close(events)
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var progress ImportProgress
+		if err := json.Unmarshal([]byte(data), &progress); err != nil {
+			t.Errorf("failed to decode event %q: %v", data, err)
+			return
+		}
+		events <- progress
+	}
+}
+
+// TestAdminHandler_ImportProgressEvents tests that progress is streamed batch by batch during an upload
+func TestAdminHandler_ImportProgressEvents(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+	handler.SetStore(store.NewMockStore())
+
+	r := chi.NewRouter()
+	r.Post("/admin/import", handler.ImportCSV)
+	r.Get("/admin/import/progress", handler.ImportProgressEvents)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// Upload through a pipe, so the test controls how much of the file exists
+	pr, pw := io.Pipe()
+	uploaded := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(server.URL+"/admin/import", "text/csv", pr)
+		if err != nil {
+			uploaded <- 0
+			return
+		}
+		defer resp.Body.Close()
+		uploaded <- resp.StatusCode
+	}()
+
+	writeRows := func(first, n int) {
+		for i := first; i < first+n; i++ {
+			fmt.Fprintf(pw, "10.0.%d.%d,City,Country\n", byte(i>>8), byte(i))
+		}
+	}
+	io.WriteString(pw, "ip,city,country\n")
+	writeRows(0, 1000)
+
+	resp, err := http.Get(server.URL + "/admin/import/progress")
+	if err != nil {
+		t.Fatalf("failed to open the progress stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected Content-Type text/event-stream, got %s", ct)
+	}
+
+	events := make(chan ImportProgress, 100)
+	go readProgressEvents(t, resp.Body, events)
+
+	// Wait for the first batch, with the rest of the file not yet sent
+	timeout := time.After(5 * time.Second)
+	for waiting := true; waiting; {
+		select {
+		case progress := <-events:
+			waiting = progress.Rows < 1000
+			if waiting && !progress.Running {
+				t.Fatalf("expected a running import, got %+v", progress)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the first batch")
+		}
+	}
+
+	writeRows(1000, 500)
+	pw.Close()
+
+	var last ImportProgress
+	for progress := range events {
+		last = progress
+	}
+	if last.Running || last.Rows != 1500 || last.Batches != 2 || last.Error != "" {
+		t.Errorf("expected a finished import of 1500 rows in 2 batches, got %+v", last)
+	}
+	if status := <-uploaded; status != http.StatusOK {
+		t.Errorf("expected the upload to succeed, got status %d", status)
+	}
+}
+
+// TestAdminHandler_ImportProgressEvents_Idle tests that without a running import the stream ends after one event
+func TestAdminHandler_ImportProgressEvents_Idle(t *testing.T) {
+	handler := NewAdminHandler(config.NewReloadableConfig(&config.Config{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/import/progress", nil)
+	rec := httptest.NewRecorder()
+	handler.ImportProgressEvents(rec, req) // Returns: a running import would block here
+
+	events := make(chan ImportProgress, 10)
+	readProgressEvents(t, rec.Body, events)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if progress := <-events; progress.Running || progress.Rows != 0 {
+		t.Errorf("expected an idle progress, got %+v", progress)
+	}
+}
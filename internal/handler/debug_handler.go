@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// defaultDebugLogLines is how many lines GET /debug/logs returns without an n parameter
+const defaultDebugLogLines = 100
+
+// defaultDebugSpans is how many lookups of each kind GET /debug/spans returns without an n parameter
+const defaultDebugSpans = 50
+
+// DebugLogsHandler serves GET /debug/logs?n=<n>: the last n log lines held by ring, oldest first, as a JSON array
+// Each line is the JSON log event as written. n defaults to 100, and is capped at the ring's size
+func DebugLogsHandler(ring *logger.RingLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, ok := debugCount(w, r, defaultDebugLogLines)
+		if !ok {
+			return
+		}
+
+		// The ring changes with every log line
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, ring.Lines(n))
+	}
+}
+
+// DebugSpansHandler serves GET /debug/spans?n=<n>: the last n lookups answered by a range of the
+// datastore and the last n answered without one, newest first (see store.SpanStore). n defaults to 50
+func DebugSpansHandler(spans *store.SpanStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, ok := debugCount(w, r, defaultDebugSpans)
+		if !ok {
+			return
+		}
+
+		// The rings change with every lookup
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, models.SpansResponse{Spans: spans.Spans(n), NoSpans: spans.NoSpans(n)})
+	}
+}
+
+// debugCount parses the n query parameter of the debug endpoints, defaulting to def
+// On an invalid value it writes a 400 and returns false
+func debugCount(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	raw := r.URL.Query().Get("n")
+	if raw == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		writeError(w, http.StatusBadRequest, "'n' must be a positive integer")
+		return 0, false
+	}
+	return n, true
+}
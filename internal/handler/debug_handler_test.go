@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// TestDebugLogsHandler tests that the last n lines are returned oldest first, and all of them when n is larger than the ring
+func TestDebugLogsHandler(t *testing.T) {
+	ring := logger.NewRingLogger(5)
+	for i := range 7 {
+		fmt.Fprintf(ring, "{\"n\":%d}\n", i)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"?n=2", []string{`{"n":5}`, `{"n":6}`}},
+		{"?n=100", []string{`{"n":2}`, `{"n":3}`, `{"n":4}`, `{"n":5}`, `{"n":6}`}},
+		{"", []string{`{"n":2}`, `{"n":3}`, `{"n":4}`, `{"n":5}`, `{"n":6}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			DebugLogsHandler(ring)(rec, httptest.NewRequest(http.MethodGet, "/debug/logs"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			var lines []string
+			if err := json.NewDecoder(rec.Body).Decode(&lines); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if strings.Join(lines, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, lines)
+			}
+		})
+	}
+}
+
+// TestDebugLogsHandler_InvalidN tests that an n that isn't a positive integer is rejected with 400
+func TestDebugLogsHandler_InvalidN(t *testing.T) {
+	for _, n := range []string{"0", "-1", "ten"} {
+		rec := httptest.NewRecorder()
+		DebugLogsHandler(logger.NewRingLogger(5))(rec, httptest.NewRequest(http.MethodGet, "/debug/logs?n="+n, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("n=%s: expected status 400, got %d", n, rec.Code)
+		}
+	}
+}
+
+// TestDebugSpansHandler tests that the last n lookups of each kind are returned, newest first
+func TestDebugSpansHandler(t *testing.T) {
+	csvStore, err := store.NewCSVStoreFromReader(strings.NewReader("ip_start,ip_end,city,country\n10.0.0.0,10.0.0.255,Aligned,Country A\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	spans := store.NewSpanStore(csvStore, 10)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "192.0.2.1"} {
+		spans.FindByIP(context.Background(), ip)
+	}
+
+	rec := httptest.NewRecorder()
+	DebugSpansHandler(spans)(rec, httptest.NewRequest(http.MethodGet, "/debug/spans?n=1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", got)
+	}
+	var resp models.SpansResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Spans) != 1 || resp.Spans[0].IP != "10.0.0.2" || resp.Spans[0].CIDRs[0] != "10.0.0.0/24" {
+		t.Errorf("expected the 10.0.0.2 span, got %+v", resp.Spans)
+	}
+	if len(resp.NoSpans) != 1 || resp.NoSpans[0].Result != "not_found" {
+		t.Errorf("expected the not_found entry, got %+v", resp.NoSpans)
+	}
+
+	rec = httptest.NewRecorder()
+	DebugSpansHandler(spans)(rec, httptest.NewRequest(http.MethodGet, "/debug/spans?n=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("n=0: expected status 400, got %d", rec.Code)
+	}
+}
@@ -2,10 +2,23 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/evyataryagoni/ip2country/internal/health"
 	"github.com/evyataryagoni/ip2country/internal/models"
 	"github.com/evyataryagoni/ip2country/internal/service"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/geo"
+	"github.com/evyataryagoni/ip2country/pkg/geofence"
 )
 
 // IPHandler handles HTTP requests for IP lookups
@@ -19,24 +32,51 @@ import (
 //   - NO business logic (that's in the service layer)
 type IPHandler struct {
 	service *service.IPService
+
+	// checks are run by Health (none until SetHealthRegistry)
+	checks *health.CheckRegistry
+
+	// maxBatchSize is the most IPs BatchLookup accepts (0 = DefaultMaxBatchSize)
+	maxBatchSize int
+}
+
+// maxFindCountryBodySize caps the POST /v1/find-country body; {"ip":"..."} with the longest IPv6 form fits easily
+const maxFindCountryBodySize = 64
+
+// FindCountryRequest is the request body of POST /v1/find-country
+type FindCountryRequest struct {
+	IP string `json:"ip" example:"8.8.8.8"` // IP address (IPv4 or IPv6)
 }
 
 // NewIPHandler creates a new IP handler with the given service
 func NewIPHandler(service *service.IPService) *IPHandler {
 	return &IPHandler{
 		service: service,
+		checks:  health.NewCheckRegistry(),
 	}
 }
 
+// SetHealthRegistry sets the registry whose checks Health runs
+func (h *IPHandler) SetHealthRegistry(checks *health.CheckRegistry) {
+	h.checks = checks
+}
+
+// SetMaxBatchSize sets the most IPs BatchLookup accepts per request
+// n <= 0 restores DefaultMaxBatchSize
+func (h *IPHandler) SetMaxBatchSize(n int) {
+	h.maxBatchSize = n
+}
+
 // FindCountry handles GET /v1/find-country?ip=<ip>
 // @Summary      Find country by IP address
-// @Description  Look up geographic location (city and country) for a given IP address
+// @Description  Look up geographic location (city and country, with their ISO country code and continent code) for a given IP address. With include_ip=true the response also has an "ip" field (models.IPLocationWithIP)
 // @Tags         IP Lookup
 // @Accept       json
 // @Produce      json
-// @Param        ip   query      string  true  "IP address (IPv4 or IPv6)"  example(8.8.8.8)
+// @Param        ip          query      string  true   "IP address (IPv4 or IPv6)"  format(ip)  example(8.8.8.8)
+// @Param        include_ip  query      bool    false  "Echo the IP address in the response"  default(false)
 // @Success      200  {object}   models.IPLocation
-// @Failure      400  {object}   models.ErrorResponse  "Invalid IP format"
+// @Failure      400  {object}   models.ErrorResponse  "Invalid IP format or include_ip"
 // @Failure      404  {object}   models.ErrorResponse  "IP not found"
 // @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
 // @Failure      500  {object}   models.ErrorResponse  "Internal server error"
@@ -50,13 +90,143 @@ func (h *IPHandler) FindCountry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 2: Call service layer
+	includeIP := false
+	if raw := r.URL.Query().Get("include_ip"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "'include_ip' must be true or false")
+			return
+		}
+		includeIP = parsed
+	}
+
+	h.lookup(w, r, ip, includeIP)
+}
+
+// FindCountryJSON handles POST /v1/find-country with the IP in a JSON body
+// For clients behind proxies that strip query parameters; the response matches the GET variant
+// @Summary      Find country by IP address (JSON body)
+// @Description  Same as GET /v1/find-country, but the IP address is sent as {"ip": "..."} (at most 64 bytes)
+// @Tags         IP Lookup
+// @Accept       json
+// @Produce      json
+// @Param        request  body      FindCountryRequest  true  "IP address to look up"
+// @Success      200  {object}   models.IPLocation
+// @Failure      400  {object}   models.ErrorResponse  "Invalid request body or IP format"
+// @Failure      404  {object}   models.ErrorResponse  "IP not found"
+// @Failure      413  {object}   models.ErrorResponse  "Request body too large"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Router       /v1/find-country [post]
+func (h *IPHandler) FindCountryJSON(w http.ResponseWriter, r *http.Request) {
+	// Read one byte past the limit so an oversized body can be told apart from one that fits exactly
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFindCountryBodySize+1))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(body) > maxFindCountryBodySize {
+		h.respondError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	var req FindCountryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.IP == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'ip' query parameter")
+		return
+	}
+
+	h.lookup(w, r, req.IP, false)
+}
+
+// DefaultMaxBatchSize is the most IPs POST /v1/batch-lookup accepts when no size was set
+const DefaultMaxBatchSize = 500
+
+// batchBodyBytesPerIP bounds the POST /v1/batch-lookup body: the longest IPv6 form, quoted, fits with room
+// for whitespace (the body is capped at maxBatchSize IPs of this size, plus batchBodyOverhead)
+const (
+	batchBodyBytesPerIP = 64
+	batchBodyOverhead   = 64
+)
+
+// BatchLookupRequest is the request body of POST /v1/batch-lookup
+type BatchLookupRequest struct {
+	IPs []string `json:"ips" example:"8.8.8.8,1.1.1.1"` // IP addresses (IPv4 or IPv6); duplicates are looked up once
+}
+
+// BatchLookup handles POST /v1/batch-lookup
+// @Summary      Look up many IPs
+// @Description  Look up the location of up to BATCH_LOOKUP_MAX_SIZE IP addresses (default 500) in one request, e.g. to geolocate a log file. The IPs are looked up concurrently. Each IP is in "results" or, if it's invalid, unknown or its lookup failed, in "errors" with the message GET /v1/find-country would return. The request counts once against the rate limit
+// @Tags         IP Lookup
+// @Accept       json
+// @Produce      json
+// @Param        request  body      BatchLookupRequest  true  "IP addresses to look up"
+// @Success      200  {object}   models.BatchLookupResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid body, no IPs, or more than BATCH_LOOKUP_MAX_SIZE"
+// @Failure      413  {object}   models.ErrorResponse  "Request body too large"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Router       /v1/batch-lookup [post]
+func (h *IPHandler) BatchLookup(w http.ResponseWriter, r *http.Request) {
+	maxBatchSize := h.maxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+
+	// Read one byte past the limit so an oversized body can be told apart from one that fits exactly
+	maxBodySize := int64(maxBatchSize)*batchBodyBytesPerIP + batchBodyOverhead
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if int64(len(body)) > maxBodySize {
+		h.respondError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	var req BatchLookupRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.IPs) == 0 {
+		h.respondError(w, http.StatusBadRequest, "Missing 'ips' field")
+		return
+	}
+	if len(req.IPs) > maxBatchSize {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("At most %d IPs per batch, got %d", maxBatchSize, len(req.IPs)))
+		return
+	}
+
+	locations, errs := h.service.LookupBatch(r.Context(), req.IPs)
+
+	resp := models.BatchLookupResponse{Results: locations, Errors: make(map[string]string, len(errs))}
+	for ip, err := range errs {
+		// The same messages as GET /v1/find-country; other errors could leak store details
+		if errors.Is(err, pkerr.ErrInvalidIP) || errors.Is(err, pkerr.ErrNotFound) {
+			resp.Errors[ip] = err.Error()
+		} else {
+			resp.Errors[ip] = "Internal server error"
+		}
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// lookup resolves ip through the service and writes the find-country response shared by GET and POST
+// With includeIP the response also has the IP address (see models.IPLocationWithIP)
+func (h *IPHandler) lookup(w http.ResponseWriter, r *http.Request, ip string, includeIP bool) {
+	// Call service layer
 	// The service handles validation and data access
-	location, err := h.service.LookupIP(ip)
+	// r's context cancels the store query if the client disconnects
+	location, err := h.service.LookupIP(r.Context(), ip)
 	if err != nil {
-		if err.Error() == "invalid IP address format" {
+		if errors.Is(err, pkerr.ErrInvalidIP) {
 			h.respondError(w, http.StatusBadRequest, err.Error())
-		} else if err.Error() == "IP address not found" {
+		} else if errors.Is(err, pkerr.ErrNotFound) {
 			h.respondError(w, http.StatusNotFound, err.Error())
 		} else {
 			// Any other error is an internal server error
@@ -65,22 +235,389 @@ func (h *IPHandler) FindCountry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 3: Return success response
+	// Stale data is better than an error, but proxies must not keep it around
+	if location.Stale {
+		w.Header().Set(StaleDataHeader, "true")
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	// Return success response
+	if includeIP {
+		h.respondJSON(w, http.StatusOK, models.IPLocationWithIP{IPLocation: *location, IP: ip})
+		return
+	}
 	h.respondJSON(w, http.StatusOK, location)
 }
 
+// Whois handles GET /v1/whois?ip=<ip>
+// @Summary      Aggregated IP information
+// @Description  Return everything the service knows about an IP address: geolocation (with the enrichment sources' timezone and abuse data, when enabled), classification, the network of the datastore range containing it and the classifiers' signals. Sub-lookups that fail, such as an IP the store doesn't know, are listed in "errors"
+// @Tags         IP Lookup
+// @Accept       json
+// @Produce      json
+// @Param        ip   query      string  true  "IP address (IPv4 or IPv6)"  format(ip)  example(8.8.8.8)
+// @Success      200  {object}   models.WhoisResult
+// @Failure      400  {object}   models.ErrorResponse  "Invalid IP format"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Failure      504  {object}   models.ErrorResponse  "Lookup timed out"
+// @Router       /v1/whois [get]
+func (h *IPHandler) Whois(w http.ResponseWriter, r *http.Request) {
+	ip := r.URL.Query().Get("ip")
+
+	if ip == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'ip' query parameter")
+		return
+	}
+
+	result, err := h.service.Whois(r.Context(), ip)
+	if err != nil {
+		switch {
+		case errors.Is(err, pkerr.ErrInvalidIP):
+			h.respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, pkerr.ErrStoreTimeout):
+			h.respondError(w, http.StatusGatewayTimeout, "whois lookup timed out")
+		default:
+			h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// ListCountries handles GET /v1/countries
+// @Summary      List countries
+// @Description  Return every country the IP data covers, sorted alphabetically. Cached for 5 minutes
+// @Tags         IP Lookup
+// @Produce      json
+// @Success      200  {object}   models.CountriesResponse
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Failure      501  {object}   models.ErrorResponse  "The configured store can't list countries"
+// @Router       /v1/countries [get]
+func (h *IPHandler) ListCountries(w http.ResponseWriter, r *http.Request) {
+	countries, err := h.service.ListCountries(r.Context())
+	if err != nil {
+		if errors.Is(err, pkerr.ErrNotSupported) {
+			h.respondError(w, http.StatusNotImplemented, err.Error())
+		} else {
+			h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, models.CountriesResponse{Countries: countries})
+}
+
+// Search limits
+const (
+	defaultSearchLimit   = 10
+	maxSearchLimit       = 100
+	maxSearchFieldLength = 100 // Characters in country or city
+)
+
+// Search handles GET /v1/search?country=<country>&city=<city>&limit=<limit>&offset=<offset>
+// @Summary      Search by location
+// @Description  Return the IPs whose country and city begin with the given values (case-insensitive), ordered by IP. Omitted fields match anything. Page through results with limit and offset
+// @Tags         IP Lookup
+// @Produce      json
+// @Param        country  query      string  false  "Country name prefix (max 100 characters)"  example(United)
+// @Param        city     query      string  false  "City name prefix (max 100 characters)"  example(New)
+// @Param        limit    query      int     false  "Number of results (1-100, default 10)"  example(10)
+// @Param        offset   query      int     false  "Number of results to skip (default 0)"  example(0)
+// @Success      200  {object}   models.SearchResponse
+// @Failure      400  {object}   models.ErrorResponse  "Invalid parameter"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Failure      501  {object}   models.ErrorResponse  "The configured store can't search"
+// @Router       /v1/search [get]
+func (h *IPHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := store.StoreQuery{
+		Country: strings.TrimSpace(q.Get("country")),
+		City:    strings.TrimSpace(q.Get("city")),
+		Limit:   defaultSearchLimit,
+	}
+	if utf8.RuneCountInString(query.Country) > maxSearchFieldLength || utf8.RuneCountInString(query.City) > maxSearchFieldLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("'country' and 'city' must be at most %d characters", maxSearchFieldLength))
+		return
+	}
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be an integer between 1 and %d", maxSearchLimit))
+			return
+		}
+		query.Limit = limit
+	}
+	if raw := q.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			h.respondError(w, http.StatusBadRequest, "'offset' must be a non-negative integer")
+			return
+		}
+		query.Offset = offset
+	}
+
+	locations, err := h.service.Search(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, pkerr.ErrNotSupported) {
+			h.respondError(w, http.StatusNotImplemented, err.Error())
+		} else {
+			h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	resp := models.SearchResponse{
+		Results: make([]models.IPLocationWithIP, len(locations)),
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}
+	for i, location := range locations {
+		resp.Results[i] = models.IPLocationWithIP{IPLocation: *location, IP: location.IP}
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+// VerifySubnet handles GET /v1/subnet?cidr=<cidr>&country=<country>
+// @Summary      Verify a CIDR's country
+// @Description  Estimate how much of a network is located in a country, e.g. to check BGP policy. Up to MAX_CIDR_SAMPLE_SIZE addresses (default 100), spread evenly across the network, are looked up; match_rate is the fraction located in the country. country is a country name or an ISO 3166 alpha-2 code
+// @Tags         IP Lookup
+// @Produce      json
+// @Param        cidr     query      string  true  "Network in CIDR notation, narrower than /8"  example(8.8.8.0/24)
+// @Param        country  query      string  true  "Country name or ISO 3166 alpha-2 code"  example(US)
+// @Success      200  {object}   models.SubnetVerification
+// @Failure      400  {object}   models.ErrorResponse  "Missing parameter or invalid CIDR"
+// @Failure      422  {object}   models.ErrorResponse  "CIDR is /8 or wider"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Router       /v1/subnet [get]
+func (h *IPHandler) VerifySubnet(w http.ResponseWriter, r *http.Request) {
+	cidr := r.URL.Query().Get("cidr")
+	if cidr == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'cidr' query parameter")
+		return
+	}
+	country := r.URL.Query().Get("country")
+	if country == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'country' query parameter")
+		return
+	}
+
+	result, err := h.service.VerifySubnet(r.Context(), cidr, country)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCIDR):
+			h.respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrCIDRTooLarge):
+			h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Distance handles GET /v1/distance?ip1=<ip>&ip2=<ip>
+// @Summary      Distance between two IPs
+// @Description  Great-circle distance in kilometres between the locations of two IP addresses, e.g. to spot logins from far-apart places. Requires a store with coordinates (MaxMind)
+// @Tags         IP Lookup
+// @Produce      json
+// @Param        ip1  query      string  true  "First IP address (IPv4 or IPv6)"   format(ip)  example(8.8.8.8)
+// @Param        ip2  query      string  true  "Second IP address (IPv4 or IPv6)"  format(ip)  example(1.1.1.1)
+// @Success      200  {object}   models.DistanceResult
+// @Failure      400  {object}   models.ErrorResponse  "Missing parameter or invalid IP format"
+// @Failure      404  {object}   models.ErrorResponse  "IP not found"
+// @Failure      422  {object}   models.ErrorResponse  "No coordinates for an IP"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Router       /v1/distance [get]
+func (h *IPHandler) Distance(w http.ResponseWriter, r *http.Request) {
+	ip1 := r.URL.Query().Get("ip1")
+	if ip1 == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'ip1' query parameter")
+		return
+	}
+	ip2 := r.URL.Query().Get("ip2")
+	if ip2 == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'ip2' query parameter")
+		return
+	}
+
+	result, err := h.service.Distance(r.Context(), ip1, ip2)
+	if err != nil {
+		switch {
+		case errors.Is(err, pkerr.ErrInvalidIP):
+			h.respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, pkerr.ErrNotFound):
+			h.respondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, geo.ErrNoCoordinates):
+			h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Geofence handles GET /v1/geofence?ip=<ip>&lat=<lat>&lon=<lon>&radius_km=<km>
+// @Summary      Geofence check
+// @Description  Whether the location of an IP address is within radius_km of a point, e.g. to restrict logins to a region. Requires a store with coordinates (MaxMind)
+// @Tags         IP Lookup
+// @Produce      json
+// @Param        ip         query      string  true  "IP address (IPv4 or IPv6)"  format(ip)  example(8.8.8.8)
+// @Param        lat        query      number  true  "Latitude of the center, -90 to 90"  example(37.386)
+// @Param        lon        query      number  true  "Longitude of the center, -180 to 180"  example(-122.0838)
+// @Param        radius_km  query      number  true  "Radius in kilometres, above 0"  example(50)
+// @Success      200  {object}   models.GeofenceResult
+// @Failure      400  {object}   models.ErrorResponse  "Missing or invalid parameter, or invalid IP format"
+// @Failure      404  {object}   models.ErrorResponse  "IP not found"
+// @Failure      422  {object}   models.ErrorResponse  "No coordinates for the IP"
+// @Failure      429  {object}   models.ErrorResponse  "Rate limit exceeded"
+// @Failure      500  {object}   models.ErrorResponse  "Internal server error"
+// @Router       /v1/geofence [get]
+func (h *IPHandler) Geofence(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ip := q.Get("ip")
+	if ip == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing 'ip' query parameter")
+		return
+	}
+
+	lat, ok := queryFloat(q, "lat", -90, 90)
+	if !ok {
+		h.respondError(w, http.StatusBadRequest, "'lat' must be a number between -90 and 90")
+		return
+	}
+	lon, ok := queryFloat(q, "lon", -180, 180)
+	if !ok {
+		h.respondError(w, http.StatusBadRequest, "'lon' must be a number between -180 and 180")
+		return
+	}
+	radiusKm, ok := queryFloat(q, "radius_km", 0, math.MaxFloat64)
+	if !ok || radiusKm == 0 {
+		h.respondError(w, http.StatusBadRequest, "'radius_km' must be a positive number")
+		return
+	}
+	fence := geofence.CircleFence{Center: models.Coordinates{Latitude: lat, Longitude: lon}, RadiusKm: radiusKm}
+
+	result, err := h.service.Geofence(r.Context(), ip, fence)
+	if err != nil {
+		switch {
+		case errors.Is(err, pkerr.ErrInvalidIP):
+			h.respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, pkerr.ErrNotFound):
+			h.respondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, geo.ErrNoCoordinates):
+			h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// queryFloat parses the query parameter name as a number between min and max (inclusive)
+// ok is false if it's missing, not a number or out of range
+func queryFloat(q url.Values, name string, min, max float64) (value float64, ok bool) {
+	value, err := strconv.ParseFloat(q.Get(name), 64)
+	if err != nil || !(value >= min && value <= max) { // Also rejects NaN
+		return 0, false
+	}
+	return value, true
+}
+
+// Recent lookups limits
+const (
+	defaultRecentLookups = 10
+	maxRecentLookups     = 100
+)
+
+// Recent handles GET /v1/recent?n=<n>
+// @Summary      Recent lookups
+// @Description  Return the most recent IP lookups (newest first) with their result and latency. For debugging; requires the X-API-Key header matching ADMIN_API_KEY, and HISTORY_SIZE > 0 to record anything
+// @Tags         IP Lookup
+// @Produce      json
+// @Param        n    query      int  false  "Number of entries (default 10, max 100)"  example(10)
+// @Success      200  {array}    models.HistoryEntry
+// @Failure      400  {object}   models.ErrorResponse  "Invalid n"
+// @Failure      401  {object}   models.ErrorResponse  "Missing or invalid API key"
+// @Router       /v1/recent [get]
+func (h *IPHandler) Recent(w http.ResponseWriter, r *http.Request) {
+	n := defaultRecentLookups
+	if raw := r.URL.Query().Get("n"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			h.respondError(w, http.StatusBadRequest, "'n' must be a positive integer")
+			return
+		}
+		n = min(parsed, maxRecentLookups)
+	}
+
+	// History changes on every request - never let a proxy cache it
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, http.StatusOK, h.service.RecentLookups(n))
+}
+
+// Health handles GET /health
+// @Summary      Health check
+// @Description  Runs every registered health check (each store registers its own) in parallel, with a 2 second timeout. Returns 503 if any check fails
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}   models.HealthResponse
+// @Failure      503  {object}   models.HealthResponse  "A health check failed"
+// @Router       /health [get]
+func (h *IPHandler) Health(w http.ResponseWriter, r *http.Request) {
+	resp := models.HealthResponse{
+		Status:      "ok",
+		DataVersion: h.service.DataVersion(),
+	}
+	statusCode := http.StatusOK
+
+	results := h.checks.Run(r.Context(), health.DefaultTimeout)
+	if len(results) > 0 {
+		resp.Checks = make(map[string]models.HealthCheckResult, len(results))
+	}
+	for name, err := range results {
+		if err != nil {
+			resp.Checks[name] = models.HealthCheckResult{Status: "error", Error: err.Error()}
+			resp.Status = "unavailable"
+			statusCode = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = models.HealthCheckResult{Status: "ok"}
+	}
+
+	h.respondJSON(w, statusCode, resp)
+}
+
+// StaleDataHeader marks a lookup answered from cached data while the datastore was unreachable
+const StaleDataHeader = "X-Stale-Data"
+
+// DataVersionHeader tells clients which version of the IP data produced a response
+// When it changes between requests, cached responses are stale
+const DataVersionHeader = "X-Data-Version"
+
 // respondJSON writes a JSON response with the given status code
+// Successful responses carry the data version header (if the store reports one)
 func (h *IPHandler) respondJSON(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		// If encoding fails, we can't change the status code since headers are already sent
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	if statusCode == http.StatusOK && h.service != nil {
+		if version := h.service.DataVersion(); version != "" {
+			w.Header().Set(DataVersionHeader, version)
+		}
 	}
+	writeJSON(w, statusCode, data)
 }
 
 // respondError writes an error response with consistent formatting
 func (h *IPHandler) respondError(w http.ResponseWriter, statusCode int, message string) {
-	h.respondJSON(w, statusCode, models.ErrorResponse{Error: message})
+	writeError(w, statusCode, message)
 }
@@ -1,15 +1,22 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/evyataryagoni/ip2country/internal/health"
+	"github.com/evyataryagoni/ip2country/internal/history"
 	"github.com/evyataryagoni/ip2country/internal/models"
 	"github.com/evyataryagoni/ip2country/internal/service"
 	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
 )
 
 // TestIPHandler_FindCountry_Success tests successful response
@@ -138,7 +145,7 @@ func TestIPHandler_FindCountry_NotFound(t *testing.T) {
 	svc := service.NewIPService(mockStore, nil, nil)
 	handler := NewIPHandler(svc)
 
-	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=192.168.1.1", nil)
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=9.9.9.9", nil)
 	rec := httptest.NewRecorder()
 
 	handler.FindCountry(rec, req)
@@ -155,6 +162,27 @@ func TestIPHandler_FindCountry_NotFound(t *testing.T) {
 	}
 }
 
+// TestIPHandler_FindCountry_NotFound_Unroutable tests that a private IP missing from the store says why
+func TestIPHandler_FindCountry_NotFound_Unroutable(t *testing.T) {
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=192.168.1.1", nil)
+	rec := httptest.NewRecorder()
+
+	handler.FindCountry(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+
+	var errResp models.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errResp)
+
+	if errResp.Error != "IP address not found: IP is not publicly routable" {
+		t.Errorf("expected not found error, got: %s", errResp.Error)
+	}
+}
+
 // TestIPHandler_FindCountry_InternalError tests store errors
 func TestIPHandler_FindCountry_InternalError(t *testing.T) {
 	mockStore := store.NewMockStore()
@@ -180,6 +208,43 @@ func TestIPHandler_FindCountry_InternalError(t *testing.T) {
 	}
 }
 
+// TestHandler_ContextCancellationStopsStoreQuery tests that a slow store query returns early once the request's context is cancelled
+func TestHandler_ContextCancellationStopsStoreQuery(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPDelay = 10 * time.Second
+	svc := service.NewIPService(mockStore, nil, nil)
+	handler := NewIPHandler(svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	// Cancel mid-query, as when the client disconnects
+	time.AfterFunc(20*time.Millisecond, cancel)
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		defer close(done)
+		handler.FindCountry(rec, req)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the store query to return once the context was cancelled")
+	}
+
+	if elapsed := time.Since(start); elapsed >= mockStore.FindByIPDelay {
+		t.Errorf("expected the query to stop early, took %v", elapsed)
+	}
+	if len(mockStore.FindByIPCalls) != 1 {
+		t.Errorf("expected 1 store call, got %v", mockStore.FindByIPCalls)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
+
 // TestIPHandler_FindCountry_MultipleIPs tests multiple IP lookups
 func TestIPHandler_FindCountry_MultipleIPs(t *testing.T) {
 	tests := []struct {
@@ -381,3 +446,819 @@ func TestIPHandler_FindCountry_CaseSensitivity(t *testing.T) {
 		})
 	}
 }
+
+// TestIPHandler_Whois_Success tests the aggregated response
+func TestIPHandler_Whois_Success(t *testing.T) {
+	mockStore := store.NewMockStore()
+	svc := service.NewIPService(mockStore, nil, nil)
+	handler := NewIPHandler(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/whois?ip=8.8.8.8", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Whois(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var result models.WhoisResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if result.Country != "United States" {
+		t.Errorf("expected country 'United States', got '%s'", result.Country)
+	}
+	if result.Classification != "public" {
+		t.Errorf("expected classification 'public', got '%s'", result.Classification)
+	}
+}
+
+// TestIPHandler_Whois_PartialFailure tests that failed sub-lookups are listed in "errors"
+func TestIPHandler_Whois_PartialFailure(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPError = fmt.Errorf("database connection failed")
+	svc := service.NewIPService(mockStore, nil, nil)
+	handler := NewIPHandler(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/whois?ip=8.8.8.8", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Whois(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&body)
+
+	errs, ok := body["errors"].([]interface{})
+	if !ok || len(errs) != 1 {
+		t.Errorf("expected 1 entry in errors, got %v", body["errors"])
+	}
+	if body["classification"] != "public" {
+		t.Errorf("expected classification to still be present, got %v", body["classification"])
+	}
+}
+
+// TestIPHandler_Whois_Timeout tests that a slow store produces 504
+func TestIPHandler_Whois_Timeout(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPDelay = 200 * time.Millisecond
+	svc := service.NewIPService(mockStore, nil, nil)
+	svc.SetWhoisTimeout(20 * time.Millisecond)
+	handler := NewIPHandler(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/whois?ip=8.8.8.8", nil)
+	rec := httptest.NewRecorder()
+
+	handler.Whois(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", rec.Code)
+	}
+}
+
+// TestIPHandler_Whois_BadRequest tests missing and invalid IP parameters
+func TestIPHandler_Whois_BadRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"missing parameter", ""},
+		{"invalid IP", "?ip=not-an-ip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := service.NewIPService(store.NewMockStore(), nil, nil)
+			handler := NewIPHandler(svc)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/whois"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.Whois(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+// newRecentHandler creates a handler whose service has made the given number of lookups
+func newRecentHandler(lookups int) *IPHandler {
+	svc := service.NewIPService(store.NewMockStore(), nil, nil)
+	svc.SetHistory(history.NewRingBuffer[models.HistoryEntry](1000))
+	for i := 0; i < lookups; i++ {
+		svc.LookupIP(context.Background(), "8.8.8.8")
+	}
+	return NewIPHandler(svc)
+}
+
+// TestIPHandler_Recent tests that exactly min(n, available) entries are returned
+func TestIPHandler_Recent(t *testing.T) {
+	tests := []struct {
+		name     string
+		lookups  int
+		query    string
+		expected int
+	}{
+		{name: "default n", lookups: 20, query: "", expected: 10},
+		{name: "fewer available", lookups: 3, query: "?n=10", expected: 3},
+		{name: "exact n", lookups: 20, query: "?n=5", expected: 5},
+		{name: "capped at 100", lookups: 150, query: "?n=500", expected: 100},
+		{name: "no history yet", lookups: 0, query: "?n=5", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newRecentHandler(tt.lookups)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/recent"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.Recent(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("expected Cache-Control no-store, got %q", got)
+			}
+
+			var entries []models.HistoryEntry
+			if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(entries) != tt.expected {
+				t.Errorf("expected %d entries, got %d", tt.expected, len(entries))
+			}
+		})
+	}
+}
+
+// TestIPHandler_Recent_InvalidN tests that non-positive or non-numeric n is rejected
+func TestIPHandler_Recent_InvalidN(t *testing.T) {
+	handler := newRecentHandler(1)
+
+	for _, n := range []string{"0", "-1", "abc"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/recent?n="+n, nil)
+		rec := httptest.NewRecorder()
+
+		handler.Recent(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("n=%s: expected status 400, got %d", n, rec.Code)
+		}
+	}
+}
+
+// TestIPHandler_DataVersionHeader tests the X-Data-Version header on successful responses only
+func TestIPHandler_DataVersionHeader(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.DataVersion = "v1"
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	// Same version across requests while the data doesn't change
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.FindCountry(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if got := rec.Header().Get(DataVersionHeader); got != "v1" {
+			t.Errorf("request %d: expected X-Data-Version 'v1', got %q", i+1, got)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.FindCountry(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=9.9.9.9", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+	if got := rec.Header().Get(DataVersionHeader); got != "" {
+		t.Errorf("expected no X-Data-Version on errors, got %q", got)
+	}
+}
+
+// TestIPHandler_Health tests the health response includes the data version
+func TestIPHandler_Health(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.DataVersion = "v2"
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp models.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Status != "ok" || resp.DataVersion != "v2" {
+		t.Errorf("unexpected health response: %+v", resp)
+	}
+	if got := rec.Header().Get(DataVersionHeader); got != "v2" {
+		t.Errorf("expected X-Data-Version 'v2', got %q", got)
+	}
+}
+
+// TestIPHandler_ListCountries tests that the response is an alphabetically sorted array of distinct countries
+func TestIPHandler_ListCountries(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.Data["8.8.4.4"] = &models.IPLocation{IP: "8.8.4.4", City: "Mountain View", Country: "United States"}
+	mockStore.Data["2.22.233.255"] = &models.IPLocation{IP: "2.22.233.255", City: "London", Country: "United Kingdom"}
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.ListCountries(rec, httptest.NewRequest(http.MethodGet, "/v1/countries", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp models.CountriesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := []string{"Australia", "United Kingdom", "United States"}
+	if fmt.Sprint(resp.Countries) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, resp.Countries)
+	}
+}
+
+// TestIPHandler_ListCountries_Errors tests store failures
+func TestIPHandler_ListCountries_Errors(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.ListCountriesError = fmt.Errorf("connection refused")
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.ListCountries(rec, httptest.NewRequest(http.MethodGet, "/v1/countries", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+
+	mockStore.ListCountriesError = fmt.Errorf("listing countries is %w", pkerr.ErrNotSupported)
+	rec = httptest.NewRecorder()
+	handler.ListCountries(rec, httptest.NewRequest(http.MethodGet, "/v1/countries", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected status 501, got %d", rec.Code)
+	}
+}
+
+// TestIPHandler_Search tests field filters and pagination, with the IP included in each result
+func TestIPHandler_Search(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.Data["4.2.2.2"] = &models.IPLocation{IP: "4.2.2.2", City: "New York", Country: "United States"}
+	mockStore.Data["9.9.9.9"] = &models.IPLocation{IP: "9.9.9.9", City: "Newark", Country: "United States"}
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	tests := []struct {
+		name     string
+		url      string
+		expected []string
+	}{
+		{"country only", "/v1/search?country=australia", []string{"1.1.1.1"}},
+		{"city only", "/v1/search?city=New", []string{"4.2.2.2", "9.9.9.9"}},
+		{"both fields", "/v1/search?country=United&city=mountain", []string{"8.8.8.8"}},
+		{"pagination", "/v1/search?country=United&limit=2&offset=1", []string{"8.8.8.8", "9.9.9.9"}},
+		{"no match", "/v1/search?country=France", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.Search(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var resp models.SearchResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			ips := []string{}
+			for _, result := range resp.Results {
+				ips = append(ips, result.IP)
+			}
+			if fmt.Sprint(ips) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ips)
+			}
+		})
+	}
+}
+
+// TestIPHandler_Search_Defaults tests that limit defaults to 10 and the page is echoed in the response
+func TestIPHandler_Search_Defaults(t *testing.T) {
+	mockStore := store.NewEmptyMockStore()
+	for i := range 20 {
+		ip := fmt.Sprintf("8.8.8.%d", i+10)
+		mockStore.Data[ip] = &models.IPLocation{IP: ip, City: "Mountain View", Country: "United States"}
+	}
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/v1/search?country=United", nil))
+
+	var resp models.SearchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 10 || resp.Limit != 10 || resp.Offset != 0 {
+		t.Errorf("expected 10 results with limit 10 and offset 0, got %d with limit %d and offset %d", len(resp.Results), resp.Limit, resp.Offset)
+	}
+	if resp.Results[0].City != "Mountain View" || resp.Results[0].Country != "United States" {
+		t.Errorf("expected the location fields, got %+v", resp.Results[0])
+	}
+}
+
+// TestIPHandler_Search_InvalidParameters tests input validation
+func TestIPHandler_Search_InvalidParameters(t *testing.T) {
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+
+	long := strings.Repeat("a", 101)
+	urls := []string{
+		"/v1/search?limit=0",
+		"/v1/search?limit=101",
+		"/v1/search?limit=ten",
+		"/v1/search?offset=-1",
+		"/v1/search?offset=x",
+		"/v1/search?country=" + long,
+		"/v1/search?city=" + long,
+	}
+	for _, url := range urls {
+		rec := httptest.NewRecorder()
+		handler.Search(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, rec.Code)
+		}
+	}
+
+	// 100 characters is the maximum, counted in characters rather than bytes
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/v1/search?limit=100&city="+strings.Repeat("é", 100), nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 at the limits, got %d", rec.Code)
+	}
+}
+
+// TestIPHandler_Search_Errors tests store failures
+func TestIPHandler_Search_Errors(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.SearchError = fmt.Errorf("connection refused")
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/v1/search?country=United", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+
+	mockStore.SearchError = fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	rec = httptest.NewRecorder()
+	handler.Search(rec, httptest.NewRequest(http.MethodGet, "/v1/search?country=United", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected status 501, got %d", rec.Code)
+	}
+}
+
+// TestIPHandler_VerifySubnet tests the match rate response for a network entirely in the requested country
+func TestIPHandler_VerifySubnet(t *testing.T) {
+	mockStore := store.NewEmptyMockStore()
+	for i := range 256 {
+		ip := fmt.Sprintf("8.8.8.%d", i)
+		mockStore.Data[ip] = &models.IPLocation{IP: ip, City: "Mountain View", Country: "United States"}
+	}
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.VerifySubnet(rec, httptest.NewRequest(http.MethodGet, "/v1/subnet?cidr=8.8.8.0/24&country=US", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.SubnetVerification
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := models.SubnetVerification{CIDR: "8.8.8.0/24", RequestedCountry: "US", MatchRate: 1.0, SampleSize: 100}
+	if resp != expected {
+		t.Errorf("expected %+v, got %+v", expected, resp)
+	}
+}
+
+// TestIPHandler_VerifySubnet_Errors tests the status code of each failure
+func TestIPHandler_VerifySubnet_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing cidr", "?country=US", http.StatusBadRequest},
+		{"missing country", "?cidr=8.8.8.0/24", http.StatusBadRequest},
+		{"invalid cidr", "?cidr=8.8.8.0/40&country=US", http.StatusBadRequest},
+		{"/8", "?cidr=8.0.0.0/8&country=US", http.StatusUnprocessableEntity},
+		{"wider than /8", "?cidr=0.0.0.0/1&country=US", http.StatusUnprocessableEntity},
+	}
+
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.VerifySubnet(rec, httptest.NewRequest(http.MethodGet, "/v1/subnet"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPError = fmt.Errorf("connection refused")
+	handler = NewIPHandler(service.NewIPService(mockStore, nil, nil))
+	rec := httptest.NewRecorder()
+	handler.VerifySubnet(rec, httptest.NewRequest(http.MethodGet, "/v1/subnet?cidr=8.8.8.0/24&country=US", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 on store errors, got %d", rec.Code)
+	}
+}
+
+// TestIPHandler_Distance tests the distance between two located IPs
+func TestIPHandler_Distance(t *testing.T) {
+	mockStore := store.NewEmptyMockStore()
+	mockStore.Data["81.2.69.160"] = &models.IPLocation{IP: "81.2.69.160", City: "London", Country: "United Kingdom", Latitude: 51.5074, Longitude: -0.1278}
+	mockStore.Data["4.4.4.4"] = &models.IPLocation{IP: "4.4.4.4", City: "New York", Country: "United States", Latitude: 40.7128, Longitude: -74.0060}
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.Distance(rec, httptest.NewRequest(http.MethodGet, "/v1/distance?ip1=81.2.69.160&ip2=4.4.4.4", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.DistanceResult
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := models.DistanceResult{IP1: "81.2.69.160", IP2: "4.4.4.4", DistanceKm: 5570.2}
+	if resp != expected {
+		t.Errorf("expected %+v, got %+v", expected, resp)
+	}
+}
+
+// TestIPHandler_Distance_Errors tests the status code of each failure
+func TestIPHandler_Distance_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing ip1", "?ip2=8.8.8.8", http.StatusBadRequest},
+		{"missing ip2", "?ip1=8.8.8.8", http.StatusBadRequest},
+		{"invalid IP", "?ip1=8.8.8.8&ip2=not-an-ip", http.StatusBadRequest},
+		{"unknown IP", "?ip1=8.8.8.8&ip2=9.9.9.9", http.StatusNotFound},
+		{"no coordinates", "?ip1=8.8.8.8&ip2=1.1.1.1", http.StatusUnprocessableEntity},
+	}
+
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.Distance(rec, httptest.NewRequest(http.MethodGet, "/v1/distance"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+// TestIPHandler_Geofence tests that IPs inside and outside the circle are both 200, with "inside" set
+func TestIPHandler_Geofence(t *testing.T) {
+	mockStore := store.NewEmptyMockStore()
+	mockStore.Data["81.2.69.160"] = &models.IPLocation{IP: "81.2.69.160", City: "London", Country: "United Kingdom", Latitude: 51.5074, Longitude: -0.1278}
+	mockStore.Data["4.4.4.4"] = &models.IPLocation{IP: "4.4.4.4", City: "New York", Country: "United States", Latitude: 40.7128, Longitude: -74.0060}
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"81.2.69.160", `{"ip":"81.2.69.160","inside":true}`},
+		{"4.4.4.4", `{"ip":"4.4.4.4","inside":false}`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.Geofence(rec, httptest.NewRequest(http.MethodGet, "/v1/geofence?ip="+tt.ip+"&lat=51.5&lon=-0.1&radius_km=100", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", tt.ip, rec.Code, rec.Body.String())
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+// TestIPHandler_Geofence_Errors tests the status code of each failure
+func TestIPHandler_Geofence_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing ip", "?lat=0&lon=0&radius_km=1", http.StatusBadRequest},
+		{"missing lat", "?ip=8.8.8.8&lon=0&radius_km=1", http.StatusBadRequest},
+		{"latitude out of range", "?ip=8.8.8.8&lat=91&lon=0&radius_km=1", http.StatusBadRequest},
+		{"longitude not a number", "?ip=8.8.8.8&lat=0&lon=east&radius_km=1", http.StatusBadRequest},
+		{"NaN longitude", "?ip=8.8.8.8&lat=0&lon=NaN&radius_km=1", http.StatusBadRequest},
+		{"zero radius", "?ip=8.8.8.8&lat=0&lon=0&radius_km=0", http.StatusBadRequest},
+		{"negative radius", "?ip=8.8.8.8&lat=0&lon=0&radius_km=-5", http.StatusBadRequest},
+		{"invalid IP", "?ip=not-an-ip&lat=0&lon=0&radius_km=1", http.StatusBadRequest},
+		{"unknown IP", "?ip=9.9.9.9&lat=0&lon=0&radius_km=1", http.StatusNotFound},
+		{"no coordinates", "?ip=8.8.8.8&lat=0&lon=0&radius_km=1", http.StatusUnprocessableEntity},
+	}
+
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.Geofence(rec, httptest.NewRequest(http.MethodGet, "/v1/geofence"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestIPHandler_BatchLookup tests that found IPs are in results and the others in errors, with find-country's messages
+func TestIPHandler_BatchLookup(t *testing.T) {
+	mockStore := store.NewMockStore()
+	handler := NewIPHandler(service.NewIPService(mockStore, nil, nil))
+
+	rec := httptest.NewRecorder()
+	body := `{"ips":["8.8.8.8","1.1.1.1","9.9.9.9","not-an-ip"]}`
+	handler.BatchLookup(rec, httptest.NewRequest(http.MethodPost, "/v1/batch-lookup", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp models.BatchLookupResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results["8.8.8.8"].City != "Mountain View" || resp.Results["1.1.1.1"].Country != "Australia" {
+		t.Errorf("expected 8.8.8.8 and 1.1.1.1 in results, got %+v", resp.Results)
+	}
+	expectedErrors := map[string]string{"9.9.9.9": "IP address not found", "not-an-ip": "invalid IP address format"}
+	if !maps.Equal(resp.Errors, expectedErrors) {
+		t.Errorf("expected errors %v, got %v", expectedErrors, resp.Errors)
+	}
+
+	// Store errors aren't passed on
+	mockStore.FindByIPError = fmt.Errorf("dial tcp 10.0.0.5:6379: connection refused")
+	rec = httptest.NewRecorder()
+	handler.BatchLookup(rec, httptest.NewRequest(http.MethodPost, "/v1/batch-lookup", strings.NewReader(`{"ips":["8.8.8.8"]}`)))
+	if !strings.Contains(rec.Body.String(), `"8.8.8.8":"Internal server error"`) {
+		t.Errorf("expected a generic error, got %s", rec.Body.String())
+	}
+}
+
+// TestIPHandler_BatchLookup_Errors tests the status code of each rejected request
+func TestIPHandler_BatchLookup_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"invalid JSON", `{"ips":`, http.StatusBadRequest},
+		{"no IPs", `{"ips":[]}`, http.StatusBadRequest},
+		{"missing field", `{}`, http.StatusBadRequest},
+		{"over the batch size", `{"ips":["8.8.8.8","1.1.1.1","9.9.9.9"]}`, http.StatusBadRequest},
+		{"body too large", `{"ips":["8.8.8.8"],"padding":"` + strings.Repeat("x", 300) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	handler.SetMaxBatchSize(2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.BatchLookup(rec, httptest.NewRequest(http.MethodPost, "/v1/batch-lookup", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestIPHandler_FindCountryJSON tests the POST variant against its error cases
+func TestIPHandler_FindCountryJSON(t *testing.T) {
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"valid body", `{"ip":"8.8.8.8"}`, http.StatusOK, ""},
+		{"missing field", `{}`, http.StatusBadRequest, "Missing 'ip' query parameter"},
+		{"malformed JSON", `{"ip":`, http.StatusBadRequest, "invalid request body"},
+		{"oversized body", `{"ip":"8.8.8.8","padding":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge, "request body too large"},
+		{"invalid IP", `{"ip":"not-an-ip"}`, http.StatusBadRequest, "invalid IP address format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/find-country", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.FindCountryJSON(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedError == "" {
+				return
+			}
+			var errResp models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if errResp.Error != tt.expectedError {
+				t.Errorf("expected error %q, got %q", tt.expectedError, errResp.Error)
+			}
+		})
+	}
+}
+
+// TestIPHandler_FindCountryJSON_MatchesGet tests that POST and GET return identical responses
+func TestIPHandler_FindCountryJSON_MatchesGet(t *testing.T) {
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+
+	for _, ip := range []string{"8.8.8.8", "2001:4860:4860::8888", "10.0.0.1"} {
+		getRec := httptest.NewRecorder()
+		handler.FindCountry(getRec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip="+ip, nil))
+
+		postRec := httptest.NewRecorder()
+		postReq := httptest.NewRequest(http.MethodPost, "/v1/find-country", strings.NewReader(`{"ip":"`+ip+`"}`))
+		postReq.Header.Set("Content-Type", "application/json")
+		handler.FindCountryJSON(postRec, postReq)
+
+		if postRec.Code != getRec.Code {
+			t.Errorf("%s: POST status %d, GET status %d", ip, postRec.Code, getRec.Code)
+		}
+		if postRec.Body.String() != getRec.Body.String() {
+			t.Errorf("%s: POST body %q, GET body %q", ip, postRec.Body.String(), getRec.Body.String())
+		}
+		if postRec.Header().Get("Content-Type") != getRec.Header().Get("Content-Type") {
+			t.Errorf("%s: Content-Type differs between POST and GET", ip)
+		}
+	}
+}
+
+// TestIPHandler_FindCountry_StaleData tests that stale results are flagged and kept out of shared caches
+func TestIPHandler_FindCountry_StaleData(t *testing.T) {
+	mockStore := store.NewMockStore()
+	handler := NewIPHandler(service.NewIPService(store.NewStaleStore(mockStore, 0), nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.FindCountry(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+	if rec.Header().Get(StaleDataHeader) != "" {
+		t.Errorf("expected no X-Stale-Data header on live data, got %q", rec.Header().Get(StaleDataHeader))
+	}
+
+	mockStore.FindByIPError = fmt.Errorf("dial tcp: connection refused")
+	rec = httptest.NewRecorder()
+	handler.FindCountry(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get(StaleDataHeader); got != "true" {
+		t.Errorf("expected X-Stale-Data 'true', got %q", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected Cache-Control 'no-store', got %q", got)
+	}
+}
+
+// TestIPHandler_FindCountry_IncludeIP tests that the IP is only in the body with include_ip=true
+func TestIPHandler_FindCountry_IncludeIP(t *testing.T) {
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+
+	tests := []struct {
+		query  string
+		wantIP bool
+	}{
+		{"", false},
+		{"&include_ip=false", false},
+		{"&include_ip=true", true},
+		{"&include_ip=1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.FindCountry(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+
+			var body map[string]any
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			ip, ok := body["ip"]
+			if ok != tt.wantIP {
+				t.Fatalf("expected ip field present=%v, got body %v", tt.wantIP, body)
+			}
+			if tt.wantIP && ip != "8.8.8.8" {
+				t.Errorf("expected ip '8.8.8.8', got %v", ip)
+			}
+			if body["city"] != "Mountain View" || body["country"] != "United States" {
+				t.Errorf("expected the location fields either way, got %v", body)
+			}
+		})
+	}
+}
+
+// TestIPHandler_FindCountry_IncludeIP_Invalid tests that a non-boolean include_ip is rejected
+func TestIPHandler_FindCountry_IncludeIP_Invalid(t *testing.T) {
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+
+	rec := httptest.NewRecorder()
+	handler.FindCountry(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8&include_ip=yes", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+// newHealthHandler creates a handler whose health checks come from a fresh registry
+func newHealthHandler() (*IPHandler, *health.CheckRegistry) {
+	checks := health.NewCheckRegistry()
+	handler := NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	handler.SetHealthRegistry(checks)
+	return handler, checks
+}
+
+// TestIPHandler_Health_Checks tests that registered checks appear in the response
+func TestIPHandler_Health_Checks(t *testing.T) {
+	handler, checks := newHealthHandler()
+	checks.Register("csv", func(ctx context.Context) error { return nil })
+	checks.Register("redis", func(ctx context.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp models.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Status != "ok" || len(resp.Checks) != 2 {
+		t.Fatalf("expected status ok with 2 checks, got %+v", resp)
+	}
+	for _, name := range []string{"csv", "redis"} {
+		if resp.Checks[name].Status != "ok" {
+			t.Errorf("expected check %q to be ok, got %+v", name, resp.Checks[name])
+		}
+	}
+}
+
+// TestIPHandler_Health_CheckFails tests that a failing check returns 503 with its error
+func TestIPHandler_Health_CheckFails(t *testing.T) {
+	handler, checks := newHealthHandler()
+	checks.Register("csv", func(ctx context.Context) error { return nil })
+	checks.Register("mysql", func(ctx context.Context) error { return fmt.Errorf("connection refused") })
+
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rec.Code)
+	}
+	var resp models.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Status != "unavailable" {
+		t.Errorf("expected status 'unavailable', got %q", resp.Status)
+	}
+	if got := resp.Checks["mysql"]; got.Status != "error" || got.Error != "connection refused" {
+		t.Errorf("expected mysql check to report 'connection refused', got %+v", got)
+	}
+	if got := resp.Checks["csv"]; got.Status != "ok" {
+		t.Errorf("expected csv check to be ok, got %+v", got)
+	}
+}
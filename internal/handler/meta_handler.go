@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/routing"
+)
+
+// NodeForHandler serves GET /meta/node-for?ip=<ip>: the instance lookups of ip should go to, so load
+// balancer scripts can route each IP to the instance whose cache already holds it (see routing.ConsistentHasher)
+// @Summary      Preferred instance for an IP
+// @Description  The instance of ROUTING_NODES that lookups of an IP should be routed to, by consistent hashing. Every instance answers the same. Only served when ROUTING_NODES is set
+// @Tags         Routing
+// @Produce      json
+// @Param        ip   query      string  true  "IP address (IPv4 or IPv6)"  format(ip)  example(8.8.8.8)
+// @Success      200  {object}   models.NodeForResponse
+// @Failure      400  {object}   models.ErrorResponse  "Missing parameter or invalid IP format"
+// @Router       /meta/node-for [get]
+func NodeForHandler(hasher routing.ConsistentHasher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := r.URL.Query().Get("ip")
+		if ip == "" {
+			writeError(w, http.StatusBadRequest, "Missing 'ip' query parameter")
+			return
+		}
+
+		node, err := hasher.NodeFor(ip)
+		if err != nil {
+			if errors.Is(err, pkerr.ErrInvalidIP) {
+				writeError(w, http.StatusBadRequest, err.Error())
+			} else {
+				writeError(w, http.StatusInternalServerError, "Internal server error")
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, models.NodeForResponse{Node: node})
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/routing"
+)
+
+// TestNodeForHandler tests that an IP is always sent to the same node, and that equivalent forms of it go there too
+func TestNodeForHandler(t *testing.T) {
+	h := NodeForHandler(routing.ConsistentHasher{Nodes: []string{"instance-1", "instance-2", "instance-3"}})
+
+	nodeFor := func(ip string) string {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/meta/node-for?ip="+ip, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", ip, rec.Code, rec.Body.String())
+		}
+		var resp models.NodeForResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.Node
+	}
+
+	node := nodeFor("8.8.8.8")
+	if node == "" {
+		t.Fatal("expected a node")
+	}
+	if again := nodeFor("8.8.8.8"); again != node {
+		t.Errorf("expected %s again, got %s", node, again)
+	}
+	if mapped := nodeFor("::ffff:8.8.8.8"); mapped != node {
+		t.Errorf("expected ::ffff:8.8.8.8 on %s, got %s", node, mapped)
+	}
+}
+
+// TestNodeForHandler_Errors tests that missing and invalid IPs are 400
+func TestNodeForHandler_Errors(t *testing.T) {
+	h := NodeForHandler(routing.ConsistentHasher{Nodes: []string{"instance-1"}})
+
+	for _, query := range []string{"", "?ip=not-an-ip"} {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/meta/node-for"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// writeJSON writes a JSON response with the given status code
+// Shared by all handlers so every endpoint formats responses the same way
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		// If encoding fails, we can't change the status code since headers are already sent
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// writeError writes an error response with consistent formatting
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, models.ErrorResponse{Error: message})
+}
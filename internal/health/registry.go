@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a whole Run of the registered checks
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc reports whether a dependency is healthy, returning an error when it isn't
+// Checks should honour ctx; one that doesn't is abandoned once ctx expires
+type CheckFunc func(ctx context.Context) error
+
+// registration is a check as registered, with an ID so a stale unregister can't remove its replacement
+type registration struct {
+	id    uint64
+	check CheckFunc
+}
+
+// CheckRegistry holds named health checks
+// Thread-safe: checks can be registered and unregistered while Run is in progress
+type CheckRegistry struct {
+	mu     sync.RWMutex
+	checks map[string]registration
+	nextID uint64
+}
+
+// NewCheckRegistry creates an empty registry
+func NewCheckRegistry() *CheckRegistry {
+	return &CheckRegistry{checks: make(map[string]registration)}
+}
+
+// Register adds check under name, replacing any check already registered with that name
+// The returned function removes the check again; it does nothing once the check has been replaced
+func (r *CheckRegistry) Register(name string, check CheckFunc) (unregister func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	id := r.nextID
+	r.checks[name] = registration{id: id, check: check}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if current, ok := r.checks[name]; ok && current.id == id {
+			delete(r.checks, name)
+		}
+	}
+}
+
+// Run calls every registered check in parallel, each bounded by timeout
+// Returns the result of each check by name (nil = healthy)
+func (r *CheckRegistry) Run(ctx context.Context, timeout time.Duration) map[string]error {
+	r.mu.RLock()
+	checks := maps.Clone(r.checks)
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error, len(checks))
+	)
+	for name, reg := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := runCheck(ctx, reg.check)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runCheck calls check, giving up when ctx expires even if check ignores it
+func runCheck(ctx context.Context, check CheckFunc) error {
+	done := make(chan error, 1) // Buffered so an abandoned check doesn't leak its goroutine forever
+	go func() { done <- check(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("health check timed out: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCheckRegistry_Run tests that every check runs and reports its own result
+func TestCheckRegistry_Run(t *testing.T) {
+	r := NewCheckRegistry()
+	r.Register("csv", func(ctx context.Context) error { return nil })
+	r.Register("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	results := r.Run(context.Background(), time.Second)
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+	if results["csv"] != nil {
+		t.Errorf("expected csv to be healthy, got %v", results["csv"])
+	}
+	if results["redis"] == nil || results["redis"].Error() != "connection refused" {
+		t.Errorf("expected redis to fail with 'connection refused', got %v", results["redis"])
+	}
+}
+
+// TestCheckRegistry_Run_Parallel tests that checks run concurrently under one timeout
+func TestCheckRegistry_Run_Parallel(t *testing.T) {
+	r := NewCheckRegistry()
+	for i := range 5 {
+		r.Register(fmt.Sprintf("slow-%d", i), func(ctx context.Context) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	}
+
+	start := time.Now()
+	results := r.Run(context.Background(), time.Second)
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("expected checks to run in parallel, took %v", elapsed)
+	}
+	for name, err := range results {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+// TestCheckRegistry_Run_Timeout tests that a check ignoring its context is abandoned at the timeout
+func TestCheckRegistry_Run_Timeout(t *testing.T) {
+	r := NewCheckRegistry()
+	release := make(chan struct{})
+	defer close(release)
+	r.Register("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	results := r.Run(context.Background(), 20*time.Millisecond)
+	if !errors.Is(results["stuck"], context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", results["stuck"])
+	}
+}
+
+// TestCheckRegistry_Unregister tests that unregistering only removes the registration it was returned for
+func TestCheckRegistry_Unregister(t *testing.T) {
+	r := NewCheckRegistry()
+	unregisterFirst := r.Register("csv", func(ctx context.Context) error { return errors.New("first") })
+	unregisterSecond := r.Register("csv", func(ctx context.Context) error { return errors.New("second") })
+
+	// The first registration was replaced, so removing it must keep the second
+	unregisterFirst()
+	results := r.Run(context.Background(), time.Second)
+	if results["csv"] == nil || results["csv"].Error() != "second" {
+		t.Errorf("expected the second check to remain, got %v", results)
+	}
+
+	unregisterSecond()
+	if results := r.Run(context.Background(), time.Second); len(results) != 0 {
+		t.Errorf("expected no checks, got %v", results)
+	}
+}
+
+// TestCheckRegistry_ConcurrentRegister tests that checks can be registered and run concurrently
+func TestCheckRegistry_ConcurrentRegister(t *testing.T) {
+	r := NewCheckRegistry()
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.Register(fmt.Sprintf("store-%d", i), func(ctx context.Context) error { return nil })
+		}()
+		go func() {
+			defer wg.Done()
+			r.Run(context.Background(), time.Second)
+		}()
+	}
+	wg.Wait()
+
+	if results := r.Run(context.Background(), time.Second); len(results) != 50 {
+		t.Errorf("expected 50 checks, got %d", len(results))
+	}
+}
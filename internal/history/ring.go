@@ -0,0 +1,70 @@
+package history
+
+import "sync"
+
+// RingBuffer keeps the most recent values up to a fixed capacity
+// Once full, each Add overwrites the oldest value. Memory use never grows
+// Thread-safe: a RWMutex lets many readers run while writes are serialized
+type RingBuffer[T any] struct {
+	mu     sync.RWMutex
+	values []T    // Fixed-size backing slice
+	next   uint64 // Total number of values ever added; next % cap is the slot written next
+}
+
+// NewRingBuffer creates a ring buffer holding at most capacity values
+// A capacity below 1 is raised to 1
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingBuffer[T]{values: make([]T, capacity)}
+}
+
+// Add appends a value, overwriting the oldest one when the buffer is full
+func (rb *RingBuffer[T]) Add(value T) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.values[rb.next%uint64(len(rb.values))] = value
+	rb.next++
+}
+
+// Last returns up to n of the most recent values, newest first
+func (rb *RingBuffer[T]) Last(n int) []T {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	if available := rb.lenLocked(); n > available {
+		n = available
+	}
+	if n <= 0 {
+		return []T{}
+	}
+
+	out := make([]T, n)
+	capacity := uint64(len(rb.values))
+	for i := 0; i < n; i++ {
+		out[i] = rb.values[(rb.next-1-uint64(i))%capacity]
+	}
+	return out
+}
+
+// Len returns the number of values currently held
+func (rb *RingBuffer[T]) Len() int {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return rb.lenLocked()
+}
+
+// Cap returns the maximum number of values held
+func (rb *RingBuffer[T]) Cap() int {
+	return len(rb.values)
+}
+
+// lenLocked returns the number of values held. Must be called with mu held
+func (rb *RingBuffer[T]) lenLocked() int {
+	if rb.next < uint64(len(rb.values)) {
+		return int(rb.next)
+	}
+	return len(rb.values)
+}
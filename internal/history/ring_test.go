@@ -0,0 +1,102 @@
+package history
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestRingBuffer_ReverseChronological tests that Last returns the newest values first
+func TestRingBuffer_ReverseChronological(t *testing.T) {
+	rb := NewRingBuffer[int](5)
+	for i := 1; i <= 3; i++ {
+		rb.Add(i)
+	}
+
+	got := rb.Last(10)
+	want := []int{3, 2, 1}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+// TestRingBuffer_Overwrite tests that old values are dropped once capacity is reached
+func TestRingBuffer_Overwrite(t *testing.T) {
+	rb := NewRingBuffer[int](3)
+	for i := 1; i <= 7; i++ {
+		rb.Add(i)
+	}
+
+	if rb.Len() != 3 {
+		t.Errorf("expected length capped at 3, got %d", rb.Len())
+	}
+
+	got := rb.Last(3)
+	want := []int{7, 6, 5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+// TestRingBuffer_LastBounds tests n larger than available, zero and negative
+func TestRingBuffer_LastBounds(t *testing.T) {
+	rb := NewRingBuffer[string](4)
+	rb.Add("a")
+	rb.Add("b")
+
+	if got := rb.Last(2); len(got) != 2 {
+		t.Errorf("expected 2 values, got %d", len(got))
+	}
+	if got := rb.Last(100); len(got) != 2 {
+		t.Errorf("expected min(n, available) = 2 values, got %d", len(got))
+	}
+	if got := rb.Last(0); len(got) != 0 {
+		t.Errorf("expected no values for n=0, got %d", len(got))
+	}
+	if got := NewRingBuffer[string](4).Last(5); got == nil || len(got) != 0 {
+		t.Errorf("expected empty (non-nil) slice from empty buffer, got %v", got)
+	}
+}
+
+// TestRingBuffer_ConcurrentWrites tests that parallel writers don't lose or corrupt values
+func TestRingBuffer_ConcurrentWrites(t *testing.T) {
+	const writers, perWriter = 8, 500
+	rb := NewRingBuffer[int](writers * perWriter)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				rb.Add(w*perWriter + i)
+				rb.Last(5) // Concurrent readers
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if rb.Len() != writers*perWriter {
+		t.Fatalf("expected %d values, got %d", writers*perWriter, rb.Len())
+	}
+
+	// Every value written exactly once
+	seen := make(map[int]bool)
+	for _, v := range rb.Last(writers * perWriter) {
+		if seen[v] {
+			t.Fatalf("value %d stored twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != writers*perWriter {
+		t.Errorf("expected %d distinct values, got %d", writers*perWriter, len(seen))
+	}
+}
@@ -0,0 +1,246 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Adaptive rate limiting defaults
+const (
+	DefaultAdaptiveHighWatermark  = 0.8
+	DefaultAdaptiveLowWatermark   = 0.5
+	DefaultAdaptiveThrottleFactor = 0.5
+	DefaultAdaptiveInterval       = 5 * time.Second
+)
+
+// CPUPoller reports CPU utilisation
+type CPUPoller interface {
+	// CPUUsage returns the utilisation since the previous call, from 0 (idle) to 1 (every core busy)
+	CPUUsage() (float64, error)
+}
+
+// AdaptiveConfig controls when an AdaptiveLimiter tightens the rate limit
+// Zero values use the defaults above
+type AdaptiveConfig struct {
+	HighWatermark  float64       // CPU utilisation above which the limit tightens
+	LowWatermark   float64       // CPU utilisation below which the configured limit is restored
+	ThrottleFactor float64       // Fraction of the configured rate allowed while throttled
+	Interval       time.Duration // How often CPU utilisation is polled
+}
+
+// withDefaults fills in zero fields and checks the watermarks are ordered
+func (c AdaptiveConfig) withDefaults() (AdaptiveConfig, error) {
+	if c.HighWatermark == 0 {
+		c.HighWatermark = DefaultAdaptiveHighWatermark
+	}
+	if c.LowWatermark == 0 {
+		c.LowWatermark = DefaultAdaptiveLowWatermark
+	}
+	if c.ThrottleFactor == 0 {
+		c.ThrottleFactor = DefaultAdaptiveThrottleFactor
+	}
+	if c.Interval <= 0 {
+		c.Interval = DefaultAdaptiveInterval
+	}
+
+	if c.LowWatermark > c.HighWatermark {
+		return c, fmt.Errorf("low watermark %.2f is above the high watermark %.2f", c.LowWatermark, c.HighWatermark)
+	}
+	if c.ThrottleFactor < 0 || c.ThrottleFactor > 1 {
+		return c, fmt.Errorf("throttle factor %.2f is not between 0 and 1", c.ThrottleFactor)
+	}
+	return c, nil
+}
+
+// AdaptiveLimiter tightens the rate limit while the server's CPU is busy, to shed load before it overloads
+// It holds two limiters built by the same function: one at the configured rate, and one at
+// ThrottleFactor times that rate, used while throttled. CPU utilisation is polled in the background:
+// above HighWatermark requests go to the throttled limiter, below LowWatermark back to the normal one.
+// In between nothing changes, so the limit doesn't flap around a single threshold.
+//
+// Every allowed request is counted by both limiters, so the one switched to already holds each
+// client's recent traffic: a client that used up its budget doesn't get a fresh one on a switch
+type AdaptiveLimiter struct {
+	normal    Limiter
+	throttled Limiter
+	cpu       CPUPoller
+	cfg       AdaptiveConfig
+
+	isThrottled atomic.Bool
+
+	// Optional, see SetRateGauge
+	gaugeMu sync.Mutex
+	gauge   prometheus.Gauge
+	rate    func() float64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAdaptiveLimiter builds the normal and throttled limiters with newLimiter, called with a factor
+// of the configured rate (1, then ThrottleFactor), and starts polling cpu every Interval
+func NewAdaptiveLimiter(newLimiter func(factor float64) (Limiter, error), cpu CPUPoller, cfg AdaptiveConfig) (*AdaptiveLimiter, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	normal, err := newLimiter(1)
+	if err != nil {
+		return nil, err
+	}
+	throttled, err := newLimiter(cfg.ThrottleFactor)
+	if err != nil {
+		normal.Close()
+		return nil, err
+	}
+
+	a := &AdaptiveLimiter{
+		normal:    normal,
+		throttled: throttled,
+		cpu:       cpu,
+		cfg:       cfg,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+// SetRateGauge reports the effective rate in requests per second to g after every poll
+// rate returns the configured rate, which a config reload may change
+func (a *AdaptiveLimiter) SetRateGauge(g prometheus.Gauge, rate func() float64) {
+	a.gaugeMu.Lock()
+	a.gauge = g
+	a.rate = rate
+	a.gaugeMu.Unlock()
+	a.updateGauge()
+}
+
+// Throttled reports whether the tightened limit is in effect
+func (a *AdaptiveLimiter) Throttled() bool {
+	return a.isThrottled.Load()
+}
+
+// Allow checks the request against the limiter for the current CPU state
+// An allowed request is also counted by the other limiter, whose answer doesn't matter until a switch
+func (a *AdaptiveLimiter) Allow(ip string) bool {
+	active, other := a.normal, a.throttled
+	if a.isThrottled.Load() {
+		active, other = a.throttled, a.normal
+	}
+
+	if !active.Allow(ip) {
+		return false
+	}
+	other.Allow(ip)
+	return true
+}
+
+// Reset clears the IP's state in both limiters
+func (a *AdaptiveLimiter) Reset(ip string) error {
+	return errors.Join(a.normal.Reset(ip), a.throttled.Reset(ip))
+}
+
+// List lists the IPs tracked by the limiter for the current CPU state
+func (a *AdaptiveLimiter) List() (map[string]RateLimitStatus, error) {
+	if a.isThrottled.Load() {
+		return a.throttled.List()
+	}
+	return a.normal.List()
+}
+
+// Limit returns the limit of the limiter for the current CPU state, 0 if it isn't inspectable
+// Implements the InspectableLimiter interface
+func (a *AdaptiveLimiter) Limit() int {
+	if inspectable, ok := a.current().(InspectableLimiter); ok {
+		return inspectable.Limit()
+	}
+	return 0
+}
+
+// Remaining returns the requests the IP has left in the limiter for the current CPU state
+// Implements the InspectableLimiter interface
+func (a *AdaptiveLimiter) Remaining(ip string) int {
+	if inspectable, ok := a.current().(InspectableLimiter); ok {
+		return inspectable.Remaining(ip)
+	}
+	return 0
+}
+
+// ResetAt returns when the IP's allowance resets in the limiter for the current CPU state
+// Implements the InspectableLimiter interface
+func (a *AdaptiveLimiter) ResetAt(ip string) time.Time {
+	if inspectable, ok := a.current().(InspectableLimiter); ok {
+		return inspectable.ResetAt(ip)
+	}
+	return time.Now()
+}
+
+// current returns the limiter for the current CPU state
+func (a *AdaptiveLimiter) current() Limiter {
+	if a.isThrottled.Load() {
+		return a.throttled
+	}
+	return a.normal
+}
+
+// Close stops polling and closes both limiters
+func (a *AdaptiveLimiter) Close() error {
+	close(a.stop)
+	<-a.done
+	return errors.Join(a.normal.Close(), a.throttled.Close())
+}
+
+// run polls CPU utilisation every Interval until Close
+func (a *AdaptiveLimiter) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.poll()
+		}
+	}
+}
+
+// poll reads CPU utilisation once and switches limiters if it crossed a watermark
+// A failed read keeps the current state
+func (a *AdaptiveLimiter) poll() {
+	usage, err := a.cpu.CPUUsage()
+	if err != nil {
+		return
+	}
+
+	switch {
+	case usage > a.cfg.HighWatermark:
+		a.isThrottled.Store(true)
+	case usage < a.cfg.LowWatermark:
+		a.isThrottled.Store(false)
+	}
+	a.updateGauge()
+}
+
+// updateGauge sets the gauge to the effective rate, if SetRateGauge was called
+func (a *AdaptiveLimiter) updateGauge() {
+	a.gaugeMu.Lock()
+	defer a.gaugeMu.Unlock()
+	if a.gauge == nil {
+		return
+	}
+
+	factor := 1.0
+	if a.isThrottled.Load() {
+		factor = a.cfg.ThrottleFactor
+	}
+	a.gauge.Set(a.rate() * factor)
+}
@@ -0,0 +1,249 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// mockCPUPoller returns the usage it was last set to
+type mockCPUPoller struct {
+	mu    sync.Mutex
+	usage float64
+	err   error
+}
+
+func (m *mockCPUPoller) set(usage float64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage, m.err = usage, err
+}
+
+func (m *mockCPUPoller) CPUUsage() (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage, m.err
+}
+
+// newTestAdaptiveLimiter returns an adaptive limiter over memory limiters at 10 req/s (burst 10)
+// Polling is left to the test (the background interval is an hour)
+func newTestAdaptiveLimiter(t *testing.T, cpu CPUPoller) *AdaptiveLimiter {
+	t.Helper()
+
+	a, err := NewAdaptiveLimiter(func(factor float64) (Limiter, error) {
+		return NewMemoryLimiter(10 * factor), nil
+	}, cpu, AdaptiveConfig{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create adaptive limiter: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+// allowedBurst returns how many immediate requests from ip are allowed
+func allowedBurst(l Limiter, ip string) int {
+	allowed := 0
+	for range 100 {
+		if l.Allow(ip) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// TestAdaptiveLimiter_HighCPU tests that the rate is halved once CPU crosses the high watermark
+func TestAdaptiveLimiter_HighCPU(t *testing.T) {
+	cpu := &mockCPUPoller{}
+	a := newTestAdaptiveLimiter(t, cpu)
+
+	if got := allowedBurst(a, "1.1.1.1"); got != 10 {
+		t.Errorf("expected 10 requests allowed at normal CPU, got %d", got)
+	}
+
+	cpu.set(0.9, nil)
+	a.poll()
+
+	if !a.Throttled() {
+		t.Fatal("expected the limiter to be throttled above the high watermark")
+	}
+	if got := allowedBurst(a, "2.2.2.2"); got != 5 {
+		t.Errorf("expected 5 requests allowed while throttled, got %d", got)
+	}
+}
+
+// TestAdaptiveLimiter_Restore tests that the rate is only restored below the low watermark
+func TestAdaptiveLimiter_Restore(t *testing.T) {
+	cpu := &mockCPUPoller{usage: 0.95}
+	a := newTestAdaptiveLimiter(t, cpu)
+	a.poll()
+
+	// Between the watermarks: stays throttled
+	cpu.set(0.6, nil)
+	a.poll()
+	if !a.Throttled() {
+		t.Error("expected the limiter to stay throttled between the watermarks")
+	}
+
+	// A failed read keeps the current state
+	cpu.set(0, errors.New("no CPU data"))
+	a.poll()
+	if !a.Throttled() {
+		t.Error("expected a failed CPU read to keep the limiter throttled")
+	}
+
+	cpu.set(0.3, nil)
+	a.poll()
+	if a.Throttled() {
+		t.Fatal("expected the limiter to be restored below the low watermark")
+	}
+	if got := allowedBurst(a, "3.3.3.3"); got != 10 {
+		t.Errorf("expected 10 requests allowed after restoring, got %d", got)
+	}
+}
+
+// TestAdaptiveLimiter_SwitchKeepsUsage tests that switching limiters doesn't give clients a fresh budget
+func TestAdaptiveLimiter_SwitchKeepsUsage(t *testing.T) {
+	cpu := &mockCPUPoller{}
+	a := newTestAdaptiveLimiter(t, cpu)
+
+	if got := allowedBurst(a, "1.1.1.1"); got != 10 {
+		t.Fatalf("expected 10 requests allowed at normal CPU, got %d", got)
+	}
+
+	cpu.set(0.9, nil)
+	a.poll()
+	if got := allowedBurst(a, "1.1.1.1"); got != 0 {
+		t.Errorf("expected no requests allowed after throttling an exhausted client, got %d", got)
+	}
+
+	// The requests allowed while throttled also count against the normal budget
+	if got := allowedBurst(a, "2.2.2.2"); got != 5 {
+		t.Fatalf("expected 5 requests allowed while throttled, got %d", got)
+	}
+	cpu.set(0.3, nil)
+	a.poll()
+	if got := allowedBurst(a, "2.2.2.2"); got != 5 {
+		t.Errorf("expected the 5 remaining requests allowed after restoring, got %d", got)
+	}
+}
+
+// TestAdaptiveLimiter_Inspect tests that the allowance is read from the limiter for the current CPU state
+func TestAdaptiveLimiter_Inspect(t *testing.T) {
+	cpu := &mockCPUPoller{}
+	a := newTestAdaptiveLimiter(t, cpu)
+
+	if got := a.Limit(); got != 10 {
+		t.Errorf("expected the normal limit of 10, got %d", got)
+	}
+	cpu.set(0.95, nil)
+	a.poll()
+	a.Allow("192.168.1.1")
+	if limit, remaining := a.Limit(), a.Remaining("192.168.1.1"); limit != 5 || remaining != 4 {
+		t.Errorf("expected 4 of 5 requests remaining while throttled, got %d of %d", remaining, limit)
+	}
+}
+
+// TestAdaptiveLimiter_RateGauge tests that the gauge reports the effective rate
+func TestAdaptiveLimiter_RateGauge(t *testing.T) {
+	cpu := &mockCPUPoller{}
+	a := newTestAdaptiveLimiter(t, cpu)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "adaptive_rate_limit_current"})
+	a.SetRateGauge(gauge, func() float64 { return 10 })
+
+	if got := testutil.ToFloat64(gauge); got != 10 {
+		t.Errorf("expected gauge 10, got %v", got)
+	}
+
+	cpu.set(0.85, nil)
+	a.poll()
+	if got := testutil.ToFloat64(gauge); got != 5 {
+		t.Errorf("expected gauge 5 while throttled, got %v", got)
+	}
+}
+
+// TestAdaptiveLimiter_ConcurrentAllow tests Allow while the CPU state flips back and forth (run with -race)
+func TestAdaptiveLimiter_ConcurrentAllow(t *testing.T) {
+	cpu := &mockCPUPoller{}
+	a := newTestAdaptiveLimiter(t, cpu)
+
+	var stop atomic.Bool
+	var polls sync.WaitGroup
+	polls.Add(1)
+	go func() {
+		defer polls.Done()
+		for i := 0; !stop.Load(); i++ {
+			cpu.set(float64(i%2), nil) // Alternates between idle and fully busy
+			a.poll()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				a.Allow(fmt.Sprintf("10.0.0.%d", i))
+			}
+		}()
+	}
+	wg.Wait()
+	stop.Store(true)
+	polls.Wait()
+}
+
+// TestAdaptiveLimiter_Background tests that CPU is polled every Interval without calling poll
+func TestAdaptiveLimiter_Background(t *testing.T) {
+	cpu := &mockCPUPoller{usage: 0.99}
+	a, err := NewAdaptiveLimiter(func(factor float64) (Limiter, error) {
+		return NewMemoryLimiter(10 * factor), nil
+	}, cpu, AdaptiveConfig{Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to create adaptive limiter: %v", err)
+	}
+	defer a.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !a.Throttled() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the background poll to throttle the limiter")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestAdaptiveConfig_Invalid tests that misordered watermarks and out of range factors are rejected
+func TestAdaptiveConfig_Invalid(t *testing.T) {
+	newLimiter := func(factor float64) (Limiter, error) { return NewMemoryLimiter(10 * factor), nil }
+
+	if _, err := NewAdaptiveLimiter(newLimiter, &mockCPUPoller{}, AdaptiveConfig{HighWatermark: 0.5, LowWatermark: 0.7}); err == nil {
+		t.Error("expected error for a low watermark above the high watermark")
+	}
+	if _, err := NewAdaptiveLimiter(newLimiter, &mockCPUPoller{}, AdaptiveConfig{ThrottleFactor: 1.5}); err == nil {
+		t.Error("expected error for a throttle factor above 1")
+	}
+}
+
+// TestProcessCPUPoller tests that usage is a fraction between 0 and 1
+func TestProcessCPUPoller(t *testing.T) {
+	poller := NewProcessCPUPoller()
+
+	// Burn some CPU so there's something to measure
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for x := 0; time.Now().Before(deadline); x++ {
+	}
+
+	usage, err := poller.CPUUsage()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage <= 0 || usage > 1 {
+		t.Errorf("expected usage in (0, 1], got %v", usage)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package limiter
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ProcessCPUPoller measures the CPU time used by this process, across all cores
+// A request-serving process is what the rate limit protects, so other processes
+// on the host (or in other containers) don't count
+type ProcessCPUPoller struct {
+	mu       sync.Mutex
+	lastCPU  time.Duration
+	lastWall time.Time
+}
+
+// NewProcessCPUPoller creates a poller; the first CPUUsage covers the time since this call
+func NewProcessCPUPoller() *ProcessCPUPoller {
+	cpu, _ := processCPUTime()
+	return &ProcessCPUPoller{lastCPU: cpu, lastWall: time.Now()}
+}
+
+// CPUUsage returns the process's CPU time since the previous call divided by the
+// wall time elapsed on every core
+func (p *ProcessCPUPoller) CPUUsage() (float64, error) {
+	cpu, err := processCPUTime()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	elapsed := now.Sub(p.lastWall) * time.Duration(runtime.NumCPU())
+	used := cpu - p.lastCPU
+	p.lastCPU, p.lastWall = cpu, now
+
+	if elapsed <= 0 {
+		return 0, nil
+	}
+	return min(float64(used)/float64(elapsed), 1), nil
+}
+
+// processCPUTime returns the user and system CPU time used by the process so far
+func processCPUTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, fmt.Errorf("failed to read CPU usage: %w", err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package limiter
+
+import "errors"
+
+// ProcessCPUPoller is unavailable on this platform: CPUUsage always fails,
+// so an AdaptiveLimiter never throttles
+type ProcessCPUPoller struct{}
+
+// NewProcessCPUPoller creates a poller
+func NewProcessCPUPoller() *ProcessCPUPoller {
+	return &ProcessCPUPoller{}
+}
+
+// CPUUsage returns an error: process CPU time isn't available on this platform
+func (p *ProcessCPUPoller) CPUUsage() (float64, error) {
+	return 0, errors.New("CPU usage is not supported on this platform")
+}
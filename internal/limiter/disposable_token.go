@@ -0,0 +1,116 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/retry"
+	"github.com/redis/go-redis/v9"
+)
+
+// DisposableTokenPrefix marks an API key as a one-time token
+const DisposableTokenPrefix = "one-time:"
+
+// disposableTokenKeyPrefix namespaces token keys in Redis ("disposable_token:{token}")
+const disposableTokenKeyPrefix = "disposable_token:"
+
+// ErrTokenExists is returned by AddToken when the token is already registered
+var ErrTokenExists = errors.New("token already exists")
+
+// DisposableTokenLimiter admits each token exactly once
+// Used for webhooks and third-party integrations that should get a single request
+//
+// Algorithm:
+//   - AddToken stores the token as a Redis key with a TTL (expired tokens disappear on their own)
+//   - Allow uses GETDEL, which reads and deletes in one atomic command, so two
+//     concurrent requests with the same token can't both succeed - even across servers
+type DisposableTokenLimiter struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewDisposableTokenLimiter creates a token limiter backed by Redis
+//
+// Parameters:
+//   - addr: Redis server address (e.g., "localhost:6379")
+//   - password: Redis password (empty string if no password)
+//   - db: Redis database number (0-15, default is 0)
+//
+// Returns:
+//   - *DisposableTokenLimiter: new token limiter instance
+//   - error: any error that occurred during connection
+func NewDisposableTokenLimiter(addr, password string, db int) (*DisposableTokenLimiter, error) {
+	return NewDisposableTokenLimiterWithRetry(addr, password, db, retry.Config{})
+}
+
+// NewDisposableTokenLimiterWithRetry creates a token limiter, retrying the initial connection per retry
+func NewDisposableTokenLimiterWithRetry(addr, password string, db int, retryConfig retry.Config) (*DisposableTokenLimiter, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	ctx := context.Background()
+
+	err := retry.Connect(func() error {
+		return client.Ping(ctx).Err()
+	}, retryConfig)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis for disposable tokens: %w", err)
+	}
+
+	return &DisposableTokenLimiter{client: client, ctx: ctx}, nil
+}
+
+// NewDisposableToken generates a random token carrying DisposableTokenPrefix
+func NewDisposableToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return DisposableTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// IsDisposableToken reports whether an API key is a one-time token
+func IsDisposableToken(key string) bool {
+	return strings.HasPrefix(key, DisposableTokenPrefix)
+}
+
+// AddToken registers a token that Allow accepts once within ttl
+// Returns ErrTokenExists rather than resetting an existing token
+func (l *DisposableTokenLimiter) AddToken(token string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("token TTL must be positive")
+	}
+
+	added, err := l.client.SetNX(l.ctx, disposableTokenKeyPrefix+token, 1, ttl).Result()
+	if err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+	if !added {
+		return ErrTokenExists
+	}
+	return nil
+}
+
+// Allow consumes the token, returning true only the first time it's presented before expiring
+// Fails closed: a Redis error rejects the request
+func (l *DisposableTokenLimiter) Allow(token string) bool {
+	err := l.client.GetDel(l.ctx, disposableTokenKeyPrefix+token).Err()
+	return err == nil
+}
+
+// Close closes the Redis connection
+func (l *DisposableTokenLimiter) Close() error {
+	if l.client != nil {
+		return l.client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// setupDisposableTokenLimiter creates a token limiter backed by miniredis
+func setupDisposableTokenLimiter(t *testing.T) (*DisposableTokenLimiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	tokens, err := NewDisposableTokenLimiter(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to create token limiter: %v", err)
+	}
+	t.Cleanup(func() { tokens.Close() })
+
+	return tokens, mr
+}
+
+// TestDisposableTokenLimiter_SingleUse tests that a token is accepted exactly once
+func TestDisposableTokenLimiter_SingleUse(t *testing.T) {
+	tokens, _ := setupDisposableTokenLimiter(t)
+
+	if err := tokens.AddToken("one-time:abc", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !tokens.Allow("one-time:abc") {
+		t.Fatal("expected first use to be allowed")
+	}
+	if tokens.Allow("one-time:abc") {
+		t.Error("expected second use to be rejected")
+	}
+	if tokens.Allow("one-time:unknown") {
+		t.Error("expected unknown token to be rejected")
+	}
+}
+
+// TestDisposableTokenLimiter_Expired tests that a token is rejected once its TTL passes
+func TestDisposableTokenLimiter_Expired(t *testing.T) {
+	tokens, mr := setupDisposableTokenLimiter(t)
+
+	if err := tokens.AddToken("one-time:abc", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mr.FastForward(time.Minute + time.Second)
+
+	if tokens.Allow("one-time:abc") {
+		t.Error("expected expired token to be rejected")
+	}
+}
+
+// TestDisposableTokenLimiter_Concurrent tests that concurrent requests with one token get exactly one success
+func TestDisposableTokenLimiter_Concurrent(t *testing.T) {
+	tokens, _ := setupDisposableTokenLimiter(t)
+
+	const workers = 50
+	for round := 0; round < 5; round++ {
+		if err := tokens.AddToken("one-time:race", time.Minute); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+		start := make(chan struct{})
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if tokens.Allow("one-time:race") {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if allowed != 1 {
+			t.Fatalf("round %d: expected exactly 1 success, got %d", round, allowed)
+		}
+	}
+}
+
+// TestDisposableTokenLimiter_GetDel tests that Allow reads and deletes the key in a single GETDEL
+func TestDisposableTokenLimiter_GetDel(t *testing.T) {
+	tokens, mr := setupDisposableTokenLimiter(t)
+
+	if err := tokens.AddToken("one-time:abc", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mr.Exists(disposableTokenKeyPrefix + "one-time:abc") {
+		t.Fatal("expected token key to be stored")
+	}
+	if ttl := mr.TTL(disposableTokenKeyPrefix + "one-time:abc"); ttl != time.Minute {
+		t.Errorf("expected TTL 1m, got %v", ttl)
+	}
+
+	before := mr.CommandCount()
+	tokens.Allow("one-time:abc")
+
+	if got := mr.CommandCount() - before; got != 1 {
+		t.Errorf("expected Allow to send 1 command, got %d", got)
+	}
+	if mr.Exists(disposableTokenKeyPrefix + "one-time:abc") {
+		t.Error("expected token key to be deleted after use")
+	}
+}
+
+// TestDisposableTokenLimiter_AddToken_Errors tests duplicate tokens and invalid TTLs
+func TestDisposableTokenLimiter_AddToken_Errors(t *testing.T) {
+	tokens, _ := setupDisposableTokenLimiter(t)
+
+	if err := tokens.AddToken("one-time:abc", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tokens.AddToken("one-time:abc", time.Hour); !errors.Is(err, ErrTokenExists) {
+		t.Errorf("expected ErrTokenExists, got %v", err)
+	}
+	if err := tokens.AddToken("one-time:def", 0); err == nil {
+		t.Error("expected error for zero TTL, got nil")
+	}
+}
+
+// TestNewDisposableToken tests that generated tokens carry the prefix and are unique
+func TestNewDisposableToken(t *testing.T) {
+	first, err := NewDisposableToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := NewDisposableToken()
+
+	if !strings.HasPrefix(first, DisposableTokenPrefix) || !IsDisposableToken(first) {
+		t.Errorf("expected %q to carry the %q prefix", first, DisposableTokenPrefix)
+	}
+	if first == second {
+		t.Error("expected unique tokens")
+	}
+	if IsDisposableToken("s3cret") {
+		t.Error("expected a plain key not to be a disposable token")
+	}
+}
@@ -3,42 +3,51 @@ package limiter
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/ratelimit"
+	"github.com/evyataryagoni/ip2country/pkg/retry"
 )
 
 // LimiterConfig holds configuration for creating a rate limiter
+// Mirrors ratelimit.Config, with the datastore's retry settings so connection retries are logged the same way
 type LimiterConfig struct {
-	Type              string  // "memory" or "redis"
-	RequestsPerSecond float64 // Rate limit (can be fractional, e.g., 0.2 = 1 req per 5 sec)
+	Type              string        // "memory", "sliding", "leaky" or "redis"
+	RequestsPerSecond float64       // Rate limit (can be fractional, e.g., 0.2 = 1 req per 5 sec)
+	BurstSize         int           // Max requests allowed at once (0 = same as RequestsPerSecond; leaky: the queue size, 0 = 1)
+	Window            time.Duration // Window of the sliding limiter, allowed RequestsPerSecond * Window requests (0 = 1 second)
 
 	// Redis-specific config
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
+
+	// Retry controls retries of the initial Redis connection (zero value = single attempt)
+	Retry retry.Config
 }
 
 // NewLimiter creates a rate limiter based on the configuration (factory pattern)
+// Redis limiters connect through NewRedisLimiterWithRetry; everything else is ratelimit.New
 func NewLimiter(cfg LimiterConfig) (Limiter, error) {
-	limiterType := strings.ToLower(strings.TrimSpace(cfg.Type))
-
-	switch limiterType {
-	case "memory", "":
-		// In-memory rate limiter (good for single-server deployments)
-		return NewMemoryLimiter(cfg.RequestsPerSecond), nil
-
-	case "redis":
+	if strings.ToLower(strings.TrimSpace(cfg.Type)) == "redis" {
 		// Redis-based rate limiter (required for multi-server deployments)
-		limiter, err := NewRedisLimiter(
+		limiter, err := NewRedisLimiterWithRetry(
 			cfg.RedisAddr,
 			cfg.RedisPassword,
 			cfg.RedisDB,
 			cfg.RequestsPerSecond,
+			cfg.Retry,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Redis limiter: %w", err)
 		}
 		return limiter, nil
-
-	default:
-		return nil, fmt.Errorf("unknown rate limiter type: %s (supported: 'memory', 'redis')", cfg.Type)
 	}
+
+	return ratelimit.New(ratelimit.Config{
+		Type:              cfg.Type,
+		RequestsPerSecond: cfg.RequestsPerSecond,
+		BurstSize:         cfg.BurstSize,
+		Window:            cfg.Window,
+	})
 }
@@ -143,6 +143,39 @@ func TestMemoryLimiter_Close(t *testing.T) {
 	}
 }
 
+// TestMemoryLimiter_Reset tests that Reset restores a full bucket after exhaustion
+func TestMemoryLimiter_Reset(t *testing.T) {
+	limiter := NewMemoryLimiter(2)
+	defer limiter.Close()
+
+	ip := "192.168.1.1"
+	limiter.Allow(ip)
+	limiter.Allow(ip)
+	if limiter.Allow(ip) {
+		t.Fatal("expected limit to be exhausted")
+	}
+
+	if err := limiter.Reset(ip); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow(ip) {
+			t.Errorf("request %d after reset should be allowed", i+1)
+		}
+	}
+}
+
+// TestMemoryLimiter_Reset_UnknownIP tests that resetting an unseen IP is not an error
+func TestMemoryLimiter_Reset_UnknownIP(t *testing.T) {
+	limiter := NewMemoryLimiter(2)
+	defer limiter.Close()
+
+	if err := limiter.Reset("10.0.0.99"); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+}
+
 // TestLimiterInterface_MemoryLimiter tests that MemoryLimiter implements Limiter interface
 func TestLimiterInterface_MemoryLimiter(t *testing.T) {
 	var _ Limiter = (*MemoryLimiter)(nil)
@@ -212,6 +245,219 @@ func TestNewLimiter_InvalidType(t *testing.T) {
 	}
 }
 
+// TestMemoryLimiter_Burst tests that burst capacity is separate from the sustained rate
+func TestMemoryLimiter_Burst(t *testing.T) {
+	limiter := NewMemoryLimiterWithBurst(5, 20)
+	defer limiter.Close()
+
+	ip := "192.168.1.1"
+
+	// Full burst is available immediately
+	for i := 0; i < 20; i++ {
+		if !limiter.Allow(ip) {
+			t.Errorf("Request %d should be allowed within burst", i+1)
+		}
+	}
+	if limiter.Allow(ip) {
+		t.Error("Request 21 should be rate limited")
+	}
+
+	// After the burst, refill happens at the sustained rate (0.4s * 5/s = 2 tokens)
+	time.Sleep(400 * time.Millisecond)
+
+	allowedCount := 0
+	for i := 0; i < 10; i++ {
+		if limiter.Allow(ip) {
+			allowedCount++
+		}
+	}
+	if allowedCount < 1 || allowedCount > 3 {
+		t.Errorf("Expected ~2 allowed requests after 0.4s at 5 req/s, got %d", allowedCount)
+	}
+}
+
+// TestNewLimiter_Burst tests factory handling of BurstSize
+func TestNewLimiter_Burst(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             LimiterConfig
+		expectedAllowed int
+	}{
+		{
+			name:            "burst larger than rate",
+			cfg:             LimiterConfig{Type: "memory", RequestsPerSecond: 5, BurstSize: 20},
+			expectedAllowed: 20,
+		},
+		{
+			name:            "no burst behaves as before",
+			cfg:             LimiterConfig{Type: "memory", RequestsPerSecond: 5},
+			expectedAllowed: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := NewLimiter(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewLimiter() error = %v", err)
+			}
+			defer limiter.Close()
+
+			allowed := 0
+			for i := 0; i < tt.expectedAllowed+5; i++ {
+				if limiter.Allow("192.168.1.1") {
+					allowed++
+				}
+			}
+			if allowed != tt.expectedAllowed {
+				t.Errorf("expected %d immediate requests allowed, got %d", tt.expectedAllowed, allowed)
+			}
+		})
+	}
+}
+
+// TestNewLimiter_BurstSmallerThanRate tests that an invalid burst is rejected
+func TestNewLimiter_BurstSmallerThanRate(t *testing.T) {
+	_, err := NewLimiter(LimiterConfig{
+		Type:              "memory",
+		RequestsPerSecond: 10,
+		BurstSize:         5,
+	})
+
+	if err == nil {
+		t.Error("Expected error when burst is smaller than rate")
+	}
+}
+
+// TestLimiterInterface_SlidingWindowLimiter tests that SlidingWindowLimiter implements Limiter interface
+func TestLimiterInterface_SlidingWindowLimiter(t *testing.T) {
+	var _ Limiter = (*SlidingWindowLimiter)(nil)
+}
+
+// TestSlidingWindowLimiter_NoBurst tests that the sliding window never allows more than the limit in a window,
+// where the token bucket refills part of its allowance
+func TestSlidingWindowLimiter_NoBurst(t *testing.T) {
+	sliding := NewSlidingWindowLimiter(5, 500*time.Millisecond)
+	defer sliding.Close()
+	bucket := NewMemoryLimiterWithBurst(10, 5) // The same rate and limit: 5 per 500ms
+	defer bucket.Close()
+
+	ip := "192.168.1.1"
+	for i := 0; i < 5; i++ {
+		if !sliding.Allow(ip) || !bucket.Allow(ip) {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	if sliding.Allow(ip) {
+		t.Fatal("request 6 should be rate limited")
+	}
+	if bucket.Allow(ip) {
+		t.Fatal("request 6 should be rate limited by the token bucket too")
+	}
+
+	// Half a window later the token bucket has refilled half of its tokens; the window is still full
+	time.Sleep(250 * time.Millisecond)
+	if !bucket.Allow(ip) {
+		t.Error("expected the token bucket to have refilled")
+	}
+	for i := 0; i < 5; i++ {
+		if sliding.Allow(ip) {
+			t.Fatalf("request %d within the window should be rate limited", i+1)
+		}
+	}
+
+	// Once the window has passed, the full limit is available again, and no more
+	time.Sleep(300 * time.Millisecond)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if sliding.Allow(ip) {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("expected 5 requests allowed in the next window, got %d", allowed)
+	}
+}
+
+// TestNewLimiter_Sliding tests that the factory sizes the sliding window from the rate and the window
+func TestNewLimiter_Sliding(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{Type: "sliding", RequestsPerSecond: 5, Window: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	if _, ok := limiter.(*SlidingWindowLimiter); !ok {
+		t.Fatalf("expected a *SlidingWindowLimiter, got %T", limiter)
+	}
+	allowed := 0
+	for i := 0; i < 15; i++ {
+		if limiter.Allow("192.168.1.1") {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("expected 10 requests per 2s window, got %d", allowed)
+	}
+}
+
+// TestLimiterInterface_LeakyBucketLimiter tests that LeakyBucketLimiter implements Limiter interface
+func TestLimiterInterface_LeakyBucketLimiter(t *testing.T) {
+	var _ Limiter = (*LeakyBucketLimiter)(nil)
+}
+
+// TestLeakyBucketLimiter_SmoothsSpikes tests that a spike is let through at the drain rate,
+// where the token bucket lets a second's worth through at once
+func TestLeakyBucketLimiter_SmoothsSpikes(t *testing.T) {
+	leaky := NewLeakyBucketLimiter(20, 1)
+	defer leaky.Close()
+	bucket := NewMemoryLimiter(20)
+	defer bucket.Close()
+
+	ip := "192.168.1.1"
+	leakyAllowed, bucketAllowed := 0, 0
+	for i := 0; i < 20; i++ {
+		if leaky.Allow(ip) {
+			leakyAllowed++
+		}
+		if bucket.Allow(ip) {
+			bucketAllowed++
+		}
+	}
+	if leakyAllowed != 1 || bucketAllowed != 20 {
+		t.Errorf("expected 1 request through the leaky bucket and 20 through the token bucket, got %d and %d",
+			leakyAllowed, bucketAllowed)
+	}
+
+	// 1/rate later the next request drains through
+	time.Sleep(60 * time.Millisecond)
+	if !leaky.Allow(ip) {
+		t.Error("expected a request to be allowed after the queue drained")
+	}
+}
+
+// TestNewLimiter_Leaky tests that the factory uses BurstSize as the leaky bucket's queue size
+func TestNewLimiter_Leaky(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{Type: "leaky", RequestsPerSecond: 1, BurstSize: 3})
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	defer limiter.Close()
+
+	if _, ok := limiter.(*LeakyBucketLimiter); !ok {
+		t.Fatalf("expected a *LeakyBucketLimiter, got %T", limiter)
+	}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if limiter.Allow("192.168.1.1") {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected a queue of 3 requests, got %d", allowed)
+	}
+}
+
 // BenchmarkMemoryLimiter_Allow benchmarks the Allow method
 func BenchmarkMemoryLimiter_Allow(b *testing.B) {
 	limiter := NewMemoryLimiter(1000000) // High limit so we don't hit it
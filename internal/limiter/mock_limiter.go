@@ -4,13 +4,17 @@ package limiter
 // It allows tests to control allow/deny behavior and verify interactions
 type MockLimiter struct {
 	// Control behavior
-	AllowResult bool // If true, Allow() returns true; if false, returns false
+	AllowResult bool                       // If true, Allow() returns true; if false, returns false
+	ListResult  map[string]RateLimitStatus // Returned by List() (nil = an empty map)
 
 	// Track method calls for verification in tests
 	AllowCalls  []string // List of IPs that Allow() was called with
+	ResetCalls  []string // List of IPs that Reset() was called with
 	CloseCalled bool     // Whether Close() was called
 
 	// Control error scenarios
+	ResetError error // Error to return from Reset(), if any
+	ListError  error // Error to return from List(), if any
 	CloseError error // Error to return from Close(), if any
 }
 
@@ -21,6 +25,7 @@ func NewMockLimiter(allowResult bool) *MockLimiter {
 	return &MockLimiter{
 		AllowResult: allowResult,
 		AllowCalls:  []string{},
+		ResetCalls:  []string{},
 	}
 }
 
@@ -31,6 +36,25 @@ func (m *MockLimiter) Allow(ip string) bool {
 	return m.AllowResult
 }
 
+// Reset implements the Limiter interface
+// Tracks the call and returns configured error if any
+func (m *MockLimiter) Reset(ip string) error {
+	m.ResetCalls = append(m.ResetCalls, ip)
+	return m.ResetError
+}
+
+// List implements the Limiter interface
+// Returns the configured ListResult, or the configured error if any
+func (m *MockLimiter) List() (map[string]RateLimitStatus, error) {
+	if m.ListError != nil {
+		return nil, m.ListError
+	}
+	if m.ListResult == nil {
+		return map[string]RateLimitStatus{}, nil
+	}
+	return m.ListResult, nil
+}
+
 // Close implements the Limiter interface
 // Tracks that close was called and returns configured error if any
 func (m *MockLimiter) Close() error {
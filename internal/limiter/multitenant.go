@@ -0,0 +1,144 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/pkg/ratelimit"
+)
+
+// Unlimited as a tier's RequestsPerSecond exempts the tier from rate limiting (e.g. enterprise customers)
+const Unlimited float64 = -1
+
+// TierFunc returns the tier of a request, e.g. by looking up the tier of its API key
+// An empty or unknown tier falls back to the limiter's default tier
+type TierFunc func(r *http.Request) string
+
+// RequestLimiter is implemented by limiters whose limit depends on the request, not just the key
+// RateLimitMiddleware calls AllowRequest instead of Allow when the limiter implements it (see ratelimit.RequestLimiter)
+type RequestLimiter = ratelimit.RequestLimiter
+
+// MultiTenantLimiter applies a different rate limit to each customer tier
+// Every tier has its own limiter, so the same key is counted separately in each tier
+// and a busy tier never uses up another tier's allowance. Keys are prefixed with the tier,
+// so tiers backed by the same Redis don't share counters either
+type MultiTenantLimiter struct {
+	tiers       map[string]Limiter // nil = unlimited tier
+	defaultTier string
+	tierFn      TierFunc
+}
+
+// NewMultiTenantLimiter creates a limiter per tier with NewLimiter
+// defaultTier must be one of tiers; it applies to requests whose tier is empty or unknown
+// A nil tierFn puts every request in the default tier
+func NewMultiTenantLimiter(tiers map[string]LimiterConfig, defaultTier string, tierFn TierFunc) (*MultiTenantLimiter, error) {
+	if _, ok := tiers[defaultTier]; !ok {
+		return nil, fmt.Errorf("default tier %q is not configured", defaultTier)
+	}
+
+	l := &MultiTenantLimiter{
+		tiers:       make(map[string]Limiter, len(tiers)),
+		defaultTier: defaultTier,
+		tierFn:      tierFn,
+	}
+	for tier, cfg := range tiers {
+		if cfg.RequestsPerSecond == Unlimited {
+			l.tiers[tier] = nil
+			continue
+		}
+
+		tierLimiter, err := NewLimiter(cfg)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("tier %q: %w", tier, err)
+		}
+		l.tiers[tier] = tierLimiter
+	}
+	return l, nil
+}
+
+// AllowRequest checks key against the limit of r's tier
+// Implements the RequestLimiter interface
+func (l *MultiTenantLimiter) AllowRequest(r *http.Request, key string) bool {
+	tier := l.defaultTier
+	if l.tierFn != nil {
+		tier = l.tierFn(r)
+	}
+	return l.AllowTier(tier, key)
+}
+
+// AllowTier checks key against the limit of tier (the default tier if unknown)
+func (l *MultiTenantLimiter) AllowTier(tier, key string) bool {
+	if _, ok := l.tiers[tier]; !ok {
+		tier = l.defaultTier
+	}
+	tierLimiter := l.tiers[tier]
+	if tierLimiter == nil {
+		return true
+	}
+	return tierLimiter.Allow(tierKey(tier, key))
+}
+
+// tierKey namespaces key by tier in the tier's limiter
+func tierKey(tier, key string) string {
+	return tier + ":" + key
+}
+
+// Allow checks key against the default tier's limit
+// Implements the Limiter interface
+func (l *MultiTenantLimiter) Allow(key string) bool {
+	return l.AllowTier(l.defaultTier, key)
+}
+
+// Reset clears the state of key in every tier
+// Implements the Limiter interface
+func (l *MultiTenantLimiter) Reset(key string) error {
+	var errs []error
+	for tier, tierLimiter := range l.tiers {
+		if tierLimiter == nil {
+			continue
+		}
+		if err := tierLimiter.Reset(tierKey(tier, key)); err != nil {
+			errs = append(errs, fmt.Errorf("tier %q: %w", tier, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List merges the IPs tracked by every tier
+// A key seen by several tiers is reported with the state from the tier it used most recently
+// Implements the Limiter interface
+func (l *MultiTenantLimiter) List() (map[string]RateLimitStatus, error) {
+	statuses := make(map[string]RateLimitStatus)
+	for tier, tierLimiter := range l.tiers {
+		if tierLimiter == nil {
+			continue
+		}
+		tierStatuses, err := tierLimiter.List()
+		if err != nil {
+			return nil, fmt.Errorf("tier %q: %w", tier, err)
+		}
+		for key, status := range tierStatuses {
+			key = strings.TrimPrefix(key, tierKey(tier, ""))
+			status.IP = key
+			if existing, ok := statuses[key]; !ok || status.LastAccessedAt.After(existing.LastAccessedAt) {
+				statuses[key] = status
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// Close closes every tier's limiter
+// Implements the Limiter interface
+func (l *MultiTenantLimiter) Close() error {
+	var errs []error
+	for _, tierLimiter := range l.tiers {
+		if tierLimiter != nil {
+			errs = append(errs, tierLimiter.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,206 @@
+package limiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testTiers returns a free, a pro and an unlimited enterprise tier
+func testTiers() map[string]LimiterConfig {
+	return map[string]LimiterConfig{
+		"free":       {Type: "memory", RequestsPerSecond: 1},
+		"pro":        {Type: "memory", RequestsPerSecond: 5},
+		"enterprise": {RequestsPerSecond: Unlimited},
+	}
+}
+
+// tierHeader reads the tier from the X-Tier header (standing in for an API key lookup)
+func tierHeader(r *http.Request) string {
+	return r.Header.Get("X-Tier")
+}
+
+// allowedCount returns how many of n requests in tier are allowed for key
+func allowedCount(l *MultiTenantLimiter, tier, key string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if l.AllowTier(tier, key) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// TestMultiTenantLimiter_PerTierLimits tests that each tier enforces its own limit
+func TestMultiTenantLimiter_PerTierLimits(t *testing.T) {
+	limiter, err := NewMultiTenantLimiter(testTiers(), "free", tierHeader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	tests := []struct {
+		tier     string
+		expected int
+	}{
+		{"free", 1},
+		{"pro", 5},
+		{"enterprise", 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tier, func(t *testing.T) {
+			if got := allowedCount(limiter, tt.tier, "key-"+tt.tier, 100); got != tt.expected {
+				t.Errorf("expected %d of 100 requests allowed, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestMultiTenantLimiter_UnknownTier tests that an empty or unknown tier gets the default tier's limit
+func TestMultiTenantLimiter_UnknownTier(t *testing.T) {
+	limiter, err := NewMultiTenantLimiter(testTiers(), "free", tierHeader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	for _, tier := range []string{"", "platinum"} {
+		if got := allowedCount(limiter, tier, "key-"+tier, 10); got != 1 {
+			t.Errorf("tier %q: expected the free limit (1), got %d allowed", tier, got)
+		}
+	}
+}
+
+// TestMultiTenantLimiter_TiersIsolated tests that exhausting one tier doesn't affect another
+func TestMultiTenantLimiter_TiersIsolated(t *testing.T) {
+	limiter, err := NewMultiTenantLimiter(testTiers(), "free", tierHeader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	key := "192.168.1.1"
+	allowedCount(limiter, "free", key, 10)
+
+	if got := allowedCount(limiter, "pro", key, 10); got != 5 {
+		t.Errorf("expected the pro tier's full allowance (5) after exhausting free, got %d", got)
+	}
+}
+
+// TestMultiTenantLimiter_AllowRequest tests that the tier is read from the request
+func TestMultiTenantLimiter_AllowRequest(t *testing.T) {
+	limiter, err := NewMultiTenantLimiter(testTiers(), "free", tierHeader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country", nil)
+	req.Header.Set("X-Tier", "pro")
+
+	for i := 0; i < 5; i++ {
+		if !limiter.AllowRequest(req, "192.168.1.1") {
+			t.Errorf("request %d should be allowed in the pro tier", i+1)
+		}
+	}
+	if limiter.AllowRequest(req, "192.168.1.1") {
+		t.Error("6th request should be rate limited in the pro tier")
+	}
+}
+
+// TestMultiTenantLimiter_Reset tests that Reset clears the key in every tier
+func TestMultiTenantLimiter_Reset(t *testing.T) {
+	limiter, err := NewMultiTenantLimiter(testTiers(), "free", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	key := "192.168.1.1"
+	allowedCount(limiter, "free", key, 10)
+	allowedCount(limiter, "pro", key, 10)
+
+	if err := limiter.Reset(key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !limiter.Allow(key) {
+		t.Error("expected the free tier to allow the key after Reset")
+	}
+	if !limiter.AllowTier("pro", key) {
+		t.Error("expected the pro tier to allow the key after Reset")
+	}
+}
+
+// TestMultiTenantLimiter_List tests that List merges the keys of every tier, reporting the most recently used tier's state
+func TestMultiTenantLimiter_List(t *testing.T) {
+	limiter, err := NewMultiTenantLimiter(testTiers(), "free", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	allowedCount(limiter, "free", "192.168.1.1", 1)
+	allowedCount(limiter, "free", "192.168.1.2", 1)
+	time.Sleep(time.Millisecond)
+	allowedCount(limiter, "pro", "192.168.1.2", 2)
+	allowedCount(limiter, "enterprise", "192.168.1.3", 1) // Unlimited: nothing tracked
+
+	statuses, err := limiter.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 keys, got %v", statuses)
+	}
+	if statuses["192.168.1.1"].Allowed {
+		t.Errorf("expected 192.168.1.1 to have used up the free tier, got %+v", statuses["192.168.1.1"])
+	}
+	if status := statuses["192.168.1.2"]; status.TokensRemaining < 2.9 || status.TokensRemaining > 3.1 {
+		t.Errorf("expected 192.168.1.2 to report its pro tier state (about 3 tokens), got %+v", status)
+	}
+}
+
+// TestNewMultiTenantLimiter_Errors tests invalid tier configurations
+func TestNewMultiTenantLimiter_Errors(t *testing.T) {
+	if _, err := NewMultiTenantLimiter(testTiers(), "missing", nil); err == nil {
+		t.Error("expected error for a default tier that isn't configured")
+	}
+
+	tiers := testTiers()
+	tiers["broken"] = LimiterConfig{Type: "invalid", RequestsPerSecond: 1}
+	if _, err := NewMultiTenantLimiter(tiers, "free", nil); err == nil {
+		t.Error("expected error for a tier with an invalid limiter type")
+	}
+}
+
+// TestLimiterInterface_MultiTenantLimiter tests that MultiTenantLimiter implements Limiter and RequestLimiter
+func TestLimiterInterface_MultiTenantLimiter(t *testing.T) {
+	var _ Limiter = (*MultiTenantLimiter)(nil)
+	var _ RequestLimiter = (*MultiTenantLimiter)(nil)
+}
+
+// TestMultiTenantLimiter_SharedBackend tests that tiers don't share counters when their limiters share one Redis
+func TestMultiTenantLimiter_SharedBackend(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tiers := map[string]LimiterConfig{
+		"free": {Type: "redis", RequestsPerSecond: 1, RedisAddr: mr.Addr()},
+		"pro":  {Type: "redis", RequestsPerSecond: 5, RedisAddr: mr.Addr()},
+	}
+	limiter, err := NewMultiTenantLimiter(tiers, "free", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	if got := allowedCount(limiter, "free", "192.168.1.1", 10); got != 1 {
+		t.Errorf("expected the free limit (1), got %d allowed", got)
+	}
+	if got := allowedCount(limiter, "pro", "192.168.1.1", 10); got != 5 {
+		t.Errorf("expected the pro limit (5) despite the free tier being used up, got %d allowed", got)
+	}
+}
@@ -0,0 +1,48 @@
+package limiter
+
+import "testing"
+
+// TestRedisLimiter_Reset tests that Reset lets an exhausted IP through again
+func TestRedisLimiter_Reset(t *testing.T) {
+	limiter, mr := NewTestRedisLimiter(t, 2)
+
+	ip := "192.168.1.1"
+	limiter.Allow(ip)
+	limiter.Allow(ip)
+	if limiter.Allow(ip) {
+		t.Fatal("expected limit to be exhausted")
+	}
+
+	// Another IP's counters must survive the reset
+	limiter.Allow("10.0.0.1")
+
+	if err := limiter.Reset(ip); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !limiter.Allow(ip) {
+		t.Error("expected request after reset to be allowed")
+	}
+	if keys := mr.Keys(); len(keys) != 2 {
+		t.Errorf("expected 2 counters (reset IP + other IP), got %v", keys)
+	}
+}
+
+// TestRedisLimiter_Reset_UnknownIP tests that resetting an unseen IP is not an error
+func TestRedisLimiter_Reset_UnknownIP(t *testing.T) {
+	limiter, _ := NewTestRedisLimiter(t, 2)
+
+	if err := limiter.Reset("10.0.0.99"); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+}
+
+// TestRedisLimiter_Reset_ServerDown tests that Redis errors are reported
+func TestRedisLimiter_Reset_ServerDown(t *testing.T) {
+	limiter, mr := NewTestRedisLimiter(t, 2)
+	mr.Close()
+
+	if err := limiter.Reset("192.168.1.1"); err == nil {
+		t.Error("expected error when Redis is down")
+	}
+}
@@ -0,0 +1,182 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/retry"
+)
+
+// ReloadableLimiter rebuilds its underlying limiter whenever its configuration changes
+// The configuration is re-read on every request through configFn, so rate limit
+// changes picked up by a config reload apply without restarting the server
+type ReloadableLimiter struct {
+	configFn func() LimiterConfig
+	build    func(LimiterConfig) (Limiter, error) // NewLimiter, replaced in tests
+
+	mu            sync.RWMutex
+	current       *limiterGeneration
+	currentConfig LimiterConfig
+
+	// rebuilding is held by the one request building the next limiter; the others keep using the current one
+	rebuilding sync.Mutex
+}
+
+// limiterGeneration is one limiter built by a ReloadableLimiter, with the calls still using it
+// A replaced limiter is closed only once they have all returned
+type limiterGeneration struct {
+	limiter  Limiter
+	inFlight sync.WaitGroup
+}
+
+// NewReloadableLimiter creates a limiter from the current configuration
+// Returns an error if the initial configuration is invalid
+func NewReloadableLimiter(configFn func() LimiterConfig) (*ReloadableLimiter, error) {
+	return newReloadableLimiter(configFn, NewLimiter)
+}
+
+// newReloadableLimiter creates a ReloadableLimiter building its limiters with build
+func newReloadableLimiter(configFn func() LimiterConfig, build func(LimiterConfig) (Limiter, error)) (*ReloadableLimiter, error) {
+	cfg := configFn()
+	current, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReloadableLimiter{
+		configFn:      configFn,
+		build:         build,
+		current:       &limiterGeneration{limiter: current},
+		currentConfig: cfg,
+	}, nil
+}
+
+// acquire returns the current limiter, which stays open until release is called
+func (rl *ReloadableLimiter) acquire() (*limiterGeneration, LimiterConfig) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	rl.current.inFlight.Add(1)
+	return rl.current, rl.currentConfig
+}
+
+// release ends a call started with acquire
+func (g *limiterGeneration) release() {
+	g.inFlight.Done()
+}
+
+// Allow checks the request against the limiter built from the latest configuration
+// Rebuilding resets all per-IP state, which is acceptable for an operator-triggered change
+func (rl *ReloadableLimiter) Allow(ip string) bool {
+	cfg := rl.configFn()
+
+	current, currentConfig := rl.acquire()
+	if cfg != currentConfig {
+		current.release()
+		rl.rebuild(cfg)
+		current, _ = rl.acquire()
+	}
+	defer current.release()
+
+	return current.limiter.Allow(ip)
+}
+
+// rebuild replaces the limiter with one built from cfg
+// Building may dial Redis, so it runs without holding mu: requests arriving meanwhile, including
+// ones that also saw the new configuration, use the current limiter instead of waiting for it.
+// The replaced limiter is closed in the background once the calls still using it have returned
+func (rl *ReloadableLimiter) rebuild(cfg LimiterConfig) {
+	if !rl.rebuilding.TryLock() {
+		return
+	}
+	defer rl.rebuilding.Unlock()
+
+	rl.mu.RLock()
+	changed := cfg != rl.currentConfig
+	rl.mu.RUnlock()
+	if !changed {
+		return // Another request rebuilt while we waited
+	}
+
+	// Never retry connections here - this runs on the request path
+	buildCfg := cfg
+	buildCfg.Retry = retry.Config{}
+	next, err := rl.build(buildCfg)
+
+	rl.mu.Lock()
+	old := rl.current
+	if err == nil {
+		rl.current = &limiterGeneration{limiter: next}
+	}
+	// On error keep the previous limiter, and remember the config
+	// so we don't retry a broken build on every request
+	rl.currentConfig = cfg
+	rl.mu.Unlock()
+
+	if err == nil {
+		go func() {
+			old.inFlight.Wait()
+			old.limiter.Close()
+		}()
+	}
+}
+
+// Reset clears the IP's state in the current underlying limiter
+func (rl *ReloadableLimiter) Reset(ip string) error {
+	current, _ := rl.acquire()
+	defer current.release()
+	return current.limiter.Reset(ip)
+}
+
+// List lists the IPs tracked by the current underlying limiter
+// A rebuild after a config change starts with an empty list
+func (rl *ReloadableLimiter) List() (map[string]RateLimitStatus, error) {
+	current, _ := rl.acquire()
+	defer current.release()
+	return current.limiter.List()
+}
+
+// Limit returns the current underlying limiter's limit, 0 if it isn't inspectable
+// Implements the InspectableLimiter interface
+func (rl *ReloadableLimiter) Limit() int {
+	current, _ := rl.acquire()
+	defer current.release()
+	if inspectable, ok := current.limiter.(InspectableLimiter); ok {
+		return inspectable.Limit()
+	}
+	return 0
+}
+
+// Remaining returns the requests the IP has left in the current underlying limiter
+// Implements the InspectableLimiter interface
+func (rl *ReloadableLimiter) Remaining(ip string) int {
+	current, _ := rl.acquire()
+	defer current.release()
+	if inspectable, ok := current.limiter.(InspectableLimiter); ok {
+		return inspectable.Remaining(ip)
+	}
+	return 0
+}
+
+// ResetAt returns when the IP's allowance resets in the current underlying limiter
+// Implements the InspectableLimiter interface
+func (rl *ReloadableLimiter) ResetAt(ip string) time.Time {
+	current, _ := rl.acquire()
+	defer current.release()
+	if inspectable, ok := current.limiter.(InspectableLimiter); ok {
+		return inspectable.ResetAt(ip)
+	}
+	return time.Now()
+}
+
+// Close waits for a rebuild in progress and the calls using the current underlying limiter, then closes it
+func (rl *ReloadableLimiter) Close() error {
+	rl.rebuilding.Lock()
+	defer rl.rebuilding.Unlock()
+
+	rl.mu.RLock()
+	current := rl.current
+	rl.mu.RUnlock()
+
+	current.inFlight.Wait()
+	return current.limiter.Close()
+}
@@ -0,0 +1,224 @@
+package limiter
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReloadableLimiter_RebuildsOnConfigChange tests that a new rate applies immediately
+func TestReloadableLimiter_RebuildsOnConfigChange(t *testing.T) {
+	var mu sync.Mutex
+	cfg := LimiterConfig{Type: "memory", RequestsPerSecond: 1}
+	configFn := func() LimiterConfig {
+		mu.Lock()
+		defer mu.Unlock()
+		return cfg
+	}
+
+	limiter, err := NewReloadableLimiter(configFn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	ip := "192.168.1.1"
+	if !limiter.Allow(ip) {
+		t.Error("first request should be allowed")
+	}
+	if limiter.Allow(ip) {
+		t.Error("second request should be rate limited at 1 req/s")
+	}
+
+	// Raise the limit
+	mu.Lock()
+	cfg.RequestsPerSecond = 5
+	mu.Unlock()
+
+	for i := 0; i < 5; i++ {
+		if !limiter.Allow(ip) {
+			t.Errorf("request %d should be allowed after raising the limit", i+1)
+		}
+	}
+	if limiter.Allow(ip) {
+		t.Error("6th request should be rate limited at 5 req/s")
+	}
+}
+
+// TestReloadableLimiter_InvalidConfig tests construction and reload with a bad type
+func TestReloadableLimiter_InvalidConfig(t *testing.T) {
+	_, err := NewReloadableLimiter(func() LimiterConfig {
+		return LimiterConfig{Type: "invalid", RequestsPerSecond: 1}
+	})
+	if err == nil {
+		t.Error("expected error for invalid initial config")
+	}
+
+	cfg := LimiterConfig{Type: "memory", RequestsPerSecond: 1}
+	limiter, err := NewReloadableLimiter(func() LimiterConfig { return cfg })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	limiter.Allow("192.168.1.1")
+
+	// A broken reload keeps the previous limiter (and its state)
+	cfg.Type = "invalid"
+	if limiter.Allow("192.168.1.1") {
+		t.Error("expected previous limiter to still be enforcing the limit")
+	}
+}
+
+// TestLimiterInterface_ReloadableLimiter tests that ReloadableLimiter implements Limiter interface
+func TestLimiterInterface_ReloadableLimiter(t *testing.T) {
+	var _ Limiter = (*ReloadableLimiter)(nil)
+	var _ InspectableLimiter = (*ReloadableLimiter)(nil)
+}
+
+// TestReloadableLimiter_Inspect tests that the allowance is read from the current underlying limiter
+func TestReloadableLimiter_Inspect(t *testing.T) {
+	cfg := LimiterConfig{Type: "memory", RequestsPerSecond: 5}
+	limiter, err := NewReloadableLimiter(func() LimiterConfig { return cfg })
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer limiter.Close()
+
+	limiter.Allow("192.168.1.1")
+	if limit, remaining := limiter.Limit(), limiter.Remaining("192.168.1.1"); limit != 5 || remaining != 4 {
+		t.Errorf("expected 4 of 5 requests remaining, got %d of %d", remaining, limit)
+	}
+
+	cfg.RequestsPerSecond = 10
+	limiter.Allow("192.168.1.1") // Rebuilds
+	if limit, remaining := limiter.Limit(), limiter.Remaining("192.168.1.1"); limit != 10 || remaining != 9 {
+		t.Errorf("expected 9 of 10 requests remaining after the rebuild, got %d of %d", remaining, limit)
+	}
+}
+
+// gatedLimiter allows every request once gate is closed, and records Close
+type gatedLimiter struct {
+	gate    chan struct{}
+	entered chan struct{} // Signalled when Allow starts waiting (nil = not signalled)
+	closed  atomic.Bool
+}
+
+func (l *gatedLimiter) Allow(ip string) bool {
+	if l.entered != nil {
+		l.entered <- struct{}{}
+	}
+	<-l.gate
+	return true
+}
+
+func (l *gatedLimiter) Reset(ip string) error { return nil }
+
+func (l *gatedLimiter) List() (map[string]RateLimitStatus, error) {
+	return map[string]RateLimitStatus{}, nil
+}
+
+func (l *gatedLimiter) Close() error {
+	l.closed.Store(true)
+	return nil
+}
+
+// TestReloadableLimiter_BuildDoesNotBlock tests that requests keep using the current limiter while the next one is built
+func TestReloadableLimiter_BuildDoesNotBlock(t *testing.T) {
+	var rate atomic.Int64
+	rate.Store(1)
+	configFn := func() LimiterConfig {
+		return LimiterConfig{Type: "memory", RequestsPerSecond: float64(rate.Load())}
+	}
+
+	building := make(chan struct{})
+	release := make(chan struct{})
+	limiter, err := newReloadableLimiter(configFn, func(cfg LimiterConfig) (Limiter, error) {
+		if cfg.RequestsPerSecond == 2 {
+			close(building)
+			<-release // A slow Redis dial
+		}
+		return NewLimiter(cfg)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	rate.Store(2)
+	builder := make(chan struct{})
+	go func() {
+		limiter.Allow("192.168.1.1")
+		close(builder)
+	}()
+	<-building
+
+	other := make(chan bool)
+	go func() { other <- limiter.Allow("192.168.1.2") }()
+	select {
+	case allowed := <-other:
+		if !allowed {
+			t.Error("expected the current limiter to allow the request")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected requests not to wait for the rebuild")
+	}
+
+	close(release)
+	<-builder
+	if limit := limiter.Limit(); limit != 2 {
+		t.Errorf("expected the rebuilt limit of 2, got %d", limit)
+	}
+}
+
+// TestReloadableLimiter_ClosesAfterInFlight tests that a replaced limiter is closed only once the calls using it return
+func TestReloadableLimiter_ClosesAfterInFlight(t *testing.T) {
+	var rate atomic.Int64
+	rate.Store(1)
+	configFn := func() LimiterConfig {
+		return LimiterConfig{Type: "memory", RequestsPerSecond: float64(rate.Load())}
+	}
+
+	open := make(chan struct{})
+	close(open)
+	old := &gatedLimiter{gate: make(chan struct{}), entered: make(chan struct{}, 1)}
+	next := &gatedLimiter{gate: open}
+	built := []Limiter{old, next}
+	limiter, err := newReloadableLimiter(configFn, func(cfg LimiterConfig) (Limiter, error) {
+		l := built[0]
+		built = built[1:]
+		return l, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inFlight := make(chan bool)
+	go func() { inFlight <- limiter.Allow("192.168.1.1") }()
+	<-old.entered
+
+	rate.Store(2)
+	if !limiter.Allow("192.168.1.2") {
+		t.Error("expected the rebuilt limiter to allow the request")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if old.closed.Load() {
+		t.Fatal("expected the old limiter to stay open while a call is using it")
+	}
+
+	close(old.gate)
+	<-inFlight
+	deadline := time.Now().Add(time.Second)
+	for !old.closed.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !old.closed.Load() {
+		t.Error("expected the old limiter to be closed once the call returned")
+	}
+
+	limiter.Close()
+	if !next.closed.Load() {
+		t.Error("expected Close to close the current limiter")
+	}
+}
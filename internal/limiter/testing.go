@@ -0,0 +1,27 @@
+package limiter
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// NewTestRedisLimiter creates a real RedisLimiter backed by an in-process miniredis server
+// For tests outside this package; the server and limiter are closed when the test ends
+func NewTestRedisLimiter(t *testing.T, requestsPerSecond float64) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.NewMiniRedis()
+	if err := mr.Start(); err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	limiter, err := NewRedisLimiter(mr.Addr(), "", 0, requestsPerSecond)
+	if err != nil {
+		t.Fatalf("failed to create Redis limiter: %v", err)
+	}
+	t.Cleanup(func() { limiter.Close() })
+
+	return limiter, mr
+}
@@ -19,6 +19,9 @@ type Config struct {
 	Level      string // debug, info, warn, error
 	Pretty     bool   // Enable pretty console output
 	OutputFile string // Optional file output path
+
+	// Ring, if set, also receives every log line, as JSON even when Pretty is set (see RingLogger)
+	Ring *RingLogger
 }
 
 // New creates a new logger with the given configuration
@@ -50,6 +53,11 @@ func New(cfg Config) *Logger {
 		}
 	}
 
+	// In-memory copy of the recent lines (optional)
+	if cfg.Ring != nil {
+		output = io.MultiWriter(output, cfg.Ring)
+	}
+
 	// Create logger
 	logger := zerolog.New(output).
 		With().
@@ -60,6 +68,16 @@ func New(cfg Config) *Logger {
 	return &Logger{Logger: &logger}
 }
 
+// SetLevel changes the global log level at runtime (e.g. after a config reload)
+// Unknown levels fall back to info, matching New
+func SetLevel(level string) {
+	parsed, err := zerolog.ParseLevel(level)
+	if err != nil {
+		parsed = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(parsed)
+}
+
 // NewDefault creates a logger with default settings
 func NewDefault() *Logger {
 	return New(Config{
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+)
+
+// DefaultRingSize is how many lines a RingLogger keeps when created with a size <= 0
+const DefaultRingSize = 1000
+
+// RingLogger is an io.Writer keeping the last lines written to it in memory
+// Added as a log output (see Config.Ring), it lets operators read recent logs over HTTP (GET /debug/logs)
+// without access to the server's stdout
+//
+// Lines are kept in a circular buffer: once it's full, each new line replaces the oldest
+type RingLogger struct {
+	mu    sync.Mutex
+	lines []string
+	next  int // Total lines ever written; the next goes to lines[next % len(lines)]
+}
+
+// NewRingLogger creates a ring of the last size lines
+// A size <= 0 uses DefaultRingSize
+func NewRingLogger(size int) *RingLogger {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &RingLogger{lines: make([]string, size)}
+}
+
+// Write adds each non-empty line of p to the ring
+// zerolog writes one event per call, so a call is usually exactly one line
+func (r *RingLogger) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for line := range bytes.SplitSeq(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		r.lines[r.next%len(r.lines)] = string(line)
+		r.next++
+	}
+	return len(p), nil
+}
+
+// Lines returns the last n lines, oldest first
+// n <= 0, or more than the ring holds, returns every line held
+func (r *RingLogger) Lines(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	held := min(r.next, len(r.lines))
+	if n <= 0 || n > held {
+		n = held
+	}
+
+	lines := make([]string, n)
+	for i := range n {
+		lines[i] = r.lines[(r.next-n+i)%len(r.lines)]
+	}
+	return lines
+}
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// TestRingLogger_Lines tests that every line logged is kept, in order, while the ring isn't full
+func TestRingLogger_Lines(t *testing.T) {
+	ring := NewRingLogger(10)
+	zl := zerolog.New(ring)
+	for i := range 5 {
+		zl.Info().Int("n", i).Msg("entry")
+	}
+
+	lines := ring.Lines(0)
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d: %v", len(lines), lines)
+	}
+	for i, line := range lines {
+		if expected := fmt.Sprintf(`"n":%d`, i); !strings.Contains(line, expected) {
+			t.Errorf("line %d: expected %s, got %s", i, expected, line)
+		}
+	}
+	if got := ring.Lines(2); len(got) != 2 || got[0] != lines[3] || got[1] != lines[4] {
+		t.Errorf("expected the last 2 lines, got %v", got)
+	}
+}
+
+// TestRingLogger_Evicts tests that once the ring is full, each new line replaces the oldest
+func TestRingLogger_Evicts(t *testing.T) {
+	ring := NewRingLogger(3)
+	for i := range 4 {
+		fmt.Fprintf(ring, "line %d\n", i)
+	}
+
+	expected := []string{"line 1", "line 2", "line 3"}
+	if got := ring.Lines(10); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+// TestRingLogger_SplitsLines tests that a write of several lines adds each, and empty lines are skipped
+func TestRingLogger_SplitsLines(t *testing.T) {
+	ring := NewRingLogger(5)
+	ring.Write([]byte("first\n\nsecond\nthird"))
+
+	if got := ring.Lines(0); strings.Join(got, ",") != "first,second,third" {
+		t.Errorf("expected [first second third], got %v", got)
+	}
+	if got := NewRingLogger(0).lines; len(got) != DefaultRingSize {
+		t.Errorf("expected a size of %d by default, got %d", DefaultRingSize, len(got))
+	}
+}
+
+// TestRingLogger_ConcurrentWrites tests that concurrent writers and readers don't race or lose lines (run with -race)
+func TestRingLogger_ConcurrentWrites(t *testing.T) {
+	ring := NewRingLogger(1000)
+	zl := zerolog.New(ring)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				zl.Info().Msg("entry")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				ring.Lines(10)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(ring.Lines(0)); got != 500 {
+		t.Errorf("expected 500 lines, got %d", got)
+	}
+}
+
+// TestNew_Ring tests that a logger with a Ring writes JSON lines to it, even with pretty output
+func TestNew_Ring(t *testing.T) {
+	ring := NewRingLogger(10)
+	log := New(Config{Level: "info", Pretty: true, Ring: ring})
+	log.Info().Msg("started")
+
+	lines := ring.Lines(0)
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "{") || !strings.Contains(lines[0], `"message":"started"`) {
+		t.Errorf("expected the JSON event, got %v", lines)
+	}
+}
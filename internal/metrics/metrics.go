@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	pkgmetrics "github.com/evyataryagoni/ip2country/pkg/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -17,55 +18,66 @@ type Metrics struct {
 	DatastoreQueriesTotal    *prometheus.CounterVec
 	DatastoreQueryDuration   *prometheus.HistogramVec
 	DatastoreCacheHits       *prometheus.CounterVec
+	DatastoreCacheEntries    prometheus.Gauge
+	DatastoreCacheEvictions  prometheus.Gauge
 	DatastoreConnectionsOpen prometheus.Gauge
+	StoreWarmupDuration      prometheus.Gauge
+	ShadowDiscrepancies      prometheus.Counter
+	StaleServesTotal         prometheus.Counter
+	WeightedDiscrepancies    prometheus.Counter
+	StoreDrift               *prometheus.GaugeVec
 
 	// Application Metrics
-	IPLookupsTotal    *prometheus.CounterVec
-	IPLookupsNotFound prometheus.Counter
-	IPLookupsErrors   *prometheus.CounterVec
+	IPLookupsErrors *prometheus.CounterVec
+
+	// Load Shedding Metrics
+	BackpressureRejections   prometheus.Counter
+	AdaptiveRateLimitCurrent prometheus.Gauge
+
+	// Security Metrics
+	BlocklistRejections prometheus.Counter
+
+	// Prefetch Metrics
+	PrefetchTotal     prometheus.Counter
+	PrefetchCacheHits prometheus.Counter
+
+	// Private IP Metrics
+	PrivateIPSkips prometheus.Counter
+
+	// Analytics Metrics
+	UniqueIPsToday prometheus.Gauge
 }
 
-// New creates and registers all Prometheus metrics
+// HTTP returns the HTTP metrics as recorded by the pkg/middleware MetricsMiddleware
+func (m *Metrics) HTTP() *pkgmetrics.HTTPMetrics {
+	return &pkgmetrics.HTTPMetrics{
+		RequestsTotal:   m.HTTPRequestsTotal,
+		RequestDuration: m.HTTPRequestDuration,
+		RequestSize:     m.HTTPRequestSize,
+		ResponseSize:    m.HTTPResponseSize,
+	}
+}
+
+// New creates all Prometheus metrics and registers them with the default registry
 func New() *Metrics {
-	return &Metrics{
-		// HTTP Metrics
-		HTTPRequestsTotal: promauto.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "http_requests_total",
-				Help: "Total number of HTTP requests",
-			},
-			[]string{"method", "endpoint", "status"},
-		),
+	return NewWithRegistry(prometheus.DefaultRegisterer)
+}
 
-		HTTPRequestDuration: promauto.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "http_request_duration_seconds",
-				Help:    "HTTP request latency in seconds",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"method", "endpoint", "status"},
-		),
+// NewWithRegistry creates all Prometheus metrics and registers them with reg
+// Tests pass a fresh prometheus.NewRegistry() so repeated setups don't collide
+func NewWithRegistry(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+	httpMetrics := pkgmetrics.NewHTTPMetrics(reg)
 
-		HTTPRequestSize: promauto.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "http_request_size_bytes",
-				Help:    "HTTP request size in bytes",
-				Buckets: prometheus.ExponentialBuckets(100, 10, 7),
-			},
-			[]string{"method", "endpoint"},
-		),
-
-		HTTPResponseSize: promauto.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "http_response_size_bytes",
-				Help:    "HTTP response size in bytes",
-				Buckets: prometheus.ExponentialBuckets(100, 10, 7),
-			},
-			[]string{"method", "endpoint", "status"},
-		),
+	return &Metrics{
+		// HTTP Metrics (defined in pkg/metrics, so other services export the same series)
+		HTTPRequestsTotal:   httpMetrics.RequestsTotal,
+		HTTPRequestDuration: httpMetrics.RequestDuration,
+		HTTPRequestSize:     httpMetrics.RequestSize,
+		HTTPResponseSize:    httpMetrics.ResponseSize,
 
 		// Datastore Metrics
-		DatastoreQueriesTotal: promauto.NewCounterVec(
+		DatastoreQueriesTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "datastore_queries_total",
 				Help: "Total number of datastore queries",
@@ -73,7 +85,7 @@ func New() *Metrics {
 			[]string{"datastore", "operation", "status"},
 		),
 
-		DatastoreQueryDuration: promauto.NewHistogramVec(
+		DatastoreQueryDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "datastore_query_duration_seconds",
 				Help:    "Datastore query latency in seconds",
@@ -82,7 +94,7 @@ func New() *Metrics {
 			[]string{"datastore", "operation"},
 		),
 
-		DatastoreCacheHits: promauto.NewCounterVec(
+		DatastoreCacheHits: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "datastore_cache_hits_total",
 				Help: "Total number of cache hits vs misses",
@@ -90,35 +102,122 @@ func New() *Metrics {
 			[]string{"datastore", "result"},
 		),
 
-		DatastoreConnectionsOpen: promauto.NewGauge(
+		DatastoreCacheEntries: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "datastore_cache_entries",
+				Help: "Number of lookups held in the in-process datastore cache (CACHE_SIZE)",
+			},
+		),
+
+		DatastoreCacheEvictions: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "datastore_cache_evictions",
+				Help: "Number of lookups evicted from the full in-process datastore cache since startup",
+			},
+		),
+
+		DatastoreConnectionsOpen: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "datastore_connections_open",
 				Help: "Number of open datastore connections",
 			},
 		),
 
-		// Application Metrics
-		IPLookupsTotal: promauto.NewCounterVec(
+		StoreWarmupDuration: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "store_warmup_duration_seconds",
+				Help: "Time spent warming up the datastore at startup",
+			},
+		),
+
+		ShadowDiscrepancies: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "shadow_discrepancy_total",
+				Help: "Total number of sampled lookups where the shadow datastore disagreed with the primary",
+			},
+		),
+
+		WeightedDiscrepancies: factory.NewCounter(
 			prometheus.CounterOpts{
-				Name: "ip_lookups_total",
-				Help: "Total number of IP lookups",
+				Name: "weighted_store_discrepancy_total",
+				Help: "Total number of verified lookups where another weighted datastore disagreed with the selected one",
+			},
+		),
+
+		StoreDrift: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "store_drift_total",
+				Help: "Records differing between the Redis datastore and its CSV file at the last comparison",
 			},
-			[]string{"result"},
+			[]string{"type"}, // "missing" (from Redis), "extra" (in Redis only) or "mismatch"
 		),
 
-		IPLookupsNotFound: promauto.NewCounter(
+		StaleServesTotal: factory.NewCounter(
 			prometheus.CounterOpts{
-				Name: "ip_lookups_not_found_total",
-				Help: "Total number of IP lookups that returned not found",
+				Name: "stale_serves_total",
+				Help: "Total number of lookups answered from cached data because the datastore returned an error",
 			},
 		),
 
-		IPLookupsErrors: promauto.NewCounterVec(
+		// Application Metrics
+		IPLookupsErrors: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "ip_lookups_errors_total",
 				Help: "Total number of IP lookup errors",
 			},
 			[]string{"error_type"},
 		),
+
+		// Load Shedding Metrics
+		BackpressureRejections: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "backpressure_rejections_total",
+				Help: "Total number of requests rejected because the server was at capacity",
+			},
+		),
+		AdaptiveRateLimitCurrent: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "adaptive_rate_limit_current",
+				Help: "Per-IP rate limit in effect (requests per second), lowered while CPU is high (ADAPTIVE_RATE_LIMIT)",
+			},
+		),
+
+		// Security Metrics
+		BlocklistRejections: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "blocklist_rejections_total",
+				Help: "Total number of requests rejected because the client IP is blocklisted",
+			},
+		),
+
+		// Prefetch Metrics
+		PrefetchTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "prefetch_total",
+				Help: "Total number of background lookups of IPs adjacent to a requested IP",
+			},
+		),
+		PrefetchCacheHits: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "prefetch_cache_hits_total",
+				Help: "Total number of requests for an IP that was prefetched",
+			},
+		),
+
+		// Private IP Metrics
+		PrivateIPSkips: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "private_ip_skips_total",
+				Help: "Total number of lookups of private, loopback or link-local IPs answered without a store query",
+			},
+		),
+
+		// Analytics Metrics
+		UniqueIPsToday: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "unique_ips_today",
+				Help: "Approximate number of distinct client IPs today (UTC), refreshed every minute",
+			},
+		),
 	}
 }
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+)
+
+// APIKeyHeader is the request header carrying the admin API key
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware rejects requests without a matching X-API-Key header (returns 401)
+// The comparison is constant-time so the key can't be guessed byte by byte from response timing
+// An empty apiKey (ADMIN_API_KEY unset) rejects every request, so the endpoints stay locked rather than open
+func APIKeyMiddleware(apiKey string) func(http.Handler) http.Handler {
+	return APIKeyMiddlewareWithTokens(apiKey, nil)
+}
+
+// APIKeyMiddlewareWithTokens is APIKeyMiddleware that also accepts one-time tokens
+// Keys starting with "one-time:" are consumed from tokens instead of compared to apiKey
+// A nil tokens limiter rejects one-time keys like any other wrong key
+func APIKeyMiddlewareWithTokens(apiKey string, tokens *limiter.DisposableTokenLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get(APIKeyHeader)
+			if tokens != nil && limiter.IsDisposableToken(provided) {
+				if !tokens.Allow(provided) {
+					writeUnauthorized(w)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if apiKey == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+				writeUnauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeUnauthorized writes the 401 response for a missing, invalid or already used key
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":  "UNAUTHORIZED",
+		"error": "missing or invalid API key",
+	})
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+)
+
+// TestAPIKeyMiddleware tests key checking
+func TestAPIKeyMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		header     string
+		wantStatus int
+	}{
+		{name: "valid key", apiKey: "s3cret", header: "s3cret", wantStatus: http.StatusOK},
+		{name: "wrong key", apiKey: "s3cret", header: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "missing key", apiKey: "s3cret", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "unset key", apiKey: "", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "unset key with a header", apiKey: "", header: "anything", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := APIKeyMiddleware(tt.apiKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+			if tt.header != "" {
+				req.Header.Set(APIKeyHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+// TestAPIKeyMiddlewareWithTokens tests that one-time tokens work once alongside the static key
+func TestAPIKeyMiddlewareWithTokens(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tokens, err := limiter.NewDisposableTokenLimiter(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to create token limiter: %v", err)
+	}
+	defer tokens.Close()
+
+	if err := tokens.AddToken("one-time:abc", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := APIKeyMiddlewareWithTokens("s3cret", tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send("one-time:abc"); code != http.StatusOK {
+		t.Errorf("expected first use of the token to return 200, got %d", code)
+	}
+	if code := send("one-time:abc"); code != http.StatusUnauthorized {
+		t.Errorf("expected reused token to return 401, got %d", code)
+	}
+	if code := send("s3cret"); code != http.StatusOK {
+		t.Errorf("expected the static key to keep working, got %d", code)
+	}
+
+	// Without a token limiter, one-time keys are just wrong keys
+	if err := tokens.AddToken("one-time:def", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plain := APIKeyMiddleware("s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set(APIKeyHeader, "one-time:def")
+	rec := httptest.NewRecorder()
+	plain.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token limiter, got %d", rec.Code)
+	}
+}
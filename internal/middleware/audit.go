@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AuditMiddleware writes an audit log entry for every request it wraps
+// Used on /admin so config reloads and rate limit resets can be traced back to a caller
+// Successful and rejected (e.g. 401) requests are both logged
+func AuditMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := GetRequestContext(r.Context())
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			log.Info().
+				Str("request_id", reqCtx.RequestID).
+				Str("client_ip", reqCtx.ClientIP).
+				Str("node_id", reqCtx.NodeID).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Int("status", ww.Status()).
+				Dur("duration_ms", time.Since(reqCtx.StartTime)).
+				Msg("Admin request")
+		})
+	}
+}
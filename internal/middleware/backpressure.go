@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	pkgmiddleware "github.com/evyataryagoni/ip2country/pkg/middleware"
+)
+
+// BackpressureMiddleware sheds load by returning 503 once more than maxInFlight requests are being served
+// Accepting more work while at capacity only increases latency for everyone
+// A maxInFlight of 0 or less disables the middleware
+// WithExemptPaths works like for RateLimitMiddleware: exempt requests (health probes, scrapes) are
+// neither counted nor rejected, so an overloaded server still answers its probes
+func BackpressureMiddleware(maxInFlight int, m *metrics.Metrics, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	exempt := pkgmiddleware.ExemptMatcher(opts...)
+
+	return func(next http.Handler) http.Handler {
+		if maxInFlight <= 0 {
+			return next
+		}
+
+		var inFlight int64
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Always decrement, including for rejected requests (we incremented for them too)
+			current := atomic.AddInt64(&inFlight, 1)
+			defer atomic.AddInt64(&inFlight, -1)
+
+			if current > int64(maxInFlight) {
+				if m != nil && m.BackpressureRejections != nil {
+					m.BackpressureRejections.Inc()
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]string{
+					"code":  "SERVER_BUSY",
+					"error": "server at capacity, try again later",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
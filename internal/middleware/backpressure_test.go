@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newBackpressureMetrics creates an unregistered counter so tests don't collide with the global registry
+func newBackpressureMetrics() *metrics.Metrics {
+	return &metrics.Metrics{
+		BackpressureRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "backpressure_rejections_total",
+			Help: "test",
+		}),
+	}
+}
+
+// TestBackpressureMiddleware_BelowLimit tests requests pass through under the limit
+func TestBackpressureMiddleware_BelowLimit(t *testing.T) {
+	handler := BackpressureMiddleware(10, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		// Requests are sequential, so only one is ever in flight
+		if rec.Code != http.StatusOK {
+			t.Errorf("request %d: expected status 200, got %d", i+1, rec.Code)
+		}
+	}
+}
+
+// TestBackpressureMiddleware_AtLimit tests 503 is returned while at capacity and cleared afterwards
+func TestBackpressureMiddleware_AtLimit(t *testing.T) {
+	m := newBackpressureMetrics()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	nextCalls := 0
+	handler := BackpressureMiddleware(1, m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalls++
+		if nextCalls == 1 {
+			close(entered)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// First request occupies the only slot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-entered
+
+	// Second request is rejected
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got '%s'", rec.Header().Get("Retry-After"))
+	}
+
+	var errResp map[string]string
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp["code"] != "SERVER_BUSY" {
+		t.Errorf("expected code SERVER_BUSY, got '%s'", errResp["code"])
+	}
+	if errResp["error"] != "server at capacity, try again later" {
+		t.Errorf("unexpected error message: %s", errResp["error"])
+	}
+	if nextCalls != 1 {
+		t.Errorf("expected next handler NOT to be called for rejected request")
+	}
+	if got := testutil.ToFloat64(m.BackpressureRejections); got != 1 {
+		t.Errorf("expected 1 rejection recorded, got %v", got)
+	}
+
+	// Once the first request finishes, the slot is free again
+	close(release)
+	<-done
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 after slot freed, got %d", rec.Code)
+	}
+}
+
+// TestBackpressureMiddleware_ExemptPaths tests that exempt paths pass while at capacity, without taking a slot
+func TestBackpressureMiddleware_ExemptPaths(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := BackpressureMiddleware(1, nil, WithExemptPaths("/health"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/countries" {
+			close(entered)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// An exempt request doesn't count towards the limit
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/countries", nil))
+	}()
+	<-entered
+
+	for _, tt := range []struct {
+		path     string
+		expected int
+	}{
+		{"/health", http.StatusOK},
+		{"/health/ready", http.StatusOK},
+		{"/healthz", http.StatusServiceUnavailable},
+		{"/v1/find-country", http.StatusServiceUnavailable},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected status %d at capacity, got %d", tt.path, tt.expected, rec.Code)
+		}
+	}
+
+	close(release)
+	<-done
+}
+
+// TestBackpressureMiddleware_Disabled tests that a limit of 0 disables the middleware
+func TestBackpressureMiddleware_Disabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := BackpressureMiddleware(0, nil)(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultBlocklistKey is the Redis set RedisBlocklistLoader reads when no key is given
+const DefaultBlocklistKey = "blocklist:cidrs"
+
+// BlocklistLoader fetches the current list of blocked networks
+// Called at startup and on every refresh, so it should read the source afresh each time
+type BlocklistLoader interface {
+	Load() ([]net.IPNet, error)
+}
+
+// FileBlocklistLoader reads a file with one CIDR (or single IP) per line
+// Blank lines and lines starting with # are ignored
+type FileBlocklistLoader struct {
+	Path string
+}
+
+// NewFileBlocklistLoader creates a loader for the blocklist file at path
+func NewFileBlocklistLoader(path string) *FileBlocklistLoader {
+	return &FileBlocklistLoader{Path: path}
+}
+
+// Load parses the file. Any invalid line fails the whole load, so a typo can't silently unblock everything
+// Implements the BlocklistLoader interface
+func (l *FileBlocklistLoader) Load() ([]net.IPNet, error) {
+	file, err := os.Open(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist file: %w", err)
+	}
+	defer file.Close()
+
+	var nets []net.IPNet
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		ipNet, err := parseBlocklistEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("blocklist file line %d: %w", line, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist file: %w", err)
+	}
+	return nets, nil
+}
+
+// RedisBlocklistLoader reads the CIDRs (or single IPs) stored in a Redis set
+// Lets the security team update the list with SADD/SREM on the shared Redis, without touching servers
+type RedisBlocklistLoader struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisBlocklistLoader creates a loader for the Redis set key ("" = DefaultBlocklistKey)
+func NewRedisBlocklistLoader(client *redis.Client, key string) *RedisBlocklistLoader {
+	if key == "" {
+		key = DefaultBlocklistKey
+	}
+	return &RedisBlocklistLoader{client: client, key: key}
+}
+
+// Load reads every member of the set. Any invalid member fails the whole load
+// Implements the BlocklistLoader interface
+func (l *RedisBlocklistLoader) Load() ([]net.IPNet, error) {
+	members, err := l.client.SMembers(context.Background(), l.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocklist from Redis: %w", err)
+	}
+
+	nets := make([]net.IPNet, 0, len(members))
+	for _, member := range members {
+		ipNet, err := parseBlocklistEntry(strings.TrimSpace(member))
+		if err != nil {
+			return nil, fmt.Errorf("blocklist set %s: %w", l.key, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// parseBlocklistEntry parses a CIDR, or a single IP as a /32 (IPv4) or /128 (IPv6) network
+func parseBlocklistEntry(entry string) (net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return net.IPNet{}, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		return *ipNet, nil
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return net.IPNet{}, fmt.Errorf("invalid IP %q", entry)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Blocklist holds the networks currently blocked by BlocklistMiddleware
+// The list is swapped atomically on reload, so lookups never wait for a refresh
+type Blocklist struct {
+	loader BlocklistLoader
+	nets   atomic.Pointer[[]net.IPNet]
+}
+
+// NewBlocklist creates a blocklist and loads it once; a failed initial load is returned as an error
+func NewBlocklist(loader BlocklistLoader) (*Blocklist, error) {
+	b := &Blocklist{loader: loader}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reload replaces the list with a fresh Load. On error the previous list stays in effect
+func (b *Blocklist) Reload() error {
+	nets, err := b.loader.Load()
+	if err != nil {
+		return err
+	}
+	b.nets.Store(&nets)
+	return nil
+}
+
+// Len returns the number of blocked networks
+func (b *Blocklist) Len() int {
+	return len(*b.nets.Load())
+}
+
+// Contains reports whether ip is in any blocked network
+func (b *Blocklist) Contains(ip net.IP) bool {
+	for _, ipNet := range *b.nets.Load() {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh reloads the blocklist every interval. Failed reloads are logged and keep the previous list
+// Blocks until ctx is cancelled, so run it in its own goroutine
+func (b *Blocklist) Refresh(ctx context.Context, interval time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := b.Reload(); err != nil {
+			log.Error().Err(err).Msg("Failed to reload blocklist, keeping the previous list")
+			continue
+		}
+		log.Debug().Int("networks", b.Len()).Msg("Blocklist reloaded")
+	}
+}
+
+// BlocklistMiddleware rejects clients whose IP is in blocklist with 403 Forbidden
+// Uses the client IP resolved by RequestContextMiddleware. A nil blocklist disables the middleware
+func BlocklistMiddleware(blocklist *Blocklist, m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if blocklist == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(GetRequestContext(r.Context()).ClientIP)
+			if ip != nil && blocklist.Contains(ip) {
+				if m != nil && m.BlocklistRejections != nil {
+					m.BlocklistRejections.Inc()
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"code":  "IP_BLOCKED",
+					"error": "access denied",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+// writeBlocklistFile writes lines to a blocklist file in a temporary directory
+func writeBlocklistFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write blocklist file: %v", err)
+	}
+}
+
+// newBlocklistMetrics creates an unregistered counter so tests don't collide with the global registry
+func newBlocklistMetrics() *metrics.Metrics {
+	return &metrics.Metrics{
+		BlocklistRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blocklist_rejections_total",
+			Help: "test",
+		}),
+	}
+}
+
+// blocklistRequest sends a request from ip through the blocklist middleware
+func blocklistRequest(blocklist *Blocklist, m *metrics.Metrics, ip string) *httptest.ResponseRecorder {
+	handler := RequestContextMiddleware(BlocklistMiddleware(blocklist, m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	req.RemoteAddr = net.JoinHostPort(ip, "12345")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestBlocklistMiddleware tests that IPs inside a blocked network are rejected and others pass
+func TestBlocklistMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklistFile(t, path, "# Known scrapers", "10.0.0.0/8", "", "2001:db8::/32", "203.0.113.7")
+
+	blocklist, err := NewBlocklist(NewFileBlocklistLoader(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := newBlocklistMetrics()
+
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"10.0.0.1", true},
+		{"10.255.255.255", true},
+		{"2001:db8::1", true},
+		{"203.0.113.7", true},
+		{"203.0.113.8", false},
+		{"11.0.0.1", false},
+		{"2001:db9::1", false},
+	}
+
+	rejected := 0
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			rec := blocklistRequest(blocklist, m, tt.ip)
+
+			if !tt.blocked {
+				if rec.Code != http.StatusOK {
+					t.Errorf("expected status 200, got %d", rec.Code)
+				}
+				return
+			}
+
+			rejected++
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("expected status 403, got %d", rec.Code)
+			}
+			var body map[string]string
+			json.NewDecoder(rec.Body).Decode(&body)
+			if body["code"] != "IP_BLOCKED" || body["error"] != "access denied" {
+				t.Errorf("unexpected body: %v", body)
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(m.BlocklistRejections); got != float64(rejected) {
+		t.Errorf("expected %d rejections counted, got %v", rejected, got)
+	}
+}
+
+// TestBlocklistMiddleware_Disabled tests that a nil blocklist lets everything through
+func TestBlocklistMiddleware_Disabled(t *testing.T) {
+	if rec := blocklistRequest(nil, nil, "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+}
+
+// TestFileBlocklistLoader_Invalid tests that a bad line fails the load and names the line
+func TestFileBlocklistLoader_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklistFile(t, path, "10.0.0.0/8", "10.0.0.0/33")
+
+	_, err := NewFileBlocklistLoader(path).Load()
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got %v", err)
+	}
+
+	if _, err := NewFileBlocklistLoader(filepath.Join(t.TempDir(), "missing.txt")).Load(); err == nil {
+		t.Error("expected error for a missing file, got nil")
+	}
+}
+
+// TestBlocklist_ReloadFromFile tests that a reload picks up new entries, and a broken file keeps the old list
+func TestBlocklist_ReloadFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklistFile(t, path, "10.0.0.0/8")
+
+	blocklist, err := NewBlocklist(NewFileBlocklistLoader(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocklist.Contains(net.ParseIP("192.0.2.1")) {
+		t.Fatal("expected 192.0.2.1 not to be blocked yet")
+	}
+
+	writeBlocklistFile(t, path, "10.0.0.0/8", "192.0.2.0/24")
+	if err := blocklist.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !blocklist.Contains(net.ParseIP("192.0.2.1")) {
+		t.Error("expected 192.0.2.1 to be blocked after the reload")
+	}
+
+	writeBlocklistFile(t, path, "not-a-cidr")
+	if err := blocklist.Reload(); err == nil {
+		t.Error("expected error for an invalid file, got nil")
+	}
+	if blocklist.Len() != 2 || !blocklist.Contains(net.ParseIP("192.0.2.1")) {
+		t.Error("expected the previous list to stay in effect after a failed reload")
+	}
+}
+
+// TestBlocklist_Refresh tests that the background refresh reloads the file
+func TestBlocklist_Refresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklistFile(t, path, "10.0.0.0/8")
+
+	blocklist, err := NewBlocklist(NewFileBlocklistLoader(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nop := zerolog.Nop()
+	go blocklist.Refresh(ctx, 10*time.Millisecond, &logger.Logger{Logger: &nop})
+
+	writeBlocklistFile(t, path, "10.0.0.0/8", "192.0.2.0/24")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !blocklist.Contains(net.ParseIP("192.0.2.1")) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the refresh to pick up the new entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestRedisBlocklistLoader tests that the loader reads its own set, defaulting to DefaultBlocklistKey
+func TestRedisBlocklistLoader(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	mr.SAdd(DefaultBlocklistKey, "10.0.0.0/8", "203.0.113.7")
+	mr.SAdd("security:blocked", "192.0.2.0/24")
+
+	nets, err := NewRedisBlocklistLoader(client, "").Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nets) != 2 {
+		t.Fatalf("expected 2 networks from %s, got %v", DefaultBlocklistKey, nets)
+	}
+
+	blocklist, err := NewBlocklist(NewRedisBlocklistLoader(client, "security:blocked"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocklist.Len() != 1 || !blocklist.Contains(net.ParseIP("192.0.2.1")) {
+		t.Error("expected only the security:blocked set to be loaded")
+	}
+	if blocklist.Contains(net.ParseIP("10.0.0.1")) {
+		t.Error("expected the default set not to be read with a custom key")
+	}
+
+	mr.SAdd("security:blocked", "garbage")
+	if _, err := NewRedisBlocklistLoader(client, "security:blocked").Load(); err == nil {
+		t.Error("expected error for an invalid member, got nil")
+	}
+}
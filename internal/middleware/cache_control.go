@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// perClientHeaders are response headers that differ from one client to the next (set by the rate limiter)
+// A shared cache serving a response carrying them would hand one client's quota to another
+var perClientHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// cacheControlWriter sets caching headers just before the status code is written
+// Headers can't be changed after WriteHeader, so we hook in at that point
+type cacheControlWriter struct {
+	http.ResponseWriter
+	maxAge      int
+	cacheable   bool // GET or HEAD: other methods' responses are never marked cacheable
+	wroteHeader bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(statusCode int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+
+		header := cw.Header()
+		header.Set("Vary", "Accept-Encoding, Accept")
+		if statusCode == http.StatusOK && cw.cacheable && header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d", cacheScope(header), cw.maxAge, cw.maxAge/2))
+		} else if statusCode >= 400 {
+			header.Set("Cache-Control", "no-store")
+		}
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// cacheScope returns "private" if header holds per-client headers, so only the client's own cache keeps
+// the response, and "public" otherwise
+func cacheScope(header http.Header) string {
+	for _, name := range perClientHeaders {
+		if header.Get(name) != "" {
+			return "private"
+		}
+	}
+	return "public"
+}
+
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// CacheControlMiddleware adds Cache-Control and Vary headers so proxies and CDNs can cache lookups
+// 200 responses to GET and HEAD are cacheable for maxAge seconds, 4xx/5xx responses are never cached
+// Responses carrying rate limit headers are private: the client may cache them, shared caches may not
+// Handlers can opt out by setting their own Cache-Control header
+// A maxAge of 0 disables the middleware
+func CacheControlMiddleware(maxAge int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxAge <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cacheable := r.Method == http.MethodGet || r.Method == http.MethodHead
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, maxAge: maxAge, cacheable: cacheable}, r)
+		})
+	}
+}
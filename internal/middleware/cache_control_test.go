@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCacheControlMiddleware_StatusCodes tests the Cache-Control value for each response type
+func TestCacheControlMiddleware_StatusCodes(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		expectedCache string
+	}{
+		{"success", http.StatusOK, "public, max-age=600, stale-while-revalidate=300"},
+		{"not found", http.StatusNotFound, "no-store"},
+		{"rate limited", http.StatusTooManyRequests, "no-store"},
+		{"server error", http.StatusInternalServerError, "no-store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CacheControlMiddleware(600)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.expectedCache {
+				t.Errorf("expected Cache-Control '%s', got '%s'", tt.expectedCache, got)
+			}
+		})
+	}
+}
+
+// TestCacheControlMiddleware_ImplicitOK tests headers are set when the handler only calls Write
+func TestCacheControlMiddleware_ImplicitOK(t *testing.T) {
+	handler := CacheControlMiddleware(3600)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600, stale-while-revalidate=1800" {
+		t.Errorf("unexpected Cache-Control: %s", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding, Accept" {
+		t.Errorf("expected Vary 'Accept-Encoding, Accept', got '%s'", got)
+	}
+}
+
+// TestCacheControlMiddleware_Disabled tests that max-age 0 leaves responses untouched
+func TestCacheControlMiddleware_Disabled(t *testing.T) {
+	handler := CacheControlMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("expected no Cache-Control header, got '%s'", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "" {
+		t.Errorf("expected no Vary header, got '%s'", got)
+	}
+}
+
+// TestCacheControlMiddleware_HandlerOverride tests that a handler's own Cache-Control is kept
+func TestCacheControlMiddleware_HandlerOverride(t *testing.T) {
+	handler := CacheControlMiddleware(3600)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/recent", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected handler's Cache-Control 'no-store', got '%s'", got)
+	}
+}
+
+// TestCacheControlMiddleware_Methods tests that only GET and HEAD responses are marked cacheable
+func TestCacheControlMiddleware_Methods(t *testing.T) {
+	tests := []struct {
+		method        string
+		expectedCache string
+	}{
+		{http.MethodGet, "public, max-age=600, stale-while-revalidate=300"},
+		{http.MethodHead, "public, max-age=600, stale-while-revalidate=300"},
+		{http.MethodPost, ""},
+		{http.MethodDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			handler := CacheControlMiddleware(600)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/v1/batch", nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Cache-Control"); got != tt.expectedCache {
+				t.Errorf("expected Cache-Control '%s', got '%s'", tt.expectedCache, got)
+			}
+		})
+	}
+
+	// Errors are still never cached, whatever the method
+	handler := CacheControlMiddleware(600)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/batch", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected Cache-Control 'no-store' for a POST error, got '%s'", got)
+	}
+}
+
+// TestCacheControlMiddleware_RateLimitHeaders tests that responses carrying a client's rate limit
+// headers are private, so a shared cache can't serve one client's quota to another
+func TestCacheControlMiddleware_RateLimitHeaders(t *testing.T) {
+	for _, name := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+		t.Run(name, func(t *testing.T) {
+			// Set before the handler runs, like RateLimitMiddleware
+			handler := func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set(name, "5")
+					next.ServeHTTP(w, r)
+				})
+			}(CacheControlMiddleware(600)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Cache-Control"); got != "private, max-age=600, stale-while-revalidate=300" {
+				t.Errorf("expected a private Cache-Control, got '%s'", got)
+			}
+		})
+	}
+}
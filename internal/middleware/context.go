@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	pkgmiddleware "github.com/evyataryagoni/ip2country/pkg/middleware"
+)
+
+// The request context, rate limiting, logging and metrics middleware live in pkg/middleware so other
+// services can import them without the rest of ip2country. The wrappers here take ip2country's own
+// types (logger.Logger, metrics.Metrics) so the service keeps using one middleware package
+
+// RequestIDHeader carries the request ID back to the client (and accepts one from upstream proxies)
+const RequestIDHeader = pkgmiddleware.RequestIDHeader
+
+// RequestContext holds per-request values shared by every middleware and handler (see pkgmiddleware.RequestContext)
+type RequestContext = pkgmiddleware.RequestContext
+
+// RequestContextMiddleware assigns the request ID, resolves the client IP and stores both in the context
+// Must run first in the chain - later middleware call GetRequestContext
+func RequestContextMiddleware(next http.Handler) http.Handler {
+	return pkgmiddleware.RequestContextMiddleware(next)
+}
+
+// GetRequestContext returns the values stored by RequestContextMiddleware
+// Panics if the middleware didn't run - that's a wiring bug, not a runtime condition
+func GetRequestContext(ctx context.Context) *RequestContext {
+	return pkgmiddleware.GetRequestContext(ctx)
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+)
+
+// newRequestMetrics creates unregistered HTTP metrics so tests don't collide with the global registry
+func newRequestMetrics() *metrics.Metrics {
+	labels := []string{"method", "endpoint", "status"}
+	return &metrics.Metrics{
+		HTTPRequestsTotal:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total", Help: "test"}, labels),
+		HTTPRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "http_request_duration_seconds", Help: "test"}, labels),
+		HTTPRequestSize:     prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "http_request_size_bytes", Help: "test"}, labels[:2]),
+		HTTPResponseSize:    prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "http_response_size_bytes", Help: "test"}, labels),
+	}
+}
+
+// loggedRequestIDs returns the request_id field of every JSON log line in buf
+func loggedRequestIDs(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var ids []string
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		id, _ := entry["request_id"].(string)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// TestRequestContextMiddleware_SharedRequestID tests that logging, metrics and audit all see the same request ID
+func TestRequestContextMiddleware_SharedRequestID(t *testing.T) {
+	var logBuf, auditBuf bytes.Buffer
+	logZL := zerolog.New(&logBuf)
+	auditZL := zerolog.New(&auditBuf)
+	m := newRequestMetrics()
+
+	var handlerID string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerID = GetRequestContext(r.Context()).RequestID
+		w.WriteHeader(http.StatusOK)
+	})
+
+	chain := RequestContextMiddleware(
+		LoggingMiddleware(&logger.Logger{Logger: &logZL})(
+			MetricsMiddleware(m)(
+				AuditMiddleware(&logger.Logger{Logger: &auditZL})(handler))))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, req)
+
+	requestID := rec.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatal("expected X-Request-ID response header")
+	}
+	if handlerID != requestID {
+		t.Errorf("handler saw request ID %q, response header has %q", handlerID, requestID)
+	}
+
+	// Logging writes a start and a completion line
+	logIDs := loggedRequestIDs(t, &logBuf)
+	if len(logIDs) != 2 {
+		t.Fatalf("expected 2 log lines, got %d", len(logIDs))
+	}
+	for _, id := range logIDs {
+		if id != requestID {
+			t.Errorf("logging middleware saw request ID %q, expected %q", id, requestID)
+		}
+	}
+
+	auditIDs := loggedRequestIDs(t, &auditBuf)
+	if len(auditIDs) != 1 || auditIDs[0] != requestID {
+		t.Errorf("audit middleware saw request IDs %v, expected [%s]", auditIDs, requestID)
+	}
+
+	// Metrics attach the request ID to the latency histogram as an exemplar
+	var metric dto.Metric
+	observer := m.HTTPRequestDuration.WithLabelValues(http.MethodGet, "/admin/config", "200")
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	var exemplarID string
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		for _, label := range bucket.GetExemplar().GetLabel() {
+			if label.GetName() == "request_id" {
+				exemplarID = label.GetValue()
+			}
+		}
+	}
+	if exemplarID != requestID {
+		t.Errorf("metrics exemplar has request ID %q, expected %q", exemplarID, requestID)
+	}
+}
+
+// TestRequestContextMiddleware_Values tests the incoming request ID and client IP are honoured
+func TestRequestContextMiddleware_Values(t *testing.T) {
+	var reqCtx *RequestContext
+	handler := RequestContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx = GetRequestContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	req.RemoteAddr = "192.168.1.1:12345"
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	req.Header.Set(RequestIDHeader, "upstream-id-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if reqCtx.RequestID != "upstream-id-123" {
+		t.Errorf("expected upstream request ID, got %q", reqCtx.RequestID)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != "upstream-id-123" {
+		t.Errorf("expected X-Request-ID 'upstream-id-123', got %q", got)
+	}
+	if reqCtx.ClientIP != "203.0.113.7" {
+		t.Errorf("expected client IP 203.0.113.7, got %q", reqCtx.ClientIP)
+	}
+	if reqCtx.StartTime.IsZero() {
+		t.Error("expected StartTime to be set")
+	}
+}
+
+// TestGetRequestContext_Missing tests the panic when RequestContextMiddleware didn't run
+func TestGetRequestContext_Missing(t *testing.T) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			t.Fatal("expected panic, got none")
+		}
+		msg, _ := recovered.(string)
+		if !strings.Contains(msg, "RequestContextMiddleware") {
+			t.Errorf("expected panic message to name RequestContextMiddleware, got %v", recovered)
+		}
+	}()
+
+	GetRequestContext(context.Background())
+}
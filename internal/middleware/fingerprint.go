@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	pkgmiddleware "github.com/evyataryagoni/ip2country/pkg/middleware"
+)
+
+// Fingerprint identifies a client by its request headers rather than its IP address
+// Returns the hex SHA-256 of User-Agent + Accept-Language + Accept-Encoding
+func Fingerprint(r *http.Request) string {
+	return pkgmiddleware.Fingerprint(r)
+}
+
+// FingerprintMiddleware rate limits by header fingerprint (returns 429 when exceeded)
+// Catches scrapers that rotate IPs to get around per-IP limits
+// A nil limiter disables the middleware. WithExemptPaths works like for RateLimitMiddleware
+func FingerprintMiddleware(lim limiter.Limiter, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return pkgmiddleware.FingerprintMiddleware(lim, opts...)
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+)
+
+// newFingerprintRequest creates a request from the given IP with browser-like headers
+func newFingerprintRequest(remoteAddr, userAgent string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept-Language", "en-US")
+	req.Header.Set("Accept-Encoding", "gzip")
+	return req
+}
+
+// TestFingerprintMiddleware_RotatingIPs tests that the same fingerprint is limited across IPs
+func TestFingerprintMiddleware_RotatingIPs(t *testing.T) {
+	lim := limiter.NewMemoryLimiter(3)
+	defer lim.Close()
+
+	handler := FingerprintMiddleware(lim)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	ips := []string{"10.0.0.1:1000", "10.0.0.2:1000", "10.0.0.3:1000", "10.0.0.4:1000"}
+	var rec *httptest.ResponseRecorder
+	for _, ip := range ips {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, newFingerprintRequest(ip, "scraper/1.0"))
+	}
+
+	// The 4th request comes from a fresh IP but shares the fingerprint
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+
+	var errResp map[string]string
+	json.NewDecoder(rec.Body).Decode(&errResp)
+	if errResp["code"] != "FINGERPRINT_RATE_LIMITED" {
+		t.Errorf("expected code FINGERPRINT_RATE_LIMITED, got '%s'", errResp["code"])
+	}
+}
+
+// TestFingerprintMiddleware_DifferentFingerprints tests that fingerprints have independent limits
+func TestFingerprintMiddleware_DifferentFingerprints(t *testing.T) {
+	lim := limiter.NewMemoryLimiter(1)
+	defer lim.Close()
+
+	handler := FingerprintMiddleware(lim)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, ua := range []string{"browser/1.0", "browser/2.0", "curl/8.0"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newFingerprintRequest("192.168.1.1:1000", ua))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", ua, rec.Code)
+		}
+	}
+}
+
+// TestFingerprint_Stable tests hashing is deterministic, including with no headers
+func TestFingerprint_Stable(t *testing.T) {
+	first := Fingerprint(httptest.NewRequest(http.MethodGet, "/", nil))
+	second := Fingerprint(httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	if first != second {
+		t.Errorf("expected stable hash for empty headers, got %s and %s", first, second)
+	}
+	if len(first) != 64 {
+		t.Errorf("expected 64-char SHA-256 hex digest, got %d chars", len(first))
+	}
+
+	if Fingerprint(newFingerprintRequest("", "a")) == first {
+		t.Error("expected different headers to produce a different hash")
+	}
+}
+
+// TestFingerprintMiddleware_Disabled tests that a nil limiter passes everything through
+func TestFingerprintMiddleware_Disabled(t *testing.T) {
+	called := false
+	handler := FingerprintMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !called {
+		t.Error("expected next handler to be called")
+	}
+}
+
+// TestFingerprintMiddleware_ExemptPaths tests that exempt paths skip the fingerprint limiter too
+func TestFingerprintMiddleware_ExemptPaths(t *testing.T) {
+	mockLimiter := limiter.NewMockLimiter(false)
+	handler := FingerprintMiddleware(mockLimiter, WithExemptPaths("/health"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newFingerprintRequest("10.0.0.1:1234", "kube-probe/1.29"))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for a non-exempt path, got %d", rec.Code)
+	}
+
+	req := newFingerprintRequest("10.0.0.1:1234", "kube-probe/1.29")
+	req.URL.Path = "/health"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || len(mockLimiter.AllowCalls) != 1 {
+		t.Errorf("expected /health to pass without asking the limiter, got %d after %d Allow calls", rec.Code, len(mockLimiter.AllowCalls))
+	}
+}
@@ -2,56 +2,28 @@ package middleware
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/evyataryagoni/ip2country/internal/logger"
-	"github.com/go-chi/chi/v5/middleware"
+	pkgmiddleware "github.com/evyataryagoni/ip2country/pkg/middleware"
 )
 
-// LoggingMiddleware logs HTTP requests with structured data
-func LoggingMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-
-			// Wrap response writer to capture status code
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-
-			// Get request ID from context (set by chi's RequestID middleware)
-			requestID := middleware.GetReqID(r.Context())
-
-			// Log request start
-			log.Info().
-				Str("request_id", requestID).
-				Str("method", r.Method).
-				Str("path", r.URL.Path).
-				Str("remote_addr", r.RemoteAddr).
-				Str("user_agent", r.UserAgent()).
-				Msg("Request started")
+// LoggingOption configures LoggingMiddleware
+type LoggingOption = pkgmiddleware.LoggingOption
 
-			// Process request
-			next.ServeHTTP(ww, r)
-
-			// Calculate duration
-			duration := time.Since(start)
+// WithBodyLogging logs up to maxBytes of each request body at debug level
+// Longer bodies are logged truncated, with a "[truncated]" suffix. The handler still receives the whole body
+func WithBodyLogging(maxBytes int) LoggingOption {
+	return pkgmiddleware.WithBodyLogging(maxBytes)
+}
 
-			// Determine log level based on status code
-			logEvent := log.Info()
-			if ww.Status() >= 500 {
-				logEvent = log.Error()
-			} else if ww.Status() >= 400 {
-				logEvent = log.Warn()
-			}
+// WithBodyExcludePaths never logs the bodies of requests whose path starts with one of prefixes
+// Used for endpoints receiving secrets
+func WithBodyExcludePaths(prefixes ...string) LoggingOption {
+	return pkgmiddleware.WithBodyExcludePaths(prefixes...)
+}
 
-			// Log request completion
-			logEvent.
-				Str("request_id", requestID).
-				Str("method", r.Method).
-				Str("path", r.URL.Path).
-				Int("status", ww.Status()).
-				Int("bytes", ww.BytesWritten()).
-				Dur("duration_ms", duration).
-				Msg("Request completed")
-		})
-	}
+// LoggingMiddleware logs HTTP requests with structured data
+// Request bodies are only logged with WithBodyLogging, and only when the logger is at debug level
+func LoggingMiddleware(log *logger.Logger, opts ...LoggingOption) func(http.Handler) http.Handler {
+	return pkgmiddleware.LoggingMiddleware(log.Logger, opts...)
 }
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/rs/zerolog"
+)
+
+// serveLogged sends body to path through LoggingMiddleware and returns what the handler read
+func serveLogged(t *testing.T, level zerolog.Level, path, body string, opts ...LoggingOption) (string, *bytes.Buffer) {
+	t.Helper()
+
+	var logBuf bytes.Buffer
+	zl := zerolog.New(&logBuf).Level(level)
+
+	var received []byte
+	handler := RequestContextMiddleware(LoggingMiddleware(&logger.Logger{Logger: &zl}, opts...)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var err error
+			if received, err = io.ReadAll(r.Body); err != nil {
+				t.Errorf("failed to read body: %v", err)
+			}
+		})))
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return string(received), &logBuf
+}
+
+// loggedBodies returns the body field of every "Request body" log line in buf
+func loggedBodies(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var bodies []string
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		if entry["message"] == "Request body" {
+			body, _ := entry["body"].(string)
+			bodies = append(bodies, body)
+		}
+	}
+	return bodies
+}
+
+// TestLoggingMiddleware_BodyLogging tests that the body is logged and still reaches the handler
+func TestLoggingMiddleware_BodyLogging(t *testing.T) {
+	body := `{"ip":"8.8.8.8"}`
+	received, logBuf := serveLogged(t, zerolog.DebugLevel, "/v1/find-country", body, WithBodyLogging(64))
+
+	if received != body {
+		t.Errorf("expected handler to receive %q, got %q", body, received)
+	}
+	if bodies := loggedBodies(t, logBuf); len(bodies) != 1 || bodies[0] != body {
+		t.Errorf("expected logged body [%s], got %v", body, bodies)
+	}
+}
+
+// TestLoggingMiddleware_BodyLogging_Truncated tests that long bodies are logged truncated but passed on whole
+func TestLoggingMiddleware_BodyLogging_Truncated(t *testing.T) {
+	body := strings.Repeat("a", 10) + strings.Repeat("b", 10)
+	received, logBuf := serveLogged(t, zerolog.DebugLevel, "/admin/import", body, WithBodyLogging(10))
+
+	if received != body {
+		t.Errorf("expected handler to receive the whole body %q, got %q", body, received)
+	}
+	expected := strings.Repeat("a", 10) + "[truncated]"
+	if bodies := loggedBodies(t, logBuf); len(bodies) != 1 || bodies[0] != expected {
+		t.Errorf("expected logged body [%s], got %v", expected, bodies)
+	}
+}
+
+// TestLoggingMiddleware_BodyLogging_ExcludedPath tests that excluded paths never log their bodies
+func TestLoggingMiddleware_BodyLogging_ExcludedPath(t *testing.T) {
+	body := `{"ttl_seconds":60}`
+	received, logBuf := serveLogged(t, zerolog.DebugLevel, "/admin/token", body,
+		WithBodyLogging(64), WithBodyExcludePaths("/admin/token"))
+
+	if received != body {
+		t.Errorf("expected handler to receive %q, got %q", body, received)
+	}
+	if bodies := loggedBodies(t, logBuf); len(bodies) != 0 {
+		t.Errorf("expected no logged body for an excluded path, got %v", bodies)
+	}
+}
+
+// TestLoggingMiddleware_BodyLogging_NotDebug tests that bodies aren't logged above debug level
+func TestLoggingMiddleware_BodyLogging_NotDebug(t *testing.T) {
+	body := `{"ip":"8.8.8.8"}`
+	received, logBuf := serveLogged(t, zerolog.InfoLevel, "/v1/find-country", body, WithBodyLogging(64))
+
+	if received != body {
+		t.Errorf("expected handler to receive %q, got %q", body, received)
+	}
+	if bodies := loggedBodies(t, logBuf); len(bodies) != 0 {
+		t.Errorf("expected no logged body at info level, got %v", bodies)
+	}
+
+	// Without the option, debug level doesn't log bodies either
+	_, logBuf = serveLogged(t, zerolog.DebugLevel, "/v1/find-country", body)
+	if bodies := loggedBodies(t, logBuf); len(bodies) != 0 {
+		t.Errorf("expected no logged body without WithBodyLogging, got %v", bodies)
+	}
+}
+
+// TestLoggingMiddleware_ForwardedURL tests that the logged URL is the one the client used behind a proxy
+func TestLoggingMiddleware_ForwardedURL(t *testing.T) {
+	var logBuf bytes.Buffer
+	zl := zerolog.New(&logBuf)
+	handler := ProxyAwareMiddleware(testProxies)(RequestContextMiddleware(LoggingMiddleware(&logger.Logger{Logger: &zl})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "geo.example.com")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.NewDecoder(&logBuf).Decode(&entry); err != nil {
+		t.Fatalf("invalid log line: %v", err)
+	}
+	if expected := "https://geo.example.com/v1/find-country?ip=8.8.8.8"; entry["url"] != expected {
+		t.Errorf("expected url %q, got %v", expected, entry["url"])
+	}
+}
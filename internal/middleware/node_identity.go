@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+
+	pkgmiddleware "github.com/evyataryagoni/ip2country/pkg/middleware"
+)
+
+// NodeIDHeader identifies the server instance that handled the request
+const NodeIDHeader = pkgmiddleware.NodeIDHeader
+
+// NodeIdentityMiddleware sets X-Processing-Node on every response so requests
+// behind a load balancer can be traced to the instance that served them
+// Register it first, before RequestContextMiddleware (see pkgmiddleware.NodeIdentityMiddleware)
+func NodeIdentityMiddleware(nodeID string) func(http.Handler) http.Handler {
+	return pkgmiddleware.NodeIdentityMiddleware(nodeID)
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+// TestNodeIdentityMiddleware tests that every response carries the node ID, whatever its status
+func TestNodeIdentityMiddleware(t *testing.T) {
+	newRouter := func(rateLimiter limiter.Limiter) chi.Router {
+		r := chi.NewRouter()
+		r.Use(NodeIdentityMiddleware("api-7"))
+		r.Use(RequestContextMiddleware)
+		r.Use(middleware.Recoverer)
+		r.Use(RateLimitMiddleware(rateLimiter))
+		r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		allow      bool
+		wantStatus int
+	}{
+		{name: "ok", path: "/ok", allow: true, wantStatus: http.StatusOK},
+		{name: "not found", path: "/missing", allow: true, wantStatus: http.StatusNotFound},
+		{name: "rate limited", path: "/ok", allow: false, wantStatus: http.StatusTooManyRequests},
+		{name: "panic", path: "/panic", allow: true, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			newRouter(limiter.NewMockLimiter(tt.allow)).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get(NodeIDHeader); got != "api-7" {
+				t.Errorf("expected X-Processing-Node 'api-7', got %q", got)
+			}
+		})
+	}
+}
+
+// TestNodeIdentityMiddleware_AuditLog tests that the node ID reaches the request context and the audit log
+func TestNodeIdentityMiddleware_AuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	zl := zerolog.New(&buf)
+
+	var nodeID string
+	handler := NodeIdentityMiddleware("api-7")(RequestContextMiddleware(
+		AuditMiddleware(&logger.Logger{Logger: &zl})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nodeID = GetRequestContext(r.Context()).NodeID
+		}))))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+	if nodeID != "api-7" {
+		t.Errorf("expected RequestContext.NodeID 'api-7', got %q", nodeID)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid audit log line %q: %v", buf.String(), err)
+	}
+	if entry["node_id"] != "api-7" {
+		t.Errorf("expected node_id 'api-7' in the audit log, got %v", entry["node_id"])
+	}
+}
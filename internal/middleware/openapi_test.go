@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/docs"
+)
+
+// openAPIRequest sends a request through the OpenAPI middleware validating against the generated spec
+// reached reports whether the request got through to the handler
+func openAPIRequest(t *testing.T, method, target string) (rec *httptest.ResponseRecorder, reached bool) {
+	t.Helper()
+	validator, err := NewOpenAPIValidator(docs.SwaggerJSON)
+	if err != nil {
+		t.Fatalf("failed to load the OpenAPI spec: %v", err)
+	}
+
+	handler := OpenAPIMiddleware(validator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec, reached
+}
+
+// TestOpenAPIMiddleware_Valid tests that requests matching the spec reach the handler
+func TestOpenAPIMiddleware_Valid(t *testing.T) {
+	targets := []string{
+		"/v1/find-country?ip=8.8.8.8",
+		"/v1/find-country?ip=8.8.8.8&include_ip=true",
+		"/v1/whois?ip=1.1.1.1",
+		"/v1/countries",
+		"/v1/recent?n=10",
+	}
+
+	for _, target := range targets {
+		t.Run(target, func(t *testing.T) {
+			rec, reached := openAPIRequest(t, http.MethodGet, target)
+			if !reached || rec.Code != http.StatusOK {
+				t.Errorf("expected the request to reach the handler, got status %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestOpenAPIMiddleware_IPv6 tests that IPv6 addresses in every notation pass the "ip" format
+func TestOpenAPIMiddleware_IPv6(t *testing.T) {
+	ips := []string{
+		"2001:4860:4860::8888",
+		"2001:4860:4860:0:0:0:0:8888",
+		"::1",
+		"::",
+		"::ffff:8.8.8.8",
+		"fe80::1%25eth0", // Zone IDs are URL-encoded as %25
+	}
+
+	for _, ip := range ips {
+		t.Run(ip, func(t *testing.T) {
+			rec, reached := openAPIRequest(t, http.MethodGet, "/v1/find-country?ip="+ip)
+			if !reached {
+				t.Errorf("expected %s to reach the handler, got status %d: %s", ip, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestOpenAPIMiddleware_Invalid tests that requests breaking the spec are rejected with 400 before the handler
+func TestOpenAPIMiddleware_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		param  string
+	}{
+		{"missing ip", "/v1/find-country", "ip"},
+		{"empty ip", "/v1/find-country?ip=", "ip"},
+		{"integer ip", "/v1/find-country?ip=12345", "ip"},
+		{"malformed ip", "/v1/whois?ip=999.1.1.1", "ip"},
+		{"non-boolean include_ip", "/v1/find-country?ip=8.8.8.8&include_ip=maybe", "include_ip"},
+		{"non-integer n", "/v1/recent?n=ten", "n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, reached := openAPIRequest(t, http.MethodGet, tt.target)
+			if reached {
+				t.Fatal("expected the request not to reach the handler")
+			}
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+
+			var body map[string]string
+			json.NewDecoder(rec.Body).Decode(&body)
+			if body["code"] != "INVALID_REQUEST" {
+				t.Errorf("expected code INVALID_REQUEST, got %v", body)
+			}
+			if !strings.Contains(body["error"], `"`+tt.param+`"`) {
+				t.Errorf("expected the error to name %q, got %q", tt.param, body["error"])
+			}
+		})
+	}
+}
+
+// TestOpenAPIMiddleware_UndocumentedRoute tests that routes missing from the spec are left to the router
+func TestOpenAPIMiddleware_UndocumentedRoute(t *testing.T) {
+	if _, reached := openAPIRequest(t, http.MethodGet, "/v1/unknown"); !reached {
+		t.Error("expected an undocumented route to pass through")
+	}
+	if _, reached := openAPIRequest(t, http.MethodPut, "/v1/find-country"); !reached {
+		t.Error("expected an undocumented method to pass through")
+	}
+}
+
+// TestOpenAPIMiddleware_Disabled tests that a nil validator lets everything through
+func TestOpenAPIMiddleware_Disabled(t *testing.T) {
+	reached := false
+	handler := OpenAPIMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/find-country", nil))
+	if !reached {
+		t.Error("expected the request to reach the handler")
+	}
+}
+
+// TestNewOpenAPIValidator_InvalidSpec tests that a spec that isn't JSON is an error
+func TestNewOpenAPIValidator_InvalidSpec(t *testing.T) {
+	if _, err := NewOpenAPIValidator([]byte("not json")); err == nil {
+		t.Error("expected error for an invalid spec, got nil")
+	}
+}
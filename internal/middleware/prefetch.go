@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/service"
+)
+
+// DefaultPrefetchConcurrency is the number of prefetch lookups a Prefetcher runs at once, across all requests
+const DefaultPrefetchConcurrency = 32
+
+// prefetchTrackedIPs is the number of prefetched IPs remembered to count prefetch_cache_hits_total
+const prefetchTrackedIPs = 10000
+
+// Prefetcher looks up the neighbours of each IP requested, in the background
+// Clients often query IPs of the same /24 one after the other, so after a successful lookup of
+// a.b.c.d the IPs a.b.c.(d±1) to a.b.c.(d±adjacent) are looked up through the service. That warms
+// whatever sits behind it - the StaleStore cache, the MySQL buffer pool, Redis - for the next request.
+// IPv6 lookups aren't prefetched: neighbouring addresses are rarely queried together
+//
+// At most DefaultPrefetchConcurrency lookups run at once; prefetches beyond that are skipped,
+// so a burst of requests never queues background work
+type Prefetcher struct {
+	svc      *service.IPService
+	adjacent int
+	metrics  *metrics.Metrics // nil = not counted
+
+	slots chan struct{} // One per running lookup
+	wg    sync.WaitGroup
+
+	// Recently prefetched IPs, least recently prefetched at the back
+	mu         sync.Mutex
+	order      *list.List
+	prefetched map[string]*list.Element
+}
+
+// NewPrefetcher creates a prefetcher looking up adjacent IPs on each side of every IPv4 lookup
+func NewPrefetcher(svc *service.IPService, adjacent int, m *metrics.Metrics) *Prefetcher {
+	return &Prefetcher{
+		svc:        svc,
+		adjacent:   adjacent,
+		metrics:    m,
+		slots:      make(chan struct{}, DefaultPrefetchConcurrency),
+		order:      list.New(),
+		prefetched: make(map[string]*list.Element),
+	}
+}
+
+// Prefetch starts background lookups of the IPs adjacent to ip that weren't prefetched recently
+// Returns immediately
+func (p *Prefetcher) Prefetch(ip string) {
+	for _, neighbour := range adjacentIPs(ip, p.adjacent) {
+		if !p.remember(neighbour) {
+			continue // Already prefetched
+		}
+
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			p.forget(neighbour)
+			continue // Every slot is busy
+		}
+
+		if p.metrics != nil && p.metrics.PrefetchTotal != nil {
+			p.metrics.PrefetchTotal.Inc()
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer func() { <-p.slots }()
+			// Background, not the request's context: the prefetch is meant to outlive the request
+			p.svc.Prefetch(context.Background(), neighbour) // Best effort: a failed prefetch only means a cold lookup later
+		}()
+	}
+}
+
+// Wait blocks until every prefetch lookup started has finished
+func (p *Prefetcher) Wait() {
+	p.wg.Wait()
+}
+
+// Close waits for the prefetch lookups in progress, so they don't outlive the store
+func (p *Prefetcher) Close() error {
+	p.Wait()
+	return nil
+}
+
+// requested records a request for ip, counting a cache hit if it was prefetched
+func (p *Prefetcher) requested(ip string) {
+	if p.forget(ip) && p.metrics != nil && p.metrics.PrefetchCacheHits != nil {
+		p.metrics.PrefetchCacheHits.Inc()
+	}
+}
+
+// remember adds ip to the recently prefetched IPs, evicting the oldest when full
+// Returns false if ip was already there
+func (p *Prefetcher) remember(ip string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.prefetched[ip]; ok {
+		return false
+	}
+	p.prefetched[ip] = p.order.PushFront(ip)
+	if p.order.Len() > prefetchTrackedIPs {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.prefetched, oldest.Value.(string))
+	}
+	return true
+}
+
+// forget removes ip from the recently prefetched IPs, reporting whether it was there
+func (p *Prefetcher) forget(ip string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, ok := p.prefetched[ip]
+	if ok {
+		p.order.Remove(elem)
+		delete(p.prefetched, ip)
+	}
+	return ok
+}
+
+// adjacentIPs returns the IPv4 addresses up to n apart from ip in its /24, nearest first
+// Returns nil for IPv6 and invalid addresses
+func adjacentIPs(ip string, n int) []string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() == nil || n <= 0 {
+		return nil
+	}
+	v4 := parsed.To4()
+	last := int(v4[3])
+
+	var ips []string
+	for d := 1; d <= n; d++ {
+		for _, octet := range []int{last - d, last + d} {
+			if octet < 0 || octet > 255 {
+				continue
+			}
+			neighbour := net.IPv4(v4[0], v4[1], v4[2], byte(octet))
+			ips = append(ips, neighbour.String())
+		}
+	}
+	return ips
+}
+
+// PrefetchMiddleware prefetches the neighbours of the ip query parameter after each successful request
+// The lookups start once the response has been written, so they never delay it
+// A nil prefetcher disables the middleware
+func PrefetchMiddleware(prefetcher *Prefetcher) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if prefetcher == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := r.URL.Query().Get("ip")
+			if ip == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			prefetcher.requested(ip)
+
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			if rw.statusCode == http.StatusOK {
+				prefetcher.Prefetch(ip)
+			}
+		})
+	}
+}
+
+// responseWriter wraps http.ResponseWriter to capture the status code
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *responseWriter) WriteHeader(statusCode int) {
+	rw.statusCode = statusCode
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush server-sent events)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
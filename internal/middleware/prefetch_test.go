@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/service"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// blockingStore records lookups, which block until release is closed
+type blockingStore struct {
+	store.Store
+	release chan struct{}
+
+	mu       sync.Mutex
+	started  []string
+	finished int
+}
+
+func newBlockingStore() *blockingStore {
+	return &blockingStore{Store: store.NewMockStore(), release: make(chan struct{})}
+}
+
+func (s *blockingStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	s.mu.Lock()
+	s.started = append(s.started, ip)
+	s.mu.Unlock()
+
+	<-s.release
+
+	s.mu.Lock()
+	s.finished++
+	s.mu.Unlock()
+	return s.Store.FindByIP(ctx, ip)
+}
+
+// waitStarted waits until n lookups have started and returns their IPs, sorted
+func (s *blockingStore) waitStarted(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		started := slices.Clone(s.started)
+		s.mu.Unlock()
+
+		if len(started) >= n || time.Now().After(deadline) {
+			slices.Sort(started)
+			return started
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newPrefetchMetrics creates unregistered counters so tests don't collide with the global registry
+func newPrefetchMetrics() *metrics.Metrics {
+	return &metrics.Metrics{
+		PrefetchTotal:     prometheus.NewCounter(prometheus.CounterOpts{Name: "prefetch_total", Help: "test"}),
+		PrefetchCacheHits: prometheus.NewCounter(prometheus.CounterOpts{Name: "prefetch_cache_hits_total", Help: "test"}),
+	}
+}
+
+// newTestPrefetcher returns a prefetcher over s, waiting for its lookups when the test ends
+func newTestPrefetcher(t *testing.T, s store.Store, adjacent int, m *metrics.Metrics) *Prefetcher {
+	t.Helper()
+
+	p := NewPrefetcher(service.NewIPService(s, nil, nil), adjacent, m)
+	t.Cleanup(p.Wait)
+	return p
+}
+
+// prefetchRequest sends GET target through PrefetchMiddleware to a handler answering with status
+func prefetchRequest(p *Prefetcher, target string, status int) *httptest.ResponseRecorder {
+	handler := PrefetchMiddleware(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+// TestPrefetchMiddleware tests that adjacentCount IPs on each side are prefetched after the response
+func TestPrefetchMiddleware(t *testing.T) {
+	s := newBlockingStore()
+	m := newPrefetchMetrics()
+	p := newTestPrefetcher(t, s, 3, m)
+	defer close(s.release)
+
+	rec := prefetchRequest(p, "/v1/find-country?ip=8.8.8.8", http.StatusOK)
+
+	// The response came back while every prefetch lookup is still blocked
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	started := s.waitStarted(t, 6)
+	s.mu.Lock()
+	finished := s.finished
+	s.mu.Unlock()
+	if finished != 0 {
+		t.Errorf("expected no prefetch to have finished, got %d", finished)
+	}
+
+	expected := []string{"8.8.8.10", "8.8.8.11", "8.8.8.5", "8.8.8.6", "8.8.8.7", "8.8.8.9"}
+	if !slices.Equal(started, expected) {
+		t.Errorf("expected prefetches of %v, got %v", expected, started)
+	}
+	if got := testutil.ToFloat64(m.PrefetchTotal); got != 6 {
+		t.Errorf("expected prefetch_total 6, got %v", got)
+	}
+}
+
+// TestPrefetchMiddleware_IPv6 tests that IPv6 lookups aren't prefetched
+func TestPrefetchMiddleware_IPv6(t *testing.T) {
+	s := store.NewMockStore()
+	m := newPrefetchMetrics()
+	p := newTestPrefetcher(t, s, 3, m)
+
+	prefetchRequest(p, "/v1/find-country?ip=2001:4860:4860::8888", http.StatusOK)
+	p.Wait()
+
+	if len(s.FindByIPCalls) != 0 {
+		t.Errorf("expected no prefetch lookups, got %v", s.FindByIPCalls)
+	}
+	if got := testutil.ToFloat64(m.PrefetchTotal); got != 0 {
+		t.Errorf("expected prefetch_total 0, got %v", got)
+	}
+}
+
+// TestPrefetchMiddleware_Failed tests that failed requests and requests without an IP aren't prefetched
+func TestPrefetchMiddleware_Failed(t *testing.T) {
+	s := store.NewMockStore()
+	p := newTestPrefetcher(t, s, 3, newPrefetchMetrics())
+
+	prefetchRequest(p, "/v1/find-country?ip=9.9.9.9", http.StatusNotFound)
+	prefetchRequest(p, "/v1/countries", http.StatusOK)
+	p.Wait()
+
+	if len(s.FindByIPCalls) != 0 {
+		t.Errorf("expected no prefetch lookups, got %v", s.FindByIPCalls)
+	}
+}
+
+// TestPrefetchMiddleware_CacheHits tests that a request for a prefetched IP counts one hit, and IPs aren't prefetched twice
+func TestPrefetchMiddleware_CacheHits(t *testing.T) {
+	s := store.NewMockStore()
+	m := newPrefetchMetrics()
+	p := newTestPrefetcher(t, s, 1, m)
+
+	prefetchRequest(p, "/v1/find-country?ip=1.1.1.1", http.StatusOK) // Prefetches 1.1.1.0 and 1.1.1.2
+	p.Wait()
+	prefetchRequest(p, "/v1/find-country?ip=1.1.1.2", http.StatusOK) // Hit; prefetches 1.1.1.1 and 1.1.1.3
+	p.Wait()
+	prefetchRequest(p, "/v1/find-country?ip=1.1.1.2", http.StatusOK) // Not a hit any more; both neighbours were just prefetched
+	p.Wait()
+
+	if got := testutil.ToFloat64(m.PrefetchCacheHits); got != 1 {
+		t.Errorf("expected prefetch_cache_hits_total 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.PrefetchTotal); got != 4 {
+		t.Errorf("expected prefetch_total 4, got %v", got)
+	}
+}
+
+// TestPrefetcher_Bounded tests that prefetches beyond DefaultPrefetchConcurrency are skipped, not queued
+func TestPrefetcher_Bounded(t *testing.T) {
+	s := newBlockingStore()
+	m := newPrefetchMetrics()
+	p := newTestPrefetcher(t, s, 20, m) // 40 neighbours
+	defer close(s.release)
+
+	p.Prefetch("10.0.0.100")
+
+	if started := s.waitStarted(t, DefaultPrefetchConcurrency); len(started) != DefaultPrefetchConcurrency {
+		t.Errorf("expected %d prefetches, got %d", DefaultPrefetchConcurrency, len(started))
+	}
+	if got := testutil.ToFloat64(m.PrefetchTotal); got != DefaultPrefetchConcurrency {
+		t.Errorf("expected prefetch_total %d, got %v", DefaultPrefetchConcurrency, got)
+	}
+}
+
+// TestAdjacentIPs tests that neighbours stay inside the /24, nearest first
+func TestAdjacentIPs(t *testing.T) {
+	tests := []struct {
+		ip       string
+		n        int
+		expected []string
+	}{
+		{"8.8.8.8", 2, []string{"8.8.8.7", "8.8.8.9", "8.8.8.6", "8.8.8.10"}},
+		{"10.0.0.0", 2, []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.255", 1, []string{"10.0.0.254"}},
+		{"8.8.8.8", 0, nil},
+		{"2001:db8::1", 2, nil},
+		{"not-an-ip", 2, nil},
+	}
+
+	for _, tt := range tests {
+		if got := adjacentIPs(tt.ip, tt.n); !slices.Equal(got, tt.expected) {
+			t.Errorf("adjacentIPs(%q, %d): expected %v, got %v", tt.ip, tt.n, tt.expected, got)
+		}
+	}
+}
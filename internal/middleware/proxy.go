@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/swaggo/swag"
+)
+
+// ParseTrustedProxies parses the TRUSTED_PROXIES entries: CIDRs, or single IPs
+// Any invalid entry fails the whole list, so a typo can't silently trust nothing (or everything)
+func ParseTrustedProxies(entries []string) ([]net.IPNet, error) {
+	nets := make([]net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		ipNet, err := parseBlocklistEntry(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxies: %w", err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// SwaggerDocMiddleware serves the Swagger spec at .../doc.json with the host and scheme of each request
+// Behind an HTTPS-terminating proxy the generated spec names the server's own address,
+// so "Try it out" in Swagger UI would call http:// on a host clients can't reach.
+// Every request gets its own copy of the spec, so clients reaching the server through
+// different hosts each see theirs and requests never wait on each other.
+// Needs ProxyAwareMiddleware in front for r.URL.Scheme and r.URL.Host; a nil spec (no generated docs) makes it a no-op
+func SwaggerDocMiddleware(spec *swag.Spec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if spec == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/doc.json") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			doc := *spec
+			if r.URL.Host != "" {
+				doc.Host = r.URL.Host
+			}
+			if r.URL.Scheme != "" {
+				doc.Schemes = []string{r.URL.Scheme}
+			}
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(doc.ReadDoc()))
+		})
+	}
+}
+
+// ProxyAwareMiddleware sets r.URL.Scheme and r.URL.Host to what the client used, so
+// r.URL.String() is the canonical URL of the request (logged as "url" by LoggingMiddleware)
+//
+// X-Forwarded-Proto (http or https) sets the scheme and X-Forwarded-Host sets both r.Host and
+// r.URL.Host, but only on requests whose connection comes from one of trustedProxies - anyone
+// else could send them to point the canonical URL and the Swagger spec at a host of their choice.
+// Otherwise the scheme follows the connection (https for TLS) and the host is r.Host.
+// No trusted proxies (the default) = the headers are always ignored
+func ProxyAwareMiddleware(trustedProxies []net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+
+			if fromTrustedProxy(r.RemoteAddr, trustedProxies) {
+				proto := strings.ToLower(firstForwardedValue(r.Header.Get("X-Forwarded-Proto")))
+				if proto == "http" || proto == "https" {
+					scheme = proto
+				}
+				if host := firstForwardedValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+					r.Host = host
+				}
+			}
+
+			r.URL.Scheme = scheme
+			r.URL.Host = r.Host
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// fromTrustedProxy reports whether the peer of the connection (r.RemoteAddr) is in one of trusted
+func fromTrustedProxy(remoteAddr string, trusted []net.IPNet) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstForwardedValue returns the value set by the proxy closest to the client
+// Each proxy in a chain may append its own value ("https, http")
+func firstForwardedValue(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(first)
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/swaggo/swag"
+)
+
+// testProxies trusts the address httptest.NewRequest connects from (192.0.2.1)
+var testProxies = []net.IPNet{{IP: net.IPv4(192, 0, 2, 0), Mask: net.CIDRMask(24, 32)}}
+
+// serveProxyAware sends req through ProxyAwareMiddleware and returns the request the handler saw
+func serveProxyAware(trustedProxies []net.IPNet, req *http.Request) *http.Request {
+	var seen *http.Request
+	handler := ProxyAwareMiddleware(trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return seen
+}
+
+// TestProxyAwareMiddleware_NoHeaders tests that the URL is http on the request's own host without forwarded headers
+func TestProxyAwareMiddleware_NoHeaders(t *testing.T) {
+	seen := serveProxyAware(testProxies, httptest.NewRequest(http.MethodGet, "http://ip2country.internal:3000/v1/find-country?ip=8.8.8.8", nil))
+
+	if seen.URL.Scheme != "http" {
+		t.Errorf("expected scheme http, got %q", seen.URL.Scheme)
+	}
+	if got := seen.URL.String(); got != "http://ip2country.internal:3000/v1/find-country?ip=8.8.8.8" {
+		t.Errorf("unexpected URL %q", got)
+	}
+}
+
+// TestProxyAwareMiddleware_ForwardedProto tests that X-Forwarded-Proto from a trusted proxy sets the scheme
+func TestProxyAwareMiddleware_ForwardedProto(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "https", expected: "https"},
+		{header: "HTTPS", expected: "https"},
+		{header: "https, http", expected: "https"}, // The client's proxy comes first
+		{header: "gopher", expected: "http"},       // Not a scheme the server could be reached on
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+			req.Header.Set("X-Forwarded-Proto", tt.header)
+
+			if seen := serveProxyAware(testProxies, req); seen.URL.Scheme != tt.expected {
+				t.Errorf("expected scheme %q, got %q", tt.expected, seen.URL.Scheme)
+			}
+		})
+	}
+}
+
+// TestProxyAwareMiddleware_ForwardedHost tests that X-Forwarded-Host from a trusted proxy replaces the host
+func TestProxyAwareMiddleware_ForwardedHost(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://10.0.0.5:3000/health", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "geo.example.com")
+
+	seen := serveProxyAware(testProxies, req)
+
+	if seen.Host != "geo.example.com" {
+		t.Errorf("expected r.Host geo.example.com, got %q", seen.Host)
+	}
+	if got := seen.URL.String(); got != "https://geo.example.com/health" {
+		t.Errorf("expected https://geo.example.com/health, got %q", got)
+	}
+}
+
+// TestProxyAwareMiddleware_UntrustedPeer tests that forwarded headers are ignored unless the connection comes from a trusted proxy
+func TestProxyAwareMiddleware_UntrustedPeer(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []net.IPNet
+		remoteAddr     string
+	}{
+		{name: "no trusted proxies", trustedProxies: nil, remoteAddr: "192.0.2.1:1234"},
+		{name: "peer outside the trusted networks", trustedProxies: testProxies, remoteAddr: "203.0.113.7:1234"},
+		{name: "unparsable peer", trustedProxies: testProxies, remoteAddr: "pipe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://10.0.0.5:3000/health", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "evil.example.com")
+
+			if got := serveProxyAware(tt.trustedProxies, req).URL.String(); got != "http://10.0.0.5:3000/health" {
+				t.Errorf("expected the headers to be ignored, got %q", got)
+			}
+		})
+	}
+}
+
+// TestParseTrustedProxies tests that CIDRs and single IPs are accepted, and any invalid entry fails the list
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.1 ", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("expected 3 networks, got %d", len(nets))
+	}
+	if !nets[0].Contains(net.ParseIP("10.1.2.3")) || !nets[1].Contains(net.ParseIP("192.0.2.1")) || nets[1].Contains(net.ParseIP("192.0.2.2")) {
+		t.Errorf("unexpected networks %v", nets)
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/8", "proxy.internal"}); err == nil {
+		t.Error("expected an error for a hostname")
+	}
+}
+
+// serveSwaggerDoc requests /swagger/doc.json through ProxyAwareMiddleware and SwaggerDocMiddleware
+func serveSwaggerDoc(spec *swag.Spec, req *http.Request) *httptest.ResponseRecorder {
+	handler := ProxyAwareMiddleware(testProxies)(SwaggerDocMiddleware(spec)(http.NotFoundHandler()))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestSwaggerDocMiddleware tests that each request gets the spec with its own scheme and host, without changing the spec
+func TestSwaggerDocMiddleware(t *testing.T) {
+	spec := &swag.Spec{
+		Host:            "localhost:3000",
+		SwaggerTemplate: `{"host": "{{.Host}}", "schemes": {{ marshal .Schemes }}}`,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://10.0.0.5:3000/swagger/doc.json", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "geo.example.com")
+	rec := serveSwaggerDoc(spec, req)
+	if expected := `{"host": "geo.example.com", "schemes": ["https"]}`; rec.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rec.Body.String())
+	}
+
+	rec = serveSwaggerDoc(spec, httptest.NewRequest(http.MethodGet, "http://10.0.0.5:3000/swagger/doc.json", nil))
+	if expected := `{"host": "10.0.0.5:3000", "schemes": ["http"]}`; rec.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rec.Body.String())
+	}
+
+	if spec.Host != "localhost:3000" || spec.Schemes != nil {
+		t.Errorf("expected the spec to be untouched, got %q %v", spec.Host, spec.Schemes)
+	}
+
+	// Anything else under /swagger is left to the UI handler
+	rec = serveSwaggerDoc(spec, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected /swagger/index.html to reach the next handler, got %d", rec.Code)
+	}
+}
+
+// TestSwaggerDocMiddleware_Concurrent tests that concurrent requests each get their own host (run with -race)
+func TestSwaggerDocMiddleware_Concurrent(t *testing.T) {
+	spec := &swag.Spec{
+		Host:            "localhost:3000",
+		SwaggerTemplate: `{"host": "{{.Host}}", "schemes": {{ marshal .Schemes }}}`,
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			host := fmt.Sprintf("edge-%d.example.com", i)
+			req := httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", host)
+
+			rec := serveSwaggerDoc(spec, req)
+			if expected := fmt.Sprintf(`{"host": "%s", "schemes": ["https"]}`, host); rec.Body.String() != expected {
+				errs <- fmt.Errorf("expected %s, got %s", expected, rec.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
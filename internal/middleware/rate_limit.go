@@ -1,39 +1,25 @@
 package middleware
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/evyataryagoni/ip2country/internal/limiter"
+	pkgmiddleware "github.com/evyataryagoni/ip2country/pkg/middleware"
 )
 
-// RateLimitMiddleware enforces rate limiting per IP address (returns 429 when exceeded)
-func RateLimitMiddleware(lim limiter.Limiter) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
-
-			// Try to get real IP from headers (for proxies/load balancers)
-			// Priority: X-Real-IP > X-Forwarded-For > RemoteAddr
-			if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-				ip = realIP
-			} else if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-				// X-Forwarded-For can contain multiple IPs (format: "client, proxy1, proxy2")
-				if firstIP := forwardedFor; firstIP != "" {
-					ip = firstIP
-				}
-			}
+// RateLimitOption configures RateLimitMiddleware and FingerprintMiddleware
+type RateLimitOption = pkgmiddleware.RateLimitOption
 
-			if !lim.Allow(ip) {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				json.NewEncoder(w).Encode(map[string]string{
-					"error": "Rate limit exceeded. Please try again later.",
-				})
-				return
-			}
+// WithExemptPaths lets requests under paths through without asking the limiter
+// A path exempts itself and everything below it: "/admin" exempts "/admin/stats" but not "/administrator"
+func WithExemptPaths(paths ...string) RateLimitOption {
+	return pkgmiddleware.WithExemptPaths(paths...)
+}
 
-			next.ServeHTTP(w, r)
-		})
-	}
+// RateLimitMiddleware enforces rate limiting per IP address (returns 429 when exceeded)
+// A limiter implementing limiter.RequestLimiter (e.g. MultiTenantLimiter) gets the request too,
+// so the limit can depend on who is calling. A limiter implementing limiter.InspectableLimiter (every limiter
+// NewLimiter builds) sets the X-RateLimit-* headers, and Retry-After on a 429
+func RateLimitMiddleware(lim limiter.Limiter, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return pkgmiddleware.RateLimitMiddleware(lim, opts...)
 }
@@ -374,3 +374,120 @@ func TestRateLimitMiddleware_PreservesNextHandlerResponse(t *testing.T) {
 		t.Errorf("expected custom response body to be preserved")
 	}
 }
+
+// TestRateLimitMiddleware_MultiTenant tests that a RequestLimiter applies the caller's tier
+func TestRateLimitMiddleware_MultiTenant(t *testing.T) {
+	tiers := map[string]limiter.LimiterConfig{
+		"free": {Type: "memory", RequestsPerSecond: 1},
+		"pro":  {Type: "memory", RequestsPerSecond: 3},
+	}
+	lim, err := limiter.NewMultiTenantLimiter(tiers, "free", func(r *http.Request) string {
+		return r.Header.Get("X-Tier")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer lim.Close()
+
+	handler := RateLimitMiddleware(lim)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	allowed := func(tier string) int {
+		count := 0
+		for i := 0; i < 5; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = "192.168.1.1:12345"
+			req.Header.Set("X-Tier", tier)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code == http.StatusOK {
+				count++
+			}
+		}
+		return count
+	}
+
+	if got := allowed("free"); got != 1 {
+		t.Errorf("expected 1 free request allowed, got %d", got)
+	}
+	if got := allowed("pro"); got != 3 {
+		t.Errorf("expected 3 pro requests allowed, got %d", got)
+	}
+}
+
+// TestRateLimitMiddleware_RealRedis tests the middleware against a real RedisLimiter
+func TestRateLimitMiddleware_RealRedis(t *testing.T) {
+	lim, mr := limiter.NewTestRedisLimiter(t, 2)
+
+	handler := RateLimitMiddleware(lim)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes[i] = rec.Code
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("expected [200 200 429], got %v", codes)
+	}
+	if len(mr.Keys()) == 0 {
+		t.Error("expected the limiter to keep its counters in Redis")
+	}
+}
+
+// TestRateLimitMiddleware_ExemptPaths tests that exempt paths and everything below them skip the limiter
+func TestRateLimitMiddleware_ExemptPaths(t *testing.T) {
+	tests := []struct {
+		path   string
+		exempt bool
+	}{
+		{"/health", true},
+		{"/metrics", true},
+		{"/admin", true},
+		{"/admin/stats", true}, // Below an exempt path
+		{"/administrator", false},
+		{"/healthz", false},
+		{"/v1/find-country", false},
+		{"/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			mockLimiter := limiter.NewMockLimiter(false) // Block all
+			handler := RateLimitMiddleware(mockLimiter, WithExemptPaths("/health", "/metrics", "/admin/"))(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if tt.exempt {
+				if rec.Code != http.StatusOK || len(mockLimiter.AllowCalls) != 0 {
+					t.Errorf("expected 200 without asking the limiter, got %d after %d Allow calls", rec.Code, len(mockLimiter.AllowCalls))
+				}
+			} else if rec.Code != http.StatusTooManyRequests || len(mockLimiter.AllowCalls) != 1 {
+				t.Errorf("expected 429 after 1 Allow call, got %d after %d", rec.Code, len(mockLimiter.AllowCalls))
+			}
+		})
+	}
+}
+
+// TestRateLimitMiddleware_NoExemptPaths tests that without WithExemptPaths every request is limited
+func TestRateLimitMiddleware_NoExemptPaths(t *testing.T) {
+	mockLimiter := limiter.NewMockLimiter(true)
+	handler := RateLimitMiddleware(mockLimiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, path := range []string{"/health", "/metrics", "/v1/find-country"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if len(mockLimiter.AllowCalls) != 3 {
+		t.Errorf("expected 3 Allow calls, got %d", len(mockLimiter.AllowCalls))
+	}
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// AdminAPISchema is the JSON Schema (draft-07) of the admin API request bodies
+// Each entry of its definitions keyed "METHOD /path" is the schema of that route's body
+//
+//go:embed schemas/admin_api.json
+var AdminAPISchema []byte
+
+// adminAPISchemaURL is the URL the schema is compiled under; $refs inside it resolve against it
+const adminAPISchemaURL = "admin_api.json"
+
+// maxSchemaBodySize caps the bodies SchemaValidationMiddleware reads, like maxDeleteIPsBodySize in the handler
+const maxSchemaBodySize = 1 << 20
+
+// schemaErrorPrinter formats validation errors in English, whatever the process locale
+var schemaErrorPrinter = message.NewPrinter(language.English)
+
+// SchemaValidator checks JSON request bodies against the schemas of a JSON Schema document
+type SchemaValidator struct {
+	schemas map[string]*jsonschema.Schema // By "METHOD /path"
+}
+
+// NewSchemaValidator compiles every definition of a schema document keyed "METHOD /path"
+// Other definitions (e.g. "ip") are only compiled as far as the routes reference them
+func NewSchemaValidator(schema []byte) (*SchemaValidator, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON schema: %w", err)
+	}
+	var parsed struct {
+		Definitions map[string]json.RawMessage `json:"definitions"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft7)
+	c.AssertFormat()
+	c.RegisterFormat(&jsonschema.Format{Name: "ip", Validate: func(v any) error {
+		s, ok := v.(string)
+		if !ok {
+			return nil // Non-strings are left to "type"
+		}
+		return validateIPFormat(s)
+	}})
+	if err := c.AddResource(adminAPISchemaURL, doc); err != nil {
+		return nil, fmt.Errorf("failed to load JSON schema: %w", err)
+	}
+
+	schemas := make(map[string]*jsonschema.Schema)
+	for name := range parsed.Definitions {
+		method, path, ok := strings.Cut(name, " ")
+		if !ok || !strings.HasPrefix(path, "/") {
+			continue
+		}
+
+		// JSON pointer escaping (~ and /), then URL escaping for the fragment
+		pointer := strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+		compiled, err := c.Compile(adminAPISchemaURL + "#/definitions/" + url.PathEscape(pointer))
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile JSON schema for %s: %w", name, err)
+		}
+		schemas[method+" "+path] = compiled
+	}
+	return &SchemaValidator{schemas: schemas}, nil
+}
+
+// Validates reports whether the body of method path requests is checked
+func (v *SchemaValidator) Validates(method, path string) bool {
+	_, ok := v.schemas[method+" "+path]
+	return ok
+}
+
+// Validate checks body against the schema of method path
+// Routes without a schema are not validated (returns nil)
+func (v *SchemaValidator) Validate(method, path string, body []byte) error {
+	schema, ok := v.schemas[method+" "+path]
+	if !ok {
+		return nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return errors.New("request body is not valid JSON")
+	}
+
+	err = schema.Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return errors.New(strings.Join(schemaErrorMessages(validationErr), "; "))
+	}
+	return err
+}
+
+// schemaErrorMessages returns one message per failed keyword, prefixed with where in the body it failed
+// e.g. "at '/ips/0': 'x' is not valid ip: not an IPv4 or IPv6 address"
+func schemaErrorMessages(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		msg := err.ErrorKind.LocalizedString(schemaErrorPrinter)
+		if len(err.InstanceLocation) == 0 {
+			return []string{msg}
+		}
+		return []string{fmt.Sprintf("at '/%s': %s", strings.Join(err.InstanceLocation, "/"), msg)}
+	}
+
+	var messages []string
+	for _, cause := range err.Causes {
+		messages = append(messages, schemaErrorMessages(cause)...)
+	}
+	return messages
+}
+
+// SchemaValidationMiddleware rejects admin request bodies that don't conform to their JSON Schema with 400 Bad Request
+// e.g. a DELETE /admin/ips without "ips", before the request reaches the handler
+// Routes without a schema and empty bodies (optional on POST /admin/token) pass through. A nil validator disables the middleware
+func SchemaValidationMiddleware(validator *SchemaValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if validator == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !validator.Validates(r.Method, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSchemaBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeSchemaError(w, http.StatusRequestEntityTooLarge, "request body too large")
+					return
+				}
+				writeSchemaError(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			// The handler decodes the body again
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if len(bytes.TrimSpace(body)) > 0 {
+				if err := validator.Validate(r.Method, r.URL.Path, body); err != nil {
+					writeSchemaError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeSchemaError writes an INVALID_REQUEST error, the code OpenAPIMiddleware rejects requests with
+func writeSchemaError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":  "INVALID_REQUEST",
+		"error": message,
+	})
+}
@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAdminSchemaValidator compiles the embedded admin API schema
+func newAdminSchemaValidator(t *testing.T) *SchemaValidator {
+	t.Helper()
+
+	v, err := NewSchemaValidator(AdminAPISchema)
+	if err != nil {
+		t.Fatalf("failed to compile admin API schema: %v", err)
+	}
+	return v
+}
+
+// schemaRequest sends body through SchemaValidationMiddleware and returns the response and the body the handler read
+// The handler is nil if it wasn't reached
+func schemaRequest(v *SchemaValidator, method, target, body string) (*httptest.ResponseRecorder, *string) {
+	var handled *string
+	handler := SchemaValidationMiddleware(v)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, _ := io.ReadAll(r.Body)
+		s := string(read)
+		handled = &s
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec, handled
+}
+
+// schemaError decodes the error message of a rejected request
+func schemaError(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body["code"] != "INVALID_REQUEST" {
+		t.Errorf("expected code INVALID_REQUEST, got %q", body["code"])
+	}
+	return body["error"]
+}
+
+// TestNewSchemaValidator_AdminAPISchema tests that the embedded schema compiles, with a schema per admin route taking JSON
+func TestNewSchemaValidator_AdminAPISchema(t *testing.T) {
+	v := newAdminSchemaValidator(t)
+
+	for _, route := range []string{"DELETE /admin/ips", "POST /admin/token"} {
+		method, path, _ := strings.Cut(route, " ")
+		if !v.Validates(method, path) {
+			t.Errorf("expected a schema for %s", route)
+		}
+	}
+	if v.Validates(http.MethodGet, "ip") {
+		t.Error("expected shared definitions not to be routes")
+	}
+}
+
+// TestNewSchemaValidator_Invalid tests that documents that aren't valid JSON Schema are rejected
+func TestNewSchemaValidator_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not JSON":      `{"definitions":`,
+		"invalid type":  `{"definitions": {"POST /x": {"type": "nothing"}}}`,
+		"invalid ref":   `{"definitions": {"POST /x": {"$ref": "#/definitions/missing"}}}`,
+		"invalid limit": `{"definitions": {"POST /x": {"maxItems": -1}}}`,
+	}
+
+	for name, schema := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewSchemaValidator([]byte(schema)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestSchemaValidationMiddleware_Valid tests that conforming bodies reach the handler unchanged
+func TestSchemaValidationMiddleware_Valid(t *testing.T) {
+	v := newAdminSchemaValidator(t)
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodDelete, "/admin/ips", `{"ips":["1.2.3.4","2001:db8::1"]}`},
+		{http.MethodDelete, "/admin/ips", `{"ips":[]}`},
+		{http.MethodPost, "/admin/token", `{"ttl_seconds":300}`},
+		{http.MethodPost, "/admin/token", ``}, // The body is optional
+	}
+
+	for _, tt := range tests {
+		rec, handled := schemaRequest(v, tt.method, tt.target, tt.body)
+		if rec.Code != http.StatusOK || handled == nil {
+			t.Errorf("%s %s %s: expected the handler to run, got status %d: %s", tt.method, tt.target, tt.body, rec.Code, rec.Body.String())
+			continue
+		}
+		if *handled != tt.body {
+			t.Errorf("%s %s: expected the handler to read %q, got %q", tt.method, tt.target, tt.body, *handled)
+		}
+	}
+}
+
+// TestSchemaValidationMiddleware_MissingField tests that a missing required field is rejected, naming the field
+func TestSchemaValidationMiddleware_MissingField(t *testing.T) {
+	rec, handled := schemaRequest(newAdminSchemaValidator(t), http.MethodDelete, "/admin/ips", `{}`)
+
+	if handled != nil {
+		t.Error("expected the handler not to run")
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if msg := schemaError(t, rec); !strings.Contains(msg, "ips") {
+		t.Errorf("expected the error to name ips, got %q", msg)
+	}
+}
+
+// TestSchemaValidationMiddleware_Invalid tests that bodies breaking the schema are rejected with the reason
+func TestSchemaValidationMiddleware_Invalid(t *testing.T) {
+	tooMany := make([]string, 10001)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`"10.0.%d.%d"`, i/256, i%256)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		expected string
+	}{
+		{"too many IPs", http.MethodDelete, "/admin/ips", `{"ips":[` + strings.Join(tooMany, ",") + `]}`, "maxItems"},
+		{"invalid IP", http.MethodDelete, "/admin/ips", `{"ips":["1.2.3.4","not-an-ip"]}`, "at '/ips/1'"},
+		{"not an array", http.MethodDelete, "/admin/ips", `{"ips":"1.2.3.4"}`, "at '/ips'"},
+		{"unknown field", http.MethodDelete, "/admin/ips", `{"ips":[],"all":true}`, "all"},
+		{"TTL too long", http.MethodPost, "/admin/token", `{"ttl_seconds":86401}`, "maximum"},
+		{"TTL not an integer", http.MethodPost, "/admin/token", `{"ttl_seconds":"300"}`, "at '/ttl_seconds'"},
+		{"not JSON", http.MethodPost, "/admin/token", `{"ttl_seconds":`, "not valid JSON"},
+	}
+
+	v := newAdminSchemaValidator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, handled := schemaRequest(v, tt.method, tt.target, tt.body)
+
+			if handled != nil {
+				t.Error("expected the handler not to run")
+			}
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			if msg := schemaError(t, rec); !strings.Contains(msg, tt.expected) {
+				t.Errorf("expected the error to contain %q, got %q", tt.expected, msg)
+			}
+		})
+	}
+}
+
+// TestSchemaValidationMiddleware_TooLarge tests that bodies over the size limit are rejected with 413
+func TestSchemaValidationMiddleware_TooLarge(t *testing.T) {
+	body := `{"ips":["` + strings.Repeat("1", maxSchemaBodySize) + `"]}`
+	rec, handled := schemaRequest(newAdminSchemaValidator(t), http.MethodDelete, "/admin/ips", body)
+
+	if handled != nil {
+		t.Error("expected the handler not to run")
+	}
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rec.Code)
+	}
+}
+
+// TestSchemaValidationMiddleware_UnknownRoute tests that routes without a schema aren't validated
+func TestSchemaValidationMiddleware_UnknownRoute(t *testing.T) {
+	v := newAdminSchemaValidator(t)
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodPost, "/admin/import", "ip,city,country\n1.2.3.4,Sydney,Australia\n"},
+		{http.MethodDelete, "/admin/rate-limit/1.2.3.4", `{"anything":true}`},
+		{http.MethodPost, "/admin/ips", `{}`}, // Only DELETE has a schema
+	}
+
+	for _, tt := range tests {
+		rec, handled := schemaRequest(v, tt.method, tt.target, tt.body)
+		if rec.Code != http.StatusOK || handled == nil || *handled != tt.body {
+			t.Errorf("%s %s: expected the handler to read the body unchecked, got status %d", tt.method, tt.target, rec.Code)
+		}
+	}
+}
+
+// TestSchemaValidationMiddleware_Disabled tests that a nil validator lets everything through
+func TestSchemaValidationMiddleware_Disabled(t *testing.T) {
+	rec, handled := schemaRequest(nil, http.MethodDelete, "/admin/ips", `{}`)
+
+	if rec.Code != http.StatusOK || handled == nil {
+		t.Errorf("expected the handler to run, got status %d", rec.Code)
+	}
+}
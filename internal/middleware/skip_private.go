@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// isPrivateIP reports whether ip is a private (RFC 1918, RFC 4193), loopback or link-local address
+// IPv4-mapped IPv6 addresses (::ffff:10.0.0.1) are classified like the IPv4 address they carry
+func isPrivateIP(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast()
+}
+
+// SkipPrivateIPMiddleware answers lookups of private, loopback and link-local addresses with response
+// Monitoring tools often look up 127.0.0.1 or their own 10.x address as a health check; no store holds
+// those, so the store query is skipped and response is returned with 200 right away.
+// include_ip=true is honoured like in the handler. Requests without a valid ip query parameter, or with
+// an invalid include_ip, go to the next handler, which reports the error
+func SkipPrivateIPMiddleware(response models.IPLocation, m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			ip := query.Get("ip")
+			if !isPrivateIP(ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body any = response
+			if raw := query.Get("include_ip"); raw != "" {
+				includeIP, err := strconv.ParseBool(raw)
+				if err != nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				if includeIP {
+					body = models.IPLocationWithIP{IPLocation: response, IP: ip}
+				}
+			}
+
+			if m != nil && m.PrivateIPSkips != nil {
+				m.PrivateIPSkips.Inc()
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(body)
+		})
+	}
+}
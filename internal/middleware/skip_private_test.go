@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// privateIPResponse is the response configured in the tests
+var privateIPResponse = models.IPLocation{City: "Private", Country: "Private Network"}
+
+// newSkipPrivateHandler wraps a handler that records whether it was reached
+func newSkipPrivateHandler(reached *bool) (http.Handler, *metrics.Metrics) {
+	m := &metrics.Metrics{PrivateIPSkips: prometheus.NewCounter(prometheus.CounterOpts{Name: "test_private_ip_skips_total"})}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached = true
+		w.WriteHeader(http.StatusTeapot)
+	})
+	return SkipPrivateIPMiddleware(privateIPResponse, m)(next), m
+}
+
+// TestSkipPrivateIPMiddleware tests which addresses are answered without reaching the handler
+func TestSkipPrivateIPMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		skipped bool
+	}{
+		{"IPv4 loopback", "ip=127.0.0.1", true},
+		{"RFC 1918", "ip=10.0.0.1", true},
+		{"RFC 1918 192.168", "ip=192.168.1.1", true},
+		{"IPv4 link-local", "ip=169.254.1.1", true},
+		{"IPv6 loopback", "ip=::1", true},
+		{"IPv6 unique local", "ip=fd00::1", true},
+		{"IPv6 link-local", "ip=fe80::1", true},
+		{"IPv4-mapped private", "ip=::ffff:10.0.0.1", true},
+		{"public IPv4", "ip=8.8.8.8", false},
+		{"public IPv6", "ip=2001:4860:4860::8888", false},
+		{"missing ip", "", false},
+		{"invalid ip", "ip=not-an-ip", false},
+		{"invalid include_ip", "ip=10.0.0.1&include_ip=maybe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reached bool
+			handler, m := newSkipPrivateHandler(&reached)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/find-country?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if reached == tt.skipped {
+				t.Errorf("expected handler reached %v, got %v", !tt.skipped, reached)
+			}
+			skips := testutil.ToFloat64(m.PrivateIPSkips)
+			if tt.skipped {
+				var got models.IPLocation
+				if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &got) != nil || got != privateIPResponse {
+					t.Errorf("expected 200 with %+v, got %d %s", privateIPResponse, rr.Code, rr.Body.String())
+				}
+				if rr.Header().Get("Content-Type") != "application/json" {
+					t.Errorf("expected Content-Type application/json, got %q", rr.Header().Get("Content-Type"))
+				}
+				if skips != 1 {
+					t.Errorf("expected private_ip_skips_total 1, got %v", skips)
+				}
+			} else if skips != 0 {
+				t.Errorf("expected private_ip_skips_total 0, got %v", skips)
+			}
+		})
+	}
+}
+
+// TestSkipPrivateIPMiddleware_IncludeIP tests that include_ip=true adds the IP, like the handler does
+func TestSkipPrivateIPMiddleware_IncludeIP(t *testing.T) {
+	var reached bool
+	handler, _ := newSkipPrivateHandler(&reached)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=127.0.0.1&include_ip=true", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var got models.IPLocationWithIP
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if expected := (models.IPLocationWithIP{IPLocation: privateIPResponse, IP: "127.0.0.1"}); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+// TestSkipPrivateIPMiddleware_NilMetrics tests that metrics are optional
+func TestSkipPrivateIPMiddleware_NilMetrics(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := SkipPrivateIPMiddleware(privateIPResponse, nil)(next)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=10.0.0.1", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// DefaultTimingsWindow is the number of recent request durations a ResponseTimes keeps
+const DefaultTimingsWindow = 10000
+
+// ResponseTimes is a sliding window of the most recent request durations
+// Gives operators without a metrics stack a rough view of latency (see TimingsHandler)
+//
+// Durations are written to a circular buffer of nanoseconds. Each write claims its slot
+// by atomically incrementing the write index, so concurrent requests never wait on a lock.
+// Readers may see a slot mid-way through being overwritten by a newer duration, which is
+// fine for percentiles over thousands of values
+type ResponseTimes struct {
+	slots []atomic.Int64
+	next  atomic.Uint64 // Total durations ever recorded; the next write goes to slots[next % len(slots)]
+}
+
+// Percentiles summarizes a ResponseTimes window, in milliseconds
+type Percentiles struct {
+	P50 float64 `json:"p50_ms"`
+	P95 float64 `json:"p95_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+// NewResponseTimes creates a window of the last size durations
+// A size <= 0 uses DefaultTimingsWindow
+func NewResponseTimes(size int) *ResponseTimes {
+	if size <= 0 {
+		size = DefaultTimingsWindow
+	}
+	return &ResponseTimes{slots: make([]atomic.Int64, size)}
+}
+
+// Record adds d to the window, replacing the oldest duration once the window is full
+func (t *ResponseTimes) Record(d time.Duration) {
+	i := t.next.Add(1) - 1
+	t.slots[i%uint64(len(t.slots))].Store(d.Nanoseconds())
+}
+
+// Durations returns the durations in the window in nanoseconds, oldest first
+func (t *ResponseTimes) Durations() []int64 {
+	next := t.next.Load()
+	size := uint64(len(t.slots))
+	if next <= size {
+		durations := make([]int64, next)
+		for i := range durations {
+			durations[i] = t.slots[i].Load()
+		}
+		return durations
+	}
+
+	// Full: the oldest duration is the one the next write will replace
+	durations := make([]int64, size)
+	for i := range durations {
+		durations[i] = t.slots[(next+uint64(i))%size].Load()
+	}
+	return durations
+}
+
+// Percentiles returns the p50, p95 and p99 of the window (nearest-rank)
+// All zero when nothing has been recorded
+func (t *ResponseTimes) Percentiles() Percentiles {
+	durations := t.Durations()
+	slices.Sort(durations)
+	return Percentiles{
+		P50: percentileMs(durations, 50),
+		P95: percentileMs(durations, 95),
+		P99: percentileMs(durations, 99),
+	}
+}
+
+// percentileMs returns the p-th percentile of sorted nanosecond durations in milliseconds
+// Nearest-rank: the smallest value with at least p% of the values at or below it
+func percentileMs(sorted []int64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return float64(sorted[rank-1]) / float64(time.Millisecond)
+}
+
+// ResponseTimeMiddleware records the duration of every request in t
+// A nil t disables the middleware
+func ResponseTimeMiddleware(t *ResponseTimes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if t == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			t.Record(time.Since(start))
+		})
+	}
+}
+
+// TimingsHandler serves GET /debug/timings: the window's percentiles as JSON, or as an HTML
+// page with a chart of every duration in the window when the client accepts text/html (e.g. a browser)
+func TimingsHandler(t *ResponseTimes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The window changes on every request
+		w.Header().Set("Cache-Control", "no-store")
+
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			page, err := timingsPage(t)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "failed to render chart"})
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(page)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Percentiles())
+	}
+}
+
+// timingsTemplate is the HTML page served by TimingsHandler; the chart is an inline SVG
+var timingsTemplate = template.Must(template.New("timings").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Response times</title></head>
+<body>
+<h1>Response times (last {{.Count}} requests)</h1>
+<p>p50: {{printf "%.2f" .Percentiles.P50}} ms &middot; p95: {{printf "%.2f" .Percentiles.P95}} ms &middot; p99: {{printf "%.2f" .Percentiles.P99}} ms</p>
+{{.Chart}}
+</body>
+</html>
+`))
+
+// timingsPage renders the HTML page for the current window
+func timingsPage(t *ResponseTimes) ([]byte, error) {
+	durations := t.Durations()
+	chart, err := timingsChart(durations)
+	if err != nil {
+		return nil, err
+	}
+
+	var page bytes.Buffer
+	err = timingsTemplate.Execute(&page, struct {
+		Count       int
+		Percentiles Percentiles
+		Chart       template.HTML
+	}{
+		Count:       len(durations),
+		Percentiles: t.Percentiles(),
+		Chart:       template.HTML(chart), // Generated by gonum/plot from numbers only
+	})
+	return page.Bytes(), err
+}
+
+// timingsChart draws durations (oldest first) as an SVG line chart in milliseconds
+func timingsChart(durations []int64) ([]byte, error) {
+	p := plot.New()
+	p.X.Label.Text = "Request"
+	p.Y.Label.Text = "Duration (ms)"
+
+	if len(durations) > 0 {
+		points := make(plotter.XYs, len(durations))
+		for i, d := range durations {
+			points[i].X = float64(i)
+			points[i].Y = float64(d) / float64(time.Millisecond)
+		}
+		line, err := plotter.NewLine(points)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plot durations: %w", err)
+		}
+		p.Add(line)
+	}
+
+	writer, err := p.WriterTo(10*vg.Inch, 3*vg.Inch, "svg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+	var svg bytes.Buffer
+	if _, err := writer.WriteTo(&svg); err != nil {
+		return nil, fmt.Errorf("failed to render chart: %w", err)
+	}
+
+	// Drop the XML prolog: the SVG is embedded in an HTML page, not served as a file
+	chart := svg.Bytes()
+	if i := bytes.Index(chart, []byte("<svg")); i > 0 {
+		chart = chart[i:]
+	}
+	return chart, nil
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestResponseTimes_Wraps tests that the window keeps the last durations, oldest first, once full
+func TestResponseTimes_Wraps(t *testing.T) {
+	window := NewResponseTimes(3)
+
+	window.Record(1)
+	window.Record(2)
+	if got := window.Durations(); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("expected [1 2] before the window is full, got %v", got)
+	}
+
+	window.Record(3)
+	window.Record(4)
+	window.Record(5)
+	if got := window.Durations(); !slices.Equal(got, []int64{3, 4, 5}) {
+		t.Errorf("expected the oldest durations to be replaced, got %v", got)
+	}
+
+	// Exactly two full turns around the buffer
+	window.Record(6)
+	if got := window.Durations(); !slices.Equal(got, []int64{4, 5, 6}) {
+		t.Errorf("expected [4 5 6], got %v", got)
+	}
+}
+
+// TestResponseTimes_Percentiles tests nearest-rank percentiles over 1ms..100ms, recorded out of order
+func TestResponseTimes_Percentiles(t *testing.T) {
+	window := NewResponseTimes(100)
+	for i := 100; i >= 1; i-- {
+		window.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	got := window.Percentiles()
+	expected := Percentiles{P50: 50, P95: 95, P99: 99}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	if empty := NewResponseTimes(10).Percentiles(); empty != (Percentiles{}) {
+		t.Errorf("expected zero percentiles for an empty window, got %+v", empty)
+	}
+}
+
+// TestResponseTimes_ConcurrentRecord tests that concurrent writes land in distinct slots (run with -race)
+func TestResponseTimes_ConcurrentRecord(t *testing.T) {
+	window := NewResponseTimes(DefaultTimingsWindow)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				window.Record(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	durations := window.Durations()
+	if len(durations) != 8000 {
+		t.Fatalf("expected 8000 durations, got %d", len(durations))
+	}
+	for _, d := range durations {
+		if d != time.Millisecond.Nanoseconds() {
+			t.Fatalf("expected every slot to hold 1ms, got %dns", d)
+		}
+	}
+}
+
+// TestResponseTimeMiddleware tests that each request's duration is recorded
+func TestResponseTimeMiddleware(t *testing.T) {
+	window := NewResponseTimes(10)
+	handler := ResponseTimeMiddleware(window)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+
+	durations := window.Durations()
+	if len(durations) != 1 || durations[0] < (2*time.Millisecond).Nanoseconds() {
+		t.Errorf("expected one duration of at least 2ms, got %v", durations)
+	}
+}
+
+// TestTimingsHandler_JSON tests that percentiles are returned as JSON when no Accept header is sent
+func TestTimingsHandler_JSON(t *testing.T) {
+	window := NewResponseTimes(100)
+	for i := 1; i <= 100; i++ {
+		window.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	TimingsHandler(window)(rec, httptest.NewRequest(http.MethodGet, "/debug/timings", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+
+	var body map[string]float64
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("expected valid JSON: %v", err)
+	}
+	if body["p50_ms"] != 50 || body["p95_ms"] != 95 || body["p99_ms"] != 99 {
+		t.Errorf("expected p50/p95/p99 of 50/95/99ms, got %v", body)
+	}
+}
+
+// TestTimingsHandler_HTML tests that browsers get a page with an inline SVG chart
+func TestTimingsHandler_HTML(t *testing.T) {
+	window := NewResponseTimes(100)
+	for i := 1; i <= 100; i++ {
+		window.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/timings", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec := httptest.NewRecorder()
+	TimingsHandler(window)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"<svg", "p50: 50.00 ms", "last 100 requests"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "<?xml") {
+		t.Error("expected the XML prolog to be stripped from the inline SVG")
+	}
+}
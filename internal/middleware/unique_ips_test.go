@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupUniqueIPs creates a miniredis-backed client and a handler wrapped by UniqueIPMiddleware
+func setupUniqueIPs(t *testing.T) (*miniredis.Miniredis, *redis.Client, http.Handler) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	handler := UniqueIPMiddleware(client, UniqueIPsDaily)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	return mr, client, handler
+}
+
+// sendFrom sends a request through handler from the given remote address
+func sendFrom(handler http.Handler, remoteAddr string) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil)
+	req.RemoteAddr = remoteAddr
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// todayCount returns the approximate unique IP count for today's key
+func todayCount(t *testing.T, client *redis.Client) int64 {
+	t.Helper()
+
+	key := UniqueIPsKey(time.Now().UTC().Format(UniqueIPsDaily))
+	count, err := client.PFCount(context.Background(), key).Result()
+	if err != nil {
+		t.Fatalf("PFCOUNT failed: %v", err)
+	}
+	return count
+}
+
+// TestUniqueIPMiddleware_SameIPCountedOnce tests that repeat requests don't inflate the count
+func TestUniqueIPMiddleware_SameIPCountedOnce(t *testing.T) {
+	_, client, handler := setupUniqueIPs(t)
+
+	// Different source ports, same client
+	sendFrom(handler, "10.0.0.1:1000")
+	sendFrom(handler, "10.0.0.1:2000")
+	sendFrom(handler, "10.0.0.1:3000")
+
+	if count := todayCount(t, client); count != 1 {
+		t.Errorf("expected 1 unique IP, got %d", count)
+	}
+}
+
+// TestUniqueIPMiddleware_DifferentIPs tests that distinct IPs are counted separately
+func TestUniqueIPMiddleware_DifferentIPs(t *testing.T) {
+	_, client, handler := setupUniqueIPs(t)
+
+	sendFrom(handler, "10.0.0.1:1000")
+	sendFrom(handler, "10.0.0.2:1000")
+
+	if count := todayCount(t, client); count != 2 {
+		t.Errorf("expected 2 unique IPs, got %d", count)
+	}
+}
+
+// TestUniqueIPMiddleware_ApproximateCount tests the HyperLogLog stays within 1% for many IPs
+func TestUniqueIPMiddleware_ApproximateCount(t *testing.T) {
+	_, client, handler := setupUniqueIPs(t)
+
+	const total = 5000
+	for i := 0; i < total; i++ {
+		sendFrom(handler, fmt.Sprintf("10.%d.%d.%d:1000", i/65536, (i/256)%256, i%256))
+	}
+
+	count := todayCount(t, client)
+	if diff := math.Abs(float64(count-total)) / total; diff > 0.01 {
+		t.Errorf("expected count within 1%% of %d, got %d (%.2f%% off)", total, count, diff*100)
+	}
+}
+
+// TestUniqueIPMiddleware_KeyExpires tests that windows older than the TTL are no longer counted
+func TestUniqueIPMiddleware_KeyExpires(t *testing.T) {
+	mr, client, handler := setupUniqueIPs(t)
+
+	sendFrom(handler, "10.0.0.1:1000")
+	if count := todayCount(t, client); count != 1 {
+		t.Fatalf("expected 1 unique IP before expiry, got %d", count)
+	}
+
+	mr.FastForward(uniqueIPsTTL + time.Second)
+
+	if count := todayCount(t, client); count != 0 {
+		t.Errorf("expected expired key to count 0, got %d", count)
+	}
+}
+
+// TestUniqueIPMiddleware_NilClient tests that tracking is disabled without a Redis client
+func TestUniqueIPMiddleware_NilClient(t *testing.T) {
+	called := false
+	handler := UniqueIPMiddleware(nil, UniqueIPsDaily)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	sendFrom(handler, "10.0.0.1:1000")
+
+	if !called {
+		t.Error("expected request to pass through")
+	}
+}
+
+// TestTrackUniqueIPsToday tests that the gauge reflects today's count
+func TestTrackUniqueIPsToday(t *testing.T) {
+	_, client, handler := setupUniqueIPs(t)
+	sendFrom(handler, "10.0.0.1:1000")
+	sendFrom(handler, "10.0.0.2:1000")
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_unique_ips_today"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		TrackUniqueIPsToday(ctx, client, UniqueIPsDaily, gauge, time.Hour)
+		close(done)
+	}()
+
+	// The first refresh happens immediately
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(gauge) != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if got := testutil.ToFloat64(gauge); got != 2 {
+		t.Errorf("expected gauge 2, got %v", got)
+	}
+}
@@ -1,12 +1,31 @@
 package models
 
+import "time"
+
 // IPLocation represents geographic information for an IP address
 // In Go, structs are used to define data structures
 // JSON tags tell Go how to convert this struct to/from JSON
 type IPLocation struct {
-	IP      string `json:"-" example:"-"`                      // The IP address (not included in JSON response)
-	City    string `json:"city" example:"Mountain View"`       // City name
-	Country string `json:"country" example:"United States"`    // Country name
+	IP            string  `json:"-" example:"-"`                                 // The IP address (not included in JSON response)
+	City          string  `json:"city" example:"Mountain View"`                  // City name
+	Country       string  `json:"country" example:"United States"`               // Country name
+	CountryCode   string  `json:"country_code,omitempty" example:"US"`           // ISO 3166-1 alpha-2 country code (from the store, or derived from Country)
+	ISP           string  `json:"isp,omitempty" example:"Google LLC"`            // ISP / AS organization (MaxMind ASN database only)
+	ASN           int     `json:"asn,omitempty" example:"15169"`                 // Autonomous system number (MaxMind ASN database only)
+	Latitude      float64 `json:"latitude,omitempty" example:"37.386"`           // Degrees north (MaxMind store or ipenrich.CoordinateEnricher; 0 = unknown)
+	Longitude     float64 `json:"longitude,omitempty" example:"-122.0838"`       // Degrees east (MaxMind store or ipenrich.CoordinateEnricher; 0 = unknown)
+	Continent     string  `json:"continent,omitempty" example:"North America"`   // Continent name (added by ipenrich.ContinentEnricher)
+	ContinentCode string  `json:"continent_code,omitempty" example:"NA"`         // Two-letter continent code: AF, AN, AS, EU, NA, OC or SA (from the store, or derived from Country)
+	Timezone      string  `json:"timezone,omitempty" example:"America/New_York"` // IANA timezone name (added by ipenrich.TimezoneEnricher)
+	AbuseScore    int     `json:"abuse_score,omitempty" example:"0"`             // Abuse score, 0 = clean (added by ipenrich.AbuseEnricher)
+	Stale         bool    `json:"-"`                                             // Served from cache because the datastore was unreachable (see store.StaleStore)
+}
+
+// IPLocationWithIP is an IPLocation that includes the IP address in JSON
+// Returned by GET /v1/find-country?include_ip=true, so clients can correlate responses with their own logs
+type IPLocationWithIP struct {
+	IPLocation
+	IP string `json:"ip" example:"8.8.8.8"` // The IP address that was looked up
 }
 
 // ErrorResponse is the standard error response format
@@ -14,3 +33,105 @@ type IPLocation struct {
 type ErrorResponse struct {
 	Error string `json:"error" example:"Invalid IP address format"` // Error message
 }
+
+// CountriesResponse is returned by GET /v1/countries
+type CountriesResponse struct {
+	Countries []string `json:"countries" example:"Australia,United States"` // Distinct countries with IP data, sorted alphabetically
+}
+
+// SearchResponse is returned by GET /v1/search
+type SearchResponse struct {
+	Results []IPLocationWithIP `json:"results"`            // Matching records, ordered by IP
+	Limit   int                `json:"limit" example:"10"` // Maximum number of results in this page
+	Offset  int                `json:"offset" example:"0"` // Number of matching records skipped
+}
+
+// HealthResponse is returned by GET /health
+type HealthResponse struct {
+	Status      string                       `json:"status" example:"ok"`                     // "ok", or "unavailable" when a check failed
+	DataVersion string                       `json:"data_version,omitempty" example:"9f86d0"` // Version of the loaded IP data (changes on every data load)
+	Checks      map[string]HealthCheckResult `json:"checks,omitempty"`                        // Result of each registered health check, by name
+}
+
+// HealthCheckResult is the outcome of one health check in HealthResponse
+type HealthCheckResult struct {
+	Status string `json:"status" example:"ok"`                          // "ok" or "error"
+	Error  string `json:"error,omitempty" example:"connection refused"` // Why the check failed
+}
+
+// HistoryEntry records a single IP lookup for the recent lookups endpoint
+type HistoryEntry struct {
+	Timestamp  time.Time `json:"timestamp" example:"2024-01-01T12:00:00Z"`  // When the lookup finished
+	IP         string    `json:"ip" example:"8.8.8.8"`                      // The IP that was looked up
+	City       string    `json:"city,omitempty" example:"Mountain View"`    // Result city (empty on error)
+	Country    string    `json:"country,omitempty" example:"United States"` // Result country (empty on error)
+	Error      string    `json:"error,omitempty"`                           // Error message if the lookup failed
+	DurationNs int64     `json:"duration_ns" example:"125000"`              // Lookup latency in nanoseconds
+}
+
+// WhoisResult aggregates everything the service knows about an IP address
+// The location includes the enrichment sources' fields (timezone, abuse score, ...) when they're enabled
+// Fields that no data source could provide are left empty
+type WhoisResult struct {
+	IPLocation
+	Classification string   `json:"classification,omitempty" example:"public"` // Address classification (public, private, loopback, ...)
+	Network        string   `json:"network,omitempty" example:"8.8.8.0/24"`    // CIDR block of the datastore range containing the IP
+	Signals        []string `json:"signals,omitempty" example:"blocklisted"`   // What the classifiers flagged: a non-public classification, blocklisted
+	Errors         []string `json:"errors,omitempty"`                          // Sub-lookups that failed
+}
+
+// DistanceResult is returned by GET /v1/distance
+type DistanceResult struct {
+	IP1        string  `json:"ip1" example:"8.8.8.8"`         // First IP address, as requested
+	IP2        string  `json:"ip2" example:"1.1.1.1"`         // Second IP address, as requested
+	DistanceKm float64 `json:"distance_km" example:"11951.9"` // Great-circle distance between their locations
+}
+
+// Coordinates is a point on the Earth, e.g. the center or a vertex of a geofence
+type Coordinates struct {
+	Latitude  float64 `json:"latitude" example:"37.386"`     // Degrees north
+	Longitude float64 `json:"longitude" example:"-122.0838"` // Degrees east
+}
+
+// GeofenceResult is returned by GET /v1/geofence
+type GeofenceResult struct {
+	IP     string `json:"ip" example:"8.8.8.8"`  // The IP address, as requested
+	Inside bool   `json:"inside" example:"true"` // Whether the IP's location is inside the fence
+}
+
+// SubnetVerification is returned by GET /v1/subnet
+type SubnetVerification struct {
+	CIDR             string  `json:"cidr" example:"8.8.8.0/24"`      // The network that was sampled
+	RequestedCountry string  `json:"requested_country" example:"US"` // Country the network was checked against, as requested
+	MatchRate        float64 `json:"match_rate" example:"0.97"`      // Fraction of sampled addresses located in the requested country
+	SampleSize       int     `json:"sample_size" example:"100"`      // Number of addresses looked up
+}
+
+// SpanEntry records which range of a range mode store answered a lookup, for GET /debug/spans
+type SpanEntry struct {
+	Timestamp time.Time `json:"timestamp" example:"2024-01-01T12:00:00Z"` // When the lookup finished
+	IP        string    `json:"ip" example:"10.0.1.7"`                    // The IP that was looked up
+	Result    string    `json:"result" example:"range"`                   // "range", "exact" (a single-IP record), "not_found" or "error"
+	Start     string    `json:"start,omitempty" example:"10.0.0.0"`       // First address of the matched range
+	End       string    `json:"end,omitempty" example:"10.0.1.127"`       // Last address of the matched range
+	CIDRs     []string  `json:"cidrs,omitempty"`                          // Fewest CIDR blocks covering the range; more than one = not aligned on a block
+	Error     string    `json:"error,omitempty"`                          // Error message if the lookup failed
+}
+
+// SpansResponse is returned by GET /debug/spans, newest entries first
+type SpansResponse struct {
+	Spans   []SpanEntry `json:"spans"`    // Lookups answered by a range
+	NoSpans []SpanEntry `json:"no_spans"` // Lookups answered without one: exact matches, not found and errors
+}
+
+// NodeForResponse is returned by GET /meta/node-for
+type NodeForResponse struct {
+	Node string `json:"node" example:"instance-3"` // Instance lookups of the IP should be routed to
+}
+
+// BatchLookupResponse is returned by POST /v1/batch-lookup, keyed by IP as requested
+// Every requested IP is in exactly one of the maps
+type BatchLookupResponse struct {
+	Results map[string]*IPLocation `json:"results"` // Locations of the IPs found
+	Errors  map[string]string      `json:"errors"`  // Why the others weren't: "IP address not found", "invalid IP address format"...
+}
@@ -3,46 +3,164 @@ package router
 import (
 	"net/http"
 
+	"github.com/evyataryagoni/ip2country/internal/config"
 	"github.com/evyataryagoni/ip2country/internal/handler"
 	"github.com/evyataryagoni/ip2country/internal/limiter"
 	"github.com/evyataryagoni/ip2country/internal/logger"
 	custommiddleware "github.com/evyataryagoni/ip2country/internal/middleware"
 	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
 	v1 "github.com/evyataryagoni/ip2country/internal/router/v1"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/evyataryagoni/ip2country/pkg/routing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
+	"github.com/swaggo/swag"
 	_ "github.com/evyataryagoni/ip2country/docs" // Swagger docs
 )
 
 // SetupRouter creates and configures the Chi router with all middleware and routes
-func SetupRouter(ipHandler *handler.IPHandler, rateLimiter limiter.Limiter, m *metrics.Metrics, log *logger.Logger) chi.Router {
+func SetupRouter(appConfig *config.Config, ipHandler *handler.IPHandler, adminHandler *handler.AdminHandler, rateLimiter limiter.Limiter, fingerprintLimiter limiter.Limiter, uniqueIPs *redis.Client, tokens *limiter.DisposableTokenLimiter, blocklist *custommiddleware.Blocklist, openAPI *custommiddleware.OpenAPIValidator, adminSchema *custommiddleware.SchemaValidator, prefetcher *custommiddleware.Prefetcher, logRing *logger.RingLogger, spans *store.SpanStore, m *metrics.Metrics, log *logger.Logger) chi.Router {
 	r := chi.NewRouter()
 
-	// Apply global middleware (order matters: RequestID → RealIP → Logging → Recoverer → RateLimiting → Metrics)
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(custommiddleware.LoggingMiddleware(log))
-	r.Use(middleware.Recoverer)
-	r.Use(custommiddleware.RateLimitMiddleware(rateLimiter))
-	r.Use(custommiddleware.MetricsMiddleware(m))
+	// Apply global middleware (see globalMiddlewares for the order and why it matters)
+	for _, global := range globalMiddlewares(appConfig, rateLimiter, fingerprintLimiter, blocklist, m, log) {
+		r.Use(global.middleware)
+	}
+
+	// Lightweight latency view for operators without a metrics stack, served at /debug/timings
+	timings := custommiddleware.NewResponseTimes(custommiddleware.DefaultTimingsWindow)
 
 	// Mount v1 API routes under /v1 prefix (allows future versioning: /v2, /v3, etc.)
-	r.Mount("/v1", v1.SetupRoutes(ipHandler))
+	// Cache-Control headers apply to API responses only (health/metrics must never be cached)
+	// Unique IP analytics count API clients only (nil client = disabled)
+	// Requests not matching the Swagger spec are rejected with 400 before reaching the handlers (nil validator = disabled)
+	// Response times are recorded for API requests only, so health checks and scrapes don't dilute them
+	// Neighbours of each looked-up IP are prefetched after the response (nil prefetcher = disabled)
+	// Lookups of private IPs are answered without a store query when SKIP_PRIVATE_IPS is set
+	// Recent lookups hold other clients' IPs and results, so they need the admin API key like /debug/logs
+	r.With(
+		custommiddleware.ResponseTimeMiddleware(timings),
+		custommiddleware.CacheControlMiddleware(appConfig.ResponseCacheMaxAge),
+		custommiddleware.UniqueIPMiddleware(uniqueIPs, UniqueIPsLayout(appConfig.UniqueIPsWindow)),
+		custommiddleware.OpenAPIMiddleware(openAPI),
+		custommiddleware.PrefetchMiddleware(prefetcher),
+	).Mount("/v1", v1.SetupRoutes(ipHandler, custommiddleware.APIKeyMiddleware(appConfig.AdminAPIKey), FindCountryMiddlewares(appConfig, m)...))
+
+	// Operator endpoints (not versioned)
+	// Audit runs before the API key check so rejected attempts are logged too
+	// One-time tokens (nil tokens = disabled) are accepted anywhere except for issuing new tokens
+	// Bodies are checked against their JSON Schema once the caller is authenticated
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(custommiddleware.AuditMiddleware(log.WithComponent("audit")))
+		r.Use(custommiddleware.APIKeyMiddlewareWithTokens(appConfig.AdminAPIKey, tokens))
+		r.Use(custommiddleware.SchemaValidationMiddleware(adminSchema))
+
+		r.Get("/config", adminHandler.GetConfig)
+		r.Post("/config/reload", adminHandler.ReloadConfig)
+		r.Get("/analytics/unique-ips", adminHandler.UniqueIPs)
+		r.Get("/analytics/sample", adminHandler.Sample)
+		r.Get("/rate-limits", adminHandler.ListRateLimits)
+		r.Delete("/rate-limit/{ip}", adminHandler.ResetRateLimit)
+		r.Get("/ips", adminHandler.ListIPs)
+		r.Delete("/ips", adminHandler.DeleteIPs)
+		r.Post("/import", adminHandler.ImportCSV)
+		r.Get("/import/progress", adminHandler.ImportProgressEvents)
+		r.With(custommiddleware.APIKeyMiddleware(appConfig.AdminAPIKey)).Post("/token", adminHandler.IssueToken)
+	})
 
 	// Root-level routes (not versioned)
-	r.Get("/health", healthCheckHandler)
+	r.Get("/health", ipHandler.Health)
 	r.Handle("/metrics", promhttp.Handler())
-	r.Get("/swagger/*", httpSwagger.Handler(
+	r.Get("/debug/timings", custommiddleware.TimingsHandler(timings))
+	if len(appConfig.RoutingNodes) > 0 {
+		r.Get("/meta/node-for", handler.NodeForHandler(routing.ConsistentHasher{Nodes: appConfig.RoutingNodes}))
+	}
+
+	// Recent log lines can hold client IPs and errors, so they need the admin API key (nil ring = disabled)
+	if logRing != nil {
+		r.With(custommiddleware.APIKeyMiddleware(appConfig.AdminAPIKey)).Get("/debug/logs", handler.DebugLogsHandler(logRing))
+	}
+	// Likewise for the looked-up IPs and ranges of the datastore (nil = DEBUG_SPANS disabled)
+	if spans != nil {
+		r.With(custommiddleware.APIKeyMiddleware(appConfig.AdminAPIKey)).Get("/debug/spans", handler.DebugSpansHandler(spans))
+	}
+	// The generated docs register the spec served at /swagger/doc.json (nil if swag init wasn't run)
+	// It names the host and scheme each request came in on, so "Try it out" works behind a proxy
+	spec, _ := swag.GetSwagger(swag.Name).(*swag.Spec)
+	r.With(custommiddleware.SwaggerDocMiddleware(spec)).Method(http.MethodGet, "/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	))
 
 	return r
 }
 
-// healthCheckHandler returns 200 OK if the service is running
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// namedMiddleware is a middleware with a name for tests to refer to it by
+type namedMiddleware struct {
+	name       string
+	middleware func(http.Handler) http.Handler
+}
+
+// globalMiddlewares returns the middleware every request goes through, outermost first
+// Order matters: ProxyAware → NodeIdentity → RequestContext → Logging → Recoverer → Blocklist → Backpressure → RateLimit → Fingerprint → Metrics
+//   - ProxyAware comes first so every later middleware sees the scheme and host the client used
+//     (forwarded by TRUSTED_PROXIES; an invalid list is logged and trusts no proxy)
+//   - NodeIdentity comes next so every response, including errors, names the instance that served it
+//   - RequestContext assigns the request ID and client IP that every later middleware reads
+//   - Recoverer sits inside Logging, so a panic anywhere further in is logged as a completed 500
+//   - Blocklisted clients are rejected before they take capacity or rate limit state (nil blocklist = disabled)
+//   - RATE_LIMIT_EXEMPT_PATHS (default /health and /metrics) skip backpressure and both rate limiters
+func globalMiddlewares(appConfig *config.Config, rateLimiter limiter.Limiter, fingerprintLimiter limiter.Limiter, blocklist *custommiddleware.Blocklist, m *metrics.Metrics, log *logger.Logger) []namedMiddleware {
+	exemptPaths := custommiddleware.WithExemptPaths(appConfig.RateLimitExemptPaths...)
+	trustedProxies, err := custommiddleware.ParseTrustedProxies(appConfig.TrustedProxies)
+	if err != nil {
+		log.Error().Err(err).Msg("Ignoring TRUSTED_PROXIES")
+	}
+	return []namedMiddleware{
+		{"ProxyAware", custommiddleware.ProxyAwareMiddleware(trustedProxies)},
+		{"NodeIdentity", custommiddleware.NodeIdentityMiddleware(appConfig.NodeID)},
+		{"RequestContext", custommiddleware.RequestContextMiddleware},
+		{"Logging", custommiddleware.LoggingMiddleware(log, LoggingOptions(appConfig)...)},
+		{"Recoverer", middleware.Recoverer},
+		{"Blocklist", custommiddleware.BlocklistMiddleware(blocklist, m)},
+		{"Backpressure", custommiddleware.BackpressureMiddleware(appConfig.BackpressureMaxInFlight, m, exemptPaths)},
+		{"RateLimit", custommiddleware.RateLimitMiddleware(rateLimiter, exemptPaths)},
+		{"Fingerprint", custommiddleware.FingerprintMiddleware(fingerprintLimiter, exemptPaths)},
+		{"Metrics", custommiddleware.MetricsMiddleware(m)},
+	}
+}
+
+// logBodyMaxBytes is how much of each request body is logged with LOG_BODY=true
+const logBodyMaxBytes = 4096
+
+// LoggingOptions enables request body logging when both LOG_LEVEL=debug and LOG_BODY=true
+func LoggingOptions(appConfig *config.Config) []custommiddleware.LoggingOption {
+	if !appConfig.LogBody || appConfig.LogLevel != "debug" {
+		return nil
+	}
+	return []custommiddleware.LoggingOption{
+		custommiddleware.WithBodyLogging(logBodyMaxBytes),
+		custommiddleware.WithBodyExcludePaths(appConfig.LogBodyExcludePaths...),
+	}
+}
+
+// FindCountryMiddlewares returns the middlewares in front of GET /v1/find-country:
+// SkipPrivateIPMiddleware when SKIP_PRIVATE_IPS=true, none otherwise
+func FindCountryMiddlewares(appConfig *config.Config, m *metrics.Metrics) []func(http.Handler) http.Handler {
+	if !appConfig.SkipPrivateIPs {
+		return nil
+	}
+	response := models.IPLocation{City: appConfig.PrivateIPCity, Country: appConfig.PrivateIPCountry}
+	return []func(http.Handler) http.Handler{custommiddleware.SkipPrivateIPMiddleware(response, m)}
+}
+
+// UniqueIPsLayout maps the configured unique IP window ("daily" or "monthly") to its key layout
+func UniqueIPsLayout(window string) string {
+	if window == "monthly" {
+		return custommiddleware.UniqueIPsMonthly
+	}
+	return custommiddleware.UniqueIPsDaily
 }
@@ -0,0 +1,401 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/handler"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	custommiddleware "github.com/evyataryagoni/ip2country/internal/middleware"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/service"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// TestLoggingOptions tests that body logging needs both LOG_LEVEL=debug and LOG_BODY=true
+func TestLoggingOptions(t *testing.T) {
+	tests := []struct {
+		logLevel string
+		logBody  bool
+		enabled  bool
+	}{
+		{"debug", true, true},
+		{"info", true, false},
+		{"debug", false, false},
+		{"info", false, false},
+	}
+
+	for _, tt := range tests {
+		appConfig := &config.Config{LogLevel: tt.logLevel, LogBody: tt.logBody}
+		if got := len(LoggingOptions(appConfig)) > 0; got != tt.enabled {
+			t.Errorf("LOG_LEVEL=%s LOG_BODY=%v: expected body logging %v, got %v", tt.logLevel, tt.logBody, tt.enabled, got)
+		}
+	}
+}
+
+// TestFindCountryMiddlewares tests that private IPs are only skipped with SKIP_PRIVATE_IPS=true
+func TestFindCountryMiddlewares(t *testing.T) {
+	if got := FindCountryMiddlewares(&config.Config{}, nil); len(got) != 0 {
+		t.Errorf("expected no middleware by default, got %d", len(got))
+	}
+	appConfig := &config.Config{SkipPrivateIPs: true, PrivateIPCity: "Private", PrivateIPCountry: "Private Network"}
+	if got := FindCountryMiddlewares(appConfig, nil); len(got) != 1 {
+		t.Errorf("expected 1 middleware with SKIP_PRIVATE_IPS=true, got %d", len(got))
+	}
+}
+
+// ChainRecorder records the order in which a chain of middlewares hands a request on
+// Each middleware wrapped with Wrap appends its name when it calls the next handler;
+// the recorder itself is the final handler and appends "handler"
+type ChainRecorder struct {
+	mu    sync.Mutex
+	calls []string
+	panic bool // Panic in the final handler after recording
+}
+
+// Wrap returns mw with its next handler recording name
+func (c *ChainRecorder) Wrap(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.record(name)
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// ServeHTTP records "handler" and answers 200, or panics if c.panic is set
+func (c *ChainRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.record("handler")
+	if c.panic {
+		panic("handler failed")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Calls returns the names recorded so far
+func (c *ChainRecorder) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.calls)
+}
+
+func (c *ChainRecorder) record(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, name)
+}
+
+// countingLimiter counts its Allow calls, allowing every request unless deny is set
+type countingLimiter struct {
+	mu    sync.Mutex
+	calls int
+	deny  bool // Reject every request instead
+}
+
+func (l *countingLimiter) Allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	return !l.deny
+}
+
+func (l *countingLimiter) Reset(ip string) error { return nil }
+func (l *countingLimiter) Close() error          { return nil }
+
+func (l *countingLimiter) List() (map[string]limiter.RateLimitStatus, error) {
+	return map[string]limiter.RateLimitStatus{}, nil
+}
+
+// newTestLogger returns a logger writing JSON lines to buf
+func newTestLogger(buf *bytes.Buffer) *logger.Logger {
+	zl := zerolog.New(buf)
+	return &logger.Logger{Logger: &zl}
+}
+
+// recordedChain wraps each global middleware with a recorder and builds the chain in front of it
+func recordedChain(recorder *ChainRecorder, lim *countingLimiter, log *logger.Logger) (http.Handler, []string) {
+	globals := globalMiddlewares(&config.Config{}, lim, nil, nil,
+		metrics.NewWithRegistry(prometheus.NewRegistry()), log)
+
+	names := make([]string, len(globals))
+	var h http.Handler = recorder
+	for i := len(globals) - 1; i >= 0; i-- {
+		names[i] = globals[i].name
+		h = recorder.Wrap(globals[i].name, globals[i].middleware)(h)
+	}
+	return h, names
+}
+
+// TestMiddlewareOrder tests that every request goes through the global middleware in the declared order
+func TestMiddlewareOrder(t *testing.T) {
+	recorder := &ChainRecorder{}
+	lim := &countingLimiter{}
+	chain, _ := recordedChain(recorder, lim, newTestLogger(&bytes.Buffer{}))
+
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	expected := []string{
+		"ProxyAware", "NodeIdentity", "RequestContext", "Logging", "Recoverer",
+		"Blocklist", "Backpressure", "RateLimit", "Fingerprint", "Metrics", "handler",
+	}
+	if got := recorder.Calls(); !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if lim.calls != 1 {
+		t.Errorf("expected the rate limiter to be asked once, got %d", lim.calls)
+	}
+}
+
+// TestMiddlewareOrder_RecovererInsideLogging tests that Recoverer runs between Logging and RateLimit,
+// so a panic further in is caught and logged as a completed 500 request
+func TestMiddlewareOrder_RecovererInsideLogging(t *testing.T) {
+	var logBuf bytes.Buffer
+	recorder := &ChainRecorder{panic: true}
+	chain, names := recordedChain(recorder, &countingLimiter{}, newTestLogger(&logBuf))
+
+	logging, recoverer, rateLimit := slices.Index(names, "Logging"), slices.Index(names, "Recoverer"), slices.Index(names, "RateLimit")
+	if !(logging < recoverer && recoverer < rateLimit) {
+		t.Fatalf("expected Logging < Recoverer < RateLimit, got positions %d, %d, %d in %v", logging, recoverer, rateLimit, names)
+	}
+
+	rec := httptest.NewRecorder()
+	chain.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 after the panic, got %d", rec.Code)
+	}
+
+	var completed map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logBuf.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err == nil && entry["message"] == "Request completed" {
+			completed = entry
+		}
+	}
+	if completed == nil {
+		t.Fatalf("expected the request to be logged as completed, got %s", logBuf.String())
+	}
+	if completed["status"] != float64(http.StatusInternalServerError) || completed["level"] != "error" {
+		t.Errorf("expected an error entry with status 500, got %v", completed)
+	}
+}
+
+// newTestRouter sets up the router over the mock store with lim as the per-IP rate limiter
+func newTestRouter(appConfig *config.Config, lim *countingLimiter) http.Handler {
+	ipHandler := handler.NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	return SetupRouter(appConfig, ipHandler, handler.NewAdminHandler(nil), lim, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		metrics.NewWithRegistry(prometheus.NewRegistry()), newTestLogger(&bytes.Buffer{}))
+}
+
+// TestSetupRouter_HealthNotRateLimited tests that /health and /metrics bypass the rate limiter by default, so
+// Kubernetes probes and Prometheus scrapes neither get 429 nor count against the limit, while API requests are still limited
+func TestSetupRouter_HealthNotRateLimited(t *testing.T) {
+	lim := &countingLimiter{deny: true}
+	r := newTestRouter(&config.Config{RateLimitExemptPaths: []string{"/health", "/metrics"}}, lim)
+
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/health", "/metrics"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected %s to return 200, got %d: %s", path, rec.Code, rec.Body.String())
+			}
+		}
+	}
+	if lim.calls != 0 {
+		t.Errorf("expected /health and /metrics not to reach the rate limiter, got %d calls", lim.calls)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected API requests to be rate limited, got %d", rec.Code)
+	}
+	if lim.calls != 1 {
+		t.Errorf("expected 1 rate limiter call, got %d", lim.calls)
+	}
+}
+
+// TestSetupRouter_CustomExemptPaths tests that RATE_LIMIT_EXEMPT_PATHS replaces the default list rather than adding to it
+func TestSetupRouter_CustomExemptPaths(t *testing.T) {
+	lim := &countingLimiter{deny: true}
+	r := newTestRouter(&config.Config{RateLimitExemptPaths: []string{"/v1/countries"}}, lim)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/countries", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the custom exempt path to return 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected /health to be rate limited once the default list is replaced, got %d", rec.Code)
+	}
+}
+
+// blockingLimiter holds every request it's asked about until release is closed, signalling entered for the first
+type blockingLimiter struct {
+	countingLimiter
+	once    sync.Once
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (l *blockingLimiter) Allow(ip string) bool {
+	l.once.Do(func() { close(l.entered) })
+	<-l.release
+	return true
+}
+
+// TestSetupRouter_HealthAtCapacity tests that RATE_LIMIT_EXEMPT_PATHS also skip backpressure, so /health and /metrics
+// answer while the server is at capacity and API requests are shed with 503
+func TestSetupRouter_HealthAtCapacity(t *testing.T) {
+	lim := &blockingLimiter{entered: make(chan struct{}), release: make(chan struct{})}
+	appConfig := &config.Config{BackpressureMaxInFlight: 1, RateLimitExemptPaths: []string{"/health", "/metrics"}}
+	ipHandler := handler.NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	r := SetupRouter(appConfig, ipHandler, handler.NewAdminHandler(nil), lim, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		metrics.NewWithRegistry(prometheus.NewRegistry()), newTestLogger(&bytes.Buffer{}))
+
+	// The first API request takes the only slot, held in the rate limiter
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8", nil))
+	}()
+	<-lim.entered
+
+	for _, path := range []string{"/health", "/metrics"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected %s to return 200 at capacity, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/countries", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected API requests to be shed at capacity, got %d", rec.Code)
+	}
+
+	close(lim.release)
+	<-done
+}
+
+// TestSetupRouter_DebugLogs tests that /debug/logs serves the log ring behind the admin API key, and is absent without a ring
+func TestSetupRouter_DebugLogs(t *testing.T) {
+	appConfig := &config.Config{AdminAPIKey: "secret"}
+	ipHandler := handler.NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	ring := logger.NewRingLogger(10)
+	ring.Write([]byte(`{"message":"started"}` + "\n"))
+	r := SetupRouter(appConfig, ipHandler, handler.NewAdminHandler(nil), &countingLimiter{}, nil, nil, nil, nil, nil, nil, nil, ring, nil,
+		metrics.NewWithRegistry(prometheus.NewRegistry()), newTestLogger(&bytes.Buffer{}))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/logs", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the API key, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/logs", nil)
+	req.Header.Set(custommiddleware.APIKeyHeader, "secret")
+	r.ServeHTTP(rec, req)
+	var lines []string
+	if err := json.NewDecoder(rec.Body).Decode(&lines); err != nil || rec.Code != http.StatusOK || len(lines) != 1 {
+		t.Errorf("expected the logged line with 200, got %d: %v (%v)", rec.Code, lines, err)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/debug/logs", nil)
+	req.Header.Set(custommiddleware.APIKeyHeader, "secret")
+	newTestRouter(appConfig, &countingLimiter{}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a log ring, got %d", rec.Code)
+	}
+}
+
+// TestSetupRouter_RecentRequiresAPIKey tests that /v1/recent, which lists other clients' lookups, sits behind the admin API key
+func TestSetupRouter_RecentRequiresAPIKey(t *testing.T) {
+	r := newTestRouter(&config.Config{AdminAPIKey: "secret"}, &countingLimiter{})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/recent", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the API key, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/recent", nil)
+	req.Header.Set(custommiddleware.APIKeyHeader, "secret")
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with the API key, got %d", rec.Code)
+	}
+}
+
+// TestSetupRouter_AdminLockedWithoutAPIKey tests that admin and debug endpoints reject every request while ADMIN_API_KEY is unset
+func TestSetupRouter_AdminLockedWithoutAPIKey(t *testing.T) {
+	ipHandler := handler.NewIPHandler(service.NewIPService(store.NewMockStore(), nil, nil))
+	spans := store.NewSpanStore(store.NewMockStore(), 0)
+	r := SetupRouter(&config.Config{}, ipHandler, handler.NewAdminHandler(nil), &countingLimiter{}, nil, nil, nil, nil, nil, nil, nil,
+		logger.NewRingLogger(0), spans, metrics.NewWithRegistry(prometheus.NewRegistry()), newTestLogger(&bytes.Buffer{}))
+
+	endpoints := []struct{ method, path string }{
+		{http.MethodGet, "/admin/config"},
+		{http.MethodPost, "/admin/config/reload"},
+		{http.MethodDelete, "/admin/ips?country=France"},
+		{http.MethodPost, "/admin/import"},
+		{http.MethodDelete, "/admin/rate-limit/192.0.2.1"},
+		{http.MethodPost, "/admin/token"},
+		{http.MethodGet, "/v1/recent"},
+		{http.MethodGet, "/debug/logs"},
+		{http.MethodGet, "/debug/spans"},
+	}
+	for _, endpoint := range endpoints {
+		for _, key := range []string{"", "anything"} {
+			req := httptest.NewRequest(endpoint.method, endpoint.path, nil)
+			if key != "" {
+				req.Header.Set(custommiddleware.APIKeyHeader, key)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with key %q: expected 401, got %d", endpoint.method, endpoint.path, key, rec.Code)
+			}
+		}
+	}
+}
+
+// TestSetupRouter_NodeFor tests that /meta/node-for names one of ROUTING_NODES, and is absent without them
+func TestSetupRouter_NodeFor(t *testing.T) {
+	nodes := []string{"instance-1", "instance-2", "instance-3"}
+	r := newTestRouter(&config.Config{RoutingNodes: nodes}, &countingLimiter{})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/meta/node-for?ip=8.8.8.8", nil))
+	var resp models.NodeForResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK || !slices.Contains(nodes, resp.Node) {
+		t.Errorf("expected one of %v with 200, got %d: %+v (%v)", nodes, rec.Code, resp, err)
+	}
+
+	rec = httptest.NewRecorder()
+	newTestRouter(&config.Config{}, &countingLimiter{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/meta/node-for?ip=8.8.8.8", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without ROUTING_NODES, got %d", rec.Code)
+	}
+}
@@ -1,19 +1,31 @@
 package v1
 
 import (
+	"net/http"
+
 	"github.com/evyataryagoni/ip2country/internal/handler"
 	"github.com/go-chi/chi/v5"
 )
 
 // SetupRoutes configures all v1 API routes
-func SetupRoutes(ipHandler *handler.IPHandler) chi.Router {
+// admin authenticates GET /recent, whose entries are other clients' lookups
+// findCountry middlewares run in front of GET /find-country only
+func SetupRoutes(ipHandler *handler.IPHandler, admin func(http.Handler) http.Handler, findCountry ...func(http.Handler) http.Handler) chi.Router {
 	r := chi.NewRouter()
 
-	r.Get("/find-country", ipHandler.FindCountry)
+	r.With(findCountry...).Get("/find-country", ipHandler.FindCountry)
+	r.Post("/find-country", ipHandler.FindCountryJSON) // For proxies that strip query parameters
+	r.Get("/whois", ipHandler.Whois)
+	r.With(admin).Get("/recent", ipHandler.Recent)
+	r.Get("/countries", ipHandler.ListCountries)
+	r.Get("/search", ipHandler.Search)
+	r.Get("/subnet", ipHandler.VerifySubnet)
+	r.Get("/distance", ipHandler.Distance)
+	r.Get("/geofence", ipHandler.Geofence)
+	r.Post("/batch-lookup", ipHandler.BatchLookup)
 
 	// Future v1 endpoints can be added here:
 	// r.Get("/lookup", ipHandler.Lookup)
-	// r.Get("/batch", ipHandler.BatchLookup)
 
 	return r
 }
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// DefaultBatchWorkers caps the goroutines used by BatchFindAsync when no count was set
+const DefaultBatchWorkers = 16
+
+// LookupResult is the outcome of an asynchronous lookup
+type LookupResult struct {
+	Location *models.IPLocation
+	Error    error
+}
+
+// IndexedResult is a LookupResult tagged with the position of its IP in the BatchFindAsync input
+// Results arrive in completion order; Index lets callers restore input order
+type IndexedResult struct {
+	Index int
+	LookupResult
+}
+
+// FindByIPAsync looks up ip in a separate goroutine
+// The returned channel receives exactly one result and is then closed.
+// If ctx is cancelled before the result is received, the channel is closed without a value
+// and the goroutine exits - callers may abandon the channel safely
+func (s *IPService) FindByIPAsync(ctx context.Context, ip string) <-chan LookupResult {
+	out := make(chan LookupResult)
+
+	go func() {
+		defer close(out)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		location, err := s.LookupIP(ctx, ip)
+		select {
+		case out <- LookupResult{Location: location, Error: err}:
+		case <-ctx.Done():
+		}
+	}()
+
+	return out
+}
+
+// SetBatchWorkers sets how many lookups BatchFindAsync (and so LookupBatch and VerifySubnet) runs at once
+// n <= 0 restores DefaultBatchWorkers
+func (s *IPService) SetBatchWorkers(n int) {
+	s.batchWorkers = n
+}
+
+// BatchFindAsync looks up all ips concurrently (at most the configured number of workers at a time)
+// The returned channel receives one IndexedResult per IP and is closed once all are sent.
+// Cancelling ctx stops the remaining lookups and closes the channel early
+func (s *IPService) BatchFindAsync(ctx context.Context, ips []string) <-chan IndexedResult {
+	out := make(chan IndexedResult)
+	indexes := make(chan int)
+
+	workers := s.batchWorkers
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(ips)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				location, err := s.LookupIP(ctx, ips[i])
+				select {
+				case out <- IndexedResult{Index: i, LookupResult: LookupResult{Location: location, Error: err}}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Feed indexes to the workers, then close out once they've all finished
+	go func() {
+		defer func() {
+			close(indexes)
+			wg.Wait()
+			close(out)
+		}()
+
+		for i := range ips {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// waitForGoroutines waits until the goroutine count drops back to baseline, failing after a timeout
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine leak: %d goroutines running, expected at most %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestIPService_FindByIPAsync tests that exactly one correct result is sent before the channel closes
+func TestIPService_FindByIPAsync(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	results := service.FindByIPAsync(context.Background(), "8.8.8.8")
+
+	result, ok := <-results
+	if !ok {
+		t.Fatal("expected a result, channel was closed")
+	}
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.Location.City != "Mountain View" {
+		t.Errorf("expected 'Mountain View', got '%s'", result.Location.City)
+	}
+
+	if _, ok := <-results; ok {
+		t.Error("expected channel to be closed after one result")
+	}
+}
+
+// TestIPService_FindByIPAsync_Error tests that lookup errors are delivered in the result
+func TestIPService_FindByIPAsync_Error(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	result := <-service.FindByIPAsync(context.Background(), "not-an-ip")
+
+	if result.Error == nil || result.Location != nil {
+		t.Errorf("expected error and no location, got %+v", result)
+	}
+}
+
+// TestIPService_FindByIPAsync_Cancelled tests that the channel closes without a value on cancellation
+func TestIPService_FindByIPAsync_Cancelled(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPDelay = 50 * time.Millisecond
+	service := NewIPService(mockStore, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := service.FindByIPAsync(ctx, "8.8.8.8")
+	cancel()
+
+	if _, ok := <-results; ok {
+		t.Error("expected channel to be closed without a result")
+	}
+	waitForGoroutines(t, baseline)
+}
+
+// TestIPService_FindByIPAsync_Concurrent tests that concurrent calls get independent channels
+func TestIPService_FindByIPAsync_Concurrent(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	google := service.FindByIPAsync(context.Background(), "8.8.8.8")
+	cloudflare := service.FindByIPAsync(context.Background(), "1.1.1.1")
+
+	// Read in reverse order of the calls
+	if result := <-cloudflare; result.Location == nil || result.Location.City != "Sydney" {
+		t.Errorf("expected Sydney, got %+v", result)
+	}
+	if result := <-google; result.Location == nil || result.Location.City != "Mountain View" {
+		t.Errorf("expected Mountain View, got %+v", result)
+	}
+}
+
+// TestIPService_FindByIPAsync_AbandonedChannel tests that an unread channel doesn't leak its goroutine
+func TestIPService_FindByIPAsync_AbandonedChannel(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 50; i++ {
+		service.FindByIPAsync(ctx, "8.8.8.8") // Never read
+	}
+	cancel()
+
+	waitForGoroutines(t, baseline)
+}
+
+// TestIPService_BatchFindAsync tests that every IP gets a result carrying its input index
+func TestIPService_BatchFindAsync(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	ips := []string{"8.8.8.8", "1.1.1.1", "9.9.9.9", "invalid"}
+
+	got := make(map[int]IndexedResult)
+	for result := range service.BatchFindAsync(context.Background(), ips) {
+		if _, dup := got[result.Index]; dup {
+			t.Fatalf("duplicate result for index %d", result.Index)
+		}
+		got[result.Index] = result
+	}
+
+	if len(got) != len(ips) {
+		t.Fatalf("expected %d results, got %d", len(ips), len(got))
+	}
+	if got[0].Location == nil || got[0].Location.City != "Mountain View" {
+		t.Errorf("index 0: expected Mountain View, got %+v", got[0])
+	}
+	if got[1].Location == nil || got[1].Location.City != "Sydney" {
+		t.Errorf("index 1: expected Sydney, got %+v", got[1])
+	}
+	if got[2].Error == nil || got[3].Error == nil {
+		t.Errorf("expected errors for unknown and invalid IPs, got %+v and %+v", got[2], got[3])
+	}
+}
+
+// TestIPService_BatchFindAsync_Empty tests that an empty batch closes immediately
+func TestIPService_BatchFindAsync_Empty(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	if _, ok := <-service.BatchFindAsync(context.Background(), nil); ok {
+		t.Error("expected closed channel for empty batch")
+	}
+}
+
+// TestIPService_BatchFindAsync_Cancelled tests that cancellation closes the channel early without leaks
+func TestIPService_BatchFindAsync_Cancelled(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPDelay = 10 * time.Millisecond
+	service := NewIPService(mockStore, nil, nil)
+
+	ips := make([]string, 1000)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := service.BatchFindAsync(ctx, ips)
+	<-results
+	cancel()
+
+	// Drain: the channel must close well before all 1000 lookups complete
+	count := 1
+	for range results {
+		count++
+	}
+	if count == len(ips) {
+		t.Error("expected cancellation to stop the batch early")
+	}
+	waitForGoroutines(t, baseline)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// LookupBatch looks up every IP of ips like LookupIP, concurrently (see SetBatchWorkers)
+// Each distinct IP is looked up once and lands in exactly one of the maps, keyed by the IP as given:
+// its location, or its error (pkg/errors.ErrInvalidIP, ErrNotFound, a store error...).
+// If ctx is cancelled, the IPs not looked up yet get ctx's error
+func (s *IPService) LookupBatch(ctx context.Context, ips []string) (map[string]*models.IPLocation, map[string]error) {
+	unique := make([]string, 0, len(ips))
+	seen := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		if !seen[ip] {
+			seen[ip] = true
+			unique = append(unique, ip)
+		}
+	}
+
+	results := make(map[string]*models.IPLocation, len(unique))
+	errs := make(map[string]error)
+	for result := range s.BatchFindAsync(ctx, unique) {
+		ip := unique[result.Index]
+		if result.Error != nil {
+			errs[ip] = result.Error
+		} else {
+			results[ip] = result.Location
+		}
+	}
+
+	// BatchFindAsync stops early when ctx is cancelled
+	if len(results)+len(errs) < len(unique) {
+		for _, ip := range unique {
+			if _, ok := results[ip]; !ok && errs[ip] == nil {
+				errs[ip] = ctx.Err()
+			}
+		}
+	}
+	return results, errs
+}
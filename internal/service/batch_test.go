@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// TestIPService_LookupBatch tests that every IP lands in one of the maps, with the store's answer
+func TestIPService_LookupBatch(t *testing.T) {
+	mockStore := store.NewMockStore()
+	svc := NewIPService(mockStore, nil, nil)
+
+	locations, errs := svc.LookupBatch(context.Background(), []string{"8.8.8.8", "1.1.1.1", "9.9.9.9", "not-an-ip", "8.8.8.8"})
+
+	expectedLocations, expectedErrs := store.NewMockStore().BatchFindByIP(context.Background(), []string{"8.8.8.8", "1.1.1.1", "9.9.9.9"})
+	if len(locations) != len(expectedLocations) {
+		t.Fatalf("expected %d locations, got %+v", len(expectedLocations), locations)
+	}
+	for ip, expected := range expectedLocations {
+		if got := locations[ip]; got == nil || *got != *expected {
+			t.Errorf("%s: expected %+v, got %+v", ip, expected, got)
+		}
+	}
+	if len(errs) != len(expectedErrs)+1 {
+		t.Fatalf("expected %d errors, got %v", len(expectedErrs)+1, errs)
+	}
+	if !errors.Is(errs["9.9.9.9"], pkerr.ErrNotFound) || !errors.Is(expectedErrs["9.9.9.9"], pkerr.ErrNotFound) {
+		t.Errorf("expected 9.9.9.9 not found, got %v", errs["9.9.9.9"])
+	}
+	if !errors.Is(errs["not-an-ip"], pkerr.ErrInvalidIP) {
+		t.Errorf("expected not-an-ip invalid, got %v", errs["not-an-ip"])
+	}
+
+	// The duplicate 8.8.8.8 is looked up once; the invalid IP never reaches the store
+	if len(mockStore.FindByIPCalls) != 3 {
+		t.Errorf("expected 3 store lookups, got %v", mockStore.FindByIPCalls)
+	}
+}
+
+// TestIPService_LookupBatch_Workers tests that no more than the configured number of lookups run at once
+func TestIPService_LookupBatch_Workers(t *testing.T) {
+	mockStore := store.NewEmptyMockStore()
+	mockStore.FindByIPDelay = 50 * time.Millisecond
+	ips := make([]string, 8)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.0.%d", i)
+	}
+
+	svc := NewIPService(mockStore, nil, nil)
+	svc.SetBatchWorkers(2)
+	start := time.Now()
+	_, errs := svc.LookupBatch(context.Background(), ips)
+
+	// 8 lookups of 50ms, 2 at a time
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected at least 200ms with 2 workers, took %v", elapsed)
+	}
+	if len(errs) != len(ips) {
+		t.Errorf("expected %d not found errors, got %v", len(ips), errs)
+	}
+}
+
+// TestIPService_LookupBatch_Cancelled tests that IPs not looked up before cancellation get the context's error
+func TestIPService_LookupBatch_Cancelled(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.FindByIPDelay = time.Second
+
+	svc := NewIPService(mockStore, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	locations, errs := svc.LookupBatch(ctx, []string{"8.8.8.8", "1.1.1.1"})
+
+	if len(locations) != 0 || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %+v and %v", locations, errs)
+	}
+	for ip, err := range errs {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected the deadline error, got %v", ip, err)
+		}
+	}
+}
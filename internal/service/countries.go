@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// countriesCacheTTL is how long a ListCountries result is reused before the store is asked again
+// The country list only changes on data loads, and listing it can mean a full table scan
+const countriesCacheTTL = 5 * time.Minute
+
+// errCountriesNotSupported is returned by ListCountries for stores that can't enumerate their data
+var errCountriesNotSupported = fmt.Errorf("listing countries is %w", pkerr.ErrNotSupported)
+
+// countriesCache holds the last ListCountries result
+type countriesCache struct {
+	mu        sync.Mutex
+	countries []string
+	expires   time.Time
+}
+
+// ListCountries returns every distinct country the store has data for, sorted alphabetically
+// Results are cached for countriesCacheTTL
+//
+// Stores implementing store.CountryLister answer directly; other stores implementing
+// store.Iterator are scanned. Stores implementing neither (e.g. MaxMind) return an error
+func (s *IPService) ListCountries(ctx context.Context) ([]string, error) {
+	s.countries.mu.Lock()
+	defer s.countries.mu.Unlock()
+
+	if s.countries.countries != nil && time.Now().Before(s.countries.expires) {
+		return s.countries.countries, nil
+	}
+
+	countries, err := s.listCountries(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to list countries")
+		return nil, err
+	}
+	if countries == nil {
+		countries = []string{}
+	}
+
+	s.countries.countries = countries
+	s.countries.expires = time.Now().Add(countriesCacheTTL)
+	return countries, nil
+}
+
+// listCountries asks the store for its countries, bypassing the cache
+func (s *IPService) listCountries(ctx context.Context) ([]string, error) {
+	switch st := s.store.(type) {
+	case store.CountryLister:
+		return st.ListCountries(ctx)
+
+	case store.Iterator:
+		seen := make(map[string]bool)
+		var countries []string
+		err := st.Iterate(func(location *models.IPLocation) error {
+			if location.Country != "" && !seen[location.Country] {
+				seen[location.Country] = true
+				countries = append(countries, location.Country)
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(countries)
+		return countries, nil
+
+	default:
+		return nil, errCountriesNotSupported
+	}
+}
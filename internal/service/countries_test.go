@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// TestIPService_ListCountries_Cached tests that the store is asked once per cache period
+func TestIPService_ListCountries_Cached(t *testing.T) {
+	mockStore := store.NewMockStore()
+	service := NewIPService(mockStore, nil, nil)
+
+	for i := 0; i < 3; i++ {
+		countries, err := service.ListCountries(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(countries, []string{"Australia", "United States"}) {
+			t.Errorf("expected [Australia United States], got %v", countries)
+		}
+	}
+	if mockStore.ListCountriesCalls != 1 {
+		t.Errorf("expected 1 store call within the cache period, got %d", mockStore.ListCountriesCalls)
+	}
+
+	// Once the cache expires the store is asked again
+	mockStore.Data["2.22.233.255"] = &models.IPLocation{IP: "2.22.233.255", City: "London", Country: "United Kingdom"}
+	service.countries.expires = time.Now().Add(-time.Second)
+
+	countries, _ := service.ListCountries(context.Background())
+	if mockStore.ListCountriesCalls != 2 {
+		t.Errorf("expected a second store call after expiry, got %d", mockStore.ListCountriesCalls)
+	}
+	if len(countries) != 3 {
+		t.Errorf("expected the refreshed list, got %v", countries)
+	}
+}
+
+// TestIPService_ListCountries_Error tests that store errors are returned and not cached
+func TestIPService_ListCountries_Error(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.ListCountriesError = errors.New("connection refused")
+	service := NewIPService(mockStore, nil, nil)
+
+	if _, err := service.ListCountries(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	mockStore.ListCountriesError = nil
+	if _, err := service.ListCountries(context.Background()); err != nil {
+		t.Errorf("expected the retry to succeed, got %v", err)
+	}
+}
+
+// findOnlyStore implements only the Store interface
+type findOnlyStore struct{}
+
+func (findOnlyStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return nil, errors.New("unused")
+}
+func (findOnlyStore) Close() error { return nil }
+
+// iterOnlyStore lists countries only through the Iterator interface
+type iterOnlyStore struct {
+	findOnlyStore
+	locations []*models.IPLocation
+}
+
+func (s iterOnlyStore) Iterate(fn func(location *models.IPLocation) error) error {
+	for _, location := range s.locations {
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestIPService_ListCountries_Fallbacks tests Iterator stores and stores that can't enumerate
+func TestIPService_ListCountries_Fallbacks(t *testing.T) {
+	iterStore := iterOnlyStore{locations: []*models.IPLocation{
+		{IP: "8.8.8.8", Country: "United States"},
+		{IP: "1.1.1.1", Country: "Australia"},
+		{IP: "8.8.4.4", Country: "United States"},
+		{IP: "10.0.0.1", Country: ""},
+	}}
+
+	countries, err := NewIPService(iterStore, nil, nil).ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(countries, []string{"Australia", "United States"}) {
+		t.Errorf("expected [Australia United States], got %v", countries)
+	}
+
+	_, err = NewIPService(findOnlyStore{}, nil, nil).ListCountries(context.Background())
+	if !errors.Is(err, pkerr.ErrNotSupported) {
+		t.Errorf("expected not supported error, got %v", err)
+	}
+}
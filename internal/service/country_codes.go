@@ -0,0 +1,101 @@
+package service
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// continentCodes are the two-letter continent codes (as used by MaxMind and GeoNames) of the
+// UN M.49 regions containing every country. Checked in order: the Americas are split into
+// South America and, for the rest of the Americas (Central America and the Caribbean included), North America
+var continentCodes = []struct {
+	region language.Region
+	code   string
+}{
+	{language.MustParseRegion("002"), "AF"}, // Africa
+	{language.MustParseRegion("AQ"), "AN"},  // Antarctica
+	{language.MustParseRegion("142"), "AS"}, // Asia
+	{language.MustParseRegion("150"), "EU"}, // Europe
+	{language.MustParseRegion("005"), "SA"}, // South America
+	{language.MustParseRegion("019"), "NA"}, // Americas
+	{language.MustParseRegion("009"), "OC"}, // Oceania
+}
+
+// countryAliases are common names of countries that differ from their CLDR English name
+var countryAliases = map[string]string{
+	"czech republic": "CZ", // CLDR: Czechia
+	"hong kong":      "HK", // CLDR: Hong Kong SAR China
+	"macau":          "MO", // CLDR: Macao SAR China
+	"ivory coast":    "CI", // CLDR: Côte d’Ivoire
+}
+
+// countryRegions maps the lowercased English name of every country to its region
+// Built on first use from the CLDR names of all two-letter region codes, plus countryAliases
+var countryRegions = sync.OnceValue(func() map[string]language.Region {
+	regions := make(map[string]language.Region)
+	for a := 'A'; a <= 'Z'; a++ {
+		for b := 'A'; b <= 'Z'; b++ {
+			region, err := language.ParseRegion(string([]rune{a, b}))
+			// Deprecated codes (UK for GB, NQ for AQ) share the name of the code replacing them
+			if err != nil || !region.IsCountry() || region.Canonicalize() != region {
+				continue
+			}
+			if name := display.English.Regions().Name(region); name != "" {
+				regions[strings.ToLower(name)] = region
+			}
+		}
+	}
+	for name, code := range countryAliases {
+		regions[name] = language.MustParseRegion(code)
+	}
+	return regions
+})
+
+// continentCode returns the continent code of the country region, empty if it's in none of continentCodes
+func continentCode(region language.Region) string {
+	for _, continent := range continentCodes {
+		if continent.region == region || continent.region.Contains(region) {
+			return continent.code
+		}
+	}
+	return ""
+}
+
+// withCountryCodes returns location with its country and continent codes filled in when the store
+// didn't provide them: the country code from the country name, and the continent from the country code.
+// Names neither CLDR nor countryAliases know are left without codes.
+// The store's location isn't modified: it may be shared with other lookups, so a copy is returned
+// whenever a code is added
+func withCountryCodes(location *models.IPLocation) *models.IPLocation {
+	if location.CountryCode != "" && location.ContinentCode != "" {
+		return location
+	}
+
+	var region language.Region
+	if location.CountryCode != "" {
+		parsed, err := language.ParseRegion(location.CountryCode)
+		if err != nil {
+			return location
+		}
+		region = parsed
+	} else {
+		named, ok := countryRegions()[strings.ToLower(location.Country)]
+		if !ok {
+			return location
+		}
+		region = named
+	}
+
+	filled := *location
+	if filled.CountryCode == "" {
+		filled.CountryCode = region.String()
+	}
+	if filled.ContinentCode == "" {
+		filled.ContinentCode = continentCode(region)
+	}
+	return &filled
+}
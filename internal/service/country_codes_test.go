@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// TestWithCountryCodes tests the codes derived from the country name or code a store returns
+func TestWithCountryCodes(t *testing.T) {
+	tests := []struct {
+		name              string
+		location          models.IPLocation
+		wantCountryCode   string
+		wantContinentCode string
+	}{
+		{"from the name", models.IPLocation{Country: "United States"}, "US", "NA"},
+		{"name case", models.IPLocation{Country: "germany"}, "DE", "EU"},
+		{"South America", models.IPLocation{Country: "Brazil"}, "BR", "SA"},
+		{"Caribbean", models.IPLocation{Country: "Jamaica"}, "JM", "NA"},
+		{"alias", models.IPLocation{Country: "Hong Kong"}, "HK", "AS"},
+		{"renamed code", models.IPLocation{Country: "United Kingdom"}, "GB", "EU"},
+		{"Antarctica", models.IPLocation{Country: "Antarctica"}, "AQ", "AN"},
+		{"from the code", models.IPLocation{Country: "Some Name", CountryCode: "AU"}, "AU", "OC"},
+		{"store's codes kept", models.IPLocation{Country: "United States", CountryCode: "US", ContinentCode: "XX"}, "US", "XX"},
+		{"unknown name", models.IPLocation{Country: "Atlantis"}, "", ""},
+		{"invalid code", models.IPLocation{Country: "United States", CountryCode: "??"}, "??", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.location
+			got := withCountryCodes(&tt.location)
+			if got.CountryCode != tt.wantCountryCode || got.ContinentCode != tt.wantContinentCode {
+				t.Errorf("expected %q/%q, got %q/%q", tt.wantCountryCode, tt.wantContinentCode, got.CountryCode, got.ContinentCode)
+			}
+			if tt.location != original {
+				t.Errorf("expected the location not to be modified, got %+v", tt.location)
+			}
+		})
+	}
+}
+
+// TestIPService_LookupIP_CountryCodes tests that lookups carry codes whether or not the store has them
+func TestIPService_LookupIP_CountryCodes(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.Data["9.9.9.9"] = &models.IPLocation{IP: "9.9.9.9", City: "Berkeley", Country: "United States"}
+	service := NewIPService(mockStore, nil, nil)
+
+	for _, ip := range []string{"8.8.8.8", "9.9.9.9"} {
+		location, err := service.LookupIP(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if location.CountryCode != "US" || location.ContinentCode != "NA" {
+			t.Errorf("expected US/NA for %s, got %+v", ip, location)
+		}
+	}
+	if mockStore.Data["9.9.9.9"].CountryCode != "" {
+		t.Error("expected the store's location not to be modified")
+	}
+}
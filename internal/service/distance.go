@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/geo"
+)
+
+// Distance returns the great-circle distance between the locations of ip1 and ip2, to 0.1 km
+// Both IPs are looked up like LookupIP (validated, recorded in the history). Returns
+// geo.ErrNoCoordinates when the store has no coordinates for either of them
+func (s *IPService) Distance(ctx context.Context, ip1, ip2 string) (*models.DistanceResult, error) {
+	loc1, err := s.LookupIP(ctx, ip1)
+	if err != nil {
+		return nil, err
+	}
+	loc2, err := s.LookupIP(ctx, ip2)
+	if err != nil {
+		return nil, err
+	}
+
+	km, err := geo.Distance(*loc1, *loc2)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DistanceResult{IP1: ip1, IP2: ip2, DistanceKm: math.Round(km*10) / 10}, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/geo"
+)
+
+// newDistanceStore returns a store with London and New York located, and 9.9.9.9 without coordinates
+func newDistanceStore() *store.MockStore {
+	s := store.NewEmptyMockStore()
+	s.Data["81.2.69.160"] = &models.IPLocation{IP: "81.2.69.160", City: "London", Country: "United Kingdom", Latitude: 51.5074, Longitude: -0.1278}
+	s.Data["4.4.4.4"] = &models.IPLocation{IP: "4.4.4.4", City: "New York", Country: "United States", Latitude: 40.7128, Longitude: -74.0060}
+	s.Data["9.9.9.9"] = &models.IPLocation{IP: "9.9.9.9", City: "Zurich", Country: "Switzerland"}
+	return s
+}
+
+// TestIPService_Distance tests the distance between two located IPs, rounded to 0.1 km
+func TestIPService_Distance(t *testing.T) {
+	svc := NewIPService(newDistanceStore(), nil, nil)
+
+	result, err := svc.Distance(context.Background(), "81.2.69.160", "4.4.4.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := models.DistanceResult{IP1: "81.2.69.160", IP2: "4.4.4.4", DistanceKm: 5570.2}
+	if *result != expected {
+		t.Errorf("expected %+v, got %+v", expected, *result)
+	}
+}
+
+// TestIPService_Distance_Errors tests that lookup errors and missing coordinates are returned
+func TestIPService_Distance_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip1, ip2 string
+		expected error
+	}{
+		{"invalid first IP", "not-an-ip", "4.4.4.4", pkerr.ErrInvalidIP},
+		{"invalid second IP", "4.4.4.4", "not-an-ip", pkerr.ErrInvalidIP},
+		{"unknown IP", "4.4.4.4", "5.5.5.5", pkerr.ErrNotFound},
+		{"no coordinates", "4.4.4.4", "9.9.9.9", geo.ErrNoCoordinates},
+	}
+
+	svc := NewIPService(newDistanceStore(), nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Distance(context.Background(), tt.ip1, tt.ip2)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
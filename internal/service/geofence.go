@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/geofence"
+)
+
+// Geofence reports whether the location of ip is inside fence
+// ip is looked up like LookupIP (validated, recorded in the history). Returns
+// geo.ErrNoCoordinates when the store has no coordinates for it
+func (s *IPService) Geofence(ctx context.Context, ip string, fence geofence.Fence) (*models.GeofenceResult, error) {
+	location, err := s.LookupIP(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	inside, err := fence.Contains(*location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GeofenceResult{IP: ip, Inside: inside}, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/geo"
+	"github.com/evyataryagoni/ip2country/pkg/geofence"
+)
+
+// TestIPService_Geofence tests that located IPs are checked against the fence
+func TestIPService_Geofence(t *testing.T) {
+	svc := NewIPService(newDistanceStore(), nil, nil)
+	aroundLondon := geofence.CircleFence{Center: models.Coordinates{Latitude: 51.5, Longitude: -0.1}, RadiusKm: 100}
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"81.2.69.160", true},
+		{"4.4.4.4", false},
+	}
+	for _, tt := range tests {
+		result, err := svc.Geofence(context.Background(), tt.ip, aroundLondon)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.ip, err)
+		}
+		expected := models.GeofenceResult{IP: tt.ip, Inside: tt.expected}
+		if *result != expected {
+			t.Errorf("expected %+v, got %+v", expected, *result)
+		}
+	}
+}
+
+// TestIPService_Geofence_Errors tests that lookup errors and missing coordinates are returned
+func TestIPService_Geofence_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip       string
+		expected error
+	}{
+		{"invalid IP", "not-an-ip", pkerr.ErrInvalidIP},
+		{"unknown IP", "5.5.5.5", pkerr.ErrNotFound},
+		{"no coordinates", "9.9.9.9", geo.ErrNoCoordinates},
+	}
+
+	svc := NewIPService(newDistanceStore(), nil, nil)
+	fence := geofence.CircleFence{Center: models.Coordinates{Latitude: 51.5, Longitude: -0.1}, RadiusKm: 100}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Geofence(context.Background(), tt.ip, fence)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -1,13 +1,21 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/netip"
+	"time"
 
+	"github.com/evyataryagoni/ip2country/internal/analytics"
+	"github.com/evyataryagoni/ip2country/internal/history"
 	"github.com/evyataryagoni/ip2country/internal/logger"
 	"github.com/evyataryagoni/ip2country/internal/metrics"
 	"github.com/evyataryagoni/ip2country/internal/models"
 	"github.com/evyataryagoni/ip2country/internal/store"
-	"github.com/go-playground/validator/v10"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/ipenrich"
+	"github.com/evyataryagoni/ip2country/pkg/validate"
 )
 
 // IPService handles business logic for IP lookups
@@ -19,10 +27,31 @@ import (
 //   - Handle errors
 //   - Transform data if needed
 type IPService struct {
-	store     store.Store          // The datastore (CSV, MySQL, or Redis)
-	validator *validator.Validate  // Validator for input validation
-	metrics   *metrics.Metrics     // Metrics collector
-	logger    *logger.Logger       // Structured logger
+	store   store.Store      // The datastore (CSV, MySQL, or Redis)
+	metrics *metrics.Metrics // Metrics collector
+	logger  *logger.Logger   // Structured logger
+
+	// Whois configuration
+	whoisLookups []whoisLookup // Sub-lookups aggregated by Whois
+	whoisTimeout time.Duration // Timeout for a whole Whois call
+
+	// history records recent lookups for debugging (nil = disabled)
+	history *history.RingBuffer[models.HistoryEntry]
+
+	// sampler keeps a random sample of successfully looked-up IPs (nil = disabled)
+	sampler *analytics.Sampler
+
+	// countries caches ListCountries results
+	countries countriesCache
+
+	// subnetSampleSize is the number of addresses VerifySubnet looks up (0 = DefaultSubnetSampleSize)
+	subnetSampleSize int
+
+	// batchWorkers is the number of lookups BatchFindAsync runs at once (0 = DefaultBatchWorkers)
+	batchWorkers int
+
+	// enricher adds continent, timezone, etc. to the locations LookupIP returns (nil = disabled)
+	enricher ipenrich.Enricher
 }
 
 // NewIPService creates a new IP service with the given dependencies
@@ -30,44 +59,122 @@ func NewIPService(store store.Store, m *metrics.Metrics, log *logger.Logger) *IP
 	if log == nil {
 		log = logger.NewDefault()
 	}
-	return &IPService{
-		store:     store,
-		validator: validator.New(),
-		metrics:   m,
-		logger:    log.WithComponent("IPService"),
+	s := &IPService{
+		store:        store,
+		metrics:      m,
+		logger:       log.WithComponent("IPService"),
+		whoisTimeout: defaultWhoisTimeout,
+	}
+	s.whoisLookups = s.defaultWhoisLookups()
+	return s
+}
+
+// SetHistory enables recording of every lookup into h
+func (s *IPService) SetHistory(h *history.RingBuffer[models.HistoryEntry]) {
+	s.history = h
+}
+
+// SetSampler enables sampling of successfully looked-up IPs into sampler
+func (s *IPService) SetSampler(sampler *analytics.Sampler) {
+	s.sampler = sampler
+}
+
+// SetEnricher enables adding the data of enricher to every location LookupIP returns
+func (s *IPService) SetEnricher(enricher ipenrich.Enricher) {
+	s.enricher = enricher
+}
+
+// RecentLookups returns up to n of the most recent lookups, newest first
+// Returns an empty slice when history is disabled
+func (s *IPService) RecentLookups(n int) []models.HistoryEntry {
+	if s.history == nil {
+		return []models.HistoryEntry{}
+	}
+	return s.history.Last(n)
+}
+
+// DataVersion returns the version of the data the store is serving
+// Returns "" when the store doesn't report one (see store.StatsProvider)
+func (s *IPService) DataVersion() string {
+	if provider, ok := s.store.(store.StatsProvider); ok {
+		return provider.Stats().DataVersion
 	}
+	return ""
 }
 
 // LookupIP looks up geographic information for an IP address
-// Flow: 
-// 1) Validate IP format 
-// 2) Query the store 
+// Every lookup, successful or not, is recorded in the history (if enabled)
+// Successful lookups are offered to the sampler (if enabled), and enriched (if enabled)
+// Country and continent codes the store doesn't provide are derived from the country name
+// The store query is cancelled when ctx is done (e.g. the client disconnected)
+func (s *IPService) LookupIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	start := time.Now()
+	location, err := s.lookupIP(ctx, ip)
+	if err == nil {
+		location = withCountryCodes(location)
+	}
+	if err == nil && s.enricher != nil {
+		location = s.enrich(ctx, ip, location)
+	}
+
+	if s.history != nil {
+		entry := models.HistoryEntry{
+			Timestamp:  time.Now(),
+			IP:         ip,
+			DurationNs: time.Since(start).Nanoseconds(),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.City = location.City
+			entry.Country = location.Country
+		}
+		s.history.Add(entry)
+	}
+	if s.sampler != nil && err == nil {
+		s.sampler.AddSample(ip, location.Country)
+	}
+
+	return location, err
+}
+
+// lookupIP performs the lookup
+// Flow:
+// 1) Validate IP format
+// 2) Query the store, then its CIDR blocks if the IP has no record of its own
 // 3) Return result or error
-func (s *IPService) LookupIP(ip string) (*models.IPLocation, error) {
+func (s *IPService) lookupIP(ctx context.Context, ip string) (*models.IPLocation, error) {
 	// Step 1: Validate IP format
-	err := s.validator.Var(ip, "required,ip")
-	if err != nil {
+	if err := validate.ValidateIP(ip); err != nil {
 		s.logger.Warn().Str("ip", ip).Msg("Invalid IP address format")
 		if s.metrics != nil {
 			s.metrics.IPLookupsErrors.WithLabelValues("validation").Inc()
 		}
-		return nil, fmt.Errorf("invalid IP address format")
+		return nil, err
 	}
 
 	// Step 2: Query the store
 	// The store handles the actual data access (CSV, MySQL, Redis)
+	// Query outcomes are counted by store.MetricsStore, not here
 	s.logger.Debug().Str("ip", ip).Msg("Looking up IP address")
-	location, err := s.store.FindByIP(ip)
+	location, err := s.store.FindByIP(ctx, ip)
+	if errors.Is(err, pkerr.ErrNotFound) {
+		// No record for the IP itself: try the CIDR blocks of stores holding them
+		if finder, ok := s.store.(store.RangeFinder); ok {
+			location, err = finder.RangeFindByIP(ctx, ip)
+		}
+	}
 	if err != nil {
-		if s.metrics != nil {
-			if err.Error() == "IP address not found" {
-				s.logger.Debug().Str("ip", ip).Msg("IP address not found")
-				s.metrics.IPLookupsNotFound.Inc()
-				s.metrics.IPLookupsTotal.WithLabelValues("not_found").Inc()
-			} else {
-				s.logger.Error().Err(err).Str("ip", ip).Msg("Store error during IP lookup")
-				s.metrics.IPLookupsErrors.WithLabelValues("store_error").Inc()
+		if errors.Is(err, pkerr.ErrNotFound) {
+			s.logger.Debug().Str("ip", ip).Msg("IP address not found")
+			// No datastore can locate these, so say why rather than leaving clients to guess
+			if addr, parseErr := netip.ParseAddr(ip); parseErr == nil && classifyIP(addr) != "public" {
+				err = fmt.Errorf("%w: %w", err, pkerr.ErrUnroutableIP)
 			}
+		} else if ctx.Err() != nil {
+			s.logger.Debug().Err(err).Str("ip", ip).Msg("IP lookup cancelled")
+		} else {
+			s.logger.Error().Err(err).Str("ip", ip).Msg("Store error during IP lookup")
 		}
 		return nil, err
 	}
@@ -78,12 +185,27 @@ func (s *IPService) LookupIP(ip string) (*models.IPLocation, error) {
 		Str("city", location.City).
 		Str("country", location.Country).
 		Msg("IP lookup successful")
-	if s.metrics != nil {
-		s.metrics.IPLookupsTotal.WithLabelValues("success").Inc()
-	}
 	return location, nil
 }
 
+// enrich returns a copy of location with the enricher's data added
+// The store's location isn't modified: it may be shared with other lookups. Enrichment failures
+// are logged and leave their fields empty - the lookup itself still succeeded
+func (s *IPService) enrich(ctx context.Context, ip string, location *models.IPLocation) *models.IPLocation {
+	enriched := *location
+	if err := s.enricher.Enrich(ctx, ip, &enriched); err != nil {
+		s.logger.Warn().Err(err).Str("ip", ip).Msg("Failed to enrich IP lookup")
+	}
+	return &enriched
+}
+
+// Prefetch looks up ip in the store so it's warm when requested
+// Unlike LookupIP nothing is logged or recorded in the history: nobody asked for ip
+func (s *IPService) Prefetch(ctx context.Context, ip string) error {
+	_, err := s.store.FindByIP(ctx, ip)
+	return err
+}
+
 // Close cleans up resources (database connections, etc.)
 func (s *IPService) Close() error {
 	return s.store.Close()
@@ -1,10 +1,21 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"testing"
+	"time"
 
+	"github.com/evyataryagoni/ip2country/internal/analytics"
+	"github.com/evyataryagoni/ip2country/internal/history"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
 	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/ipenrich"
+	"github.com/rs/zerolog"
 )
 
 // TestIPService_LookupIP_Success tests successful IP lookup
@@ -36,7 +47,7 @@ func TestIPService_LookupIP_Success(t *testing.T) {
 			service := NewIPService(mockStore, nil, nil)
 
 			// Act
-			result, err := service.LookupIP(tt.ip)
+			result, err := service.LookupIP(context.Background(), tt.ip)
 
 			// Assert
 			if err != nil {
@@ -85,7 +96,7 @@ func TestIPService_LookupIP_InvalidIP(t *testing.T) {
 			mockStore := store.NewMockStore()
 			service := NewIPService(mockStore, nil, nil)
 
-			result, err := service.LookupIP(tt.ip)
+			result, err := service.LookupIP(context.Background(), tt.ip)
 
 			if err == nil {
 				t.Error("expected validation error, got nil")
@@ -93,7 +104,7 @@ func TestIPService_LookupIP_InvalidIP(t *testing.T) {
 			if result != nil {
 				t.Error("expected nil result, got data")
 			}
-			if err.Error() != "invalid IP address format" {
+			if !errors.Is(err, pkerr.ErrInvalidIP) {
 				t.Errorf("expected 'invalid IP address format', got %s", err.Error())
 			}
 
@@ -110,7 +121,7 @@ func TestIPService_LookupIP_NotFound(t *testing.T) {
 	mockStore := store.NewMockStore()
 	service := NewIPService(mockStore, nil, nil)
 
-	result, err := service.LookupIP("192.168.1.1")
+	result, err := service.LookupIP(context.Background(), "192.168.1.1")
 
 	if err == nil {
 		t.Error("expected not found error, got nil")
@@ -118,14 +129,22 @@ func TestIPService_LookupIP_NotFound(t *testing.T) {
 	if result != nil {
 		t.Error("expected nil result, got data")
 	}
-	if err.Error() != "IP address not found" {
+	if !errors.Is(err, pkerr.ErrNotFound) {
 		t.Errorf("expected 'IP address not found', got %s", err.Error())
 	}
+	if !errors.Is(err, pkerr.ErrUnroutableIP) {
+		t.Errorf("expected a private IP to be reported as unroutable, got %s", err.Error())
+	}
 
 	// Verify store was called (validation passed, but not found in store)
 	if len(mockStore.FindByIPCalls) != 1 {
 		t.Errorf("expected 1 store call, got %d", len(mockStore.FindByIPCalls))
 	}
+
+	// A public IP is just not found
+	if _, err := service.LookupIP(context.Background(), "9.9.9.9"); !errors.Is(err, pkerr.ErrNotFound) || errors.Is(err, pkerr.ErrUnroutableIP) {
+		t.Errorf("expected only 'IP address not found' for a public IP, got %v", err)
+	}
 }
 
 // TestIPService_LookupIP_StoreError tests store errors
@@ -134,7 +153,7 @@ func TestIPService_LookupIP_StoreError(t *testing.T) {
 	mockStore.FindByIPError = fmt.Errorf("database connection failed")
 	service := NewIPService(mockStore, nil, nil)
 
-	result, err := service.LookupIP("8.8.8.8")
+	result, err := service.LookupIP(context.Background(), "8.8.8.8")
 
 	if err == nil {
 		t.Error("expected store error, got nil")
@@ -152,6 +171,39 @@ func TestIPService_LookupIP_StoreError(t *testing.T) {
 	}
 }
 
+// TestIPService_LookupIP_WithRealRedis tests lookups against a real RedisStore, including key expiry
+func TestIPService_LookupIP_WithRealRedis(t *testing.T) {
+	redisStore := store.NewTestRedisStore(t)
+	if err := redisStore.Set("8.8.8.8", "Mountain View", "United States"); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	service := NewIPService(redisStore, nil, nil)
+
+	result, err := service.LookupIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.City != "Mountain View" || result.Country != "United States" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if _, err := service.LookupIP(context.Background(), "192.168.1.1"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+
+	// A record that expires in Redis is no longer found
+	mr := store.TestingRedis(t)
+	if !mr.Exists("ip:8.8.8.8") {
+		t.Fatalf("expected key 'ip:8.8.8.8', got keys %v", mr.Keys())
+	}
+	mr.SetTTL("ip:8.8.8.8", time.Minute)
+	mr.FastForward(2 * time.Minute)
+
+	if _, err := service.LookupIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found' after expiry, got %v", err)
+	}
+}
+
 // TestIPService_Close tests cleanup
 func TestIPService_Close(t *testing.T) {
 	mockStore := store.NewMockStore()
@@ -189,12 +241,12 @@ func TestIPService_Close_WithError(t *testing.T) {
 // TestIPService_ValidIPv4 tests various valid IPv4 formats
 func TestIPService_ValidIPv4(t *testing.T) {
 	tests := []string{
-		"0.0.0.0",       // Min IP
+		"0.0.0.0",         // Min IP
 		"255.255.255.255", // Max IP
-		"127.0.0.1",     // Localhost
-		"10.0.0.1",      // Private
-		"172.16.0.1",    // Private
-		"192.168.0.1",   // Private
+		"127.0.0.1",       // Localhost
+		"10.0.0.1",        // Private
+		"172.16.0.1",      // Private
+		"192.168.0.1",     // Private
 	}
 
 	for _, ip := range tests {
@@ -204,10 +256,10 @@ func TestIPService_ValidIPv4(t *testing.T) {
 
 			// These are valid IPs, they should pass validation
 			// (even if not found in store)
-			_, err := service.LookupIP(ip)
+			_, err := service.LookupIP(context.Background(), ip)
 
 			// Should not be a validation error
-			if err != nil && err.Error() == "invalid IP address format" {
+			if errors.Is(err, pkerr.ErrInvalidIP) {
 				t.Errorf("valid IPv4 %s rejected by validator", ip)
 			}
 
@@ -223,10 +275,10 @@ func TestIPService_ValidIPv4(t *testing.T) {
 func TestIPService_ValidIPv6(t *testing.T) {
 	tests := []string{
 		"2001:4860:4860::8888", // Google DNS IPv6
-		"::1",                   // Localhost
-		"fe80::1",               // Link-local
-		"2001:db8::1",           // Documentation
-		"::ffff:192.0.2.1",      // IPv4-mapped
+		"::1",                  // Localhost
+		"fe80::1",              // Link-local
+		"2001:db8::1",          // Documentation
+		"::ffff:192.0.2.1",     // IPv4-mapped
 	}
 
 	for _, ip := range tests {
@@ -235,10 +287,10 @@ func TestIPService_ValidIPv6(t *testing.T) {
 			service := NewIPService(mockStore, nil, nil)
 
 			// Should validate successfully (even if not found in store)
-			_, err := service.LookupIP(ip)
+			_, err := service.LookupIP(context.Background(), ip)
 
 			// Should not be a validation error
-			if err != nil && err.Error() == "invalid IP address format" {
+			if errors.Is(err, pkerr.ErrInvalidIP) {
 				t.Errorf("valid IPv6 %s rejected by validator", ip)
 			}
 
@@ -255,7 +307,7 @@ func TestIPService_LookupIP_EmptyStore(t *testing.T) {
 	mockStore := store.NewEmptyMockStore()
 	service := NewIPService(mockStore, nil, nil)
 
-	result, err := service.LookupIP("8.8.8.8")
+	result, err := service.LookupIP(context.Background(), "8.8.8.8")
 
 	if err == nil {
 		t.Error("expected not found error, got nil")
@@ -263,7 +315,7 @@ func TestIPService_LookupIP_EmptyStore(t *testing.T) {
 	if result != nil {
 		t.Error("expected nil result, got data")
 	}
-	if err.Error() != "IP address not found" {
+	if !errors.Is(err, pkerr.ErrNotFound) {
 		t.Errorf("expected 'IP address not found', got %s", err.Error())
 	}
 }
@@ -274,7 +326,7 @@ func TestIPService_MultipleSequentialLookups(t *testing.T) {
 	service := NewIPService(mockStore, nil, nil)
 
 	// First lookup
-	result1, err1 := service.LookupIP("8.8.8.8")
+	result1, err1 := service.LookupIP(context.Background(), "8.8.8.8")
 	if err1 != nil {
 		t.Fatalf("first lookup failed: %v", err1)
 	}
@@ -283,7 +335,7 @@ func TestIPService_MultipleSequentialLookups(t *testing.T) {
 	}
 
 	// Second lookup (different IP)
-	result2, err2 := service.LookupIP("1.1.1.1")
+	result2, err2 := service.LookupIP(context.Background(), "1.1.1.1")
 	if err2 != nil {
 		t.Fatalf("second lookup failed: %v", err2)
 	}
@@ -292,7 +344,7 @@ func TestIPService_MultipleSequentialLookups(t *testing.T) {
 	}
 
 	// Third lookup (not found)
-	result3, err3 := service.LookupIP("192.168.1.1")
+	result3, err3 := service.LookupIP(context.Background(), "192.168.1.1")
 	if err3 == nil {
 		t.Error("third lookup: expected not found error")
 	}
@@ -311,7 +363,7 @@ func TestIPService_NilMetrics(t *testing.T) {
 	mockStore := store.NewMockStore()
 	service := NewIPService(mockStore, nil, nil) // nil metrics
 
-	result, err := service.LookupIP("8.8.8.8")
+	result, err := service.LookupIP(context.Background(), "8.8.8.8")
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -321,3 +373,150 @@ func TestIPService_NilMetrics(t *testing.T) {
 	}
 	// Should work fine without metrics
 }
+
+// TestIPService_LookupIP_RecordsHistory tests that successful and failed lookups are recorded newest first
+func TestIPService_LookupIP_RecordsHistory(t *testing.T) {
+	mockStore := store.NewMockStore()
+	service := NewIPService(mockStore, nil, nil)
+	service.SetHistory(history.NewRingBuffer[models.HistoryEntry](10))
+
+	service.LookupIP(context.Background(), "8.8.8.8")
+	service.LookupIP(context.Background(), "9.9.9.9") // Not in mock data
+
+	entries := service.RecentLookups(10)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", len(entries))
+	}
+
+	if entries[0].IP != "9.9.9.9" || entries[0].Error != "IP address not found" {
+		t.Errorf("expected newest entry to be the failed lookup, got %+v", entries[0])
+	}
+	if entries[1].IP != "8.8.8.8" || entries[1].City != "Mountain View" || entries[1].Error != "" {
+		t.Errorf("expected oldest entry to be the successful lookup, got %+v", entries[1])
+	}
+	if entries[1].Timestamp.IsZero() || entries[1].DurationNs <= 0 {
+		t.Errorf("expected timestamp and duration to be set, got %+v", entries[1])
+	}
+}
+
+// TestIPService_RecentLookups_Disabled tests that no history is returned when it isn't enabled
+func TestIPService_RecentLookups_Disabled(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.LookupIP(context.Background(), "8.8.8.8")
+
+	if entries := service.RecentLookups(10); len(entries) != 0 {
+		t.Errorf("expected no history entries, got %d", len(entries))
+	}
+}
+
+// TestIPService_LookupIP_Samples tests that only successful lookups are offered to the sampler
+func TestIPService_LookupIP_Samples(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	sampler := analytics.NewSampler(10)
+	service.SetSampler(sampler)
+
+	service.LookupIP(context.Background(), "8.8.8.8")
+	service.LookupIP(context.Background(), "9.9.9.9")   // Not in mock data
+	service.LookupIP(context.Background(), "not-an-ip") // Invalid
+
+	sample := sampler.GetSample()
+	if len(sample) != 1 || sample[0] != (analytics.Sample{IP: "8.8.8.8", Country: "United States"}) {
+		t.Errorf("expected only 8.8.8.8 in the sample, got %+v", sample)
+	}
+}
+
+// TestIPService_LookupIP_Enriches tests that the enricher's fields are added to a copy of the store's location
+func TestIPService_LookupIP_Enriches(t *testing.T) {
+	mockStore := store.NewMockStore()
+	service := NewIPService(mockStore, nil, nil)
+	service.SetEnricher(ipenrich.NewMultiEnricher(time.Second).
+		Add("continent", ipenrich.ContinentEnricher{}, 0).
+		Add("timezone", ipenrich.TimezoneEnricher{}, 0))
+
+	location, err := service.LookupIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.Country != "United States" || location.Continent != "North America" || location.Timezone != "America/New_York" {
+		t.Errorf("expected an enriched United States location, got %+v", location)
+	}
+	if mockStore.Data["8.8.8.8"].Continent != "" {
+		t.Error("expected the store's location not to be modified")
+	}
+}
+
+// TestIPService_LookupIP_EnrichmentFails tests that a lookup still returns the store's city and country when every source fails
+func TestIPService_LookupIP_EnrichmentFails(t *testing.T) {
+	failing := ipenrich.EnricherFunc(func(ctx context.Context, ip string, loc *models.IPLocation) error {
+		return errors.New("source unavailable")
+	})
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.SetEnricher(ipenrich.NewMultiEnricher(time.Second).Add("abuse", failing, 0).Add("coordinates", failing, 0))
+
+	location, err := service.LookupIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected the lookup to succeed, got %v", err)
+	}
+	if location.City != "Mountain View" || location.Country != "United States" {
+		t.Errorf("expected Mountain View, United States, got %+v", location)
+	}
+}
+
+// FuzzValidateIP fuzzes the LookupIP validation path
+// Every input must either be rejected as an invalid format or reach the store, never panic
+func FuzzValidateIP(f *testing.F) {
+	for _, seed := range []string{"8.8.8.8", "2001:4860:4860::8888", "::ffff:1.2.3.4", "256.1.1.1", "", "1.1.1.1\n", "fe80::1%eth0"} {
+		f.Add(seed)
+	}
+
+	nop := zerolog.Nop()
+	service := NewIPService(store.NewMockStore(), nil, &logger.Logger{Logger: &nop})
+
+	f.Fuzz(func(t *testing.T, ip string) {
+		location, err := service.LookupIP(context.Background(), ip)
+
+		// The validator must agree with the standard library on what an IP is
+		valid := net.ParseIP(ip) != nil
+		if rejected := errors.Is(err, pkerr.ErrInvalidIP); rejected == valid {
+			t.Fatalf("LookupIP(%q): valid=%v but got error %v", ip, valid, err)
+		}
+		if err == nil && location == nil {
+			t.Fatalf("LookupIP(%q): nil location without an error", ip)
+		}
+	})
+}
+
+// TestIPService_LookupIP_RangeFallthrough tests that an IP without a record of its own is looked up
+// in the store's CIDR blocks, and that an exact record wins over the block containing it
+func TestIPService_LookupIP_RangeFallthrough(t *testing.T) {
+	redisStore := store.NewTestRedisStore(t)
+	if err := redisStore.Set("8.8.4.4", "Mountain View", "United States"); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	if err := redisStore.SetRange("8.8.4.0/24", "Berlin", "Germany"); err != nil {
+		t.Fatalf("failed to set range: %v", err)
+	}
+	service := NewIPService(redisStore, nil, nil)
+
+	tests := []struct {
+		ip      string
+		city    string
+		wantErr error
+	}{
+		{"8.8.4.4", "Mountain View", nil},
+		{"8.8.4.1", "Berlin", nil},
+		{"8.8.4.255", "Berlin", nil},
+		{"8.8.5.0", "", pkerr.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		result, err := service.LookupIP(context.Background(), tt.ip)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.ip, tt.wantErr, err)
+			continue
+		}
+		if err == nil && (result.City != tt.city || result.IP != tt.ip) {
+			t.Errorf("%s: expected %s, got %+v", tt.ip, tt.city, result)
+		}
+	}
+}
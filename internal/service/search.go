@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// errSearchNotSupported is returned by Search for stores that can't search by field
+var errSearchNotSupported = fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+
+// Search returns the records whose country and city begin with the query's (case-insensitive), ordered by IP
+// Stores not implementing store.Searcher (e.g. MaxMind) return an error
+func (s *IPService) Search(ctx context.Context, query store.StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.store.(store.Searcher)
+	if !ok {
+		return nil, errSearchNotSupported
+	}
+
+	locations, err := searcher.Search(ctx, query)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("country", query.Country).
+			Str("city", query.City).
+			Msg("Search failed")
+		return nil, err
+	}
+	return locations, nil
+}
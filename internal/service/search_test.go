@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// TestIPService_Search tests that the query is passed to the store and its results returned
+func TestIPService_Search(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	locations, err := service.Search(context.Background(), store.StoreQuery{Country: "united", Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(locations) != 1 || locations[0].IP != "8.8.8.8" {
+		t.Errorf("expected only 8.8.8.8, got %v", locations)
+	}
+}
+
+// TestIPService_Search_Errors tests store failures and stores that can't search
+func TestIPService_Search_Errors(t *testing.T) {
+	mockStore := store.NewMockStore()
+	mockStore.SearchError = errors.New("connection refused")
+	if _, err := NewIPService(mockStore, nil, nil).Search(context.Background(), store.StoreQuery{}); err == nil {
+		t.Error("expected the store error")
+	}
+
+	_, err := NewIPService(findOnlyStore{}, nil, nil).Search(context.Background(), store.StoreQuery{})
+	if !errors.Is(err, pkerr.ErrNotSupported) {
+		t.Errorf("expected not supported error, got %v", err)
+	}
+}
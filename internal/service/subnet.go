@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// DefaultSubnetSampleSize is the number of addresses VerifySubnet looks up when no size was set
+const DefaultSubnetSampleSize = 100
+
+// minSubnetPrefix is the shortest prefix VerifySubnet accepts; a /8 or wider spans too many
+// allocations for a sample to say anything useful about it
+const minSubnetPrefix = 9
+
+// Errors returned by VerifySubnet for a bad cidr
+var (
+	// ErrInvalidCIDR is returned when cidr isn't a network in CIDR notation (400)
+	ErrInvalidCIDR = errors.New("invalid CIDR")
+	// ErrCIDRTooLarge is returned for a /8 or wider network (422)
+	ErrCIDRTooLarge = errors.New("CIDR is too large (/8 or wider)")
+)
+
+// SetSubnetSampleSize sets how many addresses VerifySubnet looks up per CIDR
+// n <= 0 restores DefaultSubnetSampleSize
+func (s *IPService) SetSubnetSampleSize(n int) {
+	s.subnetSampleSize = n
+}
+
+// VerifySubnet estimates how much of cidr is located in country
+// Up to the configured sample size of addresses, spread evenly across the range, are looked up;
+// the match rate is the fraction of them located in country. Addresses the store doesn't know
+// count as mismatches
+//
+// country is matched case-insensitively against the store's country names, and may also be
+// an ISO 3166 alpha-2 code (e.g. "US" for "United States")
+func (s *IPService) VerifySubnet(ctx context.Context, cidr, country string) (*models.SubnetVerification, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, ErrInvalidCIDR
+	}
+	if ones, _ := network.Mask.Size(); ones < minSubnetPrefix {
+		return nil, ErrCIDRTooLarge
+	}
+
+	sampleSize := s.subnetSampleSize
+	if sampleSize <= 0 {
+		sampleSize = DefaultSubnetSampleSize
+	}
+	ips := sampleNetwork(network, sampleSize)
+
+	// Cancelled on an early return, so the remaining lookups stop
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	names := countryNames(country)
+	matches := 0
+	for result := range s.BatchFindAsync(ctx, ips) {
+		if result.Error != nil {
+			if errors.Is(result.Error, pkerr.ErrNotFound) {
+				continue
+			}
+			return nil, result.Error
+		}
+		if names[strings.ToLower(result.Location.Country)] {
+			matches++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.SubnetVerification{
+		CIDR:             network.String(),
+		RequestedCountry: country,
+		MatchRate:        float64(matches) / float64(len(ips)),
+		SampleSize:       len(ips),
+	}, nil
+}
+
+// sampleNetwork returns up to n addresses of network, evenly spaced from its first address
+// Networks with no more than n addresses are returned whole
+func sampleNetwork(network *net.IPNet, n int) []string {
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	base := new(big.Int).SetBytes(network.IP)
+
+	count := n
+	if size.IsInt64() && size.Int64() < int64(n) {
+		count = int(size.Int64())
+	}
+
+	ips := make([]string, 0, count)
+	offset := new(big.Int)
+	for i := range count {
+		// offset = i * size / count, so samples spread over the whole range
+		offset.Mul(size, big.NewInt(int64(i)))
+		offset.Div(offset, big.NewInt(int64(count)))
+		offset.Add(offset, base)
+
+		ip := make(net.IP, len(network.IP))
+		offset.FillBytes(ip)
+		ips = append(ips, ip.String())
+	}
+	return ips
+}
+
+// countryNames returns the lowercased names country matches: itself, plus the English
+// name of the region when it's an ISO 3166 alpha-2 code
+func countryNames(country string) map[string]bool {
+	names := map[string]bool{strings.ToLower(country): true}
+	if len(country) == 2 {
+		if region, err := language.ParseRegion(country); err == nil {
+			if name := display.English.Regions().Name(region); name != "" {
+				names[strings.ToLower(name)] = true
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// newSubnetStore returns a store locating every address of 10.0.0.0/24: the first half
+// in the United States, the second half in Germany
+func newSubnetStore() *store.MockStore {
+	mockStore := store.NewEmptyMockStore()
+	for i := range 256 {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		country := "United States"
+		if i >= 128 {
+			country = "Germany"
+		}
+		mockStore.Data[ip] = &models.IPLocation{IP: ip, City: "Somewhere", Country: country}
+	}
+	return mockStore
+}
+
+// TestIPService_VerifySubnet_FullMatch tests that a network entirely in the country matches at 1.0
+func TestIPService_VerifySubnet_FullMatch(t *testing.T) {
+	service := NewIPService(newSubnetStore(), nil, nil)
+
+	for _, country := range []string{"US", "us", "United States", "united states"} {
+		result, err := service.VerifySubnet(context.Background(), "10.0.0.0/25", country)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.MatchRate != 1.0 {
+			t.Errorf("%s: expected match rate 1.0, got %v", country, result.MatchRate)
+		}
+		if result.SampleSize != 100 {
+			t.Errorf("%s: expected 100 samples, got %d", country, result.SampleSize)
+		}
+		if result.RequestedCountry != country || result.CIDR != "10.0.0.0/25" {
+			t.Errorf("%s: expected the request to be echoed, got %+v", country, result)
+		}
+	}
+}
+
+// TestIPService_VerifySubnet_PartialMatch tests that samples spread over the whole network
+func TestIPService_VerifySubnet_PartialMatch(t *testing.T) {
+	service := NewIPService(newSubnetStore(), nil, nil)
+
+	result, err := service.VerifySubnet(context.Background(), "10.0.0.0/24", "US")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.MatchRate != 0.5 {
+		t.Errorf("expected match rate 0.5, got %v", result.MatchRate)
+	}
+	if result.SampleSize != 100 {
+		t.Errorf("expected 100 samples, got %d", result.SampleSize)
+	}
+}
+
+// TestIPService_VerifySubnet_SampleSize tests the configured sample size, and small networks sampled whole
+func TestIPService_VerifySubnet_SampleSize(t *testing.T) {
+	mockStore := newSubnetStore()
+	service := NewIPService(mockStore, nil, nil)
+	service.SetSubnetSampleSize(10)
+
+	result, err := service.VerifySubnet(context.Background(), "10.0.0.0/24", "Germany")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.SampleSize != 10 || len(mockStore.FindByIPCalls) != 10 {
+		t.Errorf("expected 10 lookups, got sample size %d and %d calls", result.SampleSize, len(mockStore.FindByIPCalls))
+	}
+
+	result, err = service.VerifySubnet(context.Background(), "10.0.0.0/30", "US")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.SampleSize != 4 {
+		t.Errorf("expected a /30 to be sampled whole (4 addresses), got %d", result.SampleSize)
+	}
+}
+
+// TestIPService_VerifySubnet_NotFound tests that addresses the store doesn't know count as mismatches
+func TestIPService_VerifySubnet_NotFound(t *testing.T) {
+	service := NewIPService(store.NewEmptyMockStore(), nil, nil)
+
+	result, err := service.VerifySubnet(context.Background(), "10.0.0.0/24", "US")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.MatchRate != 0 {
+		t.Errorf("expected match rate 0, got %v", result.MatchRate)
+	}
+}
+
+// TestIPService_VerifySubnet_Errors tests invalid and oversized networks, and store failures
+func TestIPService_VerifySubnet_Errors(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+
+	tests := []struct {
+		cidr string
+		want error
+	}{
+		{"8.8.8.8", ErrInvalidCIDR},
+		{"8.8.8.0/33", ErrInvalidCIDR},
+		{"not-a-cidr", ErrInvalidCIDR},
+		{"10.0.0.0/8", ErrCIDRTooLarge},
+		{"0.0.0.0/0", ErrCIDRTooLarge},
+		{"2001::/8", ErrCIDRTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := service.VerifySubnet(context.Background(), tt.cidr, "US"); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.cidr, tt.want, err)
+		}
+	}
+
+	failing := store.NewMockStore()
+	failing.FindByIPError = errors.New("connection refused")
+	service = NewIPService(failing, nil, nil)
+	if _, err := service.VerifySubnet(context.Background(), "10.0.0.0/24", "US"); err == nil {
+		t.Error("expected the store error to be returned")
+	}
+}
+
+// TestSampleNetwork tests that samples are evenly spaced and stay inside the network
+func TestSampleNetwork(t *testing.T) {
+	tests := []struct {
+		cidr string
+		n    int
+		want []string
+	}{
+		{"10.0.0.0/24", 4, []string{"10.0.0.0", "10.0.0.64", "10.0.0.128", "10.0.0.192"}},
+		{"10.0.0.0/31", 100, []string{"10.0.0.0", "10.0.0.1"}},
+		{"2001:db8::/32", 2, []string{"2001:db8::", "2001:db8:8000::"}},
+	}
+	for _, tt := range tests {
+		_, network, err := net.ParseCIDR(tt.cidr)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.cidr, err)
+		}
+		got := sampleNetwork(network, tt.n)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.cidr, tt.want, got)
+		}
+	}
+}
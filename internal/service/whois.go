@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/ipenrich"
+	"github.com/evyataryagoni/ip2country/pkg/validate"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultWhoisTimeout bounds the total time spent collecting whois data
+const defaultWhoisTimeout = 2 * time.Second
+
+// whoisApplyFunc writes the data found by a sub-lookup into the aggregated result
+type whoisApplyFunc func(result *models.WhoisResult)
+
+// whoisLookup is a single data source queried by Whois
+// Each lookup runs in its own goroutine and returns a function that applies its data
+type whoisLookup struct {
+	name string
+	run  func(ctx context.Context, ip string) (whoisApplyFunc, error)
+}
+
+// defaultWhoisLookups returns the data sources available to Whois
+// The network is only looked up in stores that report the range an IP was found in (store.SpanFinder)
+func (s *IPService) defaultWhoisLookups() []whoisLookup {
+	lookups := []whoisLookup{
+		{name: "geolocation", run: s.whoisGeolocation},
+		{name: "classification", run: whoisClassification},
+	}
+	if finder, ok := s.store.(store.SpanFinder); ok {
+		lookups = append(lookups, whoisLookup{name: "network", run: func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+			return whoisNetwork(ctx, finder, ip)
+		}})
+	}
+	return lookups
+}
+
+// SetWhoisTimeout overrides the timeout applied to a whole Whois call
+func (s *IPService) SetWhoisTimeout(timeout time.Duration) {
+	s.whoisTimeout = timeout
+}
+
+// Whois returns everything the service knows about an IP address
+// Flow:
+// 1) Validate IP format
+// 2) Fan out to all sub-lookups in parallel
+// 3) Collect results until all finish or the timeout expires
+//
+// A failing sub-lookup does not fail the whole call - its error is noted in
+// result.Errors and the remaining fields are still returned.
+// If every sub-lookup fails, the first error is returned instead.
+func (s *IPService) Whois(ctx context.Context, ip string) (*models.WhoisResult, error) {
+	// Step 1: Validate IP format
+	if err := validate.ValidateIP(ip); err != nil {
+		s.logger.Warn().Str("ip", ip).Msg("Invalid IP address format")
+		if s.metrics != nil {
+			s.metrics.IPLookupsErrors.WithLabelValues("validation").Inc()
+		}
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.whoisTimeout)
+	defer cancel()
+
+	result := &models.WhoisResult{IPLocation: models.IPLocation{IP: ip}}
+
+	// mu protects result, firstErr and finished
+	// finished stops late sub-lookups from writing after we've returned on timeout
+	var mu sync.Mutex
+	var firstErr error
+	finished := false
+
+	// Step 2: Fan out
+	// Sub-lookups never return an error to the group, so one failure doesn't cancel the others
+	g, gctx := errgroup.WithContext(ctx)
+	for _, lookup := range s.whoisLookups {
+		g.Go(func() error {
+			apply, err := lookup.run(gctx, ip)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if finished {
+				return nil
+			}
+			if err != nil {
+				s.logger.Debug().Err(err).Str("ip", ip).Str("lookup", lookup.name).Msg("Whois sub-lookup failed")
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", lookup.name, err))
+				if firstErr == nil {
+					firstErr = err
+				}
+				return nil
+			}
+			apply(result)
+			return nil
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.Wait()
+		close(done)
+	}()
+
+	// Step 3: Collect
+	select {
+	case <-done:
+	case <-ctx.Done():
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+		s.logger.Warn().Str("ip", ip).Dur("timeout", s.whoisTimeout).Msg("Whois lookup timed out")
+		return nil, fmt.Errorf("whois lookup timed out: %w", pkerr.ErrStoreTimeout)
+	}
+
+	if len(result.Errors) == len(s.whoisLookups) && firstErr != nil {
+		return nil, firstErr
+	}
+
+	result.Signals = whoisSignals(result)
+	return result, nil
+}
+
+// whoisSignals lists what the classifiers flagged in result: a classification other than public,
+// and "blocklisted" when the abuse enricher found the address in its list
+func whoisSignals(result *models.WhoisResult) []string {
+	var signals []string
+	if result.Classification != "" && result.Classification != "public" {
+		signals = append(signals, result.Classification)
+	}
+	if result.AbuseScore >= ipenrich.ListedAbuseScore {
+		signals = append(signals, "blocklisted")
+	}
+	return signals
+}
+
+// whoisGeolocation fills in the location from the store, with the enricher's data (timezone, abuse score, ...) if enabled
+func (s *IPService) whoisGeolocation(ctx context.Context, ip string) (whoisApplyFunc, error) {
+	location, err := s.LookupIP(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return func(result *models.WhoisResult) {
+		result.IPLocation = *location
+		result.IP = ip
+	}, nil
+}
+
+// whoisNetwork fills in the network of the range finder found ip in
+// An IP answered by a single-IP record, or not found at all, has no network; that isn't an error
+// (the geolocation sub-lookup already reports a missing IP)
+func whoisNetwork(ctx context.Context, finder store.SpanFinder, ip string) (whoisApplyFunc, error) {
+	_, span, err := finder.FindSpan(ctx, ip)
+	if err != nil && !errors.Is(err, pkerr.ErrNotFound) {
+		return nil, err
+	}
+
+	network := ""
+	if span != nil {
+		network = spanNetwork(net.ParseIP(ip), span)
+	}
+	return func(result *models.WhoisResult) {
+		result.Network = network
+	}, nil
+}
+
+// spanNetwork returns the largest CIDR block containing ip that lies within span, e.g. the block itself
+// for a span read from a CIDR row, or "" if ip isn't in span
+// A range mode row needn't be aligned on a block boundary, so its block can be smaller than the row
+func spanNetwork(ip net.IP, span *store.IPSpan) string {
+	start, end := span.Start, span.End
+	if v4 := ip.To4(); v4 != nil && start.To4() != nil && end.To4() != nil {
+		ip, start, end = v4, start.To4(), end.To4()
+	} else {
+		ip, start, end = ip.To16(), start.To16(), end.To16()
+	}
+	if ip == nil || start == nil || end == nil {
+		return ""
+	}
+
+	bits := len(ip) * 8
+	for ones := 0; ones <= bits; ones++ {
+		mask := net.CIDRMask(ones, bits)
+		first := ip.Mask(mask)
+		last := make(net.IP, len(ip))
+		for i := range ip {
+			last[i] = first[i] | ^mask[i]
+		}
+		if bytes.Compare(first, start) >= 0 && bytes.Compare(last, end) <= 0 {
+			return (&net.IPNet{IP: first, Mask: mask}).String()
+		}
+	}
+	return ""
+}
+
+// whoisClassification classifies the address from its format alone (no store access needed)
+func whoisClassification(ctx context.Context, ip string) (whoisApplyFunc, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, pkerr.ErrInvalidIP
+	}
+
+	classification := classifyIP(addr)
+	return func(result *models.WhoisResult) {
+		result.Classification = classification
+	}, nil
+}
+
+// classifyIP returns "public" for a publicly routable address, and otherwise what kind of address it is
+func classifyIP(addr netip.Addr) string {
+	switch addr = addr.Unmap(); {
+	case addr.IsLoopback():
+		return "loopback"
+	case addr.IsPrivate():
+		return "private"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return "link-local"
+	case addr.IsMulticast():
+		return "multicast"
+	case addr.IsUnspecified():
+		return "unspecified"
+	}
+	return "public"
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/ipenrich"
+)
+
+// newMockWhoisLookups returns sub-lookups that fill every WhoisResult field
+func newMockWhoisLookups() []whoisLookup {
+	return []whoisLookup{
+		{name: "geolocation", run: func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+			return func(r *models.WhoisResult) {
+				r.City = "Mountain View"
+				r.Country = "United States"
+			}, nil
+		}},
+		{name: "abuse", run: func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+			return func(r *models.WhoisResult) { r.AbuseScore = 42 }, nil
+		}},
+		{name: "classification", run: func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+			return func(r *models.WhoisResult) { r.Classification = "public" }, nil
+		}},
+		{name: "network", run: func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+			return func(r *models.WhoisResult) { r.Network = "8.8.8.0/24" }, nil
+		}},
+		{name: "timezone", run: func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+			return func(r *models.WhoisResult) { r.Timezone = "America/Los_Angeles" }, nil
+		}},
+	}
+}
+
+// TestIPService_Whois_AllFields tests that every sub-lookup contributes to the result
+func TestIPService_Whois_AllFields(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.whoisLookups = newMockWhoisLookups()
+
+	result, err := service.Whois(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.IP != "8.8.8.8" {
+		t.Errorf("expected IP 8.8.8.8, got %s", result.IP)
+	}
+	if result.City != "Mountain View" || result.Country != "United States" {
+		t.Errorf("unexpected location: %s, %s", result.City, result.Country)
+	}
+	if result.AbuseScore != 42 {
+		t.Errorf("expected abuse score 42, got %d", result.AbuseScore)
+	}
+	if result.Classification != "public" {
+		t.Errorf("expected classification public, got %s", result.Classification)
+	}
+	if result.Network != "8.8.8.0/24" {
+		t.Errorf("expected network 8.8.8.0/24, got %s", result.Network)
+	}
+	if len(result.Signals) != 0 {
+		t.Errorf("expected no signals for a public address below the listed score, got %v", result.Signals)
+	}
+	if result.Timezone != "America/Los_Angeles" {
+		t.Errorf("expected timezone America/Los_Angeles, got %s", result.Timezone)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", result.Errors)
+	}
+}
+
+// TestIPService_Whois_PartialFailure tests that one failing sub-lookup doesn't hide the others
+func TestIPService_Whois_PartialFailure(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.whoisLookups = newMockWhoisLookups()
+	service.whoisLookups[1].run = func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+		return nil, fmt.Errorf("abuse source unavailable")
+	}
+
+	result, err := service.Whois(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.City != "Mountain View" || result.Timezone != "America/Los_Angeles" {
+		t.Error("expected fields from successful sub-lookups to be present")
+	}
+	if result.AbuseScore != 0 {
+		t.Errorf("expected empty abuse score, got %d", result.AbuseScore)
+	}
+	if len(result.Errors) != 1 || result.Errors[0] != "abuse: abuse source unavailable" {
+		t.Errorf("expected abuse error to be noted, got %v", result.Errors)
+	}
+}
+
+// TestIPService_Whois_AllFailed tests that the first error is returned when nothing succeeds
+func TestIPService_Whois_AllFailed(t *testing.T) {
+	service := NewIPService(store.NewEmptyMockStore(), nil, nil)
+	service.whoisLookups = service.whoisLookups[:1] // geolocation only
+
+	result, err := service.Whois(context.Background(), "8.8.8.8")
+
+	if result != nil {
+		t.Error("expected nil result, got data")
+	}
+	if !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+}
+
+// TestIPService_Whois_Timeout tests that slow sub-lookups produce a timeout error
+func TestIPService_Whois_Timeout(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.SetWhoisTimeout(50 * time.Millisecond)
+	service.whoisLookups = newMockWhoisLookups()
+	service.whoisLookups[0].run = func(ctx context.Context, ip string) (whoisApplyFunc, error) {
+		time.Sleep(500 * time.Millisecond)
+		return func(r *models.WhoisResult) { r.City = "late" }, nil
+	}
+
+	start := time.Now()
+	result, err := service.Whois(context.Background(), "8.8.8.8")
+
+	if result != nil {
+		t.Error("expected nil result, got data")
+	}
+	if !errors.Is(err, pkerr.ErrStoreTimeout) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if time.Since(start) > 400*time.Millisecond {
+		t.Error("expected Whois to return at the timeout, not wait for the slow sub-lookup")
+	}
+}
+
+// TestIPService_Whois_InvalidIP tests validation happens before any sub-lookup
+func TestIPService_Whois_InvalidIP(t *testing.T) {
+	mockStore := store.NewMockStore()
+	service := NewIPService(mockStore, nil, nil)
+
+	_, err := service.Whois(context.Background(), "not-an-ip")
+
+	if !errors.Is(err, pkerr.ErrInvalidIP) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+	if len(mockStore.FindByIPCalls) != 0 {
+		t.Errorf("expected 0 store calls, got %d", len(mockStore.FindByIPCalls))
+	}
+}
+
+// TestIPService_Whois_DefaultLookups tests the built-in geolocation and classification sources
+func TestIPService_Whois_DefaultLookups(t *testing.T) {
+	tests := []struct {
+		ip             string
+		classification string
+		signals        []string
+		errors         int
+	}{
+		{"8.8.8.8", "public", nil, 0},
+		{"192.168.1.1", "private", []string{"private"}, 1}, // not in store
+		{"127.0.0.1", "loopback", []string{"loopback"}, 1},
+		{"fe80::1", "link-local", []string{"link-local"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			service := NewIPService(store.NewMockStore(), nil, nil)
+
+			result, err := service.Whois(context.Background(), tt.ip)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if result.Classification != tt.classification {
+				t.Errorf("expected classification %s, got %s", tt.classification, result.Classification)
+			}
+			if !slices.Equal(result.Signals, tt.signals) {
+				t.Errorf("expected signals %v, got %v", tt.signals, result.Signals)
+			}
+			if result.Network != "" {
+				t.Errorf("expected no network from a store without ranges, got %s", result.Network)
+			}
+			if len(result.Errors) != tt.errors {
+				t.Errorf("expected %d errors, got %v", tt.errors, result.Errors)
+			}
+		})
+	}
+}
+
+// TestIPService_Whois_Enriched tests that the geolocation carries the enrichment sources' fields
+func TestIPService_Whois_Enriched(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.SetEnricher(ipenrich.NewMultiEnricher(time.Second).Add("timezone", ipenrich.TimezoneEnricher{}, 0))
+
+	result, err := service.Whois(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.IP != "8.8.8.8" || result.City != "Mountain View" || result.Timezone != "America/New_York" {
+		t.Errorf("expected the enriched location, got %+v", result.IPLocation)
+	}
+}
+
+// TestIPService_Whois_Blocklisted tests that an address the abuse enricher lists is flagged in the signals
+func TestIPService_Whois_Blocklisted(t *testing.T) {
+	service := NewIPService(store.NewMockStore(), nil, nil)
+	service.SetEnricher(ipenrich.NewMultiEnricher(time.Second).Add("abuse", ipenrich.NewAbuseEnricher(listAll{}), 0))
+
+	result, err := service.Whois(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !slices.Equal(result.Signals, []string{"blocklisted"}) {
+		t.Errorf("expected signals [blocklisted], got %v", result.Signals)
+	}
+}
+
+// listAll is an ipenrich.AbuseList listing every address
+type listAll struct{}
+
+func (listAll) Contains(ip net.IP) bool { return true }
+
+// TestIPService_Whois_Network tests that the network comes from the range an IP was found in
+func TestIPService_Whois_Network(t *testing.T) {
+	csvStore, err := store.NewCSVStoreFromReader(strings.NewReader("ip,city,country\n" +
+		"1.2.3.0/24,Berlin,Germany\n" +
+		"1.2.3.4,Munich,Germany\n" +
+		"2001:db8::/32,Documentation,Documentation\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	service := NewIPService(csvStore, nil, nil)
+
+	tests := []struct {
+		ip      string
+		network string
+	}{
+		{"1.2.3.200", "1.2.3.0/24"},
+		{"2001:db8::1", "2001:db8::/32"},
+		{"1.2.3.4", ""}, // answered by its own row
+	}
+	for _, tt := range tests {
+		result, err := service.Whois(context.Background(), tt.ip)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tt.ip, err)
+		}
+		if result.Network != tt.network {
+			t.Errorf("%s: expected network %q, got %q", tt.ip, tt.network, result.Network)
+		}
+		if len(result.Errors) != 0 {
+			t.Errorf("%s: expected no errors, got %v", tt.ip, result.Errors)
+		}
+	}
+}
+
+// TestSpanNetwork tests the block found for IPs in aligned and unaligned ranges
+func TestSpanNetwork(t *testing.T) {
+	tests := []struct {
+		ip, start, end string
+		expected       string
+	}{
+		{"10.0.0.42", "10.0.0.0", "10.0.0.255", "10.0.0.0/24"},
+		{"10.0.2.1", "10.0.1.0", "10.0.2.127", "10.0.2.0/25"},
+		{"10.0.1.9", "10.0.1.0", "10.0.2.127", "10.0.1.0/24"},
+		{"1.2.3.4", "1.2.3.4", "1.2.3.4", "1.2.3.4/32"},
+		{"0.0.0.1", "0.0.0.0", "255.255.255.255", "0.0.0.0/0"},
+		{"2001:db8::1", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::/32"},
+		{"10.0.3.1", "10.0.1.0", "10.0.2.127", ""},
+	}
+	for _, tt := range tests {
+		span := &store.IPSpan{Start: net.ParseIP(tt.start), End: net.ParseIP(tt.end)}
+		if got := spanNetwork(net.ParseIP(tt.ip), span); got != tt.expected {
+			t.Errorf("%s in %s - %s: expected %q, got %q", tt.ip, tt.start, tt.end, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/cache"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CachedStore answers repeated lookups of hot IPs from an in-process LRU cache in front of any store
+// Each FindByIP result is cached for the TTL, including pkerr.ErrNotFound, so a burst of lookups of
+// an unknown IP reaches the inner store once rather than every time. Other errors aren't cached
+//
+// Writes made through the CachedStore (BulkLoad, BulkDelete, Reload) update the cache; changes made to the
+// inner store in any other way, such as by another server, show after at most the TTL
+type CachedStore struct {
+	inner Store
+	ttl   time.Duration
+
+	// entries holds the result of each recently looked-up IP
+	entries *cache.Cache[string, cachedLookup]
+
+	metrics CachedStoreMetrics // Optional, see SetMetrics
+}
+
+// cachedLookup is a cached FindByIP result: a location, or not found
+type cachedLookup struct {
+	location models.IPLocation
+	found    bool
+}
+
+// CachedStoreMetrics are the optional metrics a CachedStore records; nil fields are skipped
+type CachedStoreMetrics struct {
+	Hits      prometheus.Counter // Lookups answered from the cache
+	Misses    prometheus.Counter // Lookups sent to the inner store
+	Entries   prometheus.Gauge   // IPs cached now
+	Evictions prometheus.Gauge   // IPs evicted to make room since the store was created
+}
+
+// NewCachedStore creates a store caching up to maxEntries lookups of inner for ttl each
+// A maxEntries <= 0 uses cache.DefaultSize; a ttl <= 0 keeps results until they're evicted
+func NewCachedStore(inner Store, maxEntries int, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		inner:   inner,
+		ttl:     ttl,
+		entries: cache.New[string, cachedLookup](maxEntries, 0),
+	}
+}
+
+// SetMetrics sets the metrics recorded by lookups
+func (s *CachedStore) SetMetrics(metrics CachedStoreMetrics) {
+	s.metrics = metrics
+}
+
+// FindByIP looks up ip in the cache, then in the inner store on a miss
+// Implements the Store interface method
+func (s *CachedStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	if cached, ok := s.entries.Get(ip); ok {
+		if s.metrics.Hits != nil {
+			s.metrics.Hits.Inc()
+		}
+		if !cached.found {
+			return nil, pkerr.ErrNotFound
+		}
+		location := cached.location
+		return &location, nil
+	}
+	if s.metrics.Misses != nil {
+		s.metrics.Misses.Inc()
+	}
+
+	location, err := s.inner.FindByIP(ctx, ip)
+	switch {
+	case err == nil:
+		s.remember(ip, cachedLookup{location: *location, found: true})
+		return location, nil
+	case errors.Is(err, pkerr.ErrNotFound):
+		s.remember(ip, cachedLookup{})
+	}
+	return nil, err
+}
+
+// remember caches result for ip, evicting the least recently used entry when full
+func (s *CachedStore) remember(ip string, result cachedLookup) {
+	s.entries.Set(ip, result, s.ttl)
+	s.updateGauges()
+}
+
+// updateGauges reports the cache's size and evictions
+func (s *CachedStore) updateGauges() {
+	if s.metrics.Entries == nil && s.metrics.Evictions == nil {
+		return
+	}
+	stats := s.entries.Stats()
+	if s.metrics.Entries != nil {
+		s.metrics.Entries.Set(float64(stats.Len))
+	}
+	if s.metrics.Evictions != nil {
+		s.metrics.Evictions.Set(float64(stats.Evictions))
+	}
+}
+
+// clear drops every cached result, which no longer matches the data
+func (s *CachedStore) clear() {
+	s.entries.Clear()
+	s.updateGauges()
+}
+
+// RangeFindByIP looks up ip in the inner store's ranges
+// Implements the RangeFinder interface. Range lookups aren't cached: they only follow a FindByIP miss
+func (s *CachedStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return rangeFindByIP(ctx, s.inner, ip)
+}
+
+// BulkLoad writes locations to the inner store and drops the cache
+// Implements the BulkLoader interface; fails if the inner store doesn't implement it
+func (s *CachedStore) BulkLoad(locations []*models.IPLocation) error {
+	loader, ok := s.inner.(BulkLoader)
+	if !ok {
+		return fmt.Errorf("inner store does not support bulk loading")
+	}
+	if err := loader.BulkLoad(locations); err != nil {
+		return err
+	}
+
+	s.clear()
+	return nil
+}
+
+// BulkDelete deletes ips from the inner store and drops them from the cache
+// Implements the BulkDeleter interface; fails if the inner store doesn't implement it
+func (s *CachedStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	deleter, ok := s.inner.(BulkDeleter)
+	if !ok {
+		return 0, fmt.Errorf("inner store does not support bulk deletes")
+	}
+
+	// Drop even when the delete fails: it may have removed some of the records
+	deleted, err := deleter.BulkDelete(ctx, ips)
+	for _, ip := range ips {
+		s.entries.Delete(ip)
+	}
+	s.updateGauges()
+	return deleted, err
+}
+
+// Warmup warms up the inner store if it implements WarmableStore
+// Implements the WarmableStore interface
+func (s *CachedStore) Warmup(ctx context.Context) error {
+	if w, ok := s.inner.(WarmableStore); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// ListCountries lists the inner store's countries
+// Implements the CountryLister interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *CachedStore) ListCountries(ctx context.Context) ([]string, error) {
+	lister, ok := s.inner.(CountryLister)
+	if !ok {
+		return nil, fmt.Errorf("listing countries is %w", pkerr.ErrNotSupported)
+	}
+	return lister.ListCountries(ctx)
+}
+
+// Search searches the inner store
+// Implements the Searcher interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *CachedStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.inner.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	}
+	return searcher.Search(ctx, query)
+}
+
+// Count counts the inner store's records
+// Implements the Counter interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *CachedStore) Count(ctx context.Context) (int, error) {
+	counter, ok := s.inner.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("counting is %w", pkerr.ErrNotSupported)
+	}
+	return counter.Count(ctx)
+}
+
+// Reload reloads the inner store and drops the cache once it has
+// Implements the Reloader interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *CachedStore) Reload() (ReloadResult, error) {
+	reloader, ok := s.inner.(Reloader)
+	if !ok {
+		return ReloadResult{}, fmt.Errorf("reloading is %w", pkerr.ErrNotSupported)
+	}
+	result, err := reloader.Reload()
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	s.clear()
+	return result, nil
+}
+
+// Stats reports the inner store's stats
+// Implements the StatsProvider interface
+func (s *CachedStore) Stats() StoreStats {
+	if provider, ok := s.inner.(StatsProvider); ok {
+		return provider.Stats()
+	}
+	return StoreStats{}
+}
+
+// Close closes the inner store
+func (s *CachedStore) Close() error {
+	return s.inner.Close()
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// setupCachedStore creates a cached store over a mock, with all its metrics
+func setupCachedStore(maxEntries int, ttl time.Duration) (*CachedStore, *MockStore, CachedStoreMetrics) {
+	inner := NewMockStore()
+	metrics := CachedStoreMetrics{
+		Hits:      prometheus.NewCounter(prometheus.CounterOpts{Name: "test_cache_hits_total"}),
+		Misses:    prometheus.NewCounter(prometheus.CounterOpts{Name: "test_cache_misses_total"}),
+		Entries:   prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_cache_entries"}),
+		Evictions: prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_cache_evictions"}),
+	}
+
+	s := NewCachedStore(inner, maxEntries, ttl)
+	s.SetMetrics(metrics)
+
+	return s, inner, metrics
+}
+
+// TestCachedStore_CachesLookups tests that a repeated lookup is answered without the inner store
+func TestCachedStore_CachesLookups(t *testing.T) {
+	s, inner, metrics := setupCachedStore(0, time.Minute)
+
+	for range 3 {
+		location, err := s.FindByIP(context.Background(), "8.8.8.8")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if location.City != "Mountain View" {
+			t.Errorf("expected Mountain View, got %+v", location)
+		}
+		location.City = "Changed by the caller" // Mustn't change the cached result
+	}
+
+	if len(inner.FindByIPCalls) != 1 {
+		t.Errorf("expected 1 lookup to reach the inner store, got %v", inner.FindByIPCalls)
+	}
+	if hits, misses := testutil.ToFloat64(metrics.Hits), testutil.ToFloat64(metrics.Misses); hits != 2 || misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %v and %v", hits, misses)
+	}
+	if got := testutil.ToFloat64(metrics.Entries); got != 1 {
+		t.Errorf("expected 1 entry, got %v", got)
+	}
+}
+
+// TestCachedStore_CachesNotFound tests that not found results are cached too
+func TestCachedStore_CachesNotFound(t *testing.T) {
+	s, inner, _ := setupCachedStore(0, time.Minute)
+
+	for range 3 {
+		if _, err := s.FindByIP(context.Background(), "9.9.9.9"); !errors.Is(err, pkerr.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if len(inner.FindByIPCalls) != 1 {
+		t.Errorf("expected 1 lookup to reach the inner store, got %v", inner.FindByIPCalls)
+	}
+}
+
+// TestCachedStore_DoesNotCacheErrors tests that store errors reach the inner store every time
+func TestCachedStore_DoesNotCacheErrors(t *testing.T) {
+	s, inner, _ := setupCachedStore(0, time.Minute)
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+
+	s.FindByIP(context.Background(), "8.8.8.8")
+	inner.FindByIPError = nil
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Errorf("expected the error not to be cached, got %v", err)
+	}
+	if len(inner.FindByIPCalls) != 2 {
+		t.Errorf("expected 2 lookups to reach the inner store, got %v", inner.FindByIPCalls)
+	}
+}
+
+// TestCachedStore_Expires tests that results are looked up again after the TTL
+func TestCachedStore_Expires(t *testing.T) {
+	s, inner, _ := setupCachedStore(0, 20*time.Millisecond)
+
+	s.FindByIP(context.Background(), "8.8.8.8")
+	inner.Data["8.8.8.8"] = &models.IPLocation{IP: "8.8.8.8", City: "Reloaded City", Country: "United States"}
+	time.Sleep(30 * time.Millisecond)
+
+	location, err := s.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil || location.City != "Reloaded City" {
+		t.Errorf("expected the expired result to be looked up again, got %+v, %v", location, err)
+	}
+}
+
+// TestCachedStore_EvictsLeastRecentlyUsed tests that the cache stays within its size and counts evictions
+func TestCachedStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	s, inner, metrics := setupCachedStore(1, time.Minute)
+
+	s.FindByIP(context.Background(), "8.8.8.8")
+	s.FindByIP(context.Background(), "1.1.1.1")
+	s.FindByIP(context.Background(), "8.8.8.8")
+
+	if len(inner.FindByIPCalls) != 3 {
+		t.Errorf("expected 8.8.8.8 to be evicted and looked up again, got %v", inner.FindByIPCalls)
+	}
+	if entries, evictions := testutil.ToFloat64(metrics.Entries), testutil.ToFloat64(metrics.Evictions); entries != 1 || evictions != 2 {
+		t.Errorf("expected 1 entry and 2 evictions, got %v and %v", entries, evictions)
+	}
+}
+
+// TestCachedStore_WritesInvalidate tests that BulkLoad drops the cache and BulkDelete the deleted IPs
+func TestCachedStore_WritesInvalidate(t *testing.T) {
+	s, inner, _ := setupCachedStore(0, time.Minute)
+	s.FindByIP(context.Background(), "8.8.8.8")
+	s.FindByIP(context.Background(), "9.9.9.9") // Not found, cached
+
+	if err := s.BulkLoad([]*models.IPLocation{{IP: "9.9.9.9", City: "Berkeley", Country: "United States"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location, err := s.FindByIP(context.Background(), "9.9.9.9"); err != nil || location.City != "Berkeley" {
+		t.Errorf("expected the loaded IP after BulkLoad, got %+v, %v", location, err)
+	}
+
+	s.FindByIP(context.Background(), "8.8.8.8")
+	if _, err := s.BulkDelete(context.Background(), []string{"8.8.8.8"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected the deleted IP to be gone, got %v", err)
+	}
+	if len(inner.FindByIPCalls) != 5 {
+		t.Errorf("expected every lookup after a write to reach the inner store, got %v", inner.FindByIPCalls)
+	}
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// contractLocations is the data every backend is seeded with before RunStoreContractTests
+var contractLocations = []models.IPLocation{
+	{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	{IP: "2.22.233.255", City: "London", Country: "United Kingdom"},
+	{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+}
+
+// contractUnknownIP is a valid address (TEST-NET-3) that no backend holds
+const contractUnknownIP = "203.0.113.1"
+
+// RunStoreContractTests checks the behaviour every Store implementation must share
+// s must hold exactly contractLocations. Close is called last, so s is unusable afterwards
+//
+// Any new store backend must pass these tests before being merged
+func RunStoreContractTests(t *testing.T, s Store) {
+	t.Helper()
+
+	t.Run("FindByIP_Known", func(t *testing.T) {
+		for _, expected := range contractLocations {
+			location, err := s.FindByIP(context.Background(), expected.IP)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", expected.IP, err)
+			}
+			if location.IP != expected.IP || location.City != expected.City || location.Country != expected.Country {
+				t.Errorf("%s: expected %+v, got %+v", expected.IP, expected, *location)
+			}
+		}
+	})
+
+	t.Run("FindByIP_Unknown", func(t *testing.T) {
+		location, err := s.FindByIP(context.Background(), contractUnknownIP)
+		if !errors.Is(err, pkerr.ErrNotFound) {
+			t.Errorf("expected 'IP address not found', got %v", err)
+		}
+		if location != nil {
+			t.Errorf("expected nil location, got %+v", *location)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		c, ok := s.(Counter)
+		if !ok {
+			t.Skipf("%T does not implement Counter", s)
+		}
+		n, err := c.Count(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != len(contractLocations) {
+			t.Errorf("expected %d records, got %d", len(contractLocations), n)
+		}
+	})
+
+	t.Run("Iterate", func(t *testing.T) {
+		it, ok := s.(Iterator)
+		if !ok {
+			t.Skipf("%T does not implement Iterator", s)
+		}
+
+		visited := make(map[string]models.IPLocation)
+		err := it.Iterate(func(location *models.IPLocation) error {
+			visited[location.IP] = *location
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(visited) != len(contractLocations) {
+			t.Errorf("expected %d records, visited %d", len(contractLocations), len(visited))
+		}
+		for _, expected := range contractLocations {
+			if got, ok := visited[expected.IP]; !ok || got != expected {
+				t.Errorf("%s: expected %+v, visited %+v", expected.IP, expected, got)
+			}
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := s.Close(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// TestStoreContract runs the contract tests against every backend that can run in-process
+func TestStoreContract(t *testing.T) {
+	backends := []struct {
+		name  string
+		setup func(t *testing.T) Store
+	}{
+		{"CSV", setupContractCSVStore},
+		{"MySQL", setupContractMySQLStore},
+		{"Redis", setupContractRedisStore},
+		{"RedisCluster", setupContractRedisClusterStore},
+		{"Sorted", setupContractSortedStore},
+		{"Cached", setupContractCachedStore},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			RunStoreContractTests(t, backend.setup(t))
+		})
+	}
+}
+
+// setupContractCSVStore writes contractLocations to a temporary CSV file and loads it
+func setupContractCSVStore(t *testing.T) Store {
+	t.Helper()
+
+	var content strings.Builder
+	content.WriteString("ip,city,country\n")
+	for _, location := range contractLocations {
+		content.WriteString(location.IP + "," + location.City + "," + location.Country + "\n")
+	}
+
+	csvPath := filepath.Join(t.TempDir(), "contract.csv")
+	if err := os.WriteFile(csvPath, []byte(content.String()), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	s, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	return s
+}
+
+// setupContractCachedStore puts a cache in front of the CSV contract store
+func setupContractCachedStore(t *testing.T) Store {
+	t.Helper()
+	return NewCachedStore(setupContractCSVStore(t), 0, time.Minute)
+}
+
+// setupContractSortedStore creates a sorted store holding contractLocations
+func setupContractSortedStore(t *testing.T) Store {
+	t.Helper()
+
+	locations := make([]*models.IPLocation, len(contractLocations))
+	for i := range contractLocations {
+		location := contractLocations[i]
+		locations[i] = &location
+	}
+	return NewSortedStore(locations)
+}
+
+// setupContractMySQLStore creates a MySQL store over sqlmock
+// sqlmock matches in order, so the expectations follow the order of RunStoreContractTests
+func setupContractMySQLStore(t *testing.T) Store {
+	t.Helper()
+
+	db, mock, _ := setupMockDB(t)
+	columns := []string{"ip", "city", "country"}
+
+	// FindByIP_Known
+	for _, location := range contractLocations {
+		mock.ExpectQuery("SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+			WithArgs(location.IP, 1).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(location.IP, location.City, location.Country))
+	}
+
+	// FindByIP_Unknown
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+		WithArgs(contractUnknownIP, 1).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	// Count
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `ip2country`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(contractLocations)))
+
+	// Iterate: a single short page
+	rows := sqlmock.NewRows(columns)
+	for _, location := range contractLocations {
+		rows.AddRow(location.IP, location.City, location.Country)
+	}
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` ORDER BY ip LIMIT \\?").
+		WithArgs(mysqlIteratePageSize).
+		WillReturnRows(rows)
+
+	// Close
+	mock.ExpectClose()
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+	return &MySQLStore{db: db}
+}
+
+// setupContractRedisStore creates a Redis store over miniredis, bulk loaded with contractLocations
+func setupContractRedisStore(t *testing.T) Store {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	s, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+
+	locations := make([]*models.IPLocation, len(contractLocations))
+	for i := range contractLocations {
+		location := contractLocations[i]
+		locations[i] = &location
+	}
+	if err := s.BulkLoad(locations); err != nil {
+		t.Fatalf("failed to load data: %v", err)
+	}
+	return s
+}
+
+// setupContractRedisClusterStore creates a Redis Cluster store over miniredis (a single node owning every slot)
+func setupContractRedisClusterStore(t *testing.T) Store {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	s, err := NewRedisClusterStore([]string{mr.Addr()}, "")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis Cluster: %v", err)
+	}
+
+	for _, location := range contractLocations {
+		if err := s.Set(location.IP, location.City, location.Country); err != nil {
+			t.Fatalf("failed to load data: %v", err)
+		}
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"cmp"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/iprange"
+)
+
+// addrRange is an inclusive range of addresses sharing one location
+// Addresses are stored in a form that orders like them, so ranges can be sorted and binary searched
+type addrRange[K cmp.Ordered] struct {
+	start    K
+	end      K
+	location models.IPLocation
+}
+
+// ipRange is an inclusive range of IPv4 addresses, stored as uint32
+type ipRange = addrRange[uint32]
+
+// ipv6Range is an inclusive range of IPv6 addresses, stored as their 16 bytes in a string
+// (big-endian bytes compare like the addresses, and a string is ordered)
+type ipv6Range = addrRange[string]
+
+// isRangeHeader reports whether a CSV header selects range mode (ip_start,ip_end,city,country,
+// optionally followed by country_code,continent_code)
+func isRangeHeader(header []string) bool {
+	return (len(header) == 4 || len(header) == 4+len(csvCodeColumns)) &&
+		strings.EqualFold(strings.TrimSpace(header[0]), "ip_start") &&
+		strings.EqualFold(strings.TrimSpace(header[1]), "ip_end")
+}
+
+// ipv4ToUint32 converts an IPv4 address (or IPv4-mapped IPv6 address) to its integer form
+// ok is false for anything else: ranges only cover IPv4
+func ipv4ToUint32(s string) (n uint32, ok bool) {
+	n, err := iprange.ToUint32(net.ParseIP(s))
+	return n, err == nil
+}
+
+// ipv6ToKey converts an IPv6 address to its 16 bytes, the form ipv6Range stores
+// ok is false for IPv4 and IPv4-mapped addresses, which ipv4ToUint32 converts, and for invalid ones
+func ipv6ToKey(s string) (key string, ok bool) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() != nil {
+		return "", false
+	}
+	return string(ip), true
+}
+
+// isCIDR reports whether ip is a CIDR block (e.g. 1.2.3.0/24) rather than a single address
+func isCIDR(ip string) bool {
+	return strings.Contains(ip, "/")
+}
+
+// parseCIDRRange converts an IPv4 CIDR block to the inclusive range of addresses it covers
+// ok is false for invalid blocks and IPv6 blocks: ranges only cover IPv4
+func parseCIDRRange(cidr string) (start, end uint32, ok bool) {
+	first, last, err := iprange.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return 0, 0, false
+	}
+	start, err = iprange.ToUint32(first)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err = iprange.ToUint32(last)
+	return start, end, err == nil
+}
+
+// parseIPv6CIDRRange converts an IPv6 CIDR block to the inclusive range of addresses it covers, as ipv6ToKey keys
+// ok is false for invalid blocks and IPv4 blocks, which parseCIDRRange converts
+func parseIPv6CIDRRange(cidr string) (start, end string, ok bool) {
+	first, last, err := iprange.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil || first.To4() != nil {
+		return "", "", false
+	}
+	return string(first), string(last), true
+}
+
+// parseRangeRecord parses an ip_start,ip_end,city,country row, with the code columns if layout has them
+// ok is false for rows to skip: wrong column count, non-IPv4 addresses, or ip_end before ip_start
+func parseRangeRecord(record []string, layout csvLayout) (r ipRange, ok bool) {
+	if len(record) != layout.columns() {
+		return ipRange{}, false
+	}
+	start, ok := ipv4ToUint32(record[0])
+	if !ok {
+		return ipRange{}, false
+	}
+	end, ok := ipv4ToUint32(record[1])
+	if !ok || end < start {
+		return ipRange{}, false
+	}
+	return ipRange{
+		start:    start,
+		end:      end,
+		location: layout.location(record),
+	}, true
+}
+
+// sortRanges sorts ranges by start address, so findRange can binary search them
+// The sort is stable: of several ranges with the same start, the first in the file is kept
+// and the rest are dropped, so lookups don't depend on how the sort ordered them
+func sortRanges[K cmp.Ordered](ranges []addrRange[K]) []addrRange[K] {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	sorted := ranges[:0]
+	for _, r := range ranges {
+		if len(sorted) > 0 && r.start == sorted[len(sorted)-1].start {
+			continue
+		}
+		sorted = append(sorted, r)
+	}
+	return sorted
+}
+
+// findRange returns the range containing ip in ranges, sorted by sortRanges
+// Binary search - O(log N): the candidate is the range with the greatest start <= ip.
+// Ranges are expected not to overlap; if they do, the range starting closest below ip wins
+func findRange[K cmp.Ordered](ranges []addrRange[K], ip K) (*addrRange[K], bool) {
+	// Index of the first range starting after ip
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].start > ip
+	})
+	if i == 0 {
+		return nil, false
+	}
+	r := &ranges[i-1]
+	if ip > r.end {
+		return nil, false
+	}
+	return r, true
+}
@@ -0,0 +1,321 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// rangeCSV builds a range mode CSV of n ranges of 256 addresses, each followed by a gap of 256 addresses
+// Range i covers 1.0.0.0 + 512*i through 1.0.0.0 + 512*i + 255
+func rangeCSV(n int) string {
+	var sb strings.Builder
+	sb.WriteString("ip_start,ip_end,city,country\n")
+	for i := 0; i < n; i++ {
+		start := rangeStart(i)
+		fmt.Fprintf(&sb, "%s,%s,City %d,Country %d\n", uint32ToIPv4(start), uint32ToIPv4(start+255), i, i%200)
+	}
+	return sb.String()
+}
+
+// rangeStart returns the first address of range i in rangeCSV
+func rangeStart(i int) uint32 {
+	return 1<<24 + uint32(i)*512
+}
+
+// uint32ToIPv4 formats an address in its integer form as a dotted quad
+func uint32ToIPv4(n uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// newRangeCSVStore loads a range mode CSV store from content
+func newRangeCSVStore(tb testing.TB, content string) *CSVStore {
+	tb.Helper()
+	store, err := NewCSVStoreFromReader(strings.NewReader(content))
+	if err != nil {
+		tb.Fatalf("failed to create CSV store: %v", err)
+	}
+	tb.Cleanup(func() { store.Close() })
+	return store
+}
+
+// linearFindRange is the O(N) scan findRange is measured against
+func linearFindRange(ranges []ipRange, ip uint32) (*ipRange, bool) {
+	for i := range ranges {
+		if ranges[i].start <= ip && ip <= ranges[i].end {
+			return &ranges[i], true
+		}
+	}
+	return nil, false
+}
+
+// TestCSVStore_Range_Boundaries tests that the first and last address of every range are found
+func TestCSVStore_Range_Boundaries(t *testing.T) {
+	store := newRangeCSVStore(t, rangeCSV(100))
+
+	for i := 0; i < 100; i++ {
+		start := rangeStart(i)
+		for _, ip := range []string{uint32ToIPv4(start), uint32ToIPv4(start + 128), uint32ToIPv4(start + 255)} {
+			location, err := store.FindByIP(context.Background(), ip)
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %v", ip, err)
+			}
+			if expected := fmt.Sprintf("City %d", i); location.City != expected {
+				t.Errorf("expected %s for %s, got %s", expected, ip, location.City)
+			}
+			if location.IP != ip {
+				t.Errorf("expected the looked up IP %s, got %s", ip, location.IP)
+			}
+		}
+	}
+}
+
+// TestCSVStore_Range_NotFound tests addresses outside every range
+func TestCSVStore_Range_NotFound(t *testing.T) {
+	store := newRangeCSVStore(t, rangeCSV(100))
+
+	ips := []string{
+		uint32ToIPv4(rangeStart(0) - 1),    // Before the first range
+		uint32ToIPv4(rangeStart(0) + 256),  // First address of the gap after range 0
+		uint32ToIPv4(rangeStart(50) - 1),   // Last address of the gap before range 50
+		uint32ToIPv4(rangeStart(99) + 256), // After the last range
+		"0.0.0.0",
+		"255.255.255.255",
+		"2001:4860:4860::8888", // Ranges only cover IPv4
+		"not-an-ip",
+	}
+
+	for _, ip := range ips {
+		location, err := store.FindByIP(context.Background(), ip)
+		if !errors.Is(err, pkerr.ErrNotFound) {
+			t.Errorf("expected 'IP address not found' for %s, got %v", ip, err)
+		}
+		if location != nil {
+			t.Errorf("expected nil location for %s, got %+v", ip, location)
+		}
+	}
+}
+
+// TestCSVStore_Range_UnsortedFile tests that ranges are found whatever their order in the file
+func TestCSVStore_Range_UnsortedFile(t *testing.T) {
+	store := newRangeCSVStore(t, `ip_start,ip_end,city,country
+8.8.8.0,8.8.8.255,Mountain View,United States
+1.1.1.0,1.1.1.255,Sydney,Australia
+::ffff:5.5.5.0,5.5.5.255,Paris,France
+9.9.9.9,9.9.9.0,Backwards,Skipped
+not-an-ip,1.0.0.0,Invalid,Skipped
+`)
+
+	if len(store.ranges) != 3 {
+		t.Fatalf("expected 3 valid ranges, got %d", len(store.ranges))
+	}
+
+	for ip, city := range map[string]string{"1.1.1.1": "Sydney", "5.5.5.5": "Paris", "8.8.8.8": "Mountain View"} {
+		location, err := store.FindByIP(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", ip, err)
+		}
+		if location.City != city {
+			t.Errorf("expected %s for %s, got %s", city, ip, location.City)
+		}
+	}
+}
+
+// TestCSVStore_Range_OverlappingStable tests that overlapping ranges resolve the same way on every load
+func TestCSVStore_Range_OverlappingStable(t *testing.T) {
+	content := `ip_start,ip_end,city,country
+10.0.0.0,10.0.0.255,First,A
+10.0.0.0,10.0.255.255,Second,B
+10.0.1.0,10.0.1.255,Nested,C
+10.0.0.0,10.0.0.15,Third,D
+`
+
+	for i := 0; i < 10; i++ {
+		store := newRangeCSVStore(t, content)
+
+		// Ranges with the same start: the first in the file wins
+		location, err := store.FindByIP(context.Background(), "10.0.0.1")
+		if err != nil || location.City != "First" {
+			t.Fatalf("load %d: expected First for 10.0.0.1, got %+v (%v)", i, location, err)
+		}
+
+		// Otherwise the range starting closest below the address wins
+		location, err = store.FindByIP(context.Background(), "10.0.1.1")
+		if err != nil || location.City != "Nested" {
+			t.Fatalf("load %d: expected Nested for 10.0.1.1, got %+v (%v)", i, location, err)
+		}
+	}
+}
+
+// TestCSVStore_Range_ListCountriesAndHealth tests that ranges count as data
+func TestCSVStore_Range_ListCountriesAndHealth(t *testing.T) {
+	store := newRangeCSVStore(t, `ip_start,ip_end,city,country
+8.8.8.0,8.8.8.255,Mountain View,United States
+1.1.1.0,1.1.1.255,Sydney,Australia
+`)
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(countries) != 2 || countries[0] != "Australia" || countries[1] != "United States" {
+		t.Errorf("expected [Australia United States], got %v", countries)
+	}
+
+	if err := store.HealthCheck(context.Background()); err != nil {
+		t.Errorf("expected a healthy store, got %v", err)
+	}
+}
+
+// TestCSVStore_Range_LargeDatasetLatency tests that a lookup over 200K ranges takes under 10µs
+// Measured with testing.Benchmark, so the average is taken over b.N lookups
+func TestCSVStore_Range_LargeDatasetLatency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping latency test in short mode")
+	}
+
+	const n = 200_000
+	store := newRangeCSVStore(t, rangeCSV(n))
+
+	ips := make([]string, 1024)
+	for i := range ips {
+		ips[i] = uint32ToIPv4(rangeStart(i*(n/len(ips))) + 100)
+	}
+
+	result := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.FindByIP(context.Background(), ips[i%len(ips)]); err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+
+	if result.N == 0 {
+		t.Fatal("benchmark did not run")
+	}
+	if perOp := result.NsPerOp(); perOp > 10_000 {
+		t.Errorf("expected a lookup under 10µs over %d ranges, got %dns (%d iterations)", n, perOp, result.N)
+	}
+}
+
+// BenchmarkCSVStore_FindByIP_Range_BinarySearch compares linear scan and binary search over 10K, 100K and 1M ranges
+func BenchmarkCSVStore_FindByIP_Range_BinarySearch(b *testing.B) {
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		store := newRangeCSVStore(b, rangeCSV(n))
+
+		// Spread lookups over the whole dataset, so linear scan isn't measured on its best case
+		ips := make([]uint32, 1024)
+		for i := range ips {
+			ips[i] = rangeStart(i*(n/len(ips))) + 100
+		}
+
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, ok := linearFindRange(store.ranges, ips[i%len(ips)]); !ok {
+					b.Fatal("expected the range to be found")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("binary/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, ok := findRange(store.ranges, ips[i%len(ips)]); !ok {
+					b.Fatal("expected the range to be found")
+				}
+			}
+		})
+	}
+}
+
+// TestCSVStore_Range_Reload tests that reloading a range mode file counts ranges added and removed by their bounds
+func TestCSVStore_Range_Reload(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "ranges.csv")
+	if err := os.WriteFile(csvPath, []byte(rangeCSV(10)), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	store, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+
+	// Ranges 0-9 become 0-11: two added, none removed
+	if err := os.WriteFile(csvPath, []byte(rangeCSV(12)), 0644); err != nil {
+		t.Fatalf("failed to update test file: %v", err)
+	}
+	result, err := store.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := (ReloadResult{Records: 12, Added: 2, Removed: 0}); result != expected {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if _, err := store.FindByIP(context.Background(), uint32ToIPv4(rangeStart(11))); err != nil {
+		t.Errorf("expected the added range after Reload, got %v", err)
+	}
+}
+
+// TestCSVStore_CIDRRows tests that CIDR blocks in an ip,city,country file are looked up as ranges,
+// that exact rows win over the block containing them, that IPv6 blocks are looked up too,
+// and that invalid blocks are skipped
+func TestCSVStore_CIDRRows(t *testing.T) {
+	store := newRangeCSVStore(t, "ip,city,country\n"+
+		"1.2.3.0/24,Berlin,Germany\n"+
+		"1.2.3.4,Munich,Germany\n"+
+		"10.0.0.0/8,Private,Private\n"+
+		"1.2.4.0/33,Invalid,Invalid\n"+
+		"2001:db8::/32,Documentation,Documentation\n")
+
+	tests := []struct {
+		ip   string
+		city string
+	}{
+		{"1.2.3.0", "Berlin"},
+		{"1.2.3.255", "Berlin"},
+		{"1.2.3.4", "Munich"},
+		{"10.20.30.40", "Private"},
+		{"1.2.4.1", ""},
+		{"2001:db8::1", "Documentation"},
+		{"2001:db8:ffff::ffff", "Documentation"},
+		{"2001:db9::1", ""},
+	}
+
+	for _, tt := range tests {
+		location, err := store.FindByIP(context.Background(), tt.ip)
+		if tt.city == "" {
+			if !errors.Is(err, pkerr.ErrNotFound) {
+				t.Errorf("%s: expected ErrNotFound, got %v", tt.ip, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.ip, err)
+			continue
+		}
+		if location.City != tt.city || location.IP != tt.ip {
+			t.Errorf("%s: expected %s, got %+v", tt.ip, tt.city, location)
+		}
+	}
+}
+
+// TestCSVStore_RangeFindByIP tests that RangeFindByIP only looks up ranges, not exact rows
+func TestCSVStore_RangeFindByIP(t *testing.T) {
+	store := newRangeCSVStore(t, "ip,city,country\n1.2.3.0/24,Berlin,Germany\n1.2.3.4,Munich,Germany\n8.8.8.8,Mountain View,United States\n")
+
+	location, err := store.RangeFindByIP(context.Background(), "1.2.3.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Berlin" {
+		t.Errorf("expected the block's city Berlin, got %s", location.City)
+	}
+
+	if _, err := store.RangeFindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an IP only in an exact row, got %v", err)
+	}
+}
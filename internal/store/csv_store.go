@@ -1,19 +1,119 @@
 package store
 
 import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
 	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/iprange"
+	"github.com/fsnotify/fsnotify"
 )
 
 // CSVStore implements Store interface using a CSV file
 // It loads all data into memory for fast lookups
 type CSVStore struct {
+	// mu guards data, ranges, ranges6 and version, which Reload, BulkLoad and BulkDelete modify while lookups are served
+	mu sync.RWMutex
+
 	// data maps IP addresses to location information
 	// map[string]*models.IPLocation means: key=IP, value=pointer to IPLocation
 	data map[string]*models.IPLocation
+
+	// ranges holds the rows of a range mode file (see NewCSVStoreFromReader), sorted by start address
+	ranges []ipRange
+
+	// ranges6 holds the IPv6 CIDR blocks of an ip,city,country file, sorted by start address
+	ranges6 []ipv6Range
+
+	// version identifies the loaded data (hash of the file's modification time, or of the content)
+	version string
+
+	// path is the file the store was loaded from (empty for NewCSVStoreFromReader), see Reload
+	path string
+
+	// watcher reports changes to path once Watch is called; closed by Close
+	watcher *fsnotify.Watcher
+
+	// options is how the file is parsed, kept so Reload parses it the same way
+	options csvOptions
+}
+
+// CSVEmbeddedPath selects the CSV dataset compiled into the binary instead of a file on disk
+const CSVEmbeddedPath = ":embedded:"
+
+// csvReloadDelay is how long Watch waits after the last change to the file before reloading it
+// Copying a large file produces many write events; only the last one needs a reload
+const csvReloadDelay = 100 * time.Millisecond
+
+// csvOptions holds the settings applied by CSVOption
+type csvOptions struct {
+	delimiter  rune // Field separator (default ',')
+	lazyQuotes bool // Accept quotes appearing in unquoted fields and unescaped quotes in quoted fields
+}
+
+// CSVOption configures how a CSV store parses its file
+type CSVOption func(*csvOptions)
+
+// WithDelimiter sets the field separator, e.g. '\t' for TSV files or '|' for pipe-delimited files
+func WithDelimiter(delimiter rune) CSVOption {
+	return func(o *csvOptions) {
+		o.delimiter = delimiter
+	}
+}
+
+// WithLazyQuotes relaxes quote handling, for files exported by tools that don't escape quotes (see csv.Reader.LazyQuotes)
+func WithLazyQuotes(lazyQuotes bool) CSVOption {
+	return func(o *csvOptions) {
+		o.lazyQuotes = lazyQuotes
+	}
+}
+
+// newCSVOptions applies options over the defaults, returning an error for a delimiter csv.Reader can't use
+func newCSVOptions(options []CSVOption) (csvOptions, error) {
+	o := csvOptions{delimiter: ','}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.delimiter == '"' || o.delimiter == '\r' || o.delimiter == '\n' || !utf8.ValidRune(o.delimiter) || o.delimiter == utf8.RuneError {
+		return csvOptions{}, fmt.Errorf("invalid CSV delimiter %q", o.delimiter)
+	}
+	return o, nil
+}
+
+// ParseCSVDelimiter returns the single character of s as a delimiter for WithDelimiter ("" = ',')
+// The two characters \t are accepted for a tab, since a literal tab is awkward to write in an environment variable
+func ParseCSVDelimiter(s string) (rune, error) {
+	if s == "" {
+		return ',', nil
+	}
+	if s == `\t` {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("CSV delimiter must be a single character, got %q", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
 }
 
 // NewCSVStore creates a new CSV store by reading a CSV file
@@ -26,7 +126,30 @@ type CSVStore struct {
 //
 // CSV Format: ip,city,country
 // Example: 8.8.8.8,Mountain View,United States
-func NewCSVStore(filePath string) (*CSVStore, error) {
+//
+// Range Format: ip_start,ip_end,city,country (see NewCSVStoreFromReader)
+// Example: 8.8.8.0,8.8.8.255,Mountain View,United States
+//
+// Either format may add country_code,continent_code after country (see csvLayout)
+// Example: 8.8.8.8,Mountain View,United States,US,NA
+//
+// A filePath ending in .gz is decompressed while it's read (see cmd/compress)
+// options change how the file is parsed, e.g. WithDelimiter('\t') for a TSV file
+func NewCSVStore(filePath string, options ...CSVOption) (*CSVStore, error) {
+	opts, err := newCSVOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	store, err := loadCSVFile(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	store.path = filePath
+	return store, nil
+}
+
+// loadCSVFile parses the CSV file at filePath, gzip-compressed if it ends in .gz
+func loadCSVFile(filePath string, opts csvOptions) (*CSVStore, error) {
 	// Open the CSV file for reading
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -36,9 +159,107 @@ func NewCSVStore(filePath string) (*CSVStore, error) {
 	// Ensures file is closed even if we return early due to an error
 	defer file.Close()
 
+	// The modification time identifies this version of the data
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat CSV file: %w", err)
+	}
+
+	var r io.Reader = file
+	if strings.HasSuffix(filePath, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip CSV file: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	store, err := parseCSV(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	store.version = dataVersion(strconv.FormatInt(info.ModTime().UnixNano(), 16))
+
+	return store, nil
+}
+
+// NewEmbeddedCSVStore creates a CSV store from the dataset bundled in the binary (data/ip2country.csv)
+// Needs no files at runtime, so it works in minimal containers
+func NewEmbeddedCSVStore() (*CSVStore, error) {
+	if len(EmbeddedCSV) == 0 {
+		return nil, fmt.Errorf("no embedded CSV data")
+	}
+	return NewCSVStoreFromReader(bytes.NewReader(EmbeddedCSV))
+}
+
+// NewCSVStoreFromReader creates a CSV store from any CSV source
+// The DataVersion is a hash of the content, since a reader has no modification time
+//
+// A header starting with ip_start,ip_end selects range mode: each row covers an inclusive
+// range of IPv4 addresses, and FindByIP binary searches the ranges sorted by start address.
+// In the ip,city,country format, a CIDR block in the ip column (1.2.3.0/24, 2001:db8::/32) is a range too
+func NewCSVStoreFromReader(r io.Reader, options ...CSVOption) (*CSVStore, error) {
+	opts, err := newCSVOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	store, err := parseCSV(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// csvCodeColumns are the optional columns after country, in both CSV formats
+var csvCodeColumns = []string{"country_code", "continent_code"}
+
+// csvLayout is where the location columns of a CSV file are, read from its header row
+// The header decides whether rows have the code columns, so files written before they
+// existed keep loading, with the codes left empty
+type csvLayout struct {
+	city  int  // Index of the city column; country follows it
+	codes bool // country_code,continent_code follow the country column
+}
+
+// newCSVLayout reads the layout of a file whose city column is at index city from its header
+func newCSVLayout(header []string, city int) csvLayout {
+	layout := csvLayout{city: city}
+	extra := header[min(city+2, len(header)):]
+	layout.codes = slices.EqualFunc(extra, csvCodeColumns, func(column, name string) bool {
+		return strings.EqualFold(strings.TrimSpace(column), name)
+	})
+	return layout
+}
+
+// columns returns the number of columns of a valid row
+func (l csvLayout) columns() int {
+	if l.codes {
+		return l.city + 2 + len(csvCodeColumns)
+	}
+	return l.city + 2
+}
+
+// location returns the location in a valid row (see columns), without its IP
+func (l csvLayout) location(record []string) models.IPLocation {
+	location := models.IPLocation{City: record[l.city], Country: record[l.city+1]}
+	if l.codes {
+		location.CountryCode = record[l.city+2]
+		location.ContinentCode = record[l.city+3]
+	}
+	return location
+}
+
+// parseCSV reads every row of r into a new store (see NewCSVStoreFromReader for the formats)
+func parseCSV(r io.Reader, opts csvOptions) (*CSVStore, error) {
+	// Hash the content as the CSV reader consumes it
+	hash := sha256.New()
+
 	// Create a CSV reader
 	// csv.Reader knows how to parse CSV format
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(io.TeeReader(r, hash))
+	reader.Comma = opts.delimiter
+	reader.LazyQuotes = opts.lazyQuotes
 
 	// Read all records at once
 	// records is a 2D slice: [][]string
@@ -50,15 +271,32 @@ func NewCSVStore(filePath string) (*CSVStore, error) {
 
 	// Check if file is empty
 	if len(records) == 0 {
-		return nil, fmt.Errorf("CSV file is empty")
+		return nil, ErrEmptyCSV
 	}
 
 	// Create the store with an empty map
 	// make(map[string]*models.IPLocation) creates a new map
 	store := &CSVStore{
-		data: make(map[string]*models.IPLocation),
+		data:    make(map[string]*models.IPLocation),
+		version: hex.EncodeToString(hash.Sum(nil)),
+		options: opts,
 	}
 
+	if isRangeHeader(records[0]) {
+		layout := newCSVLayout(records[0], 2)
+		for _, record := range records[1:] {
+			// Skip invalid rows, like in the ip,city,country format
+			if r, ok := parseRangeRecord(record, layout); ok {
+				store.ranges = append(store.ranges, r)
+			}
+		}
+		store.ranges = sortRanges(store.ranges)
+		return store, nil
+	}
+
+	// The header says whether rows have the country_code,continent_code columns
+	layout := newCSVLayout(records[0], 1)
+
 	// Parse each record (skip the header row)
 	// range is like "for each" in other languages
 	// i is the index, record is the value
@@ -68,8 +306,8 @@ func NewCSVStore(filePath string) (*CSVStore, error) {
 			continue
 		}
 
-		// Validate record has exactly 3 columns
-		if len(record) != 3 {
+		// Validate record has exactly the header's columns (3, or 5 with the codes)
+		if len(record) != layout.columns() {
 			// Skip invalid records instead of failing
 			// In production, you might want to log this
 			continue
@@ -77,41 +315,379 @@ func NewCSVStore(filePath string) (*CSVStore, error) {
 
 		// Extract fields from the CSV record
 		ip := record[0]
-		city := record[1]
-		country := record[2]
+		location := layout.location(record)
 
-		// Store in map: key=IP, value=IPLocation
-		store.data[ip] = &models.IPLocation{
-			IP:      ip,
-			City:    city,
-			Country: country,
+		// A CIDR block (1.2.3.0/24) is a range, looked up like the rows of a range mode file
+		if isCIDR(ip) {
+			if start, end, ok := parseCIDRRange(ip); ok {
+				store.ranges = append(store.ranges, ipRange{
+					start:    start,
+					end:      end,
+					location: location,
+				})
+			} else if start, end, ok := parseIPv6CIDRRange(ip); ok {
+				store.ranges6 = append(store.ranges6, ipv6Range{
+					start:    start,
+					end:      end,
+					location: location,
+				})
+			}
+			continue
 		}
+
+		// Store in map: key=IP, value=IPLocation
+		location.IP = ip
+		store.data[ip] = &location
 	}
+	store.ranges = sortRanges(store.ranges)
+	store.ranges6 = sortRanges(store.ranges6)
 
 	return store, nil
 }
 
+// Reload re-reads the file the store was loaded from and replaces its data in one swap
+// Changes made by BulkLoad and BulkDelete are discarded. On error the previous data stays in place
+// Implements the Reloader interface
+func (s *CSVStore) Reload() (ReloadResult, error) {
+	if s.path == "" {
+		return ReloadResult{}, fmt.Errorf("CSV store was not loaded from a file")
+	}
+
+	// Parse outside the lock so lookups keep being served while a large file loads
+	loaded, err := loadCSVFile(s.path, s.options)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := ReloadResult{Records: len(loaded.data) + len(loaded.ranges) + len(loaded.ranges6)}
+	result.Added, result.Removed = diffKeys(maps.Keys(s.data), maps.Keys(loaded.data))
+	rangesAdded, rangesRemoved := diffKeys(rangeKeys(s.ranges), rangeKeys(loaded.ranges))
+	result.Added += rangesAdded
+	result.Removed += rangesRemoved
+	rangesAdded, rangesRemoved = diffKeys(rangeKeys(s.ranges6), rangeKeys(loaded.ranges6))
+	result.Added += rangesAdded
+	result.Removed += rangesRemoved
+
+	s.data = loaded.data
+	s.ranges = loaded.ranges
+	s.ranges6 = loaded.ranges6
+	s.version = loaded.version
+	return result, nil
+}
+
+// diffKeys counts the keys of next missing from previous (added) and of previous missing from next (removed)
+func diffKeys[K comparable](previous, next iter.Seq[K]) (added, removed int) {
+	before := make(map[K]struct{})
+	for key := range previous {
+		before[key] = struct{}{}
+	}
+	for key := range next {
+		if _, ok := before[key]; ok {
+			delete(before, key)
+		} else {
+			added++
+		}
+	}
+	return added, len(before)
+}
+
+// rangeKeys yields the start and end address of each range, which identify it
+func rangeKeys[K cmp.Ordered](ranges []addrRange[K]) iter.Seq[[2]K] {
+	return func(yield func([2]K) bool) {
+		for _, r := range ranges {
+			if !yield([2]K{r.start, r.end}) {
+				return
+			}
+		}
+	}
+}
+
+// Watch reloads the file whenever it changes on disk, until Close
+// Failed reloads are logged to log (if not nil) and keep the previous data
+//
+// The directory is watched rather than the file, so the file can be replaced
+// atomically (written elsewhere, then renamed over it) as well as edited in place
+func (s *CSVStore) Watch(log *applogger.Logger) error {
+	if s.path == "" {
+		return fmt.Errorf("CSV store was not loaded from a file")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch CSV file: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch CSV file: %w", err)
+	}
+	s.watcher = watcher
+
+	go s.watch(watcher, log)
+	return nil
+}
+
+// watch reloads the file csvReloadDelay after the last event for it, until the watcher is closed
+func (s *CSVStore) watch(watcher *fsnotify.Watcher, log *applogger.Logger) {
+	name := filepath.Clean(s.path)
+	reload := time.NewTimer(csvReloadDelay)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == name && event.Has(fsnotify.Write|fsnotify.Create) {
+				reload.Reset(csvReloadDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if log != nil {
+				log.Warn().Err(err).Str("path", s.path).Msg("CSV file watcher error")
+			}
+
+		case <-reload.C:
+			result, err := s.Reload()
+			if err != nil {
+				if log != nil {
+					log.Error().Err(err).Str("path", s.path).Msg("Failed to reload CSV file, keeping the previous data")
+				}
+				continue
+			}
+			if log != nil {
+				log.Info().Str("path", s.path).Int("records", result.Records).Int("added", result.Added).
+					Int("removed", result.Removed).Msg("CSV file reloaded")
+			}
+		}
+	}
+}
+
 // FindByIP looks up an IP address in the store
 // Implements the Store interface method
-func (s *CSVStore) FindByIP(ip string) (*models.IPLocation, error) {
+func (s *CSVStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	location, _, err := s.FindSpan(ctx, ip)
+	return location, err
+}
+
+// FindSpan looks up an IP address like FindByIP, also returning the range of a range mode file it was found in
+// Implements the SpanFinder interface
+func (s *CSVStore) FindSpan(ctx context.Context, ip string) (*models.IPLocation, *IPSpan, error) {
 	// Look up IP in the map
 	// In Go, map[key] returns two values:
 	//   1. The value (or nil if not found)
 	//   2. A boolean indicating if the key exists
+	s.mu.RLock()
 	location, exists := s.data[ip]
-	if !exists {
-		// Return nil and an error if IP not found
-		return nil, fmt.Errorf("IP address not found")
+	s.mu.RUnlock()
+	if exists {
+		// Return the location data
+		return location, nil, nil
 	}
 
-	// Return the location data
+	// Range mode and CIDR blocks
+	location, span, found := s.findInRanges(ip)
+	if found {
+		return location, span, nil
+	}
+
+	// Return nil and an error if IP not found
+	return nil, nil, pkerr.ErrNotFound
+}
+
+// RangeFindByIP looks up an IP address in the ranges only: the rows of a range mode file and CIDR blocks
+// Implements the RangeFinder interface
+func (s *CSVStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	location, _, found := s.findInRanges(ip)
+	if !found {
+		return nil, pkerr.ErrNotFound
+	}
 	return location, nil
 }
 
+// findInRanges looks up ip in the IPv4 ranges, or the IPv6 blocks if it's an IPv6 address
+func (s *CSVStore) findInRanges(ip string) (*models.IPLocation, *IPSpan, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if n, ok := ipv4ToUint32(ip); ok {
+		if r, found := findRange(s.ranges, n); found {
+			location := r.location
+			location.IP = ip
+			return &location, &IPSpan{Start: iprange.FromUint32(r.start), End: iprange.FromUint32(r.end)}, true
+		}
+		return nil, nil, false
+	}
+	if key, ok := ipv6ToKey(ip); ok {
+		if r, found := findRange(s.ranges6, key); found {
+			location := r.location
+			location.IP = ip
+			return &location, &IPSpan{Start: net.IP(r.start), End: net.IP(r.end)}, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ListCountries returns the distinct countries in the file, sorted alphabetically
+// Implements the CountryLister interface
+func (s *CSVStore) ListCountries(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return distinctCountries(func(yield func(*models.IPLocation) bool) {
+		for _, location := range s.data {
+			if !yield(location) {
+				return
+			}
+		}
+		for i := range s.ranges {
+			if !yield(&s.ranges[i].location) {
+				return
+			}
+		}
+		for i := range s.ranges6 {
+			if !yield(&s.ranges6[i].location) {
+				return
+			}
+		}
+	}), nil
+}
+
+// Search returns the records matching query, ordered by IP
+// Implements the Searcher interface. Like Iterate, ranges of a range mode file aren't searched
+func (s *CSVStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return searchLocations(maps.Values(s.data), query), nil
+}
+
+// Count returns the number of IP records in the file
+// Implements the Counter interface. Like Search, ranges of a range mode file aren't counted
+func (s *CSVStore) Count(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data), nil
+}
+
+// Iterate calls fn for each record, ordered by IP
+// Implements the Iterator interface. Ranges of a range mode file aren't single records,
+// so they're not iterated
+func (s *CSVStore) Iterate(fn func(location *models.IPLocation) error) error {
+	// Map iteration order is random - sort so output is stable between runs
+	// fn is called on a snapshot, outside the lock
+	s.mu.RLock()
+	locations := slices.SortedFunc(maps.Values(s.data), func(a, b *models.IPLocation) int {
+		return strings.Compare(a.IP, b.IP)
+	})
+	s.mu.RUnlock()
+
+	for _, location := range locations {
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadIntoSQLite writes the store's records into a new SQLite database at path, for DATASTORE_TYPE=sqlite
+// Any existing file at path is replaced. The ranges (range mode rows and CIDR blocks) go to its ip_ranges table
+func (s *CSVStore) LoadIntoSQLite(path string) error {
+	_, err := BuildSQLiteDatabase(path, s)
+	return err
+}
+
+// iterateRanges calls fn for each IPv4 range and IPv6 block, ordered by start address
+// Like Iterate, fn is called on a snapshot, outside the lock
+func (s *CSVStore) iterateRanges(fn func(start, end net.IP, location models.IPLocation) error) error {
+	s.mu.RLock()
+	ranges, ranges6 := s.ranges, s.ranges6
+	s.mu.RUnlock()
+
+	for _, r := range ranges {
+		if err := fn(iprange.FromUint32(r.start), iprange.FromUint32(r.end), r.location); err != nil {
+			return err
+		}
+	}
+	for _, r := range ranges6 {
+		if err := fn(net.IP(r.start), net.IP(r.end), r.location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Warmup is a no-op: all data is loaded into memory by NewCSVStore
+// Implements the WarmableStore interface
+func (s *CSVStore) Warmup(ctx context.Context) error {
+	return nil
+}
+
+// Stats returns the data version of the loaded file
+// Implements the StatsProvider interface
+func (s *CSVStore) Stats() StoreStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return StoreStats{DataVersion: s.version}
+}
+
+// BulkDelete removes ips from memory, returning how many were present
+// Implements the BulkDeleter interface. The CSV file itself is left untouched,
+// so deleted IPs come back when the store is next loaded from it
+func (s *CSVStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, ip := range ips {
+		if _, ok := s.data[ip]; ok {
+			delete(s.data, ip)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		s.version = dataVersion(strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+	return deleted, nil
+}
+
+// BulkLoad adds or replaces locations in memory
+// Implements the BulkLoader interface. Like BulkDelete, the CSV file itself is left untouched
+func (s *CSVStore) BulkLoad(locations []*models.IPLocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, location := range locations {
+		stored := *location
+		s.data[location.IP] = &stored
+	}
+	if len(locations) > 0 {
+		s.version = dataVersion(strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+	return nil
+}
+
+// HealthCheck fails when the store holds no data (e.g. a CSV file with only a header)
+// Implements the HealthChecker interface
+func (s *CSVStore) HealthCheck(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.data) == 0 && len(s.ranges) == 0 && len(s.ranges6) == 0 {
+		return fmt.Errorf("CSV store holds no data")
+	}
+	return nil
+}
+
 // Close cleans up resources
 // For CSV store, there's nothing to clean up (all data is in memory)
-// But we need this method to satisfy the Store interface
+// except for the file watcher
 func (s *CSVStore) Close() error {
-	// No resources to clean up
+	if s.watcher != nil {
+		return s.watcher.Close()
+	}
 	return nil
 }
@@ -1,9 +1,22 @@
 package store
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
 )
 
 // TestCSVStore_LoadValidFile tests loading a valid CSV file
@@ -68,8 +81,8 @@ func TestCSVStore_EmptyFile(t *testing.T) {
 	if err == nil {
 		t.Error("expected error for empty file, got nil")
 	}
-	if err.Error() != "CSV file is empty" {
-		t.Errorf("expected 'CSV file is empty', got %s", err.Error())
+	if !errors.Is(err, ErrEmptyCSV) {
+		t.Errorf("expected ErrEmptyCSV, got %v", err)
 	}
 }
 
@@ -149,7 +162,7 @@ func TestCSVStore_FindByIP_Success(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.ip, func(t *testing.T) {
-			location, err := store.FindByIP(tt.ip)
+			location, err := store.FindByIP(context.Background(), tt.ip)
 
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
@@ -180,7 +193,7 @@ func TestCSVStore_FindByIP_NotFound(t *testing.T) {
 	store, _ := NewCSVStore(csvPath)
 	defer store.Close()
 
-	location, err := store.FindByIP("192.168.1.1")
+	location, err := store.FindByIP(context.Background(), "192.168.1.1")
 
 	if err == nil {
 		t.Error("expected not found error, got nil")
@@ -188,7 +201,7 @@ func TestCSVStore_FindByIP_NotFound(t *testing.T) {
 	if location != nil {
 		t.Error("expected nil location, got data")
 	}
-	if err.Error() != "IP address not found" {
+	if !errors.Is(err, pkerr.ErrNotFound) {
 		t.Errorf("expected 'IP address not found', got '%s'", err.Error())
 	}
 }
@@ -244,7 +257,7 @@ func TestCSVStore_SpecialCharacters(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.city, func(t *testing.T) {
-			location, err := store.FindByIP(tt.ip)
+			location, err := store.FindByIP(context.Background(), tt.ip)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -276,7 +289,7 @@ func TestCSVStore_HeaderOnly(t *testing.T) {
 	}
 
 	// Lookup should return not found
-	_, err = store.FindByIP("8.8.8.8")
+	_, err = store.FindByIP(context.Background(), "8.8.8.8")
 	if err == nil {
 		t.Error("expected not found error for empty store")
 	}
@@ -301,7 +314,7 @@ func TestCSVStore_DuplicateIPs(t *testing.T) {
 	defer store.Close()
 
 	// Last entry should win (map overwrites previous value)
-	location, err := store.FindByIP("8.8.8.8")
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -311,3 +324,720 @@ func TestCSVStore_DuplicateIPs(t *testing.T) {
 		t.Errorf("expected last entry to win, got city '%s'", location.City)
 	}
 }
+
+// TestCSVStore_Iterate tests that every record is visited in IP order
+func TestCSVStore_Iterate(t *testing.T) {
+	tmpDir := t.TempDir()
+	csvPath := filepath.Join(tmpDir, "test.csv")
+
+	content := `ip,city,country
+8.8.8.8,Mountain View,United States
+1.1.1.1,Sydney,Australia
+2.22.233.255,London,United Kingdom`
+
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	store, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+
+	var ips []string
+	err = store.Iterate(func(location *models.IPLocation) error {
+		ips = append(ips, location.IP)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"1.1.1.1", "2.22.233.255", "8.8.8.8"}
+	if len(ips) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(ips))
+	}
+	for i := range expected {
+		if ips[i] != expected[i] {
+			t.Errorf("record %d: expected %s, got %s", i, expected[i], ips[i])
+		}
+	}
+}
+
+// TestCSVStore_Iterate_StopsOnError tests that an error from the callback ends iteration
+func TestCSVStore_Iterate_StopsOnError(t *testing.T) {
+	store := &CSVStore{data: map[string]*models.IPLocation{
+		"1.1.1.1": {IP: "1.1.1.1"},
+		"8.8.8.8": {IP: "8.8.8.8"},
+	}}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.Iterate(func(location *models.IPLocation) error {
+		calls++
+		return stop
+	})
+
+	if err != stop {
+		t.Errorf("expected callback error to be returned, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 callback call, got %d", calls)
+	}
+}
+
+// TestCSVStore_Warmup tests that warmup is a no-op for the in-memory store
+func TestCSVStore_Warmup(t *testing.T) {
+	store := &CSVStore{data: map[string]*models.IPLocation{}}
+
+	if err := store.Warmup(context.Background()); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if len(store.data) != 0 {
+		t.Error("expected warmup to leave data untouched")
+	}
+}
+
+// TestCSVStore_DataVersion tests that the version follows the file's modification time
+func TestCSVStore_DataVersion(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n8.8.8.8,Mountain View,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	first, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	again, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	if first.Stats().DataVersion == "" {
+		t.Fatal("expected a data version")
+	}
+	if first.Stats().DataVersion != again.Stats().DataVersion {
+		t.Error("expected the same version for an unchanged file")
+	}
+
+	// Touch the file - a new modification time means new data
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(csvPath, later, later); err != nil {
+		t.Fatalf("failed to update modification time: %v", err)
+	}
+	updated, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	if updated.Stats().DataVersion == first.Stats().DataVersion {
+		t.Error("expected version to change with the modification time")
+	}
+}
+
+// writeGzipFile writes content to path, gzip-compressed
+func writeGzipFile(t testing.TB, path, content string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatalf("failed to compress test file: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to compress test file: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+}
+
+// TestCSVStore_LoadGzipFile tests that a .gz file is decompressed and every record parsed
+func TestCSVStore_LoadGzipFile(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "test.csv.gz")
+	writeGzipFile(t, csvPath, `ip,city,country
+8.8.8.8,Mountain View,United States
+1.1.1.1,Sydney,Australia
+2001:4860:4860::8888,Mountain View,United States`)
+
+	store, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		ip      string
+		city    string
+		country string
+	}{
+		{ip: "8.8.8.8", city: "Mountain View", country: "United States"},
+		{ip: "1.1.1.1", city: "Sydney", country: "Australia"},
+		{ip: "2001:4860:4860::8888", city: "Mountain View", country: "United States"},
+	}
+	for _, tt := range tests {
+		location, err := store.FindByIP(context.Background(), tt.ip)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", tt.ip, err)
+			continue
+		}
+		if location.City != tt.city || location.Country != tt.country {
+			t.Errorf("%s: expected %s, %s, got %+v", tt.ip, tt.city, tt.country, location)
+		}
+	}
+	if len(store.data) != 3 {
+		t.Errorf("expected 3 records, got %d", len(store.data))
+	}
+}
+
+// TestCSVStore_LoadGzipFile_NotCompressed tests that a plain file named .gz is rejected
+func TestCSVStore_LoadGzipFile_NotCompressed(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "test.csv.gz")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n8.8.8.8,Mountain View,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	if _, err := NewCSVStore(csvPath); err == nil || !strings.Contains(err.Error(), "gzip") {
+		t.Errorf("expected a gzip error, got %v", err)
+	}
+}
+
+// TestCSVStore_Reload tests that Reload replaces the data with the file's current contents
+func TestCSVStore_Reload(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n8.8.8.8,Mountain View,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	store, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n1.1.1.1,Sydney,Australia\n9.9.9.9,Berkeley,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to update test file: %v", err)
+	}
+	result, err := store.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := (ReloadResult{Records: 2, Added: 2, Removed: 1}); result != expected {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if _, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected the new record after Reload, got %v", err)
+	}
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected the removed record to be gone after Reload")
+	}
+
+	// A broken file keeps the previous data
+	if err := os.WriteFile(csvPath, []byte(""), 0644); err != nil {
+		t.Fatalf("failed to update test file: %v", err)
+	}
+	if _, err := store.Reload(); err == nil {
+		t.Error("expected error for an empty file, got nil")
+	}
+	if _, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected the previous data to be kept, got %v", err)
+	}
+
+	fromReader, _ := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n"))
+	if _, err := fromReader.Reload(); err == nil {
+		t.Error("expected error reloading a store not loaded from a file, got nil")
+	}
+}
+
+// TestCSVStore_Watch_Gzip tests that a watched .gz file is reloaded after it's replaced
+func TestCSVStore_Watch_Gzip(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "test.csv.gz")
+	writeGzipFile(t, csvPath, "ip,city,country\n8.8.8.8,Mountain View,United States\n")
+
+	store, err := NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Watch(nil); err != nil {
+		t.Fatalf("failed to watch CSV file: %v", err)
+	}
+
+	// Replace the file atomically, as cmd/compress does
+	tmpPath := filepath.Join(dir, "test.csv.gz.tmp")
+	writeGzipFile(t, tmpPath, "ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n")
+	if err := os.Rename(tmpPath, csvPath); err != nil {
+		t.Fatalf("failed to replace test file: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		location, err := store.FindByIP(context.Background(), "1.1.1.1")
+		if err == nil {
+			if location.City != "Sydney" {
+				t.Errorf("expected Sydney, got %s", location.City)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the replaced file to be reloaded within 2s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkCSVStore_Load_Gzip_vs_Plain compares loading 100K rows from a plain and a gzip file
+func BenchmarkCSVStore_Load_Gzip_vs_Plain(b *testing.B) {
+	var content strings.Builder
+	content.WriteString("ip,city,country\n")
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&content, "10.%d.%d.%d,City %d,Country %d\n", i>>16&0xff, i>>8&0xff, i&0xff, i%1000, i%200)
+	}
+
+	dir := b.TempDir()
+	plainPath := filepath.Join(dir, "bench.csv")
+	gzipPath := filepath.Join(dir, "bench.csv.gz")
+	if err := os.WriteFile(plainPath, []byte(content.String()), 0644); err != nil {
+		b.Fatalf("failed to create test file: %v", err)
+	}
+	writeGzipFile(b, gzipPath, content.String())
+
+	for _, bm := range []struct{ name, path string }{{"Plain", plainPath}, {"Gzip", gzipPath}} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store, err := NewCSVStore(bm.path)
+				if err != nil {
+					b.Fatalf("failed to create CSV store: %v", err)
+				}
+				store.Close()
+			}
+		})
+	}
+}
+
+// TestNewEmbeddedCSVStore tests that the bundled dataset is loaded without any file on disk
+func TestNewEmbeddedCSVStore(t *testing.T) {
+	store, err := NewEmbeddedCSVStore()
+	if err != nil {
+		t.Fatalf("failed to create embedded CSV store: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		ip      string
+		city    string
+		country string
+	}{
+		{ip: "8.8.8.8", city: "Mountain View", country: "United States"},
+		{ip: "1.1.1.1", city: "Sydney", country: "Australia"},
+	}
+	for _, tt := range tests {
+		location, err := store.FindByIP(context.Background(), tt.ip)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", tt.ip, err)
+			continue
+		}
+		if location.City != tt.city || location.Country != tt.country {
+			t.Errorf("%s: expected %s, %s, got %+v", tt.ip, tt.city, tt.country, location)
+		}
+	}
+
+	if store.Stats().DataVersion != dataVersion(string(EmbeddedCSV)) {
+		t.Error("expected the embedded store's version to be the hash of the embedded data")
+	}
+}
+
+// TestNewCSVStoreFromReader tests loading from a reader, with the version derived from the content
+func TestNewCSVStoreFromReader(t *testing.T) {
+	content := "ip,city,country\n8.8.8.8,Mountain View,United States\n"
+
+	store, err := NewCSVStoreFromReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" {
+		t.Errorf("expected Mountain View, got %s", location.City)
+	}
+
+	same, _ := NewCSVStoreFromReader(strings.NewReader(content))
+	changed, _ := NewCSVStoreFromReader(strings.NewReader(content + "1.1.1.1,Sydney,Australia\n"))
+	if store.Stats().DataVersion != same.Stats().DataVersion {
+		t.Error("expected the same version for the same content")
+	}
+	if store.Stats().DataVersion == changed.Stats().DataVersion {
+		t.Error("expected a new version for different content")
+	}
+
+	if _, err := NewCSVStoreFromReader(strings.NewReader("")); err == nil {
+		t.Error("expected error for empty input, got nil")
+	}
+}
+
+// TestCSVStore_Delimiter tests that TSV and pipe-delimited files load with WithDelimiter, and keep their delimiter on Reload
+func TestCSVStore_Delimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		delimiter rune
+		content   string
+	}{
+		{"tsv", '\t', "ip\tcity\tcountry\n8.8.8.8\tMountain View, CA\tUnited States\n"},
+		{"pipe", '|', "ip|city|country\n8.8.8.8|Mountain View, CA|United States\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csvPath := filepath.Join(t.TempDir(), "ips.csv")
+			if err := os.WriteFile(csvPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to create test file: %v", err)
+			}
+
+			store, err := NewCSVStore(csvPath, WithDelimiter(tt.delimiter))
+			if err != nil {
+				t.Fatalf("failed to create CSV store: %v", err)
+			}
+			defer store.Close()
+
+			if _, err := store.Reload(); err != nil {
+				t.Fatalf("failed to reload: %v", err)
+			}
+			location, err := store.FindByIP(context.Background(), "8.8.8.8")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if location.City != "Mountain View, CA" || location.Country != "United States" {
+				t.Errorf("expected Mountain View, CA in United States, got %+v", location)
+			}
+		})
+	}
+}
+
+// TestCSVStore_InvalidDelimiter tests that delimiters csv.Reader can't use are rejected when the store is created
+func TestCSVStore_InvalidDelimiter(t *testing.T) {
+	for _, delimiter := range []rune{'"', '\n', '\r', utf8.RuneError} {
+		if _, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n"), WithDelimiter(delimiter)); err == nil || !strings.Contains(err.Error(), "invalid CSV delimiter") {
+			t.Errorf("delimiter %q: expected an invalid CSV delimiter error, got %v", delimiter, err)
+		}
+	}
+}
+
+// TestParseCSVDelimiter tests that a single character or \t is a delimiter, empty is a comma, and anything longer is an error
+func TestParseCSVDelimiter(t *testing.T) {
+	for s, expected := range map[string]rune{"": ',', ",": ',', "|": '|', ";": ';', "\t": '\t', `\t`: '\t', "¦": '¦'} {
+		if got, err := ParseCSVDelimiter(s); err != nil || got != expected {
+			t.Errorf("%q: expected %q, got %q, %v", s, expected, got, err)
+		}
+	}
+	for _, s := range []string{"||", "::", `\n`} {
+		if _, err := ParseCSVDelimiter(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+// TestCSVStore_LazyQuotes tests that an unescaped quote in a quoted field fails to parse unless WithLazyQuotes is set
+func TestCSVStore_LazyQuotes(t *testing.T) {
+	content := "ip,city,country\n8.8.8.8,\"Mountain \"View\",United States\n"
+
+	if _, err := NewCSVStoreFromReader(strings.NewReader(content)); err == nil {
+		t.Error("expected an error for an unescaped quote without lazy quotes")
+	}
+
+	store, err := NewCSVStoreFromReader(strings.NewReader(content), WithLazyQuotes(true))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != `Mountain "View` {
+		t.Errorf(`expected Mountain "View, got %s`, location.City)
+	}
+}
+
+// TestCSVStore_ListCountries tests that countries are de-duplicated and sorted
+func TestCSVStore_ListCountries(t *testing.T) {
+	content := `ip,city,country
+8.8.8.8,Mountain View,United States
+8.8.4.4,Mountain View,United States
+1.1.1.1,Sydney,Australia
+1.0.0.1,Sydney,Australia
+2.22.233.255,London,United Kingdom`
+
+	store, err := NewCSVStoreFromReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"Australia", "United Kingdom", "United States"}
+	if !slices.Equal(countries, expected) {
+		t.Errorf("expected %v, got %v", expected, countries)
+	}
+}
+
+// searchTestContent is the data Search tests run against
+const searchTestContent = `ip,city,country
+8.8.8.8,Mountain View,United States
+8.8.4.4,Mountain View,United States
+4.2.2.2,New York,United States
+9.9.9.9,Newark,United States
+1.1.1.1,Sydney,Australia
+2.22.233.255,London,United Kingdom
+5.5.5.5,Newcastle,United Kingdom
+100.1.1.1,100% Town,Utopia`
+
+// searchIPs returns the IPs of locations, in order
+func searchIPs(locations []*models.IPLocation) []string {
+	ips := make([]string, len(locations))
+	for i, location := range locations {
+		ips[i] = location.IP
+	}
+	return ips
+}
+
+// TestCSVStore_Search tests prefix matching on each field, case-insensitivity and pagination
+func TestCSVStore_Search(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader(searchTestContent))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    StoreQuery
+		expected []string
+	}{
+		{"country only", StoreQuery{Country: "United States"}, []string{"4.2.2.2", "8.8.4.4", "8.8.8.8", "9.9.9.9"}},
+		{"country prefix", StoreQuery{Country: "United"}, []string{"2.22.233.255", "4.2.2.2", "5.5.5.5", "8.8.4.4", "8.8.8.8", "9.9.9.9"}},
+		{"city only", StoreQuery{City: "New"}, []string{"4.2.2.2", "5.5.5.5", "9.9.9.9"}},
+		{"both fields", StoreQuery{Country: "United States", City: "New"}, []string{"4.2.2.2", "9.9.9.9"}},
+		{"case-insensitive", StoreQuery{Country: "united KINGDOM", City: "lon"}, []string{"2.22.233.255"}},
+		{"first page", StoreQuery{Country: "United", Limit: 2}, []string{"2.22.233.255", "4.2.2.2"}},
+		{"second page", StoreQuery{Country: "United", Limit: 2, Offset: 2}, []string{"5.5.5.5", "8.8.4.4"}},
+		{"offset past the end", StoreQuery{Country: "United", Limit: 2, Offset: 10}, []string{}},
+		{"no match", StoreQuery{Country: "France"}, []string{}},
+		{"wildcard is literal", StoreQuery{City: "%"}, []string{}},
+		{"empty query", StoreQuery{Limit: 3}, []string{"1.1.1.1", "100.1.1.1", "2.22.233.255"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations, err := store.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := searchIPs(locations); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestCSVStore_HealthCheck tests that the check passes with data and fails for an empty store
+func TestCSVStore_HealthCheck(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n8.8.8.8,Mountain View,United States\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.HealthCheck(context.Background()); err != nil {
+		t.Errorf("expected a healthy store, got %v", err)
+	}
+
+	empty := &CSVStore{data: map[string]*models.IPLocation{}}
+	if err := empty.HealthCheck(context.Background()); err == nil {
+		t.Error("expected an empty store to be unhealthy, got nil")
+	}
+}
+
+// TestCSVStore_BulkDelete tests that only the IPs the store holds are counted, and the data version changes
+func TestCSVStore_BulkDelete(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	version := store.Stats().DataVersion
+
+	deleted, err := store.BulkDelete(context.Background(), []string{"8.8.8.8", "9.9.9.9"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d", deleted)
+	}
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 8.8.8.8 to be deleted, got %v", err)
+	}
+	if _, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected 1.1.1.1 to be kept, got %v", err)
+	}
+	if store.Stats().DataVersion == version {
+		t.Error("expected the data version to change after a delete")
+	}
+}
+
+// TestCSVStore_BulkDelete_Empty tests that deleting nothing leaves the data and its version alone
+func TestCSVStore_BulkDelete_Empty(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n8.8.8.8,Mountain View,United States\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	version := store.Stats().DataVersion
+
+	deleted, err := store.BulkDelete(context.Background(), nil)
+	if err != nil || deleted != 0 {
+		t.Errorf("expected 0 deleted and no error, got %d, %v", deleted, err)
+	}
+	if store.Stats().DataVersion != version {
+		t.Error("expected the data version to be unchanged")
+	}
+}
+
+// TestCSVStore_BulkDelete_ConcurrentLookups tests that lookups are safe while a delete is running (run with -race)
+func TestCSVStore_BulkDelete_ConcurrentLookups(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("ip,city,country\n")
+	var ips []string
+	for i := range 1000 {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		ips = append(ips, ip)
+		content.WriteString(ip + ",City,Country\n")
+	}
+	store, err := NewCSVStoreFromReader(strings.NewReader(content.String()))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, ip := range ips {
+				if _, err := store.FindByIP(context.Background(), ip); err != nil && !errors.Is(err, pkerr.ErrNotFound) {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		}()
+	}
+
+	deleted, err := store.BulkDelete(context.Background(), ips)
+	wg.Wait()
+
+	if err != nil || deleted != len(ips) {
+		t.Errorf("expected %d deleted and no error, got %d, %v", len(ips), deleted, err)
+	}
+}
+
+// FuzzCSVParse feeds arbitrary bytes to NewCSVStore
+// Malformed input must return an error, never panic, and every loaded record must be found again
+func FuzzCSVParse(f *testing.F) {
+	f.Add([]byte("ip,city,country\n8.8.8.8,Mountain View,United States\n"))
+	f.Add([]byte("ip,city,country\n\"1.1.1.1\",\"Sydney, NSW\",Australia\n"))
+	f.Add([]byte("ip,city\n8.8.8.8\n\"unterminated"))
+	f.Add([]byte(""))
+
+	csvPath := filepath.Join(f.TempDir(), "fuzz.csv")
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		if err := os.WriteFile(csvPath, content, 0644); err != nil {
+			t.Fatalf("failed to write CSV: %v", err)
+		}
+
+		store, err := NewCSVStore(csvPath)
+		if err != nil {
+			return
+		}
+		defer store.Close()
+
+		for ip, expected := range store.data {
+			location, err := store.FindByIP(context.Background(), ip)
+			if err != nil || location.City != expected.City || location.Country != expected.Country {
+				t.Fatalf("FindByIP(%q) = %+v, %v; expected %+v", ip, location, err, expected)
+			}
+		}
+	})
+}
+
+// TestCSVStore_BulkLoad tests that locations are added or replaced in memory and the data version changes
+func TestCSVStore_BulkLoad(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n8.8.8.8,Mountain View,United States\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	version := store.Stats().DataVersion
+
+	err = store.BulkLoad([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "Ashburn", Country: "United States"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if location, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil || location.City != "Ashburn" {
+		t.Errorf("expected 8.8.8.8 to be replaced, got %+v, %v", location, err)
+	}
+	if location, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil || location.City != "Sydney" {
+		t.Errorf("expected 1.1.1.1 to be added, got %+v, %v", location, err)
+	}
+	if store.Stats().DataVersion == version {
+		t.Error("expected the data version to change after a load")
+	}
+}
+
+// TestCSVStore_CountryCodes tests the optional country_code,continent_code columns of both formats
+func TestCSVStore_CountryCodes(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader(`ip,city,country,country_code,continent_code
+8.8.8.8,Mountain View,United States,US,NA
+1.1.1.1,Sydney,Australia,,
+1.2.3.0/24,Berlin,Germany,DE,EU`))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil || location.CountryCode != "US" || location.ContinentCode != "NA" {
+		t.Errorf("expected US/NA, got %+v, %v", location, err)
+	}
+	location, err = store.FindByIP(context.Background(), "1.1.1.1")
+	if err != nil || location.Country != "Australia" || location.CountryCode != "" {
+		t.Errorf("expected Australia with empty codes, got %+v, %v", location, err)
+	}
+	location, err = store.FindByIP(context.Background(), "1.2.3.4")
+	if err != nil || location.CountryCode != "DE" || location.ContinentCode != "EU" {
+		t.Errorf("expected the CIDR row's DE/EU, got %+v, %v", location, err)
+	}
+
+	ranges := newRangeCSVStore(t, `ip_start,ip_end,city,country,country_code,continent_code
+8.8.8.0,8.8.8.255,Mountain View,United States,US,NA`)
+	location, err = ranges.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil || location.CountryCode != "US" || location.ContinentCode != "NA" {
+		t.Errorf("expected the range's US/NA, got %+v, %v", location, err)
+	}
+}
+
+// TestCSVStore_CountryCodes_OldFormat tests that files without the code columns load with empty codes
+func TestCSVStore_CountryCodes_OldFormat(t *testing.T) {
+	store, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n1.1.1.1,Sydney,Australia\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer store.Close()
+
+	location, err := store.FindByIP(context.Background(), "1.1.1.1")
+	if err != nil || location.Country != "Australia" || location.CountryCode != "" {
+		t.Errorf("expected Australia without codes, got %+v, %v", location, err)
+	}
+}
@@ -0,0 +1,7 @@
+package store
+
+import "github.com/evyataryagoni/ip2country/data"
+
+// EmbeddedCSV is the CSV dataset compiled into the binary (data/ip2country.csv)
+// The //go:embed directive lives in the data package, since embed can't reach outside a package directory
+var EmbeddedCSV = data.CSV
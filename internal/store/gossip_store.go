@@ -0,0 +1,365 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/hashicorp/memberlist"
+)
+
+// gossipLeaveTimeout bounds how long Close waits to tell peers this node is leaving
+const gossipLeaveTimeout = time.Second
+
+// GossipStore keeps the stores of many nodes in sync without a central Redis
+// Meant for edge deployments: every node runs its own in-memory store, and writes are
+// propagated peer to peer over a hashicorp/memberlist gossip cluster:
+//   - Set and BulkLoad write to the local store, then send the records to every live peer
+//   - Records received from peers are written to the local store
+//   - A node joining the cluster pulls the records the others have already exchanged
+//
+// Conflicts are resolved by last-write-wins on each record's updatedAt timestamp,
+// so nodes converge whatever order the writes arrive in
+// Reads never leave the node. The inner store must implement BulkLoader (e.g. CSVStore)
+type GossipStore struct {
+	inner  Store
+	loader BulkLoader
+	list   *memberlist.Memberlist
+
+	mu      sync.Mutex
+	entries map[string]gossipEntry // IP -> latest write seen, local or remote
+
+	logger *applogger.Logger // Optional, see SetLogger
+}
+
+// gossipEntry is one write exchanged between nodes
+type gossipEntry struct {
+	IP        string `json:"ip"`
+	City      string `json:"city"`
+	Country   string `json:"country"`
+	UpdatedAt int64  `json:"updated_at"` // Unix nanoseconds of the write on the node that made it
+}
+
+// newerThan reports whether e wins over other under last-write-wins
+// Equal timestamps are broken on the record's contents, so every node picks the same winner
+func (e gossipEntry) newerThan(other gossipEntry) bool {
+	if e.UpdatedAt != other.UpdatedAt {
+		return e.UpdatedAt > other.UpdatedAt
+	}
+	return e.City+"\x00"+e.Country > other.City+"\x00"+other.Country
+}
+
+// NewGossipStore wraps inner and joins the gossip cluster
+//
+// Parameters:
+//   - inner: the node's own store; must implement BulkLoader
+//   - bindAddr: host:port to listen on for gossip (TCP and UDP); port 0 picks a free port
+//   - peers: host:port of existing members to join; empty starts a new cluster
+//
+// Joining fails only if none of the peers can be reached
+func NewGossipStore(inner Store, bindAddr string, peers []string) (*GossipStore, error) {
+	loader, ok := inner.(BulkLoader)
+	if !ok {
+		return nil, fmt.Errorf("inner store does not support bulk loading")
+	}
+
+	host, portStr, err := net.SplitHostPort(bindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gossip bind address %q: %w", bindAddr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gossip bind port %q: %w", portStr, err)
+	}
+
+	name, err := gossipNodeName()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &GossipStore{
+		inner:   inner,
+		loader:  loader,
+		entries: make(map[string]gossipEntry),
+	}
+
+	conf := memberlist.DefaultLANConfig()
+	conf.Name = name
+	conf.BindAddr = host
+	conf.BindPort = port
+	conf.AdvertisePort = port
+	conf.Delegate = &gossipDelegate{store: s}
+	conf.LogOutput = io.Discard // memberlist logs every probe; failures that matter are logged by GossipStore
+
+	s.list, err = memberlist.Create(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start gossip on %s: %w", bindAddr, err)
+	}
+
+	if len(peers) > 0 {
+		if _, err := s.list.Join(peers); err != nil {
+			s.list.Shutdown()
+			return nil, fmt.Errorf("failed to join gossip peers: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// gossipNodeName returns a name unique across the cluster: the hostname plus a random suffix,
+// so several nodes can share a host (and the same node gets a new identity after a restart)
+func gossipNodeName() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "node"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate gossip node name: %w", err)
+	}
+	return hostname + "-" + hex.EncodeToString(suffix), nil
+}
+
+// SetLogger sets the logger receiving peer delivery failures
+func (s *GossipStore) SetLogger(log *applogger.Logger) {
+	s.logger = log
+}
+
+// Members returns the number of live nodes in the cluster, including this one
+func (s *GossipStore) Members() int {
+	return s.list.NumMembers()
+}
+
+// FindByIP looks up ip in the local store
+// Implements the Store interface method
+func (s *GossipStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return s.inner.FindByIP(ctx, ip)
+}
+
+// RangeFindByIP looks up ip in the local store's ranges
+// Implements the RangeFinder interface
+func (s *GossipStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return rangeFindByIP(ctx, s.inner, ip)
+}
+
+// Set writes a single location locally and sends it to every peer
+func (s *GossipStore) Set(ip, city, country string) error {
+	return s.BulkLoad([]*models.IPLocation{{IP: ip, City: city, Country: country}})
+}
+
+// BulkLoad writes locations locally and sends them to every peer
+// Implements the BulkLoader interface
+//
+// Peers that can't be reached are logged and skipped: the local write still succeeds,
+// and a peer that comes back catches up when it rejoins
+func (s *GossipStore) BulkLoad(locations []*models.IPLocation) error {
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+	batch := make([]gossipEntry, 0, len(locations))
+	for _, location := range locations {
+		updatedAt := now
+		// A local write always wins, even if a peer's clock is ahead of ours
+		if prev, ok := s.entries[location.IP]; ok && prev.UpdatedAt >= updatedAt {
+			updatedAt = prev.UpdatedAt + 1
+		}
+		batch = append(batch, gossipEntry{IP: location.IP, City: location.City, Country: location.Country, UpdatedAt: updatedAt})
+	}
+	accepted, err := s.applyLocked(batch)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.broadcast(accepted)
+	return nil
+}
+
+// apply writes the entries that win over what the node already has, returning them
+func (s *GossipStore) apply(entries []gossipEntry) ([]gossipEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyLocked(entries)
+}
+
+// applyLocked is apply with s.mu held
+// The lock covers the inner write too, so concurrent writes reach the store in timestamp order
+func (s *GossipStore) applyLocked(entries []gossipEntry) ([]gossipEntry, error) {
+	accepted := make([]gossipEntry, 0, len(entries))
+	locations := make([]*models.IPLocation, 0, len(entries))
+	for _, entry := range entries {
+		if prev, ok := s.entries[entry.IP]; ok && !entry.newerThan(prev) {
+			continue
+		}
+		accepted = append(accepted, entry)
+		locations = append(locations, &models.IPLocation{IP: entry.IP, City: entry.City, Country: entry.Country})
+	}
+	if len(accepted) == 0 {
+		return nil, nil
+	}
+
+	if err := s.loader.BulkLoad(locations); err != nil {
+		return nil, fmt.Errorf("failed to write to local store: %w", err)
+	}
+	for _, entry := range accepted {
+		s.entries[entry.IP] = entry
+	}
+	return accepted, nil
+}
+
+// broadcast sends entries to every other live member over TCP
+func (s *GossipStore) broadcast(entries []gossipEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	msg, err := json.Marshal(entries)
+	if err != nil {
+		s.logError(err, "Failed to encode gossip message")
+		return
+	}
+
+	local := s.list.LocalNode()
+	for _, node := range s.list.Members() {
+		if node.Name == local.Name {
+			continue
+		}
+		if err := s.list.SendReliable(node, msg); err != nil {
+			s.logError(err, "Failed to send gossip message to "+node.Address())
+		}
+	}
+}
+
+// receive applies a message sent by a peer
+func (s *GossipStore) receive(msg []byte) {
+	var entries []gossipEntry
+	if err := json.Unmarshal(msg, &entries); err != nil {
+		s.logError(err, "Failed to decode gossip message")
+		return
+	}
+	if _, err := s.apply(entries); err != nil {
+		s.logError(err, "Failed to apply gossip message")
+	}
+}
+
+// logError logs err if a logger was set
+func (s *GossipStore) logError(err error, msg string) {
+	if s.logger != nil {
+		s.logger.Warn().Err(err).Msg(msg)
+	}
+}
+
+// ListCountries lists the local store's countries
+// Implements the CountryLister interface; fails if the inner store doesn't implement it
+func (s *GossipStore) ListCountries(ctx context.Context) ([]string, error) {
+	lister, ok := s.inner.(CountryLister)
+	if !ok {
+		return nil, fmt.Errorf("inner store does not support listing countries")
+	}
+	return lister.ListCountries(ctx)
+}
+
+// Search searches the local store
+// Implements the Searcher interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *GossipStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.inner.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	}
+	return searcher.Search(ctx, query)
+}
+
+// Count counts the local store's records
+// Implements the Counter interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *GossipStore) Count(ctx context.Context) (int, error) {
+	counter, ok := s.inner.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("counting is %w", pkerr.ErrNotSupported)
+	}
+	return counter.Count(ctx)
+}
+
+// Reload reloads the local store
+// Implements the Reloader interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *GossipStore) Reload() (ReloadResult, error) {
+	reloader, ok := s.inner.(Reloader)
+	if !ok {
+		return ReloadResult{}, fmt.Errorf("reloading is %w", pkerr.ErrNotSupported)
+	}
+	return reloader.Reload()
+}
+
+// Stats reports the local store's stats
+// Implements the StatsProvider interface
+func (s *GossipStore) Stats() StoreStats {
+	if provider, ok := s.inner.(StatsProvider); ok {
+		return provider.Stats()
+	}
+	return StoreStats{}
+}
+
+// Close leaves the cluster, then closes the inner store
+func (s *GossipStore) Close() error {
+	leaveErr := s.list.Leave(gossipLeaveTimeout)
+	return errors.Join(leaveErr, s.list.Shutdown(), s.inner.Close())
+}
+
+// gossipDelegate receives memberlist callbacks for a GossipStore
+// Kept separate so the callbacks don't become part of GossipStore's API
+type gossipDelegate struct {
+	store *GossipStore
+}
+
+// NodeMeta implements memberlist.Delegate; nodes carry no metadata
+func (d *gossipDelegate) NodeMeta(limit int) []byte {
+	return nil
+}
+
+// NotifyMsg implements memberlist.Delegate, applying writes sent by peers
+func (d *gossipDelegate) NotifyMsg(msg []byte) {
+	d.store.receive(msg)
+}
+
+// GetBroadcasts implements memberlist.Delegate
+// Writes are sent directly by broadcast rather than piggybacked on gossip, so there is nothing to add
+func (d *gossipDelegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return nil
+}
+
+// LocalState implements memberlist.Delegate, sending every write this node knows of
+// Exchanged when a node joins and periodically after, so missed messages are eventually repaired
+func (d *gossipDelegate) LocalState(join bool) []byte {
+	d.store.mu.Lock()
+	entries := make([]gossipEntry, 0, len(d.store.entries))
+	for _, entry := range d.store.entries {
+		entries = append(entries, entry)
+	}
+	d.store.mu.Unlock()
+
+	state, err := json.Marshal(entries)
+	if err != nil {
+		d.store.logError(err, "Failed to encode gossip state")
+		return nil
+	}
+	return state
+}
+
+// MergeRemoteState implements memberlist.Delegate, applying a peer's writes under last-write-wins
+func (d *gossipDelegate) MergeRemoteState(buf []byte, join bool) {
+	if len(buf) == 0 {
+		return
+	}
+	d.store.receive(buf)
+}
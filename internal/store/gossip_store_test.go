@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// newGossipNode starts a gossip store over an empty CSV store on a free local port, joined to peers
+func newGossipNode(t *testing.T, peers ...string) *GossipStore {
+	t.Helper()
+
+	inner, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	s, err := NewGossipStore(inner, "127.0.0.1:0", peers)
+	if err != nil {
+		t.Fatalf("failed to create gossip store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// gossipAddr returns the host:port other nodes join s on
+func gossipAddr(s *GossipStore) string {
+	node := s.list.LocalNode()
+	return node.Addr.String() + ":" + strconv.Itoa(int(node.Port))
+}
+
+// waitForCity polls s until ip resolves to city, failing the test after timeout
+func waitForCity(t *testing.T, s *GossipStore, ip, city string, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		location, err := s.FindByIP(context.Background(), ip)
+		if err == nil && location.City == city {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to resolve to %s within %v, got %+v, %v", ip, city, timeout, location, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// gossipMessage encodes entries the way a peer sends them
+func gossipMessage(t *testing.T, entries ...gossipEntry) []byte {
+	t.Helper()
+	msg, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("failed to encode gossip message: %v", err)
+	}
+	return msg
+}
+
+// TestGossipStore_PropagatesSet tests that a write on one node reaches the other within 100ms
+func TestGossipStore_PropagatesSet(t *testing.T) {
+	a := newGossipNode(t)
+	b := newGossipNode(t, gossipAddr(a))
+
+	if a.Members() != 2 || b.Members() != 2 {
+		t.Fatalf("expected both nodes to see 2 members, got %d and %d", a.Members(), b.Members())
+	}
+
+	if err := a.Set("8.8.8.8", "Mountain View", "United States"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForCity(t, b, "8.8.8.8", "Mountain View", 100*time.Millisecond)
+
+	// And back the other way
+	if err := b.Set("8.8.8.8", "Ashburn", "United States"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForCity(t, a, "8.8.8.8", "Ashburn", 100*time.Millisecond)
+}
+
+// TestGossipStore_JoinPullsExistingWrites tests that a node joining late receives earlier writes
+func TestGossipStore_JoinPullsExistingWrites(t *testing.T) {
+	a := newGossipNode(t)
+	if err := a.Set("1.1.1.1", "Sydney", "Australia"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := newGossipNode(t, gossipAddr(a))
+	waitForCity(t, b, "1.1.1.1", "Sydney", 100*time.Millisecond)
+}
+
+// TestGossipStore_LastWriteWins tests that a write older than the one already applied is ignored
+func TestGossipStore_LastWriteWins(t *testing.T) {
+	s := newGossipNode(t)
+
+	s.receive(gossipMessage(t, gossipEntry{IP: "8.8.8.8", City: "Newer", Country: "United States", UpdatedAt: 200}))
+	s.receive(gossipMessage(t, gossipEntry{IP: "8.8.8.8", City: "Older", Country: "United States", UpdatedAt: 100}))
+	if location, _ := s.FindByIP(context.Background(), "8.8.8.8"); location == nil || location.City != "Newer" {
+		t.Errorf("expected the newer write to win, got %+v", location)
+	}
+
+	s.receive(gossipMessage(t, gossipEntry{IP: "8.8.8.8", City: "Newest", Country: "United States", UpdatedAt: 300}))
+	if location, _ := s.FindByIP(context.Background(), "8.8.8.8"); location == nil || location.City != "Newest" {
+		t.Errorf("expected a later write to replace it, got %+v", location)
+	}
+
+	// A local write wins over remote writes, even ones stamped in the future
+	future := time.Now().Add(time.Hour).UnixNano()
+	s.receive(gossipMessage(t, gossipEntry{IP: "1.1.1.1", City: "Remote", Country: "Australia", UpdatedAt: future}))
+	if err := s.Set("1.1.1.1", "Local", "Australia"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location, _ := s.FindByIP(context.Background(), "1.1.1.1"); location == nil || location.City != "Local" {
+		t.Errorf("expected the local write to win, got %+v", location)
+	}
+}
+
+// TestGossipStore_TieBreak tests that writes with the same timestamp resolve the same way in either order
+func TestGossipStore_TieBreak(t *testing.T) {
+	first := gossipEntry{IP: "8.8.8.8", City: "Ashburn", Country: "United States", UpdatedAt: 100}
+	second := gossipEntry{IP: "8.8.8.8", City: "Mountain View", Country: "United States", UpdatedAt: 100}
+
+	a := newGossipNode(t)
+	a.receive(gossipMessage(t, first))
+	a.receive(gossipMessage(t, second))
+
+	b := newGossipNode(t)
+	b.receive(gossipMessage(t, second))
+	b.receive(gossipMessage(t, first))
+
+	locationA, _ := a.FindByIP(context.Background(), "8.8.8.8")
+	locationB, _ := b.FindByIP(context.Background(), "8.8.8.8")
+	if locationA == nil || locationB == nil || locationA.City != locationB.City {
+		t.Errorf("expected both nodes to converge, got %+v and %+v", locationA, locationB)
+	}
+}
+
+// TestGossipStore_PeerFailure tests that the surviving node keeps serving and accepting writes
+func TestGossipStore_PeerFailure(t *testing.T) {
+	inner, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	a, err := NewGossipStore(inner, "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatalf("failed to create gossip store: %v", err)
+	}
+	b := newGossipNode(t, gossipAddr(a))
+
+	// Simulate a crash: no graceful leave, so b still lists a as a member
+	a.list.Shutdown()
+	inner.Close()
+
+	if err := b.Set("8.8.8.8", "Mountain View", "United States"); err != nil {
+		t.Fatalf("expected the write to succeed locally, got %v", err)
+	}
+	if location, err := b.FindByIP(context.Background(), "8.8.8.8"); err != nil || location.City != "Mountain View" {
+		t.Errorf("expected the surviving node to serve the write, got %+v, %v", location, err)
+	}
+}
+
+// TestGossipStore_RequiresBulkLoader tests that an inner store that can't be written to is rejected
+func TestGossipStore_RequiresBulkLoader(t *testing.T) {
+	inner := struct{ Store }{NewMockStore()} // Hides MockStore's BulkLoad
+
+	_, err := NewGossipStore(inner, "127.0.0.1:0", nil)
+	if err == nil || !strings.Contains(err.Error(), "bulk loading") {
+		t.Errorf("expected a bulk loading error, got %v", err)
+	}
+}
+
+// TestGossipStore_InvalidBindAddr tests that a bind address without a port is rejected
+func TestGossipStore_InvalidBindAddr(t *testing.T) {
+	_, err := NewGossipStore(NewMockStore(), "127.0.0.1", nil)
+	if err == nil {
+		t.Error("expected an error for a bind address without a port")
+	}
+}
+
+// TestGossipStore_BulkLoad tests that a batch is applied locally and propagated
+func TestGossipStore_BulkLoad(t *testing.T) {
+	a := newGossipNode(t)
+	b := newGossipNode(t, gossipAddr(a))
+
+	err := a.BulkLoad([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForCity(t, b, "8.8.8.8", "Mountain View", 100*time.Millisecond)
+	waitForCity(t, b, "1.1.1.1", "Sydney", 100*time.Millisecond)
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/fsnotify/fsnotify"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// MaxMindStore implements Store interface using MaxMind GeoLite2/GeoIP2 .mmdb files
+// The City database is required; the ASN database is optional and adds ISP data
+type MaxMindStore struct {
+	cityPath string
+	asnPath  string
+
+	// mu guards the readers: lookups hold it for reading, so Reload can't close a reader in use
+	mu     sync.RWMutex
+	cityDB *geoip2.Reader
+	asnDB  *geoip2.Reader // nil when no ASN database is configured
+
+	// watcher reports changes to the database files once Watch is called; closed by Close
+	watcher *fsnotify.Watcher
+}
+
+// maxMindReloadDelay is how long Watch waits after the last change to a database file before reloading
+const maxMindReloadDelay = 100 * time.Millisecond
+
+// NewMaxMindStore opens the MaxMind databases
+//
+// Parameters:
+//   - cityPath: path to the City database (e.g., GeoLite2-City.mmdb)
+//   - asnPath: path to the ASN database (e.g., GeoLite2-ASN.mmdb), or "" to skip ISP data
+//
+// Returns:
+//   - *MaxMindStore: pointer to the created store
+//   - error: any error that occurred while opening the databases
+func NewMaxMindStore(cityPath, asnPath string) (*MaxMindStore, error) {
+	cityDB, asnDB, err := openMaxMind(cityPath, asnPath)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindStore{cityPath: cityPath, asnPath: asnPath, cityDB: cityDB, asnDB: asnDB}, nil
+}
+
+// openMaxMind opens the databases, checking each holds the records it's used for
+// (a GeoLite2-Country file passed as the City database would otherwise find no cities)
+func openMaxMind(cityPath, asnPath string) (cityDB, asnDB *geoip2.Reader, err error) {
+	cityDB, err = openMaxMindDB(cityPath, "City")
+	if err != nil {
+		return nil, nil, err
+	}
+	if asnPath == "" {
+		return cityDB, nil, nil
+	}
+
+	asnDB, err = openMaxMindDB(asnPath, "ASN")
+	if err != nil {
+		cityDB.Close()
+		return nil, nil, err
+	}
+	return cityDB, asnDB, nil
+}
+
+// openMaxMindDB opens the database at path, which must be of a kind (e.g. GeoLite2-City or GeoIP2-City for "City")
+func openMaxMindDB(path, kind string) (*geoip2.Reader, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MaxMind %s database: %w", kind, err)
+	}
+	if databaseType := db.Metadata().DatabaseType; !strings.Contains(databaseType, kind) {
+		db.Close()
+		return nil, fmt.Errorf("failed to open MaxMind %s database: %s is a %s database", kind, path, databaseType)
+	}
+	return db, nil
+}
+
+// HasASNData reports whether an ASN database is loaded (ISP and ASN fields are populated)
+func (s *MaxMindStore) HasASNData() bool {
+	return s.asnPath != ""
+}
+
+// FindByIP looks up an IP address in the MaxMind databases
+// Implements the Store interface method
+func (s *MaxMindStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, pkerr.ErrInvalidIP
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, err := s.cityDB.City(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("MaxMind City lookup failed: %w", err)
+	}
+
+	// The reader returns an empty record (not an error) for addresses it has no data for
+	city := record.City.Names["en"]
+	country := record.Country.Names["en"]
+	if city == "" && country == "" {
+		return nil, pkerr.ErrNotFound
+	}
+
+	location := &models.IPLocation{
+		IP:            ip,
+		City:          city,
+		Country:       country,
+		CountryCode:   record.Country.IsoCode,
+		ContinentCode: record.Continent.Code,
+		Latitude:      record.Location.Latitude,
+		Longitude:     record.Location.Longitude,
+	}
+
+	if s.asnDB != nil {
+		asn, err := s.asnDB.ASN(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("MaxMind ASN lookup failed: %w", err)
+		}
+		location.ISP = asn.AutonomousSystemOrganization
+		location.ASN = int(asn.AutonomousSystemNumber)
+	}
+
+	return location, nil
+}
+
+// RangeFindByIP looks up the network containing ip: every MaxMind record is a network, so this is FindByIP
+// Implements the RangeFinder interface
+func (s *MaxMindStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return s.FindByIP(ctx, ip)
+}
+
+// Stats derives the DataVersion from the build time of the loaded databases
+// Implements the StatsProvider interface
+func (s *MaxMindStore) Stats() StoreStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	source := strconv.FormatUint(uint64(s.cityDB.Metadata().BuildEpoch), 10)
+	if s.asnDB != nil {
+		source += "/" + strconv.FormatUint(uint64(s.asnDB.Metadata().BuildEpoch), 10)
+	}
+	return StoreStats{DataVersion: dataVersion(source)}
+}
+
+// Reload reopens the database files and swaps them in, e.g. after a geoipupdate run
+// MaxMind databases don't record how many networks they hold, so the result is always zero
+// On error the previous databases keep being served
+// Implements the Reloader interface
+func (s *MaxMindStore) Reload() (ReloadResult, error) {
+	cityDB, asnDB, err := openMaxMind(s.cityPath, s.asnPath)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	// Taking the write lock waits for lookups on the previous readers to finish before they're closed
+	s.mu.Lock()
+	previousCity, previousASN := s.cityDB, s.asnDB
+	s.cityDB, s.asnDB = cityDB, asnDB
+	s.mu.Unlock()
+
+	previousCity.Close()
+	if previousASN != nil {
+		previousASN.Close()
+	}
+	return ReloadResult{}, nil
+}
+
+// Watch reloads the databases whenever either file changes on disk, until Close
+// Failed reloads are logged to log (if not nil) and keep the previous databases
+//
+// As with CSVStore.Watch, the directories are watched rather than the files, so a file can be replaced
+// atomically (geoipupdate writes a temporary file and renames it over the database)
+func (s *MaxMindStore) Watch(log *applogger.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch MaxMind databases: %w", err)
+	}
+
+	names := map[string]bool{filepath.Clean(s.cityPath): true}
+	if s.asnPath != "" {
+		names[filepath.Clean(s.asnPath)] = true
+	}
+	for name := range names {
+		if err := watcher.Add(filepath.Dir(name)); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch MaxMind databases: %w", err)
+		}
+	}
+	s.watcher = watcher
+
+	go s.watch(watcher, names, log)
+	return nil
+}
+
+// watch reloads the databases maxMindReloadDelay after the last event for one of names, until the watcher is closed
+func (s *MaxMindStore) watch(watcher *fsnotify.Watcher, names map[string]bool, log *applogger.Logger) {
+	reload := time.NewTimer(maxMindReloadDelay)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if names[filepath.Clean(event.Name)] && event.Has(fsnotify.Write|fsnotify.Create) {
+				reload.Reset(maxMindReloadDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if log != nil {
+				log.Warn().Err(err).Str("path", s.cityPath).Msg("MaxMind database watcher error")
+			}
+
+		case <-reload.C:
+			if _, err := s.Reload(); err != nil {
+				if log != nil {
+					log.Error().Err(err).Str("path", s.cityPath).Msg("Failed to reload MaxMind databases, keeping the previous ones")
+				}
+				continue
+			}
+			if log != nil {
+				log.Info().Str("path", s.cityPath).Str("data_version", s.Stats().DataVersion).Msg("MaxMind databases reloaded")
+			}
+		}
+	}
+}
+
+// Close closes both database readers and the file watcher
+func (s *MaxMindStore) Close() error {
+	var errs []error
+	if s.watcher != nil {
+		errs = append(errs, s.watcher.Close())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cityDB != nil {
+		errs = append(errs, s.cityDB.Close())
+	}
+	if s.asnDB != nil {
+		errs = append(errs, s.asnDB.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// TestMaxMindStore_CityAndASN tests that ISP and ASN are populated when both databases are loaded
+func TestMaxMindStore_CityAndASN(t *testing.T) {
+	store, err := NewMaxMindStore(writeTestCityDB(t), writeTestASNDB(t))
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+
+	if !store.HasASNData() {
+		t.Error("expected HasASNData to be true")
+	}
+
+	loc, err := store.FindByIP(context.Background(), "81.2.69.160")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc.City != "London" || loc.Country != "United Kingdom" {
+		t.Errorf("expected London, United Kingdom, got %s, %s", loc.City, loc.Country)
+	}
+	if loc.ISP != "Andrews & Arnold Ltd" {
+		t.Errorf("expected ISP 'Andrews & Arnold Ltd', got '%s'", loc.ISP)
+	}
+	if loc.ASN != 20712 {
+		t.Errorf("expected ASN 20712, got %d", loc.ASN)
+	}
+	if loc.Latitude != 51.5142 || loc.Longitude != -0.0931 {
+		t.Errorf("expected coordinates 51.5142, -0.0931, got %v, %v", loc.Latitude, loc.Longitude)
+	}
+
+	loc, err = store.FindByIP(context.Background(), "216.160.83.58")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc.City != "Milton" || loc.ISP != "Qwest Communications Company, LLC" || loc.ASN != 209 {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+}
+
+// TestMaxMindStore_CityOnly tests graceful degradation when no ASN database is configured
+func TestMaxMindStore_CityOnly(t *testing.T) {
+	store, err := NewMaxMindStore(writeTestCityDB(t), "")
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+
+	if store.HasASNData() {
+		t.Error("expected HasASNData to be false")
+	}
+
+	loc, err := store.FindByIP(context.Background(), "81.2.69.142")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc.City != "London" || loc.Country != "United Kingdom" {
+		t.Errorf("expected London, United Kingdom, got %s, %s", loc.City, loc.Country)
+	}
+	if loc.ISP != "" || loc.ASN != 0 {
+		t.Errorf("expected empty ISP and ASN, got '%s', %d", loc.ISP, loc.ASN)
+	}
+}
+
+// TestMaxMindStore_IPWithoutASNRecord tests an IP covered by the City database but not the ASN database
+func TestMaxMindStore_IPWithoutASNRecord(t *testing.T) {
+	store, err := NewMaxMindStore(writeTestCityDB(t), writeTestASNDB(t))
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+
+	loc, err := store.FindByIP(context.Background(), "81.2.69.142")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc.City != "London" || loc.ISP != "" || loc.ASN != 0 {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+}
+
+// TestMaxMindStore_NotFound tests lookup of an IP missing from the City database
+func TestMaxMindStore_NotFound(t *testing.T) {
+	store, err := NewMaxMindStore(writeTestCityDB(t), writeTestASNDB(t))
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+
+	// 1.128.0.1 has ASN data but no City record
+	if _, err := store.FindByIP(context.Background(), "1.128.0.1"); err == nil {
+		t.Error("expected error for IP not in City database, got nil")
+	}
+	if _, err := store.FindByIP(context.Background(), "not-an-ip"); err == nil {
+		t.Error("expected error for invalid IP, got nil")
+	}
+}
+
+// TestMaxMindStore_RangeFindByIP tests that RangeFindByIP finds the network containing an IP, like FindByIP
+func TestMaxMindStore_RangeFindByIP(t *testing.T) {
+	store, err := NewMaxMindStore(writeTestCityDB(t), "")
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+
+	loc, err := store.RangeFindByIP(context.Background(), "81.2.69.142")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc.City != "London" || loc.IP != "81.2.69.142" {
+		t.Errorf("expected London for 81.2.69.142, got %+v", loc)
+	}
+	if _, err := store.RangeFindByIP(context.Background(), "1.128.0.1"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestMaxMindStore_OpenErrors tests handling of missing and mismatched database files
+func TestMaxMindStore_OpenErrors(t *testing.T) {
+	if _, err := NewMaxMindStore("/nonexistent/GeoLite2-City.mmdb", ""); err == nil {
+		t.Error("expected error for nonexistent City database, got nil")
+	}
+	if _, err := NewMaxMindStore(writeTestCityDB(t), "/nonexistent/GeoLite2-ASN.mmdb"); err == nil {
+		t.Error("expected error for nonexistent ASN database, got nil")
+	}
+
+	// An ASN database can't stand in for the City database, nor the other way round
+	if _, err := NewMaxMindStore(writeTestASNDB(t), ""); err == nil || !strings.Contains(err.Error(), "GeoLite2-ASN") {
+		t.Errorf("expected a database type error for an ASN database as City, got %v", err)
+	}
+	if _, err := NewMaxMindStore(writeTestCityDB(t), writeTestCityDB(t)); err == nil {
+		t.Error("expected a database type error for a City database as ASN, got nil")
+	}
+}
+
+// TestMaxMindStore_Reload tests that Reload swaps in the current City database, and keeps the previous one on error
+func TestMaxMindStore_Reload(t *testing.T) {
+	cityPath := writeTestCityDB(t)
+	store, err := NewMaxMindStore(cityPath, "")
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+
+	replaceTestMMDB(t, cityPath, writeTestMMDB(t, "GeoLite2-City", map[string]map[string]any{
+		"1.1.1.0/24": mmdbTestCity("Sydney", "Australia", "AU", -33.8688, 151.2093),
+	}))
+	if _, err := store.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil || loc.City != "Sydney" {
+		t.Errorf("expected Sydney after Reload, got %+v (%v)", loc, err)
+	}
+	if _, err := store.FindByIP(context.Background(), "81.2.69.160"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected the previous database to be gone after Reload, got %v", err)
+	}
+
+	// A file of the wrong type keeps the previous database
+	replaceTestMMDB(t, cityPath, writeTestASNDB(t))
+	if _, err := store.Reload(); err == nil {
+		t.Error("expected error reloading an ASN database as City, got nil")
+	}
+	if _, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected the previous database to be kept, got %v", err)
+	}
+}
+
+// TestMaxMindStore_Watch tests that a watched City database is reloaded after it's replaced
+func TestMaxMindStore_Watch(t *testing.T) {
+	cityPath := writeTestCityDB(t)
+	store, err := NewMaxMindStore(cityPath, "")
+	if err != nil {
+		t.Fatalf("failed to create MaxMind store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Watch(nil); err != nil {
+		t.Fatalf("failed to watch MaxMind databases: %v", err)
+	}
+
+	replaceTestMMDB(t, cityPath, writeTestMMDB(t, "GeoLite2-City", map[string]map[string]any{
+		"1.1.1.0/24": mmdbTestCity("Sydney", "Australia", "AU", -33.8688, 151.2093),
+	}))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		location, err := store.FindByIP(context.Background(), "1.1.1.1")
+		if err == nil {
+			if location.City != "Sydney" {
+				t.Errorf("expected Sydney, got %s", location.City)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the replaced database to be reloaded within 2s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// replaceTestMMDB atomically replaces the database at path with the one at newPath, as geoipupdate does
+func replaceTestMMDB(t *testing.T, path, newPath string) {
+	t.Helper()
+	tmpPath := path + ".tmp"
+	data, err := os.ReadFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		t.Fatalf("failed to replace %s: %v", path, err)
+	}
+}
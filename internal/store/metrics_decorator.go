@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// MetricsStore records datastore_queries_total and datastore_query_duration_seconds for the store it wraps
+// Any store can be instrumented this way without the service layer knowing about metrics
+//
+// FindByIP is counted with status "success", "not_found" or "error"
+// Optional interfaces are passed through to the inner store
+type MetricsStore struct {
+	inner   Store
+	metrics *metrics.Metrics
+	name    string // "datastore" label value, e.g. "redis"
+}
+
+// NewMetricsStore wraps inner, labelling its metrics with storeName
+func NewMetricsStore(inner Store, m *metrics.Metrics, storeName string) *MetricsStore {
+	return &MetricsStore{inner: inner, metrics: m, name: storeName}
+}
+
+// Unwrap returns the instrumented store
+func (s *MetricsStore) Unwrap() Store {
+	return s.inner
+}
+
+// FindByIP looks up ip in the inner store, counting and timing the query
+// Implements the Store interface method
+func (s *MetricsStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	start := time.Now()
+	location, err := s.inner.FindByIP(ctx, ip)
+	s.observeFind(start, "find_by_ip", err)
+
+	return location, err
+}
+
+// FindSpan looks up ip in the inner store's ranges, recorded like FindByIP
+// Implements the SpanFinder interface; an inner store without ranges is looked up with FindByIP, without a span
+func (s *MetricsStore) FindSpan(ctx context.Context, ip string) (*models.IPLocation, *IPSpan, error) {
+	finder, ok := s.inner.(SpanFinder)
+	if !ok {
+		location, err := s.FindByIP(ctx, ip)
+		return location, nil, err
+	}
+
+	start := time.Now()
+	location, span, err := finder.FindSpan(ctx, ip)
+	s.observeFind(start, "find_by_ip", err)
+
+	return location, span, err
+}
+
+// RangeFindByIP looks up ip in the inner store's ranges, recorded with operation "range_find_by_ip"
+// Implements the RangeFinder interface; an inner store without ranges finds nothing
+func (s *MetricsStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	start := time.Now()
+	location, err := rangeFindByIP(ctx, s.inner, ip)
+	s.observeFind(start, "range_find_by_ip", err)
+
+	return location, err
+}
+
+// observeFind records the duration and status of a lookup (operation op) started at start
+func (s *MetricsStore) observeFind(start time.Time, op string, err error) {
+	s.metrics.DatastoreQueryDuration.WithLabelValues(s.name, op).Observe(time.Since(start).Seconds())
+
+	status := "success"
+	if err != nil {
+		status = "error"
+		if errors.Is(err, pkerr.ErrNotFound) {
+			status = "not_found"
+		}
+	}
+	s.metrics.DatastoreQueriesTotal.WithLabelValues(s.name, op, status).Inc()
+}
+
+// Iterate passes through to the inner store
+// Implements the Iterator interface; fails if the inner store doesn't implement it
+func (s *MetricsStore) Iterate(fn func(location *models.IPLocation) error) error {
+	it, ok := s.inner.(Iterator)
+	if !ok {
+		return fmt.Errorf("%s store does not support iteration", s.name)
+	}
+	return it.Iterate(fn)
+}
+
+// BulkLoad passes through to the inner store
+// Implements the BulkLoader interface; fails if the inner store doesn't implement it
+func (s *MetricsStore) BulkLoad(locations []*models.IPLocation) error {
+	loader, ok := s.inner.(BulkLoader)
+	if !ok {
+		return fmt.Errorf("%s store does not support bulk loading", s.name)
+	}
+	return loader.BulkLoad(locations)
+}
+
+// BulkLoadFromReader streams into the inner store (see the BulkLoadFromReader function)
+// Implements the StreamLoader interface, so the inner store's own streaming is used when it has one
+func (s *MetricsStore) BulkLoadFromReader(ctx context.Context, r io.Reader) (int, error) {
+	return BulkLoadFromReader(ctx, s.inner, r)
+}
+
+// BulkDelete passes through to the inner store
+// Implements the BulkDeleter interface; fails if the inner store doesn't implement it
+func (s *MetricsStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	deleter, ok := s.inner.(BulkDeleter)
+	if !ok {
+		return 0, fmt.Errorf("%s store does not support bulk deletes", s.name)
+	}
+	return deleter.BulkDelete(ctx, ips)
+}
+
+// Warmup warms up the inner store if it implements WarmableStore
+// Implements the WarmableStore interface
+func (s *MetricsStore) Warmup(ctx context.Context) error {
+	if w, ok := s.inner.(WarmableStore); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// ListCountries lists the inner store's countries
+// Implements the CountryLister interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *MetricsStore) ListCountries(ctx context.Context) ([]string, error) {
+	lister, ok := s.inner.(CountryLister)
+	if !ok {
+		return nil, fmt.Errorf("listing countries is %w", pkerr.ErrNotSupported)
+	}
+	return lister.ListCountries(ctx)
+}
+
+// Search searches the inner store
+// Implements the Searcher interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *MetricsStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.inner.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	}
+	return searcher.Search(ctx, query)
+}
+
+// Count counts the inner store's records
+// Implements the Counter interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *MetricsStore) Count(ctx context.Context) (int, error) {
+	counter, ok := s.inner.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("counting is %w", pkerr.ErrNotSupported)
+	}
+	return counter.Count(ctx)
+}
+
+// Reload reloads the inner store
+// Implements the Reloader interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *MetricsStore) Reload() (ReloadResult, error) {
+	reloader, ok := s.inner.(Reloader)
+	if !ok {
+		return ReloadResult{}, fmt.Errorf("reloading is %w", pkerr.ErrNotSupported)
+	}
+	return reloader.Reload()
+}
+
+// Stats reports the inner store's stats
+// Implements the StatsProvider interface
+func (s *MetricsStore) Stats() StoreStats {
+	if provider, ok := s.inner.(StatsProvider); ok {
+		return provider.Stats()
+	}
+	return StoreStats{}
+}
+
+// Close closes the inner store
+func (s *MetricsStore) Close() error {
+	return s.inner.Close()
+}
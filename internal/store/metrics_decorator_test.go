@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// setupMetricsStore creates a metrics store over a mock, with metrics on a private registry
+func setupMetricsStore() (*MetricsStore, *MockStore, *metrics.Metrics, *prometheus.Registry) {
+	inner := NewMockStore()
+	registry := prometheus.NewRegistry()
+	m := metrics.NewWithRegistry(registry)
+	return NewMetricsStore(inner, m, "mock"), inner, m, registry
+}
+
+// TestMetricsStore_FindByIP_Status tests that each lookup outcome is counted under its status label
+func TestMetricsStore_FindByIP_Status(t *testing.T) {
+	s, inner, m, _ := setupMetricsStore()
+
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.FindByIP(context.Background(), "203.0.113.1"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Fatalf("expected 'IP address not found', got %v", err)
+	}
+
+	storeErr := errors.New("dial tcp: connection refused")
+	inner.FindByIPError = storeErr
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, storeErr) {
+		t.Fatalf("expected the store error to be returned unchanged, got %v", err)
+	}
+
+	expected := map[string]float64{"success": 2, "not_found": 1, "error": 1}
+	for status, want := range expected {
+		got := testutil.ToFloat64(m.DatastoreQueriesTotal.WithLabelValues("mock", "find_by_ip", status))
+		if got != want {
+			t.Errorf("status %q: expected %v, got %v", status, want, got)
+		}
+	}
+}
+
+// TestMetricsStore_FindByIP_Duration tests that every lookup is timed, whatever its outcome
+func TestMetricsStore_FindByIP_Duration(t *testing.T) {
+	s, inner, _, registry := setupMetricsStore()
+
+	s.FindByIP(context.Background(), "8.8.8.8")
+	s.FindByIP(context.Background(), "203.0.113.1")
+	inner.FindByIPError = errors.New("timeout")
+	s.FindByIP(context.Background(), "8.8.8.8")
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "datastore_query_duration_seconds" {
+			continue
+		}
+		if len(family.GetMetric()) != 1 {
+			t.Fatalf("expected a single series, got %d", len(family.GetMetric()))
+		}
+		if got := family.GetMetric()[0].GetHistogram().GetSampleCount(); got != 3 {
+			t.Errorf("expected 3 observations, got %d", got)
+		}
+		return
+	}
+	t.Error("datastore_query_duration_seconds was not recorded")
+}
+
+// TestMetricsStore_PassThrough tests that optional interfaces reach the inner store
+func TestMetricsStore_PassThrough(t *testing.T) {
+	s, inner, _, _ := setupMetricsStore()
+	inner.DataVersion = "v1"
+
+	if s.Unwrap() != inner {
+		t.Error("expected Unwrap to return the inner store")
+	}
+	if got := s.Stats().DataVersion; got != "v1" {
+		t.Errorf("expected data version v1, got %q", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !inner.CloseCalled {
+		t.Error("expected Close to reach the inner store")
+	}
+}
+
+// TestMetricsStore_RangeFindByIP tests that range lookups pass through and are counted under their own operation,
+// and that an inner store without ranges finds nothing rather than failing
+func TestMetricsStore_RangeFindByIP(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := metrics.NewWithRegistry(registry)
+	csv := newRangeCSVStore(t, "ip,city,country\n1.2.3.0/24,Berlin,Germany\n")
+	s := NewMetricsStore(csv, m, "csv")
+
+	location, err := s.RangeFindByIP(context.Background(), "1.2.3.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Berlin" {
+		t.Errorf("expected Berlin, got %s", location.City)
+	}
+	if got := testutil.ToFloat64(m.DatastoreQueriesTotal.WithLabelValues("csv", "range_find_by_ip", "success")); got != 1 {
+		t.Errorf("expected 1 range lookup counted, got %v", got)
+	}
+
+	mock, _, _, _ := setupMetricsStore()
+	if _, err := mock.RangeFindByIP(context.Background(), "1.2.3.4"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from a store without ranges, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// The upstream geoip2-golang fixtures live in a git submodule that isn't part of the Go module,
+// so tests build small IPv4 MaxMind DB files with the same records instead.
+// Format reference: https://maxmind.github.io/MaxMind-DB/
+
+// mmdbTestCity mirrors a record from GeoIP2-City-Test.mmdb
+func mmdbTestCity(city, country, isoCode string, latitude, longitude float64) map[string]any {
+	return map[string]any{
+		"city":     map[string]any{"names": map[string]any{"en": city}},
+		"country":  map[string]any{"iso_code": isoCode, "names": map[string]any{"en": country}},
+		"location": map[string]any{"latitude": latitude, "longitude": longitude},
+	}
+}
+
+// mmdbTestASN mirrors a record from GeoLite2-ASN-Test.mmdb
+func mmdbTestASN(number uint32, organization string) map[string]any {
+	return map[string]any{
+		"autonomous_system_number":       number,
+		"autonomous_system_organization": organization,
+	}
+}
+
+// writeTestCityDB writes a City database to a temp dir and returns its path
+func writeTestCityDB(t *testing.T) string {
+	t.Helper()
+	return writeTestMMDB(t, "GeoIP2-City", map[string]map[string]any{
+		"81.2.69.142/31":   mmdbTestCity("London", "United Kingdom", "GB", 51.5142, -0.0931),
+		"81.2.69.160/27":   mmdbTestCity("London", "United Kingdom", "GB", 51.5142, -0.0931),
+		"216.160.83.56/29": mmdbTestCity("Milton", "United States", "US", 47.2513, -122.3149),
+	})
+}
+
+// writeTestASNDB writes an ASN database to a temp dir and returns its path
+func writeTestASNDB(t *testing.T) string {
+	t.Helper()
+	return writeTestMMDB(t, "GeoLite2-ASN", map[string]map[string]any{
+		"1.128.0.0/11":    mmdbTestASN(1221, "Telstra Pty Ltd"),
+		"81.2.69.160/27":  mmdbTestASN(20712, "Andrews & Arnold Ltd"),
+		"216.160.83.0/24": mmdbTestASN(209, "Qwest Communications Company, LLC"),
+	})
+}
+
+// writeTestMMDB builds an IPv4 MaxMind DB (24-bit records) from CIDR -> record
+func writeTestMMDB(t *testing.T, databaseType string, records map[string]map[string]any) string {
+	t.Helper()
+
+	type node struct {
+		children [2]*node
+		data     int // offset into the data section, -1 if none
+	}
+	newNode := func() *node { return &node{data: -1} }
+	root := newNode()
+
+	var dataSection bytes.Buffer
+	cidrs := make([]string, 0, len(records))
+	for cidr := range records {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			t.Fatalf("bad fixture CIDR %q: %v", cidr, err)
+		}
+		offset := dataSection.Len()
+		mmdbEncode(&dataSection, records[cidr])
+
+		ip := prefix.Addr().As4()
+		current := root
+		for i := 0; i < prefix.Bits(); i++ {
+			bit := (ip[i/8] >> (7 - i%8)) & 1
+			if current.children[bit] == nil {
+				current.children[bit] = newNode()
+			}
+			current = current.children[bit]
+		}
+		current.data = offset
+	}
+
+	// Number the internal nodes breadth first - leaves become data pointers
+	var nodes []*node
+	index := map[*node]int{}
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			if child != nil && child.data < 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+
+	record := func(child *node) uint32 {
+		switch {
+		case child == nil:
+			return uint32(nodeCount) // empty
+		case child.data >= 0:
+			return uint32(nodeCount + 16 + child.data)
+		default:
+			return uint32(index[child])
+		}
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for _, child := range n.children {
+			value := record(child)
+			out.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	out.Write(make([]byte, 16)) // data section separator
+	out.Write(dataSection.Bytes())
+	out.WriteString("\xAB\xCD\xEFMaxMind.com")
+	mmdbEncode(&out, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               databaseType,
+		"description":                 map[string]any{"en": databaseType + " test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+
+	path := filepath.Join(t.TempDir(), databaseType+".mmdb")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write test database: %v", err)
+	}
+	return path
+}
+
+// mmdbEncode appends a value in the MaxMind DB data section encoding
+func mmdbEncode(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case string:
+		mmdbControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		mmdbControl(buf, 3, 8)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint16:
+		mmdbUint(buf, 5, uint64(v))
+	case uint32:
+		mmdbUint(buf, 6, uint64(v))
+	case uint64:
+		mmdbUint(buf, 9, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		mmdbControl(buf, 7, len(v))
+		for _, key := range keys {
+			mmdbEncode(buf, key)
+			mmdbEncode(buf, v[key])
+		}
+	case []any:
+		mmdbControl(buf, 11, len(v))
+		for _, item := range v {
+			mmdbEncode(buf, item)
+		}
+	default:
+		panic(fmt.Sprintf("mmdbEncode: unsupported type %T", value))
+	}
+}
+
+// mmdbUint writes an unsigned integer using the fewest bytes possible
+func mmdbUint(buf *bytes.Buffer, typeNum int, value uint64) {
+	var raw [8]byte
+	binary.BigEndian.PutUint64(raw[:], value)
+	trimmed := bytes.TrimLeft(raw[:], "\x00")
+	mmdbControl(buf, typeNum, len(trimmed))
+	buf.Write(trimmed)
+}
+
+// mmdbControl writes the control byte(s) for a field of the given type and size
+func mmdbControl(buf *bytes.Buffer, typeNum, size int) {
+	var ctrl byte
+	if typeNum <= 7 {
+		ctrl = byte(typeNum << 5)
+	}
+
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	default:
+		ctrl |= 30
+		size -= 285
+		sizeBytes = []byte{byte(size >> 8), byte(size)}
+	}
+
+	buf.WriteByte(ctrl)
+	if typeNum > 7 {
+		buf.WriteByte(byte(typeNum - 7))
+	}
+	buf.Write(sizeBytes)
+}
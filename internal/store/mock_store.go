@@ -1,24 +1,52 @@
 package store
 
+//go:generate go run ../../cmd/generate-testdata -out testdata/sample_ips.json
+
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
 )
 
 // MockStore is a test double for the Store interface
 // It allows tests to control behavior and verify interactions
+// FindByIP is safe for concurrent use; inspect the fields once calls have finished
 type MockStore struct {
+	mu sync.Mutex // Guards FindByIPCalls and Data during FindByIP
+
 	// Data holds the mock data (IP address -> location mapping)
 	Data map[string]*models.IPLocation
 
 	// Track method calls for verification in tests
-	FindByIPCalls []string
-	CloseCalled   bool
+	FindByIPCalls      []string
+	ListCountriesCalls int
+	CloseCalled        bool
+	WarmupCalled       bool
 
 	// Control behavior for error scenarios
 	FindByIPError error
 	CloseError    error
+	WarmupError   error
+	BulkLoadError error
+
+	ListCountriesError error
+	BulkDeleteError    error
+	SearchError        error
+	CountError         error
+
+	// FindByIPDelay simulates a slow backend (cut short when the lookup's context is done)
+	FindByIPDelay time.Duration
+
+	// DataVersion is returned by Stats
+	DataVersion string
 }
 
 // NewMockStore creates a mock store with sample test data
@@ -27,14 +55,18 @@ func NewMockStore() *MockStore {
 	return &MockStore{
 		Data: map[string]*models.IPLocation{
 			"8.8.8.8": {
-				IP:      "8.8.8.8",
-				City:    "Mountain View",
-				Country: "United States",
+				IP:            "8.8.8.8",
+				City:          "Mountain View",
+				Country:       "United States",
+				CountryCode:   "US",
+				ContinentCode: "NA",
 			},
 			"1.1.1.1": {
-				IP:      "1.1.1.1",
-				City:    "Sydney",
-				Country: "Australia",
+				IP:            "1.1.1.1",
+				City:          "Sydney",
+				Country:       "Australia",
+				CountryCode:   "AU",
+				ContinentCode: "OC",
 			},
 		},
 		FindByIPCalls: []string{},
@@ -50,11 +82,70 @@ func NewEmptyMockStore() *MockStore {
 	}
 }
 
+// SeedMockStoreFixture is the fixture generated by cmd/generate-testdata (go generate ./internal/store),
+// relative to this package; tests of other packages reach it as ../store/testdata/sample_ips.json
+const SeedMockStoreFixture = "testdata/sample_ips.json"
+
+// SeedRecord is one entry of a fixture generated by cmd/generate-testdata
+type SeedRecord struct {
+	IP          string `json:"ip"`
+	City        string `json:"city"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"` // ISO 3166 alpha-2
+}
+
+// LoadSeedRecords reads a fixture generated by cmd/generate-testdata
+func LoadSeedRecords(path string) ([]SeedRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed fixture: %w", err)
+	}
+
+	var records []SeedRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse seed fixture: %w", err)
+	}
+	return records, nil
+}
+
+// NewSeedMockStore creates a mock store holding the records of a fixture generated by
+// cmd/generate-testdata, e.g. SeedMockStoreFixture: a thousand IPs across dozens of countries,
+// for tests that need more variety than NewMockStore's two IPs
+func NewSeedMockStore(path string) (*MockStore, error) {
+	records, err := LoadSeedRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewEmptyMockStore()
+	for _, record := range records {
+		m.Data[record.IP] = &models.IPLocation{IP: record.IP, City: record.City, Country: record.Country, CountryCode: record.CountryCode}
+	}
+	return m, nil
+}
+
 // FindByIP implements the Store interface
 // Tracks calls and returns configured data or errors
-func (m *MockStore) FindByIP(ip string) (*models.IPLocation, error) {
+func (m *MockStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
 	// Track that this method was called with this IP
+	m.mu.Lock()
 	m.FindByIPCalls = append(m.FindByIPCalls, ip)
+	m.mu.Unlock()
+
+	// Sleep without the lock so concurrent slow lookups overlap
+	// Like a real backend, a slow lookup returns ctx's error as soon as ctx is done
+	if m.FindByIPDelay > 0 {
+		timer := time.NewTimer(m.FindByIPDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	// If configured to return an error, return it
 	if m.FindByIPError != nil {
@@ -64,12 +155,124 @@ func (m *MockStore) FindByIP(ip string) (*models.IPLocation, error) {
 	// Look up the IP in mock data
 	location, exists := m.Data[ip]
 	if !exists {
-		return nil, fmt.Errorf("IP address not found")
+		return nil, pkerr.ErrNotFound
 	}
 
 	return location, nil
 }
 
+// BatchFindByIP looks up each of ips with FindByIP, returning the locations found and the errors
+// of the others, keyed by IP: what IPService.LookupBatch should answer for a batch of valid IPs over this store
+func (m *MockStore) BatchFindByIP(ctx context.Context, ips []string) (map[string]*models.IPLocation, map[string]error) {
+	locations := make(map[string]*models.IPLocation)
+	errs := make(map[string]error)
+	for _, ip := range ips {
+		if location, err := m.FindByIP(ctx, ip); err != nil {
+			errs[ip] = err
+		} else {
+			locations[ip] = location
+		}
+	}
+	return locations, errs
+}
+
+// Iterate implements the Iterator interface
+// Records are visited in IP order so tests get deterministic output
+func (m *MockStore) Iterate(fn func(location *models.IPLocation) error) error {
+	ips := make([]string, 0, len(m.Data))
+	for ip := range m.Data {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	for _, ip := range ips {
+		if err := fn(m.Data[ip]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListCountries implements the CountryLister interface
+// Counts calls in ListCountriesCalls, or returns the configured error
+func (m *MockStore) ListCountries(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ListCountriesCalls++
+	if m.ListCountriesError != nil {
+		return nil, m.ListCountriesError
+	}
+	return distinctCountries(maps.Values(m.Data)), nil
+}
+
+// Search implements the Searcher interface
+// Returns the configured error, or the matching records of Data
+func (m *MockStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SearchError != nil {
+		return nil, m.SearchError
+	}
+	return searchLocations(maps.Values(m.Data), query), nil
+}
+
+// Count implements the Counter interface
+// Returns the configured error, or the number of records in Data
+func (m *MockStore) Count(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CountError != nil {
+		return 0, m.CountError
+	}
+	return len(m.Data), nil
+}
+
+// BulkLoad implements the BulkLoader interface
+// Stores the locations in Data, or returns the configured error
+func (m *MockStore) BulkLoad(locations []*models.IPLocation) error {
+	if m.BulkLoadError != nil {
+		return m.BulkLoadError
+	}
+	for _, location := range locations {
+		m.Data[location.IP] = location
+	}
+	return nil
+}
+
+// BulkDelete implements the BulkDeleter interface
+// Removes the IPs from Data, or returns the configured error
+func (m *MockStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.BulkDeleteError != nil {
+		return 0, m.BulkDeleteError
+	}
+	deleted := 0
+	for _, ip := range ips {
+		if _, ok := m.Data[ip]; ok {
+			delete(m.Data, ip)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Stats implements the StatsProvider interface
+func (m *MockStore) Stats() StoreStats {
+	return StoreStats{DataVersion: m.DataVersion}
+}
+
+// Warmup implements the WarmableStore interface
+// Tracks that warmup was called and returns configured error if any
+func (m *MockStore) Warmup(ctx context.Context) error {
+	m.WarmupCalled = true
+	return m.WarmupError
+}
+
 // Close implements the Store interface
 // Tracks that close was called and returns configured error if any
 func (m *MockStore) Close() error {
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/pkg/validate"
+)
+
+// loadSeedFixture reads SeedMockStoreFixture
+func loadSeedFixture(t *testing.T) []SeedRecord {
+	t.Helper()
+
+	records, err := LoadSeedRecords(SeedMockStoreFixture)
+	if err != nil {
+		t.Fatalf("failed to load %s (regenerate it with go generate ./internal/store): %v", SeedMockStoreFixture, err)
+	}
+	return records
+}
+
+// TestSeedMockStoreFixture_Entries tests that every entry of the fixture is a complete record with a valid IP
+func TestSeedMockStoreFixture_Entries(t *testing.T) {
+	records := loadSeedFixture(t)
+
+	if len(records) < 1000 {
+		t.Errorf("expected at least 1000 records, got %d", len(records))
+	}
+	for _, record := range records {
+		if err := validate.ValidateIP(record.IP); err != nil {
+			t.Errorf("invalid IP %q", record.IP)
+		}
+		if record.City == "" || record.Country == "" || len(record.CountryCode) != 2 {
+			t.Errorf("incomplete record %+v", record)
+		}
+	}
+}
+
+// TestSeedMockStoreFixture_NoDuplicates tests that no IP appears twice in the fixture
+func TestSeedMockStoreFixture_NoDuplicates(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, record := range loadSeedFixture(t) {
+		if seen[record.IP] {
+			t.Errorf("duplicate IP %s", record.IP)
+		}
+		seen[record.IP] = true
+	}
+}
+
+// TestSeedMockStoreFixture_Diversity tests that the fixture covers at least 20 countries, none of them dominating
+func TestSeedMockStoreFixture_Diversity(t *testing.T) {
+	records := loadSeedFixture(t)
+
+	perCountry := make(map[string]int)
+	names := make(map[string]string)
+	for _, record := range records {
+		perCountry[record.CountryCode]++
+		if name, ok := names[record.CountryCode]; ok && name != record.Country {
+			t.Errorf("country code %s is both %q and %q", record.CountryCode, name, record.Country)
+		}
+		names[record.CountryCode] = record.Country
+	}
+
+	if len(perCountry) < 20 {
+		t.Errorf("expected at least 20 countries, got %d", len(perCountry))
+	}
+	for code, n := range perCountry {
+		if n > len(records)/10 {
+			t.Errorf("expected at most 10%% of the records from one country, got %d of %d from %s", n, len(records), code)
+		}
+	}
+}
+
+// TestNewSeedMockStore tests that every fixture record can be looked up
+func TestNewSeedMockStore(t *testing.T) {
+	store, err := NewSeedMockStore(SeedMockStoreFixture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, record := range loadSeedFixture(t) {
+		loc, err := store.FindByIP(context.Background(), record.IP)
+		if err != nil {
+			t.Fatalf("FindByIP(%s): unexpected error: %v", record.IP, err)
+		}
+		if loc.IP != record.IP || loc.City != record.City || loc.Country != record.Country {
+			t.Errorf("FindByIP(%s): expected %+v, got %+v", record.IP, record, loc)
+		}
+	}
+}
+
+// TestNewSeedMockStore_Errors tests that missing and malformed fixtures are rejected
+func TestNewSeedMockStore_Errors(t *testing.T) {
+	if _, err := NewSeedMockStore(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing fixture")
+	}
+
+	path := filepath.Join(t.TempDir(), "sample_ips.json")
+	if err := os.WriteFile(path, []byte(`{"ip":"8.8.8.8"}`), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if _, err := NewSeedMockStore(path); err == nil {
+		t.Error("expected an error for a fixture that isn't an array")
+	}
+}
@@ -1,20 +1,30 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
 	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/retry"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 // IPCountryModel is the GORM model for the ip2country table
 // GORM uses struct tags to map to database columns
 type IPCountryModel struct {
-	IP      string `gorm:"column:ip;primaryKey"` // Primary key
-	City    string `gorm:"column:city"`
-	Country string `gorm:"column:country"`
+	IP            string `gorm:"column:ip;primaryKey"` // Primary key
+	City          string `gorm:"column:city"`
+	Country       string `gorm:"column:country"`
+	CountryCode   string `gorm:"column:country_code"`   // ISO 3166-1 alpha-2, '' if unknown
+	ContinentCode string `gorm:"column:continent_code"` // AF, AN, AS, EU, NA, OC or SA, '' if unknown
 }
 
 // TableName specifies the table name for GORM
@@ -24,10 +34,65 @@ func (IPCountryModel) TableName() string {
 	return "ip2country"
 }
 
+// IPRangeModel is the GORM model for the ip_ranges table, holding CIDR blocks loaded by BulkLoad
+// Addresses are stored as unsigned integers so a range lookup is a BETWEEN on an indexed column
+type IPRangeModel struct {
+	IPStart       uint32 `gorm:"column:ip_start;primaryKey;autoIncrement:false"` // Not auto-incremented: 0.0.0.0/8 starts at 0
+	IPEnd         uint32 `gorm:"column:ip_end"`
+	City          string `gorm:"column:city"`
+	Country       string `gorm:"column:country"`
+	CountryCode   string `gorm:"column:country_code"`
+	ContinentCode string `gorm:"column:continent_code"`
+}
+
+// TableName specifies the table name for GORM
+func (IPRangeModel) TableName() string {
+	return "ip_ranges"
+}
+
+// IPv6RangeModel is the GORM model for the ip_ranges6 table, holding the IPv6 CIDR blocks loaded by BulkLoad
+// Addresses don't fit an integer column, so they're stored as their 16 bytes (VARBINARY(16)),
+// which compare like the addresses
+type IPv6RangeModel struct {
+	IPStart       []byte `gorm:"column:ip_start;primaryKey"`
+	IPEnd         []byte `gorm:"column:ip_end"`
+	City          string `gorm:"column:city"`
+	Country       string `gorm:"column:country"`
+	CountryCode   string `gorm:"column:country_code"`
+	ContinentCode string `gorm:"column:continent_code"`
+}
+
+// TableName specifies the table name for GORM
+func (IPv6RangeModel) TableName() string {
+	return "ip_ranges6"
+}
+
+// mysqlIteratePageSize is the number of rows fetched per query by Iterate
+var mysqlIteratePageSize = 1000
+
+// queryStartKey is the GORM instance key holding a query's start time
+const queryStartKey = "ip2country:query_start"
+
 // MySQLStore implements Store interface using MySQL with GORM
 // GORM provides ORM features like automatic query building and connection pooling
 type MySQLStore struct {
 	db *gorm.DB // GORM database instance
+
+	// Optional slow query logging (see NewMySQLStoreWithOptions)
+	slowQueryThreshold time.Duration
+	slowQueryLogger    *applogger.Logger
+}
+
+// MySQLStoreOptions holds optional MySQL store settings
+type MySQLStoreOptions struct {
+	// SlowQueryThreshold is the duration above which a query is logged with its EXPLAIN plan
+	SlowQueryThreshold time.Duration
+
+	// SlowQueryLogger receives slow query entries (nil = slow query logging disabled)
+	SlowQueryLogger *applogger.Logger
+
+	// Retry controls retries of the initial connection (zero value = single attempt)
+	Retry retry.Config
 }
 
 // NewMySQLStore creates a new MySQL store using GORM
@@ -41,13 +106,41 @@ type MySQLStore struct {
 //   - *MySQLStore: pointer to the created store
 //   - error: any error that occurred during connection
 func NewMySQLStore(dsn string) (*MySQLStore, error) {
+	return NewMySQLStoreWithOptions(dsn, MySQLStoreOptions{})
+}
+
+// NewMySQLStoreWithOptions creates a new MySQL store with optional settings
+// See NewMySQLStore for the DSN format
+func NewMySQLStoreWithOptions(dsn string, opts MySQLStoreOptions) (*MySQLStore, error) {
 	// Configure GORM
 	config := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent), // Disable query logging (set to Info for debugging)
 	}
 
-	// Open database connection with GORM
+	// Open database connection with GORM and test it, retrying while MySQL is unavailable
 	// GORM handles connection pooling automatically
+	var db *gorm.DB
+	err := retry.Connect(func() error {
+		var err error
+		db, err = openMySQL(dsn, config)
+		return err
+	}, opts.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &MySQLStore{db: db}
+	if opts.SlowQueryLogger != nil {
+		if err := store.enableSlowQueryLogging(opts.SlowQueryThreshold, opts.SlowQueryLogger); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// openMySQL opens a GORM connection, configures the pool and pings the database
+func openMySQL(dsn string, config *gorm.Config) (*gorm.DB, error) {
 	db, err := gorm.Open(mysql.Open(dsn), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MySQL with GORM: %w", err)
@@ -60,34 +153,97 @@ func NewMySQLStore(dsn string) (*MySQLStore, error) {
 	}
 
 	// Configure connection pool
-	sqlDB.SetMaxOpenConns(25)   // Maximum number of open connections
-	sqlDB.SetMaxIdleConns(5)    // Maximum number of idle connections
+	sqlDB.SetMaxOpenConns(25)     // Maximum number of open connections
+	sqlDB.SetMaxIdleConns(5)      // Maximum number of idle connections
 	sqlDB.SetConnMaxLifetime(300) // Maximum connection lifetime (5 minutes)
 
 	// Test the connection
 	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to ping MySQL database: %w", err)
 	}
 
-	return &MySQLStore{db: db}, nil
+	return db, nil
+}
+
+// enableSlowQueryLogging registers GORM callbacks that time every query
+// Queries slower than threshold are logged with their arguments and EXPLAIN output
+func (s *MySQLStore) enableSlowQueryLogging(threshold time.Duration, log *applogger.Logger) error {
+	s.slowQueryThreshold = threshold
+	s.slowQueryLogger = log
+
+	err := s.db.Callback().Query().Before("gorm:query").Register("ip2country:query_timer", func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register query timer: %w", err)
+	}
+
+	err = s.db.Callback().Query().After("gorm:query").Register("ip2country:slow_query_log", s.logSlowQuery)
+	if err != nil {
+		return fmt.Errorf("failed to register slow query logger: %w", err)
+	}
+
+	return nil
+}
+
+// logSlowQuery is the GORM after-query callback
+// Runs EXPLAIN on queries that exceeded the threshold and logs the plan
+func (s *MySQLStore) logSlowQuery(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(queryStartKey)
+	if !ok {
+		return
+	}
+	start, ok := value.(time.Time)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(start)
+	if duration < s.slowQueryThreshold {
+		return
+	}
+
+	query := tx.Statement.SQL.String()
+	args := tx.Statement.Vars
+
+	// Never EXPLAIN an EXPLAIN
+	if strings.HasPrefix(strings.ToUpper(query), "EXPLAIN") {
+		return
+	}
+
+	var plan []map[string]interface{}
+	explainErr := s.db.Session(&gorm.Session{NewDB: true}).Raw("EXPLAIN "+query, args...).Scan(&plan).Error
+
+	event := s.slowQueryLogger.Warn().
+		Dur("duration", duration).
+		Str("query", query).
+		Interface("args", args)
+	if explainErr != nil {
+		event = event.AnErr("explain_error", explainErr)
+	} else {
+		event = event.Interface("plan", plan)
+	}
+	event.Msg("Slow MySQL query")
 }
 
 // FindByIP looks up an IP address using GORM
 // Implements the Store interface method
 //
 // GORM automatically generates the SQL query based on the model
-func (s *MySQLStore) FindByIP(ip string) (*models.IPLocation, error) {
+func (s *MySQLStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
 	var record IPCountryModel
 
 	// GORM query: SELECT * FROM ip2country WHERE ip = ? LIMIT 1
 	// First() finds the first record matching the condition
-	result := s.db.Where("ip = ?", ip).First(&record)
+	// WithContext cancels the query when ctx is done (e.g. the client disconnected)
+	result := s.db.WithContext(ctx).Where("ip = ?", ip).First(&record)
 
 	// Check for errors
 	if result.Error != nil {
 		// GORM returns gorm.ErrRecordNotFound when no rows found
 		if result.Error == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("IP address not found")
+			return nil, pkerr.ErrNotFound
 		}
 		// Other database errors
 		return nil, fmt.Errorf("database query failed: %w", result.Error)
@@ -95,12 +251,274 @@ func (s *MySQLStore) FindByIP(ip string) (*models.IPLocation, error) {
 
 	// Convert GORM model to our domain model
 	return &models.IPLocation{
-		IP:      record.IP,
-		City:    record.City,
-		Country: record.Country,
+		IP:            record.IP,
+		City:          record.City,
+		Country:       record.Country,
+		CountryCode:   record.CountryCode,
+		ContinentCode: record.ContinentCode,
+	}, nil
+}
+
+// RangeFindByIP looks up the CIDR block containing ip in the ip_ranges table, or ip_ranges6 for an IPv6 address
+// Implements the RangeFinder interface
+//
+// GORM query: SELECT * FROM ip_ranges WHERE ? BETWEEN ip_start AND ip_end ORDER BY ip_start DESC LIMIT 1
+// (if blocks overlap, the most specific one - starting closest below ip - wins)
+func (s *MySQLStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	n, ok := ipv4ToUint32(ip)
+	if !ok {
+		return s.ipv6RangeFindByIP(ctx, ip)
+	}
+
+	var record IPRangeModel
+	result := s.db.WithContext(ctx).Where("? BETWEEN ip_start AND ip_end", n).Order("ip_start DESC").Take(&record)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, pkerr.ErrNotFound
+		}
+		return nil, fmt.Errorf("database query failed: %w", result.Error)
+	}
+
+	return &models.IPLocation{
+		IP:            ip,
+		City:          record.City,
+		Country:       record.Country,
+		CountryCode:   record.CountryCode,
+		ContinentCode: record.ContinentCode,
 	}, nil
 }
 
+// ipv6RangeFindByIP looks up the IPv6 block containing ip in the ip_ranges6 table, like RangeFindByIP
+//
+// GORM query: SELECT * FROM ip_ranges6 WHERE ? BETWEEN ip_start AND ip_end ORDER BY ip_start DESC LIMIT 1
+func (s *MySQLStore) ipv6RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	key, ok := ipv6ToKey(ip)
+	if !ok {
+		return nil, pkerr.ErrNotFound
+	}
+
+	var record IPv6RangeModel
+	result := s.db.WithContext(ctx).Where("? BETWEEN ip_start AND ip_end", []byte(key)).Order("ip_start DESC").Take(&record)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, pkerr.ErrNotFound
+		}
+		return nil, fmt.Errorf("database query failed: %w", result.Error)
+	}
+
+	return &models.IPLocation{
+		IP:            ip,
+		City:          record.City,
+		Country:       record.Country,
+		CountryCode:   record.CountryCode,
+		ContinentCode: record.ContinentCode,
+	}, nil
+}
+
+// Iterate calls fn for each row in the ip2country table
+// Implements the Iterator interface
+//
+// Rows are fetched in pages (LIMIT/OFFSET, ordered by primary key)
+// so the whole table is never loaded into memory at once
+func (s *MySQLStore) Iterate(fn func(location *models.IPLocation) error) error {
+	for offset := 0; ; offset += mysqlIteratePageSize {
+		var records []IPCountryModel
+
+		// GORM query: SELECT * FROM ip2country ORDER BY ip LIMIT ? OFFSET ?
+		result := s.db.Order("ip").Limit(mysqlIteratePageSize).Offset(offset).Find(&records)
+		if result.Error != nil {
+			return fmt.Errorf("database query failed: %w", result.Error)
+		}
+
+		for _, record := range records {
+			location := &models.IPLocation{
+				IP:            record.IP,
+				City:          record.City,
+				Country:       record.Country,
+				CountryCode:   record.CountryCode,
+				ContinentCode: record.ContinentCode,
+			}
+			if err := fn(location); err != nil {
+				return err
+			}
+		}
+
+		// A short page means we've reached the end of the table
+		if len(records) < mysqlIteratePageSize {
+			return nil
+		}
+	}
+}
+
+// ListCountries returns the distinct countries in the table, sorted alphabetically
+// Implements the CountryLister interface
+//
+// GORM query: SELECT DISTINCT country FROM ip2country WHERE country <> '' ORDER BY country
+func (s *MySQLStore) ListCountries(ctx context.Context) ([]string, error) {
+	countries := []string{}
+	result := s.db.WithContext(ctx).Model(&IPCountryModel{}).
+		Distinct("country").
+		Where("country <> ?", "").
+		Order("country").
+		Pluck("country", &countries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("database query failed: %w", result.Error)
+	}
+	return countries, nil
+}
+
+// Search returns the rows matching query, ordered by IP
+// Implements the Searcher interface. Both fields are bound as LIKE prefix patterns (never concatenated into
+// the SQL), so a query can't inject SQL; %, _ and \ in them are escaped and match literally.
+// Matching is case-insensitive with MySQL's default collations
+//
+// GORM query: SELECT * FROM ip2country WHERE country LIKE ? AND city LIKE ? ORDER BY ip LIMIT ? OFFSET ?
+func (s *MySQLStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	db := s.db.WithContext(ctx).
+		Where("country LIKE ? AND city LIKE ?", likePrefixPattern(query.Country), likePrefixPattern(query.City)).
+		Order("ip")
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+
+	var records []IPCountryModel
+	if result := db.Find(&records); result.Error != nil {
+		return nil, fmt.Errorf("database query failed: %w", result.Error)
+	}
+
+	locations := make([]*models.IPLocation, len(records))
+	for i, record := range records {
+		locations[i] = &models.IPLocation{
+			IP:            record.IP,
+			City:          record.City,
+			Country:       record.Country,
+			CountryCode:   record.CountryCode,
+			ContinentCode: record.ContinentCode,
+		}
+	}
+	return locations, nil
+}
+
+// Count returns the number of rows in the table
+// Implements the Counter interface
+//
+// GORM query: SELECT count(*) FROM ip2country
+func (s *MySQLStore) Count(ctx context.Context) (int, error) {
+	var count int64
+	if result := s.db.WithContext(ctx).Model(&IPCountryModel{}).Count(&count); result.Error != nil {
+		return 0, fmt.Errorf("database query failed: %w", result.Error)
+	}
+	return int(count), nil
+}
+
+// mysqlBulkLoadBatchSize is the number of rows per INSERT statement in BulkLoad
+const mysqlBulkLoadBatchSize = 500
+
+// BulkLoad inserts locations, updating rows whose IP already exists
+// Implements the BulkLoader interface
+// Locations whose IP is a CIDR block go to the ip_ranges table (1.2.3.0/24) or the ip_ranges6 table
+// (2001:db8::/32), keyed by network start; invalid blocks are skipped
+//
+// GORM query: INSERT INTO ip2country (ip, city, country, country_code, continent_code) VALUES (...), (...) ON DUPLICATE KEY UPDATE ...
+func (s *MySQLStore) BulkLoad(locations []*models.IPLocation) error {
+	var records []IPCountryModel
+	var ranges []IPRangeModel
+	var ipv6Ranges []IPv6RangeModel
+	for _, location := range locations {
+		if !isCIDR(location.IP) {
+			records = append(records, IPCountryModel{
+				IP:            location.IP,
+				City:          location.City,
+				Country:       location.Country,
+				CountryCode:   location.CountryCode,
+				ContinentCode: location.ContinentCode,
+			})
+			continue
+		}
+		if start, end, ok := parseCIDRRange(location.IP); ok {
+			ranges = append(ranges, IPRangeModel{
+				IPStart:       start,
+				IPEnd:         end,
+				City:          location.City,
+				Country:       location.Country,
+				CountryCode:   location.CountryCode,
+				ContinentCode: location.ContinentCode,
+			})
+		} else if start, end, ok := parseIPv6CIDRRange(location.IP); ok {
+			ipv6Ranges = append(ipv6Ranges, IPv6RangeModel{
+				IPStart:       []byte(start),
+				IPEnd:         []byte(end),
+				City:          location.City,
+				Country:       location.Country,
+				CountryCode:   location.CountryCode,
+				ContinentCode: location.ContinentCode,
+			})
+		}
+	}
+
+	if len(records) > 0 {
+		result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(records, mysqlBulkLoadBatchSize)
+		if result.Error != nil {
+			return fmt.Errorf("bulk insert failed: %w", result.Error)
+		}
+	}
+	if len(ranges) > 0 {
+		result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(ranges, mysqlBulkLoadBatchSize)
+		if result.Error != nil {
+			return fmt.Errorf("bulk insert of ranges failed: %w", result.Error)
+		}
+	}
+	if len(ipv6Ranges) > 0 {
+		result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(ipv6Ranges, mysqlBulkLoadBatchSize)
+		if result.Error != nil {
+			return fmt.Errorf("bulk insert of IPv6 ranges failed: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+// mysqlBulkDeleteBatchSize is the number of IPs per DELETE statement in BulkDelete
+const mysqlBulkDeleteBatchSize = 500
+
+// BulkDelete deletes the rows for ips, returning how many were deleted
+// Implements the BulkDeleter interface
+//
+// GORM query: DELETE FROM ip2country WHERE ip IN (...), one statement per batch so no query grows unbounded
+func (s *MySQLStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	deleted := 0
+	for batch := range slices.Chunk(ips, mysqlBulkDeleteBatchSize) {
+		result := s.db.WithContext(ctx).Where("ip IN ?", batch).Delete(&IPCountryModel{})
+		if result.Error != nil {
+			return deleted, fmt.Errorf("bulk delete failed: %w", result.Error)
+		}
+		deleted += int(result.RowsAffected)
+	}
+	return deleted, nil
+}
+
+// Warmup reads the first rows of the table to prime the MySQL buffer pool and open a pooled connection
+// Implements the WarmableStore interface
+func (s *MySQLStore) Warmup(ctx context.Context) error {
+	var ips []string
+	if err := s.db.WithContext(ctx).Raw("SELECT ip FROM ip2country LIMIT 1000").Scan(&ips).Error; err != nil {
+		return fmt.Errorf("warmup query failed: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck pings the database
+// Implements the HealthChecker interface
+func (s *MySQLStore) HealthCheck(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 // Close closes the database connection
 // Should be called when the application shuts down
 func (s *MySQLStore) Close() error {
@@ -1,10 +1,21 @@
 package store
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/rs/zerolog"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -46,7 +57,7 @@ func TestMySQLStore_FindByIP_Success(t *testing.T) {
 		WillReturnRows(rows)
 
 	// Execute
-	location, err := store.FindByIP("8.8.8.8")
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
 
 	// Assert
 	if err != nil {
@@ -68,6 +79,56 @@ func TestMySQLStore_FindByIP_Success(t *testing.T) {
 	}
 }
 
+// TestMySQLStore_FindByIP_CountryCodes tests that the country_code and continent_code columns are read when the table has them
+func TestMySQLStore_FindByIP_CountryCodes(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	rows := sqlmock.NewRows([]string{"ip", "city", "country", "country_code", "continent_code"}).
+		AddRow("8.8.8.8", "Mountain View", "United States", "US", "NA")
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+		WithArgs("8.8.8.8", 1).
+		WillReturnRows(rows)
+
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.CountryCode != "US" || location.ContinentCode != "NA" {
+		t.Errorf("expected codes US and NA, got %q and %q", location.CountryCode, location.ContinentCode)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_FindByIP_ContextCancelled tests that a slow query returns as soon as its context is done
+func TestMySQLStore_FindByIP_ContextCancelled(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+		WithArgs("8.8.8.8", 1).
+		WillDelayFor(10 * time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "city", "country"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := store.FindByIP(ctx, "8.8.8.8")
+	if err == nil {
+		t.Fatal("expected an error for a cancelled query")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the query to stop when the context was done, took %v", elapsed)
+	}
+}
+
 // TestMySQLStore_FindByIP_MultipleIPs tests multiple IP lookups
 func TestMySQLStore_FindByIP_MultipleIPs(t *testing.T) {
 	tests := []struct {
@@ -94,7 +155,7 @@ func TestMySQLStore_FindByIP_MultipleIPs(t *testing.T) {
 				WithArgs(tt.ip, 1).
 				WillReturnRows(rows)
 
-			location, err := store.FindByIP(tt.ip)
+			location, err := store.FindByIP(context.Background(), tt.ip)
 
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
@@ -123,7 +184,7 @@ func TestMySQLStore_FindByIP_NotFound(t *testing.T) {
 		WithArgs("192.168.1.1", 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
-	location, err := store.FindByIP("192.168.1.1")
+	location, err := store.FindByIP(context.Background(), "192.168.1.1")
 
 	if err == nil {
 		t.Error("expected not found error, got nil")
@@ -131,7 +192,7 @@ func TestMySQLStore_FindByIP_NotFound(t *testing.T) {
 	if location != nil {
 		t.Error("expected nil location, got data")
 	}
-	if err.Error() != "IP address not found" {
+	if !errors.Is(err, pkerr.ErrNotFound) {
 		t.Errorf("expected 'IP address not found', got '%s'", err.Error())
 	}
 
@@ -150,7 +211,7 @@ func TestMySQLStore_FindByIP_DatabaseError(t *testing.T) {
 		WithArgs("8.8.8.8", 1).
 		WillReturnError(sql.ErrConnDone)
 
-	location, err := store.FindByIP("8.8.8.8")
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
 
 	if err == nil {
 		t.Error("expected database error, got nil")
@@ -159,7 +220,7 @@ func TestMySQLStore_FindByIP_DatabaseError(t *testing.T) {
 		t.Error("expected nil location, got data")
 	}
 	// Should wrap the error, not return "IP address not found"
-	if err.Error() == "IP address not found" {
+	if errors.Is(err, pkerr.ErrNotFound) {
 		t.Error("expected database error, got not found error")
 	}
 
@@ -234,7 +295,7 @@ func TestMySQLStore_FindByIP_SpecialCharacters(t *testing.T) {
 				WithArgs(tt.ip, 1).
 				WillReturnRows(rows)
 
-			location, err := store.FindByIP(tt.ip)
+			location, err := store.FindByIP(context.Background(), tt.ip)
 
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
@@ -270,7 +331,7 @@ func TestMySQLStore_FindByIP_IPv6(t *testing.T) {
 				WithArgs(ip, 1).
 				WillReturnRows(rows)
 
-			location, err := store.FindByIP(ip)
+			location, err := store.FindByIP(context.Background(), ip)
 
 			if err != nil {
 				t.Fatalf("unexpected error for IPv6: %v", err)
@@ -296,7 +357,7 @@ func TestMySQLStore_FindByIP_EmptyResult(t *testing.T) {
 		WithArgs("10.0.0.1", 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
-	location, err := store.FindByIP("10.0.0.1")
+	location, err := store.FindByIP(context.Background(), "10.0.0.1")
 
 	if err == nil {
 		t.Error("expected error for empty result, got nil")
@@ -328,3 +389,504 @@ func TestIPCountryModel_StructTags(t *testing.T) {
 		t.Errorf("expected country 'United States', got '%s'", model.Country)
 	}
 }
+
+// TestMySQLStore_Iterate tests paginated iteration over the table
+func TestMySQLStore_Iterate(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	originalPageSize := mysqlIteratePageSize
+	mysqlIteratePageSize = 2
+	defer func() { mysqlIteratePageSize = originalPageSize }()
+
+	// Page 1: full page, so another query follows
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` ORDER BY ip LIMIT \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "city", "country"}).
+			AddRow("1.1.1.1", "Sydney", "Australia").
+			AddRow("8.8.4.4", "Mountain View", "United States"))
+
+	// Page 2: short page ends iteration
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` ORDER BY ip LIMIT \\? OFFSET \\?").
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "city", "country"}).
+			AddRow("8.8.8.8", "Mountain View", "United States"))
+
+	var ips []string
+	err := store.Iterate(func(location *models.IPLocation) error {
+		ips = append(ips, location.IP)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 3 {
+		t.Errorf("expected 3 records, got %d", len(ips))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_Iterate_DatabaseError tests query errors are returned
+func TestMySQLStore_Iterate_DatabaseError(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` ORDER BY ip LIMIT \\?").
+		WillReturnError(sql.ErrConnDone)
+
+	err := store.Iterate(func(location *models.IPLocation) error { return nil })
+
+	if err == nil {
+		t.Error("expected database error, got nil")
+	}
+}
+
+// newSlowQueryStore creates a store with slow query logging writing to buf
+func newSlowQueryStore(t *testing.T, db *gorm.DB, threshold time.Duration, buf *bytes.Buffer) *MySQLStore {
+	t.Helper()
+
+	zl := zerolog.New(buf)
+	store := &MySQLStore{db: db}
+	if err := store.enableSlowQueryLogging(threshold, &applogger.Logger{Logger: &zl}); err != nil {
+		t.Fatalf("failed to enable slow query logging: %v", err)
+	}
+	return store
+}
+
+// TestMySQLStore_SlowQuery_Explain tests that slow queries are EXPLAINed and logged
+func TestMySQLStore_SlowQuery_Explain(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	var buf bytes.Buffer
+	store := newSlowQueryStore(t, db, 10*time.Millisecond, &buf)
+
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+		WithArgs("8.8.8.8", 1).
+		WillDelayFor(30 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "city", "country"}).
+			AddRow("8.8.8.8", "Mountain View", "United States"))
+
+	mock.ExpectQuery("EXPLAIN SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+		WithArgs("8.8.8.8", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "select_type", "table", "type"}).
+			AddRow(1, "SIMPLE", "ip2country", "const"))
+
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected EXPLAIN to be executed: %v", err)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON log entry, got %q", buf.String())
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("expected log entry to contain duration")
+	}
+	if _, ok := entry["plan"]; !ok {
+		t.Error("expected log entry to contain the EXPLAIN plan")
+	}
+	if entry["query"] == "" {
+		t.Error("expected log entry to contain the query")
+	}
+}
+
+// TestMySQLStore_FastQuery_NoExplain tests that fast queries are not EXPLAINed
+func TestMySQLStore_FastQuery_NoExplain(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	var buf bytes.Buffer
+	store := newSlowQueryStore(t, db, time.Second, &buf)
+
+	mock.ExpectQuery("SELECT \\* FROM `ip2country` WHERE ip = \\? .*").
+		WithArgs("8.8.8.8", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "city", "country"}).
+			AddRow("8.8.8.8", "Mountain View", "United States"))
+
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Any unexpected EXPLAIN query would have failed FindByIP's mock expectations
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no slow query log, got %q", buf.String())
+	}
+}
+
+// TestMySQLStore_Warmup tests that warmup runs the priming query
+func TestMySQLStore_Warmup(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT ip FROM ip2country LIMIT 1000").
+		WillReturnRows(sqlmock.NewRows([]string{"ip"}).AddRow("1.1.1.1").AddRow("8.8.8.8"))
+
+	if err := store.Warmup(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_Warmup_Error tests that a failed warmup query is reported
+func TestMySQLStore_Warmup_Error(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT ip FROM ip2country LIMIT 1000").
+		WillReturnError(sql.ErrConnDone)
+
+	if err := store.Warmup(context.Background()); err == nil {
+		t.Error("expected warmup error, got nil")
+	}
+}
+
+// TestMySQLStore_BulkLoad tests that rows are upserted in a single batch
+func TestMySQLStore_BulkLoad(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `ip2country` \\(`ip`,`city`,`country`,`country_code`,`continent_code`\\) VALUES \\(\\?,\\?,\\?,\\?,\\?\\),\\(\\?,\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE .*").
+		WithArgs("8.8.8.8", "Mountain View", "United States", "US", "NA", "1.1.1.1", "Sydney", "Australia", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err := store.BulkLoad([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States", CountryCode: "US", ContinentCode: "NA"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_BulkDelete tests that the affected row counts are returned, so missing IPs aren't counted
+func TestMySQLStore_BulkDelete(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `ip2country` WHERE ip IN \\(\\?,\\?\\)").
+		WithArgs("8.8.8.8", "9.9.9.9").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deleted, err := store.BulkDelete(context.Background(), []string{"8.8.8.8", "9.9.9.9"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d", deleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_BulkDelete_Batches tests that 501 IPs are deleted in two statements (500 + 1)
+func TestMySQLStore_BulkDelete_Batches(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	ips := make([]string, mysqlBulkDeleteBatchSize+1)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `ip2country` WHERE ip IN \\((\\?,){499}\\?\\)").
+		WillReturnResult(sqlmock.NewResult(0, mysqlBulkDeleteBatchSize))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `ip2country` WHERE ip IN \\(\\?\\)").
+		WithArgs(ips[mysqlBulkDeleteBatchSize]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deleted, err := store.BulkDelete(context.Background(), ips)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != len(ips) {
+		t.Errorf("expected %d deleted, got %d", len(ips), deleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_BulkDelete_Empty tests that an empty list sends no query
+func TestMySQLStore_BulkDelete_Empty(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	deleted, err := store.BulkDelete(context.Background(), nil)
+	if err != nil || deleted != 0 {
+		t.Errorf("expected 0 deleted and no error, got %d, %v", deleted, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unexpected queries: %v", err)
+	}
+}
+
+// TestMySQLStore_Search tests that the fields become escaped LIKE prefix patterns bound as arguments
+func TestMySQLStore_Search(t *testing.T) {
+	tests := []struct {
+		name  string
+		query StoreQuery
+		sql   string
+		args  []driver.Value
+	}{
+		{
+			"country only", StoreQuery{Country: "United States", Limit: 10},
+			"SELECT \\* FROM `ip2country` WHERE country LIKE \\? AND city LIKE \\? ORDER BY ip LIMIT \\?$",
+			[]driver.Value{"United States%", "%", 10},
+		},
+		{
+			"city only", StoreQuery{City: "New", Limit: 10},
+			"SELECT \\* FROM `ip2country` WHERE country LIKE \\? AND city LIKE \\? ORDER BY ip LIMIT \\?$",
+			[]driver.Value{"%", "New%", 10},
+		},
+		{
+			"both fields with offset", StoreQuery{Country: "United", City: "New", Limit: 5, Offset: 10},
+			"SELECT \\* FROM `ip2country` WHERE country LIKE \\? AND city LIKE \\? ORDER BY ip LIMIT \\? OFFSET \\?$",
+			[]driver.Value{"United%", "New%", 5, 10},
+		},
+		{
+			"wildcards escaped", StoreQuery{Country: `100%_\`, Limit: 10},
+			"SELECT \\* FROM `ip2country` WHERE country LIKE \\? AND city LIKE \\? ORDER BY ip LIMIT \\?$",
+			[]driver.Value{`100\%\_\\%`, "%", 10},
+		},
+		{
+			"SQL injection", StoreQuery{Country: "' OR '1'='1", City: "x'; DROP TABLE ip2country; --", Limit: 10},
+			"SELECT \\* FROM `ip2country` WHERE country LIKE \\? AND city LIKE \\? ORDER BY ip LIMIT \\?$",
+			[]driver.Value{"' OR '1'='1%", "x'; DROP TABLE ip2country; --%", 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupMockDB(t)
+			defer sqlDB.Close()
+			store := &MySQLStore{db: db}
+
+			mock.ExpectQuery(tt.sql).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"ip", "city", "country"}).
+					AddRow("4.2.2.2", "New York", "United States").
+					AddRow("9.9.9.9", "Newark", "United States"))
+
+			locations, err := store.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := searchIPs(locations); fmt.Sprint(got) != "[4.2.2.2 9.9.9.9]" {
+				t.Errorf("expected the returned rows, got %v", got)
+			}
+			if locations[0].City != "New York" || locations[0].Country != "United States" {
+				t.Errorf("expected New York, United States, got %+v", *locations[0])
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+// TestMySQLStore_Search_Error tests that a query failure is returned
+func TestMySQLStore_Search_Error(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT \\* FROM `ip2country`").WillReturnError(fmt.Errorf("connection refused"))
+
+	if _, err := store.Search(context.Background(), StoreQuery{Country: "United", Limit: 10}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// TestMySQLStore_ListCountries tests the DISTINCT query
+func TestMySQLStore_ListCountries(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT DISTINCT `country` FROM `ip2country` WHERE country <> \\? ORDER BY country").
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows([]string{"country"}).AddRow("Australia").AddRow("United States"))
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(countries) != 2 || countries[0] != "Australia" || countries[1] != "United States" {
+		t.Errorf("expected [Australia United States], got %v", countries)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_RangeFindByIP tests that the IP is looked up as an integer in the ip_ranges table
+func TestMySQLStore_RangeFindByIP(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	rows := sqlmock.NewRows([]string{"ip_start", "ip_end", "city", "country"}).
+		AddRow(16909056, 16909311, "Berlin", "Germany")
+	mock.ExpectQuery("SELECT \\* FROM `ip_ranges` WHERE \\? BETWEEN ip_start AND ip_end ORDER BY ip_start DESC LIMIT \\?").
+		WithArgs(16909060, 1). // 1.2.3.4
+		WillReturnRows(rows)
+
+	location, err := store.RangeFindByIP(context.Background(), "1.2.3.4")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.IP != "1.2.3.4" || location.City != "Berlin" || location.Country != "Germany" {
+		t.Errorf("unexpected location: %+v", location)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_RangeFindByIP_IPv6 tests that an IPv6 address is looked up as its 16 bytes in the ip_ranges6 table
+func TestMySQLStore_RangeFindByIP_IPv6(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	start := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	end := []byte{0x20, 0x01, 0x0d, 0xb8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	ip := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	rows := sqlmock.NewRows([]string{"ip_start", "ip_end", "city", "country"}).
+		AddRow(start, end, "Documentation", "Documentation")
+	mock.ExpectQuery("SELECT \\* FROM `ip_ranges6` WHERE \\? BETWEEN ip_start AND ip_end ORDER BY ip_start DESC LIMIT \\?").
+		WithArgs(ip, 1). // 2001:db8::1
+		WillReturnRows(rows)
+
+	location, err := store.RangeFindByIP(context.Background(), "2001:db8::1")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.IP != "2001:db8::1" || location.City != "Documentation" {
+		t.Errorf("unexpected location: %+v", location)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_RangeFindByIP_NotFound tests that no matching range, and an invalid address, are ErrNotFound
+func TestMySQLStore_RangeFindByIP_NotFound(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectQuery("SELECT \\* FROM `ip_ranges` .*").
+		WillReturnRows(sqlmock.NewRows([]string{"ip_start", "ip_end", "city", "country"}))
+	mock.ExpectQuery("SELECT \\* FROM `ip_ranges6` .*").
+		WillReturnRows(sqlmock.NewRows([]string{"ip_start", "ip_end", "city", "country"}))
+
+	if _, err := store.RangeFindByIP(context.Background(), "1.2.3.4"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.RangeFindByIP(context.Background(), "2001:db8::1"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for IPv6, got %v", err)
+	}
+	// Invalid addresses aren't queried at all
+	if _, err := store.RangeFindByIP(context.Background(), "not-an-ip"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an invalid address, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestMySQLStore_BulkLoad_CIDR tests that CIDR blocks are written to ip_ranges and ip_ranges6, and single IPs to ip2country
+func TestMySQLStore_BulkLoad_CIDR(t *testing.T) {
+	db, mock, sqlDB := setupMockDB(t)
+	defer sqlDB.Close()
+
+	store := &MySQLStore{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `ip2country` \\(`ip`,`city`,`country`,`country_code`,`continent_code`\\) VALUES \\(\\?,\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE .*").
+		WithArgs("8.8.8.8", "Mountain View", "United States", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `ip_ranges` \\(`ip_start`,`ip_end`,`city`,`country`,`country_code`,`continent_code`\\) VALUES \\(\\?,\\?,\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE .*").
+		WithArgs(16909056, 16909311, "Berlin", "Germany", "DE", "EU"). // 1.2.3.0 - 1.2.3.255
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `ip_ranges6` \\(`ip_start`,`ip_end`,`city`,`country`,`country_code`,`continent_code`\\) VALUES \\(\\?,\\?,\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE .*").
+		WithArgs(
+			[]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			[]byte{0x20, 0x01, 0x0d, 0xb8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			"Documentation", "Documentation", "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := store.BulkLoad([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		{IP: "1.2.3.0/24", City: "Berlin", Country: "Germany", CountryCode: "DE", ContinentCode: "EU"},
+		{IP: "2001:db8::/32", City: "Documentation", Country: "Documentation"},
+		{IP: "1.2.3.0/33", City: "Nowhere", Country: "Nowhere"}, // Skipped: invalid
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisBulkLoadBatchSize is the number of SET commands sent per pipeline
+const redisBulkLoadBatchSize = 500
+
+// queueSetLocation queues the SET of location (plus the SADD of its country) on pipe, expiring it after ttl
+// A location whose IP is a CIDR block goes to the IPv4 or IPv6 ranges sorted set instead, without a TTL
+// (see SetRange); invalid blocks are skipped
+func queueSetLocation(ctx context.Context, pipe redis.Pipeliner, location *models.IPLocation, ttl time.Duration) error {
+	if isCIDR(location.IP) {
+		if !isValidCIDR(location.IP) {
+			return nil
+		}
+		return queueSetCIDR(ctx, pipe, location.IP, *location)
+	}
+
+	data, err := json.Marshal(location)
+	if err != nil {
+		return fmt.Errorf("failed to encode IP location: %w", err)
+	}
+	pipe.Set(ctx, fmt.Sprintf("ip:%s", location.IP), data, ttl)
+	if location.Country != "" {
+		pipe.SAdd(ctx, redisCountriesKey, location.Country)
+	}
+	return nil
+}
+
+// BulkLoadCSVParallel loads a CSV file into Redis using several goroutines
+// Much faster than LoadFromCSV for large files:
+//   - The file is streamed row by row, never fully loaded into memory
+//   - Rows are spread across workers, each pipelining SETs in batches of 500
+//
+// Rows are assigned to workers by IP hash, so duplicate IPs always go to the same
+// worker in file order and the last occurrence wins (same as LoadFromCSV)
+// Workers share one ThrottledBatchWriter, so the whole load stays under SetWriteRPS
+// The first error stops all workers and is returned
+//
+// CSV Format: ip,city,country[,country_code,continent_code] (first row is a header)
+func (s *RedisStore) BulkLoadCSVParallel(csvPath string, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Invalid rows are skipped below, not fatal
+	reader.ReuseRecord = true
+
+	// The header says whether rows have the code columns
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return ErrEmptyCSV
+		}
+		return fmt.Errorf("failed to read CSV file: %w", err)
+	}
+	layout := newCSVLayout(header, 1)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	writer := NewThrottledBatchWriter(ctx, s.client, s.writeRPS)
+	writer.SetTTL(s.ttl)
+	defer writer.Stop()
+
+	// One channel per worker so each IP is always handled by the same worker
+	queues := make([]chan models.IPLocation, workers)
+	errCh := make(chan error, workers)
+	var loaded int64
+	var wg sync.WaitGroup
+
+	for i := range queues {
+		queues[i] = make(chan models.IPLocation, redisBulkLoadBatchSize)
+		wg.Add(1)
+		go func(rows <-chan models.IPLocation) {
+			defer wg.Done()
+			if err := bulkLoadWorker(ctx, writer, rows, &loaded); err != nil {
+				errCh <- err
+				cancel() // Stop the reader and the other workers
+			}
+		}(queues[i])
+	}
+
+	readErr := bulkLoadDispatch(ctx, reader, layout, queues)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	close(errCh)
+
+	// A worker error is the root cause of any cancellation seen by the reader
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if err := s.recordDataVersion(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d IP records into Redis\n", loaded)
+	return nil
+}
+
+// bulkLoadDispatch reads CSV rows and sends each to the worker queue chosen by IP hash
+// Returns early (with nil) when ctx is cancelled
+func bulkLoadDispatch(ctx context.Context, reader *csv.Reader, layout csvLayout, queues []chan models.IPLocation) error {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV file: %w", err)
+		}
+
+		// Skip invalid records, like NewCSVStore
+		if len(record) != layout.columns() {
+			continue
+		}
+
+		location := layout.location(record)
+		location.IP = record[0]
+
+		h := fnv.New32a()
+		h.Write([]byte(location.IP))
+		queue := queues[h.Sum32()%uint32(len(queues))]
+
+		select {
+		case queue <- location:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// bulkLoadWorker writes rows from its queue to Redis in pipelined batches through writer
+// Returns nil when the queue is closed or ctx is cancelled by another worker
+func bulkLoadWorker(ctx context.Context, writer *ThrottledBatchWriter, rows <-chan models.IPLocation, loaded *int64) error {
+	batch := make([]models.IPLocation, 0, redisBulkLoadBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := writer.WriteIPBatch(batch); err != nil {
+			if ctx.Err() != nil {
+				return nil // Cancelled because another worker failed
+			}
+			return err
+		}
+
+		atomic.AddInt64(loaded, int64(len(batch)))
+		batch = batch[:0]
+		return nil
+	}
+
+	for location := range rows {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		batch = append(batch, location)
+		if len(batch) == redisBulkLoadBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return flush()
+}
+
+// BulkLoad writes locations to Redis in pipelined batches
+// Implements the BulkLoader interface
+func (s *RedisStore) BulkLoad(locations []*models.IPLocation) error {
+	for start := 0; start < len(locations); start += redisBulkLoadBatchSize {
+		end := min(start+redisBulkLoadBatchSize, len(locations))
+		if err := s.pipelineSet(s.ctx, locations[start:end]); err != nil {
+			return err
+		}
+	}
+	return s.recordDataVersion()
+}
+
+// BulkLoadFromReader streams CSV rows from r into Redis, one pipeline per batch of 1000 SETs
+// Implements the StreamLoader interface. The data version is updated once, after the last batch,
+// or after a failure if some batches were written
+func (s *RedisStore) BulkLoadFromReader(ctx context.Context, r io.Reader) (int, error) {
+	loaded, err := streamCSV(ctx, r, func(batch []*models.IPLocation) error {
+		return s.pipelineSet(ctx, batch)
+	})
+	if loaded > 0 {
+		if versionErr := s.recordDataVersion(); err == nil {
+			err = versionErr
+		}
+	}
+	return loaded, err
+}
+
+// pipelineSet writes locations to Redis in a single pipeline, expiring them after the store's TTL (see SetTTL)
+func (s *RedisStore) pipelineSet(ctx context.Context, locations []*models.IPLocation) error {
+	pipe := s.client.Pipeline()
+	for _, location := range locations {
+		if err := queueSetLocation(ctx, pipe, location, s.ttl); err != nil {
+			return err
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store batch in Redis: %w", err)
+	}
+	return nil
+}
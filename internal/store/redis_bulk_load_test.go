@@ -0,0 +1,249 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// writeBulkCSV writes a CSV with a header and n generated rows, returning its path
+func writeBulkCSV(tb testing.TB, n int) string {
+	tb.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString("ip,city,country\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "10.%d.%d.%d,City%d,Country%d\n", i/65536, (i/256)%256, i%256, i, i%200)
+	}
+
+	path := filepath.Join(tb.TempDir(), "bulk.csv")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		tb.Fatalf("failed to write CSV: %v", err)
+	}
+	return path
+}
+
+// captureStdout runs fn and returns what it printed
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	original := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = original }()
+
+	fn()
+
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+// setupBulkRedis creates a Redis store backed by miniredis
+func setupBulkRedis(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, mr
+}
+
+// ipKeys returns the IP record keys (skipping metadata such as meta:data_version)
+func ipKeys(mr *miniredis.Miniredis) []string {
+	var keys []string
+	for _, key := range mr.Keys() {
+		if strings.HasPrefix(key, "ip:") {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TestRedisStore_BulkLoadCSVParallel tests that every row is loaded and the count is reported
+func TestRedisStore_BulkLoadCSVParallel(t *testing.T) {
+	store, mr := setupBulkRedis(t)
+	path := writeBulkCSV(t, 2345) // Not a multiple of the batch size
+
+	var err error
+	out := captureStdout(t, func() {
+		err = store.BulkLoadCSVParallel(path, 4)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if keys := ipKeys(mr); len(keys) != 2345 {
+		t.Errorf("expected 2345 keys, got %d", len(keys))
+	}
+	if !strings.Contains(out, "Loaded 2345 IP records") {
+		t.Errorf("expected printed count of 2345, got: %q", out)
+	}
+
+	// Last row: i = 2344 -> 10.0.9.40
+	location, err := store.FindByIP(context.Background(), "10.0.9.40")
+	if err != nil {
+		t.Fatalf("expected last row to be loaded: %v", err)
+	}
+	if location.City != "City2344" {
+		t.Errorf("expected City2344, got %s", location.City)
+	}
+}
+
+// TestRedisStore_BulkLoadCSVParallel_Duplicates tests that later rows overwrite earlier ones
+func TestRedisStore_BulkLoadCSVParallel_Duplicates(t *testing.T) {
+	store, _ := setupBulkRedis(t)
+
+	content := "ip,city,country\n" +
+		"8.8.8.8,Old City,United States\n" +
+		"1.1.1.1,Sydney,Australia\n" +
+		"8.8.8.8,Mountain View,United States\n"
+	path := filepath.Join(t.TempDir(), "dupes.csv")
+	os.WriteFile(path, []byte(content), 0644)
+
+	captureStdout(t, func() {
+		if err := store.BulkLoadCSVParallel(path, 8); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" {
+		t.Errorf("expected last row to win, got city %q", location.City)
+	}
+}
+
+// TestRedisStore_BulkLoadCSVParallel_WorkerError tests that a failing worker stops the whole load
+func TestRedisStore_BulkLoadCSVParallel_WorkerError(t *testing.T) {
+	store, mr := setupBulkRedis(t)
+	const rows = 50000
+	path := writeBulkCSV(t, rows)
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	before := mr.CommandCount()
+
+	err := store.BulkLoadCSVParallel(path, 4)
+
+	if err == nil {
+		t.Fatal("expected error from failing workers")
+	}
+	if !strings.Contains(err.Error(), "LOADING") {
+		t.Errorf("expected the Redis error, got: %v", err)
+	}
+
+	// Without cancellation every row would be sent; workers must stop after their first failed batch
+	if sent := mr.CommandCount() - before; sent > rows/2 {
+		t.Errorf("expected remaining work to be cancelled, but %d of %d commands were sent", sent, rows)
+	}
+}
+
+// TestRedisStore_BulkLoadCSVParallel_EmptyFile tests that a file without a header is rejected
+func TestRedisStore_BulkLoadCSVParallel_EmptyFile(t *testing.T) {
+	store, _ := setupBulkRedis(t)
+	path := filepath.Join(t.TempDir(), "empty.csv")
+	os.WriteFile(path, nil, 0644)
+
+	if err := store.BulkLoadCSVParallel(path, 2); err == nil {
+		t.Error("expected error for empty file")
+	}
+}
+
+// BenchmarkRedisStore_BulkLoad_1M compares sequential and parallel loading of 1M rows
+func BenchmarkRedisStore_BulkLoad_1M(b *testing.B) {
+	path := writeBulkCSV(b, 1_000_000)
+
+	run := func(b *testing.B, load func(store *RedisStore) error) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			mr := miniredis.NewMiniRedis()
+			if err := mr.Start(); err != nil {
+				b.Fatalf("failed to start miniredis: %v", err)
+			}
+			store, err := NewRedisStore(mr.Addr(), "", 0)
+			if err != nil {
+				b.Fatalf("failed to connect to Redis: %v", err)
+			}
+			b.StartTimer()
+
+			if err := load(store); err != nil {
+				b.Fatalf("load failed: %v", err)
+			}
+
+			b.StopTimer()
+			store.Close()
+			mr.Close()
+			b.StartTimer()
+		}
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		run(b, func(store *RedisStore) error { return store.LoadFromCSV(path) })
+	})
+	b.Run("parallel", func(b *testing.B) {
+		run(b, func(store *RedisStore) error { return store.BulkLoadCSVParallel(path, 8) })
+	})
+}
+
+// TestRedisStore_BulkLoad tests that locations are written and overwrite existing keys
+func TestRedisStore_BulkLoad(t *testing.T) {
+	store, _ := setupBulkRedis(t)
+	store.Set("8.8.8.8", "Old City", "United States")
+
+	err := store.BulkLoad([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil || location.City != "Mountain View" {
+		t.Errorf("expected overwritten city Mountain View, got %+v (err %v)", location, err)
+	}
+	if _, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected new IP to be stored: %v", err)
+	}
+}
+
+// TestRedisStore_BulkLoadCSVParallel_TTL tests that the parallel load sets the store's TTL on every IP
+func TestRedisStore_BulkLoadCSVParallel_TTL(t *testing.T) {
+	store, mr := setupBulkRedis(t)
+	store.SetTTL(time.Hour)
+	path := writeBulkCSV(t, 600)
+
+	captureStdout(t, func() {
+		if err := store.BulkLoadCSVParallel(path, 2); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	for _, key := range ipKeys(mr) {
+		if ttl := mr.TTL(key); ttl != time.Hour {
+			t.Fatalf("expected TTL 1h on %s, got %v", key, ttl)
+		}
+	}
+
+	mr.FastForward(time.Hour)
+	if keys := ipKeys(mr); len(keys) != 0 {
+		t.Errorf("expected every IP to expire, %d left", len(keys))
+	}
+}
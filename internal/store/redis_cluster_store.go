@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/retry"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisClusterStore implements Store interface using a Redis Cluster
+// Keys are spread across the cluster's shards, so the dataset isn't limited by one node's memory
+//
+// Redis Key Format: ip:{<ip_address>}
+// The braces are a hash tag: only the IP is hashed to pick the slot, so anything stored
+// under an IP's tag (now or later) lands on the same shard as its record
+//
+// CIDR blocks are kept in the same ip_ranges and ip_ranges6 sorted sets as RedisStore uses (see SetRange).
+// Each set is a single key, so it lives on one shard
+type RedisClusterStore struct {
+	client *redis.ClusterClient
+	ctx    context.Context
+
+	// retry controls retries of transient errors in FindByIP, Set and LoadFromCSV (see SetRetry)
+	retry retry.Config
+}
+
+// NewRedisClusterStore creates a store over the Redis Cluster reachable at addrs
+// addrs only needs some of the cluster's nodes: the rest are discovered from them
+func NewRedisClusterStore(addrs []string, password string) (*RedisClusterStore, error) {
+	return NewRedisClusterStoreWithRetry(addrs, password, retry.Config{})
+}
+
+// NewRedisClusterStoreWithRetry creates a Redis Cluster store, retrying the initial connection per retry
+func NewRedisClusterStoreWithRetry(addrs []string, password string, retryConfig retry.Config) (*RedisClusterStore, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("Redis Cluster needs at least one address")
+	}
+
+	// The client's own retries are disabled - RedisClusterStore retries transient errors itself (see SetRetry)
+	// MOVED and ASK redirections are still followed by the client
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:      addrs,
+		Password:   password,
+		MaxRetries: -1,
+	})
+
+	ctx := context.Background()
+
+	err := retry.Connect(func() error {
+		return client.Ping(ctx).Err()
+	}, retryConfig)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis Cluster: %w", err)
+	}
+
+	return &RedisClusterStore{
+		client: client,
+		ctx:    ctx,
+		retry:  DefaultRedisOperationRetry,
+	}, nil
+}
+
+// SetRetry sets how transient errors are retried by FindByIP, Set and LoadFromCSV
+// The zero value disables retries
+func (s *RedisClusterStore) SetRetry(retryConfig retry.Config) {
+	s.retry = retryConfig
+}
+
+// withRetry runs a Redis operation under the store's retry policy
+func (s *RedisClusterStore) withRetry(op string, fn func() error) error {
+	return retry.Do(op, fn, isRetryableRedisError, s.retry)
+}
+
+// redisClusterKey returns the key of ip's record, hash tagged on the IP
+func redisClusterKey(ip string) string {
+	return "ip:{" + ip + "}"
+}
+
+// FindByIP looks up an IP address on the shard owning its slot
+// Implements the Store interface method
+func (s *RedisClusterStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	key := redisClusterKey(ip)
+
+	var val string
+	err := s.withRetry("GET "+key, func() error {
+		var err error
+		val, err = s.client.Get(ctx, key).Result()
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, pkerr.ErrNotFound
+		}
+		return nil, fmt.Errorf("Redis query failed: %w", err)
+	}
+
+	var location models.IPLocation
+	if err := json.Unmarshal([]byte(val), &location); err != nil {
+		return nil, fmt.Errorf("failed to decode IP location: %w", err)
+	}
+
+	// IP field has json:"-" tag, so it's not in JSON - set it manually
+	location.IP = ip
+
+	return &location, nil
+}
+
+// Set adds or updates an IP address in the cluster
+// The record and the countries index usually live on different shards; the cluster
+// client splits the pipeline by slot and sends each part to its shard
+func (s *RedisClusterStore) Set(ip, city, country string) error {
+	data, err := json.Marshal(models.IPLocation{IP: ip, City: city, Country: country})
+	if err != nil {
+		return fmt.Errorf("failed to encode IP location: %w", err)
+	}
+
+	key := redisClusterKey(ip)
+	err = s.withRetry("SET "+key, func() error {
+		_, err := s.client.Pipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(s.ctx, key, data, 0)
+			if country != "" {
+				pipe.SAdd(s.ctx, redisCountriesKey, country)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store in Redis: %w", err)
+	}
+
+	return nil
+}
+
+// SetRange adds or replaces the CIDR block cidr (e.g. 1.2.3.0/24 or 2001:db8::/32), like RedisStore.SetRange
+func (s *RedisClusterStore) SetRange(cidr, city, country string) error {
+	return setRedisCIDR(s.ctx, s.client, s.withRetry, cidr, models.IPLocation{City: city, Country: country})
+}
+
+// RangeFindByIP looks up the CIDR block containing ip, on the shard owning the ranges sorted set
+// Implements the RangeFinder interface
+func (s *RedisClusterStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return findRedisRange(ctx, s.client, s.withRetry, ip)
+}
+
+// LoadFromCSV loads data from a CSV file into the cluster
+// Each write is retried on transient errors, so a failover doesn't abort the whole load
+// Ranges of a range mode file and CIDR blocks go to the ranges sorted sets
+func (s *RedisClusterStore) LoadFromCSV(csvPath string) error {
+	csvStore, err := NewCSVStore(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to load CSV: %w", err)
+	}
+	defer csvStore.Close()
+
+	count := 0
+	for ip, location := range csvStore.data {
+		if err := s.Set(ip, location.City, location.Country); err != nil {
+			return fmt.Errorf("failed to store IP %s: %w", ip, err)
+		}
+		count++
+	}
+	n, err := writeRedisRanges(s.ctx, s.client, s.withRetry, csvStore)
+	count += n
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d IP records into Redis Cluster\n", count)
+	return nil
+}
+
+// IsEmpty checks if the cluster has any IP data
+// SCAN only covers the node it's sent to, so every shard is scanned (in parallel)
+func (s *RedisClusterStore) IsEmpty() (bool, error) {
+	var found atomic.Bool
+	err := s.client.ForEachShard(s.ctx, func(ctx context.Context, shard *redis.Client) error {
+		iter := shard.Scan(ctx, 0, "ip:*", 100).Iterator()
+		if iter.Next(ctx) {
+			found.Store(true)
+		}
+		return iter.Err()
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check Redis keys: %w", err)
+	}
+	return !found.Load(), nil
+}
+
+// HealthCheck pings every shard
+// Implements the HealthChecker interface
+func (s *RedisClusterStore) HealthCheck(ctx context.Context) error {
+	return s.client.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+		return shard.Ping(ctx).Err()
+	})
+}
+
+// Close closes the connections to every node
+func (s *RedisClusterStore) Close() error {
+	if s.client != nil {
+		return s.client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// setupRedisClusterStore creates a cluster store over miniredis, which answers CLUSTER SLOTS
+// as a single node owning every slot, so all requests go through cluster routing
+func setupRedisClusterStore(t *testing.T) (*RedisClusterStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	store, err := NewRedisClusterStore([]string{mr.Addr()}, "")
+	if err != nil {
+		t.Fatalf("failed to connect to Redis Cluster: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store, mr
+}
+
+// TestRedisClusterStore_NoAddrs tests that at least one address is required
+func TestRedisClusterStore_NoAddrs(t *testing.T) {
+	if _, err := NewRedisClusterStore(nil, ""); err == nil {
+		t.Error("expected error for no addresses, got nil")
+	}
+}
+
+// TestRedisClusterStore_ConnectionFailure tests connecting to an unreachable cluster
+func TestRedisClusterStore_ConnectionFailure(t *testing.T) {
+	if _, err := NewRedisClusterStore([]string{"localhost:9999"}, ""); err == nil {
+		t.Error("expected error for invalid address, got nil")
+	}
+}
+
+// TestRedisClusterStore_SetAndFindByIP tests a round trip through cluster routing
+func TestRedisClusterStore_SetAndFindByIP(t *testing.T) {
+	store, _ := setupRedisClusterStore(t)
+
+	for _, ip := range []string{"8.8.8.8", "2001:4860:4860::8888"} {
+		if err := store.Set(ip, "Mountain View", "United States"); err != nil {
+			t.Fatalf("failed to set %s: %v", ip, err)
+		}
+
+		location, err := store.FindByIP(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", ip, err)
+		}
+		if location.IP != ip || location.City != "Mountain View" || location.Country != "United States" {
+			t.Errorf("unexpected location for %s: %+v", ip, location)
+		}
+	}
+}
+
+// TestRedisClusterStore_FindByIP_NotFound tests lookup of a missing IP
+func TestRedisClusterStore_FindByIP_NotFound(t *testing.T) {
+	store, _ := setupRedisClusterStore(t)
+
+	location, err := store.FindByIP(context.Background(), "203.0.113.1")
+
+	if !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+	if location != nil {
+		t.Errorf("expected nil location, got %+v", location)
+	}
+}
+
+// TestRedisClusterStore_KeyFormat tests that keys are hash tagged on the IP
+func TestRedisClusterStore_KeyFormat(t *testing.T) {
+	store, mr := setupRedisClusterStore(t)
+
+	if err := store.Set("8.8.8.8", "Mountain View", "United States"); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if !mr.Exists("ip:{8.8.8.8}") {
+		t.Errorf("expected key 'ip:{8.8.8.8}', got keys %v", mr.Keys())
+	}
+	if members, _ := mr.Members(redisCountriesKey); len(members) != 1 || members[0] != "United States" {
+		t.Errorf("expected the country to be indexed, got %v", members)
+	}
+}
+
+// TestRedisClusterStore_IsEmpty tests the per-shard scan before and after a write
+func TestRedisClusterStore_IsEmpty(t *testing.T) {
+	store, mr := setupRedisClusterStore(t)
+
+	// Keys that aren't IP records don't count
+	mr.Set("meta:data_version", "v1")
+
+	empty, err := store.IsEmpty()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !empty {
+		t.Error("expected an empty cluster")
+	}
+
+	store.Set("8.8.8.8", "Mountain View", "United States")
+
+	empty, err = store.IsEmpty()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if empty {
+		t.Error("expected a non-empty cluster after Set")
+	}
+}
+
+// TestRedisClusterStore_LoadFromCSV tests that every row is loaded and found again
+func TestRedisClusterStore_LoadFromCSV(t *testing.T) {
+	store, _ := setupRedisClusterStore(t)
+
+	csvPath := filepath.Join(t.TempDir(), "test.csv")
+	content := "ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n"
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	if err := store.LoadFromCSV(csvPath); err != nil {
+		t.Fatalf("failed to load CSV: %v", err)
+	}
+
+	for ip, city := range map[string]string{"8.8.8.8": "Mountain View", "1.1.1.1": "Sydney"} {
+		location, err := store.FindByIP(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", ip, err)
+		}
+		if location.City != city {
+			t.Errorf("expected %s for %s, got %s", city, ip, location.City)
+		}
+	}
+}
+
+// TestRedisClusterStore_RangeFindByIP tests IPv4 and IPv6 blocks set through cluster routing and loaded from a CSV file
+func TestRedisClusterStore_RangeFindByIP(t *testing.T) {
+	store, _ := setupRedisClusterStore(t)
+
+	if err := store.SetRange("1.2.3.0/24", "Berlin", "Germany"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SetRange("1.2.3.0/33", "Nowhere", "Nowhere"); err == nil {
+		t.Error("expected an error for an invalid block, got nil")
+	}
+
+	csvPath := filepath.Join(t.TempDir(), "ranges.csv")
+	content := "ip,city,country\n8.8.8.8,Mountain View,United States\n2001:db8::/32,Documentation,Documentation\n"
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	if err := store.LoadFromCSV(csvPath); err != nil {
+		t.Fatalf("failed to load CSV: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		city string
+	}{
+		{"1.2.3.4", "Berlin"},
+		{"2001:db8::1", "Documentation"},
+		{"1.2.4.0", ""},
+		{"2001:db9::1", ""},
+	}
+	for _, tt := range tests {
+		location, err := store.RangeFindByIP(context.Background(), tt.ip)
+		if tt.city == "" {
+			if !errors.Is(err, pkerr.ErrNotFound) {
+				t.Errorf("%s: expected ErrNotFound, got %v", tt.ip, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.ip, err)
+			continue
+		}
+		if location.IP != tt.ip || location.City != tt.city {
+			t.Errorf("%s: expected %s, got %+v", tt.ip, tt.city, location)
+		}
+	}
+}
+
+// TestRedisClusterStore_HealthCheck tests that the check passes while the cluster is up and fails once it's down
+func TestRedisClusterStore_HealthCheck(t *testing.T) {
+	store, mr := setupRedisClusterStore(t)
+
+	if err := store.HealthCheck(context.Background()); err != nil {
+		t.Errorf("expected a healthy cluster, got %v", err)
+	}
+
+	mr.Close()
+	if err := store.HealthCheck(context.Background()); err == nil {
+		t.Error("expected the check to fail with the cluster down, got nil")
+	}
+
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisRangesKey is a sorted set of IPv4 CIDR blocks, scored by network start (read by RangeFindByIP)
+// An IPv4 address fits a float64 score exactly, so scores compare like the addresses
+const redisRangesKey = "ip_ranges"
+
+// redisIPv6RangesKey is a sorted set of IPv6 CIDR blocks (read by RangeFindByIP)
+// An IPv6 address doesn't fit a float64 score, so every member is scored 0 and starts with the network
+// start as 32 hex digits: members sort by their bytes, which orders them like the addresses
+const redisIPv6RangesKey = "ip_ranges6"
+
+// redisRange is a member of the IPv4 ranges sorted set
+// The start is part of the member so two blocks never collide as the same member
+type redisRange struct {
+	Start         uint32 `json:"start"`
+	End           uint32 `json:"end"`
+	City          string `json:"city"`
+	Country       string `json:"country"`
+	CountryCode   string `json:"country_code,omitempty"`
+	ContinentCode string `json:"continent_code,omitempty"`
+}
+
+// redisIPv6Range is the JSON part of a member of the IPv6 ranges sorted set, after "<start>:"
+// Addresses are 32 hex digits, as written by redisIPv6Hex
+type redisIPv6Range struct {
+	End           string `json:"end"`
+	City          string `json:"city"`
+	Country       string `json:"country"`
+	CountryCode   string `json:"country_code,omitempty"`
+	ContinentCode string `json:"continent_code,omitempty"`
+}
+
+// newRedisRange returns the member for the addresses from start to end located at location
+func newRedisRange(start, end uint32, location models.IPLocation) redisRange {
+	return redisRange{
+		Start:         start,
+		End:           end,
+		City:          location.City,
+		Country:       location.Country,
+		CountryCode:   location.CountryCode,
+		ContinentCode: location.ContinentCode,
+	}
+}
+
+// redisIPv6Hex returns the 32 hex digits of an ipv6ToKey key, the form IPv6 range members use
+func redisIPv6Hex(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
+
+// redisRetryFunc runs a Redis operation under a store's retry policy (RedisStore.withRetry, RedisClusterStore.withRetry)
+type redisRetryFunc func(op string, fn func() error) error
+
+// SetRange adds or replaces the CIDR block cidr (e.g. 1.2.3.0/24 or 2001:db8::/32)
+// A block replaces any other block with the same network start. Unlike IPs, blocks never expire
+func (s *RedisStore) SetRange(cidr, city, country string) error {
+	return setRedisCIDR(s.ctx, s.client, s.withRetry, cidr, models.IPLocation{City: city, Country: country})
+}
+
+// setRedisCIDR writes the CIDR block cidr located at location with client, retrying on transient errors
+func setRedisCIDR(ctx context.Context, client redis.Cmdable, withRetry redisRetryFunc, cidr string, location models.IPLocation) error {
+	if !isValidCIDR(cidr) {
+		return fmt.Errorf("invalid CIDR block %q", cidr)
+	}
+	return pipelineRange(ctx, client, withRetry, func(pipe redis.Pipeliner) error {
+		return queueSetCIDR(ctx, pipe, cidr, location)
+	})
+}
+
+// pipelineRange runs the range writes queued by queue in one pipeline, retrying on transient errors
+func pipelineRange(ctx context.Context, client redis.Cmdable, withRetry redisRetryFunc, queue func(pipe redis.Pipeliner) error) error {
+	err := withRetry("ZADD ranges", func() error {
+		_, err := client.Pipelined(ctx, queue)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store range in Redis: %w", err)
+	}
+	return nil
+}
+
+// writeRedisRanges writes the IPv4 ranges and IPv6 blocks of csvStore with client, returning how many were written
+func writeRedisRanges(ctx context.Context, client redis.Cmdable, withRetry redisRetryFunc, csvStore *CSVStore) (int, error) {
+	count := 0
+	for _, r := range csvStore.ranges {
+		err := pipelineRange(ctx, client, withRetry, func(pipe redis.Pipeliner) error {
+			return queueSetRange(ctx, pipe, newRedisRange(r.start, r.end, r.location))
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	for _, r := range csvStore.ranges6 {
+		err := pipelineRange(ctx, client, withRetry, func(pipe redis.Pipeliner) error {
+			return queueSetIPv6Range(ctx, pipe, r.start, r.end, r.location)
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// isValidCIDR reports whether cidr is an IPv4 or IPv6 CIDR block queueSetCIDR can write
+func isValidCIDR(cidr string) bool {
+	if _, _, ok := parseCIDRRange(cidr); ok {
+		return true
+	}
+	_, _, ok := parseIPv6CIDRRange(cidr)
+	return ok
+}
+
+// queueSetCIDR queues the writes of the CIDR block cidr located at location on pipe,
+// to the IPv4 or the IPv6 ranges sorted set. Returns an error, queueing nothing, for an invalid block
+func queueSetCIDR(ctx context.Context, pipe redis.Pipeliner, cidr string, location models.IPLocation) error {
+	if start, end, ok := parseCIDRRange(cidr); ok {
+		return queueSetRange(ctx, pipe, newRedisRange(start, end, location))
+	}
+	if start, end, ok := parseIPv6CIDRRange(cidr); ok {
+		return queueSetIPv6Range(ctx, pipe, start, end, location)
+	}
+	return fmt.Errorf("invalid CIDR block %q", cidr)
+}
+
+// queueSetRange queues the commands writing r on pipe: removing the block with the same start,
+// adding r, and adding its country to the countries index
+func queueSetRange(ctx context.Context, pipe redis.Pipeliner, r redisRange) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode IP range: %w", err)
+	}
+
+	score := strconv.FormatUint(uint64(r.Start), 10)
+	pipe.ZRemRangeByScore(ctx, redisRangesKey, score, score)
+	pipe.ZAdd(ctx, redisRangesKey, redis.Z{Score: float64(r.Start), Member: data})
+	if r.Country != "" {
+		pipe.SAdd(ctx, redisCountriesKey, r.Country)
+	}
+	return nil
+}
+
+// queueSetIPv6Range queues the commands writing the IPv6 block from start to end (ipv6ToKey keys) on pipe,
+// like queueSetRange: removing the block with the same start, adding it, and adding its country
+func queueSetIPv6Range(ctx context.Context, pipe redis.Pipeliner, start, end string, location models.IPLocation) error {
+	data, err := json.Marshal(redisIPv6Range{
+		End:           redisIPv6Hex(end),
+		City:          location.City,
+		Country:       location.Country,
+		CountryCode:   location.CountryCode,
+		ContinentCode: location.ContinentCode,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode IP range: %w", err)
+	}
+
+	// ';' sorts right after ':', so [<start>: to (<start>; covers exactly the members of this start
+	prefix := redisIPv6Hex(start)
+	pipe.ZRemRangeByLex(ctx, redisIPv6RangesKey, "["+prefix+":", "("+prefix+";")
+	pipe.ZAdd(ctx, redisIPv6RangesKey, redis.Z{Score: 0, Member: prefix + ":" + string(data)})
+	if location.Country != "" {
+		pipe.SAdd(ctx, redisCountriesKey, location.Country)
+	}
+	return nil
+}
+
+// RangeFindByIP looks up the CIDR block containing ip
+// Implements the RangeFinder interface
+func (s *RedisStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return findRedisRange(ctx, s.client, s.withRetry, ip)
+}
+
+// findRedisRange looks up the CIDR block containing ip with client, in the IPv4 or the IPv6 ranges sorted set
+//
+// Redis commands: the block with the greatest start <= ip, which contains ip if it ends at or after it
+//   - IPv4: ZREVRANGEBYSCORE ip_ranges <ip> -inf LIMIT 0 1
+//   - IPv6: ZREVRANGEBYLEX ip_ranges6 (<ip>; - LIMIT 0 1
+func findRedisRange(ctx context.Context, client redis.Cmdable, withRetry redisRetryFunc, ip string) (*models.IPLocation, error) {
+	if n, ok := ipv4ToUint32(ip); ok {
+		return findRedisIPv4Range(ctx, client, withRetry, ip, n)
+	}
+	if key, ok := ipv6ToKey(ip); ok {
+		return findRedisIPv6Range(ctx, client, withRetry, ip, redisIPv6Hex(key))
+	}
+	return nil, pkerr.ErrNotFound
+}
+
+// findRedisIPv4Range looks up the block containing ip, n in integer form, in the IPv4 ranges sorted set
+func findRedisIPv4Range(ctx context.Context, client redis.Cmdable, withRetry redisRetryFunc, ip string, n uint32) (*models.IPLocation, error) {
+	var members []string
+	err := withRetry("ZREVRANGEBYSCORE "+redisRangesKey, func() error {
+		var err error
+		members, err = client.ZRevRangeByScore(ctx, redisRangesKey, &redis.ZRangeBy{
+			Max:   strconv.FormatUint(uint64(n), 10),
+			Min:   "-inf",
+			Count: 1,
+		}).Result()
+		return err
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("Redis query failed: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, pkerr.ErrNotFound
+	}
+
+	var r redisRange
+	if err := json.Unmarshal([]byte(members[0]), &r); err != nil {
+		return nil, fmt.Errorf("failed to decode IP range: %w", err)
+	}
+	if n > r.End {
+		return nil, pkerr.ErrNotFound
+	}
+
+	return &models.IPLocation{
+		IP:            ip,
+		City:          r.City,
+		Country:       r.Country,
+		CountryCode:   r.CountryCode,
+		ContinentCode: r.ContinentCode,
+	}, nil
+}
+
+// findRedisIPv6Range looks up the block containing ip, ipHex in redisIPv6Hex form, in the IPv6 ranges sorted set
+func findRedisIPv6Range(ctx context.Context, client redis.Cmdable, withRetry redisRetryFunc, ip, ipHex string) (*models.IPLocation, error) {
+	var members []string
+	err := withRetry("ZREVRANGEBYLEX "+redisIPv6RangesKey, func() error {
+		var err error
+		members, err = client.ZRevRangeByLex(ctx, redisIPv6RangesKey, &redis.ZRangeBy{
+			Max:   "(" + ipHex + ";",
+			Min:   "-",
+			Count: 1,
+		}).Result()
+		return err
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("Redis query failed: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, pkerr.ErrNotFound
+	}
+
+	_, data, ok := strings.Cut(members[0], ":")
+	if !ok {
+		return nil, fmt.Errorf("failed to decode IP range: no start address in %q", members[0])
+	}
+	var r redisIPv6Range
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		return nil, fmt.Errorf("failed to decode IP range: %w", err)
+	}
+	// Same length hex digits compare like the addresses
+	if ipHex > r.End {
+		return nil, pkerr.ErrNotFound
+	}
+
+	return &models.IPLocation{
+		IP:            ip,
+		City:          r.City,
+		Country:       r.Country,
+		CountryCode:   r.CountryCode,
+		ContinentCode: r.ContinentCode,
+	}, nil
+}
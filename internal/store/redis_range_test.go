@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// TestRedisStore_RangeFindByIP tests lookups at the edges of, between and outside CIDR blocks
+func TestRedisStore_RangeFindByIP(t *testing.T) {
+	store := NewTestRedisStore(t)
+	for _, block := range []struct{ cidr, city string }{
+		{"1.2.3.0/24", "Berlin"},
+		{"1.2.5.0/24", "Paris"},
+		{"0.0.0.0/8", "This network"},
+		{"2001:db8::/32", "Documentation"},
+		{"2001:db9:1::/48", "Next block"},
+	} {
+		if err := store.SetRange(block.cidr, block.city, "Somewhere"); err != nil {
+			t.Fatalf("failed to set %s: %v", block.cidr, err)
+		}
+	}
+
+	tests := []struct {
+		ip   string
+		city string
+	}{
+		{"1.2.3.0", "Berlin"},
+		{"1.2.3.255", "Berlin"},
+		{"1.2.4.0", ""}, // Between the blocks
+		{"1.2.5.128", "Paris"},
+		{"1.2.6.0", ""}, // After the last block
+		{"0.0.0.1", "This network"},
+		{"2001:db8::1", "Documentation"},
+		{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", "Documentation"},
+		{"2001:db9::", ""}, // Between the blocks
+		{"2001:db9:1::", "Next block"},
+		{"2001:db9:2::", ""}, // After the last block
+		{"2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+		{"::ffff:1.2.3.4", "Berlin"}, // IPv4-mapped, looked up as IPv4
+	}
+
+	for _, tt := range tests {
+		location, err := store.RangeFindByIP(context.Background(), tt.ip)
+		if tt.city == "" {
+			if !errors.Is(err, pkerr.ErrNotFound) {
+				t.Errorf("%s: expected ErrNotFound, got %v", tt.ip, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.ip, err)
+			continue
+		}
+		if location.City != tt.city || location.IP != tt.ip {
+			t.Errorf("%s: expected %s, got %+v", tt.ip, tt.city, location)
+		}
+	}
+}
+
+// TestRedisStore_SetRange_Replaces tests that a block replaces the block with the same network start,
+// IPv4 or IPv6, and that invalid blocks are rejected
+func TestRedisStore_SetRange_Replaces(t *testing.T) {
+	store := NewTestRedisStore(t)
+	mr := TestingRedis(t)
+
+	if err := store.SetRange("1.2.3.0/24", "Berlin", "Germany"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SetRange("1.2.3.0/25", "Munich", "Germany"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	members, err := mr.ZMembers(redisRangesKey)
+	if err != nil || len(members) != 1 {
+		t.Fatalf("expected 1 block, got %v (%v)", members, err)
+	}
+	if _, err := store.RangeFindByIP(context.Background(), "1.2.3.200"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected the replaced /24 to be gone, got %v", err)
+	}
+
+	if err := store.SetRange("2001:db8::/32", "Documentation", "Documentation"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SetRange("2001:db8::/48", "Narrower", "Documentation"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	members, err = mr.ZMembers(redisIPv6RangesKey)
+	if err != nil || len(members) != 1 {
+		t.Fatalf("expected 1 IPv6 block, got %v (%v)", members, err)
+	}
+	if _, err := store.RangeFindByIP(context.Background(), "2001:db8:1::1"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected the replaced /32 to be gone, got %v", err)
+	}
+
+	for _, cidr := range []string{"1.2.3.0/33", "not-a-block", "2001:db8::/129"} {
+		if err := store.SetRange(cidr, "Nowhere", "Nowhere"); err == nil {
+			t.Errorf("%s: expected an error, got nil", cidr)
+		}
+	}
+}
+
+// TestRedisStore_BulkLoad_CIDR tests that BulkLoad, LoadFromCSV and BulkLoadCSVParallel write CIDR blocks
+// to the ranges sorted set
+func TestRedisStore_BulkLoad_CIDR(t *testing.T) {
+	store, mr := setupBulkRedis(t)
+
+	err := store.BulkLoad([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		{IP: "1.2.3.0/24", City: "Berlin", Country: "Germany"},
+		{IP: "2001:db8::/32", City: "Documentation", Country: "Documentation"},
+		{IP: "1.2.3.0/33", City: "Nowhere", Country: "Nowhere"}, // Skipped: invalid
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ranges.csv")
+	if err := os.WriteFile(path, []byte("ip,city,country\n5.6.0.0/16,Paris,France\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() {
+		if err := store.LoadFromCSV(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	parallelPath := filepath.Join(t.TempDir(), "parallel.csv")
+	if err := os.WriteFile(parallelPath, []byte("ip,city,country\n9.9.9.0/24,Zurich,Switzerland\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() {
+		if err := store.BulkLoadCSVParallel(parallelPath, 2); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	if keys := ipKeys(mr); len(keys) != 1 || keys[0] != "ip:8.8.8.8" {
+		t.Errorf("expected only ip:8.8.8.8, got %v", keys)
+	}
+	for ip, city := range map[string]string{"1.2.3.4": "Berlin", "5.6.7.8": "Paris", "9.9.9.9": "Zurich", "2001:db8::1": "Documentation"} {
+		location, err := store.RangeFindByIP(context.Background(), ip)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", ip, err)
+			continue
+		}
+		if location.City != city {
+			t.Errorf("%s: expected %s, got %s", ip, city, location.City)
+		}
+	}
+
+	countries, _ := store.ListCountries(context.Background())
+	if got := strings.Join(countries, ","); got != "Documentation,France,Germany,Switzerland,United States" {
+		t.Errorf("expected the blocks' countries indexed, got %s", got)
+	}
+}
@@ -3,9 +3,19 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/retry"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,8 +24,27 @@ import (
 type RedisStore struct {
 	client *redis.Client
 	ctx    context.Context
+
+	// retry controls retries of transient errors in FindByIP, Set and LoadFromCSV (see SetRetry)
+	retry retry.Config
+
+	// writeRPS limits BulkLoadCSVParallel writes per second (see SetWriteRPS). 0 = unlimited
+	writeRPS int
+
+	// ttl is the expiry of keys written by loads (see SetTTL). 0 = never expire
+	ttl time.Duration
+
+	// version caches the DataVersion so Stats doesn't query Redis on every response
+	// Set by loads through this store, and re-read every redisDataVersionRefresh for loads by other servers
+	version atomic.Pointer[string]
+
+	// stopRefresh stops the DataVersion refresh on Close
+	stopRefresh context.CancelFunc
 }
 
+// DefaultRedisOperationRetry is the operation retry policy of a new RedisStore: 3 attempts, 50ms then 100ms apart
+var DefaultRedisOperationRetry = retry.Config{MaxRetries: 2, BaseDelay: 50 * time.Millisecond}
+
 // NewRedisStore creates a new Redis store
 //
 // Parameters:
@@ -27,24 +56,92 @@ type RedisStore struct {
 //   - *RedisStore: pointer to the created store
 //   - error: any error that occurred during connection
 func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
+	return NewRedisStoreWithRetry(addr, password, db, retry.Config{})
+}
+
+// NewRedisStoreWithRetry creates a new Redis store, retrying the initial connection per retry
+func NewRedisStoreWithRetry(addr, password string, db int, retryConfig retry.Config) (*RedisStore, error) {
 	// Create Redis client
+	// The client's own retries are disabled - RedisStore retries transient errors itself (see SetRetry)
 	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
+		Addr:       addr,
+		Password:   password,
+		DB:         db,
+		MaxRetries: -1,
 	})
 
 	ctx := context.Background()
 
 	// Test the connection
-	if err := client.Ping(ctx).Err(); err != nil {
+	err := retry.Connect(func() error {
+		return client.Ping(ctx).Err()
+	}, retryConfig)
+	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisStore{
+	s := &RedisStore{
 		client: client,
 		ctx:    ctx,
-	}, nil
+		retry:  DefaultRedisOperationRetry,
+	}
+	s.refreshDataVersion()
+	refreshCtx, stopRefresh := context.WithCancel(ctx)
+	s.stopRefresh = stopRefresh
+	go s.watchDataVersion(refreshCtx, redisDataVersionRefresh)
+	return s, nil
+}
+
+// SetRetry sets how transient errors are retried by FindByIP, Set and LoadFromCSV
+// The zero value disables retries
+func (s *RedisStore) SetRetry(retryConfig retry.Config) {
+	s.retry = retryConfig
+}
+
+// SetWriteRPS limits how many IPs per second BulkLoadCSVParallel writes, across all its workers
+// Zero (the default) disables the limit
+func (s *RedisStore) SetWriteRPS(rps int) {
+	s.writeRPS = rps
+}
+
+// SetTTL makes IPs written by LoadFromCSV and the bulk loads expire after ttl, so they're
+// re-loaded from the canonical source once stale. Zero (the default) keeps them forever
+func (s *RedisStore) SetTTL(ttl time.Duration) {
+	s.ttl = ttl
+}
+
+// isRetryableRedisError reports whether err is transient: a network error, or a
+// LOADING (dataset still loading after a restart) or BUSY (script running) reply
+// redis.Nil (key not found) and every other Redis reply are permanent
+func isRetryableRedisError(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return false
+	}
+
+	// The caller gave up (e.g. the client disconnected): retrying can't help.
+	// Checked before net.Error, which context.DeadlineExceeded also implements
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		msg := redisErr.Error()
+		return strings.HasPrefix(msg, "LOADING ") || strings.HasPrefix(msg, "BUSY ")
+	}
+
+	return false
+}
+
+// withRetry runs a Redis operation under the store's retry policy
+func (s *RedisStore) withRetry(op string, fn func() error) error {
+	return retry.Do(op, fn, isRetryableRedisError, s.retry)
 }
 
 // FindByIP looks up an IP address in Redis
@@ -53,16 +150,21 @@ func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
 // Redis Key Format: ip:<ip_address>
 // Example: ip:8.8.8.8
 // Value: JSON-encoded IPLocation
-func (s *RedisStore) FindByIP(ip string) (*models.IPLocation, error) {
+func (s *RedisStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
 	// Build Redis key
 	key := fmt.Sprintf("ip:%s", ip)
 
 	// Get value from Redis
-	val, err := s.client.Get(s.ctx, key).Result()
+	var val string
+	err := s.withRetry("GET "+key, func() error {
+		var err error
+		val, err = s.client.Get(ctx, key).Result()
+		return err
+	})
 	if err != nil {
-		if err == redis.Nil {
+		if errors.Is(err, redis.Nil) {
 			// Key does not exist
-			return nil, fmt.Errorf("IP address not found")
+			return nil, pkerr.ErrNotFound
 		}
 		// Other Redis errors
 		return nil, fmt.Errorf("Redis query failed: %w", err)
@@ -88,12 +190,17 @@ func (s *RedisStore) FindByIP(ip string) (*models.IPLocation, error) {
 //   - city: the city name
 //   - country: the country name
 func (s *RedisStore) Set(ip, city, country string) error {
-	location := models.IPLocation{
-		IP:      ip,
-		City:    city,
-		Country: country,
-	}
+	return s.SetWithTTL(ip, city, country, 0)
+}
+
+// SetWithTTL adds or updates an IP address in Redis, expiring it after ttl (0 = never)
+// The country stays in the countries index after the IP expires
+func (s *RedisStore) SetWithTTL(ip, city, country string, ttl time.Duration) error {
+	return s.setLocation(models.IPLocation{IP: ip, City: city, Country: country}, ttl)
+}
 
+// setLocation writes location under its IP with all its fields, expiring it after ttl (0 = never)
+func (s *RedisStore) setLocation(location models.IPLocation, ttl time.Duration) error {
 	// Encode to JSON
 	data, err := json.Marshal(location)
 	if err != nil {
@@ -101,10 +208,20 @@ func (s *RedisStore) Set(ip, city, country string) error {
 	}
 
 	// Build Redis key
-	key := fmt.Sprintf("ip:%s", ip)
+	key := fmt.Sprintf("ip:%s", location.IP)
 
-	// Store in Redis (no expiration)
-	if err := s.client.Set(s.ctx, key, data, 0).Err(); err != nil {
+	// Store in Redis, adding the country to the countries index in the same round trip
+	err = s.withRetry("SET "+key, func() error {
+		_, err := s.client.Pipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(s.ctx, key, data, ttl)
+			if location.Country != "" {
+				pipe.SAdd(s.ctx, redisCountriesKey, location.Country)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("failed to store in Redis: %w", err)
 	}
 
@@ -113,6 +230,8 @@ func (s *RedisStore) Set(ip, city, country string) error {
 
 // LoadFromCSV loads data from a CSV file into Redis
 // This is useful for initial data population
+// Each write is retried on transient errors, so a Redis blip doesn't abort the whole load
+// IPs expire after the store's TTL, if set (see SetTTL)
 func (s *RedisStore) LoadFromCSV(csvPath string) error {
 	// Create a temporary CSV store to read the data
 	csvStore, err := NewCSVStore(csvPath)
@@ -124,30 +243,254 @@ func (s *RedisStore) LoadFromCSV(csvPath string) error {
 	// Iterate through all IPs in the CSV store and add to Redis
 	count := 0
 	for ip, location := range csvStore.data {
-		if err := s.Set(ip, location.City, location.Country); err != nil {
+		if err := s.setLocation(*location, s.ttl); err != nil {
 			return fmt.Errorf("failed to store IP %s: %w", ip, err)
 		}
 		count++
 	}
+	// Ranges of a range mode file and CIDR blocks (these never expire)
+	n, err := writeRedisRanges(s.ctx, s.client, s.withRetry, csvStore)
+	count += n
+	if err != nil {
+		return err
+	}
+	if err := s.recordDataVersion(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d IP records into Redis\n", count)
 	return nil
 }
 
+// redisBulkDeleteBatchSize is the number of DEL commands sent per pipeline
+const redisBulkDeleteBatchSize = 500
+
+// BulkDelete deletes the keys for ips, pipelining DEL commands in batches
+// Implements the BulkDeleter interface; returns how many keys existed
+// Their countries stay in the countries index, like any country no IP references any more
+func (s *RedisStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	deleted := 0
+	for batch := range slices.Chunk(ips, redisBulkDeleteBatchSize) {
+		pipe := s.client.Pipeline()
+		cmds := make([]*redis.IntCmd, len(batch))
+		for i, ip := range batch {
+			cmds[i] = pipe.Del(ctx, fmt.Sprintf("ip:%s", ip))
+		}
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			return deleted, fmt.Errorf("failed to delete batch from Redis: %w", err)
+		}
+		for _, cmd := range cmds {
+			deleted += int(cmd.Val())
+		}
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, s.recordDataVersion()
+}
+
+// redisCountriesKey is a set of every country written by Set or a bulk load (read by ListCountries)
+// Countries are never removed from it, since another IP may still reference them
+const redisCountriesKey = "countries"
+
+// ListCountries returns the countries in the countries index, sorted alphabetically
+// Implements the CountryLister interface
+// Data loaded before the index existed has an empty set, so the keys are scanned instead
+func (s *RedisStore) ListCountries(ctx context.Context) ([]string, error) {
+	countries, err := s.client.SMembers(ctx, redisCountriesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Redis query failed: %w", err)
+	}
+
+	if len(countries) == 0 {
+		var locations []*models.IPLocation
+		err := s.Iterate(func(location *models.IPLocation) error {
+			locations = append(locations, location)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return distinctCountries(slices.Values(locations)), nil
+	}
+
+	sort.Strings(countries)
+	return countries, nil
+}
+
+// Search returns the records matching query, ordered by IP
+// Implements the Searcher interface. There's no index by country or city: every ip:* key is scanned
+// and decoded (see Iterate), so a search costs as much as a full scan whatever the page size
+func (s *RedisStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	var locations []*models.IPLocation
+	err := s.Iterate(func(location *models.IPLocation) error {
+		locations = append(locations, location)
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return searchLocations(slices.Values(locations), query), nil
+}
+
+// Count returns the number of ip:* keys
+// Implements the Counter interface. The keys are scanned rather than read with DBSIZE,
+// since the same database holds the countries index and metadata; values aren't fetched
+func (s *RedisStore) Count(ctx context.Context) (int, error) {
+	count := 0
+	iter := s.client.Scan(ctx, 0, "ip:*", 1000).Iterator()
+	for iter.Next(ctx) {
+		count++
+	}
+	if err := iter.Err(); err != nil {
+		return 0, fmt.Errorf("Redis scan failed: %w", err)
+	}
+	return count, nil
+}
+
+// redisDataVersionKey holds the DataVersion of the last load (shared by every server using this Redis)
+const redisDataVersionKey = "meta:data_version"
+
+// redisDataVersionRefresh is how often the cached DataVersion is re-read, so loads by other servers show up
+const redisDataVersionRefresh = 30 * time.Second
+
+// recordDataVersion stores a new DataVersion after a load
+// Derived from the load time in nanoseconds so two loads within the same second still differ
+func (s *RedisStore) recordDataVersion() error {
+	version := dataVersion(strconv.FormatInt(time.Now().UnixNano(), 10))
+	err := s.withRetry("SET "+redisDataVersionKey, func() error {
+		return s.client.Set(s.ctx, redisDataVersionKey, version, 0).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store data version: %w", err)
+	}
+	s.version.Store(&version)
+	return nil
+}
+
+// refreshDataVersion re-reads the DataVersion from Redis into the cache
+// A missing key clears the cache; other errors keep the previous version
+func (s *RedisStore) refreshDataVersion() {
+	version, err := s.client.Get(s.ctx, redisDataVersionKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return
+	}
+	s.version.Store(&version)
+}
+
+// watchDataVersion refreshes the cached DataVersion every interval until ctx is cancelled
+func (s *RedisStore) watchDataVersion(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshDataVersion()
+		}
+	}
+}
+
+// Stats returns the DataVersion of the last load, as recorded in Redis so every server reports the same value
+// Implements the StatsProvider interface. Served from memory: loads by other servers show up within
+// redisDataVersionRefresh. DataVersion is empty if no load was recorded
+func (s *RedisStore) Stats() StoreStats {
+	if version := s.version.Load(); version != nil {
+		return StoreStats{DataVersion: *version}
+	}
+	return StoreStats{}
+}
+
+// Iterate calls fn for each IP record in Redis
+// Implements the Iterator interface
+//
+// Uses SCAN rather than KEYS so large datasets are streamed in batches
+// without blocking the Redis server
+func (s *RedisStore) Iterate(fn func(location *models.IPLocation) error) error {
+	iter := s.client.Scan(s.ctx, 0, "ip:*", 100).Iterator()
+	for iter.Next(s.ctx) {
+		key := iter.Val()
+
+		val, err := s.client.Get(s.ctx, key).Result()
+		if err != nil {
+			if err == redis.Nil {
+				// Key was deleted between SCAN and GET
+				continue
+			}
+			return fmt.Errorf("Redis query failed: %w", err)
+		}
+
+		var location models.IPLocation
+		if err := json.Unmarshal([]byte(val), &location); err != nil {
+			return fmt.Errorf("failed to decode IP location: %w", err)
+		}
+		location.IP = strings.TrimPrefix(key, "ip:")
+
+		if err := fn(&location); err != nil {
+			return err
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan Redis keys: %w", err)
+	}
+	return nil
+}
+
 // IsEmpty checks if Redis has any IP data
-// Returns true if no keys with "ip:" prefix exist
+// Returns true if no keys with "ip:" prefix exist, which is also the case once every key has expired (see SetTTL)
+// Scans until the first key rather than listing them all with KEYS, which blocks Redis on a large dataset
 func (s *RedisStore) IsEmpty() (bool, error) {
-	// Check if any keys with "ip:" prefix exist
-	keys, err := s.client.Keys(s.ctx, "ip:*").Result()
-	if err != nil {
+	iter := s.client.Scan(s.ctx, 0, "ip:*", 100).Iterator()
+	if iter.Next(s.ctx) {
+		return false, nil
+	}
+	if err := iter.Err(); err != nil {
 		return false, fmt.Errorf("failed to check Redis keys: %w", err)
 	}
-	return len(keys) == 0, nil
+	return true, nil
+}
+
+// redisWarmupSampleSize is the number of keys prefetched by Warmup
+const redisWarmupSampleSize = 100
+
+// Warmup checks the connection and prefetches a sample of keys
+// Implements the WarmableStore interface
+func (s *RedisStore) Warmup(ctx context.Context) error {
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("Redis ping failed: %w", err)
+	}
+
+	keys, _, err := s.client.Scan(ctx, 0, "ip:*", redisWarmupSampleSize).Result()
+	if err != nil {
+		return fmt.Errorf("failed to scan Redis keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := s.client.MGet(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to prefetch Redis keys: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck pings Redis
+// Implements the HealthChecker interface
+func (s *RedisStore) HealthCheck(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
 }
 
 // Close closes the Redis connection
 // Should be called when the application shuts down
 func (s *RedisStore) Close() error {
+	if s.stopRefresh != nil {
+		s.stopRefresh()
+	}
 	if s.client != nil {
 		return s.client.Close()
 	}
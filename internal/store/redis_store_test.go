@@ -1,9 +1,22 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/retry"
+	"github.com/redis/go-redis/v9"
 )
 
 // TestRedisStore_Connection tests Redis connection
@@ -52,7 +65,7 @@ func TestRedisStore_FindByIP_Success(t *testing.T) {
 	}
 
 	// Lookup
-	location, err := store.FindByIP("8.8.8.8")
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -76,7 +89,7 @@ func TestRedisStore_FindByIP_NotFound(t *testing.T) {
 	store, _ := NewRedisStore(mr.Addr(), "", 0)
 	defer store.Close()
 
-	location, err := store.FindByIP("192.168.1.1")
+	location, err := store.FindByIP(context.Background(), "192.168.1.1")
 
 	if err == nil {
 		t.Error("expected not found error, got nil")
@@ -84,7 +97,7 @@ func TestRedisStore_FindByIP_NotFound(t *testing.T) {
 	if location != nil {
 		t.Error("expected nil location, got data")
 	}
-	if err.Error() != "IP address not found" {
+	if !errors.Is(err, pkerr.ErrNotFound) {
 		t.Errorf("expected 'IP address not found', got '%s'", err.Error())
 	}
 }
@@ -116,7 +129,7 @@ func TestRedisStore_Set(t *testing.T) {
 			}
 
 			// Verify data was stored correctly
-			location, err := store.FindByIP(tt.ip)
+			location, err := store.FindByIP(context.Background(), tt.ip)
 			if err != nil {
 				t.Fatalf("failed to retrieve stored data: %v", err)
 			}
@@ -148,7 +161,7 @@ func TestRedisStore_Set_Update(t *testing.T) {
 	}
 
 	// Verify data was updated
-	location, _ := store.FindByIP("8.8.8.8")
+	location, _ := store.FindByIP(context.Background(), "8.8.8.8")
 	if location.City != "San Francisco" {
 		t.Errorf("expected city 'San Francisco', got '%s'", location.City)
 	}
@@ -269,17 +282,17 @@ func TestRedisStore_MultipleIPsIndependent(t *testing.T) {
 	store.Set("9.9.9.9", "Berkeley", "United States")
 
 	// Verify each one independently
-	loc1, _ := store.FindByIP("8.8.8.8")
+	loc1, _ := store.FindByIP(context.Background(), "8.8.8.8")
 	if loc1.City != "Mountain View" {
 		t.Errorf("IP 8.8.8.8: expected 'Mountain View', got '%s'", loc1.City)
 	}
 
-	loc2, _ := store.FindByIP("1.1.1.1")
+	loc2, _ := store.FindByIP(context.Background(), "1.1.1.1")
 	if loc2.City != "Sydney" {
 		t.Errorf("IP 1.1.1.1: expected 'Sydney', got '%s'", loc2.City)
 	}
 
-	loc3, _ := store.FindByIP("9.9.9.9")
+	loc3, _ := store.FindByIP(context.Background(), "9.9.9.9")
 	if loc3.City != "Berkeley" {
 		t.Errorf("IP 9.9.9.9: expected 'Berkeley', got '%s'", loc3.City)
 	}
@@ -314,7 +327,7 @@ func TestRedisStore_SpecialCharacters(t *testing.T) {
 			}
 
 			// Retrieve and verify
-			location, err := store.FindByIP(tt.ip)
+			location, err := store.FindByIP(context.Background(), tt.ip)
 			if err != nil {
 				t.Fatalf("failed to retrieve data with special chars: %v", err)
 			}
@@ -346,7 +359,7 @@ func TestRedisStore_IPv6(t *testing.T) {
 				t.Fatalf("failed to set IPv6: %v", err)
 			}
 
-			location, err := store.FindByIP(ip)
+			location, err := store.FindByIP(context.Background(), ip)
 			if err != nil {
 				t.Fatalf("failed to retrieve IPv6: %v", err)
 			}
@@ -356,3 +369,563 @@ func TestRedisStore_IPv6(t *testing.T) {
 		})
 	}
 }
+
+// TestRedisStore_Iterate tests streaming every record with SCAN
+func TestRedisStore_Iterate(t *testing.T) {
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+
+	store, _ := NewRedisStore(mr.Addr(), "", 0)
+	defer store.Close()
+
+	expected := map[string]string{
+		"8.8.8.8":     "United States",
+		"1.1.1.1":     "Australia",
+		"2001:db8::1": "Test Country",
+	}
+	for ip, country := range expected {
+		store.Set(ip, "City", country)
+	}
+
+	// Non-IP keys must be ignored
+	mr.Set("ratelimit:1.2.3.4:1", "1")
+
+	seen := map[string]string{}
+	err := store.Iterate(func(location *models.IPLocation) error {
+		seen[location.IP] = location.Country
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(seen))
+	}
+	for ip, country := range expected {
+		if seen[ip] != country {
+			t.Errorf("expected %s -> %s, got %s", ip, country, seen[ip])
+		}
+	}
+}
+
+// TestRedisStore_BulkDelete tests that only existing keys are counted and other keys are kept
+func TestRedisStore_BulkDelete(t *testing.T) {
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+
+	store, _ := NewRedisStore(mr.Addr(), "", 0)
+	defer store.Close()
+
+	store.Set("8.8.8.8", "Mountain View", "United States")
+	store.Set("1.1.1.1", "Sydney", "Australia")
+
+	deleted, err := store.BulkDelete(context.Background(), []string{"8.8.8.8", "9.9.9.9"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d", deleted)
+	}
+	if mr.Exists("ip:8.8.8.8") {
+		t.Error("expected ip:8.8.8.8 to be deleted")
+	}
+	if !mr.Exists("ip:1.1.1.1") {
+		t.Error("expected ip:1.1.1.1 to be kept")
+	}
+	if store.Stats().DataVersion == "" {
+		t.Error("expected a delete to record a data version")
+	}
+}
+
+// TestRedisStore_BulkDelete_Empty tests that an empty list is a no-op
+func TestRedisStore_BulkDelete_Empty(t *testing.T) {
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+
+	store, _ := NewRedisStore(mr.Addr(), "", 0)
+	defer store.Close()
+
+	deleted, err := store.BulkDelete(context.Background(), []string{})
+	if err != nil || deleted != 0 {
+		t.Errorf("expected 0 deleted and no error, got %d, %v", deleted, err)
+	}
+	if mr.Exists(redisDataVersionKey) {
+		t.Error("expected no data version for a no-op delete")
+	}
+}
+
+// TestRedisStore_Warmup tests that warmup succeeds with and without data
+func TestRedisStore_Warmup(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Warmup(context.Background()); err != nil {
+		t.Errorf("unexpected error on empty store: %v", err)
+	}
+
+	store.Set("8.8.8.8", "Mountain View", "United States")
+	if err := store.Warmup(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestRedisStore_Warmup_ServerDown tests that warmup fails when Redis is unreachable
+func TestRedisStore_Warmup_ServerDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	defer store.Close()
+
+	mr.Close()
+
+	// Short deadline: go-redis would otherwise retry for a couple of seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := store.Warmup(ctx); err == nil {
+		t.Error("expected warmup error, got nil")
+	}
+}
+
+// TestRedisStore_DataVersion tests that the version changes on each load and is stable otherwise
+func TestRedisStore_DataVersion(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	defer store.Close()
+
+	if version := store.Stats().DataVersion; version != "" {
+		t.Errorf("expected empty version before any load, got %q", version)
+	}
+
+	locations := []*models.IPLocation{{IP: "8.8.8.8", City: "Mountain View", Country: "United States"}}
+	if err := store.BulkLoad(locations); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := store.Stats().DataVersion
+	if len(first) != 64 {
+		t.Fatalf("expected a SHA-256 hex version, got %q", first)
+	}
+	if stored, _ := mr.Get("meta:data_version"); stored != first {
+		t.Errorf("expected meta:data_version %q, got %q", first, stored)
+	}
+
+	// No load in between - every read returns the same version
+	for i := 0; i < 3; i++ {
+		if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if version := store.Stats().DataVersion; version != first {
+			t.Errorf("expected version to stay %q, got %q", first, version)
+		}
+	}
+
+	if err := store.BulkLoad(locations); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second := store.Stats().DataVersion; second == first {
+		t.Error("expected version to change after BulkLoad")
+	}
+}
+
+// TestRedisStore_DataVersion_Cached tests that Stats is served from memory, and that loads by another server show up on refresh
+func TestRedisStore_DataVersion_Cached(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	defer store.Close()
+
+	// Another server loads new data
+	other, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	defer other.Close()
+	if err := other.BulkLoad([]*models.IPLocation{{IP: "8.8.8.8", City: "Mountain View", Country: "United States"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded := other.Stats().DataVersion
+
+	if version := store.Stats().DataVersion; version != "" {
+		t.Errorf("expected the cached empty version until a refresh, got %q", version)
+	}
+	store.refreshDataVersion()
+	if version := store.Stats().DataVersion; version != loaded {
+		t.Errorf("expected %q after a refresh, got %q", loaded, version)
+	}
+
+	// No Redis round trip: the version survives Redis going away
+	mr.Close()
+	if version := store.Stats().DataVersion; version != loaded {
+		t.Errorf("expected the cached %q with Redis down, got %q", loaded, version)
+	}
+	store.refreshDataVersion()
+	if version := store.Stats().DataVersion; version != loaded {
+		t.Errorf("expected a failed refresh to keep %q, got %q", loaded, version)
+	}
+}
+
+// errorInjectionHook makes miniredis reply with msg to the first failures commands (or pipelines) sent by a client
+type errorInjectionHook struct {
+	mr       *miniredis.Miniredis
+	msg      string
+	failures int
+	calls    int
+}
+
+func (h *errorInjectionHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *errorInjectionHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.inject()
+		return next(ctx, cmd)
+	}
+}
+
+func (h *errorInjectionHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.inject()
+		return next(ctx, cmds)
+	}
+}
+
+// inject counts an attempt and sets or clears the miniredis error for it
+func (h *errorInjectionHook) inject() {
+	h.calls++
+	if h.calls <= h.failures {
+		h.mr.SetError(h.msg)
+	} else {
+		h.mr.SetError("")
+	}
+}
+
+// setupFailingRedisStore creates a Redis store whose first failures commands get msg as the reply
+func setupFailingRedisStore(t *testing.T, failures int, msg string) (*RedisStore, *errorInjectionHook) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	mr.Set("ip:8.8.8.8", `{"city":"Mountain View","country":"United States"}`)
+
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	hook := &errorInjectionHook{mr: mr, msg: msg, failures: failures}
+	store.client.AddHook(hook)
+	return store, hook
+}
+
+// TestRedisStore_Retry_SucceedsOnThirdAttempt tests that transient errors are retried with exponential backoff
+func TestRedisStore_Retry_SucceedsOnThirdAttempt(t *testing.T) {
+	store, hook := setupFailingRedisStore(t, 2, "LOADING Redis is loading the dataset in memory")
+
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" {
+		t.Errorf("expected 'Mountain View', got '%s'", location.City)
+	}
+
+	if hook.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", hook.calls)
+	}
+	if d1, d2 := store.retry.Delay(1), store.retry.Delay(2); d1 != 50*time.Millisecond || d2 != 100*time.Millisecond {
+		t.Errorf("expected delays 50ms and 100ms, got %v and %v", d1, d2)
+	}
+}
+
+// TestRedisStore_Retry_NonRetryable tests that permanent errors and not found return after one attempt
+func TestRedisStore_Retry_NonRetryable(t *testing.T) {
+	store, hook := setupFailingRedisStore(t, 1, "ERR unknown command")
+
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err == nil || !strings.Contains(err.Error(), "ERR unknown command") {
+		t.Errorf("expected the Redis error, got %v", err)
+	}
+	if hook.calls != 1 {
+		t.Errorf("expected 1 attempt for a non-retryable error, got %d", hook.calls)
+	}
+
+	// redis.Nil (not found) is never retried either
+	if _, err := store.FindByIP(context.Background(), "1.2.3.4"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+	if hook.calls != 2 {
+		t.Errorf("expected 1 attempt for a missing key, got %d", hook.calls-1)
+	}
+}
+
+// TestRedisStore_Retry_MaxAttemptsExceeded tests that the last error is returned with the attempt count
+func TestRedisStore_Retry_MaxAttemptsExceeded(t *testing.T) {
+	store, hook := setupFailingRedisStore(t, 10, "BUSY Redis is busy running a script")
+
+	err := store.Set("1.1.1.1", "Sydney", "Australia")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") || !strings.Contains(err.Error(), "BUSY") {
+		t.Errorf("expected the BUSY error with the attempt count, got %v", err)
+	}
+	if hook.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", hook.calls)
+	}
+
+	// SetRetry changes the number of attempts
+	store.SetRetry(retry.Config{MaxRetries: 4, BaseDelay: time.Millisecond})
+	hook.calls = 0
+	if err := store.Set("1.1.1.1", "Sydney", "Australia"); err == nil || !strings.Contains(err.Error(), "after 5 attempts") {
+		t.Errorf("expected failure after 5 attempts, got %v", err)
+	}
+}
+
+// TestRedisStore_Retry_LoadFromCSV tests that a transient error doesn't abort a load
+func TestRedisStore_Retry_LoadFromCSV(t *testing.T) {
+	store, _ := setupFailingRedisStore(t, 1, "LOADING Redis is loading the dataset in memory")
+
+	csvPath := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n1.1.1.1,Sydney,Australia\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	if err := store.LoadFromCSV(csvPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected loaded IP to be found, got %v", err)
+	}
+}
+
+// TestIsRetryableRedisError tests error classification
+func TestIsRetryableRedisError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "not found", err: redis.Nil, want: false},
+		{name: "network", err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, want: true},
+		{name: "plain error", err: errors.New("something else"), want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableRedisError(tt.err); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestRedisStore_ListCountries tests that Set maintains the countries index
+func TestRedisStore_ListCountries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, _ := NewRedisStore(mr.Addr(), "", 0)
+	defer store.Close()
+
+	store.Set("8.8.8.8", "Mountain View", "United States")
+	store.Set("8.8.4.4", "Mountain View", "United States")
+	store.Set("1.1.1.1", "Sydney", "Australia")
+
+	members, _ := mr.Members(redisCountriesKey)
+	if len(members) != 2 {
+		t.Errorf("expected 2 countries in the index, got %v", members)
+	}
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(countries) != 2 || countries[0] != "Australia" || countries[1] != "United States" {
+		t.Errorf("expected [Australia United States], got %v", countries)
+	}
+}
+
+// TestRedisStore_ListCountries_WithoutIndex tests the scan fallback for data loaded before the index existed
+func TestRedisStore_ListCountries_WithoutIndex(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("ip:8.8.8.8", `{"city":"Mountain View","country":"United States"}`)
+	mr.Set("ip:1.1.1.1", `{"city":"Sydney","country":"Australia"}`)
+	mr.Set("ip:1.0.0.1", `{"city":"Sydney","country":"Australia"}`)
+
+	store, _ := NewRedisStore(mr.Addr(), "", 0)
+	defer store.Close()
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(countries) != 2 || countries[0] != "Australia" || countries[1] != "United States" {
+		t.Errorf("expected [Australia United States], got %v", countries)
+	}
+}
+
+// FuzzRedisKey fuzzes Set with arbitrary values to find key-injection issues (e.g. newlines or '*' in the IP)
+// Each Set must write exactly one "ip:" key, named after the IP, that FindByIP reads back
+func FuzzRedisKey(f *testing.F) {
+	f.Add("8.8.8.8", "Mountain View", "United States")
+	f.Add("1.1.1.1\r\nFLUSHALL", "Sydney", "Australia")
+	f.Add("ip:*", "City\n\"quoted\"", "")
+	f.Add("2001:4860:4860::8888", "", "United States")
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		f.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		f.Fatalf("failed to connect to Redis: %v", err)
+	}
+	defer store.Close()
+
+	f.Fuzz(func(t *testing.T, ip, city, country string) {
+		mr.FlushAll()
+
+		if err := store.Set(ip, city, country); err != nil {
+			t.Fatalf("Set(%q) failed: %v", ip, err)
+		}
+
+		var ipKeys []string
+		for _, key := range mr.Keys() {
+			if strings.HasPrefix(key, "ip:") {
+				ipKeys = append(ipKeys, key)
+			}
+		}
+		if len(ipKeys) != 1 || ipKeys[0] != "ip:"+ip {
+			t.Fatalf("Set(%q) wrote keys %q, expected only %q", ip, ipKeys, "ip:"+ip)
+		}
+
+		location, err := store.FindByIP(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("FindByIP(%q) failed: %v", ip, err)
+		}
+		// JSON replaces invalid UTF-8, so only valid strings survive the round trip byte for byte
+		if utf8.ValidString(city) && utf8.ValidString(country) && (location.City != city || location.Country != country) {
+			t.Fatalf("FindByIP(%q) = %+v, expected %q, %q", ip, location, city, country)
+		}
+	})
+}
+
+// TestRedisStore_Search tests that scanned records are filtered and paginated like the in-memory stores
+func TestRedisStore_Search(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, _ := NewRedisStore(mr.Addr(), "", 0)
+	defer store.Close()
+
+	if _, err := store.BulkLoadFromReader(context.Background(), strings.NewReader(searchTestContent)); err != nil {
+		t.Fatalf("failed to load data: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    StoreQuery
+		expected []string
+	}{
+		{"country only", StoreQuery{Country: "australia"}, []string{"1.1.1.1"}},
+		{"city only", StoreQuery{City: "mountain"}, []string{"8.8.4.4", "8.8.8.8"}},
+		{"both fields", StoreQuery{Country: "United Kingdom", City: "New"}, []string{"5.5.5.5"}},
+		{"pagination", StoreQuery{City: "New", Limit: 2, Offset: 1}, []string{"5.5.5.5", "9.9.9.9"}},
+		{"no match", StoreQuery{City: "Paris"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations, err := store.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := searchIPs(locations); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestRedisStore_SetWithTTL tests that an IP is found until its TTL passes, then not found
+func TestRedisStore_SetWithTTL(t *testing.T) {
+	store := NewTestRedisStore(t)
+	mr := TestingRedis(t)
+
+	if err := store.SetWithTTL("8.8.8.8", "Mountain View", "United States", time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mr.FastForward(59 * time.Minute)
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Errorf("expected the IP before its TTL, got %v", err)
+	}
+
+	mr.FastForward(time.Minute)
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found' after the TTL, got %v", err)
+	}
+
+	// The IP expired, so the store is empty again and would be re-loaded
+	if isEmpty, err := store.IsEmpty(); err != nil || !isEmpty {
+		t.Errorf("expected the store to be empty after the TTL, got %v, %v", isEmpty, err)
+	}
+}
+
+// TestRedisStore_SetWithTTL_Zero tests that a zero TTL never expires, like Set
+func TestRedisStore_SetWithTTL_Zero(t *testing.T) {
+	store := NewTestRedisStore(t)
+	mr := TestingRedis(t)
+
+	if err := store.SetWithTTL("8.8.8.8", "Mountain View", "United States", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Set("1.1.1.1", "Sydney", "Australia")
+
+	mr.FastForward(365 * 24 * time.Hour)
+	for _, ip := range []string{"8.8.8.8", "1.1.1.1"} {
+		if _, err := store.FindByIP(context.Background(), ip); err != nil {
+			t.Errorf("expected %s to never expire, got %v", ip, err)
+		}
+		if ttl := mr.TTL("ip:" + ip); ttl != 0 {
+			t.Errorf("expected no TTL on %s, got %v", ip, ttl)
+		}
+	}
+}
+
+// TestRedisStore_LoadFromCSV_TTL tests that loaded IPs get the store's TTL
+func TestRedisStore_LoadFromCSV_TTL(t *testing.T) {
+	store := NewTestRedisStore(t)
+	mr := TestingRedis(t)
+	store.SetTTL(24 * time.Hour)
+
+	csvPath := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n1.1.1.1,Sydney,Australia\n8.8.8.8,Mountain View,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	if err := store.LoadFromCSV(csvPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ttl := mr.TTL("ip:1.1.1.1"); ttl != 24*time.Hour {
+		t.Errorf("expected TTL 24h, got %v", ttl)
+	}
+
+	mr.FastForward(24 * time.Hour)
+	if isEmpty, err := store.IsEmpty(); err != nil || !isEmpty {
+		t.Errorf("expected every loaded IP to expire, got empty=%v, %v", isEmpty, err)
+	}
+}
@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxShadowReadsInFlight caps concurrent shadow reads
+// When the shadow backend is slow, extra samples are dropped instead of piling up goroutines
+const maxShadowReadsInFlight = 100
+
+// ShadowStore serves reads from a primary store while comparing a sample of them against a shadow store
+// Used to validate a new backend (e.g. CSV -> MySQL) on real traffic before switching to it:
+//   - FindByIP always returns the primary's result
+//   - A fraction of lookups is repeated against the shadow in the background
+//   - Any difference is logged and counted in shadow_discrepancy_total
+//
+// Writes (BulkLoad) go to both stores so they stay in sync during the migration
+type ShadowStore struct {
+	primary    Store
+	shadow     Store
+	sampleRate float64 // Fraction of lookups compared against the shadow (0.0 - 1.0)
+
+	logger        *applogger.Logger  // Optional, see SetLogger
+	discrepancies prometheus.Counter // Optional, see SetDiscrepancyCounter
+
+	inFlight chan struct{}  // Semaphore bounding concurrent shadow reads
+	wg       sync.WaitGroup // Tracks shadow reads so Close can wait for them
+}
+
+// NewShadowStore creates a store reading from primary and sampling shadowWriteRate of lookups against shadow
+// shadowWriteRate is clamped to [0, 1]; 0 never touches the shadow
+func NewShadowStore(primary Store, shadow Store, shadowWriteRate float64) *ShadowStore {
+	return &ShadowStore{
+		primary:    primary,
+		shadow:     shadow,
+		sampleRate: min(max(shadowWriteRate, 0), 1),
+		inFlight:   make(chan struct{}, maxShadowReadsInFlight),
+	}
+}
+
+// SetLogger sets the logger receiving discrepancy reports
+func (s *ShadowStore) SetLogger(log *applogger.Logger) {
+	s.logger = log
+}
+
+// SetDiscrepancyCounter sets the counter incremented for each discrepancy
+func (s *ShadowStore) SetDiscrepancyCounter(counter prometheus.Counter) {
+	s.discrepancies = counter
+}
+
+// FindByIP returns the primary store's result
+// Implements the Store interface method
+//
+// Sampled lookups are compared against the shadow in a separate goroutine,
+// so the shadow never adds latency or errors to the response. The comparison
+// keeps ctx's values but not its cancellation: it outlives the request
+func (s *ShadowStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	location, err := s.primary.FindByIP(ctx, ip)
+
+	if s.sampleRate > 0 && rand.Float64() < s.sampleRate {
+		select {
+		case s.inFlight <- struct{}{}:
+			s.wg.Add(1)
+			go s.compare(context.WithoutCancel(ctx), ip, location, err)
+		default:
+			// Shadow is saturated - skip this sample rather than block
+		}
+	}
+
+	return location, err
+}
+
+// RangeFindByIP looks up ip in the primary store's ranges
+// Implements the RangeFinder interface. Range lookups aren't compared against the shadow
+func (s *ShadowStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return rangeFindByIP(ctx, s.primary, ip)
+}
+
+// compare reads ip from the shadow and reports any difference from the primary's result
+func (s *ShadowStore) compare(ctx context.Context, ip string, primary *models.IPLocation, primaryErr error) {
+	defer s.wg.Done()
+	defer func() { <-s.inFlight }()
+
+	shadow, shadowErr := s.shadow.FindByIP(ctx, ip)
+	if sameLookupResult(primary, primaryErr, shadow, shadowErr) {
+		return
+	}
+
+	if s.discrepancies != nil {
+		s.discrepancies.Inc()
+	}
+	if s.logger != nil {
+		s.logger.Warn().
+			Str("ip", ip).
+			Str("primary", describeLookupResult(primary, primaryErr)).
+			Str("shadow", describeLookupResult(shadow, shadowErr)).
+			Msg("Shadow store discrepancy")
+	}
+}
+
+// sameLookupResult reports whether two lookups returned the same location, or failed the same way
+func sameLookupResult(a *models.IPLocation, aErr error, b *models.IPLocation, bErr error) bool {
+	if aErr != nil || bErr != nil {
+		return aErr != nil && bErr != nil && aErr.Error() == bErr.Error()
+	}
+	return a.City == b.City && a.Country == b.Country
+}
+
+// describeLookupResult formats a lookup result for logging
+func describeLookupResult(location *models.IPLocation, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return fmt.Sprintf("%s, %s", location.City, location.Country)
+}
+
+// BulkLoad writes locations to the primary, then to the shadow
+// Implements the BulkLoader interface. Both stores must implement BulkLoader
+func (s *ShadowStore) BulkLoad(locations []*models.IPLocation) error {
+	primary, ok := s.primary.(BulkLoader)
+	if !ok {
+		return fmt.Errorf("primary store does not support bulk loading")
+	}
+	shadow, ok := s.shadow.(BulkLoader)
+	if !ok {
+		return fmt.Errorf("shadow store does not support bulk loading")
+	}
+
+	if err := primary.BulkLoad(locations); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	if err := shadow.BulkLoad(locations); err != nil {
+		return fmt.Errorf("shadow: %w", err)
+	}
+	return nil
+}
+
+// BulkDelete deletes ips from the primary, then from the shadow, returning the primary's count
+// Implements the BulkDeleter interface. Both stores must implement BulkDeleter
+func (s *ShadowStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	primary, ok := s.primary.(BulkDeleter)
+	if !ok {
+		return 0, fmt.Errorf("primary store does not support bulk deletes")
+	}
+	shadow, ok := s.shadow.(BulkDeleter)
+	if !ok {
+		return 0, fmt.Errorf("shadow store does not support bulk deletes")
+	}
+
+	deleted, err := primary.BulkDelete(ctx, ips)
+	if err != nil {
+		return deleted, fmt.Errorf("primary: %w", err)
+	}
+	if _, err := shadow.BulkDelete(ctx, ips); err != nil {
+		return deleted, fmt.Errorf("shadow: %w", err)
+	}
+	return deleted, nil
+}
+
+// Warmup warms up both stores (those implementing WarmableStore)
+// Implements the WarmableStore interface
+func (s *ShadowStore) Warmup(ctx context.Context) error {
+	var errs []error
+	if w, ok := s.primary.(WarmableStore); ok {
+		if err := w.Warmup(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("primary: %w", err))
+		}
+	}
+	if w, ok := s.shadow.(WarmableStore); ok {
+		if err := w.Warmup(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shadow: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListCountries lists the primary's countries, since the primary serves every response
+// Implements the CountryLister interface; fails if the primary doesn't implement it
+func (s *ShadowStore) ListCountries(ctx context.Context) ([]string, error) {
+	lister, ok := s.primary.(CountryLister)
+	if !ok {
+		return nil, fmt.Errorf("primary store does not support listing countries")
+	}
+	return lister.ListCountries(ctx)
+}
+
+// Search searches the primary, since the primary serves every response
+// Implements the Searcher interface; fails like an unsupported store if the primary doesn't implement it
+func (s *ShadowStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.primary.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	}
+	return searcher.Search(ctx, query)
+}
+
+// Count counts the primary's records, since the primary serves every response
+// Implements the Counter interface; fails like an unsupported store if the primary doesn't implement it
+func (s *ShadowStore) Count(ctx context.Context) (int, error) {
+	counter, ok := s.primary.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("counting is %w", pkerr.ErrNotSupported)
+	}
+	return counter.Count(ctx)
+}
+
+// Reload reloads the primary, since the primary serves every response
+// The shadow store keeps its data
+// Implements the Reloader interface; fails like an unsupported store if the primary doesn't implement it
+func (s *ShadowStore) Reload() (ReloadResult, error) {
+	reloader, ok := s.primary.(Reloader)
+	if !ok {
+		return ReloadResult{}, fmt.Errorf("reloading is %w", pkerr.ErrNotSupported)
+	}
+	return reloader.Reload()
+}
+
+// Stats reports the primary's stats, since the primary serves every response
+// Implements the StatsProvider interface
+func (s *ShadowStore) Stats() StoreStats {
+	if provider, ok := s.primary.(StatsProvider); ok {
+		return provider.Stats()
+	}
+	return StoreStats{}
+}
+
+// Close waits for in-flight shadow reads, then closes both stores
+func (s *ShadowStore) Close() error {
+	s.wg.Wait()
+	return errors.Join(s.primary.Close(), s.shadow.Close())
+}
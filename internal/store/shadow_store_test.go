@@ -0,0 +1,137 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+// setupShadowStore creates a shadow store over two mocks, with a log buffer and discrepancy counter
+func setupShadowStore(rate float64) (*ShadowStore, *MockStore, *MockStore, *bytes.Buffer, prometheus.Counter) {
+	primary := NewMockStore()
+	shadow := NewMockStore()
+
+	var buf bytes.Buffer
+	zl := zerolog.New(&buf)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_shadow_discrepancy_total"})
+
+	s := NewShadowStore(primary, shadow, rate)
+	s.SetLogger(&applogger.Logger{Logger: &zl})
+	s.SetDiscrepancyCounter(counter)
+
+	return s, primary, shadow, &buf, counter
+}
+
+// TestShadowStore_ReadsFromPrimary tests that the primary's result is always returned
+func TestShadowStore_ReadsFromPrimary(t *testing.T) {
+	s, _, shadow, _, _ := setupShadowStore(1.0)
+	shadow.Data["8.8.8.8"] = &models.IPLocation{IP: "8.8.8.8", City: "Shadow City", Country: "Shadowland"}
+
+	location, err := s.FindByIP(context.Background(), "8.8.8.8")
+	s.wg.Wait()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" {
+		t.Errorf("expected primary's city, got %s", location.City)
+	}
+
+	// Shadow errors never leak into the response
+	shadow.FindByIPError = errors.New("shadow is down")
+	if _, err := s.FindByIP(context.Background(), "1.1.1.1"); err != nil {
+		t.Errorf("expected shadow failure to be hidden, got %v", err)
+	}
+	s.wg.Wait()
+}
+
+// TestShadowStore_CountsDiscrepancies tests that differing results are counted and logged with both values
+func TestShadowStore_CountsDiscrepancies(t *testing.T) {
+	s, _, shadow, buf, counter := setupShadowStore(1.0)
+	shadow.Data["8.8.8.8"] = &models.IPLocation{IP: "8.8.8.8", City: "Shadow City", Country: "Shadowland"}
+	delete(shadow.Data, "1.1.1.1")
+
+	s.FindByIP(context.Background(), "8.8.8.8") // Different city
+	s.wg.Wait()
+	s.FindByIP(context.Background(), "1.1.1.1") // Missing from shadow
+	s.wg.Wait()
+	s.FindByIP(context.Background(), "9.9.9.9") // Missing from both - not a discrepancy
+	s.wg.Wait()
+
+	if got := testutil.ToFloat64(counter); got != 2 {
+		t.Errorf("expected 2 discrepancies, got %v", got)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{`"ip":"8.8.8.8"`, "Mountain View, United States", "Shadow City, Shadowland", `"ip":"1.1.1.1"`, "error: IP address not found"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("expected log to contain %q, got: %s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "9.9.9.9") {
+		t.Errorf("expected no discrepancy for IP missing from both stores, got: %s", logs)
+	}
+}
+
+// TestShadowStore_ZeroRate tests that a rate of 0 never queries the shadow
+func TestShadowStore_ZeroRate(t *testing.T) {
+	s, primary, shadow, _, _ := setupShadowStore(0.0)
+
+	for i := 0; i < 100; i++ {
+		s.FindByIP(context.Background(), "8.8.8.8")
+	}
+	s.wg.Wait()
+
+	if len(shadow.FindByIPCalls) != 0 {
+		t.Errorf("expected no shadow reads, got %d", len(shadow.FindByIPCalls))
+	}
+	if len(primary.FindByIPCalls) != 100 {
+		t.Errorf("expected 100 primary reads, got %d", len(primary.FindByIPCalls))
+	}
+}
+
+// TestShadowStore_BulkLoad tests that writes go to both stores
+func TestShadowStore_BulkLoad(t *testing.T) {
+	s, primary, shadow, _, _ := setupShadowStore(0.0)
+
+	err := s.BulkLoad([]*models.IPLocation{{IP: "9.9.9.9", City: "Berkeley", Country: "United States"}})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if primary.Data["9.9.9.9"] == nil || shadow.Data["9.9.9.9"] == nil {
+		t.Error("expected location written to both stores")
+	}
+}
+
+// TestShadowStore_BulkLoad_ShadowError tests that a failed shadow write is reported
+func TestShadowStore_BulkLoad_ShadowError(t *testing.T) {
+	s, _, shadow, _, _ := setupShadowStore(0.0)
+	shadow.BulkLoadError = errors.New("disk full")
+
+	err := s.BulkLoad([]*models.IPLocation{{IP: "9.9.9.9", City: "Berkeley", Country: "United States"}})
+
+	if err == nil || !strings.Contains(err.Error(), "shadow: disk full") {
+		t.Errorf("expected shadow error, got %v", err)
+	}
+}
+
+// TestShadowStore_Close tests that both stores are closed
+func TestShadowStore_Close(t *testing.T) {
+	s, primary, shadow, _, _ := setupShadowStore(1.0)
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !primary.CloseCalled || !shadow.CloseCalled {
+		t.Error("expected both stores to be closed")
+	}
+}
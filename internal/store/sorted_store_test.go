@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// sortedTestLocations is deliberately unsorted, and includes an IPv6 record the store skips
+func sortedTestLocations() []*models.IPLocation {
+	return []*models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+		{IP: "223.255.255.1", City: "Seoul", Country: "South Korea"},
+		{IP: "2001:4860:4860::8888", City: "Mountain View", Country: "United States"},
+		{IP: "2.22.233.255", City: "London", Country: "United Kingdom"},
+	}
+}
+
+// TestSortedStore_FindByIP tests lookups of the first, middle and last records, and of a missing IP
+func TestSortedStore_FindByIP(t *testing.T) {
+	s := NewSortedStore(sortedTestLocations())
+
+	tests := []struct {
+		name string
+		ip   string
+		city string
+	}{
+		{"first", "1.1.1.1", "Sydney"},
+		{"middle", "2.22.233.255", "London"},
+		{"last", "223.255.255.1", "Seoul"},
+		{"IPv4-mapped IPv6", "::ffff:8.8.8.8", "Mountain View"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, err := s.FindByIP(context.Background(), tt.ip)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if location.City != tt.city {
+				t.Errorf("expected %s, got %s", tt.city, location.City)
+			}
+		})
+	}
+
+	// Below the first, between two records, and above the last
+	for _, ip := range []string{"0.0.0.1", "5.5.5.5", "255.255.255.255"} {
+		if _, err := s.FindByIP(context.Background(), ip); !errors.Is(err, pkerr.ErrNotFound) {
+			t.Errorf("%s: expected 'IP address not found', got %v", ip, err)
+		}
+	}
+}
+
+// TestSortedStore_IPv6 tests that IPv6 lookups fail with a message saying why, and invalid IPs are rejected
+func TestSortedStore_IPv6(t *testing.T) {
+	s := NewSortedStore(sortedTestLocations())
+
+	_, err := s.FindByIP(context.Background(), "2001:4860:4860::8888")
+	if err == nil || !strings.Contains(err.Error(), "IPv6 addresses are not supported") {
+		t.Errorf("expected an IPv6 not supported error, got %v", err)
+	}
+
+	if _, err := s.FindByIP(context.Background(), "not-an-ip"); !errors.Is(err, pkerr.ErrInvalidIP) {
+		t.Errorf("expected 'invalid IP address format', got %v", err)
+	}
+
+	if s.Len() != 4 {
+		t.Errorf("expected the IPv6 record to be skipped (4 records), got %d", s.Len())
+	}
+}
+
+// TestSortedStore_Empty tests that an empty store finds nothing
+func TestSortedStore_Empty(t *testing.T) {
+	s := NewSortedStore(nil)
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+}
+
+// TestSortedStore_Duplicates tests that the last record for an IP wins, as in CSVStore
+func TestSortedStore_Duplicates(t *testing.T) {
+	s := NewSortedStore([]*models.IPLocation{
+		{IP: "8.8.8.8", City: "First", Country: "United States"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+		{IP: "8.8.8.8", City: "Last", Country: "United States"},
+	})
+
+	if s.Len() != 2 {
+		t.Errorf("expected 2 records, got %d", s.Len())
+	}
+	if location, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil || location.City != "Last" {
+		t.Errorf("expected the last record to win, got %+v, %v", location, err)
+	}
+}
+
+// TestSortedStore_LoadFromCSV tests that a CSV replaces the data, skipping the header and malformed rows
+func TestSortedStore_LoadFromCSV(t *testing.T) {
+	s := NewSortedStore(sortedTestLocations())
+
+	err := s.LoadFromCSV(strings.NewReader("ip,city,country\n9.9.9.9,Berkeley,United States\nbad,row\n4.4.4.4,Broomfield,United States\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Len() != 2 {
+		t.Errorf("expected 2 records, got %d", s.Len())
+	}
+	if location, err := s.FindByIP(context.Background(), "4.4.4.4"); err != nil || location.City != "Broomfield" {
+		t.Errorf("expected 4.4.4.4 to be loaded, got %+v, %v", location, err)
+	}
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected the previous data to be replaced")
+	}
+
+	// A failed load keeps the previous data
+	if err := s.LoadFromCSV(strings.NewReader("")); err == nil {
+		t.Error("expected an error for an empty CSV")
+	}
+	if s.Len() != 2 {
+		t.Errorf("expected the previous 2 records to be kept, got %d", s.Len())
+	}
+}
+
+// TestSortedStore_ConcurrentReads tests lookups from 8 goroutines, including during a reload (run with -race)
+func TestSortedStore_ConcurrentReads(t *testing.T) {
+	s := NewSortedStore(sortedTestLocations())
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				location, err := s.FindByIP(context.Background(), "1.1.1.1")
+				if err != nil || location.City != "Sydney" {
+					t.Errorf("expected Sydney, got %+v, %v", location, err)
+					return
+				}
+			}
+		}()
+	}
+
+	if err := s.LoadFromCSV(strings.NewReader("ip,city,country\n1.1.1.1,Sydney,Australia\n")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	wg.Wait()
+}
+
+// sortedBenchmarkSize is the number of records FindByIP is benchmarked over
+const sortedBenchmarkSize = 1_000_000
+
+// sortedBenchmarkData returns sortedBenchmarkSize records with consecutive IPs from 10.0.0.0,
+// and a sample of their IPs spread over the whole dataset
+func sortedBenchmarkData() ([]*models.IPLocation, []string) {
+	locations := make([]*models.IPLocation, sortedBenchmarkSize)
+	for i := range locations {
+		ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		locations[i] = &models.IPLocation{IP: ip, City: "City", Country: "Country"}
+	}
+
+	ips := make([]string, 1024)
+	for i := range ips {
+		ips[i] = locations[i*(sortedBenchmarkSize/len(ips))].IP
+	}
+	return locations, ips
+}
+
+// BenchmarkSortedStore_FindByIP measures binary search lookups over 1M records
+func BenchmarkSortedStore_FindByIP(b *testing.B) {
+	locations, ips := sortedBenchmarkData()
+	s := NewSortedStore(locations)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.FindByIP(context.Background(), ips[i%len(ips)]); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// BenchmarkCSVStore_FindByIP measures map lookups over the same 1M records, for comparison
+func BenchmarkCSVStore_FindByIP(b *testing.B) {
+	locations, ips := sortedBenchmarkData()
+
+	var content strings.Builder
+	content.WriteString("ip,city,country\n")
+	for _, location := range locations {
+		content.WriteString(location.IP + "," + location.City + "," + location.Country + "\n")
+	}
+	s, err := NewCSVStoreFromReader(strings.NewReader(content.String()))
+	if err != nil {
+		b.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer s.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.FindByIP(context.Background(), ips[i%len(ips)]); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
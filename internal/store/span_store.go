@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/history"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/iprange"
+)
+
+// DefaultSpanCapacity is the number of lookups of each kind a SpanStore keeps
+const DefaultSpanCapacity = 1000
+
+// SpanStore records which range answered each lookup, to debug how the ranges of a range mode
+// CSV file line up with CIDR blocks (served at GET /debug/spans)
+// Lookups answered by a range and lookups answered without one (a single-IP record, not found or an error)
+// are kept in separate rings, so a burst of misses doesn't push out the ranges
+//
+// Ranges are only seen through stores implementing SpanFinder (CSVStore, and MetricsStore over one);
+// every lookup of any other store is recorded without a span. Optional interfaces are passed through
+type SpanStore struct {
+	inner   Store
+	spans   *history.RingBuffer[models.SpanEntry]
+	noSpans *history.RingBuffer[models.SpanEntry]
+}
+
+// NewSpanStore wraps inner, keeping the last capacity lookups of each kind
+// A capacity <= 0 uses DefaultSpanCapacity
+func NewSpanStore(inner Store, capacity int) *SpanStore {
+	if capacity <= 0 {
+		capacity = DefaultSpanCapacity
+	}
+	return &SpanStore{
+		inner:   inner,
+		spans:   history.NewRingBuffer[models.SpanEntry](capacity),
+		noSpans: history.NewRingBuffer[models.SpanEntry](capacity),
+	}
+}
+
+// FindByIP looks up ip in the inner store and records the range that answered
+// Implements the Store interface method
+func (s *SpanStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	location, _, err := s.FindSpan(ctx, ip)
+	return location, err
+}
+
+// FindSpan looks up ip in the inner store and records the range that answered
+// Implements the SpanFinder interface
+func (s *SpanStore) FindSpan(ctx context.Context, ip string) (*models.IPLocation, *IPSpan, error) {
+	var (
+		location *models.IPLocation
+		span     *IPSpan
+		err      error
+	)
+	if finder, ok := s.inner.(SpanFinder); ok {
+		location, span, err = finder.FindSpan(ctx, ip)
+	} else {
+		location, err = s.inner.FindByIP(ctx, ip)
+	}
+
+	s.record(ip, span, err)
+	return location, span, err
+}
+
+// RangeFindByIP looks up ip in the inner store's ranges
+// Implements the RangeFinder interface. Not recorded: FindByIP already recorded the miss
+func (s *SpanStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return rangeFindByIP(ctx, s.inner, ip)
+}
+
+// record adds the outcome of a lookup of ip to the matching ring
+func (s *SpanStore) record(ip string, span *IPSpan, err error) {
+	entry := models.SpanEntry{Timestamp: time.Now(), IP: ip}
+	switch {
+	case err == nil && span != nil:
+		entry.Result = "range"
+		entry.Start, entry.End = span.Start.String(), span.End.String()
+		entry.CIDRs, _ = iprange.ToCIDRs(span.Start, span.End) // None for IPv6 ranges
+		s.spans.Add(entry)
+		return
+	case err == nil:
+		entry.Result = "exact"
+	case errors.Is(err, pkerr.ErrNotFound):
+		entry.Result = "not_found"
+	default:
+		entry.Result = "error"
+		entry.Error = err.Error()
+	}
+	s.noSpans.Add(entry)
+}
+
+// Spans returns up to n of the lookups answered by a range, newest first
+func (s *SpanStore) Spans(n int) []models.SpanEntry {
+	return s.spans.Last(n)
+}
+
+// NoSpans returns up to n of the lookups answered without a range, newest first
+func (s *SpanStore) NoSpans(n int) []models.SpanEntry {
+	return s.noSpans.Last(n)
+}
+
+// Iterate passes through to the inner store
+// Implements the Iterator interface; fails if the inner store doesn't implement it
+func (s *SpanStore) Iterate(fn func(location *models.IPLocation) error) error {
+	it, ok := s.inner.(Iterator)
+	if !ok {
+		return fmt.Errorf("inner store does not support iteration")
+	}
+	return it.Iterate(fn)
+}
+
+// BulkLoad passes through to the inner store
+// Implements the BulkLoader interface; fails if the inner store doesn't implement it
+func (s *SpanStore) BulkLoad(locations []*models.IPLocation) error {
+	loader, ok := s.inner.(BulkLoader)
+	if !ok {
+		return fmt.Errorf("inner store does not support bulk loading")
+	}
+	return loader.BulkLoad(locations)
+}
+
+// BulkLoadFromReader streams into the inner store (see the BulkLoadFromReader function)
+// Implements the StreamLoader interface, so the inner store's own streaming is used when it has one
+func (s *SpanStore) BulkLoadFromReader(ctx context.Context, r io.Reader) (int, error) {
+	return BulkLoadFromReader(ctx, s.inner, r)
+}
+
+// BulkDelete passes through to the inner store
+// Implements the BulkDeleter interface; fails if the inner store doesn't implement it
+func (s *SpanStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	deleter, ok := s.inner.(BulkDeleter)
+	if !ok {
+		return 0, fmt.Errorf("inner store does not support bulk deletes")
+	}
+	return deleter.BulkDelete(ctx, ips)
+}
+
+// Warmup warms up the inner store if it implements WarmableStore
+// Implements the WarmableStore interface
+func (s *SpanStore) Warmup(ctx context.Context) error {
+	if w, ok := s.inner.(WarmableStore); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// ListCountries lists the inner store's countries
+// Implements the CountryLister interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *SpanStore) ListCountries(ctx context.Context) ([]string, error) {
+	lister, ok := s.inner.(CountryLister)
+	if !ok {
+		return nil, fmt.Errorf("listing countries is %w", pkerr.ErrNotSupported)
+	}
+	return lister.ListCountries(ctx)
+}
+
+// Search searches the inner store
+// Implements the Searcher interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *SpanStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.inner.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	}
+	return searcher.Search(ctx, query)
+}
+
+// Count counts the inner store's records
+// Implements the Counter interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *SpanStore) Count(ctx context.Context) (int, error) {
+	counter, ok := s.inner.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("counting is %w", pkerr.ErrNotSupported)
+	}
+	return counter.Count(ctx)
+}
+
+// Reload reloads the inner store
+// Implements the Reloader interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *SpanStore) Reload() (ReloadResult, error) {
+	reloader, ok := s.inner.(Reloader)
+	if !ok {
+		return ReloadResult{}, fmt.Errorf("reloading is %w", pkerr.ErrNotSupported)
+	}
+	return reloader.Reload()
+}
+
+// Stats reports the inner store's stats
+// Implements the StatsProvider interface
+func (s *SpanStore) Stats() StoreStats {
+	if provider, ok := s.inner.(StatsProvider); ok {
+		return provider.Stats()
+	}
+	return StoreStats{}
+}
+
+// Close closes the inner store
+func (s *SpanStore) Close() error {
+	return s.inner.Close()
+}
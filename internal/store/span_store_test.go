@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// spanCSV has a /24, a range that isn't a single block, and a single address
+const spanCSV = `ip_start,ip_end,city,country
+10.0.0.0,10.0.0.255,Aligned,Country A
+10.0.1.0,10.0.2.127,Misaligned,Country B
+10.0.3.7,10.0.3.7,Single,Country C
+`
+
+// TestSpanStore_RecordsRanges tests that lookups matching different ranges are all recorded, newest first
+func TestSpanStore_RecordsRanges(t *testing.T) {
+	s := NewSpanStore(newRangeCSVStore(t, spanCSV), 10)
+
+	for _, ip := range []string{"10.0.0.42", "10.0.2.1", "10.0.3.7"} {
+		if _, err := s.FindByIP(context.Background(), ip); err != nil {
+			t.Fatalf("%s: unexpected error: %v", ip, err)
+		}
+	}
+
+	spans := s.Spans(10)
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %+v", spans)
+	}
+	expected := []struct {
+		ip, start, end string
+		cidrs          []string
+	}{
+		{"10.0.3.7", "10.0.3.7", "10.0.3.7", []string{"10.0.3.7/32"}},
+		{"10.0.2.1", "10.0.1.0", "10.0.2.127", []string{"10.0.1.0/24", "10.0.2.0/25"}},
+		{"10.0.0.42", "10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+	}
+	for i, want := range expected {
+		got := spans[i]
+		if got.IP != want.ip || got.Result != "range" || got.Start != want.start || got.End != want.end || !slices.Equal(got.CIDRs, want.cidrs) {
+			t.Errorf("span %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if noSpans := s.NoSpans(10); len(noSpans) != 0 {
+		t.Errorf("expected no no-span entries, got %+v", noSpans)
+	}
+}
+
+// TestSpanStore_NoSpan tests that exact matches, not found and failed lookups are recorded without a span
+func TestSpanStore_NoSpan(t *testing.T) {
+	ranged := NewSpanStore(newRangeCSVStore(t, spanCSV), 10)
+	if _, err := ranged.FindByIP(context.Background(), "192.0.2.1"); err == nil {
+		t.Fatal("expected not found")
+	}
+
+	inner := NewMockStore()
+	exact := NewSpanStore(inner, 10)
+	exact.FindByIP(context.Background(), "8.8.8.8")
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+	exact.FindByIP(context.Background(), "8.8.8.8")
+
+	if got := ranged.NoSpans(10); len(got) != 1 || got[0].IP != "192.0.2.1" || got[0].Result != "not_found" || got[0].Start != "" {
+		t.Errorf("expected a not_found entry, got %+v", got)
+	}
+	got := exact.NoSpans(10)
+	if len(got) != 2 || got[0].Result != "error" || got[0].Error != "dial tcp: connection refused" || got[1].Result != "exact" {
+		t.Errorf("expected an error then an exact entry, got %+v", got)
+	}
+	if spans := append(ranged.Spans(10), exact.Spans(10)...); len(spans) != 0 {
+		t.Errorf("expected no spans, got %+v", spans)
+	}
+}
+
+// TestSpanStore_Capacity tests that only the last capacity lookups of each kind are kept
+func TestSpanStore_Capacity(t *testing.T) {
+	s := NewSpanStore(newRangeCSVStore(t, rangeCSV(10)), 2)
+
+	for i := range 5 {
+		s.FindByIP(context.Background(), uint32ToIPv4(rangeStart(i)))
+		s.FindByIP(context.Background(), uint32ToIPv4(rangeStart(i)+300)) // In the gap after range i
+	}
+
+	spans := s.Spans(10)
+	if len(spans) != 2 || spans[0].IP != uint32ToIPv4(rangeStart(4)) || spans[1].IP != uint32ToIPv4(rangeStart(3)) {
+		t.Errorf("expected the last 2 spans, got %+v", spans)
+	}
+	if noSpans := s.NoSpans(10); len(noSpans) != 2 {
+		t.Errorf("expected 2 no-span entries, got %+v", noSpans)
+	}
+	if got := len(s.Spans(1)); got != 1 {
+		t.Errorf("expected n to limit the spans, got %d", got)
+	}
+}
+
+// TestSpanStore_ThroughMetricsStore tests that ranges are seen through a MetricsStore, which still counts the lookups
+func TestSpanStore_ThroughMetricsStore(t *testing.T) {
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	s := NewSpanStore(NewMetricsStore(newRangeCSVStore(t, spanCSV), m, "csv"), 10)
+
+	if _, err := s.FindByIP(context.Background(), "10.0.0.1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if spans := s.Spans(10); len(spans) != 1 || spans[0].Start != "10.0.0.0" {
+		t.Errorf("expected the /24 span, got %+v", spans)
+	}
+	if got := testutil.ToFloat64(m.DatastoreQueriesTotal.WithLabelValues("csv", "find_by_ip", "success")); got != 1 {
+		t.Errorf("expected 1 counted lookup, got %v", got)
+	}
+}
+
+// TestSpanStore_Concurrent tests that concurrent lookups are all recorded (run with -race)
+func TestSpanStore_Concurrent(t *testing.T) {
+	s := NewSpanStore(newRangeCSVStore(t, rangeCSV(100)), 1000)
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip := uint32ToIPv4(rangeStart(i) + 7)
+			if _, err := s.FindByIP(context.Background(), ip); err != nil {
+				t.Errorf("%s: unexpected error: %v", ip, err)
+			}
+			s.Spans(10)
+		}()
+	}
+	wg.Wait()
+
+	if got := len(s.Spans(1000)); got != 100 {
+		t.Errorf("expected 100 spans, got %d", got)
+	}
+}
+
+// TestSpanStore_PassesThrough tests that optional interfaces reach the inner store
+func TestSpanStore_PassesThrough(t *testing.T) {
+	inner := NewMockStore()
+	s := NewSpanStore(inner, 0)
+
+	countries, err := s.ListCountries(context.Background())
+	if err != nil || len(countries) == 0 {
+		t.Errorf("expected the inner store's countries, got %v, %v", countries, err)
+	}
+	if deleted, err := s.BulkDelete(context.Background(), []string{"8.8.8.8"}); err != nil || deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d, %v", deleted, err)
+	}
+}
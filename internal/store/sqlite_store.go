@@ -0,0 +1,453 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/data"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver (no cgo needed for single-binary builds)
+)
+
+// SQLiteEmbeddedPath selects the database compiled into the binary instead of a file on disk
+const SQLiteEmbeddedPath = ":embedded:"
+
+// SQLiteMemoryPath selects a new, empty database held in memory (for tests)
+const SQLiteMemoryPath = ":memory:"
+
+// sqliteSchema creates the ip2country table (same layout as the MySQL table)
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS ip2country (
+	ip      TEXT PRIMARY KEY,
+	city    TEXT NOT NULL,
+	country TEXT NOT NULL
+)`
+
+// sqliteRangesSchema creates the ip_ranges table, holding CIDR blocks and the rows of a range mode CSV file
+// Addresses are stored as their 16 bytes (IPv4 in IPv4-mapped form), so IPv4 and IPv6 ranges share the table:
+// SQLite compares BLOBs with memcmp, which orders them like the addresses
+const sqliteRangesSchema = `CREATE TABLE IF NOT EXISTS ip_ranges (
+	ip_start BLOB PRIMARY KEY,
+	ip_end   BLOB NOT NULL,
+	city     TEXT NOT NULL,
+	country  TEXT NOT NULL
+)`
+
+// sqliteFindByIPQuery is prepared once when the store opens, so lookups skip parsing and planning
+const sqliteFindByIPQuery = "SELECT city, country FROM ip2country WHERE ip = ?"
+
+// sqliteRangeFindByIPQuery selects the range with the greatest start <= ip, which contains ip if it ends at or after it
+// Prepared like sqliteFindByIPQuery, when the database has an ip_ranges table
+const sqliteRangeFindByIPQuery = "SELECT ip_end, city, country FROM ip_ranges WHERE ip_start <= ? ORDER BY ip_start DESC LIMIT 1"
+
+// SQLiteStore implements Store interface using an SQLite database file
+// Unlike CSVStore, data stays on disk and is paged in by SQLite as needed
+type SQLiteStore struct {
+	db        *sql.DB
+	findStmt  *sql.Stmt // Prepared sqliteFindByIPQuery
+	rangeStmt *sql.Stmt // Prepared sqliteRangeFindByIPQuery, nil for an embedded database built before ip_ranges existed
+	tempPath  string    // Temp copy of the embedded database, removed on Close
+	version   string    // DataVersion: hash of the embedded bytes, or of the file's modification time
+}
+
+// NewSQLiteStore opens an SQLite database, creating the file if it doesn't exist
+// The tables are created on first open (the embedded database is opened read-only, as built)
+//
+// Parameters:
+//   - path: path to the .db file, SQLiteEmbeddedPath (":embedded:") to use
+//     the database bundled into the binary at build time, or SQLiteMemoryPath (":memory:")
+//     for an empty database that lives as long as the store
+//
+// Returns:
+//   - *SQLiteStore: pointer to the created store
+//   - error: any error that occurred while opening the database
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	tempPath := ""
+	var version string
+	dsn := "file:" + path
+	switch path {
+	case SQLiteEmbeddedPath:
+		// SQLite needs a real file, so copy the embedded bytes to a temp file first
+		var err error
+		tempPath, err = writeEmbeddedSQLite()
+		if err != nil {
+			return nil, err
+		}
+		dsn = "file:" + tempPath + "?mode=ro"
+		// The temp file is new on every start, so hash the contents instead of its mtime
+		version = dataVersion(string(data.SQLiteDB))
+	case SQLiteMemoryPath:
+		// Every in-memory database is new, like the embedded temp file
+		version = dataVersion(strconv.FormatInt(time.Now().UnixNano(), 16))
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		removeTemp(tempPath)
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if path == SQLiteMemoryPath {
+		// Each connection to :memory: opens a database of its own, so keep to one
+		db.SetMaxOpenConns(1)
+	}
+
+	// Test the connection (sql.Open is lazy)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		removeTemp(tempPath)
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	if tempPath == "" {
+		if err := migrateSQLite(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if version == "" {
+		// Stat after migrating, so a new file exists and its mtime covers the tables just created
+		info, err := os.Stat(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		version = dataVersion(strconv.FormatInt(info.ModTime().UnixNano(), 16))
+	}
+
+	findStmt, err := db.Prepare(sqliteFindByIPQuery)
+	if err != nil {
+		db.Close()
+		removeTemp(tempPath)
+		return nil, fmt.Errorf("failed to prepare lookup query: %w", err)
+	}
+
+	rangeStmt, err := prepareSQLiteRangeQuery(db)
+	if err != nil {
+		findStmt.Close()
+		db.Close()
+		removeTemp(tempPath)
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db, findStmt: findStmt, rangeStmt: rangeStmt, tempPath: tempPath, version: version}, nil
+}
+
+// migrateSQLite creates the ip2country and ip_ranges tables if they don't exist yet
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("failed to migrate SQLite database: %w", err)
+	}
+	if _, err := db.Exec(sqliteRangesSchema); err != nil {
+		return fmt.Errorf("failed to migrate SQLite database: %w", err)
+	}
+	return nil
+}
+
+// prepareSQLiteRangeQuery prepares sqliteRangeFindByIPQuery, or returns nil if the database has no ip_ranges table
+// (an embedded database built before ranges were written), so its lookups just find no range
+func prepareSQLiteRangeQuery(db *sql.DB) (*sql.Stmt, error) {
+	var tables int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'ip_ranges'").Scan(&tables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SQLite schema: %w", err)
+	}
+	if tables == 0 {
+		return nil, nil
+	}
+
+	stmt, err := db.Prepare(sqliteRangeFindByIPQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare range lookup query: %w", err)
+	}
+	return stmt, nil
+}
+
+// sqliteRangeKey converts an IP address to the 16-byte form ip_ranges stores (IPv4 in IPv4-mapped form)
+// ok is false for an invalid address
+func sqliteRangeKey(ip string) ([]byte, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, false
+	}
+	return parsed.To16(), true
+}
+
+// writeEmbeddedSQLite writes the bundled database to a temp file and returns its path
+func writeEmbeddedSQLite() (string, error) {
+	if len(data.SQLiteDB) == 0 {
+		return "", fmt.Errorf("no embedded SQLite database (run go generate ./data)")
+	}
+
+	file, err := os.CreateTemp("", "ip2country-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for embedded database: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data.SQLiteDB); err != nil {
+		removeTemp(file.Name())
+		return "", fmt.Errorf("failed to write embedded database: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// removeTemp deletes a temp file if one was created
+func removeTemp(path string) {
+	if path != "" {
+		os.Remove(path)
+	}
+}
+
+// FindByIP looks up an IP address in SQLite
+// Implements the Store interface method
+func (s *SQLiteStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	location := &models.IPLocation{IP: ip}
+
+	err := s.findStmt.QueryRowContext(ctx, ip).Scan(&location.City, &location.Country)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkerr.ErrNotFound
+		}
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+
+	return location, nil
+}
+
+// RangeFindByIP looks up the range containing ip in the ip_ranges table (IPv4 or IPv6)
+// Implements the RangeFinder interface
+func (s *SQLiteStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	key, ok := sqliteRangeKey(ip)
+	if !ok || s.rangeStmt == nil {
+		return nil, pkerr.ErrNotFound
+	}
+
+	location := &models.IPLocation{IP: ip}
+	var end []byte
+	err := s.rangeStmt.QueryRowContext(ctx, key).Scan(&end, &location.City, &location.Country)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, pkerr.ErrNotFound
+		}
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	if bytes.Compare(key, end) > 0 {
+		return nil, pkerr.ErrNotFound
+	}
+
+	return location, nil
+}
+
+// Iterate calls fn for each row, ordered by IP
+// Implements the Iterator interface. Ranges aren't single records, so they're not iterated
+func (s *SQLiteStore) Iterate(fn func(location *models.IPLocation) error) error {
+	rows, err := s.db.Query("SELECT ip, city, country FROM ip2country ORDER BY ip")
+	if err != nil {
+		return fmt.Errorf("database query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var location models.IPLocation
+		if err := rows.Scan(&location.IP, &location.City, &location.Country); err != nil {
+			return fmt.Errorf("failed to read row: %w", err)
+		}
+		if err := fn(&location); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListCountries returns the distinct countries in the database, sorted alphabetically
+// Implements the CountryLister interface. The countries of ranges are included, like CSVStore's
+func (s *SQLiteStore) ListCountries(ctx context.Context) ([]string, error) {
+	query := "SELECT DISTINCT country FROM ip2country WHERE country <> '' ORDER BY country"
+	if s.rangeStmt != nil {
+		// UNION drops duplicates itself
+		query = "SELECT country FROM ip2country WHERE country <> '' UNION SELECT country FROM ip_ranges WHERE country <> '' ORDER BY country"
+	}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	defer rows.Close()
+
+	countries := []string{}
+	for rows.Next() {
+		var country string
+		if err := rows.Scan(&country); err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+		countries = append(countries, country)
+	}
+	return countries, rows.Err()
+}
+
+// sqliteSearchQuery selects a page of records for Search. -1 is SQLite's "no limit"
+const sqliteSearchQuery = `SELECT ip, city, country FROM ip2country
+	WHERE country LIKE ? ESCAPE '\' AND city LIKE ? ESCAPE '\'
+	ORDER BY ip LIMIT ? OFFSET ?`
+
+// Search returns the records matching query, ordered by IP
+// Implements the Searcher interface. SQLite's LIKE is case-insensitive for ASCII letters only
+func (s *SQLiteStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, sqliteSearchQuery,
+		likePrefixPattern(query.Country), likePrefixPattern(query.City), limit, max(query.Offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []*models.IPLocation{}
+	for rows.Next() {
+		var location models.IPLocation
+		if err := rows.Scan(&location.IP, &location.City, &location.Country); err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+		locations = append(locations, &location)
+	}
+	return locations, rows.Err()
+}
+
+// Count returns the number of records in the database
+// Implements the Counter interface
+func (s *SQLiteStore) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ip2country").Scan(&count); err != nil {
+		return 0, fmt.Errorf("database query failed: %w", err)
+	}
+	return count, nil
+}
+
+// Stats returns the data version of the opened database
+// Implements the StatsProvider interface
+func (s *SQLiteStore) Stats() StoreStats {
+	return StoreStats{DataVersion: s.version}
+}
+
+// HealthCheck pings the database
+// Implements the HealthChecker interface
+func (s *SQLiteStore) HealthCheck(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database and removes the temp copy of the embedded database
+func (s *SQLiteStore) Close() error {
+	var err error
+	if s.findStmt != nil {
+		s.findStmt.Close()
+	}
+	if s.rangeStmt != nil {
+		s.rangeStmt.Close()
+	}
+	if s.db != nil {
+		err = s.db.Close()
+	}
+	removeTemp(s.tempPath)
+	return err
+}
+
+// rangeIterator is implemented by stores holding ranges of addresses, which BuildSQLiteDatabase
+// writes to the ip_ranges table
+type rangeIterator interface {
+	// iterateRanges calls fn for each range, from its first to its last address
+	iterateRanges(fn func(start, end net.IP, location models.IPLocation) error) error
+}
+
+// BuildSQLiteDatabase writes every record from src into a new SQLite database at path
+// Ranges (the CIDR blocks and range mode rows of a CSVStore) are written to the ip_ranges table
+// Any existing file at path is replaced
+// Returns the number of records and ranges written
+func BuildSQLiteDatabase(path string, src Iterator) (int, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to remove existing database: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create SQLite database: %w", err)
+	}
+	defer db.Close()
+
+	if err := migrateSQLite(db); err != nil {
+		return 0, err
+	}
+
+	// Single transaction: orders of magnitude faster than one commit per row
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO ip2country (ip, city, country) VALUES (?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	err = src.Iterate(func(location *models.IPLocation) error {
+		if _, err := stmt.Exec(location.IP, location.City, location.Country); err != nil {
+			return fmt.Errorf("failed to insert IP %s: %w", location.IP, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if ranges, ok := src.(rangeIterator); ok {
+		n, err := insertSQLiteRanges(tx, ranges)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		count += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	// Compact the file so the embedded copy is as small as possible
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return 0, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	return count, nil
+}
+
+// insertSQLiteRanges writes the ranges of src to the ip_ranges table in tx, returning how many were written
+// Of ranges with the same start, the last one is kept
+func insertSQLiteRanges(tx *sql.Tx, src rangeIterator) (int, error) {
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO ip_ranges (ip_start, ip_end, city, country) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare range insert: %w", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	err = src.iterateRanges(func(start, end net.IP, location models.IPLocation) error {
+		if _, err := stmt.Exec([]byte(start.To16()), []byte(end.To16()), location.City, location.Country); err != nil {
+			return fmt.Errorf("failed to insert range %s - %s: %w", start, end, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,446 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// newTestSQLiteDB builds a database file from the given records and returns its path
+func newTestSQLiteDB(t *testing.T, records map[string]*models.IPLocation) string {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if _, err := BuildSQLiteDatabase(dbPath, &CSVStore{data: records}); err != nil {
+		t.Fatalf("failed to build test database: %v", err)
+	}
+	return dbPath
+}
+
+// TestSQLiteStore_Embedded tests the database bundled into the binary
+func TestSQLiteStore_Embedded(t *testing.T) {
+	store, err := NewSQLiteStore(SQLiteEmbeddedPath)
+	if err != nil {
+		t.Fatalf("expected embedded path to open without errors, got: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		ip      string
+		city    string
+		country string
+	}{
+		{"8.8.8.8", "Mountain View", "United States"},
+		{"1.1.1.1", "Sydney", "Australia"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			location, err := store.FindByIP(context.Background(), tt.ip)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if location.City != tt.city {
+				t.Errorf("expected city '%s', got '%s'", tt.city, location.City)
+			}
+			if location.Country != tt.country {
+				t.Errorf("expected country '%s', got '%s'", tt.country, location.Country)
+			}
+		})
+	}
+}
+
+// TestSQLiteStore_Embedded_RemovesTempFile tests that Close cleans up the extracted copy
+func TestSQLiteStore_Embedded_RemovesTempFile(t *testing.T) {
+	store, err := NewSQLiteStore(SQLiteEmbeddedPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tempPath := store.tempPath
+	if tempPath == "" {
+		t.Fatal("expected embedded store to use a temp file")
+	}
+
+	store.Close()
+
+	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+		t.Errorf("expected temp file %s to be removed", tempPath)
+	}
+}
+
+// TestSQLiteStore_FindByIP tests lookups against a database file
+func TestSQLiteStore_FindByIP(t *testing.T) {
+	dbPath := newTestSQLiteDB(t, map[string]*models.IPLocation{
+		"8.8.8.8":     {IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		"2001:db8::1": {IP: "2001:db8::1", City: "Test City", Country: "Test Country"},
+	})
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	location, err := store.FindByIP(context.Background(), "2001:db8::1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.IP != "2001:db8::1" || location.City != "Test City" {
+		t.Errorf("unexpected location: %+v", location)
+	}
+
+	_, err = store.FindByIP(context.Background(), "192.168.1.1")
+	if !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+}
+
+// TestSQLiteStore_CreatesMissingFile tests that a missing file is created with empty, migrated tables
+func TestSQLiteStore_CreatesMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.db")
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the database file to be created, got %v", err)
+	}
+	if count, err := store.Count(context.Background()); err != nil || count != 0 {
+		t.Errorf("expected an empty table, got %d, %v", count, err)
+	}
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.RangeFindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if store.Stats().DataVersion == "" {
+		t.Error("expected a data version")
+	}
+
+	// A directory that doesn't exist can't hold the file
+	if _, err := NewSQLiteStore(filepath.Join(t.TempDir(), "missing", "new.db")); err == nil {
+		t.Error("expected error for a missing directory, got nil")
+	}
+}
+
+// TestSQLiteStore_Memory tests that ":memory:" opens an empty database, kept across lookups until Close
+func TestSQLiteStore_Memory(t *testing.T) {
+	store, err := NewSQLiteStore(SQLiteMemoryPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := store.db.Exec("INSERT INTO ip2country (ip, city, country) VALUES ('8.8.8.8', 'Mountain View', 'United States')"); err != nil {
+		t.Fatalf("failed to insert row: %v", err)
+	}
+	location, err := store.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" || location.Country != "United States" {
+		t.Errorf("expected Mountain View, United States, got %+v", location)
+	}
+	if _, err := os.Stat(SQLiteMemoryPath); !os.IsNotExist(err) {
+		t.Errorf("expected no %s file to be created", SQLiteMemoryPath)
+	}
+}
+
+// TestSQLiteStore_Iterate tests that every row is visited in IP order
+func TestSQLiteStore_Iterate(t *testing.T) {
+	dbPath := newTestSQLiteDB(t, map[string]*models.IPLocation{
+		"8.8.8.8": {IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		"1.1.1.1": {IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	})
+
+	store, _ := NewSQLiteStore(dbPath)
+	defer store.Close()
+
+	var ips []string
+	err := store.Iterate(func(location *models.IPLocation) error {
+		ips = append(ips, location.IP)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 2 || ips[0] != "1.1.1.1" || ips[1] != "8.8.8.8" {
+		t.Errorf("expected [1.1.1.1 8.8.8.8], got %v", ips)
+	}
+}
+
+// TestBuildSQLiteDatabase_ReplacesExisting tests that rebuilding drops stale rows
+func TestBuildSQLiteDatabase_ReplacesExisting(t *testing.T) {
+	dbPath := newTestSQLiteDB(t, map[string]*models.IPLocation{
+		"8.8.8.8": {IP: "8.8.8.8", City: "Old", Country: "Old"},
+	})
+
+	count, err := BuildSQLiteDatabase(dbPath, &CSVStore{data: map[string]*models.IPLocation{
+		"1.1.1.1": {IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 record written, got %d", count)
+	}
+
+	store, _ := NewSQLiteStore(dbPath)
+	defer store.Close()
+
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected stale record to be gone after rebuild")
+	}
+}
+
+// TestSQLiteStore_Search tests prefix matching, pagination, and that LIKE wildcards and SQL in a query match literally
+func TestSQLiteStore_Search(t *testing.T) {
+	csvStore, err := NewCSVStoreFromReader(strings.NewReader(searchTestContent))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	store, err := NewSQLiteStore(newTestSQLiteDB(t, csvStore.data))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		name     string
+		query    StoreQuery
+		expected []string
+	}{
+		{"country only", StoreQuery{Country: "Australia"}, []string{"1.1.1.1"}},
+		{"city only", StoreQuery{City: "New"}, []string{"4.2.2.2", "5.5.5.5", "9.9.9.9"}},
+		{"both fields", StoreQuery{Country: "United States", City: "New"}, []string{"4.2.2.2", "9.9.9.9"}},
+		{"case-insensitive", StoreQuery{Country: "UNITED kingdom"}, []string{"2.22.233.255", "5.5.5.5"}},
+		{"pagination", StoreQuery{Country: "United", Limit: 2, Offset: 2}, []string{"5.5.5.5", "8.8.4.4"}},
+		{"no match", StoreQuery{Country: "France"}, []string{}},
+		{"percent is literal", StoreQuery{City: "100%"}, []string{"100.1.1.1"}},
+		{"underscore is literal", StoreQuery{City: "_"}, []string{}},
+		{"SQL injection", StoreQuery{Country: "' OR '1'='1"}, []string{}},
+		{"SQL injection with comment", StoreQuery{Country: "x'; DROP TABLE ip2country; --"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations, err := store.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := searchIPs(locations); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Errorf("expected the table to be intact after the injection attempts: %v", err)
+	}
+}
+
+// TestSQLiteStore_Count tests that every row is counted
+func TestSQLiteStore_Count(t *testing.T) {
+	csvStore, err := NewCSVStoreFromReader(strings.NewReader(searchTestContent))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	store, err := NewSQLiteStore(newTestSQLiteDB(t, csvStore.data))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	count, err := store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != len(csvStore.data) {
+		t.Errorf("expected %d records, got %d", len(csvStore.data), count)
+	}
+}
+
+// TestSQLiteStore_ListCountries tests that countries are de-duplicated and sorted
+func TestSQLiteStore_ListCountries(t *testing.T) {
+	dbPath := newTestSQLiteDB(t, map[string]*models.IPLocation{
+		"8.8.8.8": {IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		"8.8.4.4": {IP: "8.8.4.4", City: "Mountain View", Country: "United States"},
+		"1.1.1.1": {IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	})
+
+	store, _ := NewSQLiteStore(dbPath)
+	defer store.Close()
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(countries, []string{"Australia", "United States"}) {
+		t.Errorf("expected [Australia United States], got %v", countries)
+	}
+}
+
+// TestCSVStore_LoadIntoSQLite tests that a CSV file converted to SQLite serves the same lookups,
+// with no database server involved
+func TestCSVStore_LoadIntoSQLite(t *testing.T) {
+	csvStore, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer csvStore.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "converted.db")
+	if err := csvStore.LoadIntoSQLite(dbPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open converted database: %v", err)
+	}
+	defer store.Close()
+
+	for _, expected := range []models.IPLocation{
+		{IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		{IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+	} {
+		location, err := store.FindByIP(context.Background(), expected.IP)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", expected.IP, err)
+		}
+		if *location != expected {
+			t.Errorf("%s: expected %+v, got %+v", expected.IP, expected, *location)
+		}
+	}
+	if _, err := store.FindByIP(context.Background(), "192.168.1.1"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected 'IP address not found', got %v", err)
+	}
+}
+
+// TestCSVStore_LoadIntoSQLite_Ranges tests that the CIDR blocks of a CSV file, IPv4 and IPv6,
+// are converted to the ip_ranges table and looked up with RangeFindByIP
+func TestCSVStore_LoadIntoSQLite_Ranges(t *testing.T) {
+	csvStore, err := NewCSVStoreFromReader(strings.NewReader("ip,city,country\n" +
+		"1.2.3.0/24,Berlin,Germany\n" +
+		"1.2.3.4,Munich,Germany\n" +
+		"2001:db8::/32,Documentation,Documentation\n"))
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer csvStore.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "converted.db")
+	if err := csvStore.LoadIntoSQLite(dbPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open converted database: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		ip   string
+		city string
+	}{
+		{"1.2.3.0", "Berlin"},
+		{"1.2.3.4", "Berlin"},
+		{"1.2.3.255", "Berlin"},
+		{"2001:db8::1", "Documentation"},
+		{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", "Documentation"},
+		{"1.2.4.0", ""},
+		{"1.2.2.255", ""},
+		{"2001:db9::", ""},
+		{"not-an-ip", ""},
+	}
+
+	for _, tt := range tests {
+		location, err := store.RangeFindByIP(context.Background(), tt.ip)
+		if tt.city == "" {
+			if !errors.Is(err, pkerr.ErrNotFound) {
+				t.Errorf("%s: expected ErrNotFound, got %v", tt.ip, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.ip, err)
+			continue
+		}
+		if location.City != tt.city || location.IP != tt.ip {
+			t.Errorf("%s: expected %s, got %+v", tt.ip, tt.city, location)
+		}
+	}
+
+	// The exact row is still in ip2country
+	location, err := store.FindByIP(context.Background(), "1.2.3.4")
+	if err != nil || location.City != "Munich" {
+		t.Errorf("expected Munich for the exact row, got %+v, %v", location, err)
+	}
+
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(countries, []string{"Documentation", "Germany"}) {
+		t.Errorf("expected [Documentation Germany], got %v", countries)
+	}
+}
+
+// TestSQLiteStore_MigratesOldDatabase tests that a database built before ip_ranges existed
+// gets the table on open, keeping its rows
+func TestSQLiteStore_MigratesOldDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", "file:"+dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO ip2country (ip, city, country) VALUES ('8.8.8.8', 'Mountain View', 'United States')"); err != nil {
+		t.Fatalf("failed to insert row: %v", err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	if store.rangeStmt == nil {
+		t.Error("expected the ip_ranges table to be created")
+	}
+	if _, err := store.RangeFindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if location, err := store.FindByIP(context.Background(), "8.8.8.8"); err != nil || location.City != "Mountain View" {
+		t.Errorf("expected Mountain View, got %+v, %v", location, err)
+	}
+	countries, err := store.ListCountries(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(countries, []string{"United States"}) {
+		t.Errorf("expected [United States], got %v", countries)
+	}
+}
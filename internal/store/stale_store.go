@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/cache"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultStaleCacheSize is the number of recently looked-up IPs a StaleStore remembers
+const DefaultStaleCacheSize = 10000
+
+// StaleStore keeps serving lookups while the backing store is unreachable
+// Every lookup still goes to the inner store; the last successful result for recently
+// looked-up IPs is kept in an LRU cache:
+//   - Success: the result is cached and returned
+//   - pkerr.ErrNotFound: the cached entry is dropped and the error returned
+//   - Any other error (MySQL or Redis down): the cached result is returned with Stale set,
+//     however old it is, and stale_serves_total is incremented
+//
+// IPs that were never looked up successfully can't be served stale, so their errors propagate
+type StaleStore struct {
+	inner Store
+
+	// entries holds the last successful result of each IP; they never expire, however old
+	entries *cache.Cache[string, models.IPLocation]
+
+	staleServes prometheus.Counter // Optional, see SetStaleServesCounter
+}
+
+// NewStaleStore creates a store serving stale results from an LRU cache of size entries when inner fails
+// A size <= 0 uses DefaultStaleCacheSize
+func NewStaleStore(inner Store, size int) *StaleStore {
+	if size <= 0 {
+		size = DefaultStaleCacheSize
+	}
+	return &StaleStore{
+		inner:   inner,
+		entries: cache.New[string, models.IPLocation](size, 0),
+	}
+}
+
+// SetStaleServesCounter sets the counter incremented each time a stale result is served
+func (s *StaleStore) SetStaleServesCounter(counter prometheus.Counter) {
+	s.staleServes = counter
+}
+
+// FindByIP looks up ip in the inner store, falling back to the last known result on store errors
+// Implements the Store interface method
+func (s *StaleStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	location, err := s.inner.FindByIP(ctx, ip)
+	if err == nil {
+		s.remember(ip, location)
+		return location, nil
+	}
+
+	if errors.Is(err, pkerr.ErrNotFound) {
+		s.forget(ip)
+		return nil, err
+	}
+
+	stale, ok := s.cached(ip)
+	if !ok {
+		return nil, err
+	}
+	if s.staleServes != nil {
+		s.staleServes.Inc()
+	}
+	stale.Stale = true
+	return stale, nil
+}
+
+// RangeFindByIP looks up ip in the inner store's ranges
+// Implements the RangeFinder interface. Range lookups aren't cached: stale results are only kept for FindByIP
+func (s *StaleStore) RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	return rangeFindByIP(ctx, s.inner, ip)
+}
+
+// remember caches a copy of location, evicting the least recently used entry when full
+func (s *StaleStore) remember(ip string, location *models.IPLocation) {
+	s.entries.Set(ip, *location, 0)
+}
+
+// forget drops ip from the cache
+func (s *StaleStore) forget(ip string) {
+	s.entries.Delete(ip)
+}
+
+// cached returns a copy of the cached result for ip
+func (s *StaleStore) cached(ip string) (*models.IPLocation, bool) {
+	location, ok := s.entries.Get(ip)
+	if !ok {
+		return nil, false
+	}
+	return &location, true
+}
+
+// BulkLoad writes locations to the inner store and drops the cache, which no longer matches the data
+// Implements the BulkLoader interface; fails if the inner store doesn't implement it
+func (s *StaleStore) BulkLoad(locations []*models.IPLocation) error {
+	loader, ok := s.inner.(BulkLoader)
+	if !ok {
+		return fmt.Errorf("inner store does not support bulk loading")
+	}
+	if err := loader.BulkLoad(locations); err != nil {
+		return err
+	}
+
+	s.entries.Clear()
+	return nil
+}
+
+// BulkDelete deletes ips from the inner store and drops them from the cache, so they can't be served stale
+// Implements the BulkDeleter interface; fails if the inner store doesn't implement it
+func (s *StaleStore) BulkDelete(ctx context.Context, ips []string) (int, error) {
+	deleter, ok := s.inner.(BulkDeleter)
+	if !ok {
+		return 0, fmt.Errorf("inner store does not support bulk deletes")
+	}
+
+	// Forget even when the delete fails: it may have removed some of the records
+	deleted, err := deleter.BulkDelete(ctx, ips)
+	for _, ip := range ips {
+		s.forget(ip)
+	}
+	return deleted, err
+}
+
+// Warmup warms up the inner store if it implements WarmableStore
+// Implements the WarmableStore interface
+func (s *StaleStore) Warmup(ctx context.Context) error {
+	if w, ok := s.inner.(WarmableStore); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// ListCountries lists the inner store's countries
+// Implements the CountryLister interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *StaleStore) ListCountries(ctx context.Context) ([]string, error) {
+	lister, ok := s.inner.(CountryLister)
+	if !ok {
+		return nil, fmt.Errorf("listing countries is %w", pkerr.ErrNotSupported)
+	}
+	return lister.ListCountries(ctx)
+}
+
+// Search searches the inner store
+// Implements the Searcher interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *StaleStore) Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error) {
+	searcher, ok := s.inner.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("searching is %w", pkerr.ErrNotSupported)
+	}
+	return searcher.Search(ctx, query)
+}
+
+// Count counts the inner store's records
+// Implements the Counter interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *StaleStore) Count(ctx context.Context) (int, error) {
+	counter, ok := s.inner.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("counting is %w", pkerr.ErrNotSupported)
+	}
+	return counter.Count(ctx)
+}
+
+// Reload reloads the inner store
+// Implements the Reloader interface; fails like an unsupported store if the inner store doesn't implement it
+func (s *StaleStore) Reload() (ReloadResult, error) {
+	reloader, ok := s.inner.(Reloader)
+	if !ok {
+		return ReloadResult{}, fmt.Errorf("reloading is %w", pkerr.ErrNotSupported)
+	}
+	return reloader.Reload()
+}
+
+// Stats reports the inner store's stats
+// Implements the StatsProvider interface
+func (s *StaleStore) Stats() StoreStats {
+	if provider, ok := s.inner.(StatsProvider); ok {
+		return provider.Stats()
+	}
+	return StoreStats{}
+}
+
+// Close closes the inner store
+func (s *StaleStore) Close() error {
+	return s.inner.Close()
+}
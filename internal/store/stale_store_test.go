@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// setupStaleStore creates a stale store over a mock, with a stale serve counter
+func setupStaleStore(size int) (*StaleStore, *MockStore, prometheus.Counter) {
+	inner := NewMockStore()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_stale_serves_total"})
+
+	s := NewStaleStore(inner, size)
+	s.SetStaleServesCounter(counter)
+
+	return s, inner, counter
+}
+
+// TestStaleStore_UsesLiveData tests that lookups go to the inner store while it's healthy
+func TestStaleStore_UsesLiveData(t *testing.T) {
+	s, inner, counter := setupStaleStore(0)
+
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inner.Data["8.8.8.8"] = &models.IPLocation{IP: "8.8.8.8", City: "Reloaded City", Country: "United States"}
+	location, err := s.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Reloaded City" || location.Stale {
+		t.Errorf("expected live, non-stale data, got %+v", location)
+	}
+	if len(inner.FindByIPCalls) != 2 {
+		t.Errorf("expected every lookup to reach the inner store, got %v", inner.FindByIPCalls)
+	}
+	if got := testutil.ToFloat64(counter); got != 0 {
+		t.Errorf("expected no stale serves, got %v", got)
+	}
+}
+
+// TestStaleStore_ServesStaleOnError tests that a store error returns the cached result marked stale
+func TestStaleStore_ServesStaleOnError(t *testing.T) {
+	s, inner, counter := setupStaleStore(0)
+
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+	location, err := s.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("expected the stale result, got error %v", err)
+	}
+	if !location.Stale || location.City != "Mountain View" || location.Country != "United States" {
+		t.Errorf("expected stale Mountain View, got %+v", location)
+	}
+	if got := testutil.ToFloat64(counter); got != 1 {
+		t.Errorf("expected 1 stale serve, got %v", got)
+	}
+
+	// The stale flag is set on a copy, never on the inner store's data
+	if inner.Data["8.8.8.8"].Stale {
+		t.Error("expected the inner store's location to be left untouched")
+	}
+}
+
+// TestStaleStore_NoCachedEntry tests that errors propagate for IPs never looked up successfully
+func TestStaleStore_NoCachedEntry(t *testing.T) {
+	s, inner, counter := setupStaleStore(0)
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err == nil || err.Error() != "dial tcp: connection refused" {
+		t.Errorf("expected the store error, got %v", err)
+	}
+	if got := testutil.ToFloat64(counter); got != 0 {
+		t.Errorf("expected no stale serves, got %v", got)
+	}
+}
+
+// TestStaleStore_NotFoundDropsEntry tests that "not found" results aren't masked and clear the cache
+func TestStaleStore_NotFoundDropsEntry(t *testing.T) {
+	s, inner, _ := setupStaleStore(0)
+
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	delete(inner.Data, "8.8.8.8")
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// The IP is gone from the data, so an outage must not bring it back
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected the store error after the entry was dropped, got nil")
+	}
+}
+
+// TestStaleStore_WrappedNotFound tests that a "not found" wrapped by the inner store isn't served stale
+func TestStaleStore_WrappedNotFound(t *testing.T) {
+	s, inner, counter := setupStaleStore(0)
+
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inner.FindByIPError = fmt.Errorf("shard eu-1: %w", pkerr.ErrNotFound)
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected the wrapped not found, got %v", err)
+	}
+	if got := testutil.ToFloat64(counter); got != 0 {
+		t.Errorf("expected no stale serves, got %v", got)
+	}
+}
+
+// TestStaleStore_EvictsLeastRecentlyUsed tests that the cache stays within its size
+func TestStaleStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	s, inner, _ := setupStaleStore(1)
+
+	s.FindByIP(context.Background(), "8.8.8.8")
+	s.FindByIP(context.Background(), "1.1.1.1")
+
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected 8.8.8.8 to be evicted, got a stale result")
+	}
+	if location, err := s.FindByIP(context.Background(), "1.1.1.1"); err != nil || !location.Stale {
+		t.Errorf("expected a stale result for 1.1.1.1, got %+v, %v", location, err)
+	}
+}
+
+// TestStaleStore_BulkLoadClearsCache tests that loading new data drops the stale results
+func TestStaleStore_BulkLoadClearsCache(t *testing.T) {
+	s, inner, _ := setupStaleStore(0)
+	s.FindByIP(context.Background(), "8.8.8.8")
+
+	if err := s.BulkLoad([]*models.IPLocation{{IP: "9.9.9.9", City: "Berkeley", Country: "United States"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected the cache to be cleared by BulkLoad, got a stale result")
+	}
+}
+
+// TestStaleStore_BulkDeleteForgets tests that deleted IPs can't be served stale afterwards
+func TestStaleStore_BulkDeleteForgets(t *testing.T) {
+	s, inner, _ := setupStaleStore(0)
+	s.FindByIP(context.Background(), "8.8.8.8")
+	s.FindByIP(context.Background(), "1.1.1.1")
+
+	deleted, err := s.BulkDelete(context.Background(), []string{"8.8.8.8"})
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 deleted and no error, got %d, %v", deleted, err)
+	}
+
+	inner.FindByIPError = errors.New("dial tcp: connection refused")
+	if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected the deleted IP to be forgotten, got a stale result")
+	}
+	if location, err := s.FindByIP(context.Background(), "1.1.1.1"); err != nil || !location.Stale {
+		t.Errorf("expected a stale result for 1.1.1.1, got %+v, %v", location, err)
+	}
+}
@@ -1,13 +1,216 @@
 package store
 
-import "github.com/evyataryagoni/ip2country/internal/models"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"iter"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
 
 // Store defines the interface for IP lookup operations
 // Allows multiple implementations (CSV, MySQL, Redis) and easy testing with mocks
 type Store interface {
 	// FindByIP looks up geographic information for an IP address
-	FindByIP(ip string) (*models.IPLocation, error)
+	FindByIP(ctx context.Context, ip string) (*models.IPLocation, error)
 
 	// Close cleans up resources (database connections, file handles, etc.)
 	Close() error
 }
+
+// Iterator is implemented by stores that can enumerate every record they hold
+// Used by operator tooling (e.g. cmd/inspect) - the request path never needs it
+type Iterator interface {
+	// Iterate calls fn for each record in the store
+	// Iteration stops at the first error returned by fn, and that error is returned
+	Iterate(fn func(location *models.IPLocation) error) error
+}
+
+// BulkLoader is implemented by stores that accept writes
+// Existing records with the same IP are overwritten
+type BulkLoader interface {
+	// BulkLoad inserts or updates all locations
+	BulkLoad(locations []*models.IPLocation) error
+}
+
+// StreamLoader is implemented by stores with a faster way to ingest a CSV stream than
+// batches of BulkLoad (e.g. Redis pipelines). See the BulkLoadFromReader function
+type StreamLoader interface {
+	// BulkLoadFromReader loads ip,city,country rows from r as they are read, returning how many were written
+	BulkLoadFromReader(ctx context.Context, r io.Reader) (int, error)
+}
+
+// BulkDeleter is implemented by stores that can remove records (e.g. for GDPR erasure requests)
+type BulkDeleter interface {
+	// BulkDelete removes the records for ips, returning how many existed
+	// IPs the store doesn't hold are ignored
+	BulkDelete(ctx context.Context, ips []string) (int, error)
+}
+
+// WarmableStore is implemented by stores whose first queries are slow on a cold start
+// The server calls Warmup once at startup, before accepting traffic
+type WarmableStore interface {
+	// Warmup pre-loads caches and connections. Errors are not fatal - the store still works, just colder
+	Warmup(ctx context.Context) error
+}
+
+// HealthChecker is implemented by stores that can tell whether their backend is usable
+// The server registers the check of every store it opens, under a name unique to that store
+type HealthChecker interface {
+	// HealthCheck returns an error when the store can't serve lookups (e.g. the database is unreachable)
+	HealthCheck(ctx context.Context) error
+}
+
+// CountryLister is implemented by stores that can list the countries they have data for
+type CountryLister interface {
+	// ListCountries returns every distinct non-empty country, sorted alphabetically
+	ListCountries(ctx context.Context) ([]string, error)
+}
+
+// StoreQuery selects records by field for Searcher.Search
+type StoreQuery struct {
+	Country string // Case-insensitive prefix of the country ("" = any country)
+	City    string // Case-insensitive prefix of the city ("" = any city)
+	Limit   int    // Maximum number of records returned (0 = no limit)
+	Offset  int    // Number of matching records skipped, for pagination
+}
+
+// Matches reports whether location matches the query's Country and City prefixes
+func (q StoreQuery) Matches(location *models.IPLocation) bool {
+	return hasPrefixFold(location.Country, q.Country) && hasPrefixFold(location.City, q.City)
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring case
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
+
+// Searcher is implemented by stores that can look up records by field rather than by IP
+type Searcher interface {
+	// Search returns the records matching query, ordered by IP so pages don't overlap
+	Search(ctx context.Context, query StoreQuery) ([]*models.IPLocation, error)
+}
+
+// Counter is implemented by stores that can report how many records they hold
+// The count is of the records Search and Iterate return, so it can size their pages
+type Counter interface {
+	// Count returns the number of records in the store
+	Count(ctx context.Context) (int, error)
+}
+
+// StoreStats describes the data a store is serving
+type StoreStats struct {
+	// DataVersion changes whenever the loaded data changes, so clients know to drop cached responses
+	// Empty when the store can't tell
+	DataVersion string
+}
+
+// StatsProvider is implemented by stores that can describe their data
+type StatsProvider interface {
+	// Stats returns the current store stats
+	Stats() StoreStats
+}
+
+// ReloadResult summarizes a Reloader.Reload: how many records are loaded and how they changed
+type ReloadResult struct {
+	Records int // Records served after the reload
+	Added   int // Records (IPs or ranges) in the new data but not the previous one
+	Removed int // Records in the previous data but not the new one
+}
+
+// Reloader is implemented by stores that can re-read their source and swap the data in place
+type Reloader interface {
+	// Reload replaces the data with a fresh read of the source
+	// On error the previous data keeps being served
+	Reload() (ReloadResult, error)
+}
+
+// IPSpan is an inclusive range of addresses sharing one location, e.g. a row of a range mode CSV file
+type IPSpan struct {
+	Start net.IP
+	End   net.IP
+}
+
+// SpanFinder is implemented by stores holding ranges of addresses rather than single IPs
+type SpanFinder interface {
+	// FindSpan looks up ip like FindByIP, also returning the range it was found in
+	// The span is nil when ip matched a single-IP record
+	FindSpan(ctx context.Context, ip string) (*models.IPLocation, *IPSpan, error)
+}
+
+// RangeFinder is implemented by stores that can hold CIDR blocks (e.g. 1.2.3.0/24) as well as single IPs
+// The service falls through to RangeFindByIP when FindByIP doesn't find an IP
+type RangeFinder interface {
+	// RangeFindByIP looks up the range (IPv4 or IPv6) containing ip, returning pkg/errors.ErrNotFound if none does
+	RangeFindByIP(ctx context.Context, ip string) (*models.IPLocation, error)
+}
+
+// rangeFindByIP looks up ip in inner's ranges, for wrappers passing RangeFinder through
+// A store that can't hold ranges has none containing ip, so that's pkg/errors.ErrNotFound rather than a failure
+func rangeFindByIP(ctx context.Context, inner Store, ip string) (*models.IPLocation, error) {
+	finder, ok := inner.(RangeFinder)
+	if !ok {
+		return nil, pkerr.ErrNotFound
+	}
+	return finder.RangeFindByIP(ctx, ip)
+}
+
+// dataVersion hashes a value identifying a data load (file mtime, load timestamp) into a DataVersion
+func dataVersion(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// distinctCountries returns the distinct non-empty countries of locations, sorted alphabetically
+// Used by stores that list countries from the records they hold in memory
+func distinctCountries(locations iter.Seq[*models.IPLocation]) []string {
+	seen := make(map[string]struct{})
+	for location := range locations {
+		if location.Country != "" {
+			seen[location.Country] = struct{}{}
+		}
+	}
+
+	countries := make([]string, 0, len(seen))
+	for country := range seen {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// searchLocations returns the page of locations matching query, ordered by IP
+// Used by stores that search the records they hold in memory or scan
+func searchLocations(locations iter.Seq[*models.IPLocation], query StoreQuery) []*models.IPLocation {
+	matches := []*models.IPLocation{}
+	for location := range locations {
+		if query.Matches(location) {
+			matches = append(matches, location)
+		}
+	}
+	slices.SortFunc(matches, func(a, b *models.IPLocation) int {
+		return strings.Compare(a.IP, b.IP)
+	})
+
+	if query.Offset >= len(matches) {
+		return []*models.IPLocation{}
+	}
+	matches = matches[max(query.Offset, 0):]
+	if query.Limit > 0 && query.Limit < len(matches) {
+		matches = matches[:query.Limit]
+	}
+	return matches
+}
+
+// likePrefixPattern returns a LIKE pattern matching values that begin with prefix
+// %, _ and the escape character \ in prefix are escaped, so they match literally
+func likePrefixPattern(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
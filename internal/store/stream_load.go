@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// streamLoadBatchSize is the number of rows written at once by BulkLoadFromReader
+const streamLoadBatchSize = 1000
+
+// ErrEmptyCSV is returned for CSV data without even a header row
+var ErrEmptyCSV = errors.New("CSV file is empty")
+
+// loadProgressKey is the context key of the WithLoadProgress callback
+type loadProgressKey struct{}
+
+// WithLoadProgress returns a context that makes BulkLoadFromReader call fn after each batch
+// with the number of rows written so far
+func WithLoadProgress(ctx context.Context, fn func(rows int)) context.Context {
+	return context.WithValue(ctx, loadProgressKey{}, fn)
+}
+
+// BulkLoadFromReader loads a CSV stream into s without buffering it in memory
+// Uses s's own BulkLoadFromReader if it implements StreamLoader, and otherwise
+// writes batches of 1000 rows with BulkLoad. Stops with ctx.Err() when ctx is cancelled;
+// the rows written so far are kept, and counted in the returned total
+//
+// CSV Format: ip,city,country[,country_code,continent_code] (first row is a header; invalid rows are skipped, like NewCSVStore)
+func BulkLoadFromReader(ctx context.Context, s Store, r io.Reader) (int, error) {
+	if loader, ok := s.(StreamLoader); ok {
+		return loader.BulkLoadFromReader(ctx, r)
+	}
+	loader, ok := s.(BulkLoader)
+	if !ok {
+		return 0, fmt.Errorf("store does not support bulk loading")
+	}
+	return streamCSV(ctx, r, loader.BulkLoad)
+}
+
+// streamCSV reads ip,city,country[,country_code,continent_code] rows from r and passes them to write in batches of streamLoadBatchSize
+// Returns the number of rows write accepted
+func streamCSV(ctx context.Context, r io.Reader, write func(batch []*models.IPLocation) error) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Invalid rows are skipped below, not fatal
+	reader.ReuseRecord = true
+
+	// The header says whether rows have the code columns
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return 0, ErrEmptyCSV
+		}
+		return 0, fmt.Errorf("failed to read CSV file: %w", err)
+	}
+	layout := newCSVLayout(header, 1)
+
+	progress, _ := ctx.Value(loadProgressKey{}).(func(rows int))
+	batch := make([]*models.IPLocation, 0, streamLoadBatchSize)
+	loaded := 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := write(batch); err != nil {
+			return err
+		}
+		loaded += len(batch)
+		// A new slice: write may keep the locations (e.g. MockStore)
+		batch = make([]*models.IPLocation, 0, streamLoadBatchSize)
+		if progress != nil {
+			progress(loaded)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return loaded, err
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("failed to read CSV file: %w", err)
+		}
+
+		// Skip invalid records, like NewCSVStore
+		if len(record) != layout.columns() {
+			continue
+		}
+
+		location := layout.location(record)
+		location.IP = record[0]
+		batch = append(batch, &location)
+		if len(batch) == streamLoadBatchSize {
+			if err := flush(); err != nil {
+				return loaded, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return loaded, err
+	}
+	return loaded, nil
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// writeCSVRows writes n ip,city,country rows numbered from first to w
+func writeCSVRows(w io.Writer, first, n int) {
+	for i := first; i < first+n; i++ {
+		fmt.Fprintf(w, "10.%d.%d.%d,City %d,Country\n", byte(i>>16), byte(i>>8), byte(i), i)
+	}
+}
+
+// progressRecorder collects the totals reported through WithLoadProgress
+type progressRecorder struct {
+	mu     sync.Mutex
+	totals []int
+	ch     chan int
+}
+
+func newProgressRecorder() *progressRecorder {
+	return &progressRecorder{ch: make(chan int, 100)}
+}
+
+func (p *progressRecorder) record(rows int) {
+	p.mu.Lock()
+	p.totals = append(p.totals, rows)
+	p.mu.Unlock()
+	p.ch <- rows
+}
+
+// wait blocks until rows is reported
+func (p *progressRecorder) wait(t *testing.T, rows int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-p.ch:
+			if got == rows {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %d rows to be reported", rows)
+		}
+	}
+}
+
+// TestBulkLoadFromReader_Batches tests that rows are written batch by batch while the stream is still open
+func TestBulkLoadFromReader_Batches(t *testing.T) {
+	mockStore := NewMockStore()
+	mockStore.Data = make(map[string]*models.IPLocation)
+	progress := newProgressRecorder()
+	pr, pw := io.Pipe()
+
+	type result struct {
+		loaded int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		loaded, err := BulkLoadFromReader(WithLoadProgress(context.Background(), progress.record), mockStore, pr)
+		done <- result{loaded, err}
+	}()
+
+	io.WriteString(pw, "ip,city,country\n")
+	writeCSVRows(pw, 0, 1000)
+
+	// The first batch is written before the rest of the stream exists
+	progress.wait(t, 1000)
+
+	io.WriteString(pw, "invalid,row\n") // Skipped, like NewCSVStore
+	writeCSVRows(pw, 1000, 1500)
+	pw.Close()
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("unexpected error: %v", res.err)
+	}
+	if res.loaded != 2500 {
+		t.Errorf("expected 2500 rows loaded, got %d", res.loaded)
+	}
+	if len(mockStore.Data) != 2500 {
+		t.Errorf("expected 2500 records in the store, got %d", len(mockStore.Data))
+	}
+	if expected := []int{1000, 2000, 2500}; !slices.Equal(progress.totals, expected) {
+		t.Errorf("expected progress %v, got %v", expected, progress.totals)
+	}
+}
+
+// TestBulkLoadFromReader_Cancel tests that cancelling the context stops the load, keeping the written batches
+func TestBulkLoadFromReader_Cancel(t *testing.T) {
+	mockStore := NewMockStore()
+	mockStore.Data = make(map[string]*models.IPLocation)
+	progress := newProgressRecorder()
+	pr, pw := io.Pipe()
+	defer pr.Close() // Unblocks the writer once the load has stopped reading
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		loaded int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		loaded, err := BulkLoadFromReader(WithLoadProgress(ctx, progress.record), mockStore, pr)
+		done <- result{loaded, err}
+	}()
+
+	io.WriteString(pw, "ip,city,country\n")
+	writeCSVRows(pw, 0, 1000)
+	progress.wait(t, 1000)
+
+	cancel()
+	go writeCSVRows(pw, 1000, 5000)
+
+	res := <-done
+	if !errors.Is(res.err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", res.err)
+	}
+	if res.loaded != 1000 {
+		t.Errorf("expected the first batch to be kept, got %d rows loaded", res.loaded)
+	}
+	if len(mockStore.Data) != 1000 {
+		t.Errorf("expected 1000 records in the store, got %d", len(mockStore.Data))
+	}
+}
+
+// TestBulkLoadFromReader_Errors tests empty input, write failures and stores without writes
+func TestBulkLoadFromReader_Errors(t *testing.T) {
+	if _, err := BulkLoadFromReader(context.Background(), NewMockStore(), strings.NewReader("")); !errors.Is(err, ErrEmptyCSV) {
+		t.Errorf("expected ErrEmptyCSV, got %v", err)
+	}
+
+	failing := NewMockStore()
+	failing.BulkLoadError = errors.New("connection refused")
+	if _, err := BulkLoadFromReader(context.Background(), failing, strings.NewReader("ip,city,country\n8.8.8.8,Mountain View,United States\n")); err == nil {
+		t.Error("expected the store's error, got nil")
+	}
+
+	readOnly := struct{ Store }{NewMockStore()}
+	if _, err := BulkLoadFromReader(context.Background(), readOnly, strings.NewReader("ip,city,country\n")); err == nil {
+		t.Error("expected error for a store without BulkLoad, got nil")
+	}
+}
+
+// TestBulkLoadFromReader_CountryCodes tests that the header's code columns are loaded
+func TestBulkLoadFromReader_CountryCodes(t *testing.T) {
+	mockStore := NewMockStore()
+	mockStore.Data = make(map[string]*models.IPLocation)
+
+	csv := "ip,city,country,country_code,continent_code\n9.9.9.9,Berkeley,United States,US,NA\n"
+	if _, err := BulkLoadFromReader(context.Background(), mockStore, strings.NewReader(csv)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location := mockStore.Data["9.9.9.9"]; location == nil || location.CountryCode != "US" || location.ContinentCode != "NA" {
+		t.Errorf("expected US/NA, got %+v", location)
+	}
+}
+
+// TestRedisStore_BulkLoadFromReader tests Redis's pipelined streaming, through MetricsStore like in the server
+func TestRedisStore_BulkLoadFromReader(t *testing.T) {
+	store, mr := setupBulkRedis(t)
+	metricsStore := NewMetricsStore(store, metrics.NewWithRegistry(prometheus.NewRegistry()), "redis")
+	progress := newProgressRecorder()
+
+	var csv strings.Builder
+	csv.WriteString("ip,city,country\n")
+	writeCSVRows(&csv, 0, 2345)
+
+	loaded, err := BulkLoadFromReader(WithLoadProgress(context.Background(), progress.record), metricsStore, strings.NewReader(csv.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded != 2345 {
+		t.Errorf("expected 2345 rows loaded, got %d", loaded)
+	}
+	if keys := ipKeys(mr); len(keys) != 2345 {
+		t.Errorf("expected 2345 keys, got %d", len(keys))
+	}
+	if expected := []int{1000, 2000, 2345}; !slices.Equal(progress.totals, expected) {
+		t.Errorf("expected progress %v, got %v", expected, progress.totals)
+	}
+	if store.Stats().DataVersion == "" {
+		t.Error("expected the data version to be recorded")
+	}
+
+	location, err := store.FindByIP(context.Background(), "10.0.0.7")
+	if err != nil || location.City != "City 7" {
+		t.Errorf("expected City 7 for 10.0.0.7, got %+v (%v)", location, err)
+	}
+}
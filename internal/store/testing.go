@@ -0,0 +1,47 @@
+package store
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testRedisServers maps each test to the miniredis started by NewTestRedisStore (see TestingRedis)
+var testRedisServers sync.Map // *testing.T -> *miniredis.Miniredis
+
+// NewTestRedisStore creates a real RedisStore backed by an in-process miniredis server
+// For tests outside this package that need Redis behaviour MockStore can't provide,
+// like key expiry; the server and store are closed when the test ends
+func NewTestRedisStore(t *testing.T) *RedisStore {
+	t.Helper()
+
+	mr := miniredis.NewMiniRedis()
+	if err := mr.Start(); err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	store, err := NewRedisStore(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to create Redis store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	testRedisServers.Store(t, mr)
+	t.Cleanup(func() { testRedisServers.Delete(t) })
+
+	return store
+}
+
+// TestingRedis returns the miniredis server behind t's NewTestRedisStore
+// Lets tests inspect or manipulate keys directly, e.g. mr.FastForward to expire them
+func TestingRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+
+	mr, ok := testRedisServers.Load(t)
+	if !ok {
+		t.Fatal("TestingRedis called without NewTestRedisStore")
+	}
+	return mr.(*miniredis.Miniredis)
+}
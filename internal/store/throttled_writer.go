@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisWriteRPS is the REDIS_WRITE_RPS default: bulk loads write at most 10,000 IPs per second
+const DefaultRedisWriteRPS = 10000
+
+// ThrottledBatchWriter pipelines IP locations to Redis in batches of 500 SETs,
+// waiting between batches so writes stay under a fixed rate
+// Protects a production Redis from being saturated by a bulk import of millions of IPs
+//
+// Safe for concurrent use: workers sharing one writer share its rate limit
+type ThrottledBatchWriter struct {
+	client *redis.Client
+	ctx    context.Context
+
+	// ticker grants one batch per tick: tick interval = batch size / rps. nil = unlimited
+	ticker *time.Ticker
+
+	// ttl is the expiry of the keys written (see SetTTL). 0 = never expire
+	ttl time.Duration
+}
+
+// NewThrottledBatchWriter creates a writer limited to rps writes per second (0 = unlimited)
+// ctx cancels writes and waits in progress. Call Stop when done to release the ticker
+func NewThrottledBatchWriter(ctx context.Context, client *redis.Client, rps int) *ThrottledBatchWriter {
+	w := &ThrottledBatchWriter{client: client, ctx: ctx}
+	if rps > 0 {
+		// A rate too high for a measurable interval is as good as unlimited
+		if interval := time.Second * redisBulkLoadBatchSize / time.Duration(rps); interval > 0 {
+			w.ticker = time.NewTicker(interval)
+		}
+	}
+	return w
+}
+
+// SetTTL makes the keys written expire after ttl. Zero (the default) keeps them forever
+// Must be called before the first write
+func (w *ThrottledBatchWriter) SetTTL(ttl time.Duration) {
+	w.ttl = ttl
+}
+
+// WriteIPBatch writes batch to Redis, one pipeline per 500 locations
+// After each pipeline the writer waits for the next tick, so a batch of any size never exceeds the rate
+// A shorter final batch is charged as a full one, keeping the limit an upper bound
+func (w *ThrottledBatchWriter) WriteIPBatch(batch []models.IPLocation) error {
+	for start := 0; start < len(batch); start += redisBulkLoadBatchSize {
+		end := min(start+redisBulkLoadBatchSize, len(batch))
+		if err := w.pipeline(batch[start:end]); err != nil {
+			return err
+		}
+		if err := w.wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pipeline sends one batch of SETs (plus the SADD of each country) in a single round trip
+// CIDR blocks are written to the ranges sorted set (see queueSetLocation)
+func (w *ThrottledBatchWriter) pipeline(batch []models.IPLocation) error {
+	pipe := w.client.Pipeline()
+	for i := range batch {
+		if err := queueSetLocation(w.ctx, pipe, &batch[i], w.ttl); err != nil {
+			return err
+		}
+	}
+
+	if _, err := pipe.Exec(w.ctx); err != nil {
+		return fmt.Errorf("failed to store batch in Redis: %w", err)
+	}
+	return nil
+}
+
+// wait blocks until the next batch is allowed, or ctx is cancelled
+func (w *ThrottledBatchWriter) wait() error {
+	if w.ticker == nil {
+		return nil
+	}
+	select {
+	case <-w.ticker.C:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+// Stop releases the ticker. The writer must not be used afterwards
+func (w *ThrottledBatchWriter) Stop() {
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// throttleLocations generates n distinct locations
+func throttleLocations(n int) []models.IPLocation {
+	locations := make([]models.IPLocation, n)
+	for i := range locations {
+		locations[i] = models.IPLocation{
+			IP:      fmt.Sprintf("10.%d.%d.%d", i/65536, (i/256)%256, i%256),
+			City:    fmt.Sprintf("City%d", i),
+			Country: fmt.Sprintf("Country%d", i%10),
+		}
+	}
+	return locations
+}
+
+// newThrottleClient connects a client to a fresh miniredis, returning it with a count of the IP keys written
+func newThrottleClient(t *testing.T) (*redis.Client, func() int) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client, func() int { return len(ipKeys(mr)) }
+}
+
+// TestThrottledBatchWriter_ThrottlesBetweenBatches tests that each batch of 500 waits for the rate
+func TestThrottledBatchWriter_ThrottlesBetweenBatches(t *testing.T) {
+	client, keys := newThrottleClient(t)
+
+	// 10,000 writes/sec = one batch of 500 every 50ms
+	writer := NewThrottledBatchWriter(context.Background(), client, 10000)
+	defer writer.Stop()
+
+	started := time.Now()
+	if err := writer.WriteIPBatch(throttleLocations(1500)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	elapsed := time.Since(started)
+
+	// Three batches, each followed by a 50ms wait
+	if elapsed < 140*time.Millisecond {
+		t.Errorf("expected 3 batches to take at least ~150ms, took %v", elapsed)
+	}
+	if got := keys(); got != 1500 {
+		t.Errorf("expected 1500 keys, got %d", got)
+	}
+}
+
+// TestThrottledBatchWriter_Unlimited tests that zero rps writes without waiting
+func TestThrottledBatchWriter_Unlimited(t *testing.T) {
+	client, keys := newThrottleClient(t)
+
+	writer := NewThrottledBatchWriter(context.Background(), client, 0)
+	defer writer.Stop()
+
+	started := time.Now()
+	if err := writer.WriteIPBatch(throttleLocations(5000)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Any throttle would need ticks between the 10 batches
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("expected an unthrottled write, took %v", elapsed)
+	}
+	if got := keys(); got != 5000 {
+		t.Errorf("expected 5000 keys, got %d", got)
+	}
+}
+
+// TestThrottledBatchWriter_Cancelled tests that cancelling the context stops a write waiting for its turn
+func TestThrottledBatchWriter_Cancelled(t *testing.T) {
+	client, keys := newThrottleClient(t)
+
+	// One batch every 5s: the second batch can't start before the cancel
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := NewThrottledBatchWriter(ctx, client, 100)
+	defer writer.Stop()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	started := time.Now()
+	err := writer.WriteIPBatch(throttleLocations(1000))
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("expected the wait to stop at the cancel, took %v", elapsed)
+	}
+	if got := keys(); got != 500 {
+		t.Errorf("expected only the first batch to be written, got %d keys", got)
+	}
+}
+
+// TestRedisStore_BulkLoadCSVParallel_Throttled tests that workers share one limit and every row is loaded
+func TestRedisStore_BulkLoadCSVParallel_Throttled(t *testing.T) {
+	store, mr := setupBulkRedis(t)
+	path := writeBulkCSV(t, 2000)
+
+	// 20,000 writes/sec = one batch every 25ms, shared by all 4 workers
+	store.SetWriteRPS(20000)
+
+	started := time.Now()
+	captureStdout(t, func() {
+		if err := store.BulkLoadCSVParallel(path, 4); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	elapsed := time.Since(started)
+
+	// However the rows are spread across workers, 2000 rows need at least 4 batches, each waiting a 25ms tick
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("expected the shared limit to take at least ~100ms, took %v", elapsed)
+	}
+	if got := len(ipKeys(mr)); got != 2000 {
+		t.Errorf("expected 2000 keys, got %d", got)
+	}
+}
+
+// BenchmarkThrottledBatchWriter_1000RPS measures 10,000 writes throttled to 1000/sec: about 10s per iteration
+func BenchmarkThrottledBatchWriter_1000RPS(b *testing.B) {
+	mr := miniredis.NewMiniRedis()
+	if err := mr.Start(); err != nil {
+		b.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	locations := throttleLocations(10000)
+
+	for i := 0; i < b.N; i++ {
+		writer := NewThrottledBatchWriter(context.Background(), client, 1000)
+		started := time.Now()
+		if err := writer.WriteIPBatch(locations); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		elapsed := time.Since(started)
+		writer.Stop()
+
+		b.ReportMetric(elapsed.Seconds(), "s/10k-writes")
+		b.ReportMetric(float64(len(locations))/elapsed.Seconds(), "writes/s")
+	}
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WeightedStore is one backend of a WeightedProxyStore
+type WeightedStore struct {
+	Store  Store
+	Weight int // Relative share of reads (0 = never read, only verified against)
+}
+
+// WeightedProxyStore spreads reads across several stores holding the same data
+// Used for A/B testing data sources, e.g. 90% of reads from Redis and 10% from MySQL:
+//   - FindByIP picks a store at random, with probability weight / total weight
+//   - With verification on (see SetVerify), the result is compared against every other
+//     store before returning, and each disagreement is logged and counted
+type WeightedProxyStore struct {
+	stores      []WeightedStore
+	totalWeight int
+
+	verify        bool               // Compare every read against the other stores
+	logger        *applogger.Logger  // Optional, see SetLogger
+	discrepancies prometheus.Counter // Optional, see SetDiscrepancyCounter
+}
+
+// NewWeightedProxyStore creates a store routing reads across stores by weight
+// Weights must not be negative, and at least one must be positive
+func NewWeightedProxyStore(stores []WeightedStore) (*WeightedProxyStore, error) {
+	if len(stores) == 0 {
+		return nil, fmt.Errorf("weighted store needs at least one store")
+	}
+
+	total := 0
+	for i, ws := range stores {
+		if ws.Weight < 0 {
+			return nil, fmt.Errorf("store %d has negative weight %d", i, ws.Weight)
+		}
+		total += ws.Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("weighted store needs at least one positive weight")
+	}
+
+	return &WeightedProxyStore{stores: stores, totalWeight: total}, nil
+}
+
+// SetVerify turns verification of every read against the other stores on or off
+// Verification queries every store on each lookup, so it multiplies the load on them
+func (s *WeightedProxyStore) SetVerify(verify bool) {
+	s.verify = verify
+}
+
+// SetLogger sets the logger receiving discrepancy reports
+func (s *WeightedProxyStore) SetLogger(log *applogger.Logger) {
+	s.logger = log
+}
+
+// SetDiscrepancyCounter sets the counter incremented for each discrepancy
+func (s *WeightedProxyStore) SetDiscrepancyCounter(counter prometheus.Counter) {
+	s.discrepancies = counter
+}
+
+// pick returns the index of a store chosen by weighted random selection
+func (s *WeightedProxyStore) pick() int {
+	n := rand.IntN(s.totalWeight)
+	for i, ws := range s.stores {
+		if n < ws.Weight {
+			return i
+		}
+		n -= ws.Weight
+	}
+	return len(s.stores) - 1 // Unreachable: n < totalWeight
+}
+
+// FindByIP looks up ip in a store picked by weight
+// Implements the Store interface method
+func (s *WeightedProxyStore) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	selected := s.pick()
+	location, err := s.stores[selected].Store.FindByIP(ctx, ip)
+
+	if s.verify {
+		s.compare(ctx, ip, selected, location, err)
+	}
+
+	return location, err
+}
+
+// compare looks up ip in every store but the selected one and reports each difference from its result
+func (s *WeightedProxyStore) compare(ctx context.Context, ip string, selected int, location *models.IPLocation, err error) {
+	for i, ws := range s.stores {
+		if i == selected {
+			continue
+		}
+
+		other, otherErr := ws.Store.FindByIP(ctx, ip)
+		if sameLookupResult(location, err, other, otherErr) {
+			continue
+		}
+
+		if s.discrepancies != nil {
+			s.discrepancies.Inc()
+		}
+		if s.logger != nil {
+			s.logger.Warn().
+				Str("ip", ip).
+				Int("selected_store", selected).
+				Int("other_store", i).
+				Str("selected", describeLookupResult(location, err)).
+				Str("other", describeLookupResult(other, otherErr)).
+				Msg("Weighted store discrepancy")
+		}
+	}
+}
+
+// Warmup warms up every store implementing WarmableStore
+// Implements the WarmableStore interface
+func (s *WeightedProxyStore) Warmup(ctx context.Context) error {
+	var errs []error
+	for i, ws := range s.stores {
+		if w, ok := ws.Store.(WarmableStore); ok {
+			if err := w.Warmup(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("store %d: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every store
+func (s *WeightedProxyStore) Close() error {
+	var errs []error
+	for _, ws := range s.stores {
+		errs = append(errs, ws.Store.Close())
+	}
+	return errors.Join(errs...)
+}
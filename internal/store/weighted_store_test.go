@@ -0,0 +1,124 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	applogger "github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+// TestWeightedProxyStore_AlwaysFirst tests that a 100/0 split never reads the second store
+func TestWeightedProxyStore_AlwaysFirst(t *testing.T) {
+	first, second := NewMockStore(), NewMockStore()
+	s, err := NewWeightedProxyStore([]WeightedStore{{Store: first, Weight: 100}, {Store: second, Weight: 0}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 100 {
+		if _, err := s.FindByIP(context.Background(), "8.8.8.8"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(first.FindByIPCalls) != 100 || len(second.FindByIPCalls) != 0 {
+		t.Errorf("expected 100/0 reads, got %d/%d", len(first.FindByIPCalls), len(second.FindByIPCalls))
+	}
+}
+
+// TestWeightedProxyStore_EvenSplit tests that a 50/50 split distributes reads roughly evenly
+func TestWeightedProxyStore_EvenSplit(t *testing.T) {
+	first, second := NewMockStore(), NewMockStore()
+	s, err := NewWeightedProxyStore([]WeightedStore{{Store: first, Weight: 50}, {Store: second, Weight: 50}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 1000 {
+		s.FindByIP(context.Background(), "8.8.8.8")
+	}
+
+	// 1000 fair coin flips fall outside 400-600 with probability ~1e-10
+	got := len(first.FindByIPCalls)
+	if got < 400 || got > 600 {
+		t.Errorf("expected roughly 500 reads from the first store, got %d", got)
+	}
+	if got+len(second.FindByIPCalls) != 1000 {
+		t.Errorf("expected 1000 reads in total, got %d", got+len(second.FindByIPCalls))
+	}
+}
+
+// TestWeightedProxyStore_Verify tests that verification counts and logs disagreeing stores
+func TestWeightedProxyStore_Verify(t *testing.T) {
+	first, second := NewMockStore(), NewMockStore()
+	second.Data["8.8.8.8"] = &models.IPLocation{IP: "8.8.8.8", City: "Stale City", Country: "United States"}
+
+	s, err := NewWeightedProxyStore([]WeightedStore{{Store: first, Weight: 1}, {Store: second, Weight: 0}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	zl := zerolog.New(&buf)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_weighted_discrepancy_total"})
+	s.SetLogger(&applogger.Logger{Logger: &zl})
+	s.SetDiscrepancyCounter(counter)
+
+	// Verification off: the other store is never queried
+	s.FindByIP(context.Background(), "8.8.8.8")
+	if len(second.FindByIPCalls) != 0 || testutil.ToFloat64(counter) != 0 {
+		t.Fatalf("expected no verification while it's off")
+	}
+
+	s.SetVerify(true)
+	location, err := s.FindByIP(context.Background(), "8.8.8.8")
+	if err != nil || location.City != "Mountain View" {
+		t.Fatalf("expected the selected store's result, got %+v, %v", location, err)
+	}
+	if got := testutil.ToFloat64(counter); got != 1 {
+		t.Errorf("expected 1 discrepancy, got %v", got)
+	}
+	if !strings.Contains(buf.String(), "Stale City") {
+		t.Errorf("expected the other store's result to be logged, got %q", buf.String())
+	}
+
+	// Agreeing stores are not a discrepancy
+	s.FindByIP(context.Background(), "1.1.1.1")
+	if got := testutil.ToFloat64(counter); got != 1 {
+		t.Errorf("expected agreeing stores not to be counted, got %v", got)
+	}
+}
+
+// TestNewWeightedProxyStore_InvalidWeights tests the constructor's validation
+func TestNewWeightedProxyStore_InvalidWeights(t *testing.T) {
+	tests := map[string][]WeightedStore{
+		"no stores":       nil,
+		"negative weight": {{Store: NewMockStore(), Weight: -1}, {Store: NewMockStore(), Weight: 2}},
+		"all zero":        {{Store: NewMockStore(), Weight: 0}},
+	}
+
+	for name, stores := range tests {
+		if _, err := NewWeightedProxyStore(stores); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+// TestWeightedProxyStore_Close tests that every store is closed
+func TestWeightedProxyStore_Close(t *testing.T) {
+	first, second := NewMockStore(), NewMockStore()
+	s, _ := NewWeightedProxyStore([]WeightedStore{{Store: first, Weight: 1}, {Store: second, Weight: 1}})
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !first.CloseCalled || !second.CloseCalled {
+		t.Error("expected both stores to be closed")
+	}
+}
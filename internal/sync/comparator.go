@@ -0,0 +1,201 @@
+// Package sync checks that the IP data loaded into a datastore still matches the file it was loaded from
+package sync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"golang.org/x/sync/errgroup"
+)
+
+// MaxDriftExamples is how many IPs of each kind of drift a DriftReport lists
+const MaxDriftExamples = 10
+
+// DriftReport is the outcome of a comparison of the Redis store against the CSV file
+type DriftReport struct {
+	Missing  int // IPs in the CSV file but not in Redis
+	Extra    int // IPs in Redis but not in the CSV file, e.g. stale data never expired
+	Mismatch int // IPs in both whose city or country differ
+
+	// Up to MaxDriftExamples IPs of each kind, sorted, to start investigating from
+	MissingIPs    []string
+	ExtraIPs      []string
+	MismatchedIPs []string
+}
+
+// Total returns the number of IPs that differ, of any kind
+func (r DriftReport) Total() int {
+	return r.Missing + r.Extra + r.Mismatch
+}
+
+// Comparator compares the Redis store against the canonical CSV file, record by record
+// Silent drift (stale entries, a partial load) otherwise goes unnoticed until a lookup returns the wrong country
+type Comparator struct {
+	redisStore store.Iterator
+	csvStore   store.Iterator
+	metrics    *metrics.Metrics
+	log        *logger.Logger
+
+	threshold int               // Drift above which alertFn is called
+	alertFn   func(DriftReport) // Called after a comparison finding more than threshold differences
+}
+
+// NewComparator creates a comparator of redisStore against csvStore
+// Drift above threshold is logged as an error; SetAlertFunc replaces that
+func NewComparator(redisStore, csvStore store.Iterator, threshold int, m *metrics.Metrics, log *logger.Logger) *Comparator {
+	c := &Comparator{
+		redisStore: redisStore,
+		csvStore:   csvStore,
+		metrics:    m,
+		log:        log,
+		threshold:  threshold,
+	}
+	c.alertFn = c.logDrift
+	return c
+}
+
+// SetAlertFunc replaces the function called when a comparison finds more than the threshold of differences
+// e.g. to page someone rather than log an error
+func (c *Comparator) SetAlertFunc(fn func(report DriftReport)) {
+	c.alertFn = fn
+}
+
+// logDrift is the default alert function: it logs the report as an error
+func (c *Comparator) logDrift(report DriftReport) {
+	c.log.Error().
+		Int("missing", report.Missing).
+		Int("extra", report.Extra).
+		Int("mismatch", report.Mismatch).
+		Strs("missing_ips", report.MissingIPs).
+		Strs("extra_ips", report.ExtraIPs).
+		Strs("mismatched_ips", report.MismatchedIPs).
+		Int("threshold", c.threshold).
+		Msg("Redis datastore has drifted from the CSV file")
+}
+
+// Compare reads both stores in parallel and reports how they differ
+// The store_drift_total gauge is updated, and the alert function called when the drift is above the threshold.
+// When the CSV store can reload (store.Reloader), it's reloaded first, so the comparison is with the file as it is now.
+// Cancelling ctx stops both iterations and returns ctx's error, leaving the gauge as it was
+func (c *Comparator) Compare(ctx context.Context) (DriftReport, error) {
+	if reloader, ok := c.csvStore.(store.Reloader); ok {
+		if _, err := reloader.Reload(); err != nil {
+			c.log.Warn().Err(err).Msg("Failed to reload the CSV file, comparing with the previous data")
+		}
+	}
+
+	var redisData, csvData map[string]models.IPLocation
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		redisData, err = collect(gctx, c.redisStore)
+		if err != nil {
+			return fmt.Errorf("failed to read the Redis store: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() (err error) {
+		csvData, err = collect(gctx, c.csvStore)
+		if err != nil {
+			return fmt.Errorf("failed to read the CSV file: %w", err)
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return DriftReport{}, ctx.Err()
+		}
+		return DriftReport{}, err
+	}
+
+	report := diff(redisData, csvData)
+	c.metrics.StoreDrift.WithLabelValues("missing").Set(float64(report.Missing))
+	c.metrics.StoreDrift.WithLabelValues("extra").Set(float64(report.Extra))
+	c.metrics.StoreDrift.WithLabelValues("mismatch").Set(float64(report.Mismatch))
+
+	if report.Total() > c.threshold {
+		c.alertFn(report)
+	}
+	return report, nil
+}
+
+// Run calls Compare every interval until ctx is cancelled. Failed comparisons are logged and retried at the next tick
+// Blocks until ctx is cancelled, so run it in its own goroutine
+func (c *Comparator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		report, err := c.Compare(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.log.Error().Err(err).Msg("Failed to compare the Redis datastore with the CSV file")
+			}
+			continue
+		}
+		c.log.Info().
+			Int("missing", report.Missing).
+			Int("extra", report.Extra).
+			Int("mismatch", report.Mismatch).
+			Dur("duration", time.Since(start)).
+			Msg("Redis datastore compared with the CSV file")
+	}
+}
+
+// collect reads every record of it into a map by IP, stopping early once ctx is done
+func collect(ctx context.Context, it store.Iterator) (map[string]models.IPLocation, error) {
+	data := make(map[string]models.IPLocation)
+	err := it.Iterate(func(location *models.IPLocation) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data[location.IP] = *location
+		return nil
+	})
+	return data, err
+}
+
+// diff compares the records of Redis with those of the CSV file
+func diff(redisData, csvData map[string]models.IPLocation) DriftReport {
+	var report DriftReport
+	for ip, expected := range csvData {
+		actual, ok := redisData[ip]
+		switch {
+		case !ok:
+			report.Missing++
+			report.MissingIPs = append(report.MissingIPs, ip)
+		case actual.City != expected.City || actual.Country != expected.Country:
+			report.Mismatch++
+			report.MismatchedIPs = append(report.MismatchedIPs, ip)
+		}
+	}
+	for ip := range redisData {
+		if _, ok := csvData[ip]; !ok {
+			report.Extra++
+			report.ExtraIPs = append(report.ExtraIPs, ip)
+		}
+	}
+
+	report.MissingIPs = examples(report.MissingIPs)
+	report.ExtraIPs = examples(report.ExtraIPs)
+	report.MismatchedIPs = examples(report.MismatchedIPs)
+	return report
+}
+
+// examples returns the first MaxDriftExamples of ips once sorted
+func examples(ips []string) []string {
+	slices.Sort(ips)
+	return ips[:min(len(ips), MaxDriftExamples)]
+}
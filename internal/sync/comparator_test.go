@@ -0,0 +1,230 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+// newFixtureStore returns a mock store holding the same 3 records as every other fixture store
+func newFixtureStore() *store.MockStore {
+	s := store.NewEmptyMockStore()
+	s.Data = map[string]*models.IPLocation{
+		"8.8.8.8": {IP: "8.8.8.8", City: "Mountain View", Country: "United States"},
+		"1.1.1.1": {IP: "1.1.1.1", City: "Sydney", Country: "Australia"},
+		"9.9.9.9": {IP: "9.9.9.9", City: "Berkeley", Country: "United States"},
+	}
+	return s
+}
+
+// newTestComparator returns a comparator of redisStore against csvStore with its metrics on a private registry,
+// logging to buf
+func newTestComparator(redisStore, csvStore store.Iterator, threshold int, buf *bytes.Buffer) (*Comparator, *metrics.Metrics) {
+	zl := zerolog.New(buf)
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	return NewComparator(redisStore, csvStore, threshold, m, &logger.Logger{Logger: &zl}), m
+}
+
+// TestComparator_Compare tests the drift found for controlled differences between the stores, and the gauge set for each kind
+func TestComparator_Compare(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(redis *store.MockStore)
+		expected DriftReport
+	}{
+		{
+			name:     "identical",
+			modify:   func(redis *store.MockStore) {},
+			expected: DriftReport{},
+		},
+		{
+			name:     "missing from Redis",
+			modify:   func(redis *store.MockStore) { delete(redis.Data, "1.1.1.1") },
+			expected: DriftReport{Missing: 1, MissingIPs: []string{"1.1.1.1"}},
+		},
+		{
+			name: "extra in Redis",
+			modify: func(redis *store.MockStore) {
+				redis.Data["4.4.4.4"] = &models.IPLocation{IP: "4.4.4.4", City: "Broomfield", Country: "United States"}
+			},
+			expected: DriftReport{Extra: 1, ExtraIPs: []string{"4.4.4.4"}},
+		},
+		{
+			name: "mismatch",
+			modify: func(redis *store.MockStore) {
+				redis.Data["1.1.1.1"] = &models.IPLocation{IP: "1.1.1.1", City: "Melbourne", Country: "Australia"}
+			},
+			expected: DriftReport{Mismatch: 1, MismatchedIPs: []string{"1.1.1.1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redis := newFixtureStore()
+			tt.modify(redis)
+			c, m := newTestComparator(redis, newFixtureStore(), 100, &bytes.Buffer{})
+
+			report, err := c.Compare(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if report.Missing != tt.expected.Missing || report.Extra != tt.expected.Extra || report.Mismatch != tt.expected.Mismatch {
+				t.Errorf("expected %+v, got %+v", tt.expected, report)
+			}
+			if strings.Join(report.MissingIPs, ",") != strings.Join(tt.expected.MissingIPs, ",") ||
+				strings.Join(report.ExtraIPs, ",") != strings.Join(tt.expected.ExtraIPs, ",") ||
+				strings.Join(report.MismatchedIPs, ",") != strings.Join(tt.expected.MismatchedIPs, ",") {
+				t.Errorf("expected the IPs of %+v, got %+v", tt.expected, report)
+			}
+
+			gauges := map[string]int{"missing": tt.expected.Missing, "extra": tt.expected.Extra, "mismatch": tt.expected.Mismatch}
+			for kind, expected := range gauges {
+				if got := testutil.ToFloat64(m.StoreDrift.WithLabelValues(kind)); got != float64(expected) {
+					t.Errorf("expected store_drift_total{type=%q} %d, got %v", kind, expected, got)
+				}
+			}
+		})
+	}
+}
+
+// TestComparator_Alert tests that the alert function is called only when the drift is above the threshold
+func TestComparator_Alert(t *testing.T) {
+	redis := newFixtureStore()
+	delete(redis.Data, "1.1.1.1")
+	redis.Data["1.1.1.2"] = &models.IPLocation{IP: "1.1.1.2", City: "Sydney", Country: "Australia"}
+
+	for _, tt := range []struct {
+		threshold int
+		alerted   bool
+	}{{2, false}, {1, true}} {
+		c, _ := newTestComparator(redis, newFixtureStore(), tt.threshold, &bytes.Buffer{})
+		var alerts []DriftReport
+		c.SetAlertFunc(func(report DriftReport) { alerts = append(alerts, report) })
+
+		if _, err := c.Compare(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := len(alerts) == 1; got != tt.alerted {
+			t.Errorf("drift 2 with threshold %d: expected alerted=%v, got %v", tt.threshold, tt.alerted, alerts)
+		}
+		if tt.alerted && alerts[0].Total() != 2 {
+			t.Errorf("expected the report of 2 differences, got %+v", alerts[0])
+		}
+	}
+}
+
+// TestComparator_DefaultAlert tests that without SetAlertFunc, drift above the threshold is logged as an error
+func TestComparator_DefaultAlert(t *testing.T) {
+	var buf bytes.Buffer
+	c, _ := newTestComparator(store.NewEmptyMockStore(), newFixtureStore(), 0, &buf)
+
+	if _, err := c.Compare(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{`"level":"error"`, `"missing":3`, `"missing_ips":["1.1.1.1","8.8.8.8","9.9.9.9"]`, "drifted"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %s in the log, got %s", expected, buf.String())
+		}
+	}
+}
+
+// cancellingIterator yields count records, cancelling its context after the first one
+type cancellingIterator struct {
+	cancel  context.CancelFunc
+	count   int
+	yielded int
+}
+
+func (it *cancellingIterator) Iterate(fn func(location *models.IPLocation) error) error {
+	for i := 0; i < it.count; i++ {
+		it.yielded++
+		if err := fn(&models.IPLocation{IP: fmt.Sprintf("10.0.%d.%d", i/256, i%256), City: "City", Country: "Country"}); err != nil {
+			return err
+		}
+		if i == 0 {
+			it.cancel()
+		}
+	}
+	return nil
+}
+
+// TestComparator_Compare_ContextCancelled tests that cancelling the context stops iteration and leaves the gauge alone
+func TestComparator_Compare_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	redis := &cancellingIterator{cancel: cancel, count: 1000}
+	c, m := newTestComparator(redis, newFixtureStore(), 0, &bytes.Buffer{})
+	alerted := false
+	c.SetAlertFunc(func(DriftReport) { alerted = true })
+
+	_, err := c.Compare(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if redis.yielded >= redis.count {
+		t.Errorf("expected iteration to stop early, got all %d records", redis.yielded)
+	}
+	if alerted {
+		t.Error("expected no alert for a cancelled comparison")
+	}
+	if got := testutil.CollectAndCount(m.StoreDrift); got != 0 {
+		t.Errorf("expected no drift recorded, got %d series", got)
+	}
+}
+
+// TestComparator_Compare_ReloadsCSV tests that a CSV store is reloaded before each comparison, so changes to the file count
+func TestComparator_Compare_ReloadsCSV(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "ips.csv")
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n9.9.9.9,Berkeley,United States\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	csvStore, err := store.NewCSVStore(csvPath)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	defer csvStore.Close()
+	c, _ := newTestComparator(newFixtureStore(), csvStore, 100, &bytes.Buffer{})
+
+	if report, err := c.Compare(context.Background()); err != nil || report.Total() != 0 {
+		t.Fatalf("expected no drift, got %+v, %v", report, err)
+	}
+
+	if err := os.WriteFile(csvPath, []byte("ip,city,country\n8.8.8.8,Mountain View,United States\n1.1.1.1,Sydney,Australia\n"), 0644); err != nil {
+		t.Fatalf("failed to update test file: %v", err)
+	}
+	report, err := c.Compare(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Extra != 1 || report.Total() != 1 {
+		t.Errorf("expected 9.9.9.9 to be extra once removed from the file, got %+v", report)
+	}
+}
+
+// TestComparator_Compare_Error tests that a store failing to iterate is an error
+func TestComparator_Compare_Error(t *testing.T) {
+	c, _ := newTestComparator(newFixtureStore(), &failingIterator{}, 0, &bytes.Buffer{})
+
+	if _, err := c.Compare(context.Background()); err == nil || !strings.Contains(err.Error(), "CSV") {
+		t.Errorf("expected an error reading the CSV file, got %v", err)
+	}
+}
+
+// failingIterator fails to iterate
+type failingIterator struct{}
+
+func (failingIterator) Iterate(fn func(location *models.IPLocation) error) error {
+	return errors.New("read failed")
+}
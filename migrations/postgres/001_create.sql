@@ -0,0 +1,15 @@
+-- Migration 001: ip2country table with native inet columns
+-- Each row covers the addresses from ip_start to ip_end (equal for single-IP rows)
+-- The GiST indexes serve the inet comparisons (<=, >=) used by FindByIP
+
+CREATE TABLE IF NOT EXISTS ip2country (
+    ip_start inet NOT NULL,
+    ip_end   inet NOT NULL,
+    city     text NOT NULL DEFAULT '',
+    country  text NOT NULL DEFAULT '',
+    PRIMARY KEY (ip_start, ip_end),
+    CHECK (family(ip_start) = family(ip_end) AND ip_start <= ip_end)
+);
+
+CREATE INDEX IF NOT EXISTS ip2country_ip_start_idx ON ip2country USING gist (ip_start inet_ops);
+CREATE INDEX IF NOT EXISTS ip2country_ip_end_idx ON ip2country USING gist (ip_end inet_ops);
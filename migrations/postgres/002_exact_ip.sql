@@ -0,0 +1,9 @@
+-- Migration 002: exact-match column for single-IP rows
+-- ip is ip_start for rows covering a single address and NULL for ranges, so FindByIP can look single IPs up
+-- with an equality on a B-tree index before falling back to the range comparison
+-- Generated, so rows keep being written as (ip_start, ip_end) only
+
+ALTER TABLE ip2country ADD COLUMN IF NOT EXISTS ip inet
+    GENERATED ALWAYS AS (CASE WHEN ip_start = ip_end THEN ip_start END) STORED;
+
+CREATE INDEX IF NOT EXISTS ip2country_ip_idx ON ip2country (ip);
@@ -0,0 +1,20 @@
+// Package postgres holds the PostgreSQL schema migrations, embedded so the server can apply them itself
+package postgres
+
+import _ "embed"
+
+// Migration_001_CreateInetTable creates the ip2country table with inet range columns
+//
+//go:embed 001_create.sql
+var Migration_001_CreateInetTable string
+
+// Migration_002_AddExactIPColumn adds the generated ip column FindByIP matches single IPs on
+//
+//go:embed 002_exact_ip.sql
+var Migration_002_AddExactIPColumn string
+
+// All lists the migrations in the order they must be applied
+var All = []string{
+	Migration_001_CreateInetTable,
+	Migration_002_AddExactIPColumn,
+}
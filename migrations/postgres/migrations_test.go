@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+)
+
+// TestMigrations_Parse tests that every migration is valid PostgreSQL
+func TestMigrations_Parse(t *testing.T) {
+	for i, migration := range All {
+		if strings.TrimSpace(migration) == "" {
+			t.Errorf("migration %03d is empty", i+1)
+			continue
+		}
+		if _, err := pg_query.Parse(migration); err != nil {
+			t.Errorf("migration %03d is not valid SQL: %v", i+1, err)
+		}
+	}
+}
+
+// TestMigration_001_CreateInetTable tests that the ip2country range columns use the inet type
+func TestMigration_001_CreateInetTable(t *testing.T) {
+	tree, err := pg_query.Parse(Migration_001_CreateInetTable)
+	if err != nil {
+		t.Fatalf("failed to parse migration: %v", err)
+	}
+
+	columns := map[string]string{}
+	for _, raw := range tree.Stmts {
+		create := raw.Stmt.GetCreateStmt()
+		if create == nil || create.Relation.Relname != "ip2country" {
+			continue
+		}
+		for _, elt := range create.TableElts {
+			def := elt.GetColumnDef()
+			if def == nil {
+				continue
+			}
+			names := def.TypeName.Names
+			columns[def.Colname] = names[len(names)-1].GetString_().Sval
+		}
+	}
+
+	for _, column := range []string{"ip_start", "ip_end"} {
+		if columns[column] != "inet" {
+			t.Errorf("expected column %s to be inet, got %q", column, columns[column])
+		}
+	}
+}
+
+// TestMigration_002_AddExactIPColumn tests that the ip column is a stored column generated from the range
+func TestMigration_002_AddExactIPColumn(t *testing.T) {
+	tree, err := pg_query.Parse(Migration_002_AddExactIPColumn)
+	if err != nil {
+		t.Fatalf("failed to parse migration: %v", err)
+	}
+
+	found := false
+	for _, raw := range tree.Stmts {
+		alter := raw.Stmt.GetAlterTableStmt()
+		if alter == nil || alter.Relation.Relname != "ip2country" {
+			continue
+		}
+		for _, cmd := range alter.Cmds {
+			def := cmd.GetAlterTableCmd().GetDef().GetColumnDef()
+			if def == nil || def.Colname != "ip" {
+				continue
+			}
+			found = true
+			names := def.TypeName.Names
+			if got := names[len(names)-1].GetString_().Sval; got != "inet" {
+				t.Errorf("expected column ip to be inet, got %q", got)
+			}
+			generated := false
+			for _, constraint := range def.Constraints {
+				if constraint.GetConstraint().GetContype() == pg_query.ConstrType_CONSTR_GENERATED {
+					generated = true
+				}
+			}
+			if !generated {
+				t.Error("expected column ip to be generated")
+			}
+		}
+	}
+	if !found {
+		t.Error("expected the migration to add column ip to ip2country")
+	}
+}
@@ -0,0 +1,156 @@
+// Package cache is a size-bounded LRU cache whose entries can each expire after their own TTL,
+// safe for concurrent use. The store decorators cache lookups with it (see store.StaleStore)
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// DefaultSize is the number of entries a Cache holds when created with a size <= 0
+const DefaultSize = 10000
+
+// CacheStats counts a cache's lookups and evictions since it was created
+type CacheStats struct {
+	Hits      uint64 // Get calls that found a live entry
+	Misses    uint64 // Get calls that found no entry, or an expired one
+	Evictions uint64 // Entries removed to make room for new ones (not expirations or deletes)
+	Len       int    // Entries held now, including expired ones not yet swept
+}
+
+// entry is a cached value and when it expires
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time // Zero = never
+}
+
+// expired reports whether e has expired at now
+func (e entry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// Cache is an LRU cache of at most size entries, each with its own TTL
+// Once full, setting a new key evicts the least recently used entry. Expired entries are
+// misses for Get, and removed by a background sweep every sweepInterval (see New)
+type Cache[K comparable, V any] struct {
+	// mu guards lru and stats; Get moves the entry to the front, so reads need it exclusively too
+	mu    sync.Mutex
+	lru   *simplelru.LRU[K, entry[V]]
+	stats CacheStats
+
+	stop      chan struct{} // Closed by Close to stop the sweep (nil = no sweep)
+	closeOnce sync.Once
+}
+
+// New creates a cache of size entries (size <= 0 uses DefaultSize)
+// With a sweepInterval > 0, a goroutine removes expired entries at that interval until Close is called;
+// otherwise they're only removed when Get finds them, or evicted like any other entry
+func New[K comparable, V any](size int, sweepInterval time.Duration) *Cache[K, V] {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	lru, _ := simplelru.NewLRU[K, entry[V]](size, nil) // Only fails for size <= 0
+
+	c := &Cache[K, V]{lru: lru}
+	if sweepInterval > 0 {
+		c.stop = make(chan struct{})
+		go c.sweep(sweepInterval)
+	}
+	return c
+}
+
+// Set caches value for key, replacing any previous value, for ttl (<= 0 = until evicted)
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+	e := entry[V]{value: value}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru.Add(key, e) {
+		c.stats.Evictions++
+	}
+}
+
+// Get returns the value cached for key, and whether there was a live one
+// An expired entry is removed and counted as a miss
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lru.Get(key)
+	if ok && e.expired(time.Now()) {
+		c.lru.Remove(key)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	return e.value, true
+}
+
+// Delete removes key from the cache, if it's there
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Remove(key)
+}
+
+// Clear removes every entry. The stats are kept
+func (c *Cache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Purge()
+}
+
+// Stats returns the cache's counters and current size
+func (c *Cache[K, V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = c.lru.Len()
+	return stats
+}
+
+// Close stops the background sweep. The cache keeps working, with expired entries removed by Get only
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+		}
+	})
+}
+
+// sweep removes expired entries every interval until Close is called
+func (c *Cache[K, V]) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.removeExpired()
+		}
+	}
+}
+
+// removeExpired removes every expired entry
+func (c *Cache[K, V]) removeExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range c.lru.Keys() {
+		if e, ok := c.lru.Peek(key); ok && e.expired(now) {
+			c.lru.Remove(key)
+		}
+	}
+}
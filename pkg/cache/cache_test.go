@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCache_Get tests that a set key is a hit and an unknown key a miss
+func TestCache_Get(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Set("a", 1, time.Minute)
+
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Errorf("expected a hit of 1, got %d, %v", value, ok)
+	}
+	if value, ok := c.Get("b"); ok || value != 0 {
+		t.Errorf("expected a miss of the zero value, got %d, %v", value, ok)
+	}
+}
+
+// TestCache_Expired tests that an expired entry is a miss and is removed, while ttl <= 0 never expires
+func TestCache_Expired(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Set("short", 1, time.Millisecond)
+	c.Set("forever", 2, 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("short"); ok {
+		t.Error("expected the expired entry to be a miss")
+	}
+	if _, ok := c.Get("forever"); !ok {
+		t.Error("expected an entry without a TTL to be a hit")
+	}
+	if got := c.Stats().Len; got != 1 {
+		t.Errorf("expected the expired entry removed, got %d entries", got)
+	}
+}
+
+// TestCache_Sweep tests that the background sweep removes expired entries that are never read
+func TestCache_Sweep(t *testing.T) {
+	c := New[string, int](10, 5*time.Millisecond)
+	defer c.Close()
+	c.Set("short", 1, time.Millisecond)
+	c.Set("long", 2, time.Hour)
+
+	deadline := time.Now().Add(time.Second)
+	for c.Stats().Len != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := c.Stats().Len; got != 1 {
+		t.Errorf("expected the sweep to leave 1 entry, got %d", got)
+	}
+	c.Close() // Twice is fine
+}
+
+// TestCache_EvictsAtCapacity tests that the least recently used entry is evicted once the cache is full
+func TestCache_EvictsAtCapacity(t *testing.T) {
+	c := New[string, int](2, 0)
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Get("a") // b is now the least recently used
+	c.Set("c", 3, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Len != 2 {
+		t.Errorf("expected 1 eviction and 2 entries, got %+v", stats)
+	}
+}
+
+// TestCache_DeleteAndClear tests that deleted and cleared keys are misses
+func TestCache_DeleteAndClear(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+
+	c.Delete("a")
+	c.Delete("missing")
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a deleted key to be a miss")
+	}
+	c.Clear()
+	if _, ok := c.Get("b"); ok || c.Stats().Len != 0 {
+		t.Errorf("expected an empty cache, got %+v", c.Stats())
+	}
+}
+
+// TestCache_Stats tests that hits and misses are counted
+func TestCache_Stats(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Set("a", 1, 0)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+
+	expected := CacheStats{Hits: 2, Misses: 1, Len: 1}
+	if got := c.Stats(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+// TestCache_ConcurrentWriteThenRead tests that values written concurrently are all read back (run with -race)
+func TestCache_ConcurrentWriteThenRead(t *testing.T) {
+	c := New[string, int](1000, time.Millisecond)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("key-%d", i)
+			c.Set(key, i, time.Minute)
+			if value, ok := c.Get(key); !ok || value != i {
+				t.Errorf("%s: expected %d, got %d, %v", key, i, value, ok)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Hits != 100 || stats.Len != 100 {
+		t.Errorf("expected 100 hits and entries, got %+v", stats)
+	}
+}
+
+// BenchmarkCache_Get_Parallel benchmarks concurrent reads of a full cache
+func BenchmarkCache_Get_Parallel(b *testing.B) {
+	c := New[string, int](1000, time.Second)
+	defer c.Close()
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		c.Set(keys[i], i, time.Hour)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Package client is a Go client for the IP2Country HTTP API
+// Used by the tools in cmd/ that talk to a running server rather than to a store directly
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// DefaultTimeout bounds each request when no http.Client is given
+const DefaultTimeout = 10 * time.Second
+
+// ErrNotFound is returned by FindCountry when the server has no data for the IP (404)
+// It is pkg/errors.ErrNotFound, so callers can check for either
+var ErrNotFound = pkerr.ErrNotFound
+
+// Location is the result of a lookup
+type Location struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+	ISP     string `json:"isp,omitempty"`
+	ASN     int    `json:"asn,omitempty"`
+}
+
+// StatusError is returned for responses other than 200 and 404
+type StatusError struct {
+	StatusCode int
+	Message    string // The "error" field of the response body, if any
+}
+
+// Error formats the status code and the server's message
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the API of one server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the server at baseURL (e.g. http://localhost:3000)
+// A nil httpClient uses one with DefaultTimeout
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// FindCountry looks up ip with GET /v1/find-country
+// Returns ErrNotFound on 404, and a *StatusError for any other failed response
+func (c *Client) FindCountry(ctx context.Context, ip string) (*Location, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/find-country?ip="+url.QueryEscape(ip), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var location Location
+		if err := json.NewDecoder(resp.Body).Decode(&location); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return &location, nil
+
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+
+	default:
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body) // Best effort: the status code is enough on its own
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+}
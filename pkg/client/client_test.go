@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClient_FindCountry tests the request sent and how each response status is returned
+func TestClient_FindCountry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/find-country" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		switch r.URL.Query().Get("ip") {
+		case "8.8.8.8":
+			w.Write([]byte(`{"city":"Mountain View","country":"United States"}`))
+		case "9.9.9.9":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"IP address not found"}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"Rate limit exceeded"}`))
+		}
+	}))
+	defer server.Close()
+
+	// A trailing slash on the base URL is tolerated
+	c := New(server.URL+"/", nil)
+
+	location, err := c.FindCountry(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" || location.Country != "United States" {
+		t.Errorf("unexpected location %+v", location)
+	}
+
+	if _, err := c.FindCountry(context.Background(), "9.9.9.9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_, err = c.FindCountry(context.Background(), "1.1.1.1")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests || statusErr.Message != "Rate limit exceeded" {
+		t.Errorf("expected a 429 StatusError, got %v", err)
+	}
+}
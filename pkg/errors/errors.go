@@ -0,0 +1,22 @@
+// Package errors defines the sentinel errors shared by the stores, the service and the handlers
+// Compare with errors.Is rather than the message: stores may wrap them with more context.
+// Import it under another name (pkerr) so it doesn't shadow the standard library's errors
+package errors
+
+import "errors"
+
+// Errors returned by lookups
+var (
+	// ErrNotFound is returned when a store has no data for an IP address (404)
+	ErrNotFound = errors.New("IP address not found")
+	// ErrInvalidIP is returned for anything that isn't an IPv4 or IPv6 address (400)
+	ErrInvalidIP = errors.New("invalid IP address format")
+	// ErrStoreTimeout is wrapped by lookups that didn't get an answer from the store in time (504)
+	ErrStoreTimeout = errors.New("store timeout")
+	// ErrUnroutableIP is wrapped with ErrNotFound for addresses no datastore can locate (private, loopback, ...)
+	ErrUnroutableIP = errors.New("IP is not publicly routable")
+)
+
+// ErrNotSupported is wrapped by operations the configured store doesn't implement, e.g.
+// fmt.Errorf("searching is %w", ErrNotSupported) (501)
+var ErrNotSupported = errors.New("not supported by this store")
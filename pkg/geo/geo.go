@@ -0,0 +1,76 @@
+// Package geo provides geographic arithmetic on IP locations: the distance between two
+// locations, and whether moving between them in a given time is physically plausible
+package geo
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// EarthRadiusKm is the mean radius of the Earth used by Distance
+const EarthRadiusKm = 6371.0
+
+// DefaultMaxTravelSpeedKmh is the speed above which IsSuspiciousTravel flags a move: faster than an airliner
+const DefaultMaxTravelSpeedKmh = 1000.0
+
+// ErrNoCoordinates is returned by Distance when a location has no coordinates (latitude and longitude both 0)
+// Only the MaxMind store provides coordinates
+var ErrNoCoordinates = errors.New("location has no coordinates")
+
+// Distance returns the great-circle distance between a and b in kilometres, using the Haversine formula
+// Accurate to about 0.5%, the Earth not being a perfect sphere
+func Distance(a, b models.IPLocation) (float64, error) {
+	if !HasCoordinates(a) || !HasCoordinates(b) {
+		return 0, ErrNoCoordinates
+	}
+
+	return DistanceBetween(
+		models.Coordinates{Latitude: a.Latitude, Longitude: a.Longitude},
+		models.Coordinates{Latitude: b.Latitude, Longitude: b.Longitude},
+	), nil
+}
+
+// DistanceBetween returns the great-circle distance between two points in kilometres, like Distance
+// 0, 0 is a point like any other here (in the Gulf of Guinea)
+func DistanceBetween(a, b models.Coordinates) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// IsSuspiciousTravel reports whether getting from prev to curr in elapsed would take more than
+// DefaultMaxTravelSpeedKmh, e.g. two logins of the same account from opposite sides of the world
+// See IsSuspiciousTravelAt
+func IsSuspiciousTravel(prev, curr models.IPLocation, elapsed time.Duration) bool {
+	return IsSuspiciousTravelAt(prev, curr, elapsed, DefaultMaxTravelSpeedKmh)
+}
+
+// IsSuspiciousTravelAt reports whether getting from prev to curr in elapsed would take more than maxSpeedKmh
+// Locations without coordinates are never suspicious, as nothing is known about them. With no time
+// elapsed (or a negative time, from clock skew), any distance at all is suspicious
+func IsSuspiciousTravelAt(prev, curr models.IPLocation, elapsed time.Duration, maxSpeedKmh float64) bool {
+	km, err := Distance(prev, curr)
+	if err != nil || km == 0 {
+		return false
+	}
+	if elapsed <= 0 {
+		return true
+	}
+	return km/elapsed.Hours() > maxSpeedKmh
+}
+
+// HasCoordinates reports whether loc has coordinates; 0, 0 is the zero value, not a place anyone is
+func HasCoordinates(loc models.IPLocation) bool {
+	return loc.Latitude != 0 || loc.Longitude != 0
+}
+
+// radians converts degrees to radians
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
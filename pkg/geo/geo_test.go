@@ -0,0 +1,118 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+var (
+	london       = models.IPLocation{City: "London", Latitude: 51.5074, Longitude: -0.1278}
+	newYork      = models.IPLocation{City: "New York", Latitude: 40.7128, Longitude: -74.0060}
+	paris        = models.IPLocation{City: "Paris", Latitude: 48.8566, Longitude: 2.3522}
+	sydney       = models.IPLocation{City: "Sydney", Latitude: -33.8688, Longitude: 151.2093}
+	noCoordinate = models.IPLocation{City: "Mountain View"}
+)
+
+// TestDistance tests the Haversine formula against known distances between cities
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     models.IPLocation
+		expected float64 // km
+	}{
+		{"London to New York", london, newYork, 5570},
+		{"New York to London", newYork, london, 5570},
+		{"London to Paris", london, paris, 344},
+		{"London to Sydney", london, sydney, 16994},
+		{"same place", paris, paris, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Distance(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// Within 0.5%, or 1 km for short distances
+			if math.Abs(got-tt.expected) > math.Max(1, tt.expected*0.005) {
+				t.Errorf("expected about %v km, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestDistance_Antipodes tests that opposite points of the globe are half the circumference apart
+func TestDistance_Antipodes(t *testing.T) {
+	a := models.IPLocation{Latitude: 45, Longitude: 10}
+	b := models.IPLocation{Latitude: -45, Longitude: -170}
+
+	got, err := Distance(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := math.Pi * EarthRadiusKm; math.Abs(got-expected) > 0.001 {
+		t.Errorf("expected %v km, got %v", expected, got)
+	}
+}
+
+// TestDistance_NoCoordinates tests that a location without coordinates is an error, on either side
+func TestDistance_NoCoordinates(t *testing.T) {
+	if _, err := Distance(noCoordinate, london); !errors.Is(err, ErrNoCoordinates) {
+		t.Errorf("expected ErrNoCoordinates, got %v", err)
+	}
+	if _, err := Distance(london, noCoordinate); !errors.Is(err, ErrNoCoordinates) {
+		t.Errorf("expected ErrNoCoordinates, got %v", err)
+	}
+
+	// On the equator or the prime meridian is still a location
+	equator := models.IPLocation{Latitude: 0, Longitude: 30}
+	if _, err := Distance(equator, london); err != nil {
+		t.Errorf("unexpected error for a location on the equator: %v", err)
+	}
+}
+
+// TestIsSuspiciousTravel tests the 1000 km/h threshold
+func TestIsSuspiciousTravel(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, curr models.IPLocation
+		elapsed    time.Duration
+		expected   bool
+	}{
+		{"London to New York in 1 hour", london, newYork, time.Hour, true},                    // 5570 km/h
+		{"London to New York in 5 hours", london, newYork, 5 * time.Hour, true},               // 1114 km/h
+		{"London to New York in 6 hours", london, newYork, 6 * time.Hour, false},              // 928 km/h
+		{"London to Paris in 1 hour", london, paris, time.Hour, false},                        // 344 km/h
+		{"London to Paris in 10 minutes", london, paris, 10 * time.Minute, true},              // 2064 km/h
+		{"same place at once", london, london, 0, false},                                      // Not moving at all
+		{"different places at once", london, paris, 0, true},                                  // Infinitely fast
+		{"clock skew", london, paris, -time.Minute, true},                                     // Treated like no time elapsed
+		{"no coordinates", noCoordinate, sydney, time.Second, false},                          // Unknown, so not suspicious
+		{"Sydney to London in a day", sydney, london, 24 * time.Hour, false},                  // 708 km/h
+		{"Sydney to London in half a day", sydney, london, 12 * time.Hour, true},              // 1416 km/h
+		{"London to New York in 5h36m", london, newYork, 5*time.Hour + 36*time.Minute, false}, // 995 km/h, just under
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSuspiciousTravel(tt.prev, tt.curr, tt.elapsed); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestIsSuspiciousTravelAt tests that the threshold is configurable
+func TestIsSuspiciousTravelAt(t *testing.T) {
+	// London to Paris in 1 hour is 344 km/h
+	if !IsSuspiciousTravelAt(london, paris, time.Hour, 300) {
+		t.Error("expected 344 km/h to be suspicious at a 300 km/h threshold")
+	}
+	if IsSuspiciousTravelAt(london, paris, time.Hour, 400) {
+		t.Error("expected 344 km/h not to be suspicious at a 400 km/h threshold")
+	}
+}
@@ -0,0 +1,65 @@
+// Package geofence checks whether IP locations are inside geographic boundaries: circles around
+// a point, and polygons
+package geofence
+
+import (
+	"errors"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/geo"
+)
+
+// ErrTooFewVertices is returned by PolygonFence.Contains when the polygon has fewer than 3 vertices
+var ErrTooFewVertices = errors.New("a polygon needs at least 3 vertices")
+
+// Fence is a geographic boundary
+// Contains returns geo.ErrNoCoordinates for a location without coordinates (latitude and longitude both 0),
+// as nothing is known about where it is
+type Fence interface {
+	Contains(loc models.IPLocation) (bool, error)
+}
+
+// CircleFence is every point within RadiusKm of Center, along the Earth's surface
+type CircleFence struct {
+	Center   models.Coordinates
+	RadiusKm float64
+}
+
+// Contains reports whether loc is within the radius (inclusive) of the center, using the Haversine distance
+func (f CircleFence) Contains(loc models.IPLocation) (bool, error) {
+	if !geo.HasCoordinates(loc) {
+		return false, geo.ErrNoCoordinates
+	}
+	km := geo.DistanceBetween(f.Center, models.Coordinates{Latitude: loc.Latitude, Longitude: loc.Longitude})
+	return km <= f.RadiusKm, nil
+}
+
+// PolygonFence is the area enclosed by Vertices, in order; the last vertex connects back to the first
+// Edges are straight lines in latitude/longitude, which is close enough for regions the size of a country.
+// Polygons crossing the antimeridian (longitude ±180) aren't supported
+type PolygonFence struct {
+	Vertices []models.Coordinates
+}
+
+// Contains reports whether loc is inside the polygon, using the ray-casting algorithm: a ray going east
+// from a point inside crosses the edges an odd number of times. Points exactly on an edge may go either way
+func (f PolygonFence) Contains(loc models.IPLocation) (bool, error) {
+	if len(f.Vertices) < 3 {
+		return false, ErrTooFewVertices
+	}
+	if !geo.HasCoordinates(loc) {
+		return false, geo.ErrNoCoordinates
+	}
+
+	x, y := loc.Longitude, loc.Latitude
+	inside := false
+	for i, j := 0, len(f.Vertices)-1; i < len(f.Vertices); j, i = i, i+1 {
+		a, b := f.Vertices[i], f.Vertices[j]
+		// Does the edge a-b straddle the ray's latitude, and cross it east of the point?
+		if (a.Latitude > y) != (b.Latitude > y) &&
+			x < (b.Longitude-a.Longitude)*(y-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside, nil
+}
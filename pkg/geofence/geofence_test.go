@@ -0,0 +1,114 @@
+package geofence
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/pkg/geo"
+)
+
+var (
+	london       = models.IPLocation{City: "London", Latitude: 51.5074, Longitude: -0.1278}
+	paris        = models.IPLocation{City: "Paris", Latitude: 48.8566, Longitude: 2.3522}
+	newYork      = models.IPLocation{City: "New York", Latitude: 40.7128, Longitude: -74.0060}
+	noCoordinate = models.IPLocation{City: "Mountain View"}
+)
+
+// TestCircleFence tests points inside, on the boundary of and outside a circle around London
+func TestCircleFence(t *testing.T) {
+	center := models.Coordinates{Latitude: london.Latitude, Longitude: london.Longitude}
+	parisKm, err := geo.Distance(london, paris)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		radiusKm float64
+		loc      models.IPLocation
+		expected bool
+	}{
+		{"center", 1, london, true},
+		{"inside", 500, paris, true},
+		{"on the boundary", parisKm, paris, true},
+		{"just outside", parisKm - 0.1, paris, false},
+		{"outside", 500, newYork, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CircleFence{Center: center, RadiusKm: tt.radiusKm}.Contains(tt.loc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestPolygonFence tests containment in an L-shaped polygon, including the notch of the L
+func TestPolygonFence(t *testing.T) {
+	// (10,10)-(10,30)-(20,30)-(20,20)-(40,20)-(40,10): the vertical bar is latitude 10-40,
+	// longitude 10-20, and the foot extends to longitude 30 for latitude 10-20
+	l := PolygonFence{Vertices: []models.Coordinates{
+		{Latitude: 10, Longitude: 10},
+		{Latitude: 10, Longitude: 30},
+		{Latitude: 20, Longitude: 30},
+		{Latitude: 20, Longitude: 20},
+		{Latitude: 40, Longitude: 20},
+		{Latitude: 40, Longitude: 10},
+	}}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected bool
+	}{
+		{"in the bar", 30, 15, true},
+		{"in the foot", 15, 25, true},
+		{"in the corner", 15, 15, true},
+		{"in the notch", 30, 25, false},
+		{"west of the L", 25, 5, false},
+		{"north of the L", 45, 15, false},
+		{"east of the foot", 15, 35, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Contains(models.IPLocation{Latitude: tt.lat, Longitude: tt.lon})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestFence_NoCoordinates tests that a location without coordinates is an error, not outside
+func TestFence_NoCoordinates(t *testing.T) {
+	fences := map[string]Fence{
+		"circle": CircleFence{Center: models.Coordinates{Latitude: 1, Longitude: 1}, RadiusKm: 20000},
+		"polygon": PolygonFence{Vertices: []models.Coordinates{
+			{Latitude: -10, Longitude: -10}, {Latitude: -10, Longitude: 10}, {Latitude: 10, Longitude: 0},
+		}},
+	}
+
+	for name, fence := range fences {
+		if _, err := fence.Contains(noCoordinate); !errors.Is(err, geo.ErrNoCoordinates) {
+			t.Errorf("%s: expected ErrNoCoordinates, got %v", name, err)
+		}
+	}
+}
+
+// TestPolygonFence_TooFewVertices tests that a polygon needs at least 3 vertices
+func TestPolygonFence_TooFewVertices(t *testing.T) {
+	line := PolygonFence{Vertices: []models.Coordinates{{Latitude: 1, Longitude: 1}, {Latitude: 2, Longitude: 2}}}
+	if _, err := line.Contains(london); !errors.Is(err, ErrTooFewVertices) {
+		t.Errorf("expected ErrTooFewVertices, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package ipenrich
+
+// countryInfo is what ContinentEnricher and TimezoneEnricher know about a country
+type countryInfo struct {
+	continent string
+	timezone  string // IANA name of the capital's timezone
+}
+
+// countries maps the country names used by the datastores to their continent and timezone
+// Countries missing from the table are left without either
+var countries = map[string]countryInfo{
+	"Algeria":              {"Africa", "Africa/Algiers"},
+	"Argentina":            {"South America", "America/Argentina/Buenos_Aires"},
+	"Australia":            {"Oceania", "Australia/Sydney"},
+	"Austria":              {"Europe", "Europe/Vienna"},
+	"Bangladesh":           {"Asia", "Asia/Dhaka"},
+	"Belgium":              {"Europe", "Europe/Brussels"},
+	"Brazil":               {"South America", "America/Sao_Paulo"},
+	"Bulgaria":             {"Europe", "Europe/Sofia"},
+	"Canada":               {"North America", "America/Toronto"},
+	"Chile":                {"South America", "America/Santiago"},
+	"China":                {"Asia", "Asia/Shanghai"},
+	"Colombia":             {"South America", "America/Bogota"},
+	"Czech Republic":       {"Europe", "Europe/Prague"},
+	"Czechia":              {"Europe", "Europe/Prague"},
+	"Denmark":              {"Europe", "Europe/Copenhagen"},
+	"Egypt":                {"Africa", "Africa/Cairo"},
+	"Finland":              {"Europe", "Europe/Helsinki"},
+	"France":               {"Europe", "Europe/Paris"},
+	"Germany":              {"Europe", "Europe/Berlin"},
+	"Greece":               {"Europe", "Europe/Athens"},
+	"Hong Kong":            {"Asia", "Asia/Hong_Kong"},
+	"Hungary":              {"Europe", "Europe/Budapest"},
+	"India":                {"Asia", "Asia/Kolkata"},
+	"Indonesia":            {"Asia", "Asia/Jakarta"},
+	"Iran":                 {"Asia", "Asia/Tehran"},
+	"Ireland":              {"Europe", "Europe/Dublin"},
+	"Israel":               {"Asia", "Asia/Jerusalem"},
+	"Italy":                {"Europe", "Europe/Rome"},
+	"Japan":                {"Asia", "Asia/Tokyo"},
+	"Kenya":                {"Africa", "Africa/Nairobi"},
+	"Malaysia":             {"Asia", "Asia/Kuala_Lumpur"},
+	"Mexico":               {"North America", "America/Mexico_City"},
+	"Morocco":              {"Africa", "Africa/Casablanca"},
+	"Netherlands":          {"Europe", "Europe/Amsterdam"},
+	"New Zealand":          {"Oceania", "Pacific/Auckland"},
+	"Nigeria":              {"Africa", "Africa/Lagos"},
+	"Norway":               {"Europe", "Europe/Oslo"},
+	"Pakistan":             {"Asia", "Asia/Karachi"},
+	"Peru":                 {"South America", "America/Lima"},
+	"Philippines":          {"Asia", "Asia/Manila"},
+	"Poland":               {"Europe", "Europe/Warsaw"},
+	"Portugal":             {"Europe", "Europe/Lisbon"},
+	"Romania":              {"Europe", "Europe/Bucharest"},
+	"Russia":               {"Europe", "Europe/Moscow"},
+	"Saudi Arabia":         {"Asia", "Asia/Riyadh"},
+	"Singapore":            {"Asia", "Asia/Singapore"},
+	"South Africa":         {"Africa", "Africa/Johannesburg"},
+	"South Korea":          {"Asia", "Asia/Seoul"},
+	"Spain":                {"Europe", "Europe/Madrid"},
+	"Sweden":               {"Europe", "Europe/Stockholm"},
+	"Switzerland":          {"Europe", "Europe/Zurich"},
+	"Taiwan":               {"Asia", "Asia/Taipei"},
+	"Thailand":             {"Asia", "Asia/Bangkok"},
+	"Turkey":               {"Asia", "Europe/Istanbul"},
+	"Ukraine":              {"Europe", "Europe/Kyiv"},
+	"United Arab Emirates": {"Asia", "Asia/Dubai"},
+	"United Kingdom":       {"Europe", "Europe/London"},
+	"United States":        {"North America", "America/New_York"},
+	"Vietnam":              {"Asia", "Asia/Ho_Chi_Minh"},
+}
@@ -0,0 +1,157 @@
+// Package ipenrich adds data from sources other than the datastore to a lookup's location:
+// continent, timezone, abuse score and coordinates. Each source is an Enricher, and a
+// MultiEnricher queries several in parallel, so adding a source doesn't slow lookups down
+package ipenrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// Enricher is a data source adding fields to a location
+type Enricher interface {
+	// Enrich fills in the fields of loc it has data for, leaving the others alone
+	Enrich(ctx context.Context, ip string, loc *models.IPLocation) error
+}
+
+// EnricherFunc adapts a function to the Enricher interface
+type EnricherFunc func(ctx context.Context, ip string, loc *models.IPLocation) error
+
+// Enrich calls f
+func (f EnricherFunc) Enrich(ctx context.Context, ip string, loc *models.IPLocation) error {
+	return f(ctx, ip, loc)
+}
+
+// source is an Enricher added to a MultiEnricher
+type source struct {
+	name     string
+	enricher Enricher
+	timeout  time.Duration // 0 = only the MultiEnricher's timeout
+}
+
+// MultiEnricher runs several Enrichers in parallel and merges what they find into one location
+// Partial success is the norm: a source that fails or times out leaves its fields empty, and the
+// fields of the others are still merged
+type MultiEnricher struct {
+	timeout time.Duration // Bounds a whole Enrich call (0 = no limit)
+	sources []source
+}
+
+// NewMultiEnricher creates a MultiEnricher whose Enrich calls take at most timeout (0 = no limit)
+// Add the sources with Add
+func NewMultiEnricher(timeout time.Duration) *MultiEnricher {
+	return &MultiEnricher{timeout: timeout}
+}
+
+// Add registers enricher under name, which prefixes its errors
+// timeout bounds this source alone (0 = only the MultiEnricher's timeout). Not safe to call during Enrich
+func (m *MultiEnricher) Add(name string, enricher Enricher, timeout time.Duration) *MultiEnricher {
+	m.sources = append(m.sources, source{name: name, enricher: enricher, timeout: timeout})
+	return m
+}
+
+// Enrich runs every source in parallel, each on its own copy of loc, and merges the fields each
+// one set into loc as it finishes
+// Returns the errors of every source that failed or didn't finish in time, joined; loc keeps the
+// fields of the sources that succeeded. Sources still running at the timeout are left to finish
+// in the background, and their results discarded
+func (m *MultiEnricher) Enrich(ctx context.Context, ip string, loc *models.IPLocation) error {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	// mu protects loc, errs, pending and finished
+	// finished stops late sources from writing after Enrich has returned on timeout
+	var mu sync.Mutex
+	var errs []error
+	pending := make([]bool, len(m.sources))
+	for i := range pending {
+		pending[i] = true
+	}
+	finished := false
+	before := *loc
+
+	// Sources never return an error to the group, so one failure doesn't cancel the others
+	g, gctx := errgroup.WithContext(ctx)
+	for i, src := range m.sources {
+		g.Go(func() error {
+			sctx := gctx
+			if src.timeout > 0 {
+				var cancel context.CancelFunc
+				sctx, cancel = context.WithTimeout(gctx, src.timeout)
+				defer cancel()
+			}
+			enriched := before
+			err := src.enricher.Enrich(sctx, ip, &enriched)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if finished {
+				return nil
+			}
+			pending[i] = false
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", src.name, err))
+				return nil
+			}
+			merge(loc, &before, &enriched)
+			return nil
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+		finished = true
+		for i, src := range m.sources {
+			if pending[i] {
+				errs = append(errs, fmt.Errorf("%s: %w", src.name, ctx.Err()))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// merge copies into dst the fields of enriched that differ from before, i.e. those a source set
+func merge(dst, before, enriched *models.IPLocation) {
+	if enriched.City != before.City {
+		dst.City = enriched.City
+	}
+	if enriched.Country != before.Country {
+		dst.Country = enriched.Country
+	}
+	if enriched.ISP != before.ISP {
+		dst.ISP = enriched.ISP
+	}
+	if enriched.ASN != before.ASN {
+		dst.ASN = enriched.ASN
+	}
+	if enriched.Latitude != before.Latitude || enriched.Longitude != before.Longitude {
+		dst.Latitude, dst.Longitude = enriched.Latitude, enriched.Longitude
+	}
+	if enriched.Continent != before.Continent {
+		dst.Continent = enriched.Continent
+	}
+	if enriched.Timezone != before.Timezone {
+		dst.Timezone = enriched.Timezone
+	}
+	if enriched.AbuseScore != before.AbuseScore {
+		dst.AbuseScore = enriched.AbuseScore
+	}
+}
@@ -0,0 +1,176 @@
+package ipenrich
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+)
+
+// listedIPs is an AbuseList of exact addresses
+type listedIPs []string
+
+func (l listedIPs) Contains(ip net.IP) bool {
+	for _, listed := range l {
+		if ip.Equal(net.ParseIP(listed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fixedLocator finds every address at the same location, or fails with err
+type fixedLocator struct {
+	location models.IPLocation
+	err      error
+}
+
+func (l fixedLocator) FindByIP(ctx context.Context, ip string) (*models.IPLocation, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	location := l.location
+	return &location, nil
+}
+
+// blockingEnricher waits for its context to be done, then returns its error
+var blockingEnricher = EnricherFunc(func(ctx context.Context, ip string, loc *models.IPLocation) error {
+	<-ctx.Done()
+	return ctx.Err()
+})
+
+// failingEnricher fails without touching the location
+var failingEnricher = EnricherFunc(func(ctx context.Context, ip string, loc *models.IPLocation) error {
+	loc.City = "Changed before failing"
+	return errors.New("source unavailable")
+})
+
+// newLocation returns the location a datastore would find for 8.8.8.8
+func newLocation() *models.IPLocation {
+	return &models.IPLocation{IP: "8.8.8.8", City: "Mountain View", Country: "United States"}
+}
+
+// TestMultiEnricher_AllSucceed tests that the fields of every source are merged into the location
+func TestMultiEnricher_AllSucceed(t *testing.T) {
+	enricher := NewMultiEnricher(time.Second).
+		Add("continent", ContinentEnricher{}, 0).
+		Add("timezone", TimezoneEnricher{}, 0).
+		Add("abuse", NewAbuseEnricher(listedIPs{"8.8.8.8"}), 0).
+		Add("coordinates", NewCoordinateEnricher(fixedLocator{location: models.IPLocation{Latitude: 37.386, Longitude: -122.0838}}), 0)
+
+	loc := newLocation()
+	if err := enricher.Enrich(context.Background(), "8.8.8.8", loc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := models.IPLocation{
+		IP: "8.8.8.8", City: "Mountain View", Country: "United States",
+		Continent: "North America", Timezone: "America/New_York", AbuseScore: ListedAbuseScore,
+		Latitude: 37.386, Longitude: -122.0838,
+	}
+	if *loc != expected {
+		t.Errorf("expected %+v, got %+v", expected, *loc)
+	}
+}
+
+// TestMultiEnricher_SourceTimeout tests that a source running out of its own time is an error, and the other fields are still merged
+func TestMultiEnricher_SourceTimeout(t *testing.T) {
+	enricher := NewMultiEnricher(time.Second).
+		Add("continent", ContinentEnricher{}, 0).
+		Add("slow", blockingEnricher, 10*time.Millisecond).
+		Add("timezone", TimezoneEnricher{}, 0)
+
+	loc := newLocation()
+	err := enricher.Enrich(context.Background(), "8.8.8.8", loc)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "slow") {
+		t.Errorf("expected the slow source to time out, got %v", err)
+	}
+	if loc.Continent != "North America" || loc.Timezone != "America/New_York" {
+		t.Errorf("expected the other sources' fields, got %+v", *loc)
+	}
+}
+
+// TestMultiEnricher_SharedTimeout tests that Enrich returns at the shared timeout, even with a source ignoring its context
+func TestMultiEnricher_SharedTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	stuck := EnricherFunc(func(ctx context.Context, ip string, loc *models.IPLocation) error {
+		<-release
+		loc.Timezone = "Too/Late"
+		return nil
+	})
+	enricher := NewMultiEnricher(20*time.Millisecond).
+		Add("continent", ContinentEnricher{}, 0).
+		Add("stuck", stuck, 0)
+
+	loc := newLocation()
+	start := time.Now()
+	err := enricher.Enrich(context.Background(), "8.8.8.8", loc)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Enrich to return at the timeout, took %v", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stuck") || strings.Contains(err.Error(), "continent") {
+		t.Errorf("expected only the stuck source to time out, got %v", err)
+	}
+	if loc.Continent != "North America" {
+		t.Errorf("expected the continent, got %+v", *loc)
+	}
+}
+
+// TestMultiEnricher_AllFail tests that every failure is returned, and the location keeps the datastore's fields
+func TestMultiEnricher_AllFail(t *testing.T) {
+	enricher := NewMultiEnricher(time.Second).
+		Add("abuse", failingEnricher, 0).
+		Add("coordinates", NewCoordinateEnricher(fixedLocator{err: errors.New("IP address not found")}), 0)
+
+	loc := newLocation()
+	err := enricher.Enrich(context.Background(), "8.8.8.8", loc)
+	for _, name := range []string{"abuse: source unavailable", "coordinates: IP address not found"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %q in the error, got %v", name, err)
+		}
+	}
+	if *loc != *newLocation() {
+		t.Errorf("expected the location unchanged, got %+v", *loc)
+	}
+}
+
+// TestCountryEnrichers_UnknownCountry tests that a country missing from the table leaves the continent and timezone empty
+func TestCountryEnrichers_UnknownCountry(t *testing.T) {
+	loc := &models.IPLocation{City: "Atlantis", Country: "Atlantis"}
+	ContinentEnricher{}.Enrich(context.Background(), "8.8.8.8", loc)
+	TimezoneEnricher{}.Enrich(context.Background(), "8.8.8.8", loc)
+
+	if loc.Continent != "" || loc.Timezone != "" {
+		t.Errorf("expected no continent or timezone, got %+v", *loc)
+	}
+}
+
+// TestAbuseEnricher tests that only listed addresses get a score, and an invalid address is an error
+func TestAbuseEnricher(t *testing.T) {
+	enricher := NewAbuseEnricher(listedIPs{"203.0.113.7"})
+
+	for ip, expected := range map[string]int{"203.0.113.7": ListedAbuseScore, "8.8.8.8": 0} {
+		loc := &models.IPLocation{}
+		if err := enricher.Enrich(context.Background(), ip, loc); err != nil || loc.AbuseScore != expected {
+			t.Errorf("%s: expected score %d, got %d, %v", ip, expected, loc.AbuseScore, err)
+		}
+	}
+	if err := enricher.Enrich(context.Background(), "not-an-ip", &models.IPLocation{}); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+// TestCoordinateEnricher_KeepsCoordinates tests that a location with coordinates isn't looked up again
+func TestCoordinateEnricher_KeepsCoordinates(t *testing.T) {
+	enricher := NewCoordinateEnricher(fixedLocator{err: errors.New("should not be called")})
+	loc := &models.IPLocation{Latitude: 51.5074, Longitude: -0.1278}
+
+	if err := enricher.Enrich(context.Background(), "8.8.8.8", loc); err != nil || loc.Latitude != 51.5074 {
+		t.Errorf("expected the coordinates kept, got %+v, %v", *loc, err)
+	}
+}
@@ -0,0 +1,92 @@
+package ipenrich
+
+import (
+	"context"
+	"net"
+
+	"github.com/evyataryagoni/ip2country/internal/models"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// ContinentEnricher sets the continent of locations whose country it knows
+type ContinentEnricher struct{}
+
+// Enrich sets loc.Continent from loc.Country
+func (ContinentEnricher) Enrich(ctx context.Context, ip string, loc *models.IPLocation) error {
+	if info, ok := countries[loc.Country]; ok {
+		loc.Continent = info.continent
+	}
+	return nil
+}
+
+// TimezoneEnricher sets the timezone of locations whose country it knows
+// The timezone is the capital's, so it's approximate for countries spanning several (United States, Russia, ...)
+type TimezoneEnricher struct{}
+
+// Enrich sets loc.Timezone from loc.Country, unless the location already has one
+func (TimezoneEnricher) Enrich(ctx context.Context, ip string, loc *models.IPLocation) error {
+	if info, ok := countries[loc.Country]; ok && loc.Timezone == "" {
+		loc.Timezone = info.timezone
+	}
+	return nil
+}
+
+// ListedAbuseScore is the abuse score AbuseEnricher gives a listed address
+const ListedAbuseScore = 100
+
+// AbuseList reports whether an address is known for abuse, e.g. *middleware.Blocklist
+type AbuseList interface {
+	Contains(ip net.IP) bool
+}
+
+// AbuseEnricher sets the abuse score of addresses in a list of abusive networks
+type AbuseEnricher struct {
+	list AbuseList
+}
+
+// NewAbuseEnricher creates an enricher scoring the addresses in list ListedAbuseScore
+func NewAbuseEnricher(list AbuseList) *AbuseEnricher {
+	return &AbuseEnricher{list: list}
+}
+
+// Enrich sets loc.AbuseScore to ListedAbuseScore if ip is listed
+func (e *AbuseEnricher) Enrich(ctx context.Context, ip string, loc *models.IPLocation) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return pkerr.ErrInvalidIP
+	}
+	if e.list.Contains(parsed) {
+		loc.AbuseScore = ListedAbuseScore
+	}
+	return nil
+}
+
+// Locator looks up the location of an address, e.g. a store.Store
+type Locator interface {
+	FindByIP(ctx context.Context, ip string) (*models.IPLocation, error)
+}
+
+// CoordinateEnricher sets the coordinates of locations that have none from a second source,
+// e.g. a MaxMind store behind a CSV datastore
+type CoordinateEnricher struct {
+	locator Locator
+}
+
+// NewCoordinateEnricher creates an enricher taking coordinates from locator
+func NewCoordinateEnricher(locator Locator) *CoordinateEnricher {
+	return &CoordinateEnricher{locator: locator}
+}
+
+// Enrich sets loc.Latitude and loc.Longitude from the locator, unless loc already has coordinates
+// Errors from the locator, including not finding ip, are returned
+func (e *CoordinateEnricher) Enrich(ctx context.Context, ip string, loc *models.IPLocation) error {
+	if loc.Latitude != 0 || loc.Longitude != 0 {
+		return nil
+	}
+	found, err := e.locator.FindByIP(ctx, ip)
+	if err != nil {
+		return err
+	}
+	loc.Latitude, loc.Longitude = found.Latitude, found.Longitude
+	return nil
+}
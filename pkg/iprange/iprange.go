@@ -0,0 +1,156 @@
+// Package iprange provides IP address arithmetic shared by the store backends:
+// integer conversion, range containment, CIDR bounds and range enumeration
+package iprange
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+)
+
+// ToUint32 converts an IPv4 address (or IPv4-mapped IPv6 address like ::ffff:8.8.8.8) to its integer form
+// Returns an error for IPv6 addresses, which don't fit in 32 bits
+func ToUint32(ip net.IP) (uint32, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0, fmt.Errorf("not an IPv4 address: %v", ip)
+	}
+	return binary.BigEndian.Uint32(ip4), nil
+}
+
+// FromUint32 converts the integer form of an IPv4 address back to the address
+func FromUint32(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// ToUint128 converts an IP address to its 128-bit integer form as [high, low] 64-bit halves
+// IPv4 addresses are converted as their IPv4-mapped form (::ffff:a.b.c.d)
+func ToUint128(ip net.IP) ([2]uint64, error) {
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return [2]uint64{}, fmt.Errorf("invalid IP address: %v", ip)
+	}
+	return [2]uint64{binary.BigEndian.Uint64(ip16[:8]), binary.BigEndian.Uint64(ip16[8:])}, nil
+}
+
+// Contains reports whether target is in the inclusive range start-end
+// Addresses are compared in their 16-byte form, so an IPv4 range never contains an IPv6 address.
+// Returns false if any address is invalid
+func Contains(start, end net.IP, target net.IP) bool {
+	s, e, t := start.To16(), end.To16(), target.To16()
+	if s == nil || e == nil || t == nil {
+		return false
+	}
+	return bytes.Compare(s, t) <= 0 && bytes.Compare(t, e) <= 0
+}
+
+// ParseCIDR returns the first and last address of a network, e.g. 10.0.0.0/8 = 10.0.0.0 - 10.255.255.255
+// IPv4 networks return 4-byte addresses, IPv6 networks 16-byte addresses
+func ParseCIDR(cidr string) (start, end net.IP, err error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CIDR %q", cidr)
+	}
+
+	start = ipNet.IP
+	end = make(net.IP, len(start))
+	for i := range start {
+		end[i] = start[i] | ^ipNet.Mask[i]
+	}
+	return start, end, nil
+}
+
+// ToCIDRs returns the fewest CIDR blocks covering exactly the IPv4 range start-end, in order
+// A range that is a single block returns one (10.0.0.0 - 10.0.0.255 = [10.0.0.0/24]); one that isn't
+// aligned on a block boundary returns several. Returns an error for IPv6 addresses or start after end
+func ToCIDRs(start, end net.IP) ([]string, error) {
+	first, err := ToUint32(start)
+	if err != nil {
+		return nil, err
+	}
+	last, err := ToUint32(end)
+	if err != nil {
+		return nil, err
+	}
+	if first > last {
+		return nil, fmt.Errorf("range start %v is after its end %v", start, end)
+	}
+
+	// Computed in 64 bits, so the block ending at 255.255.255.255 doesn't overflow
+	var cidrs []string
+	for cur := uint64(first); cur <= uint64(last); {
+		// The largest block starting at cur (its alignment) that doesn't go past last
+		size := min(bits.TrailingZeros64(cur), 32)
+		for cur+(1<<size)-1 > uint64(last) {
+			size--
+		}
+		cidrs = append(cidrs, fmt.Sprintf("%v/%d", FromUint32(uint32(cur)), 32-size))
+		cur += 1 << size
+	}
+	return cidrs, nil
+}
+
+// Enumerate streams every address from start to end (inclusive) on the returned channel
+// Addresses are sent one at a time as the reader receives them, so even a /8 is never held in memory.
+// The channel is closed after end, or as soon as ctx is cancelled
+func Enumerate(ctx context.Context, start, end net.IP) (<-chan net.IP, error) {
+	first, last, err := normalize(start, end)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Compare(first, last) > 0 {
+		return nil, fmt.Errorf("range start %v is after its end %v", start, end)
+	}
+
+	ips := make(chan net.IP)
+	go func() {
+		defer close(ips)
+		for ip := first; ; ip = next(ip) {
+			select {
+			case ips <- ip:
+			case <-ctx.Done():
+				return
+			}
+			if ip.Equal(last) {
+				return
+			}
+		}
+	}()
+	return ips, nil
+}
+
+// normalize returns start and end in the same form: 4 bytes if both are IPv4, 16 bytes if both are IPv6
+func normalize(start, end net.IP) (net.IP, net.IP, error) {
+	if start.To16() == nil || end.To16() == nil {
+		return nil, nil, errors.New("invalid IP address in range")
+	}
+
+	start4, end4 := start.To4(), end.To4()
+	switch {
+	case start4 != nil && end4 != nil:
+		return start4, end4, nil
+	case start4 == nil && end4 == nil:
+		return start.To16(), end.To16(), nil
+	default:
+		return nil, nil, errors.New("range mixes IPv4 and IPv6 addresses")
+	}
+}
+
+// next returns a copy of ip incremented by one (the maximum address wraps around to zero)
+func next(ip net.IP) net.IP {
+	n := make(net.IP, len(ip))
+	copy(n, ip)
+	for i := len(n) - 1; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,295 @@
+package iprange
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestToUint32 tests the conversion at both ends of the address space and for IPv4-mapped addresses
+func TestToUint32(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected uint32
+	}{
+		{"0.0.0.0", 0},
+		{"255.255.255.255", 1<<32 - 1},
+		{"1.0.0.0", 1 << 24},
+		{"8.8.8.8", 0x08080808},
+		{"::ffff:8.8.8.8", 0x08080808},
+		{"::ffff:255.255.255.255", 1<<32 - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := ToUint32(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+			if back := FromUint32(got); !back.Equal(net.ParseIP(tt.ip)) {
+				t.Errorf("expected FromUint32 to round trip to %s, got %s", tt.ip, back)
+			}
+		})
+	}
+
+	for _, ip := range []net.IP{net.ParseIP("2001:4860:4860::8888"), net.ParseIP("::1"), nil} {
+		if _, err := ToUint32(ip); err == nil {
+			t.Errorf("expected error for %v, got nil", ip)
+		}
+	}
+}
+
+// TestToUint128 tests the conversion of IPv6 addresses and of IPv4 addresses as their mapped form
+func TestToUint128(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected [2]uint64
+	}{
+		{"::", [2]uint64{0, 0}},
+		{"::1", [2]uint64{0, 1}},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", [2]uint64{1<<64 - 1, 1<<64 - 1}},
+		{"2001:4860:4860::8888", [2]uint64{0x2001486048600000, 0x8888}},
+		{"8.8.8.8", [2]uint64{0, 0xffff08080808}},
+		{"::ffff:8.8.8.8", [2]uint64{0, 0xffff08080808}},
+	}
+
+	for _, tt := range tests {
+		got, err := ToUint128(net.ParseIP(tt.ip))
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.ip, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %#x, got %#x", tt.ip, tt.expected, got)
+		}
+	}
+
+	if _, err := ToUint128(nil); err == nil {
+		t.Error("expected error for a nil IP, got nil")
+	}
+}
+
+// TestContains tests range boundaries, single IP ranges and mixed address families
+func TestContains(t *testing.T) {
+	tests := []struct {
+		name              string
+		start, end, check string
+		expected          bool
+	}{
+		{"first address", "10.0.0.0", "10.0.0.255", "10.0.0.0", true},
+		{"last address", "10.0.0.0", "10.0.0.255", "10.0.0.255", true},
+		{"before the start", "10.0.0.0", "10.0.0.255", "9.255.255.255", false},
+		{"after the end", "10.0.0.0", "10.0.0.255", "10.0.1.0", false},
+		{"single IP range", "8.8.8.8", "8.8.8.8", "8.8.8.8", true},
+		{"next to a single IP range", "8.8.8.8", "8.8.8.8", "8.8.8.9", false},
+		{"whole IPv4 space, lowest", "0.0.0.0", "255.255.255.255", "0.0.0.0", true},
+		{"whole IPv4 space, highest", "0.0.0.0", "255.255.255.255", "255.255.255.255", true},
+		{"IPv4-mapped target", "10.0.0.0", "10.0.0.255", "::ffff:10.0.0.1", true},
+		{"IPv4-mapped range", "::ffff:10.0.0.0", "::ffff:10.0.0.255", "10.0.0.1", true},
+		{"IPv6", "2001:db8::", "2001:db8::ffff", "2001:db8::1", true},
+		{"IPv6 outside", "2001:db8::", "2001:db8::ffff", "2001:db8::1:0", false},
+		{"IPv6 target in IPv4 range", "0.0.0.0", "255.255.255.255", "2001:db8::1", false},
+		{"backwards range", "10.0.0.255", "10.0.0.0", "10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Contains(net.ParseIP(tt.start), net.ParseIP(tt.end), net.ParseIP(tt.check)); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if Contains(net.ParseIP("10.0.0.0"), net.ParseIP("10.0.0.255"), nil) {
+		t.Error("expected false for a nil target")
+	}
+}
+
+// TestContains_AdjacentRanges tests that adjacent ranges sharing no address each claim only their own boundary
+func TestContains_AdjacentRanges(t *testing.T) {
+	lowStart, lowEnd := net.ParseIP("10.0.0.0"), net.ParseIP("10.0.0.127")
+	highStart, highEnd := net.ParseIP("10.0.0.128"), net.ParseIP("10.0.0.255")
+
+	if !Contains(lowStart, lowEnd, net.ParseIP("10.0.0.127")) || Contains(highStart, highEnd, net.ParseIP("10.0.0.127")) {
+		t.Error("expected 10.0.0.127 in the low range only")
+	}
+	if Contains(lowStart, lowEnd, net.ParseIP("10.0.0.128")) || !Contains(highStart, highEnd, net.ParseIP("10.0.0.128")) {
+		t.Error("expected 10.0.0.128 in the high range only")
+	}
+
+	// Overlapping ranges both contain the shared boundary
+	if !Contains(lowStart, highStart, net.ParseIP("10.0.0.128")) || !Contains(highStart, highEnd, net.ParseIP("10.0.0.128")) {
+		t.Error("expected 10.0.0.128 in both overlapping ranges")
+	}
+}
+
+// TestParseCIDR tests the first and last address of networks from /0 to /32 and /128
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		cidr       string
+		start, end string
+	}{
+		{"0.0.0.0/0", "0.0.0.0", "255.255.255.255"},
+		{"10.0.0.0/8", "10.0.0.0", "10.255.255.255"},
+		{"192.168.1.77/24", "192.168.1.0", "192.168.1.255"}, // Host bits are masked off
+		{"8.8.8.8/32", "8.8.8.8", "8.8.8.8"},
+		{"::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"2001:db8::/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			start, end, err := ParseCIDR(tt.cidr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(net.ParseIP(tt.start)) || !end.Equal(net.ParseIP(tt.end)) {
+				t.Errorf("expected %s - %s, got %s - %s", tt.start, tt.end, start, end)
+			}
+		})
+	}
+
+	for _, cidr := range []string{"10.0.0.0/33", "10.0.0.0", "not-a-cidr", ""} {
+		if _, _, err := ParseCIDR(cidr); err == nil {
+			t.Errorf("expected error for %q, got nil", cidr)
+		}
+	}
+}
+
+// TestToCIDRs tests that ranges are split into the fewest blocks, from a single address to the whole IPv4 space
+func TestToCIDRs(t *testing.T) {
+	tests := []struct {
+		start, end string
+		expected   []string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+		{"8.8.8.8", "8.8.8.8", []string{"8.8.8.8/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"10.0.0.0", "10.0.1.127", []string{"10.0.0.0/24", "10.0.1.0/25"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.start+"-"+tt.end, func(t *testing.T) {
+			cidrs, err := ToCIDRs(net.ParseIP(tt.start), net.ParseIP(tt.end))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(cidrs, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, cidrs)
+			}
+		})
+	}
+
+	if _, err := ToCIDRs(net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")); err == nil {
+		t.Error("expected an error for start after end")
+	}
+	if _, err := ToCIDRs(net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::ff")); err == nil {
+		t.Error("expected an error for IPv6")
+	}
+}
+
+// collect reads every address from ips
+func collect(ips <-chan net.IP) []string {
+	var got []string
+	for ip := range ips {
+		got = append(got, ip.String())
+	}
+	return got
+}
+
+// TestEnumerate tests that every address of a range is sent once, in order
+func TestEnumerate(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		expected   []string
+	}{
+		{"carry across octets", "10.0.0.254", "10.0.1.1", []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{"single IP", "8.8.8.8", "8.8.8.8", []string{"8.8.8.8"}},
+		{"end of the IPv4 space", "255.255.255.254", "255.255.255.255", []string{"255.255.255.254", "255.255.255.255"}},
+		{"IPv4-mapped bounds", "::ffff:10.0.0.1", "10.0.0.2", []string{"10.0.0.1", "10.0.0.2"}},
+		{"IPv6", "2001:db8::ffff", "2001:db8::1:1", []string{"2001:db8::ffff", "2001:db8::1:0", "2001:db8::1:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, err := Enumerate(context.Background(), net.ParseIP(tt.start), net.ParseIP(tt.end))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := collect(ips)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("expected %v, got %v", tt.expected, got)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestEnumerate_Errors tests invalid, backwards and mixed family ranges
+func TestEnumerate_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end net.IP
+	}{
+		{"backwards", net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")},
+		{"mixed families", net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+		{"nil start", nil, net.ParseIP("10.0.0.1")},
+	}
+
+	for _, tt := range tests {
+		if _, err := Enumerate(context.Background(), tt.start, tt.end); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
+
+// TestEnumerate_Streams tests that the whole internet can be enumerated lazily and stopped by the context
+// Addresses are produced only as they are received, so reading a few from /0 returns immediately
+func TestEnumerate_Streams(t *testing.T) {
+	start, end, err := ParseCIDR("0.0.0.0/0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ips, err := Enumerate(ctx, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cap(ips) != 0 {
+		t.Errorf("expected an unbuffered channel, got capacity %d", cap(ips))
+	}
+
+	for i, expected := range []string{"0.0.0.0", "0.0.0.1", "0.0.0.2"} {
+		if ip := <-ips; ip.String() != expected {
+			t.Fatalf("address %d: expected %s, got %s", i, expected, ip)
+		}
+	}
+
+	// Cancelling closes the channel instead of producing the other ~4 billion addresses
+	cancel()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ips:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for the channel to close after cancel")
+		}
+	}
+}
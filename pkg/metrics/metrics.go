@@ -0,0 +1,60 @@
+// Package metrics defines the Prometheus metrics of an HTTP service, as recorded by
+// middleware.MetricsMiddleware. It has no dependencies on the rest of ip2country, so other
+// services can import it on its own and export the same series as ip2country does
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HTTPMetrics holds the per-request metrics, labelled by method, endpoint (the URL path) and status
+type HTTPMetrics struct {
+	RequestsTotal   *prometheus.CounterVec   // http_requests_total{method,endpoint,status}
+	RequestDuration *prometheus.HistogramVec // http_request_duration_seconds{method,endpoint,status}
+	RequestSize     *prometheus.HistogramVec // http_request_size_bytes{method,endpoint}, bodies with a Content-Length only
+	ResponseSize    *prometheus.HistogramVec // http_response_size_bytes{method,endpoint,status}
+}
+
+// NewHTTPMetrics creates the HTTP metrics and registers them with reg
+// Tests pass a fresh prometheus.NewRegistry() so repeated setups don't collide
+func NewHTTPMetrics(reg prometheus.Registerer) *HTTPMetrics {
+	factory := promauto.With(reg)
+
+	return &HTTPMetrics{
+		RequestsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests",
+			},
+			[]string{"method", "endpoint", "status"},
+		),
+
+		RequestDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_seconds",
+				Help:    "HTTP request latency in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "endpoint", "status"},
+		),
+
+		RequestSize: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_size_bytes",
+				Help:    "HTTP request size in bytes",
+				Buckets: prometheus.ExponentialBuckets(100, 10, 7),
+			},
+			[]string{"method", "endpoint"},
+		),
+
+		ResponseSize: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_response_size_bytes",
+				Help:    "HTTP response size in bytes",
+				Buckets: prometheus.ExponentialBuckets(100, 10, 7),
+			},
+			[]string{"method", "endpoint", "status"},
+		),
+	}
+}
@@ -0,0 +1,65 @@
+// Package middleware provides ip2country's Chi-compatible HTTP middleware: request IDs, rate limiting,
+// structured logging, Prometheus metrics, CORS and timeouts. It depends only on the pkg/ratelimit and
+// pkg/metrics packages (and third-party modules), so other services can import it on its own
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestIDHeader carries the request ID back to the client (and accepts one from upstream proxies)
+const RequestIDHeader = "X-Request-ID"
+
+// RequestContext holds per-request values shared by every middleware and handler
+// Populated once by RequestContextMiddleware so nothing re-parses headers
+type RequestContext struct {
+	RequestID string    // Correlates logs, metrics exemplars and the X-Request-ID response header
+	ClientIP  string    // Client address after X-Real-IP / X-Forwarded-For are applied (no port)
+	StartTime time.Time // When the request entered the server
+	NodeID    string    // Server instance handling the request (set by NodeIdentityMiddleware, "" without it)
+}
+
+// requestContextKey is the context key for *RequestContext (unexported so no other package can collide with it)
+type requestContextKey struct{}
+
+// RequestContextMiddleware assigns the request ID, resolves the client IP and stores both in the context
+// Must run first in the chain - LoggingMiddleware, MetricsMiddleware and later middleware call GetRequestContext
+func RequestContextMiddleware(next http.Handler) http.Handler {
+	// chi's RequestID and RealIP do the actual work, so chi's own helpers (GetReqID) stay consistent
+	populate := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx := &RequestContext{
+			RequestID: middleware.GetReqID(r.Context()),
+			ClientIP:  clientIP(r),
+			StartTime: time.Now(),
+			NodeID:    nodeIDFromContext(r.Context()),
+		}
+
+		w.Header().Set(RequestIDHeader, reqCtx.RequestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestContextKey{}, reqCtx)))
+	})
+
+	return middleware.RequestID(middleware.RealIP(populate))
+}
+
+// GetRequestContext returns the values stored by RequestContextMiddleware
+// Panics if the middleware didn't run - that's a wiring bug, not a runtime condition
+func GetRequestContext(ctx context.Context) *RequestContext {
+	reqCtx, ok := ctx.Value(requestContextKey{}).(*RequestContext)
+	if !ok {
+		panic("middleware: GetRequestContext called without RequestContextMiddleware in the chain")
+	}
+	return reqCtx
+}
+
+// clientIP returns the client address of r without its port
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of CORSConfig
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions}
+	defaultCORSHeaders = []string{"Content-Type", "X-API-Key", RequestIDHeader}
+)
+
+// exposedCORSHeaders are the response headers scripts on an allowed origin may read
+var exposedCORSHeaders = strings.Join([]string{
+	RequestIDHeader, NodeIDHeader,
+	"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After",
+}, ", ")
+
+// CORSConfig configures CORSMiddleware
+type CORSConfig struct {
+	AllowedOrigins []string      // Origins allowed to call the API, e.g. "https://app.example.com"; "*" allows any origin
+	AllowedMethods []string      // Methods a preflight request may ask for (empty = GET, POST, DELETE, OPTIONS)
+	AllowedHeaders []string      // Request headers a preflight request may ask for (empty = Content-Type, X-API-Key, X-Request-ID)
+	MaxAge         time.Duration // How long browsers may cache a preflight response (0 = browser default)
+}
+
+// CORSMiddleware lets browsers on the allowed origins call the API
+// Preflight requests (OPTIONS with Access-Control-Request-Method) from an allowed origin are answered
+// with 204 No Content without reaching the handler. Requests from other origins are served without
+// CORS headers, so the browser blocks the response; requests without an Origin header are untouched.
+// X-Request-ID, X-Processing-Node and the rate limit headers are exposed to scripts
+func CORSMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
+	methods := strings.Join(orDefault(cfg.AllowedMethods, defaultCORSMethods), ", ")
+	headers := strings.Join(orDefault(cfg.AllowedHeaders, defaultCORSHeaders), ", ")
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Access-Control-Expose-Headers", exposedCORSHeaders)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// orDefault returns values, or fallback when values is empty
+func orDefault(values, fallback []string) []string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
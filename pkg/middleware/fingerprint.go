@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/evyataryagoni/ip2country/pkg/ratelimit"
+)
+
+// fingerprintKeyPrefix keeps fingerprint buckets apart from IP buckets in shared limiters (e.g. Redis)
+const fingerprintKeyPrefix = "fp:"
+
+// Fingerprint identifies a client by its request headers rather than its IP address
+// Returns the hex SHA-256 of User-Agent + Accept-Language + Accept-Encoding
+// Missing headers hash as empty strings, so the result is always stable
+func Fingerprint(r *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(r.Header.Get("User-Agent")))
+	h.Write([]byte{0}) // Separator so "ab"+"c" and "a"+"bc" differ
+	h.Write([]byte(r.Header.Get("Accept-Language")))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Header.Get("Accept-Encoding")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FingerprintMiddleware rate limits by header fingerprint (returns 429 when exceeded)
+// Catches scrapers that rotate IPs to get around per-IP limits
+// The limiter should be more permissive than the per-IP one, since many
+// legitimate clients share common browser fingerprints
+// A nil limiter disables the middleware. WithExemptPaths works like for RateLimitMiddleware
+// Only a 429 reports the limiter's state, in Retry-After: the X-RateLimit-* headers describe the per-IP limit
+func FingerprintMiddleware(lim ratelimit.Limiter, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	options := newRateLimitOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		if lim == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if options.exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := fingerprintKeyPrefix + Fingerprint(r)
+			if !lim.Allow(key) {
+				if inspectable, ok := lim.(ratelimit.InspectableLimiter); ok {
+					setRetryAfter(w.Header(), inspectable.ResetAt(key))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Cache-Control", "no-store")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"code":  "FINGERPRINT_RATE_LIMITED",
+					"error": "Rate limit exceeded. Please try again later.",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+// truncatedBodySuffix marks a logged request body cut off at the configured limit
+const truncatedBodySuffix = "[truncated]"
+
+// loggingOptions holds the optional LoggingMiddleware behaviour
+type loggingOptions struct {
+	bodyMaxBytes     int      // 0 = request bodies are not logged
+	bodyExcludePaths []string // Path prefixes whose bodies are never logged
+}
+
+// LoggingOption configures LoggingMiddleware
+type LoggingOption func(*loggingOptions)
+
+// WithBodyLogging logs up to maxBytes of each request body at debug level
+// Longer bodies are logged truncated, with a "[truncated]" suffix. The handler still receives the whole body
+func WithBodyLogging(maxBytes int) LoggingOption {
+	return func(o *loggingOptions) {
+		o.bodyMaxBytes = maxBytes
+	}
+}
+
+// WithBodyExcludePaths never logs the bodies of requests whose path starts with one of prefixes
+// Used for endpoints receiving secrets
+func WithBodyExcludePaths(prefixes ...string) LoggingOption {
+	return func(o *loggingOptions) {
+		o.bodyExcludePaths = append(o.bodyExcludePaths, prefixes...)
+	}
+}
+
+// logsBody reports whether the body of r should be logged
+func (o *loggingOptions) logsBody(r *http.Request) bool {
+	if o.bodyMaxBytes <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	for _, prefix := range o.bodyExcludePaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// bufferedBody replays the bytes read for logging, then the rest of the original body
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// peekBody reads up to maxBytes of r.Body for logging and restores it for the handler
+func peekBody(r *http.Request, maxBytes int) string {
+	buf, _ := io.ReadAll(io.LimitReader(r.Body, int64(maxBytes)+1))
+	r.Body = bufferedBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+
+	if len(buf) > maxBytes {
+		return string(buf[:maxBytes]) + truncatedBodySuffix
+	}
+	return string(buf)
+}
+
+// LoggingMiddleware logs HTTP requests with structured data
+// Request bodies are only logged with WithBodyLogging, and only when the logger is at debug level.
+// Requires RequestContextMiddleware earlier in the chain
+func LoggingMiddleware(log *zerolog.Logger, opts ...LoggingOption) func(http.Handler) http.Handler {
+	var options loggingOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := GetRequestContext(r.Context())
+
+			// Wrap response writer to capture status code
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			// Log request start
+			log.Info().
+				Str("request_id", reqCtx.RequestID).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("url", r.URL.String()).
+				Str("remote_addr", reqCtx.ClientIP).
+				Str("user_agent", r.UserAgent()).
+				Msg("Request started")
+
+			// Debug() is disabled above debug level, so the body isn't even read then
+			if options.logsBody(r) {
+				if bodyEvent := log.Debug(); bodyEvent.Enabled() {
+					bodyEvent.
+						Str("request_id", reqCtx.RequestID).
+						Str("method", r.Method).
+						Str("path", r.URL.Path).
+						Str("body", peekBody(r, options.bodyMaxBytes)).
+						Msg("Request body")
+				}
+			}
+
+			// Process request
+			next.ServeHTTP(ww, r)
+
+			// Calculate duration
+			duration := time.Since(reqCtx.StartTime)
+
+			// Determine log level based on status code
+			logEvent := log.Info()
+			if ww.Status() >= 500 {
+				logEvent = log.Error()
+			} else if ww.Status() >= 400 {
+				logEvent = log.Warn()
+			}
+
+			// Log request completion
+			logEvent.
+				Str("request_id", reqCtx.RequestID).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Int("status", ww.Status()).
+				Int("bytes", ww.BytesWritten()).
+				Dur("duration_ms", duration).
+				Msg("Request completed")
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// responseWriter wraps http.ResponseWriter to capture status code and size
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+}
+
+func (rw *responseWriter) WriteHeader(statusCode int) {
+	rw.statusCode = statusCode
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	size, err := rw.ResponseWriter.Write(b)
+	rw.size += size
+	return size, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush server-sent events)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// MetricsMiddleware records HTTP metrics for each request
+// Requires RequestContextMiddleware earlier in the chain; its request ID is attached to durations as an exemplar
+func MetricsMiddleware(m *metrics.HTTPMetrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := GetRequestContext(r.Context())
+
+			// Wrap the response writer to capture status code and size
+			rw := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK, // Default status
+				size:           0,
+			}
+
+			// Record request size
+			requestSize := float64(r.ContentLength)
+			if requestSize > 0 {
+				m.RequestSize.WithLabelValues(
+					r.Method,
+					r.URL.Path,
+				).Observe(requestSize)
+			}
+
+			// Process the request
+			next.ServeHTTP(rw, r)
+
+			// Calculate duration
+			duration := time.Since(reqCtx.StartTime).Seconds()
+			status := strconv.Itoa(rw.statusCode)
+
+			// Record metrics
+			m.RequestsTotal.WithLabelValues(
+				r.Method,
+				r.URL.Path,
+				status,
+			).Inc()
+
+			// Attach the request ID as an exemplar so a slow bucket links back to its logs
+			observeWithRequestID(m.RequestDuration.WithLabelValues(
+				r.Method,
+				r.URL.Path,
+				status,
+			), duration, reqCtx.RequestID)
+
+			m.ResponseSize.WithLabelValues(
+				r.Method,
+				r.URL.Path,
+				status,
+			).Observe(float64(rw.size))
+		})
+	}
+}
+
+// observeWithRequestID records value with a request_id exemplar when the observer supports exemplars
+func observeWithRequestID(observer prometheus.Observer, value float64, requestID string) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && requestID != "" {
+		exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"request_id": requestID})
+		return
+	}
+	observer.Observe(value)
+}
@@ -0,0 +1,365 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go/build"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/metrics"
+	"github.com/evyataryagoni/ip2country/pkg/middleware"
+	"github.com/evyataryagoni/ip2country/pkg/ratelimit"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+// modulePath is the import path prefix of this repository's packages
+const modulePath = "github.com/evyataryagoni/ip2country"
+
+// TestPackage_Standalone tests that the package imports nothing from internal/, directly or through another package of the module
+func TestPackage_Standalone(t *testing.T) {
+	visited := make(map[string]bool)
+	var walk func(path, dir string)
+	walk = func(path, dir string) {
+		if visited[path] {
+			return
+		}
+		visited[path] = true
+
+		pkg, err := build.Import(path, dir, 0)
+		if err != nil {
+			t.Fatalf("failed to load %s: %v", path, err)
+		}
+		for _, imported := range pkg.Imports {
+			if !strings.HasPrefix(imported, modulePath+"/") {
+				continue // Standard library or a third-party module
+			}
+			if strings.HasPrefix(imported, modulePath+"/internal/") {
+				t.Errorf("%s imports %s", path, imported)
+			}
+			walk(imported, pkg.Dir)
+		}
+	}
+	walk(modulePath+"/pkg/middleware", ".")
+}
+
+// okHandler answers 200 with a small body
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+// serve runs req through handler and returns the response
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestChain tests that every middleware composes on a chi router, the way another service would use them
+func TestChain(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	m := metrics.NewHTTPMetrics(prometheus.NewRegistry())
+	ipLimiter, fingerprintLimiter := ratelimit.NewMemoryLimiter(100), ratelimit.NewMemoryLimiter(100)
+	defer ipLimiter.Close()
+	defer fingerprintLimiter.Close()
+
+	r := chi.NewRouter()
+	r.Use(
+		middleware.NodeIdentityMiddleware("node-1"),
+		middleware.RequestContextMiddleware,
+		middleware.CORSMiddleware(middleware.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}),
+		middleware.LoggingMiddleware(&log),
+		middleware.RateLimitMiddleware(ipLimiter),
+		middleware.FingerprintMiddleware(fingerprintLimiter),
+		middleware.MetricsMiddleware(m),
+		middleware.TimeoutMiddleware(time.Second),
+	)
+	r.Get("/v1/find-country", func(w http.ResponseWriter, r *http.Request) {
+		reqCtx := middleware.GetRequestContext(r.Context())
+		w.Write([]byte(reqCtx.NodeID))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := serve(r, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "node-1" {
+		t.Fatalf("expected 200 with the node ID, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get(middleware.RequestIDHeader) == "" {
+		t.Error("expected a request ID header")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("expected the origin to be allowed, got %q", got)
+	}
+	if !strings.Contains(buf.String(), "Request completed") {
+		t.Errorf("expected the request to be logged, got %s", buf.String())
+	}
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("GET", "/v1/find-country", "200")); got != 1 {
+		t.Errorf("expected 1 request counted, got %v", got)
+	}
+}
+
+// TestRequestContextMiddleware tests the request ID, client IP and node ID stored for later middleware
+func TestRequestContextMiddleware(t *testing.T) {
+	var reqCtx *middleware.RequestContext
+	handler := middleware.NodeIdentityMiddleware("node-1")(middleware.RequestContextMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx = middleware.GetRequestContext(r.Context())
+		}),
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "upstream-id")
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	rec := serve(handler, req)
+
+	if reqCtx.RequestID != "upstream-id" || reqCtx.ClientIP != "203.0.113.7" || reqCtx.NodeID != "node-1" {
+		t.Errorf("expected upstream-id, 203.0.113.7 and node-1, got %+v", *reqCtx)
+	}
+	if got := rec.Header().Get(middleware.NodeIDHeader); got != "node-1" {
+		t.Errorf("expected %s node-1, got %q", middleware.NodeIDHeader, got)
+	}
+}
+
+// tierLimiter rejects every request of the "free" tier, whatever its key
+type tierLimiter struct {
+	ratelimit.Limiter
+}
+
+func (l tierLimiter) AllowRequest(r *http.Request, key string) bool {
+	return r.Header.Get("X-Tier") != "free"
+}
+
+// TestRateLimitMiddleware tests that requests over the limit get 429, except under exempt paths,
+// and that a ratelimit.RequestLimiter decides from the request
+func TestRateLimitMiddleware(t *testing.T) {
+	lim := ratelimit.NewMemoryLimiterWithBurst(0.001, 2)
+	defer lim.Close()
+	handler := middleware.RateLimitMiddleware(lim, middleware.WithExemptPaths("/health"))(okHandler)
+
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if rec := serve(handler, httptest.NewRequest(http.MethodGet, "/v1/find-country", nil)); rec.Code != expected {
+			t.Errorf("request %d: expected status %d, got %d", i+1, expected, rec.Code)
+		}
+	}
+	if rec := serve(handler, httptest.NewRequest(http.MethodGet, "/health", nil)); rec.Code != http.StatusOK {
+		t.Errorf("expected exempt path to bypass the limit, got %d", rec.Code)
+	}
+
+	handler = middleware.RateLimitMiddleware(tierLimiter{lim})(okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/v1/find-country", nil)
+	req.Header.Set("X-Tier", "free")
+	if rec := serve(handler, req); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the request limiter to reject the free tier, got %d", rec.Code)
+	}
+}
+
+// TestRateLimitMiddleware_Headers tests that responses report the allowance left, and that a 429 says when to retry
+func TestRateLimitMiddleware_Headers(t *testing.T) {
+	lim := ratelimit.NewMemoryLimiterWithBurst(0.5, 2)
+	defer lim.Close()
+	handler := middleware.RateLimitMiddleware(lim, middleware.WithExemptPaths("/health"))(okHandler)
+
+	for i, expected := range []string{"1", "0", "0"} {
+		rec := serve(handler, httptest.NewRequest(http.MethodGet, "/v1/find-country", nil))
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: expected X-RateLimit-Limit 2, got %q", i+1, got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != expected {
+			t.Errorf("request %d: expected X-RateLimit-Remaining %s, got %q", i+1, expected, got)
+		}
+		if reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64); err != nil || reset < time.Now().Unix() {
+			t.Errorf("request %d: expected X-RateLimit-Reset to be a future Unix time, got %q", i+1, rec.Header().Get("X-RateLimit-Reset"))
+		}
+		if retryAfter := rec.Header().Get("Retry-After"); (rec.Code == http.StatusTooManyRequests) != (retryAfter != "") {
+			t.Errorf("request %d: expected Retry-After only on a 429, got status %d and %q", i+1, rec.Code, retryAfter)
+		}
+	}
+
+	// Refilling 2 tokens at 0.5/s takes 4s
+	rec := serve(handler, httptest.NewRequest(http.MethodGet, "/v1/find-country", nil))
+	if got := rec.Header().Get("Retry-After"); got != "4" {
+		t.Errorf("expected Retry-After 4, got %q", got)
+	}
+	if rec := serve(handler, httptest.NewRequest(http.MethodGet, "/health", nil)); rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("expected no rate limit headers on an exempt path, got %v", rec.Header())
+	}
+
+	// Limiters that can't be inspected set no headers
+	handler = middleware.RateLimitMiddleware(tierLimiter{lim})(okHandler)
+	if rec := serve(handler, httptest.NewRequest(http.MethodGet, "/v1/find-country", nil)); rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("expected no rate limit headers, got %v", rec.Header())
+	}
+}
+
+// TestFingerprintMiddleware tests that clients sharing a fingerprint share a limit, and that a nil limiter disables it
+func TestFingerprintMiddleware(t *testing.T) {
+	lim := ratelimit.NewMemoryLimiterWithBurst(0.001, 1)
+	defer lim.Close()
+	handler := middleware.FingerprintMiddleware(lim)(okHandler)
+
+	first := httptest.NewRequest(http.MethodGet, "/", nil)
+	first.RemoteAddr = "198.51.100.1:1234"
+	second := httptest.NewRequest(http.MethodGet, "/", nil)
+	second.RemoteAddr = "198.51.100.2:1234"
+
+	if rec := serve(handler, first); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", rec.Code)
+	}
+	rec := serve(handler, second)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "FINGERPRINT_RATE_LIMITED") {
+		t.Errorf("expected the same fingerprint from another IP to be limited, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(middleware.FingerprintMiddleware(nil)(okHandler), second); rec.Code != http.StatusOK {
+		t.Errorf("expected a nil limiter to let requests through, got %d", rec.Code)
+	}
+}
+
+// TestLoggingMiddleware tests the completion log line, with its level set by the status
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	handler := middleware.RequestContextMiddleware(middleware.LoggingMiddleware(&log)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+	))
+
+	serve(handler, httptest.NewRequest(http.MethodGet, "/v1/find-country?ip=203.0.113.1", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a start and a completion line, got %v", lines)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", lines[1], err)
+	}
+	if entry["message"] != "Request completed" || entry["level"] != "warn" || entry["status"] != float64(404) {
+		t.Errorf("expected a warn completion line with status 404, got %v", entry)
+	}
+}
+
+// TestMetricsMiddleware tests the request counter and response size recorded per status
+func TestMetricsMiddleware(t *testing.T) {
+	m := metrics.NewHTTPMetrics(prometheus.NewRegistry())
+	handler := middleware.RequestContextMiddleware(middleware.MetricsMiddleware(m)(okHandler))
+
+	for range 3 {
+		serve(handler, httptest.NewRequest(http.MethodGet, "/v1/find-country", nil))
+	}
+
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("GET", "/v1/find-country", "200")); got != 3 {
+		t.Errorf("expected 3 requests counted, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.ResponseSize); got != 1 {
+		t.Errorf("expected 1 response size series, got %d", got)
+	}
+}
+
+// TestCORSMiddleware tests the CORS headers for allowed, other and missing origins, and preflight requests
+func TestCORSMiddleware(t *testing.T) {
+	cfg := middleware.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: time.Hour}
+
+	tests := []struct {
+		name           string
+		cfg            middleware.CORSConfig
+		method         string
+		origin         string
+		status         int
+		allowOrigin    string
+		allowMethods   string
+		reachesHandler bool
+	}{
+		{"no origin", cfg, http.MethodGet, "", http.StatusOK, "", "", true},
+		{"allowed origin", cfg, http.MethodGet, "https://app.example.com", http.StatusOK, "https://app.example.com", "", true},
+		{"other origin", cfg, http.MethodGet, "https://evil.example.com", http.StatusOK, "", "", true},
+		{"any origin", middleware.CORSConfig{AllowedOrigins: []string{"*"}}, http.MethodGet, "https://evil.example.com", http.StatusOK, "*", "", true},
+		{"preflight", cfg, http.MethodOptions, "https://app.example.com", http.StatusNoContent, "https://app.example.com", "GET, POST, DELETE, OPTIONS", false},
+		{"preflight from other origin", cfg, http.MethodOptions, "https://evil.example.com", http.StatusOK, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			handler := middleware.CORSMiddleware(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+			}))
+
+			req := httptest.NewRequest(tt.method, "/v1/find-country", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			rec := serve(handler, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.allowOrigin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.allowMethods {
+				t.Errorf("expected Access-Control-Allow-Methods %q, got %q", tt.allowMethods, got)
+			}
+			if reached != tt.reachesHandler {
+				t.Errorf("expected handler reached=%v, got %v", tt.reachesHandler, reached)
+			}
+			if tt.allowMethods != "" && rec.Header().Get("Access-Control-Max-Age") != "3600" {
+				t.Errorf("expected Access-Control-Max-Age 3600, got %q", rec.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+// TestTimeoutMiddleware tests that a handler giving up at the deadline gets 503, and that responses already started are kept
+func TestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"fast handler", okHandler, http.StatusOK},
+		{"handler gives up", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}, http.StatusServiceUnavailable},
+		{"handler answers after the deadline", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}, http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middleware.TimeoutMiddleware(10 * time.Millisecond)(tt.handler)
+
+			rec := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+
+	// The deadline is the request's: a cancelled client isn't a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler := middleware.TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	if rec := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)); rec.Code == http.StatusServiceUnavailable {
+		t.Error("expected no 503 for a cancelled request")
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// NodeIDHeader identifies the server instance that handled the request
+const NodeIDHeader = "X-Processing-Node"
+
+// nodeIDKey is the context key for the node ID (read by RequestContextMiddleware)
+type nodeIDKey struct{}
+
+// NodeIdentityMiddleware sets X-Processing-Node on every response so requests
+// behind a load balancer can be traced to the instance that served them
+// Register it first: the header is set before anything else runs, so even
+// rate limited (429) and recovered (500) responses carry it
+func NodeIdentityMiddleware(nodeID string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(NodeIDHeader, nodeID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nodeIDKey{}, nodeID)))
+		})
+	}
+}
+
+// nodeIDFromContext returns the node ID stored by NodeIdentityMiddleware ("" if it didn't run)
+func nodeIDFromContext(ctx context.Context) string {
+	nodeID, _ := ctx.Value(nodeIDKey{}).(string)
+	return nodeID
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/pkg/ratelimit"
+)
+
+// RateLimitOption configures RateLimitMiddleware and FingerprintMiddleware
+type RateLimitOption func(*rateLimitOptions)
+
+type rateLimitOptions struct {
+	exemptPaths []string
+}
+
+// WithExemptPaths lets requests under paths through without asking the limiter
+// A path exempts itself and everything below it: "/admin" exempts "/admin/stats" but not "/administrator".
+// Used for Kubernetes probes and Prometheus scrapes, which shouldn't use up the allowance of the IP they come from
+func WithExemptPaths(paths ...string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.exemptPaths = append(o.exemptPaths, paths...)
+	}
+}
+
+// newRateLimitOptions applies opts
+func newRateLimitOptions(opts []RateLimitOption) rateLimitOptions {
+	var options rateLimitOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// ExemptMatcher returns a function reporting whether a request is under one of the exempt paths of opts,
+// for other middleware to skip the same requests as RateLimitMiddleware (e.g. load shedding)
+func ExemptMatcher(opts ...RateLimitOption) func(r *http.Request) bool {
+	options := newRateLimitOptions(opts)
+	return options.exempt
+}
+
+// exempt reports whether r is under one of the exempt paths
+func (o *rateLimitOptions) exempt(r *http.Request) bool {
+	for _, path := range o.exemptPaths {
+		path = strings.TrimSuffix(path, "/") // "/admin/" is the same as "/admin"
+		if r.URL.Path == path || strings.HasPrefix(r.URL.Path, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitMiddleware enforces rate limiting per IP address (returns 429 when exceeded)
+// A limiter implementing ratelimit.RequestLimiter gets the request too, so the limit can depend on who is calling
+// A limiter implementing ratelimit.InspectableLimiter also tells clients their allowance: every response it
+// checks carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (Unix time), and a 429 Retry-After
+func RateLimitMiddleware(lim ratelimit.Limiter, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	options := newRateLimitOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if options.exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := r.RemoteAddr
+
+			// Try to get real IP from headers (for proxies/load balancers)
+			// Priority: X-Real-IP > X-Forwarded-For > RemoteAddr
+			if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+				ip = realIP
+			} else if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+				// X-Forwarded-For can contain multiple IPs (format: "client, proxy1, proxy2")
+				if firstIP := forwardedFor; firstIP != "" {
+					ip = firstIP
+				}
+			}
+
+			var allowed bool
+			if requestLimiter, ok := lim.(ratelimit.RequestLimiter); ok {
+				allowed = requestLimiter.AllowRequest(r, ip)
+			} else {
+				allowed = lim.Allow(ip)
+			}
+			setRateLimitHeaders(w.Header(), lim, ip, allowed)
+
+			if !allowed {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Cache-Control", "no-store") // Usually runs before any caching middleware, so set it here
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Rate limit exceeded. Please try again later.",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders reports key's allowance after a request in the X-RateLimit-* headers, and when
+// to retry if the request was rejected
+// Nothing is set if lim isn't a ratelimit.InspectableLimiter or doesn't know its limit
+func setRateLimitHeaders(h http.Header, lim ratelimit.Limiter, key string, allowed bool) {
+	inspectable, ok := lim.(ratelimit.InspectableLimiter)
+	if !ok {
+		return
+	}
+	limit := inspectable.Limit()
+	if limit <= 0 {
+		return
+	}
+	resetAt := inspectable.ResetAt(key)
+
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(inspectable.Remaining(key)))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+	if !allowed {
+		setRetryAfter(h, resetAt)
+	}
+}
+
+// setRetryAfter sets Retry-After to the whole seconds until resetAt, at least 1
+// A rejected client retrying sooner would only be rejected again
+func setRetryAfter(h http.Header, resetAt time.Time) {
+	seconds := int(math.Ceil(time.Until(resetAt).Seconds()))
+	h.Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// TimeoutMiddleware cancels the request's context after timeout
+// Handlers (and the stores they query) must honour the context: the middleware doesn't interrupt them.
+// If the deadline passed and the handler returned without writing anything, the client gets
+// 503 Service Unavailable; a handler that already started its response keeps it
+func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !tw.wrote && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Request timed out",
+				})
+			}
+		})
+	}
+}
+
+// timeoutWriter records whether the handler wrote a response
+type timeoutWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.wrote = true
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.wrote = true
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush server-sent events)
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Config holds configuration for creating a rate limiter with New
+// Mirrors ip2country's internal limiter.LimiterConfig
+type Config struct {
+	Type              string        // "memory", "sliding", "leaky" or "redis"
+	RequestsPerSecond float64       // Rate limit (can be fractional, e.g., 0.2 = 1 req per 5 sec)
+	BurstSize         int           // Max requests allowed at once (0 = same as RequestsPerSecond; leaky: the queue size, 0 = 1)
+	Window            time.Duration // Window of the sliding limiter, allowed RequestsPerSecond * Window requests (0 = 1 second)
+
+	// Redis-specific config
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+
+	// Retry controls retries of the initial Redis connection (zero value = single attempt)
+	Retry RetryConfig
+}
+
+// RetryConfig controls retries of a connection attempt with exponential backoff
+type RetryConfig struct {
+	MaxRetries int           // Retries after the first attempt (0 = no retries)
+	BaseDelay  time.Duration // Delay before the first retry, doubled on each subsequent retry
+	MaxDelay   time.Duration // Upper bound for the delay (0 = no bound)
+
+	// OnRetry is called before each retry, e.g. to log it (nil = silent)
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// delay returns the backoff before retry number attempt (1-based)
+// Example with BaseDelay=1s, MaxDelay=30s: 1s, 2s, 4s, 8s, 16s, 30s, 30s...
+func (cfg RetryConfig) delay(attempt int) time.Duration {
+	d := cfg.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if cfg.MaxDelay > 0 && d >= cfg.MaxDelay {
+			return cfg.MaxDelay
+		}
+	}
+	if cfg.MaxDelay > 0 && d > cfg.MaxDelay {
+		return cfg.MaxDelay
+	}
+	return d
+}
+
+// connect calls connectFn until it succeeds or MaxRetries retries have failed, returning the last error
+func (cfg RetryConfig) connect(connectFn func() error) error {
+	err := connectFn()
+	for attempt := 1; err != nil && attempt <= cfg.MaxRetries; attempt++ {
+		delay := cfg.delay(attempt)
+		if cfg.OnRetry != nil {
+			cfg.OnRetry(attempt, delay, err)
+		}
+		time.Sleep(delay)
+		err = connectFn()
+	}
+	return err
+}
+
+// New creates a rate limiter based on the configuration (factory pattern)
+func New(cfg Config) (Limiter, error) {
+	limiterType := strings.ToLower(strings.TrimSpace(cfg.Type))
+
+	switch limiterType {
+	case "memory", "":
+		// In-memory rate limiter (good for single-server deployments)
+		// Default burst to the sustained rate for backward compatibility
+		burst := cfg.RequestsPerSecond
+		if cfg.BurstSize > 0 {
+			burst = float64(cfg.BurstSize)
+		}
+		if burst < cfg.RequestsPerSecond {
+			return nil, fmt.Errorf("burst size %d is smaller than the rate limit %.2f req/s", cfg.BurstSize, cfg.RequestsPerSecond)
+		}
+		return NewMemoryLimiterWithBurst(cfg.RequestsPerSecond, burst), nil
+
+	case "sliding":
+		// In-memory sliding window: a strict count per window, with no burst
+		// NewSlidingWindowLimiter allows at least 1 request, however low the rate
+		window := cfg.Window
+		if window <= 0 {
+			window = time.Second
+		}
+		return NewSlidingWindowLimiter(int(math.Round(cfg.RequestsPerSecond*window.Seconds())), window), nil
+
+	case "leaky":
+		// In-memory leaky bucket: requests drained at a constant rate, BurstSize of them queued
+		return NewLeakyBucketLimiter(cfg.RequestsPerSecond, cfg.BurstSize), nil
+
+	case "redis":
+		// Redis-based rate limiter (required for multi-server deployments)
+		limiter, err := NewRedisLimiterWithRetry(
+			cfg.RedisAddr,
+			cfg.RedisPassword,
+			cfg.RedisDB,
+			cfg.RequestsPerSecond,
+			cfg.Retry,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis limiter: %w", err)
+		}
+		return limiter, nil
+
+	default:
+		return nil, fmt.Errorf("unknown rate limiter type: %s (supported: 'memory', 'sliding', 'leaky', 'redis')", cfg.Type)
+	}
+}
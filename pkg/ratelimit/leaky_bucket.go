@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// leakyBucket is the queue of one client
+// Rather than a level that leaks away, it holds when the queue will have drained: each request
+// adds one interval to it, and the queue holds as many requests as intervals left until then.
+// Time is exact where a float level would drift, so a request 1/rate after the previous one is
+// always allowed
+type leakyBucket struct {
+	emptyAt     time.Time  // When the queued requests will all have drained
+	lastRequest time.Time  // When the last request was allowed
+	mu          sync.Mutex // Protects emptyAt and lastRequest
+}
+
+// queued returns the number of requests still in the queue at now
+// Must be called with mutex locked
+func (b *leakyBucket) queued(now time.Time, interval time.Duration) float64 {
+	if !b.emptyAt.After(now) {
+		return 0
+	}
+	return float64(b.emptyAt.Sub(now)) / float64(interval)
+}
+
+// LeakyBucketLimiter queues each client's requests in a bucket draining at a constant rate, and rejects
+// requests that would overflow it
+// With a queue of 1, requests must be spaced at least 1/rate apart, so a backend behind it never sees
+// a spike; larger queues allow that many requests at once. The queue drains continuously, computed on
+// each request rather than by a goroutine per client
+type LeakyBucketLimiter struct {
+	buckets     sync.Map      // map[string]*leakyBucket - keyed by IP address
+	interval    time.Duration // Time to drain one request (1/rate)
+	maxQueue    int           // Requests the queue holds
+	cleanupMu   sync.Mutex
+	lastCleanup time.Time
+
+	clockFn func() time.Time // Current time (time.Now; replaced in tests)
+}
+
+// NewLeakyBucketLimiter creates an in-memory limiter draining ratePerSec requests per IP, queueing up to maxQueue
+// A maxQueue < 1 queues 1, so requests are spaced evenly
+func NewLeakyBucketLimiter(ratePerSec float64, maxQueue int) *LeakyBucketLimiter {
+	return &LeakyBucketLimiter{
+		interval:    time.Duration(float64(time.Second) / ratePerSec),
+		maxQueue:    max(maxQueue, 1),
+		lastCleanup: time.Now(),
+		clockFn:     time.Now,
+	}
+}
+
+// Allow queues a request from the given IP, returning false if its queue is full
+func (rl *LeakyBucketLimiter) Allow(ip string) bool {
+	now := rl.clockFn()
+	bucket := rl.getBucket(ip)
+
+	bucket.mu.Lock()
+	if bucket.emptyAt.Before(now) {
+		bucket.emptyAt = now
+	}
+	// Room for one more request while the queue drains within maxQueue intervals
+	allowed := bucket.emptyAt.Sub(now) <= time.Duration(rl.maxQueue-1)*rl.interval
+	if allowed {
+		bucket.emptyAt = bucket.emptyAt.Add(rl.interval)
+		bucket.lastRequest = now
+	}
+	bucket.mu.Unlock()
+
+	// Periodically clean up drained buckets (prevent memory leak)
+	rl.maybeCleanup()
+
+	return allowed
+}
+
+// getBucket gets or creates the bucket of an IP address
+func (rl *LeakyBucketLimiter) getBucket(ip string) *leakyBucket {
+	if value, ok := rl.buckets.Load(ip); ok {
+		return value.(*leakyBucket)
+	}
+	actual, _ := rl.buckets.LoadOrStore(ip, &leakyBucket{})
+	return actual.(*leakyBucket)
+}
+
+// maybeCleanup removes the buckets that have drained empty, every DefaultCleanupInterval
+// An empty bucket is the same as a new one, so removing it never gives an IP extra requests
+func (rl *LeakyBucketLimiter) maybeCleanup() {
+	rl.cleanupMu.Lock()
+	defer rl.cleanupMu.Unlock()
+
+	now := rl.clockFn()
+	if now.Sub(rl.lastCleanup) < DefaultCleanupInterval {
+		return
+	}
+
+	rl.buckets.Range(func(key, value interface{}) bool {
+		bucket := value.(*leakyBucket)
+		bucket.mu.Lock()
+		empty := !bucket.emptyAt.After(now)
+		bucket.mu.Unlock()
+
+		if empty {
+			rl.buckets.Delete(key)
+		}
+		return true
+	})
+
+	rl.lastCleanup = now
+}
+
+// Reset removes the IP's bucket; its next request finds an empty queue
+func (rl *LeakyBucketLimiter) Reset(ip string) error {
+	rl.buckets.Delete(ip)
+	return nil
+}
+
+// List returns the state of every IP with a bucket
+// TokensRemaining is the room left in the IP's queue
+func (rl *LeakyBucketLimiter) List() (map[string]RateLimitStatus, error) {
+	now := rl.clockFn()
+	statuses := make(map[string]RateLimitStatus)
+	rl.buckets.Range(func(key, value interface{}) bool {
+		ip := key.(string)
+		bucket := value.(*leakyBucket)
+
+		bucket.mu.Lock()
+		queued := bucket.queued(now, rl.interval)
+		lastRequest := bucket.lastRequest
+		bucket.mu.Unlock()
+
+		statuses[ip] = RateLimitStatus{
+			IP:              ip,
+			TokensRemaining: float64(rl.maxQueue) - queued,
+			LastAccessedAt:  lastRequest,
+			Allowed:         queued <= float64(rl.maxQueue-1),
+		}
+		return true
+	})
+	return statuses, nil
+}
+
+// Limit returns the requests the queue holds
+// Implements the InspectableLimiter interface
+func (rl *LeakyBucketLimiter) Limit() int {
+	return rl.maxQueue
+}
+
+// Remaining returns the room left in the IP's queue
+// A partly drained request still takes its place, since it blocks the next one until it's gone
+// Implements the InspectableLimiter interface
+func (rl *LeakyBucketLimiter) Remaining(ip string) int {
+	value, ok := rl.buckets.Load(ip)
+	if !ok {
+		return rl.maxQueue
+	}
+	bucket := value.(*leakyBucket)
+	bucket.mu.Lock()
+	queued := bucket.queued(rl.clockFn(), rl.interval)
+	bucket.mu.Unlock()
+	return max(rl.maxQueue-int(math.Ceil(queued)), 0)
+}
+
+// ResetAt returns when the IP's queue will have drained
+// Implements the InspectableLimiter interface
+func (rl *LeakyBucketLimiter) ResetAt(ip string) time.Time {
+	now := rl.clockFn()
+	value, ok := rl.buckets.Load(ip)
+	if !ok {
+		return now
+	}
+	bucket := value.(*leakyBucket)
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	if bucket.emptyAt.After(now) {
+		return bucket.emptyAt
+	}
+	return now
+}
+
+// Close does nothing: the in-memory limiter has no resources to release
+func (rl *LeakyBucketLimiter) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newFakeClockLeakyLimiter returns a leaky bucket limiter reading the time from a fake clock
+func newFakeClockLeakyLimiter(rate float64, maxQueue int) (*LeakyBucketLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rl := NewLeakyBucketLimiter(rate, maxQueue)
+	rl.clockFn = clock.Now
+	rl.lastCleanup = clock.Now()
+	return rl, clock
+}
+
+// TestLeakyBucketLimiter_ConstantRate tests that with a queue of 1, requests closer than 1/rate apart are rejected
+func TestLeakyBucketLimiter_ConstantRate(t *testing.T) {
+	rl, clock := newFakeClockLeakyLimiter(10, 0)
+	ip := "192.168.1.1"
+
+	if !rl.Allow(ip) {
+		t.Fatal("first request should be allowed")
+	}
+	for i := 0; i < 9; i++ {
+		clock.Advance(10 * time.Millisecond)
+		if rl.Allow(ip) {
+			t.Fatalf("request %dms after the first should be rate limited", (i+1)*10)
+		}
+	}
+
+	// 100ms after the first request it has drained
+	clock.Advance(10 * time.Millisecond)
+	if !rl.Allow(ip) {
+		t.Error("expected a request 1/rate after the previous one to be allowed")
+	}
+}
+
+// TestLeakyBucketLimiter_Queue tests that a queue of n takes n requests at once, then drains at the rate
+func TestLeakyBucketLimiter_Queue(t *testing.T) {
+	rl, clock := newFakeClockLeakyLimiter(2, 4)
+	ip := "192.168.1.1"
+
+	for i := 0; i < 4; i++ {
+		if !rl.Allow(ip) {
+			t.Fatalf("request %d should be queued", i+1)
+		}
+	}
+	if rl.Allow(ip) {
+		t.Fatal("request 5 should overflow the queue")
+	}
+
+	// 1s at 2 req/s drains 2 requests
+	clock.Advance(time.Second)
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if rl.Allow(ip) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("expected 2 requests allowed after 1s, got %d", allowed)
+	}
+
+	statuses, _ := rl.List()
+	if status := statuses[ip]; status.TokensRemaining != 0 || status.Allowed {
+		t.Errorf("expected a full queue, got %+v", status)
+	}
+	clock.Advance(500 * time.Millisecond)
+	statuses, _ = rl.List()
+	if status := statuses[ip]; status.TokensRemaining != 1 || !status.Allowed {
+		t.Errorf("expected room for 1 request after 500ms, got %+v", status)
+	}
+}
+
+// TestLeakyBucketLimiter_Cleanup tests that drained buckets are removed, and others kept
+func TestLeakyBucketLimiter_Cleanup(t *testing.T) {
+	rl, clock := newFakeClockLeakyLimiter(0.01, 5) // Drains 1 request in 100s
+	rl.Allow("192.168.1.1")
+	clock.Advance(4 * time.Minute)
+	rl.Allow("192.168.1.2")
+
+	clock.Advance(90 * time.Second) // Past DefaultCleanupInterval; only the first IP's queue has drained
+	rl.Allow("192.168.1.3")
+
+	statuses, _ := rl.List()
+	if _, ok := statuses["192.168.1.1"]; ok {
+		t.Error("expected the drained bucket to be removed")
+	}
+	if len(statuses) != 2 {
+		t.Errorf("expected 2 buckets left, got %v", statuses)
+	}
+}
@@ -0,0 +1,374 @@
+// Package ratelimit provides per-key rate limiters: an in-memory token bucket, sliding window or
+// leaky bucket for a single instance, and a Redis-backed fixed window shared by every instance. It has no dependencies on
+// the rest of ip2country, so other services can import it on its own
+package ratelimit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter is the interface that all rate limiters must implement
+// This allows us to easily swap between in-memory and Redis implementations
+type Limiter interface {
+	// Allow checks if a request from the given IP should be allowed
+	// Returns true if allowed, false if rate limited
+	Allow(ip string) bool
+
+	// Reset clears the rate limit state for an IP, so its next request starts with a full allowance
+	// Resetting an IP the limiter has never seen is not an error
+	Reset(ip string) error
+
+	// List returns the current state of every IP the limiter is tracking, keyed by IP
+	// For monitoring: IPs the limiter has forgotten (expired or cleaned up) aren't listed
+	List() (map[string]RateLimitStatus, error)
+
+	// Close cleans up any resources (Redis connections, goroutines, etc.)
+	Close() error
+}
+
+// RequestLimiter is implemented by limiters whose limit depends on the request, not just the key
+// (e.g. a per-customer-tier limit). middleware.RateLimitMiddleware calls AllowRequest instead of Allow
+// when the limiter implements it
+type RequestLimiter interface {
+	AllowRequest(r *http.Request, key string) bool
+}
+
+// InspectableLimiter is implemented by limiters that can report an IP's allowance without using any of it
+// middleware.RateLimitMiddleware sends it to clients in the X-RateLimit-* headers, and in Retry-After on a 429
+type InspectableLimiter interface {
+	// Limit returns the number of requests an IP can make with its full allowance
+	// 0 means the limiter can't tell (e.g. a wrapper around a limiter that isn't inspectable)
+	Limit() int
+
+	// Remaining returns the number of requests the IP can make right now
+	Remaining(ip string) int
+
+	// ResetAt returns when the IP will have its full allowance again (now if it already has,
+	// except for fixed windows, which all reset at the end of the current window)
+	ResetAt(ip string) time.Time
+}
+
+// RateLimitStatus is the rate limit state of one IP, as reported by Limiter.List
+type RateLimitStatus struct {
+	IP              string    `json:"ip" example:"192.168.1.1"`
+	TokensRemaining float64   `json:"tokens_remaining" example:"4.5"` // Requests the IP can make right now
+	LastAccessedAt  time.Time `json:"last_accessed_at"`               // When the IP last made a request
+	Allowed         bool      `json:"allowed" example:"true"`         // Whether the IP's next request would be allowed
+}
+
+// TokenBucket represents a token bucket for a single client
+// The token bucket algorithm allows bursts while maintaining an average rate
+//
+// How it works:
+//   - Each client has a bucket with a maximum capacity
+//   - Tokens are added at a fixed rate (e.g., 10/second)
+//   - Each request consumes 1 token
+//   - If no tokens available, request is rejected (429 Too Many Requests)
+type TokenBucket struct {
+	tokens         float64    // Current number of tokens in the bucket
+	capacity       float64    // Maximum number of tokens (burst size)
+	refillRate     float64    // Tokens added per second
+	lastRefillTime time.Time  // Last time tokens were added
+	mu             sync.Mutex // Protects tokens and lastRefillTime
+
+	clockFn func() time.Time // Current time (time.Now; replaced in tests)
+}
+
+// NewTokenBucket creates a new token bucket
+//
+// Parameters:
+//   - rate: tokens per second (e.g., 10 = 10 requests/second)
+//   - capacity: maximum tokens (burst size, usually same as rate)
+//
+// Returns:
+//   - *TokenBucket: new token bucket, starts full
+func NewTokenBucket(rate float64, capacity float64) *TokenBucket {
+	return newTokenBucket(rate, capacity, time.Now)
+}
+
+// newTokenBucket creates a token bucket reading the time from clockFn
+func newTokenBucket(rate float64, capacity float64, clockFn func() time.Time) *TokenBucket {
+	// Start with at least 1 token to allow first request
+	// For fractional rates (e.g., 0.2), capacity might be < 1
+	initialTokens := capacity
+	if initialTokens < 1.0 {
+		initialTokens = 1.0
+	}
+
+	return &TokenBucket{
+		tokens:         initialTokens,
+		capacity:       max(capacity, 1.0), // Capacity should be at least 1
+		refillRate:     rate,
+		lastRefillTime: clockFn(),
+		clockFn:        clockFn,
+	}
+}
+
+// Allow checks if a request should be allowed
+// This is the main method called for each request
+//
+// Returns:
+//   - bool: true if request is allowed, false if rate limited
+func (tb *TokenBucket) Allow() bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	// Refill tokens based on time elapsed
+	tb.refill()
+
+	// Check if we have tokens available
+	if tb.tokens >= 1.0 {
+		// Consume 1 token
+		tb.tokens -= 1.0
+		return true
+	}
+
+	// No tokens available - rate limit exceeded
+	return false
+}
+
+// status returns the bucket's state for ip without consuming or refilling tokens
+func (tb *TokenBucket) status(ip string) RateLimitStatus {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tokens := min(tb.tokens+tb.clockFn().Sub(tb.lastRefillTime).Seconds()*tb.refillRate, tb.capacity)
+	return RateLimitStatus{
+		IP:              ip,
+		TokensRemaining: tokens,
+		LastAccessedAt:  tb.lastRefillTime, // Every Allow refills, so this is the last request
+		Allowed:         tokens >= 1.0,
+	}
+}
+
+// inspect returns the bucket's tokens and when it will be full again, without consuming or refilling tokens
+func (tb *TokenBucket) inspect() (float64, time.Time) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.clockFn()
+	tokens := min(tb.tokens+now.Sub(tb.lastRefillTime).Seconds()*tb.refillRate, tb.capacity)
+	if tokens >= tb.capacity || tb.refillRate <= 0 {
+		return tokens, now
+	}
+	return tokens, now.Add(time.Duration((tb.capacity - tokens) / tb.refillRate * float64(time.Second)))
+}
+
+// refill adds tokens based on time elapsed since last refill
+// Must be called with mutex locked
+func (tb *TokenBucket) refill() {
+	now := tb.clockFn()
+	elapsed := now.Sub(tb.lastRefillTime).Seconds()
+
+	// Calculate tokens to add: elapsed_time * rate
+	// Example: 0.5 seconds * 10 tokens/sec = 5 tokens
+	tokensToAdd := elapsed * tb.refillRate
+
+	// Add tokens, but don't exceed capacity
+	tb.tokens = min(tb.tokens+tokensToAdd, tb.capacity)
+
+	// Update last refill time
+	tb.lastRefillTime = now
+}
+
+// MemoryLimiter manages token buckets for multiple clients (per-IP)
+// Thread-safe using sync.Map
+// This is an in-memory implementation suitable for single-server deployments
+type MemoryLimiter struct {
+	buckets     sync.Map // map[string]*TokenBucket - keyed by IP address
+	rate        float64  // Tokens per second
+	capacity    float64  // Maximum tokens (burst size)
+	cleanupMu   sync.Mutex
+	lastCleanup time.Time
+
+	cleanupInterval time.Duration    // Minimum time between two cleanups
+	inactiveAfter   time.Duration    // Buckets not accessed for this long are removed by a cleanup
+	clockFn         func() time.Time // Current time (time.Now; replaced in tests)
+}
+
+// Cleanup defaults of the in-memory limiter
+const (
+	DefaultCleanupInterval = 5 * time.Minute // How often inactive buckets are looked for
+	DefaultInactiveAfter   = 5 * time.Minute // How long a bucket must go unused to be removed
+)
+
+// NewMemoryLimiter creates a new in-memory rate limiter
+//
+// Parameters:
+//   - requestsPerSecond: allowed requests per second per IP (can be fractional, e.g., 0.2)
+//
+// Returns:
+//   - *MemoryLimiter: new in-memory rate limiter instance
+func NewMemoryLimiter(requestsPerSecond float64) *MemoryLimiter {
+	// Burst size equals rate (can burst up to 1 second worth)
+	return NewMemoryLimiterWithBurst(requestsPerSecond, requestsPerSecond)
+}
+
+// NewMemoryLimiterWithBurst creates an in-memory rate limiter with a burst size separate from the sustained rate
+//
+// Parameters:
+//   - requestsPerSecond: sustained requests per second per IP (token refill rate)
+//   - burst: maximum requests allowed at once per IP (token bucket capacity)
+//
+// Example: NewMemoryLimiterWithBurst(5, 20) allows 20 requests instantly, then 5/sec
+//
+// Returns:
+//   - *MemoryLimiter: new in-memory rate limiter instance
+func NewMemoryLimiterWithBurst(requestsPerSecond float64, burst float64) *MemoryLimiter {
+	return NewMemoryLimiterWithCleanup(requestsPerSecond, burst, DefaultCleanupInterval, DefaultInactiveAfter)
+}
+
+// NewMemoryLimiterWithCleanup creates an in-memory rate limiter with its own cleanup schedule
+//
+// Parameters:
+//   - requestsPerSecond, burst: as for NewMemoryLimiterWithBurst
+//   - cleanupInterval: minimum time between two looks for inactive buckets (0 = DefaultCleanupInterval)
+//   - inactiveAfter: how long a bucket must go unused to be removed (0 = DefaultInactiveAfter)
+//
+// A bucket removed too early only costs its IP a full allowance again, so keep inactiveAfter
+// well above the time a bucket takes to refill (burst / requestsPerSecond)
+//
+// Returns:
+//   - *MemoryLimiter: new in-memory rate limiter instance
+func NewMemoryLimiterWithCleanup(requestsPerSecond, burst float64, cleanupInterval, inactiveAfter time.Duration) *MemoryLimiter {
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+	if inactiveAfter <= 0 {
+		inactiveAfter = DefaultInactiveAfter
+	}
+	return &MemoryLimiter{
+		rate:            requestsPerSecond,
+		capacity:        burst,
+		lastCleanup:     time.Now(),
+		cleanupInterval: cleanupInterval,
+		inactiveAfter:   inactiveAfter,
+		clockFn:         time.Now,
+	}
+}
+
+// Allow checks if a request from the given IP should be allowed
+// This is called by the middleware for each request
+//
+// Parameters:
+//   - ip: client IP address
+//
+// Returns:
+//   - bool: true if request is allowed, false if rate limited
+func (rl *MemoryLimiter) Allow(ip string) bool {
+	// Get or create token bucket for this IP
+	bucket := rl.getBucket(ip)
+
+	// Check if request is allowed
+	allowed := bucket.Allow()
+
+	// Periodically clean up old buckets (prevent memory leak)
+	rl.maybeCleanup()
+
+	return allowed
+}
+
+// getBucket gets or creates a token bucket for an IP address
+// Thread-safe using sync.Map's LoadOrStore
+func (rl *MemoryLimiter) getBucket(ip string) *TokenBucket {
+	// Try to load existing bucket
+	if value, ok := rl.buckets.Load(ip); ok {
+		return value.(*TokenBucket)
+	}
+
+	// Create new bucket for this IP
+	bucket := newTokenBucket(rl.rate, rl.capacity, rl.clockFn)
+
+	// Store it (LoadOrStore handles race conditions)
+	actual, _ := rl.buckets.LoadOrStore(ip, bucket)
+	return actual.(*TokenBucket)
+}
+
+// maybeCleanup periodically removes inactive buckets to prevent memory leak
+// Every cleanupInterval, cleans up buckets that haven't been accessed for inactiveAfter
+func (rl *MemoryLimiter) maybeCleanup() {
+	rl.cleanupMu.Lock()
+	defer rl.cleanupMu.Unlock()
+
+	// Only cleanup every cleanupInterval
+	now := rl.clockFn()
+	if now.Sub(rl.lastCleanup) < rl.cleanupInterval {
+		return
+	}
+
+	// Cleanup threshold: remove buckets inactive for inactiveAfter or more
+	threshold := now.Add(-rl.inactiveAfter)
+
+	// Iterate over all buckets
+	rl.buckets.Range(func(key, value interface{}) bool {
+		bucket := value.(*TokenBucket)
+		bucket.mu.Lock()
+		lastAccess := bucket.lastRefillTime
+		bucket.mu.Unlock()
+
+		// Remove if inactive for too long
+		if lastAccess.Before(threshold) {
+			rl.buckets.Delete(key)
+		}
+
+		return true // continue iteration
+	})
+
+	rl.lastCleanup = now
+}
+
+// Reset removes the IP's token bucket; the next request creates a full one
+func (rl *MemoryLimiter) Reset(ip string) error {
+	rl.buckets.Delete(ip)
+	return nil
+}
+
+// List returns the state of every IP with a token bucket
+// Buckets are read one at a time under their own mutex, so requests are never blocked for the whole listing
+func (rl *MemoryLimiter) List() (map[string]RateLimitStatus, error) {
+	statuses := make(map[string]RateLimitStatus)
+	rl.buckets.Range(func(key, value interface{}) bool {
+		ip := key.(string)
+		statuses[ip] = value.(*TokenBucket).status(ip)
+		return true
+	})
+	return statuses, nil
+}
+
+// Limit returns the bucket capacity (burst size), at least 1
+// Implements the InspectableLimiter interface
+func (rl *MemoryLimiter) Limit() int {
+	return int(max(rl.capacity, 1.0))
+}
+
+// Remaining returns the whole tokens left in the IP's bucket
+// Implements the InspectableLimiter interface
+func (rl *MemoryLimiter) Remaining(ip string) int {
+	value, ok := rl.buckets.Load(ip)
+	if !ok {
+		return rl.Limit()
+	}
+	tokens, _ := value.(*TokenBucket).inspect()
+	return int(tokens)
+}
+
+// ResetAt returns when the IP's bucket will have refilled to capacity
+// Implements the InspectableLimiter interface
+func (rl *MemoryLimiter) ResetAt(ip string) time.Time {
+	value, ok := rl.buckets.Load(ip)
+	if !ok {
+		return rl.clockFn()
+	}
+	_, full := value.(*TokenBucket).inspect()
+	return full
+}
+
+// Close cleans up resources for the in-memory limiter
+// For in-memory implementation, there's nothing to clean up
+// This method exists to satisfy the Limiter interface
+func (rl *MemoryLimiter) Close() error {
+	// No resources to clean up for in-memory implementation
+	return nil
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newFakeClockLimiter returns a limiter with the default cleanup schedule, reading the time from a fake clock
+func newFakeClockLimiter() (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rl := NewMemoryLimiterWithCleanup(10, 10, 0, 0)
+	rl.clockFn = clock.Now
+	rl.lastCleanup = clock.Now()
+	return rl, clock
+}
+
+// countBuckets returns the number of buckets the limiter holds
+func countBuckets(rl *MemoryLimiter) int {
+	n := 0
+	rl.buckets.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// TestMemoryLimiter_Cleanup tests that buckets inactive for longer than the threshold are removed
+// by the next Allow once the cleanup interval has passed
+func TestMemoryLimiter_Cleanup(t *testing.T) {
+	rl, clock := newFakeClockLimiter()
+
+	for i := range 100 {
+		rl.Allow(fmt.Sprintf("10.0.0.%d", i))
+	}
+	if got := countBuckets(rl); got != 100 {
+		t.Fatalf("expected 100 buckets, got %d", got)
+	}
+
+	clock.Advance(10 * time.Minute)
+	rl.Allow("192.168.1.1") // Triggers the cleanup
+
+	if got := countBuckets(rl); got != 1 {
+		t.Errorf("expected only the new IP's bucket, got %d buckets", got)
+	}
+	if _, ok := rl.buckets.Load("192.168.1.1"); !ok {
+		t.Error("expected the new IP's bucket to be kept")
+	}
+}
+
+// TestMemoryLimiter_Inspect tests that Remaining counts whole tokens and ResetAt is when the bucket is full again
+func TestMemoryLimiter_Inspect(t *testing.T) {
+	rl, clock := newFakeClockLimiter()
+	start := clock.Now()
+
+	if got := rl.ResetAt("10.0.0.1"); !got.Equal(start) {
+		t.Errorf("expected an unseen IP to reset now, got %v", got)
+	}
+	for range 4 {
+		rl.Allow("10.0.0.1")
+	}
+	if got := rl.Remaining("10.0.0.1"); got != 6 {
+		t.Errorf("expected 6 requests remaining, got %d", got)
+	}
+	if got, expected := rl.ResetAt("10.0.0.1"), start.Add(400*time.Millisecond); !got.Equal(expected) {
+		t.Errorf("expected a reset at %v, got %v", expected, got)
+	}
+
+	clock.Advance(250 * time.Millisecond)
+	if got := rl.Remaining("10.0.0.1"); got != 8 {
+		t.Errorf("expected 8 requests remaining after refilling 2.5 tokens, got %d", got)
+	}
+	if got, expected := rl.ResetAt("10.0.0.1"), start.Add(400*time.Millisecond); !got.Equal(expected) {
+		t.Errorf("expected the reset to stay at %v, got %v", expected, got)
+	}
+}
+
+// TestMemoryLimiter_Cleanup_KeepsRecent tests that a bucket accessed less than the threshold ago is kept
+func TestMemoryLimiter_Cleanup_KeepsRecent(t *testing.T) {
+	rl, clock := newFakeClockLimiter()
+
+	rl.Allow("10.0.0.1")
+	rl.Allow("10.0.0.2")
+	clock.Advance(4 * time.Minute)
+	rl.Allow("10.0.0.2") // Accessed again, 2 minutes before the cleanup
+	clock.Advance(2 * time.Minute)
+	rl.Allow("192.168.1.1") // Triggers the cleanup
+
+	if _, ok := rl.buckets.Load("10.0.0.1"); ok {
+		t.Error("expected the bucket inactive for 6 minutes to be removed")
+	}
+	if _, ok := rl.buckets.Load("10.0.0.2"); !ok {
+		t.Error("expected the bucket accessed 2 minutes ago to be kept")
+	}
+}
+
+// TestMemoryLimiter_Cleanup_Interval tests that no cleanup runs before the interval has passed,
+// and that the interval and threshold are configurable
+func TestMemoryLimiter_Cleanup_Interval(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rl := NewMemoryLimiterWithCleanup(10, 10, time.Minute, 30*time.Second)
+	rl.clockFn = clock.Now
+	rl.lastCleanup = clock.Now()
+
+	rl.Allow("10.0.0.1")
+	clock.Advance(50 * time.Second)
+	rl.Allow("192.168.1.1") // Inactive long enough, but the interval hasn't passed
+	if _, ok := rl.buckets.Load("10.0.0.1"); !ok {
+		t.Fatal("expected no cleanup before the interval")
+	}
+
+	clock.Advance(15 * time.Second)
+	rl.Allow("192.168.1.2")
+	if _, ok := rl.buckets.Load("10.0.0.1"); ok {
+		t.Error("expected the bucket inactive for 65s to be removed")
+	}
+	if _, ok := rl.buckets.Load("192.168.1.1"); !ok {
+		t.Error("expected the bucket inactive for 15s to be kept")
+	}
+}
@@ -0,0 +1,350 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"go/build"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/pkg/ratelimit"
+)
+
+// modulePath is the import path prefix of this repository's packages
+const modulePath = "github.com/evyataryagoni/ip2country"
+
+// TestPackage_Standalone tests that the package imports nothing from internal/, directly or through another package of the module
+func TestPackage_Standalone(t *testing.T) {
+	visited := make(map[string]bool)
+	var walk func(path, dir string)
+	walk = func(path, dir string) {
+		if visited[path] {
+			return
+		}
+		visited[path] = true
+
+		pkg, err := build.Import(path, dir, 0)
+		if err != nil {
+			t.Fatalf("failed to load %s: %v", path, err)
+		}
+		for _, imported := range pkg.Imports {
+			if !strings.HasPrefix(imported, modulePath+"/") {
+				continue // Standard library or a third-party module
+			}
+			if strings.HasPrefix(imported, modulePath+"/internal/") {
+				t.Errorf("%s imports %s", path, imported)
+			}
+			walk(imported, pkg.Dir)
+		}
+	}
+	walk(modulePath+"/pkg/ratelimit", ".")
+}
+
+// newTestRedis starts a miniredis server, closed when the test ends
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.NewMiniRedis()
+	if err := mr.Start(); err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	return mr
+}
+
+// TestNew tests that every limiter type New creates enforces its rate per IP
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ratelimit.Config
+		allowed int // Requests allowed at once for one IP
+	}{
+		{"memory", ratelimit.Config{Type: "memory", RequestsPerSecond: 3}, 3},
+		{"memory with burst", ratelimit.Config{Type: "memory", RequestsPerSecond: 2, BurstSize: 5}, 5},
+		{"sliding", ratelimit.Config{Type: "sliding", RequestsPerSecond: 2, Window: 2 * time.Second}, 4},
+		{"leaky", ratelimit.Config{Type: "leaky", RequestsPerSecond: 2, BurstSize: 3}, 3},
+		{"leaky without a queue size", ratelimit.Config{Type: "leaky", RequestsPerSecond: 10}, 1},
+		{"redis", ratelimit.Config{Type: "redis", RequestsPerSecond: 3}, 3},
+		{"default type", ratelimit.Config{RequestsPerSecond: 3}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cfg.Type == "redis" {
+				tt.cfg.RedisAddr = newTestRedis(t).Addr()
+			}
+			l, err := ratelimit.New(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer l.Close()
+
+			for i := 0; i < tt.allowed; i++ {
+				if !l.Allow("192.168.1.1") {
+					t.Fatalf("request %d should be allowed", i+1)
+				}
+			}
+			if l.Allow("192.168.1.1") {
+				t.Errorf("request %d should be rate limited", tt.allowed+1)
+			}
+			if !l.Allow("192.168.1.2") {
+				t.Error("another IP should have its own allowance")
+			}
+
+			if err := l.Reset("192.168.1.1"); err != nil {
+				t.Fatalf("unexpected Reset error: %v", err)
+			}
+			if !l.Allow("192.168.1.1") {
+				t.Error("request after Reset should be allowed")
+			}
+		})
+	}
+}
+
+// TestRedisLimiter_Reset tests that Reset deletes the IP's counters of recent windows, and only the IP's
+func TestRedisLimiter_Reset(t *testing.T) {
+	mr := newTestRedis(t)
+	l, err := ratelimit.New(ratelimit.Config{Type: "redis", RequestsPerSecond: 5, RedisAddr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	window := time.Now().Unix()
+	for _, key := range []string{
+		fmt.Sprintf("ratelimit:192.168.1.1:%d", window),
+		fmt.Sprintf("ratelimit:192.168.1.1:%d", window-1),
+		fmt.Sprintf("ratelimit:192.168.1.10:%d", window),
+	} {
+		mr.Set(key, "5")
+	}
+
+	if err := l.Reset("192.168.1.1"); err != nil {
+		t.Fatalf("unexpected Reset error: %v", err)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != fmt.Sprintf("ratelimit:192.168.1.10:%d", window) {
+		t.Errorf("expected only the other IP's counter to be left, got %v", keys)
+	}
+}
+
+// TestNew_Errors tests that invalid configurations are rejected
+func TestNew_Errors(t *testing.T) {
+	tests := map[string]ratelimit.Config{
+		"unknown type":            {Type: "memcached", RequestsPerSecond: 10},
+		"burst smaller than rate": {Type: "memory", RequestsPerSecond: 10, BurstSize: 5},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if l, err := ratelimit.New(cfg); err == nil {
+				l.Close()
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestNew_Retry tests that an unreachable Redis server is an error after MaxRetries retries with growing, capped delays
+func TestNew_Retry(t *testing.T) {
+	var delays []time.Duration
+	cfg := ratelimit.Config{
+		Type:              "redis",
+		RequestsPerSecond: 10,
+		RedisAddr:         "127.0.0.1:1",
+		Retry: ratelimit.RetryConfig{
+			MaxRetries: 2,
+			BaseDelay:  time.Millisecond,
+			MaxDelay:   1500 * time.Microsecond,
+			OnRetry: func(attempt int, delay time.Duration, err error) {
+				delays = append(delays, delay)
+			},
+		},
+	}
+
+	if _, err := ratelimit.New(cfg); err == nil {
+		t.Fatal("expected an error")
+	}
+	if expected := []time.Duration{time.Millisecond, 1500 * time.Microsecond}; fmt.Sprint(delays) != fmt.Sprint(expected) {
+		t.Errorf("expected retries after %v, got %v", expected, delays)
+	}
+}
+
+// TestLimiters_MatchInternal tests that each limiter type answers exactly like the internal/limiter one built from the same settings
+func TestLimiters_MatchInternal(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  limiter.LimiterConfig
+	}{
+		{"memory", limiter.LimiterConfig{Type: "memory", RequestsPerSecond: 4}},
+		{"memory with burst", limiter.LimiterConfig{Type: "memory", RequestsPerSecond: 2, BurstSize: 6}},
+		{"sliding", limiter.LimiterConfig{Type: "sliding", RequestsPerSecond: 2, Window: 3 * time.Second}},
+		{"leaky", limiter.LimiterConfig{Type: "leaky", RequestsPerSecond: 2, BurstSize: 4}},
+		{"redis", limiter.LimiterConfig{Type: "redis", RequestsPerSecond: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ratelimit.Config{Type: tt.cfg.Type, RequestsPerSecond: tt.cfg.RequestsPerSecond, BurstSize: tt.cfg.BurstSize, Window: tt.cfg.Window}
+			if tt.cfg.Type == "redis" {
+				tt.cfg.RedisAddr = newTestRedis(t).Addr()
+				cfg.RedisAddr = newTestRedis(t).Addr()
+			}
+
+			internal, err := limiter.NewLimiter(tt.cfg)
+			if err != nil {
+				t.Fatalf("failed to create internal limiter: %v", err)
+			}
+			defer internal.Close()
+			standalone, err := ratelimit.New(cfg)
+			if err != nil {
+				t.Fatalf("failed to create limiter: %v", err)
+			}
+			defer standalone.Close()
+
+			if fmt.Sprintf("%T", internal) != fmt.Sprintf("%T", standalone) {
+				t.Errorf("expected the same type, got %T and %T", internal, standalone)
+			}
+			for i := 0; i < 20; i++ {
+				ip := fmt.Sprintf("10.0.0.%d", i%3)
+				if got, expected := standalone.Allow(ip), internal.Allow(ip); got != expected {
+					t.Fatalf("request %d from %s: expected %v like the internal limiter, got %v", i+1, ip, expected, got)
+				}
+			}
+		})
+	}
+}
+
+// TestList tests that every limiter type lists each IP that made a request, with fewer tokens after each request
+func TestList(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ratelimit.Config
+	}{
+		{"memory", ratelimit.Config{Type: "memory", RequestsPerSecond: 5}},
+		{"sliding", ratelimit.Config{Type: "sliding", RequestsPerSecond: 5}},
+		{"leaky", ratelimit.Config{Type: "leaky", RequestsPerSecond: 5, BurstSize: 5}},
+		{"redis", ratelimit.Config{Type: "redis", RequestsPerSecond: 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cfg.Type == "redis" {
+				tt.cfg.RedisAddr = newTestRedis(t).Addr()
+				// Start at the beginning of a one-second window so the counters aren't reset mid-test
+				time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			}
+			l, err := ratelimit.New(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer l.Close()
+
+			statuses, err := l.List()
+			if err != nil {
+				t.Fatalf("unexpected List error: %v", err)
+			}
+			if len(statuses) != 0 {
+				t.Fatalf("expected an empty list, got %v", statuses)
+			}
+
+			ips := []string{"192.168.1.1", "192.168.1.2", "2001:db8::1"}
+			for _, ip := range ips {
+				l.Allow(ip)
+			}
+			statuses, err = l.List()
+			if err != nil {
+				t.Fatalf("unexpected List error: %v", err)
+			}
+			if len(statuses) != len(ips) {
+				t.Errorf("expected %d IPs, got %v", len(ips), statuses)
+			}
+			for _, ip := range ips {
+				status, ok := statuses[ip]
+				if !ok {
+					t.Errorf("expected %s in the list, got %v", ip, statuses)
+					continue
+				}
+				if status.IP != ip || !status.Allowed || status.LastAccessedAt.IsZero() {
+					t.Errorf("unexpected status for %s: %+v", ip, status)
+				}
+			}
+
+			previous := statuses["192.168.1.1"].TokensRemaining
+			for i := 0; i < 4; i++ {
+				l.Allow("192.168.1.1")
+				statuses, err := l.List()
+				if err != nil {
+					t.Fatalf("unexpected List error: %v", err)
+				}
+				tokens := statuses["192.168.1.1"].TokensRemaining
+				if tokens >= previous {
+					t.Fatalf("request %d: expected fewer than %.2f tokens, got %.2f", i+2, previous, tokens)
+				}
+				previous = tokens
+			}
+			statuses, err = l.List()
+			if err != nil {
+				t.Fatalf("unexpected List error: %v", err)
+			}
+			if statuses["192.168.1.1"].Allowed {
+				t.Errorf("expected the next request to be denied after 5 requests, got %+v", statuses["192.168.1.1"])
+			}
+		})
+	}
+}
+
+// TestInspectableLimiter tests that every limiter type reports an IP's allowance going down with each request
+func TestInspectableLimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ratelimit.Config
+	}{
+		{"memory", ratelimit.Config{Type: "memory", RequestsPerSecond: 5}},
+		{"sliding", ratelimit.Config{Type: "sliding", RequestsPerSecond: 5}},
+		{"leaky", ratelimit.Config{Type: "leaky", RequestsPerSecond: 5, BurstSize: 5}},
+		{"redis", ratelimit.Config{Type: "redis", RequestsPerSecond: 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cfg.Type == "redis" {
+				tt.cfg.RedisAddr = newTestRedis(t).Addr()
+				// Start at the beginning of a one-second window so the counters aren't reset mid-test
+				time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			}
+			l, err := ratelimit.New(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer l.Close()
+
+			inspectable, ok := l.(ratelimit.InspectableLimiter)
+			if !ok {
+				t.Fatalf("expected %T to implement InspectableLimiter", l)
+			}
+			if got := inspectable.Limit(); got != 5 {
+				t.Fatalf("expected a limit of 5, got %d", got)
+			}
+			if got := inspectable.Remaining("192.168.1.1"); got != 5 {
+				t.Errorf("expected 5 requests remaining for an unseen IP, got %d", got)
+			}
+
+			for i := 1; i <= 5; i++ {
+				l.Allow("192.168.1.1")
+				if got := inspectable.Remaining("192.168.1.1"); got != 5-i {
+					t.Fatalf("request %d: expected %d requests remaining, got %d", i, 5-i, got)
+				}
+			}
+			if resetAt := inspectable.ResetAt("192.168.1.1"); !resetAt.After(time.Now()) || resetAt.After(time.Now().Add(time.Second)) {
+				t.Errorf("expected the allowance to reset within a second, got %v", resetAt)
+			}
+			if l.Allow("192.168.1.1") {
+				t.Error("expected the request after the limit to be denied")
+			}
+			if got := inspectable.Remaining("192.168.1.2"); got != 5 {
+				t.Errorf("expected another IP to keep its allowance, got %d", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLimiter implements distributed rate limiting using Redis
+// This is suitable for multi-server deployments where rate limits need to be
+// shared across all instances
+//
+// Algorithm: Token Bucket with Redis
+// - Uses Redis keys with TTL for automatic cleanup
+// - Uses INCR for atomic counter operations
+// - Key format: "ratelimit:{ip}:{window}"
+type RedisLimiter struct {
+	client         *redis.Client
+	ctx            context.Context
+	requestsPerSec float64
+	windowSize     time.Duration // Time window for rate limiting (e.g., 1 second)
+}
+
+// NewRedisLimiter creates a new Redis-based rate limiter
+//
+// Parameters:
+//   - addr: Redis server address (e.g., "localhost:6379")
+//   - password: Redis password (empty string if no password)
+//   - db: Redis database number (0-15, default is 0)
+//   - requestsPerSecond: allowed requests per second per IP (can be fractional, e.g., 0.2)
+//
+// Returns:
+//   - *RedisLimiter: new Redis rate limiter instance
+//   - error: any error that occurred during connection
+func NewRedisLimiter(addr, password string, db int, requestsPerSecond float64) (*RedisLimiter, error) {
+	return NewRedisLimiterWithRetry(addr, password, db, requestsPerSecond, RetryConfig{})
+}
+
+// NewRedisLimiterWithRetry creates a Redis rate limiter, retrying the initial connection per retry
+func NewRedisLimiterWithRetry(addr, password string, db int, requestsPerSecond float64, retry RetryConfig) (*RedisLimiter, error) {
+	// Create Redis client
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	// Test the connection
+	err := retry.connect(func() error {
+		return client.Ping(context.Background()).Err()
+	})
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis for rate limiting: %w", err)
+	}
+	return NewRedisLimiterFromClient(client, requestsPerSecond), nil
+}
+
+// NewRedisLimiterFromClient creates a Redis rate limiter on a client the caller has already connected
+// The limiter takes ownership of client: Close closes it
+func NewRedisLimiterFromClient(client *redis.Client, requestsPerSecond float64) *RedisLimiter {
+	// Calculate appropriate window size based on rate
+	// For fractional rates (e.g., 0.2 = 1 req per 5 sec), use longer window
+	// For integer rates (e.g., 10 = 10 req per sec), use 1 second window
+	windowSize := time.Second
+	if requestsPerSecond < 1.0 {
+		// For fractional rates, calculate window: 1 / rate
+		// Example: 0.2 req/s → 1/0.2 = 5 seconds
+		windowSize = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+
+	return &RedisLimiter{
+		client:         client,
+		ctx:            context.Background(),
+		requestsPerSec: requestsPerSecond,
+		windowSize:     windowSize,
+	}
+}
+
+// Allow checks if a request from the given IP should be allowed
+// Uses a Lua script, so all operations in Redis happen as a single atomic unit
+//
+// How it works:
+//  1. Generate a Redis key based on IP and current time window
+//  2. Execute a Lua script atomically that:
+//     - Increments the counter
+//     - Sets expiry if needed
+//     - Returns the current count
+//  3. Check if count exceeds the limit
+//
+// Parameters:
+//   - ip: client IP address
+//
+// Returns:
+//   - bool: true if request is allowed, false if rate limited
+func (rl *RedisLimiter) Allow(ip string) bool {
+	// Generate key based on current time window
+	// Format: ratelimit:192.168.1.1:1640000000
+	// Window changes based on configured window size (e.g., every 5 seconds for 0.2 req/s)
+	now := time.Now()
+	windowSeconds := int64(rl.windowSize.Seconds())
+	window := now.Unix() / windowSeconds // Rounds down to current window
+	key := fmt.Sprintf("ratelimit:%s:%d", ip, window)
+
+	// Lua script for atomic rate limiting
+	// This executes atomically on Redis server, no race conditions possible
+	luaScript := `
+		local key = KEYS[1]
+		local limit = tonumber(ARGV[1])
+		local ttl = tonumber(ARGV[2])
+
+		-- Increment the counter atomically
+		local current = redis.call('INCR', key)
+
+		-- Set expiry only if this is the first request (count = 1)
+		if current == 1 then
+			redis.call('EXPIRE', key, ttl)
+		end
+
+		-- Return the current count
+		return current
+	`
+
+	// Execute the Lua script
+	// KEYS[1] = key, ARGV[1] = limit, ARGV[2] = TTL in seconds
+	result, err := rl.client.Eval(rl.ctx, luaScript, []string{key}, rl.requestsPerSec, int(rl.windowSize.Seconds())*2).Result()
+	if err != nil {
+		// On Redis error, fail open (allow the request) to avoid blocking legitimate traffic
+		// In production, you might want to log this error and use a fallback mechanism
+		return true
+	}
+
+	// Get the count from Lua script result
+	count, ok := result.(int64)
+	if !ok {
+		// If type assertion fails, fail open
+		return true
+	}
+
+	// Check if we're within the rate limit
+	// For fractional rates, window is adjusted (e.g., 0.2 req/s uses 5-second window)
+	// So we allow ceiling of (rate * window) requests per window
+	// Example: 0.2 req/s * 5 sec = 1 req per 5-second window
+	limit := int64(math.Ceil(rl.requestsPerSec * rl.windowSize.Seconds()))
+	return count <= limit
+}
+
+// Limit returns the requests allowed per window
+// Implements the InspectableLimiter interface
+func (rl *RedisLimiter) Limit() int {
+	return int(math.Ceil(rl.requestsPerSec * rl.windowSize.Seconds()))
+}
+
+// Remaining returns the requests left to the IP in the current window, from its counter
+// Costs a GET; on a Redis error it reports the full limit, failing open like Allow
+// Implements the InspectableLimiter interface
+func (rl *RedisLimiter) Remaining(ip string) int {
+	windowSeconds := int64(rl.windowSize.Seconds())
+	key := fmt.Sprintf("ratelimit:%s:%d", ip, time.Now().Unix()/windowSeconds)
+
+	count, err := rl.client.Get(rl.ctx, key).Int()
+	if err != nil {
+		return rl.Limit() // No counter (redis.Nil) means no request in this window yet
+	}
+	return max(rl.Limit()-count, 0)
+}
+
+// ResetAt returns the end of the current window, when every IP's counter starts again
+// The same for every IP, so it needs no Redis call
+// Implements the InspectableLimiter interface
+func (rl *RedisLimiter) ResetAt(ip string) time.Time {
+	windowSeconds := int64(rl.windowSize.Seconds())
+	window := time.Now().Unix() / windowSeconds
+	return time.Unix((window+1)*windowSeconds, 0)
+}
+
+// Reset deletes the IP's ratelimit:{ip}:{window} counters, clearing them in every window
+// The keys are known, so they're deleted directly instead of scanning the keyspace: a counter expires
+// two windows after its first request, so only the current window and the two before it can have one,
+// plus the next window for servers whose clock runs ahead. Each key is a DEL of its own in one pipeline,
+// so the keys needn't share a cluster slot
+func (rl *RedisLimiter) Reset(ip string) error {
+	windowSeconds := int64(rl.windowSize.Seconds())
+	current := time.Now().Unix() / windowSeconds
+
+	pipe := rl.client.Pipeline()
+	for window := current - 2; window <= current+1; window++ {
+		pipe.Del(rl.ctx, fmt.Sprintf("ratelimit:%s:%d", ip, window))
+	}
+	if _, err := pipe.Exec(rl.ctx); err != nil {
+		return fmt.Errorf("failed to reset rate limit for %s: %w", ip, err)
+	}
+	return nil
+}
+
+// List returns the state of every IP with a counter, from a SCAN of the ratelimit:* keys
+// A fixed window keeps no access times: LastAccessedAt is the start of the latest window the IP made
+// a request in. An IP whose latest counter is for a past window has its full allowance again
+func (rl *RedisLimiter) List() (map[string]RateLimitStatus, error) {
+	windowSeconds := int64(rl.windowSize.Seconds())
+	currentWindow := time.Now().Unix() / windowSeconds
+	limit := math.Ceil(rl.requestsPerSec * rl.windowSize.Seconds())
+
+	statuses := make(map[string]RateLimitStatus)
+	latestWindow := make(map[string]int64)
+	iter := rl.client.Scan(rl.ctx, 0, "ratelimit:*", 100).Iterator()
+	for iter.Next(rl.ctx) {
+		key := iter.Val()
+
+		// Key format: ratelimit:{ip}:{window} - IPv6 addresses contain colons, so split at the last one
+		rest := strings.TrimPrefix(key, "ratelimit:")
+		sep := strings.LastIndex(rest, ":")
+		if sep < 0 {
+			continue
+		}
+		ip := rest[:sep]
+		window, err := strconv.ParseInt(rest[sep+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		if latest, seen := latestWindow[ip]; seen && latest >= window {
+			continue
+		}
+
+		tokens := limit
+		if window == currentWindow {
+			count, err := rl.client.Get(rl.ctx, key).Int64()
+			if err != nil {
+				if err == redis.Nil {
+					continue // Expired between SCAN and GET
+				}
+				return nil, fmt.Errorf("failed to read rate limit counter %s: %w", key, err)
+			}
+			tokens = max(limit-float64(count), 0)
+		}
+
+		latestWindow[ip] = window
+		statuses[ip] = RateLimitStatus{
+			IP:              ip,
+			TokensRemaining: tokens,
+			LastAccessedAt:  time.Unix(window*windowSeconds, 0),
+			Allowed:         tokens >= 1,
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan rate limit keys: %w", err)
+	}
+	return statuses, nil
+}
+
+// Close closes the Redis connection and cleans up resources
+func (rl *RedisLimiter) Close() error {
+	if rl.client != nil {
+		return rl.client.Close()
+	}
+	return nil
+}
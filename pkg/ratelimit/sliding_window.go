@@ -0,0 +1,210 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// slidingWindow holds the times of the requests a client made within the window, oldest first
+// It's a circular buffer of limit timestamps: a full buffer whose oldest request is still
+// inside the window means the client has used its allowance
+type slidingWindow struct {
+	times []time.Time // len = limit
+	start int         // Index of the oldest request
+	count int         // Requests within the window
+	mu    sync.Mutex  // Protects times, start and count
+}
+
+// newSlidingWindow creates an empty window holding up to limit requests
+func newSlidingWindow(limit int) *slidingWindow {
+	return &slidingWindow{times: make([]time.Time, limit)}
+}
+
+// allow records a request at now and returns true, unless limit requests were made in the window before now
+func (w *slidingWindow) allow(now time.Time, window time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire(now, window)
+	if w.count == len(w.times) {
+		return false
+	}
+	w.times[(w.start+w.count)%len(w.times)] = now
+	w.count++
+	return true
+}
+
+// expire drops the requests older than window at now
+// Must be called with mutex locked
+func (w *slidingWindow) expire(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	for w.count > 0 && !w.times[w.start].After(cutoff) {
+		w.start = (w.start + 1) % len(w.times)
+		w.count--
+	}
+}
+
+// lastRequest returns the time of the newest request in the window (zero if there's none)
+// Must be called with mutex locked
+func (w *slidingWindow) lastRequest() time.Time {
+	if w.count == 0 {
+		return time.Time{}
+	}
+	return w.times[(w.start+w.count-1)%len(w.times)]
+}
+
+// status returns the window's state for ip without recording a request
+func (w *slidingWindow) status(ip string, now time.Time, window time.Duration) RateLimitStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire(now, window)
+	remaining := len(w.times) - w.count
+	return RateLimitStatus{
+		IP:              ip,
+		TokensRemaining: float64(remaining),
+		LastAccessedAt:  w.lastRequest(),
+		Allowed:         remaining > 0,
+	}
+}
+
+// inspect returns the requests left in the window and when its newest request leaves it, at now
+func (w *slidingWindow) inspect(now time.Time, window time.Duration) (int, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire(now, window)
+	if w.count == 0 {
+		return len(w.times), now
+	}
+	return len(w.times) - w.count, w.lastRequest().Add(window)
+}
+
+// SlidingWindowLimiter allows each client at most limit requests in any window-long period
+// Unlike the token bucket of MemoryLimiter, there's no burst above the limit and no boundary
+// between fixed windows to burst across: every request is counted for exactly one window
+// Only allowed requests are counted, so a client that keeps retrying gets through as soon as its
+// oldest request leaves the window. Memory is one timestamp per allowed request per client,
+// so prefer MemoryLimiter for large limits
+type SlidingWindowLimiter struct {
+	windows     sync.Map // map[string]*slidingWindow - keyed by IP address
+	limit       int      // Requests allowed per window
+	window      time.Duration
+	cleanupMu   sync.Mutex
+	lastCleanup time.Time
+
+	clockFn func() time.Time // Current time (time.Now; replaced in tests)
+}
+
+// NewSlidingWindowLimiter creates an in-memory limiter allowing requestsPerWindow requests per IP in any window
+// A requestsPerWindow < 1 allows 1, and a window <= 0 is one second
+func NewSlidingWindowLimiter(requestsPerWindow int, window time.Duration) *SlidingWindowLimiter {
+	if window <= 0 {
+		window = time.Second
+	}
+	return &SlidingWindowLimiter{
+		limit:       max(requestsPerWindow, 1),
+		window:      window,
+		lastCleanup: time.Now(),
+		clockFn:     time.Now,
+	}
+}
+
+// Allow checks if a request from the given IP should be allowed, counting it if it is
+func (rl *SlidingWindowLimiter) Allow(ip string) bool {
+	allowed := rl.getWindow(ip).allow(rl.clockFn(), rl.window)
+
+	// Periodically clean up empty windows (prevent memory leak)
+	rl.maybeCleanup()
+
+	return allowed
+}
+
+// getWindow gets or creates the window of an IP address
+func (rl *SlidingWindowLimiter) getWindow(ip string) *slidingWindow {
+	if value, ok := rl.windows.Load(ip); ok {
+		return value.(*slidingWindow)
+	}
+	actual, _ := rl.windows.LoadOrStore(ip, newSlidingWindow(rl.limit))
+	return actual.(*slidingWindow)
+}
+
+// maybeCleanup removes the windows with no request left in them, every DefaultCleanupInterval
+// A removed window was empty, so unlike MemoryLimiter's cleanup this never gives an IP extra requests
+func (rl *SlidingWindowLimiter) maybeCleanup() {
+	rl.cleanupMu.Lock()
+	defer rl.cleanupMu.Unlock()
+
+	now := rl.clockFn()
+	if now.Sub(rl.lastCleanup) < DefaultCleanupInterval {
+		return
+	}
+
+	rl.windows.Range(func(key, value interface{}) bool {
+		w := value.(*slidingWindow)
+		w.mu.Lock()
+		w.expire(now, rl.window)
+		empty := w.count == 0
+		w.mu.Unlock()
+
+		if empty {
+			rl.windows.Delete(key)
+		}
+		return true
+	})
+
+	rl.lastCleanup = now
+}
+
+// Reset removes the IP's window; its next request starts with the full limit
+func (rl *SlidingWindowLimiter) Reset(ip string) error {
+	rl.windows.Delete(ip)
+	return nil
+}
+
+// List returns the state of every IP with a window
+// TokensRemaining is the number of requests the IP can make right now
+func (rl *SlidingWindowLimiter) List() (map[string]RateLimitStatus, error) {
+	now := rl.clockFn()
+	statuses := make(map[string]RateLimitStatus)
+	rl.windows.Range(func(key, value interface{}) bool {
+		ip := key.(string)
+		statuses[ip] = value.(*slidingWindow).status(ip, now, rl.window)
+		return true
+	})
+	return statuses, nil
+}
+
+// Limit returns the requests allowed per window
+// Implements the InspectableLimiter interface
+func (rl *SlidingWindowLimiter) Limit() int {
+	return rl.limit
+}
+
+// Remaining returns the requests the IP can make before its oldest one leaves the window
+// Implements the InspectableLimiter interface
+func (rl *SlidingWindowLimiter) Remaining(ip string) int {
+	value, ok := rl.windows.Load(ip)
+	if !ok {
+		return rl.limit
+	}
+	remaining, _ := value.(*slidingWindow).inspect(rl.clockFn(), rl.window)
+	return remaining
+}
+
+// ResetAt returns when every request the IP made will have left the window
+// Implements the InspectableLimiter interface
+func (rl *SlidingWindowLimiter) ResetAt(ip string) time.Time {
+	now := rl.clockFn()
+	value, ok := rl.windows.Load(ip)
+	if !ok {
+		return now
+	}
+	_, resetAt := value.(*slidingWindow).inspect(now, rl.window)
+	return resetAt
+}
+
+// Close does nothing: the in-memory limiter has no resources to release
+func (rl *SlidingWindowLimiter) Close() error {
+	return nil
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newFakeClockSlidingLimiter returns a sliding window limiter reading the time from a fake clock
+func newFakeClockSlidingLimiter(limit int, window time.Duration) (*SlidingWindowLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rl := NewSlidingWindowLimiter(limit, window)
+	rl.clockFn = clock.Now
+	rl.lastCleanup = clock.Now()
+	return rl, clock
+}
+
+// TestSlidingWindowLimiter_NoBurstAcrossBoundaries tests that limit requests at the end of one second and
+// limit more at the start of the next aren't all allowed, as they would be with fixed windows
+func TestSlidingWindowLimiter_NoBurstAcrossBoundaries(t *testing.T) {
+	rl, clock := newFakeClockSlidingLimiter(3, time.Second)
+	ip := "192.168.1.1"
+
+	clock.Advance(900 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if !rl.Allow(ip) {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+
+	// A fixed window would start over here
+	clock.Advance(200 * time.Millisecond)
+	if rl.Allow(ip) {
+		t.Error("expected no request until the first ones leave the window")
+	}
+
+	// Exactly one window after the first requests, they no longer count
+	clock.Advance(800 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if !rl.Allow(ip) {
+			t.Fatalf("request %d after the window should be allowed", i+1)
+		}
+	}
+	if rl.Allow(ip) {
+		t.Error("expected the limit to apply again")
+	}
+}
+
+// TestSlidingWindowLimiter_RejectedRequestsDontCount tests that a client retrying while limited
+// gets through as soon as its oldest allowed request leaves the window
+func TestSlidingWindowLimiter_RejectedRequestsDontCount(t *testing.T) {
+	rl, clock := newFakeClockSlidingLimiter(2, time.Second)
+	ip := "192.168.1.1"
+
+	rl.Allow(ip)
+	clock.Advance(500 * time.Millisecond)
+	rl.Allow(ip)
+	for i := 0; i < 10; i++ {
+		clock.Advance(40 * time.Millisecond)
+		if rl.Allow(ip) {
+			t.Fatalf("retry %d should be rate limited", i+1)
+		}
+	}
+
+	clock.Advance(100 * time.Millisecond) // 1s after the first request
+	if !rl.Allow(ip) {
+		t.Error("expected a request once the first one left the window")
+	}
+}
+
+// TestSlidingWindowLimiter_List tests that List reports the requests left in each IP's window
+func TestSlidingWindowLimiter_List(t *testing.T) {
+	rl, clock := newFakeClockSlidingLimiter(3, time.Second)
+	rl.Allow("192.168.1.1")
+	rl.Allow("192.168.1.1")
+	clock.Advance(100 * time.Millisecond)
+	rl.Allow("192.168.1.2")
+	rl.Allow("192.168.1.2")
+	rl.Allow("192.168.1.2")
+
+	statuses, _ := rl.List()
+	if status := statuses["192.168.1.1"]; status.TokensRemaining != 1 || !status.Allowed {
+		t.Errorf("expected 1 request left for 192.168.1.1, got %+v", status)
+	}
+	if status := statuses["192.168.1.2"]; status.TokensRemaining != 0 || status.Allowed || !status.LastAccessedAt.Equal(clock.Now()) {
+		t.Errorf("expected no request left for 192.168.1.2, last made now, got %+v", status)
+	}
+
+	clock.Advance(950 * time.Millisecond)
+	statuses, _ = rl.List()
+	if status := statuses["192.168.1.1"]; status.TokensRemaining != 3 {
+		t.Errorf("expected the full limit once the window passed, got %+v", status)
+	}
+}
+
+// TestSlidingWindowLimiter_Cleanup tests that windows with no request left are removed, and others kept
+func TestSlidingWindowLimiter_Cleanup(t *testing.T) {
+	rl, clock := newFakeClockSlidingLimiter(3, 2*time.Minute)
+	rl.Allow("192.168.1.1")
+	clock.Advance(4*time.Minute + 30*time.Second)
+	rl.Allow("192.168.1.2")
+
+	clock.Advance(90 * time.Second) // Past DefaultCleanupInterval; only the second IP's request is in the window
+	rl.Allow("192.168.1.3")
+
+	statuses, _ := rl.List()
+	if _, ok := statuses["192.168.1.1"]; ok {
+		t.Error("expected the empty window to be removed")
+	}
+	if len(statuses) != 2 {
+		t.Errorf("expected 2 windows left, got %v", statuses)
+	}
+}
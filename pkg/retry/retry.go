@@ -0,0 +1,90 @@
+// Package retry retries connections and operations with exponential backoff
+// It's shared by the datastores and the rate limiters, so neither depends on the other to retry
+package retry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evyataryagoni/ip2country/internal/logger"
+)
+
+// sleep waits between attempts (replaced in tests)
+var sleep = time.Sleep
+
+// Config controls how many times and how far apart a connection or operation is retried
+// Useful when the backend starts after the API server (common with docker-compose)
+// The zero value means a single attempt with no retries
+type Config struct {
+	MaxRetries int           // Retries after the first attempt (0 = no retries)
+	BaseDelay  time.Duration // Delay before the first retry, doubled on each subsequent retry
+	MaxDelay   time.Duration // Upper bound for the delay (0 = no bound)
+
+	Logger *logger.Logger // Logs each retry attempt (nil = silent)
+}
+
+// Delay returns the backoff before retry number attempt (1-based)
+// Example with BaseDelay=1s, MaxDelay=30s: 1s, 2s, 4s, 8s, 16s, 30s, 30s...
+func (cfg Config) Delay(attempt int) time.Duration {
+	d := cfg.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if cfg.MaxDelay > 0 && d >= cfg.MaxDelay {
+			return cfg.MaxDelay
+		}
+	}
+	if cfg.MaxDelay > 0 && d > cfg.MaxDelay {
+		return cfg.MaxDelay
+	}
+	return d
+}
+
+// Connect calls connectFn until it succeeds or cfg.MaxRetries retries have failed
+// Waits with exponential backoff between attempts
+// Returns nil on success, or the error from the last attempt
+func Connect(connectFn func() error, cfg Config) error {
+	err := connectFn()
+	for attempt := 1; err != nil && attempt <= cfg.MaxRetries; attempt++ {
+		delay := cfg.Delay(attempt)
+		if cfg.Logger != nil {
+			cfg.Logger.Warn().
+				Err(err).
+				Int("attempt", attempt).
+				Int("max_retries", cfg.MaxRetries).
+				Dur("delay", delay).
+				Msg("Connection failed, retrying")
+		}
+
+		sleep(delay)
+		err = connectFn()
+	}
+	return err
+}
+
+// Do calls fn until it succeeds, fails with an error retryable rejects,
+// or cfg.MaxRetries retries have failed, waiting with exponential backoff between attempts
+// When every attempt fails, the returned error wraps the last one and reports the attempt count
+func Do(op string, fn func() error, retryable func(error) bool, cfg Config) error {
+	err := fn()
+	attempts := 1
+	for ; err != nil && retryable(err) && attempts <= cfg.MaxRetries; attempts++ {
+		delay := cfg.Delay(attempts)
+		if cfg.Logger != nil {
+			cfg.Logger.Warn().
+				Err(err).
+				Str("operation", op).
+				Int("attempt", attempts).
+				Int("max_attempts", cfg.MaxRetries+1).
+				Dur("delay", delay).
+				Msg("Operation failed, retrying")
+		}
+
+		sleep(delay)
+		err = fn()
+	}
+
+	if err != nil && attempts > 1 && retryable(err) {
+		return fmt.Errorf("%s failed after %d attempts: %w", op, attempts, err)
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recordSleeps replaces sleep for the duration of the test and returns the recorded delays
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+
+	var delays []time.Duration
+	original := sleep
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	t.Cleanup(func() { sleep = original })
+
+	return &delays
+}
+
+// failingConnect returns a connect function that fails the first n calls
+func failingConnect(n int) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= n {
+			return fmt.Errorf("connection refused (attempt %d)", calls)
+		}
+		return nil
+	}, &calls
+}
+
+// TestConnect_SucceedsAfterFailures tests success on the last allowed attempt
+func TestConnect_SucceedsAfterFailures(t *testing.T) {
+	recordSleeps(t)
+	connect, calls := failingConnect(3)
+
+	err := Connect(connect, Config{MaxRetries: 3, BaseDelay: time.Second})
+
+	if err != nil {
+		t.Errorf("expected nil error on final successful attempt, got: %v", err)
+	}
+	if *calls != 4 {
+		t.Errorf("expected 4 attempts (1 + 3 retries), got %d", *calls)
+	}
+}
+
+// TestConnect_ExponentialDelay tests the delay doubles and respects MaxDelay
+func TestConnect_ExponentialDelay(t *testing.T) {
+	delays := recordSleeps(t)
+	connect, _ := failingConnect(10)
+
+	Connect(connect, Config{
+		MaxRetries: 5,
+		BaseDelay:  100 * time.Millisecond,
+		MaxDelay:   1 * time.Second,
+	})
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1 * time.Second, // capped
+	}
+	if len(*delays) != len(expected) {
+		t.Fatalf("expected %d sleeps, got %d", len(expected), len(*delays))
+	}
+	for i := range expected {
+		if (*delays)[i] != expected[i] {
+			t.Errorf("retry %d: expected delay %v, got %v", i+1, expected[i], (*delays)[i])
+		}
+	}
+}
+
+// TestConnect_MaxRetriesExceeded tests that the last error is returned
+func TestConnect_MaxRetriesExceeded(t *testing.T) {
+	recordSleeps(t)
+	connect, calls := failingConnect(10)
+
+	err := Connect(connect, Config{MaxRetries: 2, BaseDelay: time.Second})
+
+	if err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if err.Error() != "connection refused (attempt 3)" {
+		t.Errorf("expected last attempt's error, got: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", *calls)
+	}
+}
+
+// TestConnect_ZeroConfig tests that the zero value makes a single attempt
+func TestConnect_ZeroConfig(t *testing.T) {
+	delays := recordSleeps(t)
+	connect, calls := failingConnect(1)
+
+	err := Connect(connect, Config{})
+
+	if err == nil {
+		t.Error("expected error with no retries")
+	}
+	if *calls != 1 || len(*delays) != 0 {
+		t.Errorf("expected 1 attempt and no sleeps, got %d attempts and %d sleeps", *calls, len(*delays))
+	}
+}
+
+// TestDo_RetriesRetryableErrors tests that retryable errors are retried and reported with the attempt count
+func TestDo_RetriesRetryableErrors(t *testing.T) {
+	delays := recordSleeps(t)
+	fn, calls := failingConnect(10)
+	retryable := func(err error) bool { return true }
+
+	err := Do("GET ip:8.8.8.8", fn, retryable, Config{MaxRetries: 2, BaseDelay: 50 * time.Millisecond})
+
+	if err == nil || err.Error() != "GET ip:8.8.8.8 failed after 3 attempts: connection refused (attempt 3)" {
+		t.Errorf("expected the last error with the attempt count, got %v", err)
+	}
+	if *calls != 3 || len(*delays) != 2 {
+		t.Errorf("expected 3 attempts and 2 sleeps, got %d attempts and %v", *calls, *delays)
+	}
+}
+
+// TestDo_StopsOnPermanentError tests that an error retryable rejects is returned as is, after one attempt
+func TestDo_StopsOnPermanentError(t *testing.T) {
+	delays := recordSleeps(t)
+	fn, calls := failingConnect(10)
+	retryable := func(err error) bool { return false }
+
+	err := Do("GET ip:8.8.8.8", fn, retryable, Config{MaxRetries: 2, BaseDelay: time.Second})
+
+	if err == nil || err.Error() != "connection refused (attempt 1)" {
+		t.Errorf("expected the first error unwrapped, got %v", err)
+	}
+	if *calls != 1 || len(*delays) != 0 {
+		t.Errorf("expected 1 attempt and no sleeps, got %d attempts and %v", *calls, *delays)
+	}
+}
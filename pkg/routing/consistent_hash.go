@@ -0,0 +1,63 @@
+// Package routing maps IPs to service instances with consistent hashing, so a load balancer can
+// send every lookup of an IP to the same instance and each instance's cache holds a share of the IPs
+// instead of all of them
+package routing
+
+import (
+	"errors"
+	"hash/fnv"
+
+	"github.com/evyataryagoni/ip2country/pkg/validate"
+)
+
+// ErrNoNodes is returned by NodeFor when there are no nodes to choose from
+var ErrNoNodes = errors.New("no nodes to route to")
+
+// ConsistentHasher picks one of Nodes for each IP using rendezvous (highest random weight) hashing:
+// every node scores the IP and the highest score wins. Adding a node only moves the IPs it now wins
+// (about 1/N of them), and removing one only moves the IPs it had. The order of Nodes doesn't matter,
+// so every client given the same node names picks the same node
+type ConsistentHasher struct {
+	Nodes []string
+}
+
+// NodeFor returns the node ip should be routed to
+// ip is canonicalized first (::ffff:8.8.8.8 routes like 8.8.8.8), and returns
+// pkg/errors.ErrInvalidIP if it isn't an IP address, or ErrNoNodes if there are no nodes
+func (h ConsistentHasher) NodeFor(ip string) (string, error) {
+	canonical, err := validate.NormalizeAndValidate(ip)
+	if err != nil {
+		return "", err
+	}
+	if len(h.Nodes) == 0 {
+		return "", ErrNoNodes
+	}
+
+	var best string
+	var bestScore uint64
+	for i, node := range h.Nodes {
+		score := score(node, canonical)
+		// Ties (practically impossible) go to the smallest name, so they don't depend on the order either
+		if i == 0 || score > bestScore || (score == bestScore && node < best) {
+			best, bestScore = node, score
+		}
+	}
+	return best, nil
+}
+
+// score hashes node and ip together (FNV-1a, then the splitmix64 finalizer: FNV alone barely mixes
+// the last bytes, and IPs often differ only there)
+func score(node, ip string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(node))
+	h.Write([]byte{0}) // So "ab"+"c" and "a"+"bc" differ
+	h.Write([]byte(ip))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,119 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+// testIPs returns n distinct IPv4 addresses
+func testIPs(n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+	return ips
+}
+
+// nodeNames returns instance-1 to instance-n
+func nodeNames(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("instance-%d", i+1)
+	}
+	return nodes
+}
+
+// assign maps each IP to its node
+func assign(t *testing.T, h ConsistentHasher, ips []string) map[string]string {
+	t.Helper()
+	nodes := make(map[string]string, len(ips))
+	for _, ip := range ips {
+		node, err := h.NodeFor(ip)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ip, err)
+		}
+		nodes[ip] = node
+	}
+	return nodes
+}
+
+// TestConsistentHasher_Stable tests that an IP always maps to the same node, whatever the order of the nodes
+func TestConsistentHasher_Stable(t *testing.T) {
+	nodes := nodeNames(5)
+	reversed := []string{nodes[4], nodes[3], nodes[2], nodes[1], nodes[0]}
+	ips := testIPs(1000)
+
+	first := assign(t, ConsistentHasher{Nodes: nodes}, ips)
+	again := assign(t, ConsistentHasher{Nodes: nodes}, ips)
+	shuffled := assign(t, ConsistentHasher{Nodes: reversed}, ips)
+
+	for _, ip := range ips {
+		if again[ip] != first[ip] || shuffled[ip] != first[ip] {
+			t.Fatalf("%s: expected %s every time, got %s and %s", ip, first[ip], again[ip], shuffled[ip])
+		}
+	}
+
+	// Equivalent forms of an IP route alike
+	mapped, _ := ConsistentHasher{Nodes: nodes}.NodeFor("::ffff:10.0.0.7")
+	if mapped != first["10.0.0.7"] {
+		t.Errorf("expected ::ffff:10.0.0.7 on %s like 10.0.0.7, got %s", first["10.0.0.7"], mapped)
+	}
+}
+
+// TestConsistentHasher_Balanced tests that every node gets a fair share of the IPs
+func TestConsistentHasher_Balanced(t *testing.T) {
+	nodes := nodeNames(5)
+	counts := make(map[string]int)
+	for _, node := range assign(t, ConsistentHasher{Nodes: nodes}, testIPs(10000)) {
+		counts[node]++
+	}
+
+	for _, node := range nodes {
+		if counts[node] < 1600 || counts[node] > 2400 { // 2000 each, give or take 20%
+			t.Errorf("expected about 2000 IPs on %s, got %d", node, counts[node])
+		}
+	}
+}
+
+// TestConsistentHasher_MinimalRemapping tests that adding a node moves fewer than 1/N of the IPs,
+// all of them to the new node, and that removing a node only moves the IPs it had
+func TestConsistentHasher_MinimalRemapping(t *testing.T) {
+	const total = 10000
+	ips := testIPs(total)
+	nodes := nodeNames(5)
+	before := assign(t, ConsistentHasher{Nodes: nodes}, ips)
+
+	added := assign(t, ConsistentHasher{Nodes: append(nodeNames(5), "instance-6")}, ips)
+	moved := 0
+	for _, ip := range ips {
+		if added[ip] != before[ip] {
+			moved++
+			if added[ip] != "instance-6" {
+				t.Fatalf("%s: moved from %s to %s instead of the new node", ip, before[ip], added[ip])
+			}
+		}
+	}
+	if moved == 0 || moved >= total/len(nodes) {
+		t.Errorf("adding a node: expected 0 < moved < %d, got %d", total/len(nodes), moved)
+	}
+
+	removed := assign(t, ConsistentHasher{Nodes: []string{"instance-1", "instance-2", "instance-4", "instance-5"}}, ips)
+	for _, ip := range ips {
+		if removed[ip] != before[ip] && before[ip] != "instance-3" {
+			t.Fatalf("%s: moved from %s to %s though its node wasn't removed", ip, before[ip], removed[ip])
+		}
+	}
+}
+
+// TestConsistentHasher_Errors tests that an empty node list and invalid IPs are errors
+func TestConsistentHasher_Errors(t *testing.T) {
+	if _, err := (ConsistentHasher{}).NodeFor("8.8.8.8"); !errors.Is(err, ErrNoNodes) {
+		t.Errorf("expected ErrNoNodes, got %v", err)
+	}
+	if _, err := (ConsistentHasher{Nodes: nodeNames(3)}).NodeFor("not-an-ip"); !errors.Is(err, pkerr.ErrInvalidIP) {
+		t.Errorf("expected ErrInvalidIP, got %v", err)
+	}
+}
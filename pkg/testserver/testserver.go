@@ -0,0 +1,180 @@
+// Package testserver starts the full IP2Country HTTP server in process, for tests outside cmd/server
+// (the client, integration tests) that talk to it over HTTP. Unlike testutil.BuildTestServer it needs
+// no *testing.T, and it builds the store itself:
+//
+//	ts := testserver.New(testserver.WithCSVData("ip,city,country\n1.2.3.4,Paris,France\n"))
+//	defer ts.Close()
+//	location, err := ts.Client().FindCountry(ctx, "1.2.3.4")
+package testserver
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/handler"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/models"
+	"github.com/evyataryagoni/ip2country/internal/router"
+	"github.com/evyataryagoni/ip2country/internal/service"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/evyataryagoni/ip2country/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// DefaultRateLimit is the requests per second each client IP may make without WithRateLimit
+const DefaultRateLimit = 1000
+
+// options is what New builds the server from
+type options struct {
+	csvData   string
+	hasCSV    bool
+	redis     *miniredis.Miniredis
+	rateLimit float64
+	apiKey    string
+}
+
+// Option changes one of New's defaults
+type Option func(*options)
+
+// WithCSVData serves the records of csv (ip,city,country with a header row, or a range mode file)
+// instead of the mock store's (8.8.8.8 in Mountain View, 1.1.1.1 in Sydney...)
+func WithCSVData(csv string) Option {
+	return func(o *options) {
+		o.csvData = csv
+		o.hasCSV = true
+	}
+}
+
+// WithRedis serves the records from mr through a RedisStore, as DATASTORE_TYPE=redis would
+// With WithCSVData too, the records are written to mr first (range mode files aren't supported)
+// The caller keeps ownership of mr: it isn't closed with the server
+func WithRedis(mr *miniredis.Miniredis) Option {
+	return func(o *options) {
+		o.redis = mr
+	}
+}
+
+// WithRateLimit sets the requests per second each client IP may make (default DefaultRateLimit)
+func WithRateLimit(rps float64) Option {
+	return func(o *options) {
+		o.rateLimit = rps
+	}
+}
+
+// WithAPIKey sets the admin API key, required in the X-API-Key header for /admin and /debug
+// (like ADMIN_API_KEY). The lookup endpoints stay open, as in the real server
+func WithAPIKey(key string) Option {
+	return func(o *options) {
+		o.apiKey = key
+	}
+}
+
+// TestServer is a running in-process server
+type TestServer struct {
+	*httptest.Server
+
+	closers   []func() error // Closed in reverse order by Close, after the HTTP server
+	closeOnce sync.Once
+}
+
+// New starts a server with the full router chain (logging, rate limiting, metrics, admin routes)
+// Metrics go to a private registry and logs are discarded. Like httptest.NewServer, it panics if
+// the server can't be built, e.g. for invalid CSV data or an unreachable Redis
+func New(opts ...Option) *TestServer {
+	o := &options{rateLimit: DefaultRateLimit}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ts := &TestServer{}
+	dataStore, err := newStore(o)
+	if err != nil {
+		panic(fmt.Sprintf("testserver: %v", err))
+	}
+	ts.closers = append(ts.closers, dataStore.Close)
+
+	appConfig := &config.Config{
+		Port:            "0",
+		LogLevel:        "info",
+		NodeID:          "testserver",
+		RateLimitType:   "memory",
+		UniqueIPsWindow: "daily",
+		AdminAPIKey:     o.apiKey,
+	}
+	discard := zerolog.New(io.Discard)
+	log := &logger.Logger{Logger: &discard}
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+
+	rateLimiter := limiter.NewMemoryLimiter(o.rateLimit)
+	ts.closers = append(ts.closers, rateLimiter.Close)
+
+	ipService := service.NewIPService(dataStore, m, log)
+	ts.closers = append(ts.closers, ipService.Close)
+
+	r := router.SetupRouter(appConfig,
+		handler.NewIPHandler(ipService),
+		handler.NewAdminHandler(config.NewReloadableConfig(appConfig)),
+		rateLimiter, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		m, log)
+
+	ts.Server = httptest.NewServer(r)
+	return ts
+}
+
+// newStore builds the store the options select: the CSV data, Redis, or the mock store
+func newStore(o *options) (store.Store, error) {
+	var csvStore *store.CSVStore
+	if o.hasCSV {
+		var err error
+		if csvStore, err = store.NewCSVStoreFromReader(strings.NewReader(o.csvData)); err != nil {
+			return nil, fmt.Errorf("invalid CSV data: %w", err)
+		}
+	}
+
+	switch {
+	case o.redis != nil:
+		redisStore, err := store.NewRedisStore(o.redis.Addr(), "", 0)
+		if err != nil {
+			return nil, err
+		}
+		if csvStore != nil {
+			defer csvStore.Close()
+			err = csvStore.Iterate(func(location *models.IPLocation) error {
+				return redisStore.Set(location.IP, location.City, location.Country)
+			})
+			if err != nil {
+				redisStore.Close()
+				return nil, fmt.Errorf("failed to write CSV data to Redis: %w", err)
+			}
+		}
+		return redisStore, nil
+	case csvStore != nil:
+		return csvStore, nil
+	default:
+		return store.NewMockStore(), nil
+	}
+}
+
+// Client returns a client for the server, using its HTTP client
+func (ts *TestServer) Client() *client.Client {
+	return client.New(ts.URL, ts.Server.Client())
+}
+
+// Close shuts the server down and closes everything it built, newest first
+// Safe to call more than once
+func (ts *TestServer) Close() {
+	ts.closeOnce.Do(func() {
+		ts.Server.Close()
+		for i := len(ts.closers) - 1; i >= 0; i-- {
+			ts.closers[i]()
+		}
+	})
+}
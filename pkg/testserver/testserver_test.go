@@ -0,0 +1,134 @@
+package testserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+)
+
+const testCSV = "ip,city,country\n1.2.3.4,Paris,France\n"
+
+// TestNew_Defaults tests that a server without options answers from the mock store
+func TestNew_Defaults(t *testing.T) {
+	ts := New()
+	defer ts.Close()
+
+	location, err := ts.Client().FindCountry(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Mountain View" || location.Country != "United States" {
+		t.Errorf("expected Mountain View, United States, got %+v", location)
+	}
+}
+
+// TestWithCSVData tests that the CSV records replace the mock store's
+func TestWithCSVData(t *testing.T) {
+	ts := New(WithCSVData(testCSV))
+	defer ts.Close()
+
+	location, err := ts.Client().FindCountry(context.Background(), "1.2.3.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Paris" || location.Country != "France" {
+		t.Errorf("expected Paris, France, got %+v", location)
+	}
+	if _, err := ts.Client().FindCountry(context.Background(), "8.8.8.8"); !errors.Is(err, pkerr.ErrNotFound) {
+		t.Errorf("expected not found for a mock store IP, got %v", err)
+	}
+}
+
+// TestWithRedis tests that CSV records are written to Redis and served from it
+func TestWithRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ts := New(WithRedis(mr), WithCSVData(testCSV))
+	defer ts.Close()
+
+	location, err := ts.Client().FindCountry(context.Background(), "1.2.3.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.City != "Paris" {
+		t.Errorf("expected Paris, got %+v", location)
+	}
+	if len(mr.Keys()) == 0 {
+		t.Error("expected the records in Redis")
+	}
+}
+
+// TestWithRateLimit tests that requests over the limit are rejected with 429
+func TestWithRateLimit(t *testing.T) {
+	ts := New(WithRateLimit(1))
+	defer ts.Close()
+
+	statuses := map[int]int{}
+	for range 5 {
+		resp, err := ts.Server.Client().Get(ts.URL + "/v1/find-country?ip=8.8.8.8")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		statuses[resp.StatusCode]++
+	}
+	if statuses[http.StatusTooManyRequests] == 0 {
+		t.Errorf("expected some requests rate limited, got %v", statuses)
+	}
+}
+
+// TestWithAPIKey tests that admin requests without the key are rejected and lookups stay open
+func TestWithAPIKey(t *testing.T) {
+	ts := New(WithAPIKey("secret"))
+	defer ts.Close()
+
+	get := func(path, key string) int {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp, err := ts.Server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := get("/admin/config", ""); got != http.StatusUnauthorized {
+		t.Errorf("without the key: expected 401, got %d", got)
+	}
+	if got := get("/admin/config", "wrong"); got != http.StatusUnauthorized {
+		t.Errorf("with a wrong key: expected 401, got %d", got)
+	}
+	if got := get("/admin/config", "secret"); got != http.StatusOK {
+		t.Errorf("with the key: expected 200, got %d", got)
+	}
+	if got := get("/v1/find-country?ip=8.8.8.8", ""); got != http.StatusOK {
+		t.Errorf("lookup without the key: expected 200, got %d", got)
+	}
+}
+
+// TestNew_InvalidCSV tests that New panics on CSV data it can't load
+func TestNew_InvalidCSV(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New(WithCSVData("not,a\nvalid"))
+}
+
+// TestClose_Idempotent tests that closing twice doesn't panic and the server stops answering
+func TestClose_Idempotent(t *testing.T) {
+	ts := New()
+	ts.Close()
+	ts.Close()
+
+	if _, err := ts.Client().FindCountry(context.Background(), "8.8.8.8"); err == nil {
+		t.Error("expected an error from a closed server")
+	}
+}
@@ -0,0 +1,64 @@
+// Package testutil holds assertions and fixtures for testing code that serves or calls the IP2Country API,
+// so tests don't repeat the decode-the-body, check-the-status boilerplate
+package testutil
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// AssertJSON fails the test unless rec holds a JSON object with an application/json Content-Type
+// Returns the decoded object
+func AssertJSON(t testing.TB, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if mediaType != "application/json" {
+		t.Fatalf("expected Content-Type application/json, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a JSON object, got %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+// AssertIPLocation fails the test unless rec is a find-country response with the given status, city and country
+func AssertIPLocation(t testing.TB, rec *httptest.ResponseRecorder, status int, city, country string) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	body := AssertJSON(t, rec)
+	if body["city"] != city || body["country"] != country {
+		t.Errorf("expected %s, %s, got %v, %v", city, country, body["city"], body["country"])
+	}
+}
+
+// AssertErrorResponse fails the test unless rec is an error response with the given status and error code
+// Handler errors carry no code, only a message: pass "" for those
+func AssertErrorResponse(t testing.TB, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	body := AssertJSON(t, rec)
+	if message, _ := body["error"].(string); message == "" {
+		t.Errorf("expected an error message, got %s", rec.Body.String())
+	}
+	if got, _ := body["code"].(string); got != code {
+		t.Errorf("expected error code %q, got %q", code, got)
+	}
+}
+
+// NewTestRequest returns a request for url with no body, ready to pass to a handler's ServeHTTP
+// url may be a path ("/v1/find-country?ip=8.8.8.8") or absolute; an invalid url panics
+func NewTestRequest(method, url string) *http.Request {
+	return httptest.NewRequest(method, url, nil)
+}
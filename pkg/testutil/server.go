@@ -0,0 +1,120 @@
+package testutil
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/handler"
+	"github.com/evyataryagoni/ip2country/internal/health"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/logger"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/router"
+	"github.com/evyataryagoni/ip2country/internal/service"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// TestAdminAPIKey is the admin API key of servers built by BuildTestServer
+const TestAdminAPIKey = "test-admin-key"
+
+// testServerOptions is what BuildTestServer wires the router with
+type testServerOptions struct {
+	config      *config.Config
+	rateLimiter limiter.Limiter
+	metrics     *metrics.Metrics
+	logger      *logger.Logger
+	checks      *health.CheckRegistry
+}
+
+// TestServerOption changes one of BuildTestServer's defaults
+type TestServerOption func(*testServerOptions)
+
+// WithConfig modifies the default config before the server is built
+func WithConfig(modify func(c *config.Config)) TestServerOption {
+	return func(o *testServerOptions) {
+		modify(o.config)
+	}
+}
+
+// WithRateLimiter replaces the memory limiter built from the config, e.g. with limiter.NewMockLimiter
+// The caller keeps ownership: it isn't closed with the server
+func WithRateLimiter(l limiter.Limiter) TestServerOption {
+	return func(o *testServerOptions) {
+		o.rateLimiter = l
+	}
+}
+
+// WithMetrics replaces the unregistered metrics collector, to check what the server recorded
+func WithMetrics(m *metrics.Metrics) TestServerOption {
+	return func(o *testServerOptions) {
+		o.metrics = m
+	}
+}
+
+// WithLogger replaces the logger that discards everything
+func WithLogger(log *logger.Logger) TestServerOption {
+	return func(o *testServerOptions) {
+		o.logger = log
+	}
+}
+
+// WithHealthCheck adds check to the checks GET /health runs, under name
+func WithHealthCheck(name string, check health.CheckFunc) TestServerOption {
+	return func(o *testServerOptions) {
+		o.checks.Register(name, check)
+	}
+}
+
+// BuildTestServer serves s through the full router chain (logging, rate limiting, metrics, admin routes)
+// Defaults suit tests: a memory limiter at 1000 req/s, metrics on a private registry, no log output,
+// and TestAdminAPIKey for /admin. The server and everything it built are closed when the test ends
+func BuildTestServer(t testing.TB, s store.Store, opts ...TestServerOption) *httptest.Server {
+	t.Helper()
+
+	discard := zerolog.New(io.Discard)
+	o := &testServerOptions{
+		config: &config.Config{
+			Port:            "0",
+			LogLevel:        "info",
+			NodeID:          "test",
+			RateLimitType:   "memory",
+			RateLimit:       1000,
+			RateLimitWindow: 1,
+			UniqueIPsWindow: "daily",
+			AdminAPIKey:     TestAdminAPIKey,
+		},
+		logger: &logger.Logger{Logger: &discard},
+		checks: health.NewCheckRegistry(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.metrics == nil {
+		o.metrics = metrics.NewWithRegistry(prometheus.NewRegistry())
+	}
+	if o.rateLimiter == nil {
+		rateLimiter := limiter.NewMemoryLimiter(float64(o.config.RateLimit) / float64(o.config.RateLimitWindow))
+		t.Cleanup(func() { rateLimiter.Close() })
+		o.rateLimiter = rateLimiter
+	}
+
+	ipService := service.NewIPService(s, o.metrics, o.logger)
+	t.Cleanup(func() { ipService.Close() })
+
+	ipHandler := handler.NewIPHandler(ipService)
+	ipHandler.SetHealthRegistry(o.checks)
+	r := router.SetupRouter(o.config,
+		ipHandler,
+		handler.NewAdminHandler(config.NewReloadableConfig(o.config)),
+		o.rateLimiter, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		o.metrics, o.logger)
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,223 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/middleware"
+	"github.com/evyataryagoni/ip2country/internal/store"
+)
+
+// recordingTB records failures instead of failing the test it wraps
+// Fatalf stops the helper with a panic that check recovers, like FailNow stops a real test
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+// errFatal is the panic value Fatalf stops the helper with
+var errFatal = errors.New("fatal")
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	panic(errFatal)
+}
+
+// check runs assertion against a recordingTB and returns the failures it reported
+func check(t *testing.T, assertion func(tb testing.TB)) []string {
+	t.Helper()
+
+	tb := &recordingTB{TB: t}
+	func() {
+		defer func() {
+			if r := recover(); r != nil && r != errFatal {
+				panic(r)
+			}
+		}()
+		assertion(tb)
+	}()
+	return tb.failures
+}
+
+// newRecorder returns a recorder holding a response with the given status and body
+func newRecorder(status int, contentType, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	if contentType != "" {
+		rec.Header().Set("Content-Type", contentType)
+	}
+	rec.WriteHeader(status)
+	rec.WriteString(body)
+	return rec
+}
+
+// TestAssertJSON tests that JSON objects are decoded and anything else fails the test
+func TestAssertJSON(t *testing.T) {
+	var body map[string]any
+	failures := check(t, func(tb testing.TB) {
+		body = AssertJSON(tb, newRecorder(http.StatusOK, "application/json; charset=utf-8", `{"city":"Sydney"}`))
+	})
+	if len(failures) != 0 {
+		t.Errorf("expected no failures, got %v", failures)
+	}
+	if body["city"] != "Sydney" {
+		t.Errorf("expected the decoded body, got %v", body)
+	}
+
+	bad := map[string]*httptest.ResponseRecorder{
+		"wrong content type": newRecorder(http.StatusOK, "text/plain", `{"city":"Sydney"}`),
+		"invalid JSON":       newRecorder(http.StatusOK, "application/json", `{"city":`),
+		"not an object":      newRecorder(http.StatusOK, "application/json", `["Sydney"]`),
+	}
+	for name, rec := range bad {
+		t.Run(name, func(t *testing.T) {
+			if failures := check(t, func(tb testing.TB) { AssertJSON(tb, rec) }); len(failures) != 1 {
+				t.Errorf("expected 1 failure, got %v", failures)
+			}
+		})
+	}
+}
+
+// TestAssertIPLocation tests that the status, city and country are all checked
+func TestAssertIPLocation(t *testing.T) {
+	rec := newRecorder(http.StatusOK, "application/json", `{"city":"Mountain View","country":"United States"}`)
+
+	if failures := check(t, func(tb testing.TB) {
+		AssertIPLocation(tb, rec, http.StatusOK, "Mountain View", "United States")
+	}); len(failures) != 0 {
+		t.Errorf("expected no failures, got %v", failures)
+	}
+	if failures := check(t, func(tb testing.TB) {
+		AssertIPLocation(tb, rec, http.StatusOK, "Sydney", "United States")
+	}); len(failures) != 1 {
+		t.Errorf("expected 1 failure for the wrong city, got %v", failures)
+	}
+	if failures := check(t, func(tb testing.TB) {
+		AssertIPLocation(tb, rec, http.StatusNotFound, "Mountain View", "United States")
+	}); len(failures) != 1 {
+		t.Errorf("expected 1 failure for the wrong status, got %v", failures)
+	}
+}
+
+// TestAssertErrorResponse tests that the status, message and code are all checked
+func TestAssertErrorResponse(t *testing.T) {
+	blocked := newRecorder(http.StatusForbidden, "application/json", `{"code":"IP_BLOCKED","error":"access denied"}`)
+	notFound := newRecorder(http.StatusNotFound, "application/json", `{"error":"IP address not found"}`)
+
+	tests := []struct {
+		name     string
+		rec      *httptest.ResponseRecorder
+		status   int
+		code     string
+		failures int
+	}{
+		{"matching code", blocked, http.StatusForbidden, "IP_BLOCKED", 0},
+		{"no code", notFound, http.StatusNotFound, "", 0},
+		{"wrong code", blocked, http.StatusForbidden, "SERVER_BUSY", 1},
+		{"missing code", notFound, http.StatusNotFound, "NOT_FOUND", 1},
+		{"wrong status", blocked, http.StatusTooManyRequests, "IP_BLOCKED", 1},
+		{"no message", newRecorder(http.StatusForbidden, "application/json", `{"code":"IP_BLOCKED"}`), http.StatusForbidden, "IP_BLOCKED", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := check(t, func(tb testing.TB) { AssertErrorResponse(tb, tt.rec, tt.status, tt.code) })
+			if len(failures) != tt.failures {
+				t.Errorf("expected %d failures, got %v", tt.failures, failures)
+			}
+		})
+	}
+}
+
+// TestNewTestRequest tests that paths and absolute URLs are accepted, and invalid ones panic
+func TestNewTestRequest(t *testing.T) {
+	req := NewTestRequest(http.MethodGet, "/v1/find-country?ip=8.8.8.8")
+	if req.Method != http.MethodGet || req.URL.Query().Get("ip") != "8.8.8.8" {
+		t.Errorf("unexpected request %s %s", req.Method, req.URL)
+	}
+
+	req = NewTestRequest(http.MethodPost, "https://geo.example.com/v1/find-country")
+	if req.Host != "geo.example.com" || req.TLS == nil {
+		t.Errorf("expected an https request to geo.example.com, got %s", req.URL)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an invalid URL")
+		}
+	}()
+	NewTestRequest(http.MethodGet, "http://[::1")
+}
+
+// get sends GET path to server and records the response
+func get(t *testing.T, server *httptest.Server, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	for key, values := range resp.Header {
+		rec.Header()[key] = values
+	}
+	rec.WriteHeader(resp.StatusCode)
+	rec.Body.ReadFrom(resp.Body)
+	return rec
+}
+
+// TestBuildTestServer tests lookups through the full router chain
+func TestBuildTestServer(t *testing.T) {
+	server := BuildTestServer(t, store.NewMockStore())
+
+	AssertIPLocation(t, get(t, server, "/v1/find-country?ip=8.8.8.8"), http.StatusOK, "Mountain View", "United States")
+	AssertErrorResponse(t, get(t, server, "/v1/find-country?ip=9.9.9.9"), http.StatusNotFound, "")
+	AssertErrorResponse(t, get(t, server, "/v1/find-country?ip=not-an-ip"), http.StatusBadRequest, "")
+
+	if rec := get(t, server, "/admin/config"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected /admin status 401 without TestAdminAPIKey, got %d", rec.Code)
+	}
+}
+
+// TestBuildTestServer_Options tests that options replace the defaults
+func TestBuildTestServer_Options(t *testing.T) {
+	server := BuildTestServer(t, store.NewMockStore(),
+		WithRateLimiter(limiter.NewMockLimiter(false)),
+		WithConfig(func(c *config.Config) { c.NodeID = "edge-1" }),
+	)
+
+	rec := get(t, server, "/v1/find-country?ip=8.8.8.8")
+	AssertErrorResponse(t, rec, http.StatusTooManyRequests, "")
+	if node := rec.Header().Get(middleware.NodeIDHeader); node != "edge-1" {
+		t.Errorf("expected node edge-1, got %q", node)
+	}
+}
+
+// TestBuildTestServer_HealthCheck tests that WithHealthCheck checks are reported by /health
+func TestBuildTestServer_HealthCheck(t *testing.T) {
+	server := BuildTestServer(t, store.NewMockStore(),
+		WithHealthCheck("mock", func(ctx context.Context) error { return errors.New("connection refused") }),
+	)
+
+	rec := get(t, server, "/health")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 with the mock check failing, got %d", rec.Code)
+	}
+	checks, _ := AssertJSON(t, rec)["checks"].(map[string]any)
+	if _, ok := checks["mock"]; !ok {
+		t.Errorf("expected the mock check in the response, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,70 @@
+// Package validate exposes the IP address validation used by the lookup service,
+// so tools importing or checking data accept exactly the addresses the API does
+package validate
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+// Errors returned by the Validate functions
+// ErrInvalidIP is pkg/errors.ErrInvalidIP, so errors.Is matches either
+var (
+	ErrInvalidIP = pkerr.ErrInvalidIP
+	ErrNotIPv4   = errors.New("not an IPv4 address")
+	ErrNotIPv6   = errors.New("not an IPv6 address")
+)
+
+// validate is shared by every call; validator.Validate is safe for concurrent use
+var validate = validator.New()
+
+// ValidateIP checks that ip is an IPv4 or IPv6 address, returning ErrInvalidIP if not
+// The address must be exact: no surrounding whitespace, port or zone (see NormalizeAndValidate)
+func ValidateIP(ip string) error {
+	if err := validate.Var(ip, "required,ip"); err != nil {
+		return ErrInvalidIP
+	}
+	return nil
+}
+
+// IsValidIP reports whether ip passes ValidateIP
+func IsValidIP(ip string) bool {
+	return ValidateIP(ip) == nil
+}
+
+// ValidateIPv4 checks that ip is an IPv4 address in dotted decimal notation (8.8.8.8)
+// IPv4-mapped IPv6 addresses (::ffff:8.8.8.8) are written as IPv6, so they return ErrNotIPv4
+func ValidateIPv4(ip string) error {
+	if err := ValidateIP(ip); err != nil {
+		return err
+	}
+	if strings.Contains(ip, ":") {
+		return ErrNotIPv4
+	}
+	return nil
+}
+
+// ValidateIPv6 checks that ip is an IPv6 address, including IPv4-mapped ones (::ffff:8.8.8.8)
+func ValidateIPv6(ip string) error {
+	if err := ValidateIP(ip); err != nil {
+		return err
+	}
+	if !strings.Contains(ip, ":") {
+		return ErrNotIPv6
+	}
+	return nil
+}
+
+// NormalizeAndValidate trims surrounding whitespace, validates ip and returns its canonical form:
+// lowercase, zero-compressed IPv6 (2001:DB8:0::1 = 2001:db8::1), and IPv4-mapped addresses as plain IPv4
+func NormalizeAndValidate(ip string) (canonical string, err error) {
+	ip = strings.TrimSpace(ip)
+	if err := ValidateIP(ip); err != nil {
+		return "", err
+	}
+	return net.ParseIP(ip).String(), nil
+}
@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"testing"
+)
+
+// invalidIPs are rejected by every function (the cases of TestIPService_LookupIP_InvalidIP, plus a few more)
+var invalidIPs = []struct {
+	name string
+	ip   string
+}{
+	{"empty string", ""},
+	{"invalid format", "not-an-ip"},
+	{"incomplete IPv4", "192.168.1"},
+	{"invalid characters", "192.168.1.abc"},
+	{"too many octets", "192.168.1.1.1"},
+	{"negative numbers", "192.-168.1.1"},
+	{"out of range", "300.300.300.300"},
+	{"just dots", "..."},
+	{"missing octets", "192.168..1"},
+	{"octet just over 255", "256.0.0.0"},
+	{"leading zeros", "010.0.0.1"},
+	{"with port", "8.8.8.8:53"},
+	{"CIDR", "10.0.0.0/8"},
+	{"IPv6 with zone", "fe80::1%eth0"},
+	{"IPv6 too many groups", "1:2:3:4:5:6:7:8:9"},
+	{"IPv6 double compression", "2001::db8::1"},
+	{"IPv6 bad hex", "2001:db8::g"},
+	{"surrounding whitespace", " 8.8.8.8 "},
+}
+
+// TestValidateIP_Invalid tests that every invalid input returns ErrInvalidIP from every function
+func TestValidateIP_Invalid(t *testing.T) {
+	for _, tt := range invalidIPs {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateIP(tt.ip); err != ErrInvalidIP {
+				t.Errorf("ValidateIP: expected ErrInvalidIP, got %v", err)
+			}
+			if IsValidIP(tt.ip) {
+				t.Error("IsValidIP: expected false")
+			}
+			if err := ValidateIPv4(tt.ip); err != ErrInvalidIP {
+				t.Errorf("ValidateIPv4: expected ErrInvalidIP, got %v", err)
+			}
+			if err := ValidateIPv6(tt.ip); err != ErrInvalidIP {
+				t.Errorf("ValidateIPv6: expected ErrInvalidIP, got %v", err)
+			}
+		})
+	}
+
+	// The message is the one the API returns
+	if ErrInvalidIP.Error() != "invalid IP address format" {
+		t.Errorf("unexpected ErrInvalidIP message %q", ErrInvalidIP)
+	}
+}
+
+// TestValidateIP_Valid tests that both families pass ValidateIP, including their boundary addresses
+func TestValidateIP_Valid(t *testing.T) {
+	ips := []string{
+		"0.0.0.0",
+		"255.255.255.255",
+		"8.8.8.8",
+		"::",
+		"::1",
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+		"2001:4860:4860::8888",
+		"2001:DB8::1",
+		"::ffff:8.8.8.8",
+	}
+
+	for _, ip := range ips {
+		if err := ValidateIP(ip); err != nil {
+			t.Errorf("ValidateIP(%q): unexpected error %v", ip, err)
+		}
+		if !IsValidIP(ip) {
+			t.Errorf("IsValidIP(%q): expected true", ip)
+		}
+	}
+}
+
+// TestValidateIPv4 tests that only dotted decimal IPv4 passes
+func TestValidateIPv4(t *testing.T) {
+	for _, ip := range []string{"0.0.0.0", "255.255.255.255", "8.8.8.8", "192.168.1.1"} {
+		if err := ValidateIPv4(ip); err != nil {
+			t.Errorf("ValidateIPv4(%q): unexpected error %v", ip, err)
+		}
+	}
+
+	for _, ip := range []string{"::", "::1", "2001:4860:4860::8888", "::ffff:8.8.8.8", "::ffff:0.0.0.0"} {
+		if err := ValidateIPv4(ip); err != ErrNotIPv4 {
+			t.Errorf("ValidateIPv4(%q): expected ErrNotIPv4, got %v", ip, err)
+		}
+	}
+}
+
+// TestValidateIPv6 tests that IPv6, including IPv4-mapped addresses, passes and IPv4 doesn't
+func TestValidateIPv6(t *testing.T) {
+	for _, ip := range []string{"::", "::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "2001:4860:4860::8888", "::ffff:8.8.8.8"} {
+		if err := ValidateIPv6(ip); err != nil {
+			t.Errorf("ValidateIPv6(%q): unexpected error %v", ip, err)
+		}
+	}
+
+	for _, ip := range []string{"0.0.0.0", "255.255.255.255", "8.8.8.8"} {
+		if err := ValidateIPv6(ip); err != ErrNotIPv6 {
+			t.Errorf("ValidateIPv6(%q): expected ErrNotIPv6, got %v", ip, err)
+		}
+	}
+}
+
+// TestNormalizeAndValidate tests the canonical form returned for each notation
+func TestNormalizeAndValidate(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"8.8.8.8", "8.8.8.8"},
+		{" 8.8.8.8\n", "8.8.8.8"},
+		{"0.0.0.0", "0.0.0.0"},
+		{"255.255.255.255", "255.255.255.255"},
+		{"2001:DB8::1", "2001:db8::1"},
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"0:0:0:0:0:0:0:0", "::"},
+		{"::ffff:8.8.8.8", "8.8.8.8"},
+		{"FFFF:FFFF:FFFF:FFFF:FFFF:FFFF:FFFF:FFFF", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		canonical, err := NormalizeAndValidate(tt.ip)
+		if err != nil {
+			t.Errorf("NormalizeAndValidate(%q): unexpected error %v", tt.ip, err)
+			continue
+		}
+		if canonical != tt.expected {
+			t.Errorf("NormalizeAndValidate(%q): expected %q, got %q", tt.ip, tt.expected, canonical)
+		}
+	}
+
+	for _, ip := range []string{"", "   ", "not-an-ip", "300.1.1.1", "8.8.8.8:53"} {
+		if canonical, err := NormalizeAndValidate(ip); err != ErrInvalidIP || canonical != "" {
+			t.Errorf("NormalizeAndValidate(%q): expected ErrInvalidIP, got %q, %v", ip, canonical, err)
+		}
+	}
+}
@@ -6,15 +6,39 @@ CREATE TABLE IF NOT EXISTS ip2country (
     ip VARCHAR(45) PRIMARY KEY,          -- Supports both IPv4 and IPv6
     city VARCHAR(100) NOT NULL,
     country VARCHAR(100) NOT NULL,
+    country_code CHAR(2) NOT NULL DEFAULT '',   -- ISO 3166-1 alpha-2 (e.g. US), '' if unknown
+    continent_code CHAR(2) NOT NULL DEFAULT '', -- AF, AN, AS, EU, NA, OC or SA, '' if unknown
     INDEX idx_ip (ip)                    -- Index for fast lookups
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- CIDR blocks, stored as unsigned integers so a range lookup is a BETWEEN on the primary key
+-- Looked up when an IP has no row of its own in ip2country
+CREATE TABLE IF NOT EXISTS ip_ranges (
+    ip_start INT UNSIGNED PRIMARY KEY,   -- First address of the block (network start)
+    ip_end INT UNSIGNED NOT NULL,        -- Last address of the block (broadcast address)
+    city VARCHAR(100) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    country_code CHAR(2) NOT NULL DEFAULT '',
+    continent_code CHAR(2) NOT NULL DEFAULT ''
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- IPv6 CIDR blocks, stored as their 16 bytes (too wide for an integer column); bytes compare like the addresses
+CREATE TABLE IF NOT EXISTS ip_ranges6 (
+    ip_start VARBINARY(16) PRIMARY KEY,  -- First address of the block (network start)
+    ip_end VARBINARY(16) NOT NULL,       -- Last address of the block
+    city VARCHAR(100) NOT NULL,
+    country VARCHAR(100) NOT NULL,
+    country_code CHAR(2) NOT NULL DEFAULT '',
+    continent_code CHAR(2) NOT NULL DEFAULT ''
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 -- Insert sample data (we'll add more later)
-INSERT INTO ip2country (ip, city, country) VALUES
-    ('8.8.8.8', 'Mountain View', 'United States'),
-    ('1.1.1.1', 'Sydney', 'Australia'),
-    ('2.22.233.255', 'London', 'United Kingdom')
-ON DUPLICATE KEY UPDATE city=VALUES(city), country=VALUES(country);
+INSERT INTO ip2country (ip, city, country, country_code, continent_code) VALUES
+    ('8.8.8.8', 'Mountain View', 'United States', 'US', 'NA'),
+    ('1.1.1.1', 'Sydney', 'Australia', 'AU', 'OC'),
+    ('2.22.233.255', 'London', 'United Kingdom', 'GB', 'EU')
+ON DUPLICATE KEY UPDATE city=VALUES(city), country=VALUES(country),
+    country_code=VALUES(country_code), continent_code=VALUES(continent_code);
 
 -- Log successful initialization
 SELECT 'MySQL database initialized successfully!' AS message;
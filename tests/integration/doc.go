@@ -0,0 +1,7 @@
+// Package integration holds end-to-end tests of the HTTP API: a real HTTP server in front of the
+// router, middleware, service and stores, with no mocks in between
+//
+// The tests only build with the integration tag, so plain go test skips them:
+//
+//	go test -tags integration ./tests/integration/
+package integration
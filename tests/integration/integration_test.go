@@ -0,0 +1,297 @@
+//go:build integration
+
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/config"
+	"github.com/evyataryagoni/ip2country/internal/limiter"
+	"github.com/evyataryagoni/ip2country/internal/metrics"
+	"github.com/evyataryagoni/ip2country/internal/store"
+	"github.com/evyataryagoni/ip2country/pkg/testutil"
+)
+
+// testCSV is the dataset every test serves, through a CSV store or loaded into Redis
+const testCSV = "testdata/ips.csv"
+
+// defaultMetrics registers the metrics with the default Prometheus registry, which /metrics serves
+// Registering twice panics, so they're created once per test binary however many times the tests run (-count)
+var defaultMetrics = sync.OnceValue(metrics.New)
+
+// newTestServer serves s through testutil.BuildTestServer with the production defaults of the settings
+// the middleware reads (Cache-Control on lookups, /health and /metrics exempt from rate limiting)
+func newTestServer(t *testing.T, s store.Store, opts ...testutil.TestServerOption) *httptest.Server {
+	t.Helper()
+
+	defaults := testutil.WithConfig(func(c *config.Config) {
+		c.NodeID = "integration-node"
+		c.RateLimitExemptPaths = []string{"/health", "/metrics"}
+		c.BackpressureMaxInFlight = 1000
+		c.ResponseCacheMaxAge = 3600
+	})
+	return testutil.BuildTestServer(t, s, append([]testutil.TestServerOption{defaults}, opts...)...)
+}
+
+// get sends a GET request for path to server and returns the response with its body read
+func get(t *testing.T, server *httptest.Server, path string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read the response to GET %s: %v", path, err)
+	}
+	return resp, body
+}
+
+// assertFindCountry looks up the IPs of testCSV, unknown and invalid IPs through server and checks each response
+func assertFindCountry(t *testing.T, server *httptest.Server) {
+	t.Helper()
+
+	tests := []struct {
+		name    string
+		ip      string
+		status  int
+		city    string
+		country string
+		error   string
+	}{
+		{"IPv4", "8.8.8.8", http.StatusOK, "Mountain View", "United States", ""},
+		{"another IPv4", "1.1.1.1", http.StatusOK, "Sydney", "Australia", ""},
+		{"IPv6", "2001:4860:4860::8888", http.StatusOK, "Mountain View", "United States", ""},
+		{"not found", "203.0.113.1", http.StatusNotFound, "", "", "IP address not found"},
+		{"invalid", "not-an-ip", http.StatusBadRequest, "", "", "invalid IP address format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := get(t, server, "/v1/find-country?ip="+tt.ip)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, resp.StatusCode, body)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %q", got)
+			}
+			if got := resp.Header.Get("X-Request-ID"); got == "" {
+				t.Error("expected an X-Request-ID header")
+			}
+			if got := resp.Header.Get("X-Processing-Node"); got != "integration-node" {
+				t.Errorf("expected X-Processing-Node integration-node, got %q", got)
+			}
+
+			var decoded struct {
+				City    string `json:"city"`
+				Country string `json:"country"`
+				Error   string `json:"error"`
+			}
+			if err := json.Unmarshal(body, &decoded); err != nil {
+				t.Fatalf("expected a JSON body, got %s: %v", body, err)
+			}
+			if decoded.City != tt.city || decoded.Country != tt.country || decoded.Error != tt.error {
+				t.Errorf("expected city=%q country=%q error=%q, got %s", tt.city, tt.country, tt.error, body)
+			}
+
+			if tt.status == http.StatusOK {
+				if got := resp.Header.Get("Cache-Control"); !strings.HasPrefix(got, "public, max-age=3600") {
+					t.Errorf("expected a cacheable response, got Cache-Control %q", got)
+				}
+			} else if got := resp.Header.Get("Cache-Control"); got != "no-store" {
+				t.Errorf("expected errors not to be cached, got Cache-Control %q", got)
+			}
+		})
+	}
+}
+
+// TestIntegration_FindCountry_CSV tests lookups end to end over a CSV store
+func TestIntegration_FindCountry_CSV(t *testing.T) {
+	csvStore, err := store.NewCSVStore(testCSV)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	t.Cleanup(func() { csvStore.Close() })
+
+	server := newTestServer(t, csvStore)
+	assertFindCountry(t, server)
+
+	resp, _ := get(t, server, "/v1/find-country?ip=8.8.8.8")
+	if resp.Header.Get("X-Data-Version") == "" {
+		t.Error("expected an X-Data-Version header from the CSV store")
+	}
+}
+
+// TestIntegration_FindCountry_Redis tests lookups end to end over a Redis store loaded from the same CSV file
+func TestIntegration_FindCountry_Redis(t *testing.T) {
+	redisStore := store.NewTestRedisStore(t)
+	if err := redisStore.LoadFromCSV(testCSV); err != nil {
+		t.Fatalf("failed to load Redis: %v", err)
+	}
+
+	server := newTestServer(t, redisStore)
+	assertFindCountry(t, server)
+}
+
+// TestIntegration_RateLimit_Enforced tests that a client gets 429 on its first request over the limit, while probes are exempt
+func TestIntegration_RateLimit_Enforced(t *testing.T) {
+	const limit = 5
+
+	csvStore, err := store.NewCSVStore(testCSV)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	t.Cleanup(func() { csvStore.Close() })
+
+	// A rate this low doesn't refill a token while the requests are sent
+	rateLimiter := limiter.NewMemoryLimiterWithBurst(0.001, limit)
+	t.Cleanup(func() { rateLimiter.Close() })
+	server := newTestServer(t, csvStore, testutil.WithRateLimiter(rateLimiter))
+
+	for i := 1; i <= limit; i++ {
+		if resp, body := get(t, server, "/v1/find-country?ip=8.8.8.8"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d: %s", i, resp.StatusCode, body)
+		}
+	}
+
+	resp, body := get(t, server, "/v1/find-country?ip=8.8.8.8")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("request %d: expected status 429, got %d: %s", limit+1, resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected the 429 not to be cached, got Cache-Control %q", got)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal(body, &decoded); err != nil || !strings.Contains(decoded["error"], "Rate limit exceeded") {
+		t.Errorf("expected a rate limit error, got %s", body)
+	}
+
+	if resp, _ := get(t, server, "/health"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected /health to be exempt from the rate limit, got %d", resp.StatusCode)
+	}
+}
+
+// TestIntegration_Health tests that /health reports the store's check, and 503 once the store is down
+func TestIntegration_Health(t *testing.T) {
+	redisStore := store.NewTestRedisStore(t)
+	if err := redisStore.LoadFromCSV(testCSV); err != nil {
+		t.Fatalf("failed to load Redis: %v", err)
+	}
+	server := newTestServer(t, redisStore, testutil.WithHealthCheck("redis", redisStore.HealthCheck))
+
+	var health struct {
+		Status      string `json:"status"`
+		DataVersion string `json:"data_version"`
+		Checks      map[string]struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"checks"`
+	}
+
+	resp, body := get(t, server, "/health")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, &health); err != nil {
+		t.Fatalf("expected a JSON body, got %s: %v", body, err)
+	}
+	if health.Status != "ok" || health.Checks["redis"].Status != "ok" || health.DataVersion == "" {
+		t.Errorf("expected status ok with a passing redis check and a data version, got %s", body)
+	}
+	if got := resp.Header.Get("Cache-Control"); strings.Contains(got, "max-age") {
+		t.Errorf("expected /health not to be cacheable, got Cache-Control %q", got)
+	}
+
+	store.TestingRedis(t).Close()
+	resp, body = get(t, server, "/health")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 with Redis down, got %d: %s", resp.StatusCode, body)
+	}
+	health.Checks = nil
+	if err := json.Unmarshal(body, &health); err != nil {
+		t.Fatalf("expected a JSON body, got %s: %v", body, err)
+	}
+	if health.Status != "unavailable" || health.Checks["redis"].Status != "error" || health.Checks["redis"].Error == "" {
+		t.Errorf("expected status unavailable with a failing redis check, got %s", body)
+	}
+}
+
+// TestIntegration_Metrics_Prometheus tests that /metrics serves the Prometheus text format, counting the API requests made
+func TestIntegration_Metrics_Prometheus(t *testing.T) {
+	csvStore, err := store.NewCSVStore(testCSV)
+	if err != nil {
+		t.Fatalf("failed to create CSV store: %v", err)
+	}
+	t.Cleanup(func() { csvStore.Close() })
+	server := newTestServer(t, csvStore, testutil.WithMetrics(defaultMetrics()))
+
+	const (
+		found    = `http_requests_total{endpoint="/v1/find-country",method="GET",status="200"}`
+		notFound = `http_requests_total{endpoint="/v1/find-country",method="GET",status="404"}`
+	)
+	before := scrape(t, server)
+
+	for _, ip := range []string{"8.8.8.8", "1.1.1.1", "203.0.113.1"} {
+		get(t, server, "/v1/find-country?ip="+ip)
+	}
+
+	after := scrape(t, server)
+	if got := after[found] - before[found]; got != 2 {
+		t.Errorf("expected %s to grow by 2, got %v", found, got)
+	}
+	if got := after[notFound] - before[notFound]; got != 1 {
+		t.Errorf("expected %s to grow by 1, got %v", notFound, got)
+	}
+	if _, ok := after[`http_request_duration_seconds_count{endpoint="/v1/find-country",method="GET",status="200"}`]; !ok {
+		t.Error("expected request durations for /v1/find-country")
+	}
+}
+
+// scrape fetches /metrics from server and returns each sample's value by series (metric name and labels)
+func scrape(t *testing.T, server *httptest.Server) map[string]float64 {
+	t.Helper()
+
+	resp, body := get(t, server, "/metrics")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 from /metrics, got %d: %s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Fatalf("expected the Prometheus text format, got Content-Type %q", got)
+	}
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		series, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("unexpected line in /metrics: %q", line)
+		}
+		// The value may be followed by a timestamp
+		value, _, _ = strings.Cut(value, " ")
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("unexpected value in /metrics line %q: %v", line, err)
+		}
+		samples[series] = parsed
+	}
+	return samples
+}
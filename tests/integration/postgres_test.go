@@ -0,0 +1,183 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/evyataryagoni/ip2country/internal/store"
+	pkerr "github.com/evyataryagoni/ip2country/pkg/errors"
+	"github.com/evyataryagoni/ip2country/pkg/testutil"
+	"github.com/jackc/pgx/v5"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// postgresImage is the PostgreSQL image the tests run against
+const postgresImage = "postgres:16-alpine"
+
+// newPostgresStore starts PostgreSQL in a container, creates the schema through PG_AUTO_MIGRATE and loads testCSV
+// Skipped when Docker isn't available
+func newPostgresStore(t *testing.T) (*store.PostgreSQLStore, *postgres.PostgresContainer) {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	container, err := postgres.Run(ctx, postgresImage,
+		postgres.WithDatabase("ip2country"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("password"),
+		postgres.BasicWaitStrategies(),
+	)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start PostgreSQL: %v", err)
+	}
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get the PostgreSQL DSN: %v", err)
+	}
+
+	pgStore, err := store.NewPostgreSQLStoreWithOptions(dsn, store.PostgreSQLStoreOptions{AutoMigrate: true, MaxConns: 4})
+	if err != nil {
+		t.Fatalf("failed to create PostgreSQL store: %v", err)
+	}
+	t.Cleanup(func() { pgStore.Close() })
+
+	loadPostgres(t, ctx, dsn)
+	return pgStore, container
+}
+
+// loadPostgres inserts each ip,city,country row of testCSV as a single-IP range
+func loadPostgres(t *testing.T, ctx context.Context, dsn string) {
+	t.Helper()
+
+	file, err := os.Open(testCSV)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", testCSV, err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", testCSV, err)
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	for _, record := range records[1:] {
+		_, err := conn.Exec(ctx, `INSERT INTO ip2country (ip_start, ip_end, city, country) VALUES ($1::inet, $1::inet, $2, $3)`,
+			record[0], record[1], record[2])
+		if err != nil {
+			t.Fatalf("failed to insert %s: %v", record[0], err)
+		}
+	}
+}
+
+// TestIntegration_FindCountry_Postgres tests lookups end to end over a PostgreSQL store loaded from the same CSV file
+func TestIntegration_FindCountry_Postgres(t *testing.T) {
+	pgStore, _ := newPostgresStore(t)
+	server := newTestServer(t, pgStore)
+	assertFindCountry(t, server)
+}
+
+// TestIntegration_FindByIP_PostgresRange tests that addresses strictly inside a multi-address range match it
+func TestIntegration_FindByIP_PostgresRange(t *testing.T) {
+	pgStore, container := newPostgresStore(t)
+
+	ctx := context.Background()
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get the PostgreSQL DSN: %v", err)
+	}
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL: %v", err)
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, `INSERT INTO ip2country (ip_start, ip_end, city, country) VALUES
+		('198.51.100.0', '198.51.100.255', 'Range City', 'Testland'),
+		('2001:db8::', '2001:db8::ffff', 'Range City v6', 'Testland')`)
+	if err != nil {
+		t.Fatalf("failed to insert the ranges: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		city string
+	}{
+		{"inside IPv4 range", "198.51.100.77", "Range City"},
+		{"IPv4 range start", "198.51.100.0", "Range City"},
+		{"IPv4 range end", "198.51.100.255", "Range City"},
+		{"inside IPv6 range", "2001:db8::1234", "Range City v6"},
+		{"past IPv4 range", "198.51.101.0", ""},
+		{"before IPv4 range", "198.51.99.255", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, err := pgStore.FindByIP(ctx, tt.ip)
+			if tt.city == "" {
+				if !errors.Is(err, pkerr.ErrNotFound) {
+					t.Errorf("expected ErrNotFound, got %v, %v", location, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if location.City != tt.city || location.Country != "Testland" {
+				t.Errorf("expected %s, Testland, got %s, %s", tt.city, location.City, location.Country)
+			}
+		})
+	}
+}
+
+// TestIntegration_Health_Postgres tests that /health reports the PostgreSQL store's check, and 503 once PostgreSQL is down
+func TestIntegration_Health_Postgres(t *testing.T) {
+	pgStore, container := newPostgresStore(t)
+	server := newTestServer(t, pgStore, testutil.WithHealthCheck("postgres", pgStore.HealthCheck))
+
+	var health struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Status string `json:"status"`
+		} `json:"checks"`
+	}
+
+	resp, body := get(t, server, "/health")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, &health); err != nil {
+		t.Fatalf("expected a JSON body, got %s: %v", body, err)
+	}
+	if health.Status != "ok" || health.Checks["postgres"].Status != "ok" {
+		t.Errorf("expected status ok with a passing postgres check, got %s", body)
+	}
+
+	if err := container.Stop(context.Background(), nil); err != nil {
+		t.Fatalf("failed to stop PostgreSQL: %v", err)
+	}
+	resp, body = get(t, server, "/health")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 with PostgreSQL down, got %d: %s", resp.StatusCode, body)
+	}
+	health.Checks = nil
+	if err := json.Unmarshal(body, &health); err != nil {
+		t.Fatalf("expected a JSON body, got %s: %v", body, err)
+	}
+	if health.Checks["postgres"].Status != "error" {
+		t.Errorf("expected a failing postgres check, got %s", body)
+	}
+}